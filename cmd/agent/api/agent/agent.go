@@ -25,6 +25,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/collector/py"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/flare"
+	"github.com/DataDog/datadog-agent/pkg/logs"
 	"github.com/DataDog/datadog-agent/pkg/status"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 	"github.com/DataDog/datadog-agent/pkg/tagger"
@@ -50,6 +51,7 @@ func SetupHandlers(r *mux.Router) {
 	r.HandleFunc("/config-check", getConfigCheck).Methods("GET")
 	r.HandleFunc("/config", getRuntimeConfig).Methods("GET")
 	r.HandleFunc("/tagger-list", getTaggerList).Methods("GET")
+	r.HandleFunc("/logs-registry", getLogsRegistry).Methods("GET")
 }
 
 func stopAgent(w http.ResponseWriter, r *http.Request) {
@@ -255,3 +257,20 @@ func getTaggerList(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Write(jsonTags)
 }
+
+func getLogsRegistry(w http.ResponseWriter, r *http.Request) {
+	if !logs.IsAgentRunning() {
+		body, _ := json.Marshal(map[string]string{"error": "logs-agent is not running"})
+		http.Error(w, string(body), 404)
+		return
+	}
+
+	jsonRegistry, err := json.Marshal(logs.GetRegistry())
+	if err != nil {
+		log.Errorf("Unable to marshal logs registry response: %s", err)
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(body), 500)
+		return
+	}
+	w.Write(jsonRegistry)
+}
@@ -15,6 +15,7 @@ import (
 	"os"
 	"os/signal"
 
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
 	"github.com/DataDog/datadog-agent/cmd/agent/common/signals"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/spf13/cobra"
@@ -92,6 +93,17 @@ func run(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
+	// Reload the logs configurations upon SIGHUP, the sources that did not change keep being collected
+	// from their current offset while the new ones are collected from their start position.
+	sighupCh := make(chan os.Signal, 1)
+	signal.Notify(sighupCh, syscall.SIGHUP)
+	go func() {
+		for range sighupCh {
+			log.Info("Received SIGHUP, reloading the logs configurations...")
+			common.ReloadLogsConfigs()
+		}
+	}()
+
 	if err := StartAgent(); err != nil {
 		return err
 	}
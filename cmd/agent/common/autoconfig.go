@@ -48,12 +48,7 @@ func SetupAutoConfig(confdPath string) {
 
 	// Add the configuration providers
 	// File Provider is hardocded and always enabled
-	confSearchPaths := []string{
-		confdPath,
-		filepath.Join(GetDistPath(), "conf.d"),
-		"",
-	}
-	AC.AddProvider(providers.NewFileConfigProvider(confSearchPaths), false)
+	AC.AddProvider(providers.NewFileConfigProvider(confSearchPaths(confdPath)), false)
 
 	// Register additional configuration providers
 	var CP []config.ConfigurationProviders
@@ -90,6 +85,34 @@ func SetupAutoConfig(confdPath string) {
 	}
 }
 
+// ReloadLogsConfigs collects the configuration files again
+// and reloads the logs sources defined in them.
+func ReloadLogsConfigs() {
+	logsScheduler := logs.GetScheduler()
+	if logsScheduler == nil {
+		return
+	}
+	provider := providers.NewFileConfigProvider(confSearchPaths(config.Datadog.GetString("confd_path")))
+	configs, err := provider.Collect()
+	if err != nil {
+		log.Errorf("Unable to collect the configuration files: %v", err)
+		return
+	}
+	for i := range configs {
+		configs[i].Provider = provider.String()
+	}
+	logsScheduler.Reload(configs)
+}
+
+// confSearchPaths returns the paths the configuration files are looked up in.
+func confSearchPaths(confdPath string) []string {
+	return []string{
+		confdPath,
+		filepath.Join(GetDistPath(), "conf.d"),
+		"",
+	}
+}
+
 // StartAutoConfig starts the autoconfig:
 //   1. start polling the providers
 //   2. load all the configurations available at startup
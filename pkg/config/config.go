@@ -284,10 +284,24 @@ func initConfig(config Config) {
 	config.BindEnvAndSetDefault("log_enabled", false) // deprecated, use logs_enabled instead
 	// collect all logs from all containers:
 	config.BindEnvAndSetDefault("logs_config.container_collect_all", false)
+	// collect the logs the containers running before the agent started emitted since they were created,
+	// up to max_age seconds back and max_bytes of logs per container, instead of only their new logs:
+	config.BindEnvAndSetDefault("logs_config.container_tail_from_start", false)
+	config.BindEnvAndSetDefault("logs_config.container_tail_from_start_max_age", 3600)
+	config.BindEnvAndSetDefault("logs_config.container_tail_from_start_max_bytes", 10*1024*1024)
+	// collect the logs of the containers annotated with a logs-config by polling the kubelet instead of using the docker socket:
+	config.BindEnvAndSetDefault("logs_config.k8s_pod_annotations", false)
+	// collect the logs of all the containers from the files written by the CRI runtimes (containerd, CRI-O) in the pods directory,
+	// without relying on the docker socket nor on the kubelet:
+	config.BindEnvAndSetDefault("logs_config.cri_collect_all", false)
+	config.BindEnvAndSetDefault("logs_config.cri_pods_path", "/var/log/pods")
 	// collect all logs forwarded by TCP on a specific port:
 	config.BindEnvAndSetDefault("logs_config.tcp_forward_port", -1)
 	// add a socks5 proxy:
 	config.BindEnvAndSetDefault("logs_config.socks5_proxy_address", "")
+	// or an http proxy, as an http or https URL, the logs are tunneled through it with the CONNECT method,
+	// both can be overridden by each additional and failover endpoint:
+	config.BindEnvAndSetDefault("logs_config.http_proxy", "")
 	// send the logs to a proxy:
 	config.BindEnvAndSetDefault("logs_config.logs_dd_url", "") // must respect format '<HOST>:<PORT>' and '<PORT>' to be an integer
 	config.BindEnvAndSetDefault("logs_config.logs_no_ssl", false)
@@ -297,6 +311,163 @@ func initConfig(config Config) {
 	config.BindEnvAndSetDefault("logs_config.frame_size", 9000)
 	// increase the number of files that can be tailed in parallel:
 	config.BindEnvAndSetDefault("logs_config.open_files_limit", 100)
+	// close the files which stopped growing to tail the files above the limit of open files, "ignore" or "rotate":
+	config.BindEnvAndSetDefault("logs_config.open_files_limit_policy", "ignore")
+	// refuse to start the logs-agent when the offsets can not be persisted in run_path:
+	config.BindEnvAndSetDefault("logs_config.require_writable_run_path", false)
+	// drop the logs instead of blocking the inputs when a pipeline is full, "block", "drop_newest" or "drop_oldest":
+	config.BindEnvAndSetDefault("logs_config.overflow_policy", "block")
+	// send all the logs of a file or a journal to the same pipeline to keep them in order:
+	config.BindEnvAndSetDefault("logs_config.pipeline_affinity", true)
+	// process and send the logs in parallel in several pipelines, they can be scaled at runtime up to this number,
+	// or up to autoscale.max_pipelines when autoscaling is enabled:
+	config.BindEnvAndSetDefault("logs_config.pipelines", 4)
+	// add a pipeline when an input buffer is filled above scale_up_threshold in percentage of its capacity while the main
+	// destination accepts the logs, and remove one when all the buffers stay below scale_down_threshold for a minute
+	// at the default interval in seconds,
+	// keeping between min_pipelines and max_pipelines, logs_config.pipelines is then the initial number of pipelines:
+	config.BindEnvAndSetDefault("logs_config.autoscale.enabled", false)
+	config.BindEnvAndSetDefault("logs_config.autoscale.interval", 10)
+	config.BindEnvAndSetDefault("logs_config.autoscale.min_pipelines", 1)
+	config.BindEnvAndSetDefault("logs_config.autoscale.max_pipelines", 8)
+	config.BindEnvAndSetDefault("logs_config.autoscale.scale_up_threshold", 80)
+	config.BindEnvAndSetDefault("logs_config.autoscale.scale_down_threshold", 20)
+	// apply the processing rules in parallel in each pipeline:
+	config.BindEnvAndSetDefault("logs_config.processor_workers", 1)
+	// report the fill level of the buffers of the pipelines every interval in seconds, 0 disables it,
+	// and warn when a buffer is filled above the threshold in percentage of its capacity:
+	config.BindEnvAndSetDefault("logs_config.buffer_utilization_interval", 10)
+	config.BindEnvAndSetDefault("logs_config.buffer_utilization_warning_threshold", 80)
+	// send the logs formatted in CEF (Common Event Format) to a SIEM, using logs_config.logs_dd_url:
+	config.BindEnvAndSetDefault("logs_config.use_cef", false)
+	// send the logs as JSON objects holding their metadata, one per line, to a custom sink using logs_config.logs_dd_url,
+	// the logs which are valid JSON are embedded as objects instead of strings when json_raw_message is set:
+	config.BindEnvAndSetDefault("logs_config.use_json", false)
+	config.BindEnvAndSetDefault("logs_config.json_raw_message", false)
+	// post the logs to an HTTP intake at http_path, authenticated with the API key as header, instead of sending
+	// them over TCP, each additional endpoint does with its own use_http setting:
+	config.BindEnvAndSetDefault("logs_config.use_http", false)
+	config.BindEnvAndSetDefault("logs_config.http_path", "/v1/input")
+	// compress the logs posted to the HTTP intake with gzip or zstd at compression_level, 0 means the default level,
+	// each additional endpoint does with its own compression setting:
+	config.BindEnvAndSetDefault("logs_config.compression", "")
+	config.BindEnvAndSetDefault("logs_config.compression_level", 0)
+	// limit the number of connection attempts in progress at the same time across all destinations, 0 means no limit:
+	config.BindEnvAndSetDefault("logs_config.max_concurrent_connection_attempts", 0)
+	// add the number of bytes the tailer was behind the end of the file when reading them to the attributes
+	// of the file logs, this costs an additional stat of the file per read. Like any attribute, it makes the
+	// logs sent as {"message": "<log>", "collection_lag_bytes": <lag>} so that the JSON logs are sent as
+	// escaped strings, unless the logs are sent in CEF which writes it as an extension:
+	config.BindEnvAndSetDefault("logs_config.collection_lag_attribute", false)
+	// drop the logs older than this age in seconds when they are about to be sent, 0 means no limit, the age is only
+	// known for the logs of the containers and the syslog sources and the ones timestamped by the processing rules:
+	config.BindEnvAndSetDefault("logs_config.max_message_age", 0)
+	// policy applied when several sources match the same file, either "precedence" to tail it for the source
+	// with the highest precedence or the first one added, or "error" to not tail it and to report the sources
+	// whose paths can match the same files as errors when they are validated:
+	config.BindEnvAndSetDefault("logs_config.file_conflict_policy", "precedence")
+	// publish the logs to an AMQP exchange instead of sending them to Datadog when the url is set,
+	// the routing key is the value of the tag with the routing key tag as key or the service of the logs:
+	config.BindEnvAndSetDefault("logs_config.amqp_url", "")
+	config.BindEnvAndSetDefault("logs_config.amqp_exchange", "logs")
+	config.BindEnvAndSetDefault("logs_config.amqp_exchange_type", "topic")
+	config.BindEnvAndSetDefault("logs_config.amqp_durable", true)
+	config.BindEnvAndSetDefault("logs_config.amqp_routing_key_tag", "")
+	// publish the logs to the topic of a Kafka cluster instead of sending them to Datadog when the brokers are set,
+	// the logs of the sources setting kafka_topic are published to their own topic:
+	config.BindEnvAndSetDefault("logs_config.kafka_brokers", []string{})
+	config.BindEnvAndSetDefault("logs_config.kafka_topic", "logs")
+	// names of the container environment variables and labels whose values are attached as tags to the container logs,
+	// the names ending with * allow all the names starting with the rest of the name, which is left out of the tags,
+	// for example com.datadoghq.tags.* turns the label com.datadoghq.tags.env=prod into env:prod,
+	// the other environment variables and labels are never collected:
+	config.BindEnvAndSetDefault("logs_config.container_env_as_tags", []string{})
+	config.BindEnvAndSetDefault("logs_config.container_labels_as_tags", []string{})
+	// policy applied when the pre-send hook registered by an embedder fails, either "drop" to drop the message
+	// or "send" to send it unmodified, the messages rejected by the hook are always dropped:
+	config.BindEnvAndSetDefault("logs_config.pre_send_hook_error_policy", "drop")
+	// number of goroutines reading all the files tailed, 0 to read each file in a goroutine of its own,
+	// this saves one goroutine per file tailed, the goroutines decoding each file and the ones of the network
+	// and container inputs are not pooled, so the number of goroutines still grows with the inputs:
+	config.BindEnvAndSetDefault("logs_config.file_reader_workers", 0)
+	// time in seconds after which the last line of a file is sent when it is not terminated by a line feed
+	// and the file does not grow anymore, unlike the multi-line flush timeout it applies to all the files, 0 means never:
+	config.BindEnvAndSetDefault("logs_config.eof_flush_timeout", 0)
+	// drop a share of the messages with the statuses listed when the pipelines are congested, the share grows
+	// linearly from 0 when their buffer is filled up to the low watermark to the max drop rate at the high watermark:
+	config.BindEnvAndSetDefault("logs_config.adaptive_sampling", false)
+	config.BindEnvAndSetDefault("logs_config.adaptive_sampling_low_watermark", 0.5)
+	config.BindEnvAndSetDefault("logs_config.adaptive_sampling_high_watermark", 0.9)
+	config.BindEnvAndSetDefault("logs_config.adaptive_sampling_max_drop_rate", 0.9)
+	config.BindEnvAndSetDefault("logs_config.adaptive_sampling_statuses", []string{"debug"})
+	// drop the messages identical to a message of the same source sent within the window in seconds, 0 means never,
+	// the hashes of up to size messages are remembered per source, a message repeated after more is sent again:
+	config.BindEnvAndSetDefault("logs_config.dedup_window", 0)
+	config.BindEnvAndSetDefault("logs_config.dedup_window_size", 1000)
+	// delays in seconds between the attempts to send logs to an unreachable endpoint, the delay doubles
+	// after each failure from the base up to the max, a random jitter spreads the attempts of the agents:
+	config.BindEnvAndSetDefault("logs_config.sender.backoff_base", 2)
+	config.BindEnvAndSetDefault("logs_config.sender.backoff_max", 30)
+	// head start in seconds given to the IPv6 addresses of a dual-stack intake before its IPv4 addresses are dialed,
+	// a negative value dials the addresses one after the other:
+	config.BindEnvAndSetDefault("logs_config.connection_fallback_delay", 0.3)
+	// time in seconds after which a write to an intake which stopped reading the logs fails, the connection
+	// is then re-established with the backoff and the failure counts toward the circuit breaker, 0 means never:
+	config.BindEnvAndSetDefault("logs_config.sender.write_timeout", 30)
+	// period in seconds of the TCP keep-alive probes detecting the connections dropped silently, a negative value
+	// disables them, and times in seconds after which a connection is re-established before the next write,
+	// since it was established and since the last write, to rebalance the connections over the backends
+	// of a load balancer and shed the connections it dropped silently, 0 means never:
+	config.BindEnvAndSetDefault("logs_config.sender.keep_alive", 15)
+	config.BindEnvAndSetDefault("logs_config.sender.max_connection_age", 0)
+	config.BindEnvAndSetDefault("logs_config.sender.max_connection_idle", 0)
+	// number of failed attempts to reconnect to the main endpoint, following a first failure, after which the logs
+	// are dropped until the agent is restarted and the embedding application is notified, 0 means never:
+	config.BindEnvAndSetDefault("logs_config.sender.max_reconnect_attempts", 0)
+	// drop the logs without trying to send them for a cooldown in seconds once a destination failed too many times
+	// in a row, until a single attempt probes it again, 0 failures means never, the logs sent to the main and
+	// failover destinations are retried until they are sent by default:
+	config.BindEnvAndSetDefault("logs_config.circuit_breaker.threshold", 5)
+	config.BindEnvAndSetDefault("logs_config.circuit_breaker.main_threshold", 0)
+	config.BindEnvAndSetDefault("logs_config.circuit_breaker.cooldown", 30)
+	// size in bytes above which the processed messages are truncated and ended with the marker,
+	// it defaults to the largest message accepted by the backend, 0 means never:
+	config.BindEnvAndSetDefault("logs_config.max_message_size", 256*1000)
+	config.BindEnvAndSetDefault("logs_config.truncation_marker", "...TRUNCATED...")
+	// length in bytes above which the lines read are truncated and ended with the marker, the rest of the line
+	// is discarded, 0 means the lines too long to be received are split instead:
+	config.BindEnvAndSetDefault("logs_config.max_line_length", 0)
+	// number of bytes read from a file at most at once, lower it to collect many small files,
+	// raise it to collect few large files:
+	config.BindEnvAndSetDefault("logs_config.read_buffer_size", 4096)
+	// mask the credit card numbers and the bearer tokens found in the logs of all the sources:
+	config.BindEnvAndSetDefault("logs_config.scrub_secrets", false)
+	// spill the logs to a queue on disk under run_path when they can not be sent fast enough, during network outages for example,
+	// the oldest logs are dropped first once the queue holds disk_buffer_max_size bytes or disk_buffer_max_messages logs, 0 means no limit:
+	config.BindEnvAndSetDefault("logs_config.disk_buffer", false)
+	config.BindEnvAndSetDefault("logs_config.disk_buffer_max_size", 100*1024*1024)
+	config.BindEnvAndSetDefault("logs_config.disk_buffer_max_messages", 0)
+	// send the logs in a single write by batches of up to batch_max_count logs and batch_max_size bytes,
+	// a batch is sent at the latest batch_max_linger_ms after its first log, a count lower than 2 disables batching:
+	config.BindEnvAndSetDefault("logs_config.batch_max_count", 1)
+	config.BindEnvAndSetDefault("logs_config.batch_max_size", 1000*1000)
+	config.BindEnvAndSetDefault("logs_config.batch_max_linger_ms", 100)
+	// when failover_endpoints is set, the logs are sent to the first healthy endpoint of the main one then failover_endpoints,
+	// the endpoints are probed every failover_probe_interval seconds, demoted after failover_failure_threshold failed probes
+	// in a row and promoted back after failover_recovery_threshold successful probes in a row:
+	config.BindEnvAndSetDefault("logs_config.failover_probe_interval", 10)
+	config.BindEnvAndSetDefault("logs_config.failover_failure_threshold", 3)
+	config.BindEnvAndSetDefault("logs_config.failover_recovery_threshold", 6)
+	// add the tags of the host to the logs of the sources not setting exclude_host_tags, only the ones with a key
+	// in host_tags_include if set, the tags are resolved again every host_tags_refresh_interval seconds:
+	config.BindEnvAndSetDefault("logs_config.host_tags", false)
+	config.BindEnvAndSetDefault("logs_config.host_tags_include", []string{})
+	config.BindEnvAndSetDefault("logs_config.host_tags_refresh_interval", 300)
+	// client certificate, its key and certificate authorities used to connect to an intake requiring mutual TLS,
+	// the additional endpoints set their own, the files are read again on each connection:
+	config.BindEnvAndSetDefault("logs_config.tls_cert_path", "")
+	config.BindEnvAndSetDefault("logs_config.tls_key_path", "")
+	config.BindEnvAndSetDefault("logs_config.tls_ca_path", "")
 
 	// Internal Use Only: avoid modifying those configuration parameters, this could lead to unexpected results.
 	config.BindEnvAndSetDefault("logset", "")
@@ -306,6 +477,10 @@ func initConfig(config Config) {
 	config.BindEnvAndSetDefault("logs_config.dev_mode_use_proto", true)
 	config.BindEnvAndSetDefault("logs_config.dd_url_443", "agent-443-intake.logs.datadoghq.com")
 	config.BindEnvAndSetDefault("logs_config.stop_grace_period", 30)
+	// keep trying to send the pending logs until one second before the hard deadline instead of dropping them at the end of the grace period when stopping:
+	config.BindEnvAndSetDefault("logs_config.stop_drain", false)
+	// the number of seconds after which stopping returns even if the logs in flight are not flushed yet:
+	config.BindEnvAndSetDefault("logs_config.stop_hard_deadline", 60)
 
 	// Tagger full cardinality mode
 	// Undocumented opt-in feature for now
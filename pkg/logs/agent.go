@@ -6,6 +6,7 @@
 package logs
 
 import (
+	"context"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/status/health"
@@ -15,13 +16,18 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/input/container"
+	"github.com/DataDog/datadog-agent/pkg/logs/input/cri"
 	"github.com/DataDog/datadog-agent/pkg/logs/input/file"
 	"github.com/DataDog/datadog-agent/pkg/logs/input/journald"
+	"github.com/DataDog/datadog-agent/pkg/logs/input/kubernetes"
 	"github.com/DataDog/datadog-agent/pkg/logs/input/listener"
+	"github.com/DataDog/datadog-agent/pkg/logs/input/namedpipe"
 	"github.com/DataDog/datadog-agent/pkg/logs/input/windowsevent"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 	"github.com/DataDog/datadog-agent/pkg/logs/restart"
 	"github.com/DataDog/datadog-agent/pkg/logs/service"
+	"github.com/DataDog/datadog-agent/pkg/logs/status"
 )
 
 // Agent represents the data pipeline that collects, decodes,
@@ -32,6 +38,7 @@ import (
 // |                                                        |
 // + ------------------------------------------------------ +
 type Agent struct {
+	sources          *config.LogSources
 	auditor          *auditor.Auditor
 	destinationsCtx  *client.DestinationsContext
 	pipelineProvider pipeline.Provider
@@ -47,21 +54,31 @@ func NewAgent(sources *config.LogSources, services *service.Services, endpoints
 	// We pass the health handle to the auditor because it's the end of the pipeline and the most
 	// critical part. Arguably it could also be plugged to the destination.
 	auditor := auditor.New(config.LogsAgent.GetString("logs_config.run_path"), health)
-	destinationsCtx := client.NewDestinationsContext()
+	// setup the limiter shared by all destinations to stagger reconnections
+	connectionLimiter := client.NewConnectionLimiter(config.LogsAgent.GetInt("logs_config.max_concurrent_connection_attempts"))
+	destinationsCtx := client.NewDestinationsContext(connectionLimiter)
 
 	// setup the pipeline provider that provides pairs of processor and sender
-	pipelineProvider := pipeline.NewProvider(config.NumberOfPipelines, auditor, endpoints, destinationsCtx)
+	numberOfPipelines := config.LogsAgent.GetInt("logs_config.pipelines")
+	if numberOfPipelines < 1 {
+		numberOfPipelines = config.NumberOfPipelines
+	}
+	pipelineProvider := pipeline.NewProvider(numberOfPipelines, auditor, endpoints, destinationsCtx)
+	// the tees of the sources removed are stopped
+	sources.OnRemoved(pipelineProvider.ReleaseSource)
 
 	// setup the inputs
 	inputs := []restart.Restartable{
-		file.NewScanner(sources, config.LogsAgent.GetInt("logs_config.open_files_limit"), pipelineProvider, auditor, file.DefaultSleepDuration),
-		container.NewLauncher(sources, services, pipelineProvider, auditor),
+		file.NewScanner(sources, config.LogsAgent.GetInt("logs_config.open_files_limit"), pipelineProvider, auditor, file.DefaultSleepDuration, config.LogsAgent.GetBool("logs_config.collection_lag_attribute")),
+		newContainerInput(sources, services, pipelineProvider, auditor),
 		listener.NewLauncher(sources, config.LogsAgent.GetInt("logs_config.frame_size"), pipelineProvider),
 		journald.NewLauncher(sources, pipelineProvider, auditor),
-		windowsevent.NewLauncher(sources, pipelineProvider),
+		windowsevent.NewLauncher(sources, pipelineProvider, auditor),
+		namedpipe.NewLauncher(sources, pipelineProvider),
 	}
 
 	return &Agent{
+		sources:          sources,
 		auditor:          auditor,
 		destinationsCtx:  destinationsCtx,
 		pipelineProvider: pipelineProvider,
@@ -70,6 +87,22 @@ func NewAgent(sources *config.LogSources, services *service.Services, endpoints
 	}
 }
 
+// newContainerInput returns the input collecting the logs of the containers,
+// the CRI launcher or the pod provider replace the container launcher when enabled so that the containers are not collected twice.
+func newContainerInput(sources *config.LogSources, services *service.Services, pipelineProvider pipeline.Provider, registry auditor.Registry) restart.Restartable {
+	if config.LogsAgent.GetBool("logs_config.cri_collect_all") {
+		return cri.NewLauncher(sources, config.LogsAgent.GetString("logs_config.cri_pods_path"))
+	}
+	if config.LogsAgent.GetBool("logs_config.k8s_pod_annotations") {
+		provider, err := kubernetes.NewPodProvider(sources)
+		if err == nil {
+			return provider
+		}
+		log.Warnf("Could not setup the kubernetes pod provider, falling back to the container launcher: %v", err)
+	}
+	return container.NewLauncher(sources, services, pipelineProvider, registry)
+}
+
 // Start starts all the elements of the data pipeline
 // in the right order to prevent data loss
 func (a *Agent) Start() {
@@ -80,9 +113,26 @@ func (a *Agent) Start() {
 	starter.Start()
 }
 
+// stopFlushWindow is the time left before the hard deadline to the destinations to drop the pending logs
+// once the agent is done draining them when stopping.
+const stopFlushWindow = time.Second
+
+// StopSummary reports what happened to the logs in flight while the agent was stopping.
+type StopSummary struct {
+	// Delivered is the number of logs sent while stopping.
+	Delivered int64
+	// Dropped is the number of logs dropped while stopping, these logs are not sent.
+	Dropped int64
+	// TimedOut is true when the hard deadline was reached before the pipeline was flushed,
+	// the logs still in flight are then lost without being counted.
+	TimedOut bool
+}
+
 // Stop stops all the elements of the data pipeline
-// in the right order to prevent data loss
-func (a *Agent) Stop() {
+// in the right order to prevent data loss,
+// returns how many logs were delivered and dropped while stopping.
+func (a *Agent) Stop() StopSummary {
+	before := a.sourcesThroughput()
 	inputs := restart.NewParallelStopper()
 	for _, input := range a.inputs {
 		inputs.Add(input)
@@ -104,7 +154,21 @@ func (a *Agent) Stop() {
 		stopper.Stop()
 		close(c)
 	}()
-	timeout := time.Duration(config.LogsAgent.GetInt("logs_config.stop_grace_period")) * time.Second
+	gracePeriod := time.Duration(config.LogsAgent.GetInt("logs_config.stop_grace_period")) * time.Second
+	hardDeadline := time.Duration(config.LogsAgent.GetInt("logs_config.stop_hard_deadline")) * time.Second
+	if hardDeadline < gracePeriod {
+		hardDeadline = gracePeriod
+	}
+	deadline := time.NewTimer(hardDeadline)
+	defer deadline.Stop()
+	// when draining, the senders keep retrying to send the pending logs until shortly before the hard deadline,
+	// leaving the destinations the time to drop what is still pending so that it is counted
+	timeout := gracePeriod
+	if config.LogsAgent.GetBool("logs_config.stop_drain") && hardDeadline-stopFlushWindow > gracePeriod {
+		timeout = hardDeadline - stopFlushWindow
+	}
+
+	summary := StopSummary{}
 	select {
 	case <-c:
 	case <-time.After(timeout):
@@ -112,7 +176,84 @@ func (a *Agent) Stop() {
 		// We force all destinations to read/flush all the messages they get without
 		// trying to write to the network.
 		a.destinationsCtx.Stop()
-		// Wait again for the stopper to complete.
-		<-c
+		// Wait again for the stopper to complete, up to the hard deadline.
+		select {
+		case <-c:
+		case <-deadline.C:
+			log.Warn("Reached the hard deadline when stopping logs-agent, the logs in flight are lost")
+			summary.TimedOut = true
+		}
+	}
+
+	after := a.sourcesThroughput()
+	summary.Delivered = after.Sent - before.Sent
+	summary.Dropped = after.Dropped - before.Dropped
+	return summary
+}
+
+// Flush blocks until the logs held by the pipelines when it is called are sent or dropped, without stopping
+// the agent so that the logs keep being collected, returns an error if ctx is done first, in which case
+// some of these logs may still be in flight. The logs are not waited for to be sent to the additional endpoints,
+// nor for their offsets to be committed.
+func (a *Agent) Flush(ctx context.Context) error {
+	return a.pipelineProvider.Flush(ctx)
+}
+
+// sourcesThroughput returns the logs counted so far by all the sources.
+func (a *Agent) sourcesThroughput() metrics.Throughput {
+	throughput := metrics.Throughput{}
+	for _, source := range a.sources.GetSources() {
+		throughput = throughput.Add(source.Throughput.Value())
+	}
+	return throughput
+}
+
+// Health returns the state of the delivery of the logs, independently of the health handle of the agent
+// which only tells whether the auditor keeps up: the number of pipelines failing to send the logs,
+// the number of pipelines of which the inputs are blocked, the last successful send by destination,
+// the fill level of the buffers of the pipelines and the logs waiting to be sent.
+func (a *Agent) Health() status.Health {
+	delivery := a.pipelineProvider.Health()
+	health := status.NewHealth(delivery.BlockedPipelines, delivery.BackpressuredPipelines, delivery.LastSuccessfulSends)
+	if len(delivery.ActiveDestinations) > 0 {
+		health.ActiveDestinations = delivery.ActiveDestinations
+	}
+	if len(delivery.OpenCircuits) > 0 {
+		health.OpenCircuits = delivery.OpenCircuits
+	}
+	utilization := a.pipelineProvider.BufferUtilization()
+	health.BufferUtilization = status.BufferUtilization{
+		MaxInput:  utilization.MaxInput,
+		AvgInput:  utilization.AvgInput,
+		MaxSender: utilization.MaxSender,
+		AvgSender: utilization.AvgSender,
 	}
+	backlog := a.pipelineProvider.Backlog()
+	health.Backlog = status.Backlog{
+		Buffered:         backlog.Buffered,
+		OldestAgeSeconds: backlog.OldestAge(time.Now()).Seconds(),
+	}
+	return health
+}
+
+// SourceStatuses returns the state of the collection of each source, pending, running or error,
+// along with the last error reported by its inputs, so that the broken sources can be told apart.
+func (a *Agent) SourceStatuses() []status.SourceStatus {
+	return status.GetSourceStatuses(a.sources)
+}
+
+// OnTerminalFailure sets the handler called once when the logs can not be sent anymore because the main destination
+// gave up reconnecting after logs_config.sender.max_reconnect_attempts, the logs are then dropped until the agent
+// is restarted. The handler is called from a goroutine of its own so that it can stop the agent or exit.
+func (a *Agent) OnTerminalFailure(handler func(err error)) {
+	a.destinationsCtx.OnTerminalFailure(handler)
+}
+
+// ScalePipelines adds or removes pipelines until there are numberOfPipelines of them without restarting the agent,
+// up to the number of pipelines the agent was started with or logs_config.autoscale.max_pipelines when autoscaling
+// is enabled, the pipelines removed are stopped once the messages they hold are sent. When logs_config.autoscale
+// is enabled, the autoscaler keeps scaling the pipelines from there within its bounds.
+func (a *Agent) ScalePipelines(numberOfPipelines int) {
+	log.Infof("Scaling the logs pipelines to %d", numberOfPipelines)
+	a.pipelineProvider.Scale(numberOfPipelines)
 }
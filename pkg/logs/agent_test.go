@@ -15,10 +15,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/providers"
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/scheduler"
 	"github.com/DataDog/datadog-agent/pkg/logs/service"
 )
 
@@ -55,6 +58,8 @@ func (suite *AgentTestSuite) SetupTest() {
 	config.LogsAgent.Set("logs_config.run_path", suite.testDir)
 	// Shorter grace period for tests.
 	config.LogsAgent.Set("logs_config.stop_grace_period", 1)
+	config.LogsAgent.Set("logs_config.stop_hard_deadline", 60)
+	config.LogsAgent.Set("logs_config.stop_drain", false)
 }
 
 func (suite *AgentTestSuite) TearDownTest() {
@@ -96,12 +101,14 @@ func (suite *AgentTestSuite) TestAgent() {
 	sources.AddSource(suite.source)
 	// Give the tailer some time to start its job.
 	time.Sleep(10 * time.Millisecond)
-	agent.Stop()
+	summary := agent.Stop()
 
 	assert.Equal(suite.T(), suite.fakeLogs, metrics.LogsDecoded.Value())
 	assert.Equal(suite.T(), suite.fakeLogs, metrics.LogsProcessed.Value())
 	assert.Equal(suite.T(), suite.fakeLogs, metrics.LogsSent.Value())
 	assert.Equal(suite.T(), zero, metrics.DestinationErrors.Value())
+	assert.False(suite.T(), summary.TimedOut)
+	assert.Equal(suite.T(), zero, summary.Dropped)
 
 	// Validate that we can restart it without obvious breakages.
 	agent.Start()
@@ -118,12 +125,96 @@ func (suite *AgentTestSuite) TestAgentStopsWithWrongBackend() {
 	sources.AddSource(suite.source)
 	// Give the tailer some time to start its job.
 	time.Sleep(10 * time.Millisecond)
-	agent.Stop()
+	summary := agent.Stop()
 
 	assert.Equal(suite.T(), suite.fakeLogs, metrics.LogsDecoded.Value())
 	assert.Equal(suite.T(), suite.fakeLogs, metrics.LogsProcessed.Value())
 	assert.Equal(suite.T(), int64(0), metrics.LogsSent.Value())
 	assert.True(suite.T(), metrics.DestinationErrors.Value() > 0)
+	// the logs are dropped once the grace period is over
+	assert.Equal(suite.T(), StopSummary{Delivered: 0, Dropped: suite.fakeLogs}, summary)
+}
+
+func (suite *AgentTestSuite) TestAgentStopDrainsUntilHardDeadline() {
+	config.LogsAgent.Set("logs_config.stop_drain", true)
+	config.LogsAgent.Set("logs_config.stop_hard_deadline", 3)
+
+	endpoint := client.Endpoint{Host: "fake:", Port: 0}
+	endpoints := client.NewEndpoints(endpoint, nil)
+
+	agent, sources, _ := createAgent(endpoints)
+
+	agent.Start()
+	sources.AddSource(suite.source)
+	// Give the tailer some time to start its job.
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	summary := agent.Stop()
+
+	// the sender kept retrying after the grace period until shortly before the hard deadline,
+	// the logs were then dropped before the hard deadline
+	elapsed := time.Since(start)
+	assert.True(suite.T(), elapsed >= 2*time.Second)
+	assert.True(suite.T(), elapsed < 3*time.Second)
+	assert.Equal(suite.T(), int64(0), metrics.LogsSent.Value())
+	assert.Equal(suite.T(), StopSummary{Delivered: 0, Dropped: suite.fakeLogs}, summary)
+}
+
+func (suite *AgentTestSuite) TestAgentStopDrainsWithoutTimingOut() {
+	config.LogsAgent.Set("logs_config.stop_drain", true)
+	config.LogsAgent.Set("logs_config.stop_hard_deadline", 3)
+
+	l := mock.NewMockLogsIntake(suite.T())
+	defer l.Close()
+	endpoints := client.NewEndpoints(client.AddrToEndPoint(l.Addr()), nil)
+
+	agent, sources, _ := createAgent(endpoints)
+
+	agent.Start()
+	sources.AddSource(suite.source)
+	// Give the tailer some time to start its job.
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	summary := agent.Stop()
+
+	// stopping returned as soon as the pipeline was flushed
+	assert.True(suite.T(), time.Since(start) < 2*time.Second)
+	assert.Equal(suite.T(), suite.fakeLogs, metrics.LogsSent.Value())
+	assert.Equal(suite.T(), int64(0), summary.Dropped)
+	assert.False(suite.T(), summary.TimedOut)
+}
+
+func (suite *AgentTestSuite) TestAgentReloadsTheSourcesOfConfigFiles() {
+	l := mock.NewMockLogsIntake(suite.T())
+	defer l.Close()
+
+	endpoint := client.AddrToEndPoint(l.Addr())
+	endpoints := client.NewEndpoints(endpoint, nil)
+
+	agent, sources, services := createAgent(endpoints)
+	agent.Start()
+	configs := []integration.Config{{
+		Name:       "test",
+		Provider:   providers.File,
+		LogsConfig: []byte(fmt.Sprintf("logs:\n  - type: file\n    path: %s\n    start_position: beginning\n", suite.testLogFile)),
+	}}
+	configScheduler := scheduler.NewScheduler(sources, services)
+	configScheduler.Reload(configs)
+	assert.Equal(suite.T(), 1, len(sources.GetSources()))
+	source := sources.GetSources()[0]
+
+	// the source is defined the same way and is not restarted
+	configScheduler.Reload(configs)
+	assert.Equal(suite.T(), []*config.LogSource{source}, sources.GetSources())
+
+	// Give the tailer some time to start its job.
+	time.Sleep(10 * time.Millisecond)
+	configScheduler.Reload(nil)
+	assert.Equal(suite.T(), 0, len(sources.GetSources()))
+	agent.Stop()
+
+	// the messages of the removed source are sent
+	assert.Equal(suite.T(), suite.fakeLogs, metrics.LogsSent.Value())
 }
 
 func TestAgentTestSuite(t *testing.T) {
@@ -23,7 +23,9 @@ import (
 
 const defaultFlushPeriod = 1 * time.Second
 const defaultCleanupPeriod = 300 * time.Second
-const defaultTTL = 23 * time.Hour
+
+// DefaultTTL is the time after which the entries of the registry which were not updated are removed.
+const DefaultTTL = 23 * time.Hour
 
 // latest version of the API used by the auditor to retrieve the registry from disk.
 const registryAPIVersion = 2
@@ -55,15 +57,39 @@ type Auditor struct {
 	mu           sync.Mutex
 	entryTTL     time.Duration
 	done         chan struct{}
+	// inMemory is true when the registry can not be persisted on disk
+	inMemory bool
 }
 
-// New returns an initialized Auditor
+// New returns an initialized Auditor,
+// when runPath is not writable, the offsets are only kept in memory.
 func New(runPath string, health *health.Handle) *Auditor {
-	return &Auditor{
+	auditor := &Auditor{
 		health:       health,
 		registryPath: filepath.Join(runPath, "registry.json"),
-		entryTTL:     defaultTTL,
+		entryTTL:     DefaultTTL,
+	}
+	if err := CheckRunPath(runPath); err != nil {
+		log.Errorf("%v, the offsets will only be kept in memory and logs may be collected again after a restart", err)
+		auditor.inMemory = true
+	}
+	return auditor
+}
+
+// CheckRunPath returns an error if the registry can not be written in runPath.
+func CheckRunPath(runPath string) error {
+	dir := runPath
+	if dir == "" {
+		// the registry is written in the working directory
+		dir = "."
 	}
+	f, err := ioutil.TempFile(dir, "registry")
+	if err != nil {
+		return fmt.Errorf("could not write in logs_config.run_path %s: %v, make sure that the directory exists and is writable by the agent or set logs_config.run_path to another directory", runPath, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
 }
 
 // Start starts the Auditor
@@ -111,6 +137,12 @@ func (a *Auditor) GetOffset(identifier string) string {
 	return entry.Offset
 }
 
+// Snapshot returns a copy of the last committed offsets and their update times by identifier,
+// it is safe to call while the messages are being committed.
+func (a *Auditor) Snapshot() map[string]RegistryEntry {
+	return a.readOnlyRegistryCopy()
+}
+
 // run keeps up to date the registry depending on different events
 func (a *Auditor) run() {
 	cleanUpTicker := time.NewTicker(defaultCleanupPeriod)
@@ -208,6 +240,9 @@ func (a *Auditor) readOnlyRegistryCopy() map[string]RegistryEntry {
 
 // flushRegistry writes on disk the registry at the given path
 func (a *Auditor) flushRegistry() error {
+	if a.inMemory {
+		return nil
+	}
 	r := a.readOnlyRegistryCopy()
 	mr, err := a.marshalRegistry(r)
 	if err != nil {
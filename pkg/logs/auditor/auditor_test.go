@@ -18,6 +18,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
 )
 
 var testpath = "testpath"
@@ -71,6 +72,19 @@ func (suite *AuditorTestSuite) TestAuditorUpdatesRegistry() {
 	suite.Equal("43", suite.a.registry[suite.source.Config.Path].Offset)
 }
 
+func (suite *AuditorTestSuite) TestAuditorReturnsASnapshotOfTheRegistry() {
+	suite.a.registry = make(map[string]*RegistryEntry)
+	suite.a.updateRegistry(suite.source.Config.Path, "42")
+	snapshot := suite.a.Snapshot()
+	suite.Equal(1, len(snapshot))
+	suite.Equal("42", snapshot[suite.source.Config.Path].Offset)
+	suite.False(snapshot[suite.source.Config.Path].LastUpdated.IsZero())
+
+	// the snapshot is not updated with the registry
+	suite.a.updateRegistry(suite.source.Config.Path, "43")
+	suite.Equal("42", snapshot[suite.source.Config.Path].Offset)
+}
+
 func (suite *AuditorTestSuite) TestAuditorFlushesAndRecoversRegistry() {
 	suite.a.registry = make(map[string]*RegistryEntry)
 	suite.a.registry[suite.source.Config.Path] = &RegistryEntry{
@@ -121,6 +135,55 @@ func (suite *AuditorTestSuite) TestAuditorCleansupRegistry() {
 	suite.Equal("43", suite.a.registry[otherpath].Offset)
 }
 
+func (suite *AuditorTestSuite) TestAuditorKeepsRegistryInMemoryWhenRunPathIsUnwritable() {
+	runPath := fmt.Sprintf("%s/missing", suite.testDir)
+	suite.NotNil(CheckRunPath(runPath))
+
+	a := New(runPath, health.Register("fake"))
+	suite.True(a.inMemory)
+
+	a.Start()
+	a.Channel() <- newMessage(suite.source, "42")
+	a.Stop()
+
+	suite.Equal("42", a.GetOffset(testpath))
+	_, err := os.Stat(a.registryPath)
+	suite.True(os.IsNotExist(err))
+}
+
+func (suite *AuditorTestSuite) TestAuditorPersistsRegistryWhenRunPathIsWritable() {
+	suite.Nil(CheckRunPath(suite.testDir))
+
+	a := New(suite.testDir, health.Register("fake"))
+	suite.False(a.inMemory)
+
+	a.Start()
+	a.Channel() <- newMessage(suite.source, "42")
+	a.Stop()
+
+	_, err := os.Stat(a.registryPath)
+	suite.Nil(err)
+}
+
+func (suite *AuditorTestSuite) TestCheckRunPathFailsWithReadOnlyDirectory() {
+	if os.Geteuid() == 0 {
+		suite.T().Skip("permissions are not enforced for root")
+	}
+	runPath := fmt.Sprintf("%s/readonly", suite.testDir)
+	suite.Nil(os.Mkdir(runPath, 0500))
+	defer os.Remove(runPath)
+
+	suite.NotNil(CheckRunPath(runPath))
+	suite.True(New(runPath, health.Register("fake")).inMemory)
+}
+
+func newMessage(source *config.LogSource, offset string) *message.Message {
+	origin := message.NewOrigin(source)
+	origin.Identifier = source.Config.Path
+	origin.Offset = offset
+	return message.NewMessage(nil, origin, "")
+}
+
 func TestScannerTestSuite(t *testing.T) {
 	suite.Run(t, new(AuditorTestSuite))
 }
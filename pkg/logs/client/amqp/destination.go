@@ -0,0 +1,268 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// defaultRoutingKey is the routing key of the logs without tag nor service to derive it from.
+const defaultRoutingKey = "logs"
+
+// sequenceHeader is the header holding the sequence number of the messages of the sources numbering them.
+const sequenceHeader = "sequence"
+
+// maxNacks is the number of publications in a row the broker does not acknowledge
+// after which the channel is closed and a new one is opened.
+const maxNacks = 3
+
+var (
+	backoffUnit = 2 * time.Second
+	backoffMax  = 30 * time.Second
+)
+
+var (
+	errChannelClosed = errors.New("the AMQP channel has been closed before the publication was confirmed")
+	errNotAcked      = errors.New("the AMQP broker did not acknowledge the publication")
+)
+
+// channel is the part of an AMQP channel used to publish logs.
+type channel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// dialer opens a channel in confirm mode to publish to the exchange of endpoint
+// and returns it with the chan the publications are confirmed on.
+type dialer func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error)
+
+// Destination publishes logs to an AMQP exchange, each publication is confirmed
+// by the broker so that the offsets are only committed for the logs it received.
+type Destination struct {
+	endpoint            client.AMQPEndpoint
+	destinationsContext *client.DestinationsContext
+	dial                dialer
+	channel             channel
+	confirmations       chan amqp.Confirmation
+	// failures is the number of publications in a row which failed, the next one is delayed accordingly,
+	// nacks is the number of them the broker did not acknowledge.
+	failures int
+	nacks    int
+}
+
+// NewDestination returns a new destination.
+func NewDestination(endpoint client.AMQPEndpoint, destinationsContext *client.DestinationsContext) *Destination {
+	return &Destination{
+		endpoint:            endpoint,
+		destinationsContext: destinationsContext,
+		dial:                dial,
+	}
+}
+
+// Send publishes the content of msg and blocks until the broker confirms it,
+// returns an error if the operation failed, the publication following a failure is delayed with a backoff.
+func (d *Destination) Send(msg *message.Message) error {
+	ctx := d.destinationsContext.Context()
+	if d.failures > 0 {
+		log.Debugf("Publish attempt #%d", d.failures)
+		backoff(ctx, d.failures)
+		if ctx.Err() != nil {
+			// the agent is stopping
+			return ctx.Err()
+		}
+	}
+	err := d.publish(ctx, msg)
+	switch err {
+	case nil:
+		d.failures = 0
+		d.nacks = 0
+	case errNotAcked:
+		d.failures++
+		d.nacks++
+		if d.nacks >= maxNacks {
+			// the channel may be in a bad state, a new one is opened for the next publication
+			d.close()
+		}
+	default:
+		d.failures++
+	}
+	return err
+}
+
+// publish publishes the content of msg and blocks until the broker confirms it,
+// returns an error if the operation failed.
+func (d *Destination) publish(ctx context.Context, msg *message.Message) error {
+	if d.channel == nil {
+		if err := d.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	publishing := amqp.Publishing{
+		Timestamp: time.Now().UTC(),
+		Body:      msg.Content,
+	}
+	if d.endpoint.Durable {
+		publishing.DeliveryMode = amqp.Persistent
+	}
+	if msg.Origin.LogSource.Config.SequenceNumbers {
+		publishing.Headers = amqp.Table{sequenceHeader: int64(msg.Sequence)}
+	}
+	err := d.channel.Publish(d.endpoint.Exchange, d.routingKey(msg), false, false, publishing)
+	if err != nil {
+		d.close()
+		return err
+	}
+
+	select {
+	case confirmation, isOpen := <-d.confirmations:
+		if !isOpen {
+			d.close()
+			return errChannelClosed
+		}
+		if !confirmation.Ack {
+			return errNotAcked
+		}
+		return nil
+	case <-ctx.Done():
+		d.close()
+		return ctx.Err()
+	}
+}
+
+// routingKey returns the value of the routing key tag of msg,
+// or its service when it does not have this tag.
+func (d *Destination) routingKey(msg *message.Message) string {
+	if d.endpoint.RoutingKeyTag != "" {
+		prefix := d.endpoint.RoutingKeyTag + ":"
+		for _, tag := range msg.Origin.Tags() {
+			if strings.HasPrefix(tag, prefix) {
+				return tag[len(prefix):]
+			}
+		}
+	}
+	if service := msg.Origin.Service(); service != "" {
+		return service
+	}
+	return defaultRoutingKey
+}
+
+// connect opens a new channel, it blocks until the channel is open or ctx is done.
+func (d *Destination) connect(ctx context.Context) error {
+	var retries int
+	for {
+		if retries > 0 {
+			log.Debugf("Connect attempt #%d", retries)
+			backoff(ctx, retries)
+		}
+		retries++
+
+		select {
+		case <-ctx.Done():
+			// the agent is stopping
+			return ctx.Err()
+		default:
+		}
+
+		channel, confirmations, err := d.dial(d.endpoint)
+		if err != nil {
+			log.Warnf("Could not connect to the AMQP broker: %v", err)
+			continue
+		}
+		d.channel = channel
+		d.confirmations = confirmations
+		return nil
+	}
+}
+
+// close closes the channel, a new one is opened for the next publication.
+func (d *Destination) close() {
+	d.channel.Close()
+	d.channel = nil
+	d.confirmations = nil
+	d.nacks = 0
+}
+
+// backoff blocks until the backoff duration of the connection or publication attempt is elapsed or ctx is done.
+func backoff(ctx context.Context, retries int) {
+	backoffDuration := backoffUnit * time.Duration(retries)
+	if backoffDuration > backoffMax {
+		backoffDuration = backoffMax
+	}
+	ctx, cancel := context.WithTimeout(ctx, backoffDuration)
+	defer cancel()
+	<-ctx.Done()
+}
+
+// amqpChannel closes its connection along with the channel.
+type amqpChannel struct {
+	*amqp.Channel
+	conn *amqp.Connection
+}
+
+// Close closes the channel and its connection.
+func (c *amqpChannel) Close() error {
+	c.Channel.Close()
+	return c.conn.Close()
+}
+
+// CheckEndpoint returns an error if the broker of endpoint can not be reached within timeout,
+// the connection is closed right away and the exchange is not declared.
+func CheckEndpoint(endpoint client.AMQPEndpoint, timeout time.Duration) error {
+	conn, err := amqp.DialConfig(endpoint.URL, amqp.Config{
+		Locale: "en_US",
+		Dial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.DialTimeout(network, addr, timeout)
+			if err != nil {
+				return nil, err
+			}
+			// the deadline bounds the handshakes, it is cleared once the connection is open
+			return conn, conn.SetDeadline(time.Now().Add(timeout))
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// dial opens a new connection to the broker, declares the exchange
+// and opens a channel in confirm mode.
+func dial(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+	conn, err := amqp.Dial(endpoint.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	c := &amqpChannel{Channel: ch, conn: conn}
+	err = ch.ExchangeDeclare(endpoint.Exchange, endpoint.ExchangeType, endpoint.Durable, false, false, false, nil)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	err = ch.Confirm(false)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	confirmations := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return c, confirmations, nil
+}
@@ -0,0 +1,223 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+type publication struct {
+	exchange string
+	key      string
+	msg      amqp.Publishing
+}
+
+// stubChannel records the publications and confirms them with ack,
+// or closes the confirmations when the broker is shut down.
+type stubChannel struct {
+	publications  []publication
+	confirmations chan amqp.Confirmation
+	ack           bool
+	shutdown      bool
+	closed        bool
+}
+
+func (c *stubChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.publications = append(c.publications, publication{exchange: exchange, key: key, msg: msg})
+	if c.shutdown {
+		close(c.confirmations)
+		return nil
+	}
+	c.confirmations <- amqp.Confirmation{DeliveryTag: uint64(len(c.publications)), Ack: c.ack}
+	return nil
+}
+
+func (c *stubChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newStubChannel(ack bool) *stubChannel {
+	return &stubChannel{
+		confirmations: make(chan amqp.Confirmation, 1),
+		ack:           ack,
+	}
+}
+
+func newTestDestination(endpoint client.AMQPEndpoint, dial dialer) (*Destination, *client.DestinationsContext) {
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destinationsCtx.Start()
+	destination := NewDestination(endpoint, destinationsCtx)
+	destination.dial = dial
+	return destination, destinationsCtx
+}
+
+func newMessage(content []byte, source *config.LogSource) *message.Message {
+	return message.NewMessage(content, message.NewOrigin(source), "")
+}
+
+func TestDestinationPublishesToExchange(t *testing.T) {
+	stub := newStubChannel(true)
+	dial := func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+		return stub, stub.confirmations, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.AMQPEndpoint{Exchange: "logs", Durable: true}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{Service: "my-service"})
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), source)))
+
+	assert.Equal(t, 1, len(stub.publications))
+	publication := stub.publications[0]
+	assert.Equal(t, "logs", publication.exchange)
+	assert.Equal(t, "my-service", publication.key)
+	assert.Equal(t, []byte("foo"), publication.msg.Body)
+	assert.Equal(t, amqp.Persistent, publication.msg.DeliveryMode)
+	assert.Nil(t, publication.msg.Headers)
+}
+
+func TestDestinationPublishesSequenceNumbers(t *testing.T) {
+	stub := newStubChannel(true)
+	dial := func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+		return stub, stub.confirmations, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.AMQPEndpoint{Exchange: "logs"}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{SequenceNumbers: true})
+	msg := newMessage([]byte("foo"), source)
+	msg.Sequence = 42
+	assert.Nil(t, destination.Send(msg))
+	assert.Equal(t, amqp.Table{"sequence": int64(42)}, stub.publications[0].msg.Headers)
+}
+
+func TestDestinationReturnsErrorWhenNotAcked(t *testing.T) {
+	stub := newStubChannel(false)
+	dial := func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+		return stub, stub.confirmations, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.AMQPEndpoint{Exchange: "logs"}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	assert.Equal(t, errNotAcked, destination.Send(newMessage([]byte("foo"), source)))
+	assert.Equal(t, uint8(0), stub.publications[0].msg.DeliveryMode)
+	assert.False(t, stub.closed)
+}
+
+func TestDestinationReconnectsWhenChannelIsClosed(t *testing.T) {
+	defaultBackoffUnit := backoffUnit
+	backoffUnit = time.Millisecond
+	defer func() { backoffUnit = defaultBackoffUnit }()
+
+	stub := newStubChannel(true)
+	var dials int
+	dial := func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+		dials++
+		return stub, stub.confirmations, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.AMQPEndpoint{Exchange: "logs"}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), source)))
+
+	// the broker closes the channel before confirming the publication
+	stub.shutdown = true
+	assert.Equal(t, errChannelClosed, destination.Send(newMessage([]byte("bar"), source)))
+	assert.True(t, stub.closed)
+
+	stub.shutdown = false
+	stub.confirmations = make(chan amqp.Confirmation, 1)
+	assert.Nil(t, destination.Send(newMessage([]byte("bar"), source)))
+	assert.Equal(t, 2, dials)
+}
+
+func TestDestinationRetriesToConnectWithBackoff(t *testing.T) {
+	defaultBackoffUnit := backoffUnit
+	backoffUnit = time.Millisecond
+	defer func() { backoffUnit = defaultBackoffUnit }()
+
+	stub := newStubChannel(true)
+	var dials int
+	dial := func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+		dials++
+		if dials < 3 {
+			return nil, nil, errors.New("connection refused")
+		}
+		return stub, stub.confirmations, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.AMQPEndpoint{Exchange: "logs"}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), source)))
+	assert.Equal(t, 3, dials)
+}
+
+func TestDestinationBacksOffAndReopensChannelWhenNotAcked(t *testing.T) {
+	defaultBackoffUnit := backoffUnit
+	backoffUnit = 20 * time.Millisecond
+	defer func() { backoffUnit = defaultBackoffUnit }()
+
+	stub := newStubChannel(false)
+	var dials int
+	dial := func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+		dials++
+		return stub, stub.confirmations, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.AMQPEndpoint{Exchange: "logs"}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	start := time.Now()
+	for i := 0; i < maxNacks; i++ {
+		assert.Equal(t, errNotAcked, destination.Send(newMessage([]byte("foo"), source)))
+	}
+	// the second and third publications waited for one and two backoff units
+	assert.True(t, time.Since(start) >= 3*backoffUnit)
+	assert.True(t, stub.closed)
+
+	stub.ack = true
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), source)))
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 0, destination.failures)
+}
+
+func TestDestinationStopsConnectingWhenContextIsDone(t *testing.T) {
+	dial := func(endpoint client.AMQPEndpoint) (channel, chan amqp.Confirmation, error) {
+		return nil, nil, errors.New("connection refused")
+	}
+	destination, destinationsCtx := newTestDestination(client.AMQPEndpoint{Exchange: "logs"}, dial)
+	destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	assert.Equal(t, context.Canceled, destination.Send(newMessage([]byte("foo"), source)))
+}
+
+func TestRoutingKey(t *testing.T) {
+	destination := NewDestination(client.AMQPEndpoint{RoutingKeyTag: "env"}, nil)
+
+	source := config.NewLogSource("", &config.LogsConfig{Service: "my-service"})
+	msg := newMessage([]byte("foo"), source)
+	assert.Equal(t, "my-service", destination.routingKey(msg))
+
+	msg.Origin.SetTags([]string{"environment:staging", "env:prod"})
+	assert.Equal(t, "prod", destination.routingKey(msg))
+
+	source = config.NewLogSource("", &config.LogsConfig{})
+	assert.Equal(t, defaultRoutingKey, destination.routingKey(newMessage([]byte("foo"), source)))
+}
@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBackoffBase = 2 * time.Second
+	defaultBackoffMax  = 30 * time.Second
+)
+
+// backoff computes the delays between the attempts to reach an endpoint,
+// the delay doubles after each failure from the base up to the max
+// and only its first half is fixed, the other half is random
+// so that the agents do not all reconnect at the same time after an outage.
+type backoff struct {
+	base     time.Duration
+	max      time.Duration
+	failures int
+	// delay is the last delay waited for in nanoseconds, it is read concurrently.
+	delay  int64
+	random func(n int64) int64
+}
+
+// newBackoff returns a new backoff, the defaults are used when base or max are not set.
+func newBackoff(base, max time.Duration) *backoff {
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	if max < base {
+		max = base
+	}
+	return &backoff{
+		base:   base,
+		max:    max,
+		random: rand.Int63n,
+	}
+}
+
+// fail records a failed attempt.
+func (b *backoff) fail() {
+	b.failures++
+}
+
+// reset clears the failed attempts once an attempt succeeded.
+func (b *backoff) reset() {
+	b.failures = 0
+	atomic.StoreInt64(&b.delay, 0)
+}
+
+// exhausted returns true once the first failure following the last success, if any,
+// has been followed by maxAttempts other failures, 0 means never.
+func (b *backoff) exhausted(maxAttempts int) bool {
+	return maxAttempts > 0 && b.failures > maxAttempts
+}
+
+// wait sleeps the delay following the failed attempts, if any,
+// returns an error if ctx is cancelled in the meantime.
+func (b *backoff) wait(ctx context.Context) error {
+	if b.failures == 0 {
+		return nil
+	}
+	delay := b.nextDelay()
+	atomic.StoreInt64(&b.delay, int64(delay))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// nextDelay returns a delay between the half and the whole of the current backoff.
+func (b *backoff) nextDelay() time.Duration {
+	current := b.base
+	for i := 1; i < b.failures && current < b.max; i++ {
+		current *= 2
+	}
+	if current > b.max {
+		current = b.max
+	}
+	half := current / 2
+	return half + time.Duration(b.random(int64(current-half)+1))
+}
+
+// currentDelay returns the last delay waited for, 0 once an attempt succeeded.
+func (b *backoff) currentDelay() time.Duration {
+	return time.Duration(atomic.LoadInt64(&b.delay))
+}
@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffDelayGrowsExponentiallyUpToMax(t *testing.T) {
+	b := newBackoff(time.Second, 10*time.Second)
+	// no jitter, the delays are the whole backoffs
+	b.random = func(n int64) int64 { return n - 1 }
+
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		b.fail()
+		delays = append(delays, b.nextDelay())
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}, delays)
+}
+
+func TestBackoffDelayHasJitter(t *testing.T) {
+	b := newBackoff(time.Second, 10*time.Second)
+	b.fail()
+	b.fail()
+	for i := 0; i < 100; i++ {
+		delay := b.nextDelay()
+		assert.True(t, delay >= time.Second && delay <= 2*time.Second, delay)
+	}
+}
+
+func TestBackoffUsesDefaults(t *testing.T) {
+	b := newBackoff(0, 0)
+	assert.Equal(t, defaultBackoffBase, b.base)
+	assert.Equal(t, defaultBackoffMax, b.max)
+}
+
+func TestBackoffWaitAndReset(t *testing.T) {
+	b := newBackoff(time.Millisecond, 10*time.Millisecond)
+
+	// no delay before the first attempt
+	assert.Nil(t, b.wait(context.Background()))
+	assert.Equal(t, time.Duration(0), b.currentDelay())
+
+	b.fail()
+	assert.Nil(t, b.wait(context.Background()))
+	assert.NotEqual(t, time.Duration(0), b.currentDelay())
+
+	b.reset()
+	assert.Equal(t, time.Duration(0), b.currentDelay())
+	assert.Equal(t, 0, b.failures)
+}
+
+func TestBackoffWaitReturnsWhenContextCancelled(t *testing.T) {
+	b := newBackoff(time.Hour, time.Hour)
+	b.fail()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+
+	assert.Equal(t, context.Canceled, b.wait(ctx))
+}
+
+func TestBackoffIsExhaustedAfterMaxAttempts(t *testing.T) {
+	b := newBackoff(time.Millisecond, 10*time.Millisecond)
+	b.fail()
+	assert.False(t, b.exhausted(1))
+	b.fail()
+	b.fail()
+	// the first failure followed by two failed attempts
+	assert.True(t, b.exhausted(1))
+	assert.True(t, b.exhausted(2))
+	assert.False(t, b.exhausted(3))
+	assert.False(t, b.exhausted(0))
+}
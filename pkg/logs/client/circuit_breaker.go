@@ -0,0 +1,112 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// States of the circuit breaker of a destination.
+const (
+	// CircuitClosed lets the logs be sent to the destination.
+	CircuitClosed = "closed"
+	// CircuitOpen drops the logs without trying to send them until the cooldown is over.
+	CircuitOpen = "open"
+	// CircuitHalfOpen lets a single attempt probe the destination once the cooldown is over.
+	CircuitHalfOpen = "half_open"
+)
+
+// ErrCircuitOpen is returned when the logs are not sent because the circuit breaker of the destination is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker stops sending logs to a destination after too many consecutive failures,
+// the logs are dropped during the cooldown, then a single attempt probes the destination
+// which closes the circuit on success or opens it for another cooldown on failure.
+// A nil circuit breaker never opens.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker returns a circuit breaker opening after threshold consecutive failures for cooldown,
+// nil when threshold is not positive.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     CircuitClosed,
+	}
+}
+
+// allow returns true if an attempt to send logs can be made,
+// the circuit becomes half-open when the cooldown is over to let a single attempt through.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		return true
+	case CircuitHalfOpen:
+		// the probe is still in progress
+		return false
+	default:
+		return true
+	}
+}
+
+// success closes the circuit and resets the failures.
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+}
+
+// failure records a failed attempt, the circuit opens when the probe failed or the failures reach the threshold.
+func (b *circuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// currentState returns the state of the circuit, closed for a nil circuit breaker.
+func (b *circuitBreaker) currentState() string {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerOpensAfterTooManyConsecutiveFailures(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.failure()
+	b.failure()
+	b.success()
+	b.failure()
+	b.failure()
+	assert.True(t, b.allow())
+	assert.Equal(t, CircuitClosed, b.currentState())
+
+	b.failure()
+	assert.False(t, b.allow())
+	assert.Equal(t, CircuitOpen, b.currentState())
+}
+
+func TestCircuitBreakerProbesOnceTheCooldownIsOver(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+	b.failure()
+	assert.False(t, b.allow())
+
+	// a single attempt probes the destination
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow())
+	assert.Equal(t, CircuitHalfOpen, b.currentState())
+	assert.False(t, b.allow())
+
+	// the circuit opens again when the probe fails
+	b.failure()
+	assert.Equal(t, CircuitOpen, b.currentState())
+	assert.False(t, b.allow())
+
+	// and closes when it succeeds
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow())
+	b.success()
+	assert.Equal(t, CircuitClosed, b.currentState())
+	assert.True(t, b.allow())
+}
+
+func TestCircuitBreakerNeverOpensWithoutThreshold(t *testing.T) {
+	b := newCircuitBreaker(0, time.Minute)
+	assert.Nil(t, b)
+	b.failure()
+	assert.True(t, b.allow())
+	assert.Equal(t, CircuitClosed, b.currentState())
+}
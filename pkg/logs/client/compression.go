@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+// The kinds of compression of the payloads posted to an HTTP intake, also used as their Content-Encoding header.
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// maxZstdLevel is the highest level of zstd compression.
+const maxZstdLevel = 22
+
+// CheckCompression returns an error if the payloads can not be compressed with kind at level by this agent,
+// 0 meaning the default level of kind.
+func CheckCompression(kind string, level int) error {
+	switch kind {
+	case CompressionNone:
+		return nil
+	case CompressionGzip:
+		if level != 0 && (level < gzip.HuffmanOnly || level > gzip.BestCompression) {
+			return fmt.Errorf("invalid gzip compression level %d, use a level from %d to %d", level, gzip.BestSpeed, gzip.BestCompression)
+		}
+		return nil
+	case CompressionZstd:
+		if !zstdAvailable {
+			return fmt.Errorf("zstd compression is not supported by this build of the agent, use gzip instead")
+		}
+		if level < 0 || level > maxZstdLevel {
+			return fmt.Errorf("invalid zstd compression level %d, use a level from 1 to %d", level, maxZstdLevel)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown compression %q, use gzip or zstd", kind)
+	}
+}
+
+// compress returns payload compressed with kind at level, 0 meaning the default level of kind,
+// along with the compression applied, none when the compressed payload would not be smaller.
+func compress(kind string, level int, payload []byte) ([]byte, string, error) {
+	var compressed []byte
+	var err error
+	switch kind {
+	case CompressionGzip:
+		compressed, err = compressGzip(level, payload)
+	case CompressionZstd:
+		compressed, err = compressZstd(level, payload)
+	default:
+		return payload, CompressionNone, nil
+	}
+	if err != nil {
+		return nil, kind, err
+	}
+	if len(compressed) >= len(payload) {
+		// the headers of the compressed formats outweigh the gains on the small payloads
+		return payload, CompressionNone, nil
+	}
+	return compressed, kind, nil
+}
+
+// compressGzip returns payload compressed with gzip at level, 0 meaning the default level.
+func compressGzip(level int, payload []byte) ([]byte, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,18 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !zstd
+
+package client
+
+import "errors"
+
+// zstdAvailable is true when the agent is built with zstd.
+const zstdAvailable = false
+
+// compressZstd returns an error as the agent is not built with zstd.
+func compressZstd(level int, payload []byte) ([]byte, error) {
+	return nil, errors.New("zstd compression is not supported by this build of the agent")
+}
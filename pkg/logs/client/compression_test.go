@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCompression(t *testing.T) {
+	assert.Nil(t, CheckCompression(CompressionNone, 0))
+	assert.Nil(t, CheckCompression(CompressionGzip, 0))
+	assert.Nil(t, CheckCompression(CompressionGzip, gzip.BestCompression))
+	assert.NotNil(t, CheckCompression(CompressionGzip, 42))
+	assert.Equal(t, zstdAvailable, CheckCompression(CompressionZstd, 0) == nil)
+	assert.NotNil(t, CheckCompression(CompressionZstd, 42))
+	assert.NotNil(t, CheckCompression("lz4", 0))
+}
+
+func TestCompress(t *testing.T) {
+	payload := bytes.Repeat([]byte("hello world "), 100)
+
+	uncompressed, compression, err := compress(CompressionNone, 0, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, uncompressed)
+	assert.Equal(t, CompressionNone, compression)
+
+	for _, level := range []int{0, gzip.BestSpeed, gzip.BestCompression} {
+		compressed, compression, err := compress(CompressionGzip, level, payload)
+		assert.Nil(t, err)
+		assert.Equal(t, CompressionGzip, compression)
+		assert.True(t, len(compressed) < len(payload))
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		assert.Nil(t, err)
+		decompressed, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, payload, decompressed)
+	}
+
+	_, _, err = compress(CompressionGzip, 42, payload)
+	assert.NotNil(t, err)
+}
+
+func TestCompressSendsTheSmallPayloadsUncompressed(t *testing.T) {
+	payload := []byte("hello")
+	compressed, compression, err := compress(CompressionGzip, 0, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, compressed)
+	assert.Equal(t, CompressionNone, compression)
+}
@@ -0,0 +1,21 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build zstd
+
+package client
+
+import "github.com/DataDog/zstd"
+
+// zstdAvailable is true when the agent is built with zstd.
+const zstdAvailable = true
+
+// compressZstd returns payload compressed with zstd at level, 0 meaning the default level.
+func compressZstd(level int, payload []byte) ([]byte, error) {
+	if level == 0 {
+		level = zstd.DefaultCompression
+	}
+	return zstd.CompressLevel(nil, payload, level)
+}
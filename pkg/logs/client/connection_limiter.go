@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"context"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// A ConnectionLimiter bounds the number of connection attempts in progress at the same time
+// across all the destinations, this staggers the reconnections when the backend recovers from an outage.
+// A nil ConnectionLimiter does not limit anything.
+type ConnectionLimiter struct {
+	slots chan struct{}
+}
+
+// NewConnectionLimiter returns a new ConnectionLimiter allowing at most maxAttempts concurrent connection attempts,
+// returns nil when maxAttempts is not strictly positive.
+func NewConnectionLimiter(maxAttempts int) *ConnectionLimiter {
+	if maxAttempts <= 0 {
+		return nil
+	}
+	return &ConnectionLimiter{
+		slots: make(chan struct{}, maxAttempts),
+	}
+}
+
+// Acquire blocks until a connection attempt can be made or the context is cancelled,
+// in which case it returns the error of the context.
+func (l *ConnectionLimiter) Acquire(ctx context.Context) error {
+	if l != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	metrics.ReconnectsInProgress.Add(1)
+	return nil
+}
+
+// Release frees the slot taken by a connection attempt.
+func (l *ConnectionLimiter) Release() {
+	metrics.ReconnectsInProgress.Add(-1)
+	if l != nil {
+		<-l.slots
+	}
+}
@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func TestNewConnectionLimiter(t *testing.T) {
+	assert.Nil(t, NewConnectionLimiter(0))
+	assert.Nil(t, NewConnectionLimiter(-1))
+	assert.NotNil(t, NewConnectionLimiter(1))
+}
+
+func TestConnectionLimiterBlocksWhenFull(t *testing.T) {
+	limiter := NewConnectionLimiter(2)
+	ctx := context.Background()
+
+	assert.Nil(t, limiter.Acquire(ctx))
+	assert.Nil(t, limiter.Acquire(ctx))
+	assert.Equal(t, int64(2), metrics.ReconnectsInProgress.Value())
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire(ctx)
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		assert.Fail(t, "the limiter should be full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.Release()
+	<-acquired
+	assert.Equal(t, int64(2), metrics.ReconnectsInProgress.Value())
+
+	limiter.Release()
+	limiter.Release()
+	assert.Equal(t, int64(0), metrics.ReconnectsInProgress.Value())
+}
+
+func TestConnectionLimiterReturnsWhenContextCancelled(t *testing.T) {
+	limiter := NewConnectionLimiter(1)
+	assert.Nil(t, limiter.Acquire(context.Background()))
+	defer limiter.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, limiter.Acquire(ctx))
+}
+
+func TestNilConnectionLimiterDoesNotLimit(t *testing.T) {
+	var limiter *ConnectionLimiter
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, limiter.Acquire(context.Background()))
+	}
+	for i := 0; i < 10; i++ {
+		limiter.Release()
+	}
+	assert.Equal(t, int64(0), metrics.ReconnectsInProgress.Value())
+}
+
+func TestManyConnectionManagersConnectWithLimiter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	limiter := NewConnectionLimiter(2)
+	destinationsCtx := NewDestinationsContext(limiter)
+	destinationsCtx.Start()
+	defer destinationsCtx.Stop()
+
+	host, port := AddrToHostPort(l.Addr())
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			connManager := NewConnectionManager(Endpoint{Host: host, Port: port}, limiter)
+			conn, err := connManager.NewConnection(destinationsCtx.Context())
+			assert.Nil(t, err)
+			assert.NotNil(t, conn)
+			assert.True(t, metrics.ReconnectsInProgress.Value() <= 2)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(0), metrics.ReconnectsInProgress.Value())
+}
@@ -8,8 +8,10 @@ package client
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"io"
 	"net"
+	"net/url"
 	"strconv"
 	"sync"
 	"time"
@@ -20,46 +22,72 @@ import (
 )
 
 const (
-	backoffUnit       = 2 * time.Second
-	backoffMax        = 30 * time.Second
 	connectionTimeout = 20 * time.Second
 )
 
+// ErrReconnectAttemptsExhausted is returned when a destination gave up sending the logs
+// after too many failed attempts to reconnect.
+var ErrReconnectAttemptsExhausted = errors.New("too many failed attempts to reconnect")
+
 // A ConnectionManager manages connections
 type ConnectionManager struct {
 	endpoint  Endpoint
+	limiter   *ConnectionLimiter
+	backoff   *backoff
 	mutex     sync.Mutex
 	firstConn sync.Once
+	// lastSuccess is the last time logs were sent successfully, guarded by mutex
+	lastSuccess time.Time
 }
 
-// NewConnectionManager returns an initialized ConnectionManager
-func NewConnectionManager(endpoint Endpoint) *ConnectionManager {
+// NewConnectionManager returns an initialized ConnectionManager,
+// limiter bounds the connection attempts made concurrently with other connection managers.
+func NewConnectionManager(endpoint Endpoint, limiter *ConnectionLimiter) *ConnectionManager {
 	return &ConnectionManager{
 		endpoint: endpoint,
+		limiter:  limiter,
+		backoff:  newBackoff(endpoint.BackoffBase, endpoint.BackoffMax),
 	}
 }
 
 // NewConnection returns an initialized connection to the intake.
-// It blocks until a connection is available
+// It blocks until a connection is available, backing off after each failed attempt.
 func (cm *ConnectionManager) NewConnection(ctx context.Context) (net.Conn, error) {
+	return cm.newConnection(ctx, true)
+}
+
+// TryNewConnection returns an initialized connection to the intake after a single attempt,
+// made once the delay following the previous failed attempts is over, returns an error if it failed.
+func (cm *ConnectionManager) TryNewConnection(ctx context.Context) (net.Conn, error) {
+	return cm.newConnection(ctx, false)
+}
+
+// newConnection returns an initialized connection to the intake, the failed attempts are retried if retry is set,
+// until the maximum number of attempts to reconnect of the endpoint is reached.
+func (cm *ConnectionManager) newConnection(ctx context.Context, retry bool) (net.Conn, error) {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
 	cm.firstConn.Do(func() {
 		if cm.endpoint.ProxyAddress != "" {
 			log.Infof("Connecting to the backend: %v, via socks5: %v, with SSL: %v", cm.address(), cm.endpoint.ProxyAddress, cm.endpoint.UseSSL)
+		} else if proxyURL, err := ProxyURL(cm.endpoint); err == nil && proxyURL != nil {
+			log.Infof("Connecting to the backend: %v, via http proxy: %v, with SSL: %v", cm.address(), proxyURL.Host, cm.endpoint.UseSSL)
 		} else {
 			log.Infof("Connecting to the backend: %v, with SSL: %v", cm.address(), cm.endpoint.UseSSL)
 		}
 	})
 
-	var retries int
 	for {
-		if retries > 0 {
-			log.Debugf("Connect attempt #%d", retries)
-			cm.backoff(ctx, retries)
+		if cm.backoff.exhausted(cm.endpoint.MaxReconnectAttempts) {
+			return nil, ErrReconnectAttemptsExhausted
+		}
+		if cm.backoff.failures > 0 {
+			log.Debugf("Connect attempt #%d", cm.backoff.failures)
+		}
+		if err := cm.backoff.wait(ctx); err != nil {
+			return nil, err
 		}
-		retries++
 
 		// Check if we should continue.
 		select {
@@ -70,42 +98,19 @@ func (cm *ConnectionManager) NewConnection(ctx context.Context) (net.Conn, error
 			// Continue.
 		}
 
-		var conn net.Conn
-		var err error
-
-		if cm.endpoint.ProxyAddress != "" {
-			var dialer proxy.Dialer
-			dialer, err = proxy.SOCKS5("tcp", cm.endpoint.ProxyAddress, nil, proxy.Direct)
-			if err != nil {
-				log.Warn(err)
-				continue
-			}
-			// TODO: handle timeouts with ctx.
-			conn, err = dialer.Dial("tcp", cm.address())
-		} else {
-			var dialer net.Dialer
-			dctx, cancel := context.WithTimeout(ctx, connectionTimeout)
-			defer cancel()
-			conn, err = dialer.DialContext(dctx, "tcp", cm.address())
+		// Wait for the other connection attempts to complete.
+		if err := cm.limiter.Acquire(ctx); err != nil {
+			return nil, err
 		}
+		conn, err := cm.connect(ctx)
+		cm.limiter.Release()
 		if err != nil {
 			log.Warn(err)
-			continue
-		}
-		log.Debug("connected to %v", cm.address())
-
-		if cm.endpoint.UseSSL {
-			sslConn := tls.Client(conn, &tls.Config{
-				ServerName: cm.endpoint.Host,
-			})
-			// TODO: handle timeouts with ctx.
-			err = sslConn.Handshake()
-			if err != nil {
-				log.Warn(err)
-				continue
+			cm.backoff.fail()
+			if !retry {
+				return nil, err
 			}
-			log.Debug("SSL handshake successful")
-			conn = sslConn
+			continue
 		}
 
 		go cm.handleServerClose(conn)
@@ -113,6 +118,78 @@ func (cm *ConnectionManager) NewConnection(ctx context.Context) (net.Conn, error
 	}
 }
 
+// connect dials the intake and performs the SSL handshake if needed,
+// returns an error if one of these steps failed.
+func (cm *ConnectionManager) connect(ctx context.Context) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+
+	switch {
+	case cm.endpoint.ProxyAddress != "":
+		var dialer proxy.Dialer
+		dialer, err = proxy.SOCKS5("tcp", cm.endpoint.ProxyAddress, nil, &net.Dialer{KeepAlive: cm.endpoint.KeepAlive})
+		if err != nil {
+			return nil, err
+		}
+		// TODO: handle timeouts with ctx.
+		conn, err = dialer.Dial("tcp", cm.address())
+	case cm.endpoint.HTTPProxy != "":
+		var proxyURL *url.URL
+		proxyURL, err = ProxyURL(cm.endpoint)
+		if err != nil {
+			return nil, err
+		}
+		dialer := net.Dialer{KeepAlive: cm.endpoint.KeepAlive}
+		dctx, cancel := context.WithTimeout(ctx, connectionTimeout)
+		defer cancel()
+		conn, err = dialHTTPProxy(dctx, &dialer, proxyURL, cm.address())
+	default:
+		// the addresses of both families are raced as described by the happy eyeballs algorithm (RFC 6555),
+		// so that a black-holed IPv6 path does not stall the connection until the timeout
+		dialer := net.Dialer{FallbackDelay: cm.endpoint.FallbackDelay, KeepAlive: cm.endpoint.KeepAlive}
+		dctx, cancel := context.WithTimeout(ctx, connectionTimeout)
+		defer cancel()
+		conn, err = dialer.DialContext(dctx, "tcp", cm.address())
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("connected to %v", cm.address())
+
+	if cm.endpoint.UseSSL {
+		// the certificates are loaded again on each connection in case they have been renewed
+		tlsConfig, err := LoadTLSConfig(cm.endpoint)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		sslConn := tls.Client(conn, tlsConfig)
+		if deadline, ok := ctx.Deadline(); ok {
+			sslConn.SetDeadline(deadline)
+		}
+		err = sslConn.Handshake()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		sslConn.SetDeadline(time.Time{})
+		log.Debug("SSL handshake successful")
+		conn = sslConn
+	}
+
+	return conn, nil
+}
+
+// CheckEndpoint returns an error if no connection can be established to endpoint before ctx expires,
+// the connection is closed right away.
+func CheckEndpoint(ctx context.Context, endpoint Endpoint) error {
+	conn, err := NewConnectionManager(endpoint, nil).connect(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 // address returns the address of the server to send logs to.
 func (cm *ConnectionManager) address() string {
 	return net.JoinHostPort(cm.endpoint.Host, strconv.Itoa(cm.endpoint.Port))
@@ -135,6 +212,9 @@ func (cm *ConnectionManager) handleServerClose(conn net.Conn) {
 		if err == io.EOF {
 			cm.CloseConnection(conn)
 			return
+		} else if errors.Is(err, net.ErrClosed) {
+			// the connection was closed on the client side
+			return
 		} else if err != nil {
 			log.Warn(err)
 			return
@@ -142,15 +222,31 @@ func (cm *ConnectionManager) handleServerClose(conn net.Conn) {
 	}
 }
 
-// backoff lets the connection manager sleep a bit
-func (cm *ConnectionManager) backoff(ctx context.Context, retries int) {
-	backoffDuration := backoffUnit * time.Duration(retries)
-	if backoffDuration > backoffMax {
-		backoffDuration = backoffMax
-	}
-	time.Sleep(backoffDuration)
+// recordFailure makes the next connection attempt back off,
+// the delay grows until recordSuccess is called.
+func (cm *ConnectionManager) recordFailure() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.backoff.fail()
+}
+
+// recordSuccess resets the backoff once logs have been sent.
+func (cm *ConnectionManager) recordSuccess() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.backoff.reset()
+	cm.lastSuccess = time.Now()
+}
+
+// LastSuccess returns the last time logs were sent successfully, the zero time if they never were.
+func (cm *ConnectionManager) LastSuccess() time.Time {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	return cm.lastSuccess
+}
 
-	ctx, cancel := context.WithTimeout(ctx, backoffDuration)
-	defer cancel()
-	<-ctx.Done()
+// BackoffDelay returns the delay waited for before the last connection attempt,
+// 0 when the last logs were sent successfully.
+func (cm *ConnectionManager) BackoffDelay() time.Duration {
+	return cm.backoff.currentDelay()
 }
@@ -6,9 +6,11 @@
 package client
 
 import (
+	"context"
 	"net"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -22,7 +24,7 @@ func newConnectionManagerForAddr(addr net.Addr) *ConnectionManager {
 
 func newConnectionManagerForHostPort(host string, port int) *ConnectionManager {
 	endpoint := Endpoint{Host: host, Port: port}
-	return NewConnectionManager(endpoint)
+	return NewConnectionManager(endpoint, nil)
 }
 
 func TestAddress(t *testing.T) {
@@ -34,7 +36,7 @@ func TestNewConnection(t *testing.T) {
 	l := mock.NewMockLogsIntake(t)
 	defer l.Close()
 
-	destinationsCtx := NewDestinationsContext()
+	destinationsCtx := NewDestinationsContext(nil)
 
 	connManager := newConnectionManagerForAddr(l.Addr())
 	destinationsCtx.Start()
@@ -45,8 +47,23 @@ func TestNewConnection(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestNewConnectionToDualStackHost(t *testing.T) {
+	// the intake only listens on IPv4 whereas localhost may resolve to an IPv6 address first
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+	_, port := AddrToHostPort(l.Addr())
+
+	connManager := NewConnectionManager(Endpoint{Host: "localhost", Port: port, FallbackDelay: 10 * time.Millisecond}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := connManager.connect(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	conn.Close()
+}
+
 func TestNewConnectionReturnsWhenContextCancelled(t *testing.T) {
-	destinationsCtx := NewDestinationsContext()
+	destinationsCtx := NewDestinationsContext(nil)
 	connManager := newConnectionManagerForHostPort("foo", 0)
 
 	destinationsCtx.Start()
@@ -67,3 +84,47 @@ func TestNewConnectionReturnsWhenContextCancelled(t *testing.T) {
 	// Make sure NewConnection really returns.
 	wg.Wait()
 }
+
+func TestNewConnectionReturnsWhenContextCancelledDuringBackoff(t *testing.T) {
+	// nothing listens on the port of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	host, port := AddrToHostPort(l.Addr())
+	l.Close()
+
+	destinationsCtx := NewDestinationsContext(nil)
+	connManager := NewConnectionManager(Endpoint{Host: host, Port: port, BackoffBase: time.Hour, BackoffMax: time.Hour}, nil)
+
+	destinationsCtx.Start()
+	done := make(chan error)
+	go func() {
+		_, err := connManager.NewConnection(destinationsCtx.Context())
+		done <- err
+	}()
+
+	// wait for the first attempt to fail
+	for connManager.BackoffDelay() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, connManager.BackoffDelay() >= 30*time.Minute)
+
+	destinationsCtx.Stop()
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "NewConnection did not return")
+	}
+}
+
+func TestLastSuccessIsRecordedOnSuccess(t *testing.T) {
+	connManager := newConnectionManagerForHostPort("foo", 1234)
+	assert.True(t, connManager.LastSuccess().IsZero())
+
+	connManager.recordFailure()
+	assert.True(t, connManager.LastSuccess().IsZero())
+
+	before := time.Now()
+	connManager.recordSuccess()
+	assert.False(t, connManager.LastSuccess().Before(before))
+}
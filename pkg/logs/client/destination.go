@@ -6,7 +6,13 @@
 package client
 
 import (
+	"context"
+	"io"
 	"net"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
 // FramingError represents a kind of error that can occur when a log can not properly
@@ -27,6 +33,24 @@ func (e *FramingError) Error() string {
 	return e.err.Error()
 }
 
+// RefusedError is returned when a message broker refuses to publish logs permanently,
+// publishing them again would fail the same way.
+type RefusedError struct {
+	err error
+}
+
+// NewRefusedError returns a new refused error.
+func NewRefusedError(err error) *RefusedError {
+	return &RefusedError{
+		err: err,
+	}
+}
+
+// Error returns the message of the error.
+func (e *RefusedError) Error() string {
+	return e.err.Error()
+}
+
 // Destination is responsible for shipping logs to a remote server over TCP.
 type Destination struct {
 	prefixer            Prefixer
@@ -34,43 +58,189 @@ type Destination struct {
 	connManager         *ConnectionManager
 	destinationsContext *DestinationsContext
 	conn                net.Conn
+	breaker             *circuitBreaker
+	writeTimeout        time.Duration
+	maxConnectionAge    time.Duration
+	maxConnectionIdle   time.Duration
+	// connectedAt and lastWrite are the times the connection was established and last written to
+	connectedAt time.Time
+	lastWrite   time.Time
 }
 
 // NewDestination returns a new destination.
 func NewDestination(endpoint Endpoint, destinationsContext *DestinationsContext) *Destination {
+	var prefixer Prefixer
+	if endpoint.UseCEF || endpoint.UseJSON {
+		// CEF records and JSON lines are sent to SIEMs and custom sinks which do not expect any API key
+		prefixer = &noopPrefixer{}
+	} else {
+		prefixer = NewAPIKeyPrefixer(endpoint.APIKey, endpoint.Logset)
+	}
 	return &Destination{
-		prefixer:            NewAPIKeyPrefixer(endpoint.APIKey, endpoint.Logset),
+		prefixer:            prefixer,
 		delimiter:           NewDelimiter(endpoint.UseProto),
-		connManager:         NewConnectionManager(endpoint),
+		connManager:         NewConnectionManager(endpoint, destinationsContext.connectionLimiter),
 		destinationsContext: destinationsContext,
+		breaker:             newCircuitBreaker(endpoint.CircuitBreakerThreshold, endpoint.CircuitBreakerCooldown),
+		writeTimeout:        endpoint.WriteTimeout,
+		maxConnectionAge:    endpoint.MaxConnectionAge,
+		maxConnectionIdle:   endpoint.MaxConnectionIdle,
 	}
 }
 
 // Send transforms a message into a frame and sends it to a remote server,
 // returns an error if the operation failed.
+// The connection is re-established after a failure with a delay growing with the consecutive failures.
 func (d *Destination) Send(payload []byte) error {
-	if d.conn == nil {
-		var err error
-
-		// We work only if we have a started destination context
-		ctx := d.destinationsContext.Context()
-		if d.conn, err = d.connManager.NewConnection(ctx); err != nil {
-			return err
-		}
-	}
+	return d.send(d.destinationsContext.Context(), payload)
+}
 
+// send sends a message, ctx interrupts the connection attempts.
+func (d *Destination) send(ctx context.Context, payload []byte) error {
 	content := d.prefixer.prefix(payload)
 	frame, err := d.delimiter.delimit(content)
 	if err != nil {
 		return NewFramingError(err)
 	}
+	return d.write(ctx, frame)
+}
+
+// SendBatch transforms the messages into frames and sends them to a remote server in a single write,
+// returns an error if the operation failed, in which case none of the messages are considered sent.
+func (d *Destination) SendBatch(payloads [][]byte) error {
+	return d.sendBatch(d.destinationsContext.Context(), payloads)
+}
+
+// sendBatch sends the messages in a single write, ctx interrupts the connection attempts.
+func (d *Destination) sendBatch(ctx context.Context, payloads [][]byte) error {
+	var frames []byte
+	for _, payload := range payloads {
+		content := d.prefixer.prefix(payload)
+		frame, err := d.delimiter.delimit(content)
+		if err != nil {
+			return NewFramingError(err)
+		}
+		frames = append(frames, frame...)
+	}
+	return d.write(ctx, frames)
+}
 
-	_, err = d.conn.Write(frame)
+// write writes frames to the connection, which is established first if need be.
+// The frames are written until the last byte even when the connection accepts them partially,
+// the connection is closed when a write fails so that the frames sent again by the caller
+// start on a new connection instead of following a truncated frame.
+// With a circuit breaker, a single connection attempt is made so that the failures are counted,
+// and ErrCircuitOpen is returned without trying to send the frames while the circuit is open.
+// With a write timeout, the frames must be written before its deadline, set anew for each write,
+// otherwise the endpoint is considered stuck and the write fails as any other.
+// The connection is recycled before the frames are written once it is too old or was idle for too long.
+func (d *Destination) write(ctx context.Context, frames []byte) error {
+	if !d.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	if d.conn != nil && d.shouldRecycle(time.Now()) {
+		log.Debugf("Recycling the connection to %v established at %v", d.Address(), d.connectedAt)
+		metrics.ConnectionsRecycled.Add(1)
+		d.connManager.CloseConnection(d.conn)
+		d.conn = nil
+	}
+	if d.conn == nil {
+		var err error
+		if d.breaker == nil {
+			d.conn, err = d.connManager.NewConnection(ctx)
+		} else {
+			d.conn, err = d.connManager.TryNewConnection(ctx)
+		}
+		if err == ErrReconnectAttemptsExhausted {
+			d.destinationsContext.giveUp(d.Address(), err)
+			return err
+		}
+		if err != nil {
+			d.breaker.failure()
+			return err
+		}
+		d.connectedAt = time.Now()
+		d.lastWrite = d.connectedAt
+	}
+
+	if d.writeTimeout > 0 {
+		d.conn.SetWriteDeadline(time.Now().Add(d.writeTimeout))
+	}
+	err := writeFully(d.conn, frames)
 	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			metrics.SlowConsumerTimeouts.Add(1)
+			log.Warnf("Could not write logs to %v for %v, reconnecting: %v", d.Address(), d.writeTimeout, err)
+		}
+		metrics.WriteErrorReconnects.Add(1)
 		d.connManager.CloseConnection(d.conn)
 		d.conn = nil
+		d.connManager.recordFailure()
+		d.breaker.failure()
 		return err
 	}
+	d.lastWrite = time.Now()
+	d.connManager.recordSuccess()
+	d.breaker.success()
+
+	return nil
+}
+
+// shouldRecycle returns true if the connection must be re-established at now
+// because it is older than the max age or was not written to for longer than the max idle time.
+func (d *Destination) shouldRecycle(now time.Time) bool {
+	if d.maxConnectionAge > 0 && !d.connectedAt.IsZero() && now.Sub(d.connectedAt) >= d.maxConnectionAge {
+		return true
+	}
+	return d.maxConnectionIdle > 0 && !d.lastWrite.IsZero() && now.Sub(d.lastWrite) >= d.maxConnectionIdle
+}
 
+// writeFully writes data to conn until it is fully written,
+// returns an error if a write fails or does not make progress.
+func writeFully(conn net.Conn, data []byte) error {
+	for len(data) > 0 {
+		n, err := conn.Write(data)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+		if n < len(data) {
+			metrics.ShortWrites.Add(1)
+		}
+		data = data[n:]
+	}
 	return nil
 }
+
+// Address returns the address of the server the logs are sent to.
+func (d *Destination) Address() string {
+	return d.connManager.address()
+}
+
+// BufferFullPolicy returns the policy applied when the destination does not keep up with the main one.
+func (d *Destination) BufferFullPolicy() string {
+	return d.connManager.endpoint.BufferFullPolicy
+}
+
+// Route returns the route of the logs sent to the destination, nil when all the logs are sent to it.
+func (d *Destination) Route() *Route {
+	return d.connManager.endpoint.Route
+}
+
+// BackoffDelay returns the delay waited for before the last attempt to reconnect,
+// 0 when the last logs were sent successfully.
+func (d *Destination) BackoffDelay() time.Duration {
+	return d.connManager.BackoffDelay()
+}
+
+// LastSuccess returns the last time logs were sent successfully, the zero time if they never were.
+func (d *Destination) LastSuccess() time.Time {
+	return d.connManager.LastSuccess()
+}
+
+// CircuitState returns the state of the circuit breaker of the destination, closed when it has none.
+func (d *Destination) CircuitState() string {
+	return d.breaker.currentState()
+}
@@ -0,0 +1,244 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// shortWriteConn accepts at most maxWrite bytes per write and fails once it accepted limit bytes.
+type shortWriteConn struct {
+	net.Conn
+	maxWrite int
+	limit    int
+	written  []byte
+	closed   bool
+}
+
+func (c *shortWriteConn) Write(b []byte) (int, error) {
+	if len(c.written) >= c.limit {
+		return 0, errors.New("connection reset")
+	}
+	n := len(b)
+	if n > c.maxWrite {
+		n = c.maxWrite
+	}
+	if n > c.limit-len(c.written) {
+		n = c.limit - len(c.written)
+	}
+	c.written = append(c.written, b[:n]...)
+	return n, nil
+}
+
+func (c *shortWriteConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDestinationWritesTheWholeFrameOnShortWrites(t *testing.T) {
+	shortWrites := metrics.ShortWrites.Value()
+	destination := NewDestination(Endpoint{APIKey: "foo"}, NewDestinationsContext(nil))
+	conn := &shortWriteConn{maxWrite: 3, limit: 100}
+	destination.conn = conn
+
+	assert.Nil(t, destination.Send([]byte("hello")))
+	assert.Equal(t, "foo hello\n", string(conn.written))
+	assert.Equal(t, shortWrites+3, metrics.ShortWrites.Value())
+	assert.False(t, conn.closed)
+}
+
+func TestDestinationResendsTheWholeFrameOnANewConnectionAfterAWriteError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	reconnects := metrics.WriteErrorReconnects.Value()
+	destinationsContext := NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	endpoint := AddrToEndPoint(l.Addr())
+	endpoint.APIKey = "foo"
+	endpoint.BackoffBase = time.Millisecond
+	endpoint.BackoffMax = time.Millisecond
+	destination := NewDestination(endpoint, destinationsContext)
+
+	// the connection fails in the middle of the frame
+	conn := &shortWriteConn{maxWrite: 3, limit: 6}
+	destination.conn = conn
+	assert.NotNil(t, destination.Send([]byte("hello")))
+	assert.Equal(t, "foo he", string(conn.written))
+	assert.True(t, conn.closed)
+	assert.Nil(t, destination.conn)
+	assert.Equal(t, reconnects+1, metrics.WriteErrorReconnects.Value())
+
+	// the whole frame is sent again on a new connection
+	assert.Nil(t, destination.Send([]byte("hello")))
+	assert.Equal(t, "foo hello\n", <-received)
+}
+
+func TestDestinationStopsSendingWhenItsCircuitIsOpen(t *testing.T) {
+	// nothing listens on the address once the listener is closed
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	l.Close()
+
+	destinationsContext := NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	endpoint := AddrToEndPoint(l.Addr())
+	endpoint.BackoffBase = time.Millisecond
+	endpoint.BackoffMax = time.Millisecond
+	endpoint.CircuitBreakerThreshold = 2
+	endpoint.CircuitBreakerCooldown = time.Hour
+	destination := NewDestination(endpoint, destinationsContext)
+
+	// each send makes a single connection attempt
+	for i := 0; i < 2; i++ {
+		err = destination.Send([]byte("hello"))
+		assert.NotNil(t, err)
+		assert.NotEqual(t, ErrCircuitOpen, err)
+	}
+	assert.Equal(t, CircuitOpen, destination.CircuitState())
+	assert.Equal(t, ErrCircuitOpen, destination.Send([]byte("hello")))
+	assert.Equal(t, ErrCircuitOpen, destination.SendBatch([][]byte{[]byte("hello")}))
+}
+
+func TestDestinationReconnectsWhenTheEndpointStopsReading(t *testing.T) {
+	timeouts := metrics.SlowConsumerTimeouts.Value()
+	endpoint := Endpoint{
+		APIKey:                  "foo",
+		WriteTimeout:            10 * time.Millisecond,
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerCooldown:  time.Hour,
+	}
+	destination := NewDestination(endpoint, NewDestinationsContext(nil))
+
+	// the endpoint accepts the connections but never reads from them
+	for i := 0; i < 2; i++ {
+		conn, stuck := net.Pipe()
+		defer stuck.Close()
+		destination.conn = conn
+		err := destination.SendBatch([][]byte{[]byte("hello"), []byte("world")})
+		netErr, isNetErr := err.(net.Error)
+		assert.True(t, isNetErr && netErr.Timeout())
+		assert.Nil(t, destination.conn)
+	}
+	assert.Equal(t, timeouts+2, metrics.SlowConsumerTimeouts.Value())
+	assert.Equal(t, CircuitOpen, destination.CircuitState())
+}
+
+func TestDestinationRecyclesTheConnectionBetweenTheFrames(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	received := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				received <- line
+			}()
+		}
+	}()
+
+	destinationsContext := NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	endpoint := AddrToEndPoint(l.Addr())
+	endpoint.APIKey = "foo"
+	endpoint.MaxConnectionAge = time.Hour
+	endpoint.MaxConnectionIdle = time.Minute
+
+	for name, expire := range map[string]func(d *Destination){
+		"too old": func(d *Destination) {
+			d.connectedAt = time.Now().Add(-2 * time.Hour)
+		},
+		"idle": func(d *Destination) {
+			d.lastWrite = time.Now().Add(-2 * time.Minute)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			recycled := metrics.ConnectionsRecycled.Value()
+			destination := NewDestination(endpoint, destinationsContext)
+			conn := &shortWriteConn{maxWrite: 100, limit: 100}
+			destination.conn = conn
+			destination.connectedAt = time.Now()
+			destination.lastWrite = time.Now()
+
+			// the connection is kept as long as it is young and busy
+			assert.Nil(t, destination.Send([]byte("hello")))
+			assert.Equal(t, "foo hello\n", string(conn.written))
+			assert.False(t, conn.closed)
+
+			// the frame is written to a new connection
+			expire(destination)
+			assert.Nil(t, destination.Send([]byte("world")))
+			assert.Equal(t, "foo hello\n", string(conn.written))
+			assert.True(t, conn.closed)
+			assert.Equal(t, "foo world\n", <-received)
+			assert.Equal(t, recycled+1, metrics.ConnectionsRecycled.Value())
+			destination.connManager.CloseConnection(destination.conn)
+		})
+	}
+}
+
+func TestWriteFullyFailsWhenTheConnectionDoesNotMakeProgress(t *testing.T) {
+	conn := &shortWriteConn{maxWrite: 0, limit: 100}
+	assert.Equal(t, io.ErrShortWrite, writeFully(conn, []byte("hello")))
+}
+
+func TestDestinationGivesUpAfterMaxReconnectAttempts(t *testing.T) {
+	// nothing listens on the address once the listener is closed
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	l.Close()
+
+	destinationsContext := NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	failures := make(chan error, 2)
+	destinationsContext.OnTerminalFailure(func(err error) { failures <- err })
+	endpoint := AddrToEndPoint(l.Addr())
+	endpoint.BackoffBase = time.Millisecond
+	endpoint.BackoffMax = time.Millisecond
+	endpoint.MaxReconnectAttempts = 2
+	destination := NewDestination(endpoint, destinationsContext)
+
+	assert.Equal(t, ErrReconnectAttemptsExhausted, destination.Send([]byte("hello")))
+	assert.Equal(t, ErrReconnectAttemptsExhausted, <-failures)
+
+	// the next logs are not sent and the handler is only called once
+	assert.Equal(t, ErrReconnectAttemptsExhausted, destination.SendBatch([][]byte{[]byte("hello")}))
+	select {
+	case err := <-failures:
+		assert.Fail(t, "the terminal failure handler was called twice", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -5,14 +5,39 @@
 
 package client
 
+import (
+	"time"
+)
+
+// MainDestination is the destination the logs are sent to before being committed,
+// either a single destination or a failover between several destinations.
+type MainDestination interface {
+	Send(payload []byte) error
+	SendBatch(payloads [][]byte) error
+	Address() string
+	BackoffDelay() time.Duration
+	LastSuccess() time.Time
+}
+
+// AdditionalDestination is a destination the logs are sent to in addition to the main one,
+// either over TCP or to an HTTP intake, the logs which failed to be sent to it are not retried.
+type AdditionalDestination interface {
+	Send(payload []byte) error
+	Address() string
+	LastSuccess() time.Time
+	CircuitState() string
+	BufferFullPolicy() string
+	Route() *Route
+}
+
 // Destinations holds the main destination and additional ones to send logs to.
 type Destinations struct {
-	Main        *Destination
-	Additionals []*Destination
+	Main        MainDestination
+	Additionals []AdditionalDestination
 }
 
 // NewDestinations returns a new destinations composite.
-func NewDestinations(main *Destination, additionals []*Destination) *Destinations {
+func NewDestinations(main MainDestination, additionals []AdditionalDestination) *Destinations {
 	return &Destinations{
 		Main:        main,
 		Additionals: additionals,
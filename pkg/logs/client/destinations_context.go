@@ -8,19 +8,29 @@ package client
 import (
 	"context"
 	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
 // A DestinationsContext manages senders and allows us to "unclog" the pipeline
 // when trying to stop it and failing to send messages.
+// It also holds the connection limiter shared by all the destinations
+// and notifies the terminal failure of a destination which gave up sending the logs.
 type DestinationsContext struct {
-	context context.Context
-	cancel  context.CancelFunc
-	mutex   sync.Mutex
+	context           context.Context
+	cancel            context.CancelFunc
+	mutex             sync.Mutex
+	connectionLimiter *ConnectionLimiter
+	terminalFailure   func(err error)
+	gaveUp            bool
 }
 
-// NewDestinationsContext returns an initialized ConnectionManager
-func NewDestinationsContext() *DestinationsContext {
-	return &DestinationsContext{}
+// NewDestinationsContext returns an initialized DestinationsContext,
+// connectionLimiter can be nil to not limit the concurrent connection attempts.
+func NewDestinationsContext(connectionLimiter *ConnectionLimiter) *DestinationsContext {
+	return &DestinationsContext{
+		connectionLimiter: connectionLimiter,
+	}
 }
 
 // Start creates a context that will be cancelled on Stop()
@@ -47,3 +57,26 @@ func (dc *DestinationsContext) Context() context.Context {
 	defer dc.mutex.Unlock()
 	return dc.context
 }
+
+// OnTerminalFailure sets the handler called once, from a goroutine of its own, when a destination gives up
+// sending the logs after too many failed attempts to reconnect, so that it can stop the agent.
+func (dc *DestinationsContext) OnTerminalFailure(handler func(err error)) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	dc.terminalFailure = handler
+}
+
+// giveUp reports that the destination sending the logs to address gave up because of err,
+// the terminal failure handler is only called for the first destination giving up.
+func (dc *DestinationsContext) giveUp(address string, err error) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	if dc.gaveUp {
+		return
+	}
+	dc.gaveUp = true
+	log.Errorf("Gave up sending logs to %v, they are dropped until the agent is restarted: %v", address, err)
+	if dc.terminalFailure != nil {
+		go dc.terminalFailure(err)
+	}
+}
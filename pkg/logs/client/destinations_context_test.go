@@ -11,7 +11,7 @@ import (
 )
 
 func TestNewDestinationsContext(t *testing.T) {
-	destinationsCtx := NewDestinationsContext()
+	destinationsCtx := NewDestinationsContext(nil)
 	assert.Nil(t, destinationsCtx.Context())
 
 	destinationsCtx.Start()
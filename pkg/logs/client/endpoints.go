@@ -5,6 +5,10 @@
 
 package client
 
+import (
+	"time"
+)
+
 // Endpoint holds all the organization and network parameters to send logs to Datadog.
 type Endpoint struct {
 	APIKey       string `mapstructure:"api_key"`
@@ -13,13 +17,100 @@ type Endpoint struct {
 	Port         int
 	UseSSL       bool
 	UseProto     bool
-	ProxyAddress string
+	UseCEF       bool
+	ProxyAddress string `mapstructure:"socks5_proxy_address"`
+	// HTTPProxy is the URL of an HTTP or HTTPS proxy the connections to the endpoint are tunneled through
+	// with the CONNECT method instead of the SOCKS5 proxy at ProxyAddress, with the credentials it holds if any.
+	HTTPProxy string `mapstructure:"http_proxy"`
+	// UseJSON sends the logs as JSON objects holding their metadata, one per line,
+	// the logs which are valid JSON are embedded as objects instead of strings when JSONRawMessage is set.
+	UseJSON        bool `mapstructure:"use_json"`
+	JSONRawMessage bool `mapstructure:"json_raw_message"`
+	// UseHTTP posts the logs to an HTTP intake at Path instead of writing them to a TCP connection,
+	// Path defaults to /v1/input.
+	UseHTTP bool `mapstructure:"use_http"`
+	Path    string
+	// Compression compresses the payloads posted to an HTTP intake with gzip or zstd at CompressionLevel,
+	// 0 meaning the default level, they are posted uncompressed once the intake answers they are not supported.
+	Compression      string `mapstructure:"compression"`
+	CompressionLevel int    `mapstructure:"compression_level"`
+	// BackoffBase and BackoffMax bound the delays between the attempts to send logs,
+	// the defaults are used when they are not set.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// FallbackDelay is the head start given to the IPv6 addresses of a dual-stack host before its IPv4 addresses
+	// are dialed concurrently, the first connection established is used, 0 means the default of 300ms.
+	FallbackDelay time.Duration
+	// CircuitBreakerThreshold is the number of consecutive failures after which the logs are dropped without
+	// trying to send them for CircuitBreakerCooldown, before a single attempt probes the endpoint again,
+	// 0 means the circuit never opens and the attempts to send the logs are retried until they succeed.
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+	// WriteTimeout is the time after which a write to the connection fails when the endpoint does not read
+	// the logs sent, the connection is then re-established as after any other failure, 0 means never.
+	WriteTimeout time.Duration
+	// KeepAlive is the period of the TCP keep-alive probes of the connections, so that a connection dropped silently
+	// by a load balancer or a firewall is detected, 0 means the default of 15s and a negative value disables them.
+	KeepAlive time.Duration
+	// MaxConnectionAge and MaxConnectionIdle are the times after which the connection is closed and re-established
+	// before the next write, since it was established and since the last write, to spread the connections over
+	// the backends of a load balancer and to shed the connections it dropped silently, 0 means never.
+	MaxConnectionAge  time.Duration
+	MaxConnectionIdle time.Duration
+	// MaxReconnectAttempts is the number of failed attempts to reconnect, following a first failure, after which
+	// the destination gives up sending the logs until the agent is restarted, 0 means the attempts never stop.
+	MaxReconnectAttempts int
+	// BufferFullPolicy is applied when an additional endpoint does not keep up with the main one,
+	// either "drop" to drop the logs or "block" to slow down the main endpoint,
+	// the logs are always sent to the main endpoint before being committed.
+	BufferFullPolicy string `mapstructure:"buffer_full_policy"`
+	// Route restricts the logs sent to an additional endpoint, all the logs are sent to it when it is nil,
+	// the logs are always sent to the main endpoint.
+	Route *Route
+	// Kafka publishes the logs sent to an additional endpoint to a Kafka topic instead of sending them to Host.
+	Kafka *KafkaEndpoint
+	// TLSCertPath and TLSKeyPath are the client certificate and its key presented to the endpoint
+	// when it requires mutual TLS, TLSCAPath is a bundle of certificate authorities trusted instead of the system ones.
+	TLSCertPath string `mapstructure:"tls_cert_path"`
+	TLSKeyPath  string `mapstructure:"tls_key_path"`
+	TLSCAPath   string `mapstructure:"tls_ca_path"`
+}
+
+// Route selects the logs with one of Statuses or whose content, as sent, matches the regular expression Pattern.
+type Route struct {
+	Statuses []string
+	Pattern  string
+}
+
+// AMQPEndpoint holds the parameters to publish logs to an AMQP exchange.
+type AMQPEndpoint struct {
+	URL          string
+	Exchange     string
+	ExchangeType string
+	Durable      bool
+	// RoutingKeyTag is the key of the tag whose value is used as routing key,
+	// the service of the logs is used instead when they don't have this tag.
+	RoutingKeyTag string
+}
+
+// KafkaEndpoint holds the parameters to publish logs to a Kafka topic.
+type KafkaEndpoint struct {
+	Brokers []string
+	// Topic is the topic of the logs whose source does not set one.
+	Topic string
 }
 
-// Endpoints holds the main endpoint and additional ones to dualship logs.
+// Endpoints holds the main endpoint and additional ones to dualship logs,
+// the logs are published to the AMQP or the Kafka endpoint instead when it is set.
+// When Failovers is set, the logs are sent to the first healthy endpoint of Main then Failovers
+// instead of Main alone, as selected by the health probes following FailoverPolicy.
 type Endpoints struct {
-	Main        Endpoint
-	Additionals []Endpoint
+	Main           Endpoint
+	Additionals    []Endpoint
+	AMQP           *AMQPEndpoint
+	Kafka          *KafkaEndpoint
+	Failovers      []Endpoint
+	FailoverPolicy FailoverPolicy
 }
 
 // NewEndpoints returns a new endpoints composite.
@@ -0,0 +1,269 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Default settings of the failover, used when they are not set.
+const (
+	defaultProbeInterval     = 10 * time.Second
+	defaultFailureThreshold  = 3
+	defaultRecoveryThreshold = 6
+)
+
+// FailoverPolicy holds the settings of the health probes promoting and demoting the endpoints of a failover.
+type FailoverPolicy struct {
+	// ProbeInterval is the delay between two probes of the endpoints
+	ProbeInterval time.Duration
+	// FailureThreshold is the number of consecutive failed probes after which an endpoint is demoted
+	FailureThreshold int
+	// RecoveryThreshold is the number of consecutive successful probes after which an endpoint is promoted again,
+	// it is usually greater than FailureThreshold so that a flapping endpoint is not promoted back and forth
+	RecoveryThreshold int
+}
+
+// endpointState holds the result of the last probes of an endpoint.
+type endpointState struct {
+	healthy   bool
+	failures  int
+	successes int
+}
+
+// record updates the state with the result of a probe, returns true if the health of the endpoint changed.
+func (s *endpointState) record(success bool, policy FailoverPolicy) bool {
+	if success {
+		s.failures = 0
+		s.successes++
+		if !s.healthy && s.successes >= policy.RecoveryThreshold {
+			s.healthy = true
+			return true
+		}
+		return false
+	}
+	s.successes = 0
+	s.failures++
+	if s.healthy && s.failures >= policy.FailureThreshold {
+		s.healthy = false
+		return true
+	}
+	return false
+}
+
+// Failover sends the logs to a single destination at a time, the first healthy one of a list ordered by priority.
+// The endpoints are probed periodically: the active one is demoted after consecutive failed probes, in which case
+// the logs are sent to the next healthy endpoint, and a higher priority endpoint is promoted back after consecutive
+// successful probes. Unlike the additional destinations, the logs are only sent to the active destination.
+type Failover struct {
+	endpoints           []Endpoint
+	destinations        []*Destination
+	destinationsContext *DestinationsContext
+	policy              FailoverPolicy
+	// check probes an endpoint, returns an error if it is unreachable
+	check func(ctx context.Context, endpoint Endpoint) error
+
+	// mutex guards the active destination and the cancellation of the send in flight
+	mutex  sync.Mutex
+	active int
+	cancel context.CancelFunc
+
+	// states and lastProbe are only accessed by the goroutine probing the endpoints
+	states    []endpointState
+	lastProbe time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFailover returns a new failover between endpoints, ordered by priority,
+// the defaults are used for the settings of policy which are not set.
+func NewFailover(endpoints []Endpoint, policy FailoverPolicy, destinationsContext *DestinationsContext) *Failover {
+	if policy.ProbeInterval <= 0 {
+		policy.ProbeInterval = defaultProbeInterval
+	}
+	if policy.FailureThreshold <= 0 {
+		policy.FailureThreshold = defaultFailureThreshold
+	}
+	if policy.RecoveryThreshold <= 0 {
+		policy.RecoveryThreshold = defaultRecoveryThreshold
+	}
+	destinations := make([]*Destination, len(endpoints))
+	states := make([]endpointState, len(endpoints))
+	for i, endpoint := range endpoints {
+		destinations[i] = NewDestination(endpoint, destinationsContext)
+		states[i].healthy = true
+	}
+	return &Failover{
+		endpoints:           endpoints,
+		destinations:        destinations,
+		destinationsContext: destinationsContext,
+		policy:              policy,
+		check:               CheckEndpoint,
+		states:              states,
+		stop:                make(chan struct{}),
+		done:                make(chan struct{}),
+	}
+}
+
+// Start starts probing the endpoints.
+func (f *Failover) Start() {
+	go f.run()
+}
+
+// Stop stops probing the endpoints, the logs keep being sent to the active destination.
+func (f *Failover) Stop() {
+	close(f.stop)
+	<-f.done
+}
+
+// Send sends a message to the active destination, the message is sent to the new active destination
+// if the active one is demoted in the meantime.
+func (f *Failover) Send(payload []byte) error {
+	return f.do(func(ctx context.Context, destination *Destination) error {
+		return destination.send(ctx, payload)
+	})
+}
+
+// SendBatch sends the messages in a single write to the active destination, the messages are sent
+// to the new active destination if the active one is demoted in the meantime.
+func (f *Failover) SendBatch(payloads [][]byte) error {
+	return f.do(func(ctx context.Context, destination *Destination) error {
+		return destination.sendBatch(ctx, payloads)
+	})
+}
+
+// do calls send with the active destination until it succeeds, fails or the destinations context is cancelled,
+// the context passed to send is cancelled when the active destination changes to stop waiting for a connection.
+func (f *Failover) do(send func(ctx context.Context, destination *Destination) error) error {
+	for {
+		parent := f.destinationsContext.Context()
+		ctx, cancel := context.WithCancel(parent)
+		f.mutex.Lock()
+		destination := f.destinations[f.active]
+		f.cancel = cancel
+		f.mutex.Unlock()
+
+		err := send(ctx, destination)
+		cancel()
+		if err != nil && ctx.Err() != nil && parent.Err() == nil {
+			// the active destination changed while sending
+			continue
+		}
+		return err
+	}
+}
+
+// Address returns the address of the active destination.
+func (f *Failover) Address() string {
+	return f.activeDestination().Address()
+}
+
+// BackoffDelay returns the delay waited for before the last attempt to reconnect to the active destination,
+// 0 when the last logs were sent successfully.
+func (f *Failover) BackoffDelay() time.Duration {
+	return f.activeDestination().BackoffDelay()
+}
+
+// LastSuccess returns the last time logs were sent successfully to any of the destinations,
+// the zero time if they never were.
+func (f *Failover) LastSuccess() time.Time {
+	var lastSuccess time.Time
+	for _, destination := range f.destinations {
+		if success := destination.LastSuccess(); success.After(lastSuccess) {
+			lastSuccess = success
+		}
+	}
+	return lastSuccess
+}
+
+// Destinations returns the destinations of the failover, ordered by priority.
+func (f *Failover) Destinations() []*Destination {
+	return f.destinations
+}
+
+// activeDestination returns the destination the logs are sent to.
+func (f *Failover) activeDestination() *Destination {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.destinations[f.active]
+}
+
+// run probes the endpoints every probe interval until the failover is stopped.
+func (f *Failover) run() {
+	defer close(f.done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-f.stop
+		cancel()
+	}()
+	ticker := time.NewTicker(f.policy.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.probe(ctx)
+		}
+	}
+}
+
+// probe probes all the endpoints then activates the first healthy one,
+// the active endpoint is not probed when logs were sent to it since the last probe.
+func (f *Failover) probe(ctx context.Context) {
+	active := f.activeDestination()
+	for i, endpoint := range f.endpoints {
+		success := f.destinations[i] == active && f.destinations[i].LastSuccess().After(f.lastProbe)
+		if !success {
+			probeCtx, cancel := context.WithTimeout(ctx, connectionTimeout)
+			err := f.check(probeCtx, endpoint)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Debugf("Could not reach the logs endpoint %v: %v", f.destinations[i].Address(), err)
+			}
+			success = err == nil
+		}
+		if f.states[i].record(success, f.policy) {
+			if success {
+				log.Infof("The logs endpoint %v is healthy again", f.destinations[i].Address())
+			} else {
+				log.Warnf("The logs endpoint %v is unhealthy", f.destinations[i].Address())
+			}
+		}
+	}
+	f.lastProbe = time.Now()
+	f.activate()
+}
+
+// activate sends the logs to the first healthy destination, the active destination is kept
+// when none of them is healthy, the send in flight is interrupted when the active destination changes.
+func (f *Failover) activate() {
+	for i := range f.states {
+		if !f.states[i].healthy {
+			continue
+		}
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if i == f.active {
+			return
+		}
+		log.Infof("Sending the logs to %v instead of %v", f.destinations[i].Address(), f.destinations[f.active].Address())
+		f.active = i
+		if f.cancel != nil {
+			f.cancel()
+		}
+		return
+	}
+}
@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
+)
+
+type FailoverTestSuite struct {
+	suite.Suite
+	destinationsCtx *DestinationsContext
+	primary         Endpoint
+	secondary       net.Listener
+	failover        *Failover
+	// reachable tells whether the primary endpoint is reachable for the probes
+	reachable bool
+}
+
+func (suite *FailoverTestSuite) SetupTest() {
+	// nothing listens on the port of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Nil(err)
+	suite.primary = AddrToEndPoint(l.Addr())
+	suite.primary.BackoffBase = time.Millisecond
+	suite.primary.BackoffMax = 10 * time.Millisecond
+	l.Close()
+	suite.secondary = mock.NewMockLogsIntake(suite.T())
+
+	suite.destinationsCtx = NewDestinationsContext(nil)
+	suite.destinationsCtx.Start()
+	policy := FailoverPolicy{ProbeInterval: time.Hour, FailureThreshold: 2, RecoveryThreshold: 3}
+	suite.failover = NewFailover([]Endpoint{suite.primary, AddrToEndPoint(suite.secondary.Addr())}, policy, suite.destinationsCtx)
+	suite.reachable = true
+	suite.failover.check = func(ctx context.Context, endpoint Endpoint) error {
+		if endpoint == suite.primary && !suite.reachable {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+}
+
+func (suite *FailoverTestSuite) TearDownTest() {
+	suite.destinationsCtx.Stop()
+	suite.secondary.Close()
+}
+
+func (suite *FailoverTestSuite) probe(times int) {
+	for i := 0; i < times; i++ {
+		suite.failover.probe(context.Background())
+	}
+}
+
+func (suite *FailoverTestSuite) TestFailoverSendsToTheNextHealthyEndpoint() {
+	suite.Equal(suite.failover.Destinations()[0].Address(), suite.failover.Address())
+
+	// the send waits for the primary endpoint until it is demoted
+	done := make(chan error)
+	go func() {
+		done <- suite.failover.Send([]byte("foo"))
+	}()
+	suite.reachable = false
+	suite.probe(1)
+	suite.Equal(suite.failover.Destinations()[0].Address(), suite.failover.Address())
+	suite.probe(1)
+	suite.Equal(suite.failover.Destinations()[1].Address(), suite.failover.Address())
+
+	select {
+	case err := <-done:
+		suite.Nil(err)
+	case <-time.After(5 * time.Second):
+		suite.Fail("the send was not interrupted")
+	}
+	suite.True(suite.failover.Destinations()[0].LastSuccess().IsZero())
+	suite.False(suite.failover.Destinations()[1].LastSuccess().IsZero())
+	suite.Equal(suite.failover.Destinations()[1].LastSuccess(), suite.failover.LastSuccess())
+}
+
+func (suite *FailoverTestSuite) TestFailoverFailsBackWithHysteresis() {
+	suite.reachable = false
+	suite.probe(2)
+	suite.Equal(suite.failover.Destinations()[1].Address(), suite.failover.Address())
+
+	// the primary endpoint is flapping
+	suite.reachable = true
+	suite.probe(2)
+	suite.reachable = false
+	suite.probe(1)
+	suite.reachable = true
+	suite.probe(2)
+	suite.Equal(suite.failover.Destinations()[1].Address(), suite.failover.Address())
+
+	suite.probe(1)
+	suite.Equal(suite.failover.Destinations()[0].Address(), suite.failover.Address())
+}
+
+func (suite *FailoverTestSuite) TestFailoverKeepsTheActiveEndpointWhenNoneIsHealthy() {
+	suite.failover.check = func(ctx context.Context, endpoint Endpoint) error {
+		return errors.New("unreachable")
+	}
+	suite.probe(2)
+	suite.Equal(suite.failover.Destinations()[0].Address(), suite.failover.Address())
+}
+
+func (suite *FailoverTestSuite) TestFailoverStops() {
+	suite.failover.Start()
+	suite.failover.Stop()
+}
+
+func TestFailoverTestSuite(t *testing.T) {
+	suite.Run(t, new(FailoverTestSuite))
+}
+
+func TestEndpointStateRecordsTheProbes(t *testing.T) {
+	policy := FailoverPolicy{FailureThreshold: 2, RecoveryThreshold: 3}
+	state := endpointState{healthy: true}
+
+	assert.False(t, state.record(false, policy))
+	assert.True(t, state.healthy)
+	assert.True(t, state.record(false, policy))
+	assert.False(t, state.healthy)
+
+	assert.False(t, state.record(true, policy))
+	assert.False(t, state.record(true, policy))
+	assert.False(t, state.healthy)
+	assert.True(t, state.record(true, policy))
+	assert.True(t, state.healthy)
+}
@@ -0,0 +1,317 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// defaultHTTPPath is the path the logs are posted to when the endpoint does not set one.
+	defaultHTTPPath = "/v1/input"
+	// maxIdleConnsPerHost is the number of connections kept open to an HTTP intake between the requests,
+	// enough for each pipeline to reuse its own.
+	maxIdleConnsPerHost = 16
+	// maxLoggedBodySize is the number of bytes of the body of a rejection logged.
+	maxLoggedBodySize = 1024
+)
+
+// RejectedError is returned when an HTTP intake rejects logs permanently,
+// the logs must not be sent again.
+type RejectedError struct {
+	StatusCode int
+}
+
+// Error returns the message of the error.
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("logs rejected by the intake with status code %d", e.StatusCode)
+}
+
+// TooLargeError is returned when an HTTP intake rejects a batch of several logs as too large,
+// the logs can be sent again in smaller batches.
+type TooLargeError struct {
+	Count int
+}
+
+// Error returns the message of the error.
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("batch of %d logs rejected by the intake as too large", e.Count)
+}
+
+// HTTPDestination posts the logs to an HTTP intake, authenticating with the API key of the endpoint as header.
+// The 2xx responses mean the logs were sent, the 5xx responses and the 429 responses are retried by the caller,
+// after the delay of the Retry-After header for the latter, as are the 415 responses to compressed payloads,
+// posted uncompressed from then on, and the 413 responses to batches of several logs, split by the caller.
+// The other 4xx responses reject the logs permanently.
+type HTTPDestination struct {
+	url                 string
+	apiKey              string
+	client              *http.Client
+	destinationsContext *DestinationsContext
+	backoff             *backoff
+	rejection           sync.Once
+	// maxReconnectAttempts is the number of failed requests, following a first failure,
+	// after which the destination gives up, 0 means never
+	maxReconnectAttempts int
+	// compression is the compression of the payloads, set to none once the intake does not support it
+	compression      string
+	compressionLevel int
+	// bufferFullPolicy and route apply when the destination is an additional one
+	bufferFullPolicy string
+	route            *Route
+
+	// mutex guards retryAt, lastSuccess and compression which are read concurrently
+	mutex       sync.Mutex
+	retryAt     time.Time
+	lastSuccess time.Time
+}
+
+// NewHTTPDestination returns a new HTTP destination posting the logs to endpoint,
+// the connections are pooled with the other destinations of the same endpoint.
+func NewHTTPDestination(endpoint Endpoint, destinationsContext *DestinationsContext) *HTTPDestination {
+	scheme := "http"
+	if endpoint.UseSSL {
+		scheme = "https"
+	}
+	path := endpoint.Path
+	if path == "" {
+		path = defaultHTTPPath
+	}
+	return &HTTPDestination{
+		url:                  fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.Port)), path),
+		apiKey:               endpoint.APIKey,
+		client:               &http.Client{Transport: httpTransport(endpoint), Timeout: connectionTimeout},
+		destinationsContext:  destinationsContext,
+		backoff:              newBackoff(endpoint.BackoffBase, endpoint.BackoffMax),
+		maxReconnectAttempts: endpoint.MaxReconnectAttempts,
+		compression:          endpoint.Compression,
+		compressionLevel:     endpoint.CompressionLevel,
+		bufferFullPolicy:     endpoint.BufferFullPolicy,
+		route:                endpoint.Route,
+	}
+}
+
+var (
+	httpTransportsMutex sync.Mutex
+	httpTransports      = make(map[Endpoint]*http.Transport)
+)
+
+// httpTransport returns the transport shared by the HTTP destinations of endpoint to pool their connections.
+func httpTransport(endpoint Endpoint) *http.Transport {
+	httpTransportsMutex.Lock()
+	defer httpTransportsMutex.Unlock()
+	if transport, exists := httpTransports[endpoint]; exists {
+		return transport
+	}
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: connectionTimeout,
+	}
+	if endpoint.ProxyAddress != "" {
+		dialer, err := proxy.SOCKS5("tcp", endpoint.ProxyAddress, nil, &net.Dialer{KeepAlive: endpoint.KeepAlive})
+		if err != nil {
+			log.Warnf("Could not use the socks5 proxy %v: %v", endpoint.ProxyAddress, err)
+		} else {
+			transport.Dial = dialer.Dial
+		}
+	} else {
+		dialer := &net.Dialer{Timeout: connectionTimeout, FallbackDelay: endpoint.FallbackDelay, KeepAlive: endpoint.KeepAlive}
+		transport.DialContext = dialer.DialContext
+		proxyURL, err := ProxyURL(endpoint)
+		if err != nil {
+			log.Warnf("Could not use the http proxy: %v", err)
+		} else if proxyURL != nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	if endpoint.UseSSL {
+		tlsConfig, err := LoadTLSConfig(endpoint)
+		if err != nil {
+			log.Warnf("Could not load the TLS config of %v: %v", endpoint.Host, err)
+		} else {
+			transport.TLSClientConfig = tlsConfig
+		}
+	}
+	httpTransports[endpoint] = transport
+	return transport
+}
+
+// Send posts a message to the intake, returns an error if the operation failed.
+func (d *HTTPDestination) Send(payload []byte) error {
+	return d.SendBatch([][]byte{payload})
+}
+
+// SendBatch posts the messages to the intake in a single request, one per line,
+// returns an error if the operation failed, in which case none of the messages are considered sent.
+// The request is made once the delay following the previous failures is over,
+// ErrReconnectAttemptsExhausted is returned without making it once there were too many failures.
+func (d *HTTPDestination) SendBatch(payloads [][]byte) error {
+	if d.backoff.exhausted(d.maxReconnectAttempts) {
+		d.destinationsContext.giveUp(d.Address(), ErrReconnectAttemptsExhausted)
+		return ErrReconnectAttemptsExhausted
+	}
+	ctx := d.destinationsContext.Context()
+	if err := d.waitRetryAfter(ctx); err != nil {
+		return err
+	}
+	if err := d.backoff.wait(ctx); err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	compression := d.compression
+	d.mutex.Unlock()
+	body, compression, err := compress(compression, d.compressionLevel, bytes.Join(payloads, []byte("\n")))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("DD-API-KEY", d.apiKey)
+	req.Header.Set("Content-Type", "text/plain")
+	if compression != CompressionNone {
+		req.Header.Set("Content-Encoding", compression)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		d.backoff.fail()
+		return err
+	}
+	defer func() {
+		// the body is read until its end for the connection to be reused
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		d.backoff.reset()
+		d.mutex.Lock()
+		d.lastSuccess = time.Now()
+		d.mutex.Unlock()
+		return nil
+	case resp.StatusCode == http.StatusUnsupportedMediaType && compression != CompressionNone:
+		// the intake does not support the compression, the logs are posted uncompressed from now on
+		log.Warnf("%v does not support %s compression, the logs are posted uncompressed", d.Address(), compression)
+		d.mutex.Lock()
+		d.compression = CompressionNone
+		d.mutex.Unlock()
+		return fmt.Errorf("%s compression not supported by %v", compression, d.Address())
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if retryAfter == 0 {
+			// the intake did not say when to retry, back off as for any other failure
+			d.backoff.fail()
+		}
+		d.mutex.Lock()
+		d.retryAt = time.Now().Add(retryAfter)
+		d.mutex.Unlock()
+		return fmt.Errorf("too many requests sent to %v", d.Address())
+	case resp.StatusCode == http.StatusRequestEntityTooLarge && len(payloads) > 1:
+		return &TooLargeError{Count: len(payloads)}
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		metrics.LogsRejectedByIntake.Add(int64(len(payloads)))
+		d.rejection.Do(func() {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxLoggedBodySize))
+			log.Warnf("Logs rejected by %v with status code %d, they are dropped: %s", d.Address(), resp.StatusCode, body)
+		})
+		return &RejectedError{StatusCode: resp.StatusCode}
+	default:
+		d.backoff.fail()
+		return fmt.Errorf("could not send logs to %v: %v", d.Address(), resp.Status)
+	}
+}
+
+// waitRetryAfter waits until the delay requested by the last 429 response is over or ctx is done.
+func (d *HTTPDestination) waitRetryAfter(ctx context.Context) error {
+	d.mutex.Lock()
+	delay := time.Until(d.retryAt)
+	d.mutex.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, either a number of seconds or an HTTP date,
+// 0 if it is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// Address returns the URL the logs are posted to.
+func (d *HTTPDestination) Address() string {
+	return d.url
+}
+
+// BackoffDelay returns the delay waited for before the next request,
+// 0 when the last logs were sent successfully.
+func (d *HTTPDestination) BackoffDelay() time.Duration {
+	d.mutex.Lock()
+	retryAfter := time.Until(d.retryAt)
+	d.mutex.Unlock()
+	if delay := d.backoff.currentDelay(); delay > retryAfter {
+		return delay
+	}
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	return 0
+}
+
+// LastSuccess returns the last time logs were sent successfully, the zero time if they never were.
+func (d *HTTPDestination) LastSuccess() time.Time {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.lastSuccess
+}
+
+// CircuitState returns closed as the requests to an HTTP intake are never skipped.
+func (d *HTTPDestination) CircuitState() string {
+	return CircuitClosed
+}
+
+// BufferFullPolicy returns the policy applied when the destination does not keep up with the main one.
+func (d *HTTPDestination) BufferFullPolicy() string {
+	return d.bufferFullPolicy
+}
+
+// Route returns the route of the logs sent to the destination, nil when all the logs are sent to it.
+func (d *HTTPDestination) Route() *Route {
+	return d.route
+}
@@ -0,0 +1,249 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// intake records the requests it receives and answers with the status codes of responses in turn,
+// then with 200.
+type intake struct {
+	mutex     sync.Mutex
+	responses []int
+	headers   []http.Header
+	bodies    []string
+}
+
+func (i *intake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.headers = append(i.headers, r.Header)
+	i.bodies = append(i.bodies, string(body))
+	status := http.StatusOK
+	if len(i.responses) > 0 {
+		status = i.responses[0]
+		i.responses = i.responses[1:]
+	}
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "1")
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(`{"status":"` + http.StatusText(status) + `"}`))
+}
+
+func newTestHTTPDestination(t *testing.T, server *httptest.Server) (*HTTPDestination, *DestinationsContext) {
+	u, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+	host, portString, err := net.SplitHostPort(u.Host)
+	assert.Nil(t, err)
+	port, err := strconv.Atoi(portString)
+	assert.Nil(t, err)
+	destinationsContext := NewDestinationsContext(nil)
+	destinationsContext.Start()
+	endpoint := Endpoint{
+		APIKey:      "foo",
+		Host:        host,
+		Port:        port,
+		UseHTTP:     true,
+		Path:        "/v1/input/" + t.Name(),
+		BackoffBase: time.Millisecond,
+		BackoffMax:  time.Millisecond,
+	}
+	return NewHTTPDestination(endpoint, destinationsContext), destinationsContext
+}
+
+func TestHTTPDestinationPostsTheLogsWithTheAPIKey(t *testing.T) {
+	intake := &intake{}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+
+	assert.Nil(t, destination.SendBatch([][]byte{[]byte("hello"), []byte("world")}))
+	assert.Equal(t, []string{"hello\nworld"}, intake.bodies)
+	assert.Equal(t, "foo", intake.headers[0].Get("DD-API-KEY"))
+	assert.Equal(t, server.URL+"/v1/input/"+t.Name(), destination.Address())
+	assert.False(t, destination.LastSuccess().IsZero())
+	assert.Equal(t, time.Duration(0), destination.BackoffDelay())
+}
+
+func TestHTTPDestinationRetriesOnServerErrors(t *testing.T) {
+	intake := &intake{responses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+
+	err := destination.Send([]byte("hello"))
+	assert.NotNil(t, err)
+	_, isRejected := err.(*RejectedError)
+	assert.False(t, isRejected)
+	assert.True(t, destination.LastSuccess().IsZero())
+
+	assert.Nil(t, destination.Send([]byte("hello")))
+	assert.Equal(t, []string{"hello", "hello"}, intake.bodies)
+}
+
+func TestHTTPDestinationGivesUpAfterMaxReconnectAttempts(t *testing.T) {
+	intake := &intake{responses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+	failures := make(chan error, 1)
+	destinationsContext.OnTerminalFailure(func(err error) { failures <- err })
+	destination.maxReconnectAttempts = 1
+
+	assert.NotNil(t, destination.Send([]byte("hello")))
+	assert.NotNil(t, destination.Send([]byte("hello")))
+	// the intake is not requested anymore
+	assert.Equal(t, ErrReconnectAttemptsExhausted, destination.Send([]byte("hello")))
+	assert.Equal(t, ErrReconnectAttemptsExhausted, <-failures)
+	assert.Equal(t, []string{"hello", "hello"}, intake.bodies)
+}
+
+func TestHTTPDestinationWaitsForRetryAfterOnTooManyRequests(t *testing.T) {
+	intake := &intake{responses: []int{http.StatusTooManyRequests}}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+
+	err := destination.Send([]byte("hello"))
+	assert.NotNil(t, err)
+	_, isRejected := err.(*RejectedError)
+	assert.False(t, isRejected)
+	assert.True(t, destination.BackoffDelay() > 500*time.Millisecond)
+
+	start := time.Now()
+	assert.Nil(t, destination.Send([]byte("hello")))
+	assert.True(t, time.Since(start) > 500*time.Millisecond)
+	assert.Equal(t, 2, len(intake.bodies))
+}
+
+func TestHTTPDestinationRejectsTheLogsOnClientErrors(t *testing.T) {
+	intake := &intake{responses: []int{http.StatusBadRequest, http.StatusForbidden}}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+
+	rejected := metrics.LogsRejectedByIntake.Value()
+	assert.Equal(t, &RejectedError{StatusCode: http.StatusBadRequest}, destination.SendBatch([][]byte{[]byte("hello"), []byte("world")}))
+	assert.Equal(t, &RejectedError{StatusCode: http.StatusForbidden}, destination.Send([]byte("hello")))
+	assert.Equal(t, rejected+3, metrics.LogsRejectedByIntake.Value())
+	// a rejection is not a failure of the intake
+	assert.Equal(t, time.Duration(0), destination.BackoffDelay())
+}
+
+func TestHTTPDestinationRejectsTheBatchesTooLarge(t *testing.T) {
+	intake := &intake{responses: []int{http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge}}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+
+	rejected := metrics.LogsRejectedByIntake.Value()
+	// the batches of several logs can be split
+	assert.Equal(t, &TooLargeError{Count: 2}, destination.SendBatch([][]byte{[]byte("hello"), []byte("world")}))
+	assert.Equal(t, rejected, metrics.LogsRejectedByIntake.Value())
+	// a single log is rejected
+	assert.Equal(t, &RejectedError{StatusCode: http.StatusRequestEntityTooLarge}, destination.Send([]byte("hello")))
+	assert.Equal(t, rejected+1, metrics.LogsRejectedByIntake.Value())
+}
+
+func TestHTTPDestinationReusesTheConnections(t *testing.T) {
+	var mutex sync.Mutex
+	connections := 0
+	server := httptest.NewUnstartedServer(&intake{responses: []int{http.StatusInternalServerError, http.StatusBadRequest}})
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			connections++
+			mutex.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+
+	for i := 0; i < 5; i++ {
+		destination.Send([]byte("hello"))
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 1, connections)
+}
+
+func TestHTTPDestinationCompressesThePayloads(t *testing.T) {
+	intake := &intake{}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+	destination.compression = CompressionGzip
+
+	hello, world := bytes.Repeat([]byte("hello "), 100), bytes.Repeat([]byte("world "), 100)
+	assert.Nil(t, destination.SendBatch([][]byte{hello, world}))
+	assert.Equal(t, "gzip", intake.headers[0].Get("Content-Encoding"))
+	reader, err := gzip.NewReader(bytes.NewReader([]byte(intake.bodies[0])))
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, string(hello)+"\n"+string(world), string(body))
+
+	// the payloads larger once compressed are posted uncompressed
+	assert.Nil(t, destination.Send([]byte("hello")))
+	assert.Equal(t, "", intake.headers[1].Get("Content-Encoding"))
+	assert.Equal(t, "hello", intake.bodies[1])
+}
+
+func TestHTTPDestinationPostsUncompressedWhenTheCompressionIsNotSupported(t *testing.T) {
+	intake := &intake{responses: []int{http.StatusUnsupportedMediaType}}
+	server := httptest.NewServer(intake)
+	defer server.Close()
+	destination, destinationsContext := newTestHTTPDestination(t, server)
+	defer destinationsContext.Stop()
+	destination.compression = CompressionGzip
+
+	payload := bytes.Repeat([]byte("hello "), 100)
+	err := destination.Send(payload)
+	assert.NotNil(t, err)
+	_, isRejected := err.(*RejectedError)
+	assert.False(t, isRejected)
+	assert.Equal(t, time.Duration(0), destination.BackoffDelay())
+
+	assert.Nil(t, destination.Send(payload))
+	assert.Equal(t, "", intake.headers[1].Get("Content-Encoding"))
+	assert.Equal(t, string(payload), intake.bodies[1])
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, 2*time.Minute, parseRetryAfter("Fri, 01 Jun 2018 12:02:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Fri, 01 Jun 2018 11:58:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
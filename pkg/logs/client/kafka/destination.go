@@ -0,0 +1,270 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// defaultTopic is the topic of the logs when neither their source nor the endpoint set one.
+const defaultTopic = "logs"
+
+var (
+	backoffUnit = 2 * time.Second
+	backoffMax  = 30 * time.Second
+)
+
+// producer is the part of a Kafka producer used to publish logs.
+type producer interface {
+	SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error)
+	Close() error
+}
+
+// dialer returns a producer publishing to the brokers of endpoint.
+type dialer func(endpoint client.KafkaEndpoint) (producer, error)
+
+// Destination publishes logs to a Kafka topic, each publication is acknowledged
+// by all the in-sync replicas so that the offsets are only committed for the logs they received.
+// The topic and the partition key of the logs are the ones of their source when it sets them.
+type Destination struct {
+	endpoint            client.KafkaEndpoint
+	destinationsContext *client.DestinationsContext
+	dial                dialer
+	producer            producer
+	// failures is the number of publications in a row which failed, the next one is delayed accordingly.
+	failures int
+
+	// mutex guards lastSuccess which is read concurrently
+	mutex       sync.Mutex
+	lastSuccess time.Time
+}
+
+// NewDestination returns a new destination.
+func NewDestination(endpoint client.KafkaEndpoint, destinationsContext *client.DestinationsContext) *Destination {
+	return &Destination{
+		endpoint:            endpoint,
+		destinationsContext: destinationsContext,
+		dial:                dial,
+	}
+}
+
+// Send publishes the content of msg to the topic of its source with its partition key
+// and blocks until the brokers acknowledge it, returns an error if the operation failed,
+// the publication following a failure is delayed with a backoff unless the brokers refused the message.
+func (d *Destination) Send(msg *message.Message) error {
+	ctx := d.destinationsContext.Context()
+	if d.failures > 0 {
+		log.Debugf("Publish attempt #%d", d.failures)
+		backoff(ctx, d.failures)
+		if ctx.Err() != nil {
+			// the agent is stopping
+			return ctx.Err()
+		}
+	}
+	topic, key := d.topicAndKey(msg)
+	err := d.publish(topic, key, msg.Content)
+	if _, refused := err.(*client.RefusedError); err == nil || refused {
+		d.failures = 0
+	} else {
+		d.failures++
+	}
+	return err
+}
+
+// topicAndKey returns the topic and the partition key of the source of msg,
+// the topic of the endpoint when its source does not set one.
+func (d *Destination) topicAndKey(msg *message.Message) (string, string) {
+	topic, key := d.endpoint.Topic, ""
+	if msg.Origin != nil && msg.Origin.LogSource != nil {
+		if msg.Origin.LogSource.Config.KafkaTopic != "" {
+			topic = msg.Origin.LogSource.Config.KafkaTopic
+		}
+		key = msg.Origin.LogSource.Config.KafkaKey
+	}
+	return topic, key
+}
+
+// publish publishes content to topic with key, to the default topic when topic is empty
+// and to any partition when key is empty, a refused error is returned if the brokers can never accept it.
+func (d *Destination) publish(topic, key string, content []byte) error {
+	ctx := d.destinationsContext.Context()
+	if d.producer == nil {
+		if err := d.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if topic == "" {
+		topic = defaultTopic
+	}
+	publication := &sarama.ProducerMessage{
+		Topic:     topic,
+		Value:     sarama.ByteEncoder(content),
+		Timestamp: time.Now().UTC(),
+	}
+	if key != "" {
+		publication.Key = sarama.StringEncoder(key)
+	}
+	if _, _, err := d.producer.SendMessage(publication); err != nil {
+		if isRefused(err) {
+			return client.NewRefusedError(err)
+		}
+		if err == sarama.ErrClosedClient || err == sarama.ErrOutOfBrokers {
+			// the producer can not be used anymore, a new one is dialed for the next publication
+			d.close()
+		}
+		return err
+	}
+	d.mutex.Lock()
+	d.lastSuccess = time.Now()
+	d.mutex.Unlock()
+	return nil
+}
+
+// isRefused returns true if the brokers refused a publication permanently,
+// the message being too large or invalid, or the topic invalid.
+func isRefused(err error) bool {
+	switch err {
+	case sarama.ErrMessageSizeTooLarge, sarama.ErrInvalidMessageSize, sarama.ErrInvalidMessage, sarama.ErrInvalidTopic:
+		return true
+	default:
+		return false
+	}
+}
+
+// connect dials a new producer, it blocks until the producer is ready or ctx is done.
+func (d *Destination) connect(ctx context.Context) error {
+	var retries int
+	for {
+		if retries > 0 {
+			log.Debugf("Connect attempt #%d", retries)
+			backoff(ctx, retries)
+		}
+		retries++
+
+		select {
+		case <-ctx.Done():
+			// the agent is stopping
+			return ctx.Err()
+		default:
+		}
+
+		producer, err := d.dial(d.endpoint)
+		if err != nil {
+			log.Warnf("Could not connect to the Kafka brokers %v: %v", d.Address(), err)
+			continue
+		}
+		d.producer = producer
+		return nil
+	}
+}
+
+// close closes the producer, a new one is dialed for the next publication.
+func (d *Destination) close() {
+	d.producer.Close()
+	d.producer = nil
+}
+
+// Address returns the brokers and the default topic the logs are published to.
+func (d *Destination) Address() string {
+	topic := d.endpoint.Topic
+	if topic == "" {
+		topic = defaultTopic
+	}
+	return fmt.Sprintf("kafka://%s/%s", strings.Join(d.endpoint.Brokers, ","), topic)
+}
+
+// LastSuccess returns when logs were last published, the zero time if never.
+func (d *Destination) LastSuccess() time.Time {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.lastSuccess
+}
+
+// backoff blocks until the backoff duration of the connection or publication attempt is elapsed or ctx is done.
+func backoff(ctx context.Context, retries int) {
+	backoffDuration := backoffUnit * time.Duration(retries)
+	if backoffDuration > backoffMax {
+		backoffDuration = backoffMax
+	}
+	ctx, cancel := context.WithTimeout(ctx, backoffDuration)
+	defer cancel()
+	<-ctx.Done()
+}
+
+// dial returns a producer waiting for all the in-sync replicas to acknowledge each publication.
+func dial(endpoint client.KafkaEndpoint) (producer, error) {
+	config := sarama.NewConfig()
+	config.ClientID = "datadog-agent"
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	return sarama.NewSyncProducer(endpoint.Brokers, config)
+}
+
+// AdditionalDestination publishes the logs sent to an additional endpoint to a Kafka topic,
+// with a try and forget strategy as the other additional destinations.
+type AdditionalDestination struct {
+	destination      *Destination
+	bufferFullPolicy string
+	route            *client.Route
+}
+
+// NewAdditionalDestination returns a new destination publishing the logs sent to endpoint
+// to its Kafka topic, endpoint.Kafka must be set.
+func NewAdditionalDestination(endpoint client.Endpoint, destinationsContext *client.DestinationsContext) *AdditionalDestination {
+	return &AdditionalDestination{
+		destination:      NewDestination(*endpoint.Kafka, destinationsContext),
+		bufferFullPolicy: endpoint.BufferFullPolicy,
+		route:            endpoint.Route,
+	}
+}
+
+// Send publishes payload to the topic of the endpoint, returns an error if the operation failed.
+func (d *AdditionalDestination) Send(payload []byte) error {
+	return d.destination.publish(d.destination.endpoint.Topic, "", payload)
+}
+
+// SendMessage publishes the content of msg to the topic of its source with its partition key,
+// returns an error if the operation failed.
+func (d *AdditionalDestination) SendMessage(msg *message.Message) error {
+	topic, key := d.destination.topicAndKey(msg)
+	return d.destination.publish(topic, key, msg.Content)
+}
+
+// Address returns the brokers and the default topic the logs are published to.
+func (d *AdditionalDestination) Address() string {
+	return d.destination.Address()
+}
+
+// LastSuccess returns when logs were last published, the zero time if never.
+func (d *AdditionalDestination) LastSuccess() time.Time {
+	return d.destination.LastSuccess()
+}
+
+// CircuitState returns closed as the publications are never skipped.
+func (d *AdditionalDestination) CircuitState() string {
+	return client.CircuitClosed
+}
+
+// BufferFullPolicy returns the policy applied when the destination does not keep up with the main one.
+func (d *AdditionalDestination) BufferFullPolicy() string {
+	return d.bufferFullPolicy
+}
+
+// Route returns the route of the logs sent to the destination, nil when all the logs are sent to it.
+func (d *AdditionalDestination) Route() *client.Route {
+	return d.route
+}
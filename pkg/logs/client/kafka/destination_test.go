@@ -0,0 +1,210 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// stubProducer records the publications and fails them with the errors in order.
+type stubProducer struct {
+	publications []*sarama.ProducerMessage
+	errors       []error
+	closed       bool
+}
+
+func (p *stubProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.publications = append(p.publications, msg)
+	if len(p.errors) > 0 {
+		err := p.errors[0]
+		p.errors = p.errors[1:]
+		return -1, -1, err
+	}
+	return 0, int64(len(p.publications)), nil
+}
+
+func (p *stubProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func newTestDestination(endpoint client.KafkaEndpoint, dial dialer) (*Destination, *client.DestinationsContext) {
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destinationsCtx.Start()
+	destination := NewDestination(endpoint, destinationsCtx)
+	destination.dial = dial
+	return destination, destinationsCtx
+}
+
+func newMessage(content []byte, source *config.LogSource) *message.Message {
+	return message.NewMessage(content, message.NewOrigin(source), "")
+}
+
+func encoded(t *testing.T, encoder sarama.Encoder) string {
+	if encoder == nil {
+		return ""
+	}
+	bytes, err := encoder.Encode()
+	assert.Nil(t, err)
+	return string(bytes)
+}
+
+func TestDestinationPublishesToTopic(t *testing.T) {
+	stub := &stubProducer{}
+	dial := func(endpoint client.KafkaEndpoint) (producer, error) {
+		return stub, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.KafkaEndpoint{Brokers: []string{"kafka:9092"}, Topic: "logs"}, dial)
+	defer destinationsCtx.Stop()
+
+	assert.True(t, destination.LastSuccess().IsZero())
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), config.NewLogSource("", &config.LogsConfig{}))))
+	assert.Nil(t, destination.Send(newMessage([]byte("bar"), config.NewLogSource("", &config.LogsConfig{KafkaTopic: "payments", KafkaKey: "api"}))))
+
+	assert.Equal(t, 2, len(stub.publications))
+	assert.Equal(t, "logs", stub.publications[0].Topic)
+	assert.Equal(t, "", encoded(t, stub.publications[0].Key))
+	assert.Equal(t, "foo", encoded(t, stub.publications[0].Value))
+	assert.Equal(t, "payments", stub.publications[1].Topic)
+	assert.Equal(t, "api", encoded(t, stub.publications[1].Key))
+	assert.Equal(t, "bar", encoded(t, stub.publications[1].Value))
+	assert.False(t, destination.LastSuccess().IsZero())
+	assert.Equal(t, "kafka://kafka:9092/logs", destination.Address())
+}
+
+func TestDestinationRedialsWhenTheBrokersAreGone(t *testing.T) {
+	defaultBackoffUnit := backoffUnit
+	backoffUnit = time.Millisecond
+	defer func() { backoffUnit = defaultBackoffUnit }()
+
+	stub := &stubProducer{errors: []error{sarama.ErrNotLeaderForPartition, sarama.ErrOutOfBrokers}}
+	var dials int
+	dial := func(endpoint client.KafkaEndpoint) (producer, error) {
+		dials++
+		return stub, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.KafkaEndpoint{}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	// the producer recovers from the errors of a partition on its own
+	assert.Equal(t, sarama.ErrNotLeaderForPartition, destination.Send(newMessage([]byte("foo"), source)))
+	assert.False(t, stub.closed)
+	assert.Equal(t, sarama.ErrOutOfBrokers, destination.Send(newMessage([]byte("foo"), source)))
+	assert.True(t, stub.closed)
+
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), source)))
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, defaultTopic, stub.publications[2].Topic)
+}
+
+func TestDestinationBacksOffAfterFailures(t *testing.T) {
+	defaultBackoffUnit := backoffUnit
+	backoffUnit = 20 * time.Millisecond
+	defer func() { backoffUnit = defaultBackoffUnit }()
+
+	stub := &stubProducer{errors: []error{sarama.ErrNotLeaderForPartition, sarama.ErrNotLeaderForPartition}}
+	dial := func(endpoint client.KafkaEndpoint) (producer, error) {
+		return stub, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.KafkaEndpoint{}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	start := time.Now()
+	assert.Equal(t, sarama.ErrNotLeaderForPartition, destination.Send(newMessage([]byte("foo"), source)))
+	assert.Equal(t, sarama.ErrNotLeaderForPartition, destination.Send(newMessage([]byte("foo"), source)))
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), source)))
+	// the second and third publications waited for one and two backoff units
+	assert.True(t, time.Since(start) >= 3*backoffUnit)
+	assert.Equal(t, 0, destination.failures)
+}
+
+func TestDestinationReturnsRefusedErrorWithoutBackoff(t *testing.T) {
+	stub := &stubProducer{errors: []error{sarama.ErrMessageSizeTooLarge, sarama.ErrInvalidTopic}}
+	dial := func(endpoint client.KafkaEndpoint) (producer, error) {
+		return stub, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.KafkaEndpoint{}, dial)
+	defer destinationsCtx.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	for i := 0; i < 2; i++ {
+		err := destination.Send(newMessage([]byte("foo"), source))
+		assert.IsType(t, &client.RefusedError{}, err)
+		assert.Equal(t, 0, destination.failures)
+	}
+	assert.False(t, stub.closed)
+}
+
+func TestDestinationRetriesToConnectWithBackoff(t *testing.T) {
+	defaultBackoffUnit := backoffUnit
+	backoffUnit = time.Millisecond
+	defer func() { backoffUnit = defaultBackoffUnit }()
+
+	stub := &stubProducer{}
+	var dials int
+	dial := func(endpoint client.KafkaEndpoint) (producer, error) {
+		dials++
+		if dials < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return stub, nil
+	}
+	destination, destinationsCtx := newTestDestination(client.KafkaEndpoint{}, dial)
+	defer destinationsCtx.Stop()
+
+	assert.Nil(t, destination.Send(newMessage([]byte("foo"), config.NewLogSource("", &config.LogsConfig{}))))
+	assert.Equal(t, 3, dials)
+}
+
+func TestDestinationStopsConnectingWhenContextIsDone(t *testing.T) {
+	dial := func(endpoint client.KafkaEndpoint) (producer, error) {
+		return nil, errors.New("connection refused")
+	}
+	destination, destinationsCtx := newTestDestination(client.KafkaEndpoint{}, dial)
+	destinationsCtx.Stop()
+
+	assert.Equal(t, context.Canceled, destination.Send(newMessage([]byte("foo"), config.NewLogSource("", &config.LogsConfig{}))))
+}
+
+func TestAdditionalDestination(t *testing.T) {
+	stub := &stubProducer{}
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destinationsCtx.Start()
+	defer destinationsCtx.Stop()
+	endpoint := client.Endpoint{
+		Kafka:            &client.KafkaEndpoint{Brokers: []string{"kafka:9092"}, Topic: "logs"},
+		BufferFullPolicy: "block",
+		Route:            &client.Route{Statuses: []string{"error"}},
+	}
+	destination := NewAdditionalDestination(endpoint, destinationsCtx)
+	destination.destination.dial = func(endpoint client.KafkaEndpoint) (producer, error) {
+		return stub, nil
+	}
+
+	var additional client.AdditionalDestination = destination
+	assert.Equal(t, "block", additional.BufferFullPolicy())
+	assert.Equal(t, endpoint.Route, additional.Route())
+	assert.Equal(t, client.CircuitClosed, additional.CircuitState())
+	assert.Equal(t, "kafka://kafka:9092/logs", additional.Address())
+
+	assert.Nil(t, additional.Send([]byte("foo")))
+	assert.Nil(t, destination.SendMessage(newMessage([]byte("bar"), config.NewLogSource("", &config.LogsConfig{KafkaTopic: "payments"}))))
+	assert.Equal(t, "logs", stub.publications[0].Topic)
+	assert.Equal(t, "payments", stub.publications[1].Topic)
+	assert.False(t, additional.LastSuccess().IsZero())
+}
@@ -33,3 +33,12 @@ func NewAPIKeyPrefixer(apikey, logset string) Prefixer {
 func (p *apiKeyPrefixer) prefix(content []byte) []byte {
 	return append(p.key, content...)
 }
+
+// noopPrefixer leaves the messages untouched, it is used for endpoints that are not Datadog intakes.
+type noopPrefixer struct {
+	Prefixer
+}
+
+func (p *noopPrefixer) prefix(content []byte) []byte {
+	return content
+}
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ProxyURL returns the URL of the HTTP proxy of endpoint, nil when it does not use one,
+// an error is returned if it is not an http or https URL.
+func ProxyURL(endpoint Endpoint) (*url.URL, error) {
+	if endpoint.HTTPProxy == "" {
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(endpoint.HTTPProxy)
+	if err != nil {
+		// the error is not wrapped as it holds the credentials of the proxy
+		return nil, fmt.Errorf("could not parse the http proxy of %v", endpoint.Host)
+	}
+	if (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+		return nil, fmt.Errorf("the http proxy of %v must be an http or https URL", endpoint.Host)
+	}
+	return proxyURL, nil
+}
+
+// dialHTTPProxy returns a connection to address tunneled through the HTTP proxy at proxyURL with the CONNECT method,
+// authenticated with the credentials of proxyURL when set, the connection to the proxy is encrypted with an https URL.
+func dialHTTPProxy(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, address string) (net.Conn, error) {
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// the buffered reader can be dropped as nothing is received through the tunnel before the agent sends the logs
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("the http proxy %v refused to connect to %v: %v", proxyURL.Host, address, response.Status)
+	}
+	return conn, nil
+}
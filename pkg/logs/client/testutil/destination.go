@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// Package testutil provides helpers to test the pipelines without any remote server,
+// they must not be used in production code.
+package testutil
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+)
+
+// ErrSendFailed is returned by the sends failing on purpose when no other error was set.
+var ErrSendFailed = errors.New("send failed on purpose")
+
+// Destination is a main destination keeping the payloads sent in memory,
+// it can fail a number of sends and delay each send to simulate a remote server.
+type Destination struct {
+	mu          sync.Mutex
+	payloads    [][]byte
+	attempts    int
+	failures    int
+	err         error
+	latency     time.Duration
+	lastSuccess time.Time
+	// sent is closed and replaced each time payloads are sent
+	sent chan struct{}
+}
+
+var _ client.MainDestination = &Destination{}
+
+// NewDestination returns a new destination accepting all the payloads without delay.
+func NewDestination() *Destination {
+	return &Destination{
+		sent: make(chan struct{}),
+	}
+}
+
+// FailNextSends makes the next n sends fail with err, or with ErrSendFailed if err is nil,
+// a framing error makes the sender drop the payloads instead of sending them again.
+func (d *Destination) FailNextSends(n int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		err = ErrSendFailed
+	}
+	d.failures = n
+	d.err = err
+}
+
+// SetLatency delays each send by latency, failing or not.
+func (d *Destination) SetLatency(latency time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latency = latency
+}
+
+// Send keeps payload in memory unless the send must fail.
+func (d *Destination) Send(payload []byte) error {
+	return d.SendBatch([][]byte{payload})
+}
+
+// SendBatch keeps payloads in memory unless the send must fail,
+// in which case none of the payloads are kept.
+func (d *Destination) SendBatch(payloads [][]byte) error {
+	d.mu.Lock()
+	latency := d.latency
+	d.mu.Unlock()
+	time.Sleep(latency)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.attempts++
+	if d.failures > 0 {
+		d.failures--
+		return d.err
+	}
+	for _, payload := range payloads {
+		// the sender may reuse the buffers of the payloads
+		d.payloads = append(d.payloads, append([]byte(nil), payload...))
+	}
+	d.lastSuccess = time.Now()
+	close(d.sent)
+	d.sent = make(chan struct{})
+	return nil
+}
+
+// Address returns a name identifying the destination in the logs and the status.
+func (d *Destination) Address() string {
+	return "testutil"
+}
+
+// BackoffDelay returns 0 as the sends are never delayed after a failure.
+func (d *Destination) BackoffDelay() time.Duration {
+	return 0
+}
+
+// LastSuccess returns the time of the last successful send, the zero time if none succeeded yet.
+func (d *Destination) LastSuccess() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastSuccess
+}
+
+// Attempts returns the number of sends so far, failed or not.
+func (d *Destination) Attempts() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.attempts
+}
+
+// Payloads returns the payloads sent so far, in the order they were sent.
+func (d *Destination) Payloads() [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([][]byte(nil), d.payloads...)
+}
+
+// WaitForPayloads returns the payloads sent once there are at least n of them,
+// returns an error if they are not sent before timeout.
+func (d *Destination) WaitForPayloads(n int, timeout time.Duration) ([][]byte, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		d.mu.Lock()
+		payloads, sent := d.payloads, d.sent
+		d.mu.Unlock()
+		if len(payloads) >= n {
+			return append([][]byte(nil), payloads...), nil
+		}
+		select {
+		case <-sent:
+		case <-timer.C:
+			return nil, fmt.Errorf("%d payloads sent out of %d after %v", len(payloads), n, timeout)
+		}
+	}
+}
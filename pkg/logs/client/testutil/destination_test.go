@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+)
+
+func TestDestinationKeepsThePayloadsInOrder(t *testing.T) {
+	destination := NewDestination()
+	assert.True(t, destination.LastSuccess().IsZero())
+
+	assert.Nil(t, destination.Send([]byte("foo")))
+	assert.Nil(t, destination.SendBatch([][]byte{[]byte("bar"), []byte("baz")}))
+
+	assert.Equal(t, [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}, destination.Payloads())
+	assert.Equal(t, 2, destination.Attempts())
+	assert.False(t, destination.LastSuccess().IsZero())
+}
+
+func TestDestinationFailsTheNextSends(t *testing.T) {
+	destination := NewDestination()
+	destination.FailNextSends(2, nil)
+	assert.Equal(t, ErrSendFailed, destination.Send([]byte("foo")))
+	assert.Equal(t, ErrSendFailed, destination.SendBatch([][]byte{[]byte("foo"), []byte("bar")}))
+	assert.Nil(t, destination.Send([]byte("foo")))
+	assert.Equal(t, [][]byte{[]byte("foo")}, destination.Payloads())
+	assert.Equal(t, 3, destination.Attempts())
+
+	framingError := client.NewFramingError(ErrSendFailed)
+	destination.FailNextSends(1, framingError)
+	assert.Equal(t, framingError, destination.Send([]byte("bar")))
+}
+
+func TestDestinationDelaysTheSends(t *testing.T) {
+	destination := NewDestination()
+	destination.SetLatency(20 * time.Millisecond)
+	start := time.Now()
+	assert.Nil(t, destination.Send([]byte("foo")))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
+
+func TestDestinationWaitsForThePayloads(t *testing.T) {
+	destination := NewDestination()
+	go func() {
+		for _, content := range []string{"foo", "bar"} {
+			destination.Send([]byte(content))
+		}
+	}()
+	payloads, err := destination.WaitForPayloads(2, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("foo"), []byte("bar")}, payloads)
+
+	_, err = destination.WaitForPayloads(3, 10*time.Millisecond)
+	assert.NotNil(t, err)
+}
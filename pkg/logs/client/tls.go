@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// LoadTLSConfig returns the TLS config to connect to endpoint,
+// with the client certificate and the certificate authorities of the endpoint when set.
+// The files are read on each call so that the certificates renewed are used from the next connection,
+// an error is returned if one of them can not be loaded.
+func LoadTLSConfig(endpoint Endpoint) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: endpoint.Host,
+	}
+	if endpoint.TLSCertPath != "" || endpoint.TLSKeyPath != "" {
+		if endpoint.TLSCertPath == "" || endpoint.TLSKeyPath == "" {
+			return nil, fmt.Errorf("both the TLS certificate and key must be set for %v", endpoint.Host)
+		}
+		cert, err := LoadKeyPair(endpoint.TLSCertPath, endpoint.TLSKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if endpoint.TLSCAPath != "" {
+		pool, err := LoadCertPool(endpoint.TLSCAPath)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// LoadKeyPair returns the TLS certificate at certPath along with its key at keyPath,
+// an error is returned if they can not be loaded.
+func LoadKeyPair(certPath, keyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not load the TLS certificate %v: %v", certPath, err)
+	}
+	return cert, nil
+}
+
+// LoadCertPool returns the pool of the certificate authorities of the PEM file at path,
+// an error is returned if it can not be read or holds no valid certificate.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the TLS certificate authorities %v: %v", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificate found in %v", path)
+	}
+	return pool, nil
+}
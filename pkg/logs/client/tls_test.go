@@ -0,0 +1,152 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfSignedCertificate writes a certificate for 127.0.0.1 signed by itself and its key in dir,
+// the certificate is both its own authority and valid for the server and the client.
+func writeSelfSignedCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	assert.Nil(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certPath, keyPath
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs-tls-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeSelfSignedCertificate(t, dir)
+
+	config, err := LoadTLSConfig(Endpoint{Host: "foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", config.ServerName)
+	assert.Empty(t, config.Certificates)
+	assert.Nil(t, config.RootCAs)
+
+	config, err = LoadTLSConfig(Endpoint{Host: "foo", TLSCertPath: certPath, TLSKeyPath: keyPath, TLSCAPath: certPath})
+	assert.Nil(t, err)
+	assert.Len(t, config.Certificates, 1)
+	assert.NotNil(t, config.RootCAs)
+
+	invalidEndpoints := []Endpoint{
+		{Host: "foo", TLSCertPath: certPath},
+		{Host: "foo", TLSKeyPath: keyPath},
+		{Host: "foo", TLSCertPath: certPath, TLSKeyPath: filepath.Join(dir, "missing.pem")},
+		{Host: "foo", TLSCertPath: keyPath, TLSKeyPath: certPath},
+		{Host: "foo", TLSCAPath: filepath.Join(dir, "missing.pem")},
+		{Host: "foo", TLSCAPath: keyPath},
+	}
+	for _, endpoint := range invalidEndpoints {
+		_, err = LoadTLSConfig(endpoint)
+		assert.NotNil(t, err, endpoint)
+	}
+}
+
+func TestLoadKeyPairAndCertPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs-tls-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeSelfSignedCertificate(t, dir)
+
+	cert, err := LoadKeyPair(certPath, keyPath)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, cert.Certificate)
+	_, err = LoadKeyPair(keyPath, certPath)
+	assert.NotNil(t, err)
+
+	pool, err := LoadCertPool(certPath)
+	assert.Nil(t, err)
+	assert.NotNil(t, pool)
+	_, err = LoadCertPool(keyPath)
+	assert.NotNil(t, err)
+	_, err = LoadCertPool(filepath.Join(dir, "missing.pem"))
+	assert.NotNil(t, err)
+}
+
+func TestNewConnectionWithClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs-tls-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeSelfSignedCertificate(t, dir)
+
+	// the intake only accepts the clients presenting a certificate it trusts
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	assert.Nil(t, err)
+	serverConfig, err := LoadTLSConfig(Endpoint{TLSCAPath: certPath})
+	assert.Nil(t, err)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    serverConfig.RootCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	assert.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go conn.(*tls.Conn).Handshake()
+		}
+	}()
+
+	host, port := AddrToHostPort(l.Addr())
+	endpoint := Endpoint{Host: host, Port: port, UseSSL: true, TLSCertPath: certPath, TLSKeyPath: keyPath, TLSCAPath: certPath}
+	conn, err := NewConnectionManager(endpoint, nil).connect(context.Background())
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+	conn.Close()
+
+	// the connection is refused without certificate
+	endpoint = Endpoint{Host: host, Port: port, UseSSL: true, TLSCAPath: certPath}
+	conn, err = NewConnectionManager(endpoint, nil).connect(context.Background())
+	if err == nil {
+		// the handshake completes on the client side before the server checks the certificate
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.NotNil(t, err)
+}
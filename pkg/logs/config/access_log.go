@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Access log fields, used as names of the groups of the access log regular expressions.
+const (
+	AccessLogClientIP     = "client_ip"
+	AccessLogIdent        = "ident"
+	AccessLogAuth         = "auth"
+	AccessLogDate         = "date_access"
+	AccessLogRequest      = "request"
+	AccessLogStatusCode   = "status_code"
+	AccessLogBytesWritten = "bytes_written"
+	AccessLogReferer      = "referer"
+	AccessLogUserAgent    = "useragent"
+)
+
+// AccessLogFormats maps the predefined formats to their Apache or Nginx log format.
+var AccessLogFormats = map[string]string{
+	"common":   `%h %l %u %t "%r" %>s %b`,
+	"combined": `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`,
+	"nginx":    `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`,
+}
+
+// apacheDirectives maps the Apache format directives to the fields they hold.
+var apacheDirectives = map[string]string{
+	"%h":             AccessLogClientIP,
+	"%a":             AccessLogClientIP,
+	"%l":             AccessLogIdent,
+	"%u":             AccessLogAuth,
+	"%t":             AccessLogDate,
+	"%r":             AccessLogRequest,
+	"%s":             AccessLogStatusCode,
+	"%>s":            AccessLogStatusCode,
+	"%b":             AccessLogBytesWritten,
+	"%B":             AccessLogBytesWritten,
+	"%{Referer}i":    AccessLogReferer,
+	"%{User-agent}i": AccessLogUserAgent,
+	"%{User-Agent}i": AccessLogUserAgent,
+}
+
+// nginxVariables maps the Nginx format variables to the fields they hold.
+var nginxVariables = map[string]string{
+	"$remote_addr":     AccessLogClientIP,
+	"$remote_user":     AccessLogAuth,
+	"$time_local":      AccessLogDate,
+	"$request":         AccessLogRequest,
+	"$status":          AccessLogStatusCode,
+	"$body_bytes_sent": AccessLogBytesWritten,
+	"$http_referer":    AccessLogReferer,
+	"$http_user_agent": AccessLogUserAgent,
+}
+
+// accessLogTokens matches the Apache directives, with their optional modifiers and arguments,
+// and the Nginx variables of a log format.
+var accessLogTokens = regexp.MustCompile(`%[<>]?(\{[^}]*\})?[a-zA-Z]|\$[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// compileAccessLogRule returns the regular expression matching the lines written in the log format of rule.
+func compileAccessLogRule(rule ProcessingRule) (*regexp.Regexp, error) {
+	format := rule.LogFormat
+	if format == "" {
+		var exists bool
+		format, exists = AccessLogFormats[rule.Format]
+		if !exists {
+			return nil, fmt.Errorf("format %q is not supported, use a predefined format or a log_format", rule.Format)
+		}
+	}
+	return compileAccessLogFormat(format)
+}
+
+// compileAccessLogFormat turns an Apache or Nginx log format into a regular expression
+// whose named groups capture the known fields, the other directives or variables are matched but not captured.
+func compileAccessLogFormat(format string) (*regexp.Regexp, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	var offset int
+	var hasFields bool
+	for _, loc := range accessLogTokens.FindAllStringIndex(format, -1) {
+		literal := format[offset:loc[0]]
+		token := format[loc[0]:loc[1]]
+		offset = loc[1]
+		pattern.WriteString(regexp.QuoteMeta(literal))
+
+		// quoted values can contain spaces
+		value := `\S+`
+		if strings.HasSuffix(literal, `"`) {
+			value = `[^"]*`
+		}
+
+		field, isApache := apacheDirectives[token]
+		if !isApache {
+			field = nginxVariables[token]
+		}
+		switch {
+		case token == "%t":
+			// the Apache time directive writes the brackets
+			pattern.WriteString(`\[(?P<` + AccessLogDate + `>[^\]]*)\]`)
+		case field == AccessLogDate:
+			pattern.WriteString(`(?P<` + field + `>[^\]]*)`)
+		case field != "":
+			pattern.WriteString(`(?P<` + field + `>` + value + `)`)
+		default:
+			pattern.WriteString(value)
+			continue
+		}
+		hasFields = true
+	}
+	pattern.WriteString(regexp.QuoteMeta(format[offset:]))
+	pattern.WriteString("$")
+
+	if !hasFields {
+		return nil, fmt.Errorf("log format %q does not contain any known field", format)
+	}
+	return regexp.Compile(pattern.String())
+}
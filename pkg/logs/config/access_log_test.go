@@ -0,0 +1,42 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileAccessLogRuleWithPredefinedFormats(t *testing.T) {
+	for format := range AccessLogFormats {
+		reg, err := compileAccessLogRule(ProcessingRule{Type: ParseAccessLog, Format: format})
+		assert.Nil(t, err)
+		assert.NotNil(t, reg)
+	}
+
+	_, err := compileAccessLogRule(ProcessingRule{Type: ParseAccessLog, Format: "iis"})
+	assert.NotNil(t, err)
+	_, err = compileAccessLogRule(ProcessingRule{Type: ParseAccessLog})
+	assert.NotNil(t, err)
+}
+
+func TestCompileAccessLogFormat(t *testing.T) {
+	reg, err := compileAccessLogFormat(AccessLogFormats["common"])
+	assert.Nil(t, err)
+	assert.Equal(t, `^(?P<client_ip>\S+) (?P<ident>\S+) (?P<auth>\S+) \[(?P<date_access>[^\]]*)\] "(?P<request>[^"]*)" (?P<status_code>\S+) (?P<bytes_written>\S+)$`, reg.String())
+
+	reg, err = compileAccessLogFormat(`$remote_addr [$time_local] "$request" $status $request_time`)
+	assert.Nil(t, err)
+	assert.Equal(t, `^(?P<client_ip>\S+) \[(?P<date_access>[^\]]*)\] "(?P<request>[^"]*)" (?P<status_code>\S+) \S+$`, reg.String())
+}
+
+func TestCompileAccessLogFormatFailsWithoutKnownFields(t *testing.T) {
+	_, err := compileAccessLogFormat(`$request_time $upstream_addr`)
+	assert.NotNil(t, err)
+	_, err = compileAccessLogFormat(`plain text`)
+	assert.NotNil(t, err)
+}
@@ -8,7 +8,9 @@ package config
 import (
 	"fmt"
 	"net"
+	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -54,13 +56,56 @@ func BuildEndpoints() (*client.Endpoints, error) {
 
 	var useSSL bool
 	useProto := LogsAgent.GetBool("logs_config.dev_mode_use_proto")
+	useCEF := LogsAgent.GetBool("logs_config.use_cef")
+	useJSON := LogsAgent.GetBool("logs_config.use_json") && !useCEF
+	jsonRawMessage := LogsAgent.GetBool("logs_config.json_raw_message")
+	if useCEF || useJSON {
+		// CEF records and JSON objects are sent as plain lines
+		useProto = false
+	}
+	// the main endpoint posts the logs to an HTTP intake as plain lines with use_http,
+	// each additional endpoint does with its own use_http setting
+	useHTTP := LogsAgent.GetBool("logs_config.use_http")
 	proxyAddress := LogsAgent.GetString("logs_config.socks5_proxy_address")
+	httpProxy := LogsAgent.GetString("logs_config.http_proxy")
+	backoffBase := time.Duration(LogsAgent.GetFloat64("logs_config.sender.backoff_base") * float64(time.Second))
+	backoffMax := time.Duration(LogsAgent.GetFloat64("logs_config.sender.backoff_max") * float64(time.Second))
+	fallbackDelay := time.Duration(LogsAgent.GetFloat64("logs_config.connection_fallback_delay") * float64(time.Second))
+	// dropping the logs of the main destination is worse than delaying them, its circuit breaker never opens by default
+	breakerThreshold := LogsAgent.GetInt("logs_config.circuit_breaker.threshold")
+	mainBreakerThreshold := LogsAgent.GetInt("logs_config.circuit_breaker.main_threshold")
+	breakerCooldown := time.Duration(LogsAgent.GetFloat64("logs_config.circuit_breaker.cooldown") * float64(time.Second))
+	writeTimeout := time.Duration(LogsAgent.GetFloat64("logs_config.sender.write_timeout") * float64(time.Second))
+	keepAlive := time.Duration(LogsAgent.GetFloat64("logs_config.sender.keep_alive") * float64(time.Second))
+	maxConnectionAge := time.Duration(LogsAgent.GetFloat64("logs_config.sender.max_connection_age") * float64(time.Second))
+	maxConnectionIdle := time.Duration(LogsAgent.GetFloat64("logs_config.sender.max_connection_idle") * float64(time.Second))
+	// only the main endpoint gives up reconnecting, the additional ones have circuit breakers instead
+	maxReconnectAttempts := LogsAgent.GetInt("logs_config.sender.max_reconnect_attempts")
 
 	main := client.Endpoint{
-		APIKey:       LogsAgent.GetString("api_key"),
-		Logset:       LogsAgent.GetString("logset"),
-		UseProto:     useProto,
-		ProxyAddress: proxyAddress,
+		APIKey:               LogsAgent.GetString("api_key"),
+		Logset:               LogsAgent.GetString("logset"),
+		UseProto:             useProto && !useHTTP,
+		UseCEF:               useCEF,
+		UseHTTP:              useHTTP,
+		Path:                 LogsAgent.GetString("logs_config.http_path"),
+		Compression:          LogsAgent.GetString("logs_config.compression"),
+		CompressionLevel:     LogsAgent.GetInt("logs_config.compression_level"),
+		UseJSON:              useJSON,
+		JSONRawMessage:       jsonRawMessage,
+		ProxyAddress:         proxyAddress,
+		HTTPProxy:            httpProxy,
+		BackoffBase:          backoffBase,
+		BackoffMax:           backoffMax,
+		FallbackDelay:        fallbackDelay,
+		WriteTimeout:         writeTimeout,
+		KeepAlive:            keepAlive,
+		MaxConnectionAge:     maxConnectionAge,
+		MaxConnectionIdle:    maxConnectionIdle,
+		MaxReconnectAttempts: maxReconnectAttempts,
+		TLSCertPath:          LogsAgent.GetString("logs_config.tls_cert_path"),
+		TLSKeyPath:           LogsAgent.GetString("logs_config.tls_key_path"),
+		TLSCAPath:            LogsAgent.GetString("logs_config.tls_ca_path"),
 	}
 	switch {
 	case LogsAgent.GetString("logs_config.logs_dd_url") != "":
@@ -89,6 +134,8 @@ func BuildEndpoints() (*client.Endpoints, error) {
 		useSSL = !LogsAgent.GetBool("logs_config.dev_mode_no_ssl")
 	}
 	main.UseSSL = useSSL
+	main.CircuitBreakerThreshold = mainBreakerThreshold
+	main.CircuitBreakerCooldown = breakerCooldown
 
 	var additionals []client.Endpoint
 	err := LogsAgent.UnmarshalKey("logs_config.additional_endpoints", &additionals)
@@ -96,10 +143,153 @@ func BuildEndpoints() (*client.Endpoints, error) {
 		log.Warnf("Could not parse additional_endpoints for logs: %v", err)
 	}
 	for i := 0; i < len(additionals); i++ {
+		if additionals[i].UseHTTP && main.UseProto {
+			// the logs are encoded once for all the destinations
+			return nil, fmt.Errorf("additional_endpoints can not use use_http while the logs are encoded as protocol buffers, set dev_mode_use_proto to false")
+		}
 		additionals[i].UseSSL = useSSL
 		additionals[i].UseProto = useProto
-		additionals[i].ProxyAddress = proxyAddress
+		additionals[i].UseCEF = useCEF
+		additionals[i].UseJSON = useJSON
+		additionals[i].JSONRawMessage = jsonRawMessage
+		if additionals[i].ProxyAddress == "" && additionals[i].HTTPProxy == "" {
+			// the endpoints which do not set a proxy use the global one
+			additionals[i].ProxyAddress = proxyAddress
+			additionals[i].HTTPProxy = httpProxy
+		}
+		additionals[i].BackoffBase = backoffBase
+		additionals[i].BackoffMax = backoffMax
+		additionals[i].FallbackDelay = fallbackDelay
+		additionals[i].CircuitBreakerThreshold = breakerThreshold
+		additionals[i].CircuitBreakerCooldown = breakerCooldown
+		additionals[i].WriteTimeout = writeTimeout
+		additionals[i].KeepAlive = keepAlive
+		additionals[i].MaxConnectionAge = maxConnectionAge
+		additionals[i].MaxConnectionIdle = maxConnectionIdle
+	}
+
+	// the failover endpoints are used in turn instead of the main one, they share its settings
+	var failovers []client.Endpoint
+	err = LogsAgent.UnmarshalKey("logs_config.failover_endpoints", &failovers)
+	if err != nil {
+		log.Warnf("Could not parse failover_endpoints for logs: %v", err)
+	}
+	for i := 0; i < len(failovers); i++ {
+		if failovers[i].APIKey == "" {
+			failovers[i].APIKey = main.APIKey
+		}
+		failovers[i].Logset = main.Logset
+		failovers[i].UseSSL = useSSL
+		failovers[i].UseProto = useProto
+		failovers[i].UseCEF = useCEF
+		failovers[i].UseJSON = useJSON
+		failovers[i].JSONRawMessage = jsonRawMessage
+		if failovers[i].ProxyAddress == "" && failovers[i].HTTPProxy == "" {
+			// the endpoints which do not set a proxy use the global one
+			failovers[i].ProxyAddress = proxyAddress
+			failovers[i].HTTPProxy = httpProxy
+		}
+		failovers[i].BackoffBase = backoffBase
+		failovers[i].BackoffMax = backoffMax
+		failovers[i].FallbackDelay = fallbackDelay
+		failovers[i].CircuitBreakerThreshold = mainBreakerThreshold
+		failovers[i].CircuitBreakerCooldown = breakerCooldown
+		failovers[i].WriteTimeout = writeTimeout
+		failovers[i].KeepAlive = keepAlive
+		failovers[i].MaxConnectionAge = maxConnectionAge
+		failovers[i].MaxConnectionIdle = maxConnectionIdle
+	}
+
+	if useHTTP && len(failovers) > 0 {
+		return nil, fmt.Errorf("failover_endpoints can not be used with use_http")
+	}
+	if maxReconnectAttempts > 0 && len(failovers) > 0 {
+		return nil, fmt.Errorf("failover_endpoints can not be used with sender.max_reconnect_attempts")
 	}
 
-	return client.NewEndpoints(main, additionals), nil
+	// the certificates are checked now so that a misconfiguration prevents the agent from starting
+	// rather than failing every connection attempt
+	for _, endpoint := range append(append([]client.Endpoint{main}, additionals...), failovers...) {
+		if err := checkTLSConfig(endpoint); err != nil {
+			return nil, err
+		}
+	}
+	for _, endpoint := range append(append([]client.Endpoint{main}, additionals...), failovers...) {
+		if err := checkProxy(endpoint); err != nil {
+			return nil, err
+		}
+	}
+	// only the HTTP intakes decompress the payloads
+	for _, endpoint := range append([]client.Endpoint{main}, additionals...) {
+		if endpoint.Compression != client.CompressionNone && !endpoint.UseHTTP {
+			return nil, fmt.Errorf("the logs sent to %s can not be compressed without use_http", endpoint.Host)
+		}
+		if err := client.CheckCompression(endpoint.Compression, endpoint.CompressionLevel); err != nil {
+			return nil, err
+		}
+	}
+	for _, endpoint := range additionals {
+		if endpoint.Kafka != nil && len(endpoint.Kafka.Brokers) == 0 {
+			return nil, fmt.Errorf("the kafka additional endpoint has no brokers")
+		}
+	}
+	for _, endpoint := range additionals {
+		if endpoint.Route == nil {
+			continue
+		}
+		if _, err := regexp.Compile(endpoint.Route.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid route pattern of additional endpoint %s: %v", endpoint.Host, err)
+		}
+	}
+
+	endpoints := client.NewEndpoints(main, additionals)
+	endpoints.Failovers = failovers
+	endpoints.FailoverPolicy = client.FailoverPolicy{
+		ProbeInterval:     time.Duration(LogsAgent.GetInt("logs_config.failover_probe_interval")) * time.Second,
+		FailureThreshold:  LogsAgent.GetInt("logs_config.failover_failure_threshold"),
+		RecoveryThreshold: LogsAgent.GetInt("logs_config.failover_recovery_threshold"),
+	}
+	if url := LogsAgent.GetString("logs_config.amqp_url"); url != "" {
+		endpoints.AMQP = &client.AMQPEndpoint{
+			URL:           url,
+			Exchange:      LogsAgent.GetString("logs_config.amqp_exchange"),
+			ExchangeType:  LogsAgent.GetString("logs_config.amqp_exchange_type"),
+			Durable:       LogsAgent.GetBool("logs_config.amqp_durable"),
+			RoutingKeyTag: LogsAgent.GetString("logs_config.amqp_routing_key_tag"),
+		}
+	}
+	if brokers := LogsAgent.GetStringSlice("logs_config.kafka_brokers"); len(brokers) > 0 {
+		if endpoints.AMQP != nil {
+			return nil, fmt.Errorf("the logs can not be published to both amqp_url and kafka_brokers")
+		}
+		endpoints.Kafka = &client.KafkaEndpoint{
+			Brokers: brokers,
+			Topic:   LogsAgent.GetString("logs_config.kafka_topic"),
+		}
+	}
+
+	return endpoints, nil
+}
+
+// checkProxy returns an error if endpoint sets both a socks5 and an http proxy
+// or if its http proxy is not a valid URL.
+func checkProxy(endpoint client.Endpoint) error {
+	if endpoint.ProxyAddress != "" && endpoint.HTTPProxy != "" {
+		return fmt.Errorf("the logs sent to %v can not go through both a socks5 and an http proxy", endpoint.Host)
+	}
+	_, err := client.ProxyURL(endpoint)
+	return err
+}
+
+// checkTLSConfig returns an error if the certificates of endpoint can not be loaded
+// or if they are set while SSL is disabled.
+func checkTLSConfig(endpoint client.Endpoint) error {
+	if endpoint.TLSCertPath == "" && endpoint.TLSKeyPath == "" && endpoint.TLSCAPath == "" {
+		return nil
+	}
+	if !endpoint.UseSSL {
+		return fmt.Errorf("TLS certificates are set for %v but SSL is disabled", endpoint.Host)
+	}
+	_, err := client.LoadTLSConfig(endpoint)
+	return err
 }
@@ -7,18 +7,28 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
 )
 
 // Logs source types
 const (
 	TCPType          = "tcp"
 	UDPType          = "udp"
+	UnixType         = "unix"
+	UnixgramType     = "unixgram"
 	FileType         = "file"
 	ContainerdType   = "containerd"
 	DockerType       = "docker"
 	JournaldType     = "journald"
 	WindowsEventType = "windows_event"
+	NamedPipeType    = "named_pipe"
 )
 
 // Logs rule types
@@ -27,6 +37,75 @@ const (
 	IncludeAtMatch = "include_at_match"
 	MaskSequences  = "mask_sequences"
 	MultiLine      = "multi_line"
+	MaskJSONKeys   = "mask_json_keys"
+	ParseAccessLog = "parse_access_log"
+	DecodeJSON     = "json"
+	ParseTimestamp = "parse_timestamp"
+	Sample         = "sample"
+	ParseStatus    = "parse_status"
+)
+
+// statuses are the statuses of the logs, the default statuses of the sources
+// and the statuses of the status mappings must be one of them.
+var statuses = map[string]bool{
+	"emergency": true,
+	"alert":     true,
+	"critical":  true,
+	"error":     true,
+	"warn":      true,
+	"notice":    true,
+	"info":      true,
+	"debug":     true,
+}
+
+// Framings of the messages received by the network sources
+const (
+	LineFraming       = "line"
+	OctetCountFraming = "octet_count"
+	AutoFraming       = "auto"
+	// AckedOctetCountFraming is the octet count framing where the agent acknowledges the messages
+	// once handed to the pipeline, so that the sender can retransmit the others after a restart of the agent.
+	AckedOctetCountFraming = "acked_octet_count"
+)
+
+// Types of the Unix sockets
+const (
+	StreamSocketType   = "stream"
+	DatagramSocketType = "datagram"
+)
+
+// DefaultSocketMode lets all the local processes write to the Unix sockets, like to /dev/log.
+const DefaultSocketMode os.FileMode = 0666
+
+// Formats of the windows events
+const (
+	JSONEventFormat    = "json"
+	XMLEventFormat     = "xml"
+	MessageEventFormat = "message"
+)
+
+// Encodings of the content of the sources, transcoded to UTF-8 by the decoder
+const (
+	UTF8Encoding = "utf-8"
+	// UTF16Encoding is little endian unless the content starts with a big endian byte order mark
+	UTF16Encoding   = "utf-16"
+	UTF16LEEncoding = "utf-16le"
+	UTF16BEEncoding = "utf-16be"
+	Latin1Encoding  = "latin-1"
+)
+
+// Policies applied to the messages larger than the max size the backend accepts
+const (
+	// TruncateOversizePolicy cuts the messages and ends them with the truncation marker.
+	TruncateOversizePolicy = "truncate"
+	// SplitOversizePolicy splits the messages into parts sent in order, tagged with their group and index.
+	SplitOversizePolicy = "split"
+)
+
+// Start positions
+const (
+	BeginningStartPosition = "beginning"
+	EndStartPosition       = "end"
 )
 
 // ProcessingRule defines an exclusion or a masking rule to
@@ -36,9 +115,38 @@ type ProcessingRule struct {
 	Name               string
 	ReplacePlaceholder string `mapstructure:"replace_placeholder" json:"replace_placeholder"`
 	Pattern            string
+	Keys               []string
+	// Format is the predefined access log format parsed by the rule, see AccessLogFormats,
+	// LogFormat is a custom Apache or Nginx log format used instead.
+	// For the timestamp rules, Format is a predefined timestamp format or a strftime format.
+	Format    string
+	LogFormat string `mapstructure:"log_format" json:"log_format"`
+	// Timezone is the IANA name of the timezone of the timestamps without offset, or local, UTC by default.
+	// Across the daylight saving time transitions, the times skipped are read with the offset before the transition
+	// and the times repeated as their first occurrence.
+	Timezone string
+	// SampleRate is the share of the logs kept by the sampling rules, 0.1 keeps one log in ten,
+	// the logs sharing the same key, captured by the last group of Pattern or found in the field KeyField
+	// of the JSON logs, are kept or dropped together.
+	SampleRate float64 `mapstructure:"sample_rate" json:"sample_rate"`
+	KeyField   string  `mapstructure:"key_field" json:"key_field"`
+	// FlushTimeout is the number of seconds after which a multi-line message is sent
+	// when no new line is received, MaxSize is the number of bytes above which it is truncated and sent.
+	FlushTimeout float64 `mapstructure:"flush_timeout" json:"flush_timeout"`
+	MaxSize      int     `mapstructure:"max_size" json:"max_size"`
+	// TimestampField and SeverityField are the fields of the JSON logs holding their timestamp and severity,
+	// the dots denote nested fields.
+	TimestampField string `mapstructure:"timestamp_field" json:"timestamp_field"`
+	SeverityField  string `mapstructure:"severity_field" json:"severity_field"`
+	// StatusMapping maps the severity names to the statuses for the status rules, it extends the usual names,
+	// such as WARNING or ERR, which are matched regardless of their case, StatusAliases once compiled.
+	StatusMapping map[string]string `mapstructure:"status_mapping" json:"status_mapping"`
 	// TODO: should be moved out
 	Reg                     *regexp.Regexp
 	ReplacePlaceholderBytes []byte
+	TimestampParser         *TimestampParser
+	SampleCount             *uint64
+	StatusAliases           map[string]string
 }
 
 // LogsConfig represents a log source config, which can be for instance
@@ -47,10 +155,66 @@ type LogsConfig struct {
 	Type string
 
 	Port int    // Network
-	Path string // File, Journald
+	Path string // File, Journald, Unix, Unixgram, Named pipe
+
+	// SocketType is the type of the Unix socket created at Path, stream by default, each connection being
+	// read like a TCP connection, or datagram, each datagram being one message.
+	SocketType string `mapstructure:"socket_type" json:"socket_type"` // Unix
+	// SocketMode is the permissions of the socket in octal, like "0660", writable by all the local users by default.
+	SocketMode string `mapstructure:"socket_mode" json:"socket_mode"` // Unix, Unixgram
+
+	// Framing is how the messages are delimited in the stream, either by line feeds,
+	// by an octet count prefix as defined by RFC6587, or auto to detect it for each message.
+	// With UDP, each datagram is one message unless the framing is by line feeds.
+	// With TCP, the acked octet count framing acknowledges the messages to the sender, see the listener input.
+	Framing string // Network
+	// Syslog parses the header of the messages received as syslog messages, in the RFC3164 or the RFC5424 format,
+	// into their status, timestamp and attributes, the messages without a valid priority are left untouched.
+	// The messages received by the unixgram sources are always parsed as syslog messages.
+	Syslog bool // Network, Unix
+	// TLSCertPath and TLSKeyPath are the certificate and the key presented to the senders,
+	// the connections are TLS-terminated when both are set.
+	TLSCertPath string `mapstructure:"tls_cert_path" json:"tls_cert_path"` // TCP
+	TLSKeyPath  string `mapstructure:"tls_key_path" json:"tls_key_path"`   // TCP
+	// TLSCAPath is the certificate authorities the senders must present a certificate signed by,
+	// any sender is accepted when it is not set.
+	TLSCAPath string `mapstructure:"tls_ca_path" json:"tls_ca_path"` // TCP
+
+	// ManifestFormat indicates that Path is a manifest listing the segments of a rotated set,
+	// from the oldest to the active one, written in this format.
+	ManifestFormat string `mapstructure:"manifest_format" json:"manifest_format"` // File
+	// Precedence determines which source tails the files matched by several sources,
+	// the highest precedence wins.
+	Precedence int // File
+	// StartPosition is where the files are tailed from when no offset has been recorded for them,
+	// either the beginning or the end of the file, it defaults to the end for the sources of the configs
+	// and to the beginning for the sources discovered. The files created after the source was added
+	// are always tailed from their beginning.
+	StartPosition string `mapstructure:"start_position" json:"start_position"` // File
+	// ExcludePaths are the glob patterns of the files matched by Path which must not be tailed,
+	// the patterns without any path separator are matched against the name of the files.
+	ExcludePaths []string `mapstructure:"exclude_paths" json:"exclude_paths"` // File
+	// FollowSymlinkRetarget makes the tailers of the symlinks switch to the new target when the symlinks are repointed,
+	// once the old target has been read until its end.
+	FollowSymlinkRetarget bool `mapstructure:"follow_symlink_retarget" json:"follow_symlink_retarget"` // File
+	// PathTagsPattern is a regular expression matched against the absolute path of the files tailed,
+	// the values of its named groups are added as tags to the messages of each file, PathTagsReg once compiled.
+	PathTagsPattern string `mapstructure:"path_tags_pattern" json:"path_tags_pattern"` // File
+	PathTagsReg     *regexp.Regexp
+	// ReadCompressed reads once the rotated files compressed with gzip which are found when the source is added,
+	// the files named after the files matched by Path followed by a suffix ending with .gz such as app.log.1.gz,
+	// the compressed files are never tailed.
+	ReadCompressed bool `mapstructure:"read_compressed" json:"read_compressed"` // File
+	// IgnorePreExisting only tails the files first seen after the source was added, from their beginning,
+	// the files present when it is added are never tailed for it, whatever their offsets, even after a restart,
+	// unless they are replaced by new files at the same path.
+	IgnorePreExisting bool `mapstructure:"ignore_pre_existing" json:"ignore_pre_existing"` // File
 
 	IncludeUnits []string `mapstructure:"include_units" json:"include_units"` // Journald
 	ExcludeUnits []string `mapstructure:"exclude_units" json:"exclude_units"` // Journald
+	// IncludeMatches and ExcludeMatches are the FIELD=value matches of the entries to collect and to drop.
+	IncludeMatches []string `mapstructure:"include_match" json:"include_match"` // Journald
+	ExcludeMatches []string `mapstructure:"exclude_match" json:"exclude_match"` // Journald
 
 	Image      string // Docker
 	Label      string // Docker
@@ -59,17 +223,71 @@ type LogsConfig struct {
 
 	ChannelPath string `mapstructure:"channel_path" json:"channel_path"` // Windows Event
 	Query       string // Windows Event
+	// EventIDs restricts the events collected to the ones with these IDs, instead of a query.
+	EventIDs []int `mapstructure:"event_ids" json:"event_ids"` // Windows Event
+	// EventFormat is how the events are rendered, see the event formats.
+	EventFormat string `mapstructure:"event_format" json:"event_format"` // Windows Event
 
 	Service         string
 	Source          string
 	SourceCategory  string
 	Tags            []string
 	ProcessingRules []ProcessingRule `mapstructure:"log_processing_rules" json:"log_processing_rules"`
+	// PreserveOrder ensures that the messages of the source are processed in order
+	// when the processing rules are applied in parallel.
+	PreserveOrder bool `mapstructure:"preserve_order" json:"preserve_order"`
+	// MaxMessageAge is the age in seconds above which the messages are dropped instead of being sent,
+	// it overrides logs_config.max_message_age when set, the messages without timestamp are never dropped.
+	MaxMessageAge int `mapstructure:"max_message_age" json:"max_message_age"`
+	// StripBOM removes the UTF-8 byte order mark at the beginning of the messages,
+	// which appears in the middle of the stream when a file is truncated or rewritten.
+	StripBOM bool `mapstructure:"strip_bom" json:"strip_bom"`
+	// Encoding is the encoding of the content of the source, see the encodings, UTF-8 by default,
+	// the content is transcoded to UTF-8 and the byte order marks are stripped.
+	Encoding string
+	// SequenceNumbers adds the sequence number of each message of the source to its metadata
+	// so that the gaps reveal the messages lost on the way, this is not supported by the protobuf format.
+	// The numbers restart at 1 when the source is created again, after a restart of the agent or a reload.
+	SequenceNumbers bool `mapstructure:"sequence_numbers" json:"sequence_numbers"`
+	// KafkaTopic and KafkaKey are the topic and the partition key of the logs of the source published to Kafka,
+	// the topic of the endpoint is used when KafkaTopic is not set and the logs are spread over the partitions
+	// when KafkaKey is not set, otherwise they all go to the same partition to keep them in order.
+	KafkaTopic string `mapstructure:"kafka_topic" json:"kafka_topic"`
+	KafkaKey   string `mapstructure:"kafka_key" json:"kafka_key"`
+	// LogsPerSecond caps the number of logs of the source processed per second, the excess logs are dropped
+	// so that a noisy source can not congest the pipelines, bursts of one second of logs are allowed.
+	LogsPerSecond float64 `mapstructure:"logs_per_second" json:"logs_per_second"`
+	// OversizePolicy is how the messages larger than logs_config.max_message_size are handled,
+	// see the oversize policies, they are truncated by default.
+	OversizePolicy string `mapstructure:"oversize_policy" json:"oversize_policy"`
+	// ExcludeHostTags keeps the tags of the host out of the messages of the source when logs_config.host_tags is set,
+	// for the sources of high cardinality for example.
+	ExcludeHostTags bool `mapstructure:"exclude_host_tags" json:"exclude_host_tags"`
+	// DefaultStatus is the status of the messages of the source whose status is neither set by the source
+	// nor parsed by a processing rule, info when it is not set.
+	DefaultStatus string `mapstructure:"default_status" json:"default_status"`
+	// Tee duplicates the messages of the source to additional pipelines,
+	// each with its own processing rules and destinations.
+	Tee []TeeConfig
+
+	// interpolationErr is the error which occurred when interpolating the config, which makes it invalid.
+	interpolationErr error
+}
+
+// TeeConfig represents an additional pipeline the messages of a source are duplicated to,
+// the messages are processed with its processing rules instead of the ones of the source
+// and are sent to its endpoints, the first one being the main endpoint.
+type TeeConfig struct {
+	Name            string
+	ProcessingRules []ProcessingRule `mapstructure:"log_processing_rules" json:"log_processing_rules"`
+	Endpoints       []client.Endpoint
 }
 
 // Validate returns an error if the config is misconfigured
 func (c *LogsConfig) Validate() error {
 	switch {
+	case c.interpolationErr != nil:
+		return c.interpolationErr
 	case c.Type == "":
 		// user don't have to specify a logs-config type when defining
 		// an autodiscovery label because so we must override it at some point,
@@ -81,24 +299,199 @@ func (c *LogsConfig) Validate() error {
 		return fmt.Errorf("tcp source must have a port")
 	case c.Type == UDPType && c.Port == 0:
 		return fmt.Errorf("udp source must have a port")
+	case c.Type == UnixType && c.Path == "":
+		return fmt.Errorf("unix source must have a path")
+	case c.Type == UnixgramType && c.Path == "":
+		return fmt.Errorf("unixgram source must have a path")
+	case c.SocketType != "" && c.SocketType != StreamSocketType && c.SocketType != DatagramSocketType:
+		return fmt.Errorf("socket type %s is not supported, must be %s or %s", c.SocketType, StreamSocketType, DatagramSocketType)
+	case c.Type == NamedPipeType && c.Path == "":
+		return fmt.Errorf("named pipe source must have a path")
+	case c.Type == WindowsEventType && c.ChannelPath == "":
+		return fmt.Errorf("windows event source must have a channel path")
+	case len(c.EventIDs) > 0 && c.Query != "":
+		return fmt.Errorf("event ids can not be used with a query")
+	case c.EventFormat != "" && c.EventFormat != JSONEventFormat && c.EventFormat != XMLEventFormat && c.EventFormat != MessageEventFormat:
+		return fmt.Errorf("event format %s is not supported, must be %s, %s or %s", c.EventFormat, JSONEventFormat, XMLEventFormat, MessageEventFormat)
+	case c.Framing != "" && c.Framing != LineFraming && c.Framing != OctetCountFraming && c.Framing != AutoFraming && c.Framing != AckedOctetCountFraming:
+		return fmt.Errorf("framing %s is not supported, must be %s, %s, %s or %s", c.Framing, LineFraming, OctetCountFraming, AutoFraming, AckedOctetCountFraming)
+	case c.Syslog && c.Type != TCPType && c.Type != UDPType && c.Type != UnixType && c.Type != UnixgramType:
+		return fmt.Errorf("syslog is only supported by tcp, udp, unix and unixgram sources")
+	case (c.TLSCertPath != "" || c.TLSKeyPath != "" || c.TLSCAPath != "") && c.Type != TCPType:
+		return fmt.Errorf("tls is only supported by tcp sources")
+	case (c.TLSCertPath != "") != (c.TLSKeyPath != ""):
+		return fmt.Errorf("both the tls certificate and key must be set")
+	case c.TLSCAPath != "" && c.TLSCertPath == "":
+		return fmt.Errorf("tls certificate authorities can not be used without a tls certificate and key")
+	case c.Framing == AckedOctetCountFraming && c.Type != TCPType:
+		return fmt.Errorf("framing %s is only supported by tcp sources", c.Framing)
+	case c.Framing == AckedOctetCountFraming && c.Encoding != "" && c.Encoding != UTF8Encoding:
+		return fmt.Errorf("framing %s only supports the %s encoding", c.Framing, UTF8Encoding)
+	case c.Encoding != "" && c.Encoding != UTF8Encoding && c.Encoding != UTF16Encoding && c.Encoding != UTF16LEEncoding && c.Encoding != UTF16BEEncoding && c.Encoding != Latin1Encoding:
+		return fmt.Errorf("encoding %s is not supported, must be %s, %s, %s, %s or %s", c.Encoding, UTF8Encoding, UTF16Encoding, UTF16LEEncoding, UTF16BEEncoding, Latin1Encoding)
+	case c.StartPosition != "" && c.StartPosition != BeginningStartPosition && c.StartPosition != EndStartPosition:
+		return fmt.Errorf("start position %s is not supported, must be %s or %s", c.StartPosition, BeginningStartPosition, EndStartPosition)
+	case c.OversizePolicy != "" && c.OversizePolicy != TruncateOversizePolicy && c.OversizePolicy != SplitOversizePolicy:
+		return fmt.Errorf("oversize policy %s is not supported, must be %s or %s", c.OversizePolicy, TruncateOversizePolicy, SplitOversizePolicy)
+	case c.LogsPerSecond < 0:
+		return fmt.Errorf("logs per second can not be negative: %v", c.LogsPerSecond)
+	case c.ReadCompressed && c.ManifestFormat != "":
+		return fmt.Errorf("compressed files can not be read with a manifest")
+	case c.IgnorePreExisting && c.ManifestFormat != "":
+		return fmt.Errorf("pre-existing files can not be ignored with a manifest")
+	case c.IgnorePreExisting && c.ReadCompressed:
+		return fmt.Errorf("pre-existing files can not be ignored when reading the compressed files")
+	case c.DefaultStatus != "" && !statuses[c.DefaultStatus]:
+		return fmt.Errorf("default status %s is not supported", c.DefaultStatus)
+	}
+	if _, err := c.SocketPermissions(); err != nil {
+		return err
+	}
+	if c.Framing == AckedOctetCountFraming {
+		// the acked messages are handed to the pipeline as they are received, they can not be aggregated
+		for _, rule := range c.ProcessingRules {
+			if rule.Type == MultiLine {
+				return fmt.Errorf("processing rule `%s` can not be a multi-line rule with framing %s", rule.Name, c.Framing)
+			}
+		}
+	}
+	for _, pattern := range c.ExcludePaths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclusion pattern %s: %v", pattern, err)
+		}
+	}
+	for _, matches := range [][]string{c.IncludeMatches, c.ExcludeMatches} {
+		for _, match := range matches {
+			if strings.Index(match, "=") < 1 {
+				return fmt.Errorf("invalid journal match %s, must be FIELD=value", match)
+			}
+		}
+	}
+	if c.PathTagsPattern != "" {
+		reg, err := regexp.Compile(c.PathTagsPattern)
+		if err != nil {
+			return fmt.Errorf("invalid path tags pattern %s: %v", c.PathTagsPattern, err)
+		}
+		if !hasNamedGroup(reg) {
+			return fmt.Errorf("path tags pattern %s must have a named group", c.PathTagsPattern)
+		}
+	}
+	err := validateProcessingRules(c.ProcessingRules)
+	if err != nil {
+		return err
+	}
+	return c.validateTee()
+}
+
+// hasNamedGroup returns true if reg captures at least one named group.
+func hasNamedGroup(reg *regexp.Regexp) bool {
+	for _, name := range reg.SubexpNames() {
+		if name != "" {
+			return true
+		}
 	}
-	return c.validateProcessingRules()
+	return false
+}
+
+// SocketPermissions returns the permissions of the Unix socket parsed from SocketMode,
+// DefaultSocketMode when it is not set, returns an error if it is not a valid octal mode.
+func (c *LogsConfig) SocketPermissions() (os.FileMode, error) {
+	if c.SocketMode == "" {
+		return DefaultSocketMode, nil
+	}
+	mode, err := strconv.ParseUint(c.SocketMode, 8, 32)
+	if err != nil || os.FileMode(mode)&^os.ModePerm != 0 {
+		return 0, fmt.Errorf("invalid socket mode %s, must be octal permissions like 0660", c.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// validateTee validates the tees and raises an error if one is misconfigured.
+// Each tee must have a name, at least one endpoint and valid processing rules,
+// multi-line rules are not supported as they are applied before the messages are duplicated.
+func (c *LogsConfig) validateTee() error {
+	for _, tee := range c.Tee {
+		if tee.Name == "" {
+			return fmt.Errorf("all tees must have a name")
+		}
+		if len(tee.Endpoints) == 0 {
+			return fmt.Errorf("no endpoints provided for tee: %s", tee.Name)
+		}
+		for _, rule := range tee.ProcessingRules {
+			if rule.Type == MultiLine {
+				return fmt.Errorf("processing rule `%s` of tee %s can not be a multi-line rule", rule.Name, tee.Name)
+			}
+		}
+		err := validateProcessingRules(tee.ProcessingRules)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // validateProcessingRules validates the rules and raises an error if one is misconfigured.
 // Each processing rule must have:
 // - a valid name
 // - a valid type
-// - a valid pattern that compiles
-func (c *LogsConfig) validateProcessingRules() error {
-	for _, rule := range c.ProcessingRules {
+// - a valid pattern that compiles, or a list of keys for json masking rules, json decoding rules need none
+// - a valid format for the timestamp rules, whose pattern is optional
+// - a valid rate for the sampling rules, whose pattern is optional
+// - either a pattern or a severity field for the status rules, whose mapping must be to valid statuses
+func validateProcessingRules(rules []ProcessingRule) error {
+	for _, rule := range rules {
 		if rule.Name == "" {
 			return fmt.Errorf("all processing rules must have a name")
 		}
 
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine:
+		case ExcludeAtMatch, IncludeAtMatch, MaskSequences:
 			break
+		case MultiLine:
+			if rule.FlushTimeout < 0 {
+				return fmt.Errorf("invalid flush timeout for processing rule `%s`: %v", rule.Name, rule.FlushTimeout)
+			}
+			if rule.MaxSize < 0 {
+				return fmt.Errorf("invalid max size for processing rule `%s`: %v", rule.Name, rule.MaxSize)
+			}
+		case MaskJSONKeys:
+			if len(rule.Keys) == 0 {
+				return fmt.Errorf("no keys provided for processing rule: %s", rule.Name)
+			}
+			continue
+		case ParseAccessLog:
+			if _, err := compileAccessLogRule(rule); err != nil {
+				return fmt.Errorf("invalid access log format for processing rule `%s`: %v", rule.Name, err)
+			}
+			continue
+		case DecodeJSON:
+			continue
+		case ParseTimestamp:
+			if _, err := compileTimestampRule(rule); err != nil {
+				return fmt.Errorf("invalid timestamp format for processing rule `%s`: %v", rule.Name, err)
+			}
+			continue
+		case Sample:
+			if rule.SampleRate <= 0 || rule.SampleRate > 1 {
+				return fmt.Errorf("invalid sample rate for processing rule `%s`: %v, it must be above 0 and at most 1", rule.Name, rule.SampleRate)
+			}
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
+			}
+			continue
+		case ParseStatus:
+			if (rule.Pattern == "") == (rule.SeverityField == "") {
+				return fmt.Errorf("either a pattern or a severity field must be provided for processing rule: %s", rule.Name)
+			}
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
+			}
+			for level, status := range rule.StatusMapping {
+				if !statuses[status] {
+					return fmt.Errorf("invalid status %s of %s for processing rule `%s`", status, level, rule.Name)
+				}
+			}
+			continue
 		case "":
 			return fmt.Errorf("type must be set for processing rule `%s`", rule.Name)
 		default:
@@ -116,9 +509,29 @@ func (c *LogsConfig) validateProcessingRules() error {
 	return nil
 }
 
-// Compile compiles all processing rule regular expressions.
+// Compile compiles all processing rule regular expressions, including the ones of the tees,
+// and the path tags pattern.
 func (c *LogsConfig) Compile() error {
-	rules := c.ProcessingRules
+	err := compileProcessingRules(c.ProcessingRules)
+	if err != nil {
+		return err
+	}
+	if c.PathTagsPattern != "" {
+		if c.PathTagsReg, err = regexp.Compile(c.PathTagsPattern); err != nil {
+			return err
+		}
+	}
+	for _, tee := range c.Tee {
+		err = compileProcessingRules(tee.ProcessingRules)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileProcessingRules compiles the regular expressions of rules.
+func compileProcessingRules(rules []ProcessingRule) error {
 	for i, rule := range rules {
 		re, err := regexp.Compile(rule.Pattern)
 		if err != nil {
@@ -130,12 +543,75 @@ func (c *LogsConfig) Compile() error {
 		case MaskSequences:
 			rules[i].Reg = re
 			rules[i].ReplacePlaceholderBytes = []byte(rule.ReplacePlaceholder)
+		case MaskJSONKeys:
+			rules[i].ReplacePlaceholderBytes = []byte(rule.ReplacePlaceholder)
 		case MultiLine:
 			rules[i].Reg, err = regexp.Compile("^" + rule.Pattern)
 			if err != nil {
 				return err
 			}
+		case ParseAccessLog:
+			rules[i].Reg, err = compileAccessLogRule(rule)
+			if err != nil {
+				return err
+			}
+		case ParseTimestamp:
+			rules[i].TimestampParser, err = compileTimestampRule(rule)
+			if err != nil {
+				return err
+			}
+		case Sample:
+			if rule.Pattern != "" {
+				rules[i].Reg = re
+			}
+			rules[i].SampleCount = new(uint64)
+		case ParseStatus:
+			if rule.Pattern != "" {
+				rules[i].Reg = re
+			}
+			rules[i].StatusAliases = make(map[string]string, len(rule.StatusMapping))
+			for level, status := range rule.StatusMapping {
+				rules[i].StatusAliases[strings.ToLower(level)] = status
+			}
 		}
 	}
 	return nil
 }
+
+// Equal returns true if both configs define the same source,
+// the compiled fields of the processing rules and the path tags are ignored as they derive from the other ones.
+func (c *LogsConfig) Equal(other *LogsConfig) bool {
+	return reflect.DeepEqual(c.withoutCompiledRules(), other.withoutCompiledRules())
+}
+
+// withoutCompiledRules returns a copy of the config whose processing rules and path tags are not compiled.
+func (c *LogsConfig) withoutCompiledRules() LogsConfig {
+	config := *c
+	config.PathTagsReg = nil
+	config.ProcessingRules = withoutCompiledRules(c.ProcessingRules)
+	if c.Tee != nil {
+		config.Tee = make([]TeeConfig, len(c.Tee))
+		for i, tee := range c.Tee {
+			tee.ProcessingRules = withoutCompiledRules(tee.ProcessingRules)
+			config.Tee[i] = tee
+		}
+	}
+	return config
+}
+
+// withoutCompiledRules returns a copy of rules without their compiled fields.
+func withoutCompiledRules(rules []ProcessingRule) []ProcessingRule {
+	if rules == nil {
+		return nil
+	}
+	copies := make([]ProcessingRule, len(rules))
+	for i, rule := range rules {
+		rule.Reg = nil
+		rule.ReplacePlaceholderBytes = nil
+		rule.TimestampParser = nil
+		rule.SampleCount = nil
+		rule.StatusAliases = nil
+		copies[i] = rule
+	}
+	return copies
+}
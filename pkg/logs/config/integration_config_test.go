@@ -8,9 +8,12 @@
 package config
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
 )
 
 func TestValidateShouldSucceedWithValidConfigs(t *testing.T) {
@@ -18,8 +21,45 @@ func TestValidateShouldSucceedWithValidConfigs(t *testing.T) {
 		{Type: FileType, Path: "/var/log/foo.log"},
 		{Type: TCPType, Port: 1234},
 		{Type: UDPType, Port: 5678},
+		{Type: TCPType, Port: 1234, Framing: OctetCountFraming},
+		{Type: UDPType, Port: 5678, Framing: AutoFraming},
+		{Type: TCPType, Port: 1234, Framing: AckedOctetCountFraming, ProcessingRules: []ProcessingRule{{Name: "foo", Type: ExcludeAtMatch, Pattern: ".*"}}},
+		{Type: UDPType, Port: 1234, Syslog: true},
+		{Type: TCPType, Port: 1234, Syslog: true, TLSCertPath: "/etc/ssl/logs.crt", TLSKeyPath: "/etc/ssl/logs.key"},
+		{Type: TCPType, Port: 1234, TLSCertPath: "/etc/ssl/logs.crt", TLSKeyPath: "/etc/ssl/logs.key", TLSCAPath: "/etc/ssl/ca.crt"},
+		{Type: UnixType, Path: "/var/run/app.sock", Syslog: true},
+		{Type: UnixgramType, Path: "/dev/log"},
+		{Type: UnixgramType, Path: "/dev/log", SocketMode: "0622"},
+		{Type: UnixType, Path: "/var/run/app.sock"},
+		{Type: UnixType, Path: "/var/run/app.sock", SocketType: StreamSocketType, SocketMode: "0660", Framing: OctetCountFraming},
+		{Type: UnixType, Path: "/var/run/app.sock", SocketType: DatagramSocketType},
+		{Type: NamedPipeType, Path: "/var/run/app.pipe"},
 		{Type: DockerType},
+		{Type: WindowsEventType, ChannelPath: "Microsoft-Windows-Sysmon/Operational", Query: "*[System[Level<=3]]", EventFormat: XMLEventFormat},
+		{Type: WindowsEventType, ChannelPath: "Security", EventIDs: []int{4624, 4625}, EventFormat: MessageEventFormat},
 		{Type: JournaldType, ProcessingRules: []ProcessingRule{{Name: "foo", Type: ExcludeAtMatch, Pattern: ".*"}}},
+		{Type: JournaldType, IncludeMatches: []string{"_COMM=nginx"}, ExcludeMatches: []string{"PRIORITY=7", "MESSAGE="}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: MaskJSONKeys, Keys: []string{"password"}}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseAccessLog, Format: "combined"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseAccessLog, LogFormat: `$remote_addr "$request" $status`}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: MultiLine, Pattern: "[0-9]", FlushTimeout: 0.5, MaxSize: 1024}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: DecodeJSON}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: DecodeJSON, TimestampField: "time", SeverityField: "log.level"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseTimestamp, Format: "%Y-%m-%d %H:%M:%S", Timezone: "local"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: Sample, SampleRate: 0.1, KeyField: "trace_id"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseStatus, Pattern: `\[(\w+)\]`, StatusMapping: map[string]string{"SEV1": "critical"}}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseStatus, SeverityField: "level"}}},
+		{Type: FileType, Path: "/var/log/foo.log", DefaultStatus: "warn"},
+		{Type: FileType, Path: "/var/log/*.log", IgnorePreExisting: true},
+		{Type: FileType, Path: "/var/log/foo.log", OversizePolicy: SplitOversizePolicy},
+		{Type: FileType, Path: "/logs/*/app.log", PathTagsPattern: `^/logs/tenant-(?P<tenant>[^/]+)/`},
+		{Type: FileType, Path: "/var/log/foo.log", StartPosition: BeginningStartPosition},
+		{Type: FileType, Path: "/var/log/foo.log", StartPosition: EndStartPosition},
+		{Type: FileType, Path: "/var/log/foo.log", LogsPerSecond: 100},
+		{Type: FileType, Path: "/var/log/foo.log", Encoding: UTF16LEEncoding},
+		{Type: FileType, Path: "/var/log/foo.log", Encoding: Latin1Encoding},
+		{Type: FileType, Path: "/var/log/*.log", ExcludePaths: []string{"debug-*.log", "/var/log/[ab].log"}},
+		{Type: FileType, Path: "/var/log/foo.log", Tee: []TeeConfig{{Name: "foo", Endpoints: []client.Endpoint{{Host: "foo"}}, ProcessingRules: []ProcessingRule{{Name: "foo", Type: ExcludeAtMatch, Pattern: ".*"}}}}},
 	}
 
 	for _, config := range validConfigs {
@@ -34,6 +74,47 @@ func TestValidateShouldFailWithInvalidConfigs(t *testing.T) {
 		{Type: FileType},
 		{Type: TCPType},
 		{Type: UDPType},
+		{Type: UnixgramType},
+		{Type: UnixType},
+		{Type: UnixType, Path: "/var/run/app.sock", SocketType: "seqpacket"},
+		{Type: UnixType, Path: "/var/run/app.sock", SocketMode: "rw-rw----"},
+		{Type: UnixType, Path: "/var/run/app.sock", SocketMode: "4755"},
+		{Type: NamedPipeType},
+		{Type: WindowsEventType},
+		{Type: WindowsEventType, ChannelPath: "Security", EventIDs: []int{4624}, Query: "*"},
+		{Type: WindowsEventType, ChannelPath: "Security", EventFormat: "text"},
+		{Type: FileType, Path: "/var/log/foo.log", StartPosition: "middle"},
+		{Type: TCPType, Port: 1234, Framing: "newline"},
+		{Type: UDPType, Port: 1234, Framing: AckedOctetCountFraming},
+		{Type: FileType, Path: "/var/log/syslog", Syslog: true},
+		{Type: UDPType, Port: 1234, TLSCertPath: "/etc/ssl/logs.crt", TLSKeyPath: "/etc/ssl/logs.key"},
+		{Type: TCPType, Port: 1234, TLSCertPath: "/etc/ssl/logs.crt"},
+		{Type: TCPType, Port: 1234, TLSKeyPath: "/etc/ssl/logs.key"},
+		{Type: TCPType, Port: 1234, TLSCAPath: "/etc/ssl/ca.crt"},
+		{Type: TCPType, Port: 1234, Framing: AckedOctetCountFraming, Encoding: UTF16Encoding},
+		{Type: TCPType, Port: 1234, Framing: AckedOctetCountFraming, ProcessingRules: []ProcessingRule{{Name: "foo", Type: MultiLine, Pattern: "[0-9]"}}},
+		{Type: JournaldType, IncludeMatches: []string{"nginx"}},
+		{Type: JournaldType, ExcludeMatches: []string{"=7"}},
+		{Type: FileType, Path: "/var/log/foo.log", LogsPerSecond: -1},
+		{Type: FileType, Path: "/var/log/foo.log", Encoding: "shift-jis"},
+		{Type: FileType, Path: "/var/log/*.log", ExcludePaths: []string{"[a-.log"}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseAccessLog}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseAccessLog, Format: "iis"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseTimestamp, Format: "iso8601"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: Sample}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: Sample, SampleRate: 2}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseStatus}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseStatus, Pattern: "level=(\\w+)", SeverityField: "level"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseStatus, Pattern: "(?=abf)"}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: ParseStatus, SeverityField: "level", StatusMapping: map[string]string{"sev1": "fatal"}}}},
+		{Type: FileType, Path: "/var/log/foo.log", DefaultStatus: "warning"},
+		{Type: FileType, Path: "/var/log/foo.log", IgnorePreExisting: true, ReadCompressed: true},
+		{Type: FileType, Path: "/var/log/foo.log", OversizePolicy: "drop"},
+		{Type: FileType, Path: "/var/log/manifest", IgnorePreExisting: true, ManifestFormat: "lines"},
+		{Type: FileType, Path: "/logs/*/app.log", PathTagsPattern: `^/logs/tenant-([^/]+)/`},
+		{Type: FileType, Path: "/logs/*/app.log", PathTagsPattern: `(?P<tenant>`},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: MultiLine, Pattern: "[0-9]", FlushTimeout: -1}}},
+		{Type: FileType, Path: "/var/log/foo.log", ProcessingRules: []ProcessingRule{{Name: "foo", Type: MultiLine, Pattern: "[0-9]", MaxSize: -1}}},
 		{Type: DockerType, ProcessingRules: []ProcessingRule{{Name: "foo"}}},
 		{Type: DockerType, ProcessingRules: []ProcessingRule{{Name: "foo", Type: "bar"}}},
 		{Type: DockerType, ProcessingRules: []ProcessingRule{{Name: "foo", Type: ExcludeAtMatch}}},
@@ -41,6 +122,11 @@ func TestValidateShouldFailWithInvalidConfigs(t *testing.T) {
 		{Type: DockerType, ProcessingRules: []ProcessingRule{{Type: ExcludeAtMatch, Pattern: ".*"}}},
 		{Type: DockerType, ProcessingRules: []ProcessingRule{{Type: ExcludeAtMatch}}},
 		{Type: DockerType, ProcessingRules: []ProcessingRule{{Pattern: ".*"}}},
+		{Type: DockerType, ProcessingRules: []ProcessingRule{{Name: "foo", Type: MaskJSONKeys}}},
+		{Type: DockerType, Tee: []TeeConfig{{Endpoints: []client.Endpoint{{Host: "foo"}}}}},
+		{Type: DockerType, Tee: []TeeConfig{{Name: "foo"}}},
+		{Type: DockerType, Tee: []TeeConfig{{Name: "foo", Endpoints: []client.Endpoint{{Host: "foo"}}, ProcessingRules: []ProcessingRule{{Name: "foo", Type: ExcludeAtMatch}}}}},
+		{Type: DockerType, Tee: []TeeConfig{{Name: "foo", Endpoints: []client.Endpoint{{Host: "foo"}}, ProcessingRules: []ProcessingRule{{Name: "foo", Type: MultiLine, Pattern: ".*"}}}}},
 	}
 
 	for _, config := range invalidConfigs {
@@ -49,6 +135,23 @@ func TestValidateShouldFailWithInvalidConfigs(t *testing.T) {
 	}
 }
 
+func TestSocketPermissions(t *testing.T) {
+	mode, err := (&LogsConfig{}).SocketPermissions()
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultSocketMode, mode)
+
+	mode, err = (&LogsConfig{SocketMode: "0660"}).SocketPermissions()
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0660), mode)
+
+	mode, err = (&LogsConfig{SocketMode: "600"}).SocketPermissions()
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), mode)
+
+	_, err = (&LogsConfig{SocketMode: "0999"}).SocketPermissions()
+	assert.NotNil(t, err)
+}
+
 func TestCompileShouldSucceedWithValidRules(t *testing.T) {
 	rules := []ProcessingRule{{Pattern: "[[:alnum:]]{5}", Type: IncludeAtMatch}}
 	config := &LogsConfig{ProcessingRules: rules}
@@ -58,6 +161,39 @@ func TestCompileShouldSucceedWithValidRules(t *testing.T) {
 	assert.True(t, rules[0].Reg.MatchString("abcde"))
 }
 
+func TestCompileShouldCompileTeeRules(t *testing.T) {
+	rules := []ProcessingRule{{Pattern: "[[:alnum:]]{5}", Type: MaskSequences, ReplacePlaceholder: "[masked]"}}
+	config := &LogsConfig{Tee: []TeeConfig{{ProcessingRules: rules}}}
+	err := config.Compile()
+	assert.Nil(t, err)
+	assert.NotNil(t, rules[0].Reg)
+	assert.Equal(t, []byte("[masked]"), rules[0].ReplacePlaceholderBytes)
+}
+
+func TestCompileShouldCompileAccessLogRules(t *testing.T) {
+	rules := []ProcessingRule{{Type: ParseAccessLog, Format: "nginx"}}
+	config := &LogsConfig{ProcessingRules: rules}
+	err := config.Compile()
+	assert.Nil(t, err)
+	assert.NotNil(t, rules[0].Reg)
+	assert.Contains(t, rules[0].Reg.SubexpNames(), AccessLogStatusCode)
+}
+
+func TestCompileShouldLowerCaseTheStatusMapping(t *testing.T) {
+	rules := []ProcessingRule{{Type: ParseStatus, Pattern: `\[(\w+)\]`, StatusMapping: map[string]string{"SEV1": "critical"}}}
+	config := &LogsConfig{ProcessingRules: rules}
+	err := config.Compile()
+	assert.Nil(t, err)
+	assert.NotNil(t, rules[0].Reg)
+	assert.Equal(t, map[string]string{"sev1": "critical"}, rules[0].StatusAliases)
+
+	// the rules of the JSON logs have no pattern
+	rules = []ProcessingRule{{Type: ParseStatus, SeverityField: "level"}}
+	config = &LogsConfig{ProcessingRules: rules}
+	assert.Nil(t, config.Compile())
+	assert.Nil(t, rules[0].Reg)
+}
+
 func TestCompileShouldFailWithInvalidRules(t *testing.T) {
 	invalidRules := []ProcessingRule{
 		{Type: IncludeAtMatch, Pattern: "(?=abf)"},
@@ -70,3 +206,32 @@ func TestCompileShouldFailWithInvalidRules(t *testing.T) {
 		assert.Nil(t, rule.Reg)
 	}
 }
+
+func TestEqualIgnoresCompiledRules(t *testing.T) {
+	newConfig := func() *LogsConfig {
+		return &LogsConfig{
+			Type:            FileType,
+			Path:            "/var/log/foo.log",
+			ProcessingRules: []ProcessingRule{{Name: "foo", Type: MaskSequences, Pattern: "[0-9]+", ReplacePlaceholder: "[masked]"}},
+			Tee:             []TeeConfig{{Name: "foo", ProcessingRules: []ProcessingRule{{Name: "bar", Type: ExcludeAtMatch, Pattern: "bar"}}}},
+		}
+	}
+
+	config := newConfig()
+	assert.Nil(t, config.Compile())
+	assert.True(t, config.Equal(newConfig()))
+	assert.NotNil(t, config.ProcessingRules[0].Reg)
+	assert.NotNil(t, config.Tee[0].ProcessingRules[0].Reg)
+
+	other := newConfig()
+	other.ProcessingRules[0].Pattern = "[a-z]+"
+	assert.False(t, config.Equal(other))
+
+	other = newConfig()
+	other.Tee[0].ProcessingRules[0].Pattern = "baz"
+	assert.False(t, config.Equal(other))
+
+	other = newConfig()
+	other.StartPosition = BeginningStartPosition
+	assert.False(t, config.Equal(other))
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// SecretResolver returns the secret of handle, the content of an ENC[handle] reference.
+type SecretResolver func(handle string) (string, error)
+
+// envVarReference matches the references to environment variables, ${NAME} or ${NAME:-default},
+// the references preceded by another $ are escaped.
+var envVarReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Interpolate expands the references to environment variables found in the string fields of the config,
+// ${NAME} is replaced by the value of NAME and ${NAME:-default} by default when NAME is not set or empty,
+// $${NAME} is left as ${NAME}. The fields whose whole value is an ENC[handle] reference are replaced
+// by the secret of handle returned by resolveSecret, nil when no secret backend is configured.
+// Returns an error when a variable without default is not set or when a secret can not be resolved,
+// the config is then invalid.
+func (c *LogsConfig) Interpolate(resolveSecret SecretResolver) error {
+	c.interpolationErr = interpolateValue(reflect.ValueOf(c).Elem(), "", resolveSecret)
+	return c.interpolationErr
+}
+
+// interpolateValue interpolates the strings held by v, whose name is used in the errors.
+func interpolateValue(v reflect.Value, name string, resolveSecret SecretResolver) error {
+	switch v.Kind() {
+	case reflect.String:
+		value, err := interpolate(v.String(), resolveSecret)
+		if err != nil {
+			return fmt.Errorf("could not interpolate %s: %v", name, err)
+		}
+		v.SetString(value)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := interpolateValue(v.Index(i), fmt.Sprintf("%s[%d]", name, i), resolveSecret); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				// the field is not exported
+				continue
+			}
+			fieldName := configKey(field)
+			if name != "" {
+				fieldName = name + "." + fieldName
+			}
+			if err := interpolateValue(v.Field(i), fieldName, resolveSecret); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// configKey returns the key of field in the configs.
+func configKey(field reflect.StructField) string {
+	if tag := field.Tag.Get("mapstructure"); tag != "" {
+		return strings.Split(tag, ",")[0]
+	}
+	return strings.ToLower(field.Name)
+}
+
+// interpolate returns value with its references to environment variables expanded,
+// or the secret it references.
+func interpolate(value string, resolveSecret SecretResolver) (string, error) {
+	if handle, isSecret := secretHandle(value); isSecret {
+		if resolveSecret == nil {
+			return "", fmt.Errorf("secret %s can not be resolved without secret backend", handle)
+		}
+		secret, err := resolveSecret(handle)
+		if err != nil {
+			return "", fmt.Errorf("could not resolve secret %s: %v", handle, err)
+		}
+		return secret, nil
+	}
+	var err error
+	value = envVarReference.ReplaceAllStringFunc(value, func(reference string) string {
+		if strings.HasPrefix(reference, "$$") {
+			return reference[1:]
+		}
+		match := envVarReference.FindStringSubmatch(reference)
+		name, hasDefault, defaultValue := match[1], match[2] != "", match[3]
+		envValue, isSet := os.LookupEnv(name)
+		switch {
+		case envValue != "":
+			return envValue
+		case hasDefault:
+			return defaultValue
+		case isSet:
+			return ""
+		}
+		if err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return reference
+	})
+	return value, err
+}
+
+// secretHandle returns the handle of the secret referenced by value if it is an ENC[handle] reference.
+func secretHandle(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "ENC[") && strings.HasSuffix(value, "]") {
+		return value[len("ENC[") : len(value)-1], true
+	}
+	return "", false
+}
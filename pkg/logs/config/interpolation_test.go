@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+)
+
+func resolveTestSecret(handle string) (string, error) {
+	if handle == "token" {
+		return "s3cr3t", nil
+	}
+	return "", fmt.Errorf("unknown handle")
+}
+
+func TestInterpolateExpandsTheEnvironmentVariables(t *testing.T) {
+	os.Setenv("DD_TEST_LOG_DIR", "/var/log/app")
+	os.Setenv("DD_TEST_EMPTY", "")
+	defer os.Unsetenv("DD_TEST_LOG_DIR")
+	defer os.Unsetenv("DD_TEST_EMPTY")
+
+	config := &LogsConfig{
+		Type:    FileType,
+		Path:    "${DD_TEST_LOG_DIR}/*.log",
+		Service: "${DD_TEST_SERVICE:-app}",
+		Source:  "${DD_TEST_EMPTY:-go}${DD_TEST_EMPTY}",
+		Tags:    []string{"dir:${DD_TEST_LOG_DIR}", "escaped:$${DD_TEST_LOG_DIR}", "price:$5"},
+		ProcessingRules: []ProcessingRule{
+			{Type: MaskSequences, Name: "mask", Pattern: "user=(\\w+)", ReplacePlaceholder: "user=${1}"},
+		},
+	}
+	assert.Nil(t, config.Interpolate(resolveTestSecret))
+	assert.Equal(t, "/var/log/app/*.log", config.Path)
+	assert.Equal(t, "app", config.Service)
+	assert.Equal(t, "go", config.Source)
+	assert.Equal(t, []string{"dir:/var/log/app", "escaped:${DD_TEST_LOG_DIR}", "price:$5"}, config.Tags)
+	// the references to the groups of the patterns are not environment variables
+	assert.Equal(t, "user=${1}", config.ProcessingRules[0].ReplacePlaceholder)
+	assert.Nil(t, config.Validate())
+}
+
+func TestInterpolateResolvesTheSecrets(t *testing.T) {
+	config := &LogsConfig{
+		Type: FileType,
+		Path: "/var/log/app.log",
+		Tee: []TeeConfig{
+			{Name: "siem", Endpoints: []client.Endpoint{{APIKey: " ENC[token] ", Host: "ENC[token]-host"}}},
+		},
+	}
+	assert.Nil(t, config.Interpolate(resolveTestSecret))
+	assert.Equal(t, "s3cr3t", config.Tee[0].Endpoints[0].APIKey)
+	// only the whole values are references
+	assert.Equal(t, "ENC[token]-host", config.Tee[0].Endpoints[0].Host)
+}
+
+func TestInterpolateInvalidatesTheConfigOnUnresolvedReferences(t *testing.T) {
+	config := &LogsConfig{Type: FileType, Path: "${DD_TEST_UNSET_LOG_DIR}/app.log"}
+	err := config.Interpolate(resolveTestSecret)
+	assert.EqualError(t, err, "could not interpolate path: environment variable DD_TEST_UNSET_LOG_DIR is not set")
+	assert.Equal(t, err, config.Validate())
+
+	config = &LogsConfig{Type: FileType, Path: "/var/log/app.log", Tee: []TeeConfig{{Endpoints: []client.Endpoint{{APIKey: "ENC[unknown]"}}}}}
+	err = config.Interpolate(resolveTestSecret)
+	assert.EqualError(t, err, "could not interpolate tee[0].endpoints[0].api_key: could not resolve secret unknown: unknown handle")
+	assert.NotNil(t, config.Validate())
+
+	config = &LogsConfig{Type: FileType, Path: "/var/log/app.log", Service: "ENC[token]"}
+	assert.EqualError(t, config.Interpolate(nil), "could not interpolate service: secret token can not be resolved without secret backend")
+}
@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter caps the number of logs per second of a source with a token bucket,
+// the bucket holds one second of logs so that short bursts are allowed.
+// A nil limiter allows all the logs.
+type RateLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+// NewRateLimiter returns a new limiter allowing rate logs per second, or nil if rate is not positive.
+func NewRateLimiter(rate float64) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	capacity := math.Max(rate, 1)
+	return &RateLimiter{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+		now:      time.Now,
+	}
+}
+
+// Allow returns true if a log can be sent now, false if it must be dropped.
+func (l *RateLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.tokens = math.Min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
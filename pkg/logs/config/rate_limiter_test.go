@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterAllowsBurstsThenRate(t *testing.T) {
+	limiter := NewRateLimiter(10)
+	now := time.Now()
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+
+	// a burst of one second of logs is allowed
+	for i := 0; i < 10; i++ {
+		assert.True(t, limiter.Allow())
+	}
+	assert.False(t, limiter.Allow())
+
+	// one log is allowed every 100ms
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow())
+
+	// the bucket does not hold more than one second of logs
+	now = now.Add(time.Minute)
+	for i := 0; i < 10; i++ {
+		assert.True(t, limiter.Allow())
+	}
+	assert.False(t, limiter.Allow())
+}
+
+func TestRateLimiterBelowOneLogPerSecond(t *testing.T) {
+	limiter := NewRateLimiter(0.5)
+	now := time.Now()
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow())
+	now = now.Add(time.Second)
+	assert.False(t, limiter.Allow())
+	now = now.Add(time.Second)
+	assert.True(t, limiter.Allow())
+}
+
+func TestNilRateLimiterAllowsAll(t *testing.T) {
+	assert.Nil(t, NewRateLimiter(0))
+	var limiter *RateLimiter
+	assert.True(t, limiter.Allow())
+	assert.Nil(t, NewLogSource("", &LogsConfig{}).RateLimiter)
+	assert.NotNil(t, NewLogSource("", &LogsConfig{LogsPerSecond: 1}).RateLimiter)
+}
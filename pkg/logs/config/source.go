@@ -7,18 +7,30 @@ package config
 
 import (
 	"sync"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 )
 
 // LogSource holds a reference to an integration name and a log configuration, and allows to track errors and
 // successful operations on it. Both name and configuration are static for now and determined at creation time.
 // Changing the status is designed to be thread safe.
 type LogSource struct {
+	// sequence is the number of messages of the source processed so far,
+	// it comes first to be 64-bit aligned for atomic operations on 32-bit platforms.
+	sequence uint64
+	// removed is 1 while the source is removed from the sources collected
+	removed  int32
 	Name     string
 	Config   *LogsConfig
 	Status   *LogStatus
 	inputs   map[string]bool
 	lock     *sync.Mutex
 	Messages *Messages
+	// Throughput counts the logs of the source flowing through the pipelines.
+	Throughput *metrics.ThroughputCounter
+	// RateLimiter caps the logs per second of the source, it is nil when they are not capped.
+	RateLimiter *RateLimiter
 	// sourceType is the type of the source that we are tailing whereas Config.Type is the type of the tailer
 	// that reads log lines for this source. E.g, a sourceType == containerd and Config.Type == file means that
 	// the agent is tailing a file to read logs of a containerd container
@@ -27,14 +39,19 @@ type LogSource struct {
 
 // NewLogSource creates a new log source.
 func NewLogSource(name string, config *LogsConfig) *LogSource {
-	return &LogSource{
-		Name:     name,
-		Config:   config,
-		Status:   NewLogStatus(),
-		inputs:   make(map[string]bool),
-		lock:     &sync.Mutex{},
-		Messages: NewMessages(),
+	source := &LogSource{
+		Name:       name,
+		Config:     config,
+		Status:     NewLogStatus(),
+		inputs:     make(map[string]bool),
+		lock:       &sync.Mutex{},
+		Messages:   NewMessages(),
+		Throughput: metrics.NewThroughputCounter(),
+	}
+	if config != nil {
+		source.RateLimiter = NewRateLimiter(config.LogsPerSecond)
 	}
+	return source
 }
 
 // AddInput registers an input as being handled by this source.
@@ -62,6 +79,17 @@ func (s *LogSource) GetInputs() []string {
 	return inputs
 }
 
+// NextSequence returns the sequence number of the next message of the source,
+// the numbers start at 1 when the source is created.
+func (s *LogSource) NextSequence() uint64 {
+	return atomic.AddUint64(&s.sequence, 1)
+}
+
+// IsRemoved returns true while the source is removed from the sources collected.
+func (s *LogSource) IsRemoved() bool {
+	return atomic.LoadInt32(&s.removed) == 1
+}
+
 // SetSourceType sets a format that give information on how the source lines should be parsed
 func (s *LogSource) SetSourceType(sourceType string) {
 	s.lock.Lock()
@@ -28,6 +28,13 @@ func (s *LogSourceSuite) TestInputs() {
 
 }
 
+func (s *LogSourceSuite) TestNextSequence() {
+	s.source = NewLogSource("", nil)
+	s.Equal(uint64(1), s.source.NextSequence())
+	s.Equal(uint64(2), s.source.NextSequence())
+	s.Equal(uint64(1), NewLogSource("", nil).NextSequence())
+}
+
 func TestTrackerSuite(t *testing.T) {
 	suite.Run(t, new(LogSourceSuite))
 }
@@ -7,6 +7,7 @@ package config
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // LogSources stores a list of log sources.
@@ -15,6 +16,8 @@ type LogSources struct {
 	sources       []*LogSource
 	addedByType   map[string]chan *LogSource
 	removedByType map[string]chan *LogSource
+	// removedHandlers are called with the sources removed
+	removedHandlers []func(source *LogSource)
 }
 
 // NewLogSources creates a new log sources.
@@ -29,6 +32,7 @@ func NewLogSources() *LogSources {
 func (s *LogSources) AddSource(source *LogSource) {
 	s.mu.Lock()
 	s.sources = append(s.sources, source)
+	atomic.StoreInt32(&source.removed, 0)
 	if source.Config == nil || source.Config.Validate() != nil {
 		s.mu.Unlock()
 		return
@@ -53,11 +57,27 @@ func (s *LogSources) RemoveSource(source *LogSource) {
 		}
 	}
 	stream, streamExists := s.removedByType[source.Config.Type]
+	handlers := s.removedHandlers
 	s.mu.Unlock()
 
-	if sourceFound && streamExists {
+	if !sourceFound {
+		return
+	}
+	atomic.StoreInt32(&source.removed, 1)
+	if streamExists {
 		stream <- source
 	}
+	for _, handler := range handlers {
+		handler(source)
+	}
+}
+
+// OnRemoved registers handler to be called with each source removed once the inputs of its type are notified,
+// handler must not block.
+func (s *LogSources) OnRemoved(handler func(source *LogSource)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removedHandlers = append(s.removedHandlers, handler)
 }
 
 // GetAddedForType returns the new added sources matching the provided type.
@@ -93,3 +113,35 @@ func (s *LogSources) GetSources() []*LogSource {
 
 	return s.sources
 }
+
+// DiffSources compares the sources currently collected with the next ones, the sources defining
+// the same source in both are unchanged and taken from current so that they keep being collected
+// from their current position, the other sources of next are added and the ones of current removed.
+func DiffSources(current, next []*LogSource) (unchanged, added, removed []*LogSource) {
+	matched := make(map[*LogSource]bool)
+	for _, source := range current {
+		if nextSource := findSource(next, source, matched); nextSource != nil {
+			matched[nextSource] = true
+			unchanged = append(unchanged, source)
+		} else {
+			removed = append(removed, source)
+		}
+	}
+	for _, source := range next {
+		if !matched[source] {
+			added = append(added, source)
+		}
+	}
+	return unchanged, added, removed
+}
+
+// findSource returns the source of sources defining the same source as source
+// that has not been matched yet, or nil if there is none.
+func findSource(sources []*LogSource, source *LogSource, matched map[*LogSource]bool) *LogSource {
+	for _, src := range sources {
+		if !matched[src] && src.Name == source.Name && src.Config.Equal(source.Config) {
+			return src
+		}
+	}
+	return nil
+}
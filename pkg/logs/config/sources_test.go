@@ -83,3 +83,40 @@ func TestGetRemovedForType(t *testing.T) {
 	s := <-stream
 	assert.Equal(t, s, source)
 }
+
+func TestOnRemoved(t *testing.T) {
+	sources := NewLogSources()
+	source := NewLogSource("foo", &LogsConfig{Type: "foo"})
+	var removed []*LogSource
+	sources.OnRemoved(func(source *LogSource) {
+		removed = append(removed, source)
+	})
+
+	// the sources not collected are not removed
+	sources.RemoveSource(source)
+	assert.Empty(t, removed)
+	assert.False(t, source.IsRemoved())
+
+	sources.AddSource(source)
+	sources.RemoveSource(source)
+	assert.Equal(t, []*LogSource{source}, removed)
+	assert.True(t, source.IsRemoved())
+
+	sources.AddSource(source)
+	assert.False(t, source.IsRemoved())
+}
+
+func TestDiffSources(t *testing.T) {
+	foo := NewLogSource("foo", &LogsConfig{Type: FileType, Path: "/var/log/foo.log"})
+	bar := NewLogSource("bar", &LogsConfig{Type: FileType, Path: "/var/log/bar.log"})
+	baz := NewLogSource("baz", &LogsConfig{Type: TCPType, Port: 1234})
+	newFoo := NewLogSource("foo", &LogsConfig{Type: FileType, Path: "/var/log/foo.log"})
+	newBar := NewLogSource("bar", &LogsConfig{Type: FileType, Path: "/var/log/bar/*.log"})
+	qux := NewLogSource("qux", &LogsConfig{Type: UDPType, Port: 1234})
+
+	unchanged, added, removed := DiffSources([]*LogSource{foo, bar, baz}, []*LogSource{newBar, newFoo, qux})
+	// the unchanged sources are the ones currently collected
+	assert.Equal(t, []*LogSource{foo}, unchanged)
+	assert.Equal(t, []*LogSource{newBar, qux}, added)
+	assert.Equal(t, []*LogSource{bar, baz}, removed)
+}
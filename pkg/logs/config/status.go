@@ -18,11 +18,23 @@ const (
 	isError
 )
 
+// States of the collection of a source, as reported by its inputs.
+const (
+	// SourcePending is the state of a source none of the inputs started collecting yet.
+	SourcePending = "pending"
+	// SourceRunning is the state of a source whose logs are collected.
+	SourceRunning = "running"
+	// SourceError is the state of a source whose last attempt to collect the logs failed.
+	SourceError = "error"
+)
+
 // LogStatus tracks errors and success.
 type LogStatus struct {
 	status status
 	err    string
-	mu     *sync.Mutex
+	// lastErr is the message of the last error, kept once the source recovered
+	lastErr string
+	mu      *sync.Mutex
 }
 
 // NewLogStatus creates a new log status.
@@ -47,24 +59,54 @@ func (s *LogStatus) Error(err error) {
 	defer s.mu.Unlock()
 	s.status = isError
 	s.err = fmt.Sprintf("Error: %s", err.Error())
+	s.lastErr = err.Error()
 }
 
 // IsPending returns whether the current status is not yet determined.
 func (s *LogStatus) IsPending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.status == isPending
 }
 
 // IsSuccess returns whether the current status is a success.
 func (s *LogStatus) IsSuccess() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.status == isSuccess
 }
 
 // IsError returns whether the current status is an error.
 func (s *LogStatus) IsError() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.status == isError
 }
 
 // GetError returns the error.
 func (s *LogStatus) GetError() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.err
 }
+
+// State returns the state of the collection of the source, one of pending, running or error.
+func (s *LogStatus) State() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.status {
+	case isSuccess:
+		return SourceRunning
+	case isError:
+		return SourceError
+	default:
+		return SourcePending
+	}
+}
+
+// LastError returns the message of the last error, even if the source recovered since, empty if none.
+func (s *LogStatus) LastError() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
@@ -23,6 +23,8 @@ func (s *LogStatusSuite) TestPending() {
 	s.False(s.status.IsSuccess())
 	s.False(s.status.IsError())
 	s.Equal("", s.status.GetError())
+	s.Equal(SourcePending, s.status.State())
+	s.Equal("", s.status.LastError())
 }
 
 func (s *LogStatusSuite) TestSuccess() {
@@ -32,6 +34,7 @@ func (s *LogStatusSuite) TestSuccess() {
 	s.True(s.status.IsSuccess())
 	s.False(s.status.IsError())
 	s.Equal("", s.status.GetError())
+	s.Equal(SourceRunning, s.status.State())
 }
 
 func (s *LogStatusSuite) TestError() {
@@ -41,6 +44,18 @@ func (s *LogStatusSuite) TestError() {
 	s.False(s.status.IsSuccess())
 	s.True(s.status.IsError())
 	s.Equal("Error: bar", s.status.GetError())
+	s.Equal(SourceError, s.status.State())
+	s.Equal("bar", s.status.LastError())
+}
+
+func (s *LogStatusSuite) TestRecovery() {
+	s.status = NewLogStatus()
+	s.status.Error(errors.New("bar"))
+	s.status.Success()
+	s.Equal(SourceRunning, s.status.State())
+	s.Equal("", s.status.GetError())
+	// the last error is kept once recovered
+	s.Equal("bar", s.status.LastError())
 }
 
 func TestLogStatusSuite(t *testing.T) {
@@ -0,0 +1,157 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampFormat is a format of timestamp, either the layout of the dates parsed with the time package
+// or the unit of the numbers of seconds since the epoch, along with the pattern matching them in the logs.
+type timestampFormat struct {
+	layout  string
+	unit    time.Duration
+	pattern string
+}
+
+// timestampFormats maps the predefined timestamp formats to their layout and pattern.
+var timestampFormats = map[string]timestampFormat{
+	"rfc3339": {layout: time.RFC3339Nano, pattern: `\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})`},
+	"rfc3164": {layout: time.Stamp, pattern: `[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`},
+	"common":  {layout: "02/Jan/2006:15:04:05 -0700", pattern: `\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`},
+	"unix":    {unit: time.Second, pattern: `\b\d{10}(?:\.\d+)?\b`},
+	"unix_ms": {unit: time.Millisecond, pattern: `\b\d{13}\b`},
+}
+
+// strftimeDirectives maps the strftime directives to their layout and pattern.
+var strftimeDirectives = map[byte]timestampFormat{
+	'Y': {layout: "2006", pattern: `\d{4}`},
+	'y': {layout: "06", pattern: `\d{2}`},
+	'm': {layout: "01", pattern: `\d{2}`},
+	'd': {layout: "02", pattern: `\d{2}`},
+	'e': {layout: "_2", pattern: `[ \d]\d`},
+	'j': {layout: "002", pattern: `\d{3}`},
+	'H': {layout: "15", pattern: `\d{2}`},
+	'I': {layout: "03", pattern: `\d{2}`},
+	'M': {layout: "04", pattern: `\d{2}`},
+	'S': {layout: "05", pattern: `\d{2}`},
+	'f': {layout: "000000", pattern: `\d{6}`},
+	'p': {layout: "PM", pattern: `[AP]M`},
+	'b': {layout: "Jan", pattern: `[A-Z][a-z]{2}`},
+	'B': {layout: "January", pattern: `[A-Z][a-z]+`},
+	'a': {layout: "Mon", pattern: `[A-Z][a-z]{2}`},
+	'A': {layout: "Monday", pattern: `[A-Z][a-z]+`},
+	'z': {layout: "-0700", pattern: `(?:Z|[+-]\d{4})`},
+	'Z': {layout: "MST", pattern: `[A-Z]{3,5}`},
+	'F': {layout: "2006-01-02", pattern: `\d{4}-\d{2}-\d{2}`},
+	'T': {layout: "15:04:05", pattern: `\d{2}:\d{2}:\d{2}`},
+	'%': {layout: "%", pattern: `%`},
+}
+
+// TimestampParser extracts the time of the logs from their content.
+type TimestampParser struct {
+	reg      *regexp.Regexp
+	format   timestampFormat
+	location *time.Location
+}
+
+// Parse returns the time of the first timestamp found in content, false if there is none.
+// The dates without year are set in the current year, or in the previous one if they would be in the future.
+func (p *TimestampParser) Parse(content []byte) (time.Time, bool) {
+	matches := p.reg.FindSubmatch(content)
+	if matches == nil {
+		return time.Time{}, false
+	}
+	value := string(matches[len(matches)-1])
+	if p.format.unit != 0 {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number <= 0 {
+			return time.Time{}, false
+		}
+		return time.Unix(0, int64(number*float64(p.format.unit))), true
+	}
+	timestamp, err := time.ParseInLocation(p.format.layout, value, p.location)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if timestamp.Year() == 0 {
+		now := time.Now().In(p.location)
+		timestamp = timestamp.AddDate(now.Year(), 0, 0)
+		if timestamp.After(now.Add(24 * time.Hour)) {
+			timestamp = timestamp.AddDate(-1, 0, 0)
+		}
+	}
+	return timestamp, true
+}
+
+// compileTimestampRule returns the parser of the timestamps written in the format of rule, either a predefined format
+// or a strftime format, in the timezone of rule, UTC by default. The timestamps are located by the pattern of rule,
+// captured by its last group if it has any, or by the pattern of the format otherwise.
+func compileTimestampRule(rule ProcessingRule) (*TimestampParser, error) {
+	format, exists := timestampFormats[rule.Format]
+	if !exists {
+		if !strings.Contains(rule.Format, "%") {
+			return nil, fmt.Errorf("format %q is not supported, use a predefined format or a strftime format", rule.Format)
+		}
+		var err error
+		if format, err = compileStrftimeFormat(rule.Format); err != nil {
+			return nil, err
+		}
+	}
+
+	pattern := rule.Pattern
+	if pattern == "" {
+		pattern = format.pattern
+	}
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var location *time.Location
+	switch rule.Timezone {
+	case "", "UTC":
+		location = time.UTC
+	case "local":
+		location = time.Local
+	default:
+		if location, err = time.LoadLocation(rule.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	return &TimestampParser{
+		reg:      reg,
+		format:   format,
+		location: location,
+	}, nil
+}
+
+// compileStrftimeFormat turns a strftime format into the layout of the time package and a pattern matching its dates.
+func compileStrftimeFormat(format string) (timestampFormat, error) {
+	var layout, pattern strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout.WriteByte(format[i])
+			pattern.WriteString(regexp.QuoteMeta(format[i : i+1]))
+			continue
+		}
+		if i+1 == len(format) {
+			return timestampFormat{}, fmt.Errorf("format %q ends with a single %%", format)
+		}
+		i++
+		directive, exists := strftimeDirectives[format[i]]
+		if !exists {
+			return timestampFormat{}, fmt.Errorf("directive %%%c of format %q is not supported", format[i], format)
+		}
+		layout.WriteString(directive.layout)
+		pattern.WriteString(directive.pattern)
+	}
+	return timestampFormat{layout: layout.String(), pattern: pattern.String()}, nil
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseWith(t *testing.T, rule ProcessingRule, content string) (time.Time, bool) {
+	parser, err := compileTimestampRule(rule)
+	assert.Nil(t, err)
+	return parser.Parse([]byte(content))
+}
+
+func TestTimestampParserWithPredefinedFormats(t *testing.T) {
+	expected := time.Date(2018, 10, 15, 8, 30, 0, 0, time.UTC)
+
+	timestamp, ok := parseWith(t, ProcessingRule{Format: "rfc3339"}, "level=info time=2018-10-15T10:30:00+02:00 hello")
+	assert.True(t, ok)
+	assert.True(t, expected.Equal(timestamp))
+
+	timestamp, ok = parseWith(t, ProcessingRule{Format: "common"}, `127.0.0.1 - - [15/Oct/2018:08:30:00 +0000] "GET / HTTP/1.1" 200 2`)
+	assert.True(t, ok)
+	assert.True(t, expected.Equal(timestamp))
+
+	timestamp, ok = parseWith(t, ProcessingRule{Format: "unix"}, "1539592200 hello")
+	assert.True(t, ok)
+	assert.True(t, expected.Equal(timestamp))
+
+	timestamp, ok = parseWith(t, ProcessingRule{Format: "unix_ms"}, "ts=1539592200500 hello")
+	assert.True(t, ok)
+	assert.True(t, expected.Add(500*time.Millisecond).Equal(timestamp))
+
+	_, ok = parseWith(t, ProcessingRule{Format: "rfc3339"}, "hello")
+	assert.False(t, ok)
+}
+
+func TestTimestampParserSetsTheYearOfTheDatesWithoutYear(t *testing.T) {
+	now := time.Now().UTC()
+	yesterday := now.Add(-24 * time.Hour).Truncate(time.Second)
+	timestamp, ok := parseWith(t, ProcessingRule{Format: "rfc3164"}, yesterday.Format(time.Stamp)+" host app: hello")
+	assert.True(t, ok)
+	assert.True(t, yesterday.Equal(timestamp))
+
+	// a date a few days ahead was written last year
+	ahead := now.Add(72 * time.Hour).Truncate(time.Second)
+	timestamp, ok = parseWith(t, ProcessingRule{Format: "rfc3164"}, ahead.Format(time.Stamp)+" host app: hello")
+	assert.True(t, ok)
+	assert.True(t, ahead.AddDate(-1, 0, 0).Equal(timestamp))
+}
+
+func TestTimestampParserWithStrftimeFormats(t *testing.T) {
+	rule := ProcessingRule{Format: "%Y-%m-%d %H:%M:%S,%f", Timezone: "Europe/Paris"}
+	timestamp, ok := parseWith(t, rule, "2018-10-15 10:30:00,123456 INFO hello")
+	assert.True(t, ok)
+	assert.True(t, time.Date(2018, 10, 15, 8, 30, 0, 123456000, time.UTC).Equal(timestamp))
+
+	// the pattern locates the timestamp when the format is ambiguous
+	rule = ProcessingRule{Format: "%d/%m/%Y %T", Pattern: `at (\S+ \S+)`}
+	timestamp, ok = parseWith(t, rule, "02/01/2006 00:00:00 started at 15/10/2018 08:30:00")
+	assert.True(t, ok)
+	assert.True(t, time.Date(2018, 10, 15, 8, 30, 0, 0, time.UTC).Equal(timestamp))
+}
+
+func TestTimestampParserAcrossDaylightSavingTimeTransitions(t *testing.T) {
+	rule := ProcessingRule{Format: "%Y-%m-%d %H:%M:%S", Timezone: "America/New_York"}
+	parse := func(content string) time.Time {
+		timestamp, ok := parseWith(t, rule, content)
+		assert.True(t, ok)
+		return timestamp.UTC()
+	}
+
+	// on 2018-03-11 the clocks skip from 02:00 EST to 03:00 EDT
+	assert.Equal(t, time.Date(2018, 3, 11, 6, 59, 59, 0, time.UTC), parse("2018-03-11 01:59:59 hello"))
+	assert.Equal(t, time.Date(2018, 3, 11, 7, 0, 0, 0, time.UTC), parse("2018-03-11 03:00:00 hello"))
+	// the times of the skipped hour do not exist, they are read with the offset before the transition
+	assert.Equal(t, time.Date(2018, 3, 11, 6, 30, 0, 0, time.UTC), parse("2018-03-11 02:30:00 hello"))
+
+	// on 2018-11-04 the clocks go back from 02:00 EDT to 01:00 EST
+	assert.Equal(t, time.Date(2018, 11, 4, 4, 30, 0, 0, time.UTC), parse("2018-11-04 00:30:00 hello"))
+	assert.Equal(t, time.Date(2018, 11, 4, 7, 30, 0, 0, time.UTC), parse("2018-11-04 02:30:00 hello"))
+	// the times of the repeated hour are ambiguous, they are read as the first occurrence, in EDT
+	assert.Equal(t, time.Date(2018, 11, 4, 5, 30, 0, 0, time.UTC), parse("2018-11-04 01:30:00 hello"))
+	// unless the timestamps carry their offset
+	rule.Format = "%Y-%m-%d %H:%M:%S %z"
+	assert.Equal(t, time.Date(2018, 11, 4, 6, 30, 0, 0, time.UTC), parse("2018-11-04 01:30:00 -0500 hello"))
+}
+
+func TestCompileTimestampRuleFailsWithInvalidRules(t *testing.T) {
+	for _, rule := range []ProcessingRule{
+		{},
+		{Format: "iso8601"},
+		{Format: "%Y-%Q"},
+		{Format: "%Y %"},
+		{Format: "rfc3339", Timezone: "Mars/Olympus_Mons"},
+		{Format: "rfc3339", Pattern: "("},
+	} {
+		_, err := compileTimestampRule(rule)
+		assert.NotNil(t, err)
+	}
+}
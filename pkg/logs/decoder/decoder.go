@@ -7,9 +7,12 @@ package decoder
 
 import (
 	"bytes"
+	"io"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/parser"
 )
 
@@ -19,11 +22,27 @@ var contentLenLimit = 256 * 1000
 // Input represents a list of bytes consumed by the Decoder
 type Input struct {
 	content []byte
+	// holdPendingContent asks the line handler to hold its pending content
+	// once all the previous inputs have been handled.
+	holdPendingContent bool
+	// flushPartialLine asks the decoder to handle the line it has started to decode
+	// even though it is not terminated by a line feed.
+	flushPartialLine bool
+	// frame indicates that content is a whole message which must not be split on line feeds.
+	frame bool
+	// synced is called once the messages of the previous inputs are sent to the output channel, or dropped.
+	synced func()
 }
 
 // NewInput returns a new input
 func NewInput(content []byte) *Input {
-	return &Input{content}
+	return &Input{content: content}
+}
+
+// NewFrameInput returns a new input holding a whole message,
+// it is used when the messages are delimited by the protocol rather than by line feeds.
+func NewFrameInput(content []byte) *Input {
+	return &Input{content: content, frame: true}
 }
 
 // Decoder splits raw data into lines and passes them to a lineHandler that emits outputs
@@ -33,24 +52,69 @@ type Decoder struct {
 
 	lineBuffer  *bytes.Buffer
 	lineHandler LineHandler
+
+	// maxLineLength is the length above which the lines are truncated and ended with truncationMarker,
+	// the rest of the line is discarded until the next line feed, 0 means the lines are split in chunks
+	// of contentLenLimit instead. discardedLen is the length of the part of the current line discarded.
+	maxLineLength    int
+	truncationMarker []byte
+	discardedLen     int
+
+	// transcoder transcodes the lines to UTF-8 when the content is in another encoding, nil otherwise
+	transcoder *transcoder
 }
 
 // InitializeDecoder returns a properly initialized Decoder
-func InitializeDecoder(source *config.LogSource, parser parser.Parser) *Decoder {
+func InitializeDecoder(source *config.LogSource, lineParser parser.Parser) *Decoder {
 	inputChan := make(chan *Input)
 	outputChan := make(chan *message.Message)
 
+	if source.Config.StripBOM {
+		lineParser = parser.NewBOMParser(lineParser)
+	}
+
 	var lineHandler LineHandler
 	for _, rule := range source.Config.ProcessingRules {
 		if rule.Type == config.MultiLine {
-			lineHandler = NewMultiLineHandler(outputChan, rule.Reg, defaultFlushTimeout, parser)
+			lineHandler = newMultiLineHandlerForRule(outputChan, rule, lineParser)
 		}
 	}
 	if lineHandler == nil {
-		lineHandler = NewSingleLineHandler(outputChan, parser)
+		lineHandler = NewSingleLineHandler(outputChan, lineParser)
 	}
 
-	return New(inputChan, outputChan, lineHandler)
+	decoder := New(inputChan, outputChan, lineHandler)
+	decoder.truncationMarker = []byte(config.LogsAgent.GetString("logs_config.truncation_marker"))
+	decoder.maxLineLength = maxLineLength(config.LogsAgent.GetInt("logs_config.max_line_length"), len(decoder.truncationMarker))
+	decoder.transcoder = newTranscoder(source.Config.Encoding)
+	return decoder
+}
+
+// maxLineLength returns the max length of the lines configured, lowered for the lines truncated
+// and ended with a marker of markerLen to stay below contentLenLimit, 0 if it is not positive.
+func maxLineLength(configured int, markerLen int) int {
+	switch {
+	case configured <= 0:
+		return 0
+	case configured+markerLen >= contentLenLimit:
+		return contentLenLimit - markerLen - 1
+	default:
+		return configured
+	}
+}
+
+// newMultiLineHandlerForRule returns a new MultiLineHandler applying the flush timeout and the max size of rule,
+// the max size can only lower the length limit of the content.
+func newMultiLineHandlerForRule(outputChan chan *message.Message, rule config.ProcessingRule, lineParser parser.Parser) *MultiLineHandler {
+	flushTimeout := defaultFlushTimeout
+	if rule.FlushTimeout > 0 {
+		flushTimeout = time.Duration(rule.FlushTimeout * float64(time.Second))
+	}
+	handler := NewMultiLineHandler(outputChan, rule.Reg, flushTimeout, lineParser)
+	if rule.MaxSize > 0 && rule.MaxSize < contentLenLimit {
+		handler.contentLenLimit = rule.MaxSize
+	}
+	return handler
 }
 
 // New returns an initialized Decoder
@@ -75,9 +139,74 @@ func (d *Decoder) Stop() {
 	close(d.InputChan)
 }
 
+// DetectByteOrder reads the byte order of the content from the byte order mark at the beginning of file
+// when its encoding does not tell it, so that it is known whatever the offset the file is read from,
+// it must be called before the decoder is started.
+func (d *Decoder) DetectByteOrder(file io.ReaderAt) {
+	if d.transcoder == nil || !d.transcoder.detectByteOrder {
+		return
+	}
+	head := make([]byte, d.transcoder.unitLen)
+	n, _ := file.ReadAt(head, 0)
+	d.transcoder.detect(head[:n])
+}
+
+// HoldPendingContent prevents the pending multi-line content from being sent when the flush timeout expires,
+// it is sent once carried over to another decoder or when the decoder is stopped.
+// It is used when the end of a rotated file is reached as the content may continue in the new file.
+func (d *Decoder) HoldPendingContent() {
+	d.InputChan <- &Input{holdPendingContent: true}
+}
+
+// CarryPendingContentTo moves the held multi-line content to next, which must not be started yet,
+// so that a message split between the end of a rotated file and the beginning of the new one is sent whole.
+func (d *Decoder) CarryPendingContentTo(next *Decoder) {
+	handler, isMultiLine := d.lineHandler.(*MultiLineHandler)
+	nextHandler, isNextMultiLine := next.lineHandler.(*MultiLineHandler)
+	if isMultiLine && isNextMultiLine {
+		handler.carryOver(nextHandler)
+	}
+}
+
+// FlushPartialLine lets the decoder handle the line it has started to decode once the previous inputs are decoded,
+// it is used when the input stops in the middle of a line which would otherwise never be sent.
+func (d *Decoder) FlushPartialLine() {
+	d.InputChan <- &Input{flushPartialLine: true}
+}
+
+// Sync calls done once the messages decoded from the previous inputs are sent to OutputChan, or dropped,
+// it is used to acknowledge the messages to their sender once they are handed to the pipeline.
+func (d *Decoder) Sync(done func()) {
+	d.InputChan <- &Input{synced: done}
+}
+
 // run lets the Decoder handle data coming from InputChan
 func (d *Decoder) run() {
 	for data := range d.InputChan {
+		if data.holdPendingContent {
+			if handler, isMultiLine := d.lineHandler.(*MultiLineHandler); isMultiLine {
+				handler.hold()
+			}
+			continue
+		}
+		if data.synced != nil {
+			if handler, ok := d.lineHandler.(syncLineHandler); ok {
+				handler.Sync(data.synced)
+			} else {
+				data.synced()
+			}
+			continue
+		}
+		if data.flushPartialLine {
+			if d.lineBuffer.Len() > 0 {
+				d.sendPartialLine()
+			}
+			continue
+		}
+		if data.frame {
+			d.decodeFrame(data.content)
+			continue
+		}
 		d.decodeIncomingData(data.content)
 	}
 	// finish to stop decoder
@@ -86,6 +215,14 @@ func (d *Decoder) run() {
 
 // decodeIncomingData splits raw data based on '\n', creates and processes new lines
 func (d *Decoder) decodeIncomingData(inBuf []byte) {
+	if d.transcoder != nil {
+		d.decodeEncodedData(inBuf)
+		return
+	}
+	if d.maxLineLength > 0 {
+		d.decodeIncomingDataWithMaxLineLength(inBuf)
+		return
+	}
 	i, j := 0, 0
 	n := len(inBuf)
 	maxj := contentLenLimit - d.lineBuffer.Len()
@@ -107,10 +244,173 @@ func (d *Decoder) decodeIncomingData(inBuf []byte) {
 	d.lineBuffer.Write(inBuf[i:j])
 }
 
-// sendLine copies content from lineBuffer which is passed to lineHandler
+// decodeIncomingDataWithMaxLineLength splits raw data based on '\n', the lines longer than maxLineLength
+// are truncated and the rest of their data is discarded until the next '\n', whatever the number of inputs
+// it spans, so that a single line can not grow the buffer beyond maxLineLength.
+func (d *Decoder) decodeIncomingDataWithMaxLineLength(inBuf []byte) {
+	for {
+		i := bytes.IndexByte(inBuf, '\n')
+		if i < 0 {
+			d.addToLine(inBuf)
+			return
+		}
+		d.addToLine(inBuf[:i])
+		d.sendLine()
+		inBuf = inBuf[i+1:] // +1 as we skip the `\n`
+	}
+}
+
+// decodeEncodedData splits raw data based on the line feeds of the encoding of the content,
+// the lines are transcoded to UTF-8 once split and the lines longer than contentLenLimit are split in chunks.
+func (d *Decoder) decodeEncodedData(inBuf []byte) {
+	d.lineBuffer.Write(inBuf)
+	d.transcoder.detect(d.lineBuffer.Bytes())
+	if d.maxLineLength > 0 {
+		d.decodeEncodedDataWithMaxLineLength()
+		return
+	}
+	for {
+		i := d.transcoder.indexLineFeed(d.lineBuffer.Bytes())
+		if i < 0 {
+			break
+		}
+		d.sendEncodedLine(i, len(d.transcoder.lineFeed))
+	}
+	if d.lineBuffer.Len() >= contentLenLimit {
+		// send the chunk because it is too long, cut at the boundary of a code unit
+		d.sendEncodedLine(contentLenLimit-contentLenLimit%d.transcoder.unitLen, 0)
+	}
+}
+
+// decodeEncodedDataWithMaxLineLength splits the content of lineBuffer based on the line feeds of its encoding,
+// the lines longer than maxLineLength bytes before being transcoded are truncated and the rest of their data
+// is discarded until the next line feed, so that a single line can not grow the buffer beyond maxLineLength.
+func (d *Decoder) decodeEncodedDataWithMaxLineLength() {
+	unitLen := d.transcoder.unitLen
+	// the part of the line kept is cut at the boundary of a code unit
+	maxLen := d.maxLineLength - d.maxLineLength%unitLen
+	if maxLen < unitLen {
+		maxLen = unitLen
+	}
+	for {
+		i := d.transcoder.indexLineFeed(d.lineBuffer.Bytes())
+		if i < 0 {
+			break
+		}
+		if i > maxLen {
+			d.discardEncoded(maxLen, i)
+			i = maxLen
+		}
+		d.sendEncodedLine(i, len(d.transcoder.lineFeed))
+	}
+	if excess := d.lineBuffer.Len() - maxLen; excess >= unitLen {
+		// the code unit split between this input and the next one is kept to find the next line feed
+		d.discardEncoded(maxLen, d.lineBuffer.Len()-excess%unitLen)
+	}
+}
+
+// discardEncoded removes the bytes of lineBuffer between from and to, and counts them as discarded.
+func (d *Decoder) discardEncoded(from int, to int) {
+	content := d.lineBuffer.Bytes()
+	copy(content[from:], content[to:])
+	d.lineBuffer.Truncate(len(content) - (to - from))
+	d.discardedLen += to - from
+}
+
+// sendEncodedLine transcodes the first lineLen bytes of lineBuffer and passes them to lineHandler,
+// they are followed by a line feed of lineFeedLen bytes, 0 if the line is not complete,
+// a line truncated is ended with the truncation marker.
+func (d *Decoder) sendEncodedLine(lineLen int, lineFeedLen int) {
+	content := d.transcoder.transcode(d.lineBuffer.Next(lineLen))
+	d.lineBuffer.Next(lineFeedLen)
+	rawDataLen := lineLen + lineFeedLen
+	if d.discardedLen > 0 {
+		content = append(content, d.truncationMarker...)
+		rawDataLen += d.discardedLen
+		d.discardedLen = 0
+		metrics.LogsTruncated.Add(1)
+	}
+	d.handleRaw(content, rawDataLen)
+}
+
+// addToLine adds content to the line decoded, up to maxLineLength, and discards the rest.
+func (d *Decoder) addToLine(content []byte) {
+	if room := d.maxLineLength - d.lineBuffer.Len(); len(content) > room {
+		d.lineBuffer.Write(content[:room])
+		d.discardedLen += len(content) - room
+		return
+	}
+	d.lineBuffer.Write(content)
+}
+
+// decodeFrame handles frame as one line, line feeds included, once the partial line decoded so far is handled,
+// the frame is cut to the length limit of the content.
+func (d *Decoder) decodeFrame(frame []byte) {
+	if d.lineBuffer.Len() > 0 {
+		d.sendPartialLine()
+	}
+	if d.transcoder != nil {
+		frame = d.transcoder.transcode(frame)
+	}
+	if len(frame) >= contentLenLimit {
+		frame = frame[:contentLenLimit-1]
+	}
+	d.lineHandler.Handle(frame)
+}
+
+// sendLine copies content from lineBuffer which is passed to lineHandler,
+// a line truncated is ended with the truncation marker.
 func (d *Decoder) sendLine() {
+	if d.transcoder != nil {
+		d.sendEncodedLine(d.lineBuffer.Len(), 0)
+		return
+	}
+	if d.discardedLen > 0 {
+		d.sendTruncatedLine(1)
+		return
+	}
 	content := make([]byte, d.lineBuffer.Len())
 	copy(content, d.lineBuffer.Bytes())
 	d.lineBuffer.Reset()
 	d.lineHandler.Handle(content)
 }
+
+// sendPartialLine passes the line decoded so far to lineHandler although its line feed has not been read,
+// along with its raw length which does not include any line feed.
+func (d *Decoder) sendPartialLine() {
+	if d.transcoder != nil {
+		d.sendEncodedLine(d.lineBuffer.Len(), 0)
+		return
+	}
+	if d.discardedLen > 0 {
+		d.sendTruncatedLine(0)
+		return
+	}
+	content := make([]byte, d.lineBuffer.Len())
+	copy(content, d.lineBuffer.Bytes())
+	d.lineBuffer.Reset()
+	d.handleRaw(content, len(content))
+}
+
+// sendTruncatedLine passes the part of the line kept ended with the truncation marker to lineHandler,
+// along with the length of the whole line, followed by a line feed of lineFeedLen bytes, 0 if it is not complete,
+// for its offset to account for the data discarded.
+func (d *Decoder) sendTruncatedLine(lineFeedLen int) {
+	content := make([]byte, 0, d.lineBuffer.Len()+len(d.truncationMarker))
+	content = append(content, d.lineBuffer.Bytes()...)
+	content = append(content, d.truncationMarker...)
+	rawDataLen := d.lineBuffer.Len() + d.discardedLen + lineFeedLen
+	d.lineBuffer.Reset()
+	d.discardedLen = 0
+	metrics.LogsTruncated.Add(1)
+	d.handleRaw(content, rawDataLen)
+}
+
+// handleRaw passes a line decoded from data of rawDataLen to lineHandler.
+func (d *Decoder) handleRaw(content []byte, rawDataLen int) {
+	if handler, ok := d.lineHandler.(rawLineHandler); ok {
+		handler.HandleRaw(content, rawDataLen)
+	} else {
+		d.lineHandler.Handle(content)
+	}
+}
@@ -6,10 +6,16 @@
 package decoder
 
 import (
+	"bytes"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/parser"
 )
 
 type MockLineHandler struct {
@@ -98,6 +104,67 @@ func TestDecodeIncomingData(t *testing.T) {
 	assert.Equal(t, "", d.lineBuffer.String())
 }
 
+func TestDecodeIncomingDataWithMaxLineLength(t *testing.T) {
+	h := NewMockLineHandler()
+	d := New(nil, nil, h)
+	d.maxLineLength = 10
+	d.truncationMarker = []byte("...TRUNCATED...")
+
+	// the lines shorter than the max are sent as is
+	d.decodeIncomingData([]byte("helloworld\nhello"))
+	assert.Equal(t, "helloworld", string(<-h.lineChan))
+	assert.Equal(t, "hello", d.lineBuffer.String())
+	d.decodeIncomingData([]byte(" you\n"))
+	assert.Equal(t, "hello you", string(<-h.lineChan))
+
+	// a line spanning multiple inputs is truncated and the next line is decoded from its beginning
+	d.decodeIncomingData([]byte("0123456"))
+	d.decodeIncomingData([]byte("789abc"))
+	d.decodeIncomingData([]byte(strings.Repeat("d", 10)))
+	assert.Equal(t, "0123456789", d.lineBuffer.String())
+	d.decodeIncomingData([]byte("ef\nnext\n"))
+	assert.Equal(t, "0123456789...TRUNCATED...", string(<-h.lineChan))
+	assert.Equal(t, "next", string(<-h.lineChan))
+
+	// a line far exceeding the max does not grow the buffer
+	d.decodeIncomingData([]byte(strings.Repeat("a", 10*contentLenLimit)))
+	assert.Equal(t, 10, d.lineBuffer.Len())
+	d.decodeIncomingData([]byte("\nafter\n"))
+	assert.Equal(t, "aaaaaaaaaa...TRUNCATED...", string(<-h.lineChan))
+	assert.Equal(t, "after", string(<-h.lineChan))
+	assert.Equal(t, "", d.lineBuffer.String())
+}
+
+func TestDecoderAccountsForTheDataDiscardedFromTruncatedLines(t *testing.T) {
+	config.LogsAgent.Set("logs_config.max_line_length", 10)
+	config.LogsAgent.Set("logs_config.truncation_marker", "[cut]")
+	defer config.LogsAgent.Set("logs_config.max_line_length", 0)
+	defer config.LogsAgent.Set("logs_config.truncation_marker", "...TRUNCATED...")
+
+	for _, rules := range [][]config.ProcessingRule{nil, {{Type: config.MultiLine, Reg: regexp.MustCompile("^[a-z]"), FlushTimeout: 0.01}}} {
+		source := config.NewLogSource("", &config.LogsConfig{ProcessingRules: rules})
+		d := InitializeDecoder(source, parser.NoopParser)
+		d.Start()
+
+		d.InputChan <- NewInput([]byte("0123456789abc"))
+		d.InputChan <- NewInput([]byte("def\nnext\n"))
+		output := <-d.OutputChan
+		assert.Equal(t, "0123456789[cut]", string(output.Content))
+		assert.Equal(t, len("0123456789abcdef\n"), output.RawDataLen)
+		output = <-d.OutputChan
+		assert.Equal(t, "next", string(output.Content))
+		assert.Equal(t, len("next\n"), output.RawDataLen)
+		d.Stop()
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	assert.Equal(t, 0, maxLineLength(0, 15))
+	assert.Equal(t, 0, maxLineLength(-1, 15))
+	assert.Equal(t, 1000, maxLineLength(1000, 15))
+	assert.Equal(t, contentLenLimit-16, maxLineLength(contentLenLimit, 15))
+}
+
 func TestDecoderLifeCycle(t *testing.T) {
 	h := NewMockLineHandler()
 	d := New(nil, nil, h)
@@ -120,3 +187,260 @@ func TestDecoderLifeCycle(t *testing.T) {
 		assert.Fail(t, "LineHandler should be stopped")
 	}
 }
+
+func TestDecoderFlushPartialLine(t *testing.T) {
+	h := NewMockLineHandler()
+	d := New(make(chan *Input), nil, h)
+	d.Start()
+
+	d.InputChan <- NewInput([]byte("hello\nwor"))
+	d.FlushPartialLine()
+	assert.Equal(t, "hello", string(<-h.lineChan))
+	assert.Equal(t, "wor", string(<-h.lineChan))
+
+	// there is nothing to flush at the end of a line
+	d.InputChan <- NewInput([]byte("ld\n"))
+	d.FlushPartialLine()
+	assert.Equal(t, "ld", string(<-h.lineChan))
+	d.Stop()
+	_, isOpen := <-h.lineChan
+	assert.False(t, isOpen)
+}
+
+func TestDecoderFlushPartialLineReportsItsRawLength(t *testing.T) {
+	multiLine := []config.ProcessingRule{{Type: config.MultiLine, Reg: regexp.MustCompile("^[0-9]"), FlushTimeout: 0.01}}
+	for _, rules := range [][]config.ProcessingRule{nil, multiLine} {
+		d := InitializeDecoder(config.NewLogSource("", &config.LogsConfig{ProcessingRules: rules}), parser.NoopParser)
+		d.Start()
+
+		d.InputChan <- NewInput([]byte("1 hello\n2 wor"))
+		d.FlushPartialLine()
+		output := <-d.OutputChan
+		assert.Equal(t, "1 hello", string(output.Content))
+		assert.Equal(t, len("1 hello\n"), output.RawDataLen)
+		// the line feed of the partial line has not been read
+		output = <-d.OutputChan
+		assert.Equal(t, "2 wor", string(output.Content))
+		assert.Equal(t, len("2 wor"), output.RawDataLen)
+		d.Stop()
+	}
+}
+
+func TestDecoderDoesNotSplitFrames(t *testing.T) {
+	h := NewMockLineHandler()
+	d := New(make(chan *Input), nil, h)
+	d.Start()
+
+	d.InputChan <- NewInput([]byte("hello\nwor"))
+	d.InputChan <- NewFrameInput([]byte("foo\nbar"))
+	assert.Equal(t, "hello", string(<-h.lineChan))
+	// the partial line is handled before the frame
+	assert.Equal(t, "wor", string(<-h.lineChan))
+	assert.Equal(t, "foo\nbar", string(<-h.lineChan))
+
+	d.InputChan <- NewFrameInput([]byte(strings.Repeat("a", contentLenLimit+10)))
+	assert.Equal(t, contentLenLimit-1, len(<-h.lineChan))
+	d.Stop()
+}
+
+func TestDecoderStripsBOMAfterTruncation(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{StripBOM: true})
+	d := InitializeDecoder(source, parser.NoopParser)
+	d.Start()
+	defer d.Stop()
+
+	// the file starts with a BOM and is rewritten with a new one
+	d.InputChan <- NewInput([]byte("\xEF\xBB\xBFfoo\n"))
+	d.InputChan <- NewInput([]byte("\xEF\xBB\xBFbar\nbaz\n"))
+
+	output := <-d.OutputChan
+	assert.Equal(t, "foo", string(output.Content))
+	assert.Equal(t, 7, output.RawDataLen)
+	output = <-d.OutputChan
+	assert.Equal(t, "bar", string(output.Content))
+	assert.Equal(t, 7, output.RawDataLen)
+	output = <-d.OutputChan
+	assert.Equal(t, "baz", string(output.Content))
+	assert.Equal(t, 4, output.RawDataLen)
+}
+
+func TestDecoderStripsBOMWithMultiLineRule(t *testing.T) {
+	rules := []config.ProcessingRule{{Type: config.MultiLine, Name: "numbers", Pattern: "[0-9]"}}
+	logsConfig := &config.LogsConfig{StripBOM: true, ProcessingRules: rules}
+	assert.Nil(t, logsConfig.Compile())
+	d := InitializeDecoder(config.NewLogSource("", logsConfig), parser.NoopParser)
+	d.Start()
+
+	d.InputChan <- NewInput([]byte("1 foo\nbar\n"))
+	d.InputChan <- NewInput([]byte("\xEF\xBB\xBF2 baz\n"))
+	defer d.Stop()
+
+	output := <-d.OutputChan
+	assert.Equal(t, "1 foo\\nbar", string(output.Content))
+	output = <-d.OutputChan
+	assert.Equal(t, "2 baz", string(output.Content))
+}
+
+func TestDecoderAppliesMultiLineRuleLimits(t *testing.T) {
+	rules := []config.ProcessingRule{{Type: config.MultiLine, Name: "numbers", Pattern: "[0-9]"}}
+	logsConfig := &config.LogsConfig{ProcessingRules: rules}
+	assert.Nil(t, logsConfig.Compile())
+	handler := InitializeDecoder(config.NewLogSource("", logsConfig), parser.NoopParser).lineHandler.(*MultiLineHandler)
+	assert.Equal(t, defaultFlushTimeout, handler.flushTimeout)
+	assert.Equal(t, contentLenLimit, handler.contentLenLimit)
+
+	rules[0].FlushTimeout = 0.1
+	rules[0].MaxSize = 10
+	handler = InitializeDecoder(config.NewLogSource("", logsConfig), parser.NoopParser).lineHandler.(*MultiLineHandler)
+	assert.Equal(t, 100*time.Millisecond, handler.flushTimeout)
+	assert.Equal(t, 10, handler.contentLenLimit)
+
+	// the max size can not exceed the length limit of the decoder
+	rules[0].MaxSize = contentLenLimit + 1
+	handler = InitializeDecoder(config.NewLogSource("", logsConfig), parser.NoopParser).lineHandler.(*MultiLineHandler)
+	assert.Equal(t, contentLenLimit, handler.contentLenLimit)
+}
+
+func TestDecoderKeepsBOMByDefault(t *testing.T) {
+	d := InitializeDecoder(config.NewLogSource("", &config.LogsConfig{}), parser.NoopParser)
+	d.Start()
+	defer d.Stop()
+
+	d.InputChan <- NewInput([]byte("\xEF\xBB\xBFfoo\n"))
+	output := <-d.OutputChan
+	assert.Equal(t, "\xEF\xBB\xBFfoo", string(output.Content))
+}
+
+func TestDecoderTranscodesUTF16(t *testing.T) {
+	for _, bigEndian := range []bool{false, true} {
+		source := config.NewLogSource("", &config.LogsConfig{Encoding: config.UTF16Encoding})
+		d := InitializeDecoder(source, parser.NoopParser)
+		d.Start()
+
+		content := encodeUTF16("héllo\r\n😀 world\nlast", bigEndian, true)
+		// the reads are split in the middle of the code units and of the surrogate pairs
+		d.InputChan <- NewInput(content[:7])
+		d.InputChan <- NewInput(content[7:19])
+		output := <-d.OutputChan
+		assert.Equal(t, "héllo", string(output.Content))
+		assert.Equal(t, len(encodeUTF16("héllo\r\n", bigEndian, true)), output.RawDataLen)
+
+		d.InputChan <- NewInput(content[19:])
+		output = <-d.OutputChan
+		assert.Equal(t, "😀 world", string(output.Content))
+		assert.Equal(t, len(encodeUTF16("😀 world\n", bigEndian, false)), output.RawDataLen)
+
+		d.FlushPartialLine()
+		output = <-d.OutputChan
+		assert.Equal(t, "last", string(output.Content))
+		assert.Equal(t, len(encodeUTF16("last", bigEndian, false)), output.RawDataLen)
+		d.Stop()
+	}
+}
+
+func TestDecoderTranscodesUTF16WithMultiLineRule(t *testing.T) {
+	rules := []config.ProcessingRule{{Type: config.MultiLine, Reg: regexp.MustCompile("^[0-9]"), FlushTimeout: 0.01}}
+	source := config.NewLogSource("", &config.LogsConfig{Encoding: config.UTF16LEEncoding, ProcessingRules: rules})
+	d := InitializeDecoder(source, parser.NoopParser)
+	d.Start()
+	defer d.Stop()
+
+	d.InputChan <- NewInput(encodeUTF16("1 foo\n  bar\n2 baz\n", false, false))
+	output := <-d.OutputChan
+	assert.Equal(t, `1 foo\n  bar`, string(output.Content))
+	assert.Equal(t, len(encodeUTF16("1 foo\n  bar\n", false, false)), output.RawDataLen)
+	output = <-d.OutputChan
+	assert.Equal(t, "2 baz", string(output.Content))
+	assert.Equal(t, len(encodeUTF16("2 baz\n", false, false)), output.RawDataLen)
+}
+
+func TestDecoderTranscodesLatin1(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{Encoding: config.Latin1Encoding})
+	d := InitializeDecoder(source, parser.NoopParser)
+	d.Start()
+	defer d.Stop()
+
+	d.InputChan <- NewInput([]byte("caf\xe9\nna\xefve\n"))
+	output := <-d.OutputChan
+	assert.Equal(t, "café", string(output.Content))
+	assert.Equal(t, 5, output.RawDataLen)
+	output = <-d.OutputChan
+	assert.Equal(t, "naïve", string(output.Content))
+	assert.Equal(t, 6, output.RawDataLen)
+}
+
+func TestDecoderDetectsTheByteOrderFromTheBeginningOfTheFile(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{Encoding: config.UTF16Encoding})
+	d := InitializeDecoder(source, parser.NoopParser)
+	d.DetectByteOrder(bytes.NewReader(encodeUTF16("foo\n", true, true)))
+	d.Start()
+	defer d.Stop()
+
+	// the content is read after the byte order mark
+	d.InputChan <- NewInput(encodeUTF16("bar\n", true, false))
+	output := <-d.OutputChan
+	assert.Equal(t, "bar", string(output.Content))
+}
+
+func TestDecoderTruncatesTranscodedLinesLongerThanMaxLineLength(t *testing.T) {
+	config.LogsAgent.Set("logs_config.max_line_length", 9)
+	config.LogsAgent.Set("logs_config.truncation_marker", "[cut]")
+	defer config.LogsAgent.Set("logs_config.max_line_length", 0)
+	defer config.LogsAgent.Set("logs_config.truncation_marker", "...TRUNCATED...")
+
+	source := config.NewLogSource("", &config.LogsConfig{Encoding: config.UTF16LEEncoding})
+	d := InitializeDecoder(source, parser.NoopParser)
+	d.Start()
+	defer d.Stop()
+
+	// the line is cut at the boundary of a code unit, and the reads are split in the middle of the code units
+	content := encodeUTF16("0123456789\nnext\n", false, false)
+	d.InputChan <- NewInput(content[:9])
+	d.InputChan <- NewInput(content[9:])
+	output := <-d.OutputChan
+	assert.Equal(t, "0123[cut]", string(output.Content))
+	assert.Equal(t, len(encodeUTF16("0123456789\n", false, false)), output.RawDataLen)
+	output = <-d.OutputChan
+	assert.Equal(t, "next", string(output.Content))
+	assert.Equal(t, len(encodeUTF16("next\n", false, false)), output.RawDataLen)
+
+	// the data discarded spans multiple inputs
+	d.InputChan <- NewInput(encodeUTF16(strings.Repeat("a", contentLenLimit), false, false))
+	d.InputChan <- NewInput(encodeUTF16("\n", false, false))
+	output = <-d.OutputChan
+	assert.Equal(t, "aaaa[cut]", string(output.Content))
+	assert.Equal(t, len(encodeUTF16(strings.Repeat("a", contentLenLimit)+"\n", false, false)), output.RawDataLen)
+}
+
+func TestDecoderSyncsOnceTheMessagesDecodedBeforeAreSent(t *testing.T) {
+	rules := []config.ProcessingRule{{Type: config.MultiLine, Reg: regexp.MustCompile("^[0-9]"), FlushTimeout: 0.01}}
+	for _, source := range []*config.LogSource{
+		config.NewLogSource("", &config.LogsConfig{}),
+		config.NewLogSource("", &config.LogsConfig{ProcessingRules: rules}),
+	} {
+		d := InitializeDecoder(source, parser.NoopParser)
+		d.Start()
+
+		synced := make(chan struct{})
+		d.InputChan <- NewFrameInput([]byte("1 foo"))
+		d.Sync(func() {
+			close(synced)
+		})
+		select {
+		case <-synced:
+			assert.Fail(t, "synced before the message is sent")
+		case output := <-d.OutputChan:
+			assert.Equal(t, "1 foo", string(output.Content))
+		}
+		<-synced
+
+		// the empty messages are dropped
+		synced = make(chan struct{})
+		d.InputChan <- NewFrameInput([]byte("  "))
+		d.Sync(func() {
+			close(synced)
+		})
+		<-synced
+		d.Stop()
+	}
+}
@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package decoder
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// byteOrderMark is the character written at the beginning of some files to tell their encoding and byte order.
+const byteOrderMark = '\uFEFF'
+
+// transcoder transcodes the content of a source to UTF-8,
+// the lines are split on the line feeds of its encoding before being transcoded.
+type transcoder struct {
+	// unitLen is the length of the code units of the encoding, the line feeds are only searched at their boundaries
+	unitLen   int
+	bigEndian bool
+	// detectByteOrder is true until the byte order is read from the byte order mark at the beginning of the content
+	detectByteOrder bool
+	lineFeed        []byte
+}
+
+// newTranscoder returns a transcoder from encoding, nil for UTF-8 which is not transcoded.
+func newTranscoder(encoding string) *transcoder {
+	switch encoding {
+	case config.UTF16Encoding:
+		return &transcoder{unitLen: 2, detectByteOrder: true, lineFeed: []byte{'\n', 0}}
+	case config.UTF16LEEncoding:
+		return &transcoder{unitLen: 2, lineFeed: []byte{'\n', 0}}
+	case config.UTF16BEEncoding:
+		return &transcoder{unitLen: 2, bigEndian: true, lineFeed: []byte{0, '\n'}}
+	case config.Latin1Encoding:
+		return &transcoder{unitLen: 1, lineFeed: []byte{'\n'}}
+	default:
+		return nil
+	}
+}
+
+// detect reads the byte order from the byte order mark at the beginning of content,
+// it keeps the default byte order when content does not start with a byte order mark.
+func (t *transcoder) detect(content []byte) {
+	if !t.detectByteOrder || len(content) < t.unitLen {
+		return
+	}
+	t.detectByteOrder = false
+	if content[0] == 0xFE && content[1] == 0xFF {
+		t.bigEndian = true
+		t.lineFeed = []byte{0, '\n'}
+	}
+}
+
+// indexLineFeed returns the index of the first line feed of content, -1 if there is none.
+func (t *transcoder) indexLineFeed(content []byte) int {
+	if t.unitLen == 1 {
+		return bytes.IndexByte(content, '\n')
+	}
+	for i := 0; i+t.unitLen <= len(content); i += t.unitLen {
+		if bytes.Equal(content[i:i+t.unitLen], t.lineFeed) {
+			return i
+		}
+	}
+	return -1
+}
+
+// transcode returns content in UTF-8 without its leading byte order mark,
+// the invalid sequences are replaced with the Unicode replacement character.
+func (t *transcoder) transcode(content []byte) []byte {
+	var runes []rune
+	if t.unitLen == 1 {
+		// the Latin-1 characters are the first 256 Unicode code points
+		runes = make([]rune, len(content))
+		for i, b := range content {
+			runes[i] = rune(b)
+		}
+	} else {
+		units := make([]uint16, 0, len(content)/2)
+		for i := 0; i+1 < len(content); i += 2 {
+			if t.bigEndian {
+				units = append(units, uint16(content[i])<<8|uint16(content[i+1]))
+			} else {
+				units = append(units, uint16(content[i+1])<<8|uint16(content[i]))
+			}
+		}
+		runes = utf16.Decode(units)
+		if len(content)%2 != 0 {
+			// the content ends in the middle of a code unit
+			runes = append(runes, utf8.RuneError)
+		}
+	}
+	if len(runes) > 0 && runes[0] == byteOrderMark {
+		runes = runes[1:]
+	}
+	return []byte(string(runes))
+}
@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package decoder
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// encodeUTF16 returns s encoded in UTF-16 in the byte order given, preceded by a byte order mark if bom is set.
+func encodeUTF16(s string, bigEndian bool, bom bool) []byte {
+	if bom {
+		s = string(byteOrderMark) + s
+	}
+	var content []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			content = append(content, byte(unit>>8), byte(unit))
+		} else {
+			content = append(content, byte(unit), byte(unit>>8))
+		}
+	}
+	return content
+}
+
+func TestNewTranscoder(t *testing.T) {
+	assert.Nil(t, newTranscoder(""))
+	assert.Nil(t, newTranscoder(config.UTF8Encoding))
+	assert.NotNil(t, newTranscoder(config.UTF16Encoding))
+	assert.NotNil(t, newTranscoder(config.UTF16LEEncoding))
+	assert.NotNil(t, newTranscoder(config.UTF16BEEncoding))
+	assert.NotNil(t, newTranscoder(config.Latin1Encoding))
+}
+
+func TestTranscoderTranscodesUTF16(t *testing.T) {
+	le := newTranscoder(config.UTF16LEEncoding)
+	assert.Equal(t, "héllo 😀", string(le.transcode(encodeUTF16("héllo 😀", false, false))))
+	// the byte order mark is stripped
+	assert.Equal(t, "foo", string(le.transcode(encodeUTF16("foo", false, true))))
+
+	be := newTranscoder(config.UTF16BEEncoding)
+	assert.Equal(t, "héllo 😀", string(be.transcode(encodeUTF16("héllo 😀", true, false))))
+}
+
+func TestTranscoderReplacesInvalidSequences(t *testing.T) {
+	le := newTranscoder(config.UTF16LEEncoding)
+	// an unpaired surrogate
+	assert.Equal(t, "a�b", string(le.transcode([]byte{'a', 0, 0x3D, 0xD8, 'b', 0})))
+	// the content ends in the middle of a code unit
+	assert.Equal(t, "a�", string(le.transcode([]byte{'a', 0, 'b'})))
+}
+
+func TestTranscoderTranscodesLatin1(t *testing.T) {
+	latin1 := newTranscoder(config.Latin1Encoding)
+	assert.Equal(t, "café ÿ", string(latin1.transcode([]byte("caf\xe9 \xff"))))
+}
+
+func TestTranscoderDetectsTheByteOrder(t *testing.T) {
+	transcoder := newTranscoder(config.UTF16Encoding)
+	transcoder.detect(encodeUTF16("foo", true, true))
+	assert.Equal(t, "foo", string(transcoder.transcode(encodeUTF16("foo", true, true))))
+	// the byte order is only read from the beginning of the content
+	transcoder.detect(encodeUTF16("foo", false, true))
+	assert.True(t, transcoder.bigEndian)
+
+	// little endian by default
+	transcoder = newTranscoder(config.UTF16Encoding)
+	transcoder.detect([]byte{0xFE})
+	assert.True(t, transcoder.detectByteOrder)
+	transcoder.detect(encodeUTF16("foo", false, false))
+	assert.False(t, transcoder.bigEndian)
+}
+
+func TestTranscoderIndexesTheLineFeedsAtTheBoundariesOfTheCodeUnits(t *testing.T) {
+	le := newTranscoder(config.UTF16LEEncoding)
+	// U+0A0A is followed by a NUL, which would make a line feed across the code units
+	content := []byte{0x0A, 0x0A, 0, 0, '\n', 0}
+	assert.Equal(t, 4, le.indexLineFeed(content))
+	assert.Equal(t, -1, le.indexLineFeed(content[:5]))
+
+	be := newTranscoder(config.UTF16BEEncoding)
+	assert.Equal(t, 2, be.indexLineFeed(encodeUTF16("a\nb", true, false)))
+}
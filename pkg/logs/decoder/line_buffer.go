@@ -14,6 +14,9 @@ import (
 type LineBuffer struct {
 	buffer     *bytes.Buffer
 	rawDataLen int
+	// carriedDataLen is the part of rawDataLen read before the buffer was carried over
+	// from another file, it is not accounted for in the length of the data of the content.
+	carriedDataLen int
 }
 
 // NewLineBuffer returns a new LineBuffer
@@ -39,6 +42,13 @@ func (l *LineBuffer) Add(line []byte) {
 	l.rawDataLen += len(line) + 1 // add 1 for '\n'
 }
 
+// AddRawLine stores a line truncated or transcoded by the decoder in buffer,
+// rawDataLen is the length of the whole line read, '\n' included
+func (l *LineBuffer) AddRawLine(line []byte, rawDataLen int) {
+	l.buffer.Write(line)
+	l.rawDataLen += rawDataLen
+}
+
 // AddEndOfLine stores an escaped '\n' in buffer
 func (l *LineBuffer) AddEndOfLine() {
 	l.buffer.Write([]byte(`\n`))
@@ -59,11 +69,17 @@ func (l *LineBuffer) AddTruncate(line []byte) {
 func (l *LineBuffer) Content() ([]byte, int) {
 	content := make([]byte, l.buffer.Len())
 	copy(content, l.buffer.Bytes())
-	return content, l.rawDataLen
+	return content, l.rawDataLen - l.carriedDataLen
 }
 
 // Reset prepares buffer to receive new lines
 func (l *LineBuffer) Reset() {
 	l.rawDataLen = 0
+	l.carriedDataLen = 0
 	l.buffer.Reset()
 }
+
+// CarryOver marks all the data accumulated so far as read from another file.
+func (l *LineBuffer) CarryOver() {
+	l.carriedDataLen = l.rawDataLen
+}
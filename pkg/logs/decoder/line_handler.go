@@ -25,18 +25,43 @@ type LineHandler interface {
 	Stop()
 }
 
+// rawLineHandler is implemented by the line handlers accounting for the length of the data a line was decoded from
+// when it differs from the length of its content, because the decoder truncated a line longer than its max line length
+// or transcoded it to UTF-8.
+type rawLineHandler interface {
+	HandleRaw(content []byte, rawDataLen int)
+}
+
+// syncLineHandler is implemented by the line handlers able to tell when the lines handled so far are sent or dropped.
+type syncLineHandler interface {
+	Sync(done func())
+}
+
+// handledLine is a line received by a line handler, rawDataLen is the length of the data the whole line was
+// decoded from, line feed included, when the decoder truncated or transcoded it, 0 otherwise.
+// It holds no line but synced when the handler must call synced once the previous lines are sent or dropped.
+type handledLine struct {
+	content    []byte
+	rawDataLen int
+	synced     func()
+}
+
 // SingleLineHandler creates and forward outputs to outputChan from single-lines
 type SingleLineHandler struct {
-	lineChan       chan []byte
+	lineChan       chan *handledLine
 	outputChan     chan *message.Message
 	shouldTruncate bool
 	parser         parser.Parser
+	// partialLine holds the parts received so far of a line split by the format of the parser,
+	// partialDataLen is their raw length
+	partialLine    []byte
+	partialDataLen int
 }
 
 // NewSingleLineHandler returns a new SingleLineHandler
 func NewSingleLineHandler(outputChan chan *message.Message, parser parser.Parser) *SingleLineHandler {
 	return &SingleLineHandler{
-		lineChan:   make(chan []byte),
+		lineChan:   make(chan *handledLine),
 		outputChan: outputChan,
 		parser:     parser,
 	}
@@ -45,7 +70,18 @@ func NewSingleLineHandler(outputChan chan *message.Message, parser parser.Parser
 // Handle trims leading and trailing whitespaces from content,
 // and sends it as a new Line to lineChan.
 func (h *SingleLineHandler) Handle(content []byte) {
-	h.lineChan <- content
+	h.lineChan <- &handledLine{content: content}
+}
+
+// HandleRaw sends a line truncated or transcoded by the decoder to lineChan,
+// rawDataLen is the length of the whole line read.
+func (h *SingleLineHandler) HandleRaw(content []byte, rawDataLen int) {
+	h.lineChan <- &handledLine{content: content, rawDataLen: rawDataLen}
+}
+
+// Sync calls done once the lines handled before are sent to outputChan or dropped.
+func (h *SingleLineHandler) Sync(done func()) {
+	h.lineChan <- &handledLine{synced: done}
 }
 
 // Stop stops the handler from processing new lines
@@ -68,8 +104,22 @@ func (h *SingleLineHandler) run() {
 
 // process creates outputs from lines and forwards them to outputChan
 // When lines are too long, they are truncated
-func (h *SingleLineHandler) process(line []byte) {
+func (h *SingleLineHandler) process(handled *handledLine) {
+	if handled.synced != nil {
+		handled.synced()
+		return
+	}
+	line := handled.content
 	lineLen := len(line)
+	if handled.rawDataLen == 0 && parser.IsPartial(h.parser, line) && len(h.partialLine)+lineLen < contentLenLimit {
+		// the line continues in the next part
+		h.addPart(line)
+		return
+	}
+	var partialDataLen int
+	if h.partialLine != nil {
+		line, partialDataLen = h.joinParts(line)
+	}
 	line = bytes.TrimSpace(line)
 	if len(line) == 0 {
 		return
@@ -94,7 +144,11 @@ func (h *SingleLineHandler) process(line []byte) {
 			return
 		}
 		if len(output.Content) > 0 {
-			output.RawDataLen = lineLen + 1
+			output.RawDataLen = partialDataLen + lineLen + 1
+			if handled.rawDataLen > 0 {
+				// the line has been truncated or transcoded by the decoder
+				output.RawDataLen = partialDataLen + handled.rawDataLen
+			}
 			h.outputChan <- output
 		}
 	} else {
@@ -106,13 +160,38 @@ func (h *SingleLineHandler) process(line []byte) {
 			return
 		}
 		if len(output.Content) > 0 {
-			output.RawDataLen = lineLen
+			output.RawDataLen = partialDataLen + lineLen
+			if handled.rawDataLen > 0 {
+				output.RawDataLen = partialDataLen + handled.rawDataLen
+			}
 			h.outputChan <- output
 			h.shouldTruncate = true
 		}
 	}
 }
 
+// addPart keeps part until the last part of its line is received,
+// the header of the parts following the first one is removed.
+func (h *SingleLineHandler) addPart(part []byte) {
+	if h.partialLine == nil {
+		h.partialLine = append([]byte{}, part...)
+	} else if content, err := h.parser.Unwrap(part); err == nil {
+		h.partialLine = append(h.partialLine, content...)
+	}
+	h.partialDataLen += len(part) + 1 // add 1 for '\n'
+}
+
+// joinParts returns the line made of the parts received so far and of its last part,
+// along with the raw length of the parts received so far.
+func (h *SingleLineHandler) joinParts(last []byte) ([]byte, int) {
+	line, partialDataLen := h.partialLine, h.partialDataLen
+	if content, err := h.parser.Unwrap(last); err == nil {
+		line = append(line, content...)
+	}
+	h.partialLine, h.partialDataLen = nil, 0
+	return line, partialDataLen
+}
+
 // defaultFlushTimeout represents the time we want to wait before flushing lineBuffer
 // when no more line is received
 const defaultFlushTimeout = 1000 * time.Millisecond
@@ -120,29 +199,59 @@ const defaultFlushTimeout = 1000 * time.Millisecond
 // MultiLineHandler reads lines from lineChan and uses lineBuffer to send them
 // when a new line matches with re or flushTimer is fired
 type MultiLineHandler struct {
-	lineChan     chan []byte
+	lineChan     chan *handledLine
 	outputChan   chan *message.Message
 	lineBuffer   *LineBuffer
 	newContentRe *regexp.Regexp
 	flushTimeout time.Duration
 	parser       parser.Parser
+	// contentLenLimit is the length above which the content is truncated and sent.
+	contentLenLimit int
+	// partial is set when the last line is followed by the next part of the same line
+	// in the format of the parser, the parts are joined without line feed.
+	partial bool
+	// holding prevents lineBuffer from being flushed on timeout
+	// until it is carried over to another handler.
+	holding       bool
+	carried       bool
+	holdRequests  chan struct{}
+	carryRequests chan chan *LineBuffer
+	done          chan struct{}
+	// synced are called once the content of lineBuffer is sent or dropped.
+	synced []func()
 }
 
 // NewMultiLineHandler returns a new MultiLineHandler
 func NewMultiLineHandler(outputChan chan *message.Message, newContentRe *regexp.Regexp, flushTimeout time.Duration, parser parser.Parser) *MultiLineHandler {
 	return &MultiLineHandler{
-		lineChan:     make(chan []byte),
-		outputChan:   outputChan,
-		lineBuffer:   NewLineBuffer(),
-		newContentRe: newContentRe,
-		flushTimeout: flushTimeout,
-		parser:       parser,
+		lineChan:        make(chan *handledLine),
+		outputChan:      outputChan,
+		lineBuffer:      NewLineBuffer(),
+		newContentRe:    newContentRe,
+		flushTimeout:    flushTimeout,
+		parser:          parser,
+		contentLenLimit: contentLenLimit,
+		holdRequests:    make(chan struct{}),
+		carryRequests:   make(chan chan *LineBuffer),
+		done:            make(chan struct{}),
 	}
 }
 
 // Handle forward lines to lineChan to process them
 func (h *MultiLineHandler) Handle(content []byte) {
-	h.lineChan <- content
+	h.lineChan <- &handledLine{content: content}
+}
+
+// HandleRaw forwards a line truncated or transcoded by the decoder to lineChan,
+// rawDataLen is the length of the whole line read.
+func (h *MultiLineHandler) HandleRaw(content []byte, rawDataLen int) {
+	h.lineChan <- &handledLine{content: content, rawDataLen: rawDataLen}
+}
+
+// Sync calls done once the lines handled before are sent to outputChan or dropped,
+// which may only happen when the next content starts or the flush timeout expires.
+func (h *MultiLineHandler) Sync(done func()) {
+	h.lineChan <- &handledLine{synced: done}
 }
 
 // Stop stops the lineHandler from processing lines
@@ -160,6 +269,7 @@ func (h *MultiLineHandler) run() {
 	flushTimer := time.NewTimer(h.flushTimeout)
 	defer func() {
 		flushTimer.Stop()
+		close(h.done)
 		close(h.outputChan)
 	}()
 	for {
@@ -167,41 +277,100 @@ func (h *MultiLineHandler) run() {
 		case line, isOpen := <-h.lineChan:
 			if !isOpen {
 				// lineChan has been closed, no more lines are expected
+				if h.holding {
+					// the content has not been carried over, send it as is
+					h.sendContent()
+				}
 				return
 			}
+			if line.synced != nil {
+				if h.lineBuffer.IsEmpty() {
+					line.synced()
+				} else {
+					h.synced = append(h.synced, line.synced)
+				}
+				continue
+			}
 			// process the new line and restart the timeout
 			flushTimer.Stop()
 			h.process(line)
 			flushTimer.Reset(h.flushTimeout)
 		case <-flushTimer.C:
+			if h.holding {
+				// the content may continue in another file
+				continue
+			}
 			// the timout expired, the content is ready to be sent
 			h.sendContent()
+		case <-h.holdRequests:
+			// once carried over, the next content can not be joined to the new file anymore
+			h.holding = !h.carried
+		case reply := <-h.carryRequests:
+			if h.holding && !h.lineBuffer.IsEmpty() {
+				h.lineBuffer.CarryOver()
+				reply <- h.lineBuffer
+				h.lineBuffer = NewLineBuffer()
+			} else {
+				reply <- nil
+			}
+			h.holding = false
+			h.carried = true
+			// the content carried over is sent by the next handler
+			h.sync()
+		}
+	}
+}
+
+// hold prevents the content from being sent on timeout until it is carried over to another handler.
+func (h *MultiLineHandler) hold() {
+	h.holdRequests <- struct{}{}
+}
+
+// carryOver moves the held content to next which must not be started yet,
+// the lines next receives are then appended to this content.
+func (h *MultiLineHandler) carryOver(next *MultiLineHandler) {
+	reply := make(chan *LineBuffer)
+	select {
+	case h.carryRequests <- reply:
+		if lineBuffer := <-reply; lineBuffer != nil {
+			next.lineBuffer = lineBuffer
 		}
+	case <-h.done:
+		// the handler is stopped, its content has already been sent
 	}
 }
 
 // process accumulates lines in lineBuffer and flushes lineBuffer when a new line matches with newContentRe
 // When lines are too long, they are truncated
-func (h *MultiLineHandler) process(line []byte) {
+func (h *MultiLineHandler) process(handled *handledLine) {
+	line := handled.content
 	unwrappedLine, err := h.parser.Unwrap(line)
 	if err != nil {
 		log.Warn(err)
 		return
 	}
-	if h.newContentRe.Match(unwrappedLine) {
+	continued := h.partial
+	h.partial = parser.IsPartial(h.parser, line)
+	if !continued && h.newContentRe.Match(unwrappedLine) {
 		// send content from lineBuffer
 		h.sendContent()
 	}
 	if !h.lineBuffer.IsEmpty() {
 		// unwrap all the following lines
 		line = unwrappedLine
-		// add '\n' to content in lineBuffer
-		h.lineBuffer.AddEndOfLine()
+		if !continued {
+			// add '\n' to content in lineBuffer
+			h.lineBuffer.AddEndOfLine()
+		}
 	}
-	if len(line)+h.lineBuffer.Length() < contentLenLimit {
+	switch {
+	case handled.rawDataLen > 0:
+		// the line has been truncated or transcoded by the decoder, it is complete
+		h.lineBuffer.AddRawLine(line, handled.rawDataLen)
+	case len(line)+h.lineBuffer.Length() < h.contentLenLimit:
 		// add line to content in lineBuffer
 		h.lineBuffer.Add(line)
-	} else {
+	default:
 		// add line and truncate and flush content in lineBuffer
 		h.lineBuffer.AddIncompleteLine(line)
 		h.lineBuffer.AddTruncate(line)
@@ -214,6 +383,7 @@ func (h *MultiLineHandler) process(line []byte) {
 
 // sendContent forwards the content from lineBuffer to outputChan
 func (h *MultiLineHandler) sendContent() {
+	defer h.sync()
 	defer h.lineBuffer.Reset()
 	content, rawDataLen := h.lineBuffer.Content()
 	content = bytes.TrimSpace(content)
@@ -229,3 +399,11 @@ func (h *MultiLineHandler) sendContent() {
 		}
 	}
 }
+
+// sync calls the functions waiting for the content of lineBuffer to be sent.
+func (h *MultiLineHandler) sync() {
+	for _, done := range h.synced {
+		done()
+	}
+	h.synced = nil
+}
@@ -169,6 +169,27 @@ func TestMultiLineHandler(t *testing.T) {
 	h.Stop()
 }
 
+func TestMultiLineHandlerWithContentLenLimit(t *testing.T) {
+	re := regexp.MustCompile("[0-9]+\\.")
+	outputChan := make(chan *message.Message, 10)
+	h := NewMultiLineHandler(outputChan, re, 10*time.Millisecond, parser.NoopParser)
+	h.contentLenLimit = 30
+	h.Start()
+
+	// a message which never ends is sent once it exceeds the limit
+	h.Handle([]byte("1. first line"))
+	h.Handle([]byte("second line"))
+	h.Handle([]byte("third line"))
+	output := <-outputChan
+	assert.Equal(t, "1. first line\\nsecond line\\nthird line"+string(TRUNCATED), string(output.Content))
+
+	h.Handle([]byte("fourth"))
+	output = <-outputChan
+	assert.Equal(t, string(TRUNCATED)+"fourth", string(output.Content))
+
+	h.Stop()
+}
+
 func TestTrimMultiLine(t *testing.T) {
 	re := regexp.MustCompile("[0-9]+\\.")
 	outputChan := make(chan *message.Message, 10)
@@ -262,3 +283,150 @@ func TestMultiLineHandlerDropsEmptyMessages(t *testing.T) {
 	output = <-outputChan
 	assert.Equal(t, "1.third line\\nfourth line", string(output.Content))
 }
+
+func TestMultiLineHandlerCarriesHeldContentOver(t *testing.T) {
+	re := regexp.MustCompile("[0-9]+\\.")
+	outputChan := make(chan *message.Message, 10)
+	h := NewMultiLineHandler(outputChan, re, 10*time.Millisecond, parser.NoopParser)
+	h.Start()
+
+	h.Handle([]byte("1. first line"))
+	h.hold()
+	synced := make(chan struct{})
+	h.Sync(func() {
+		close(synced)
+	})
+
+	// the held content must not be flushed on timeout
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, len(outputChan))
+	select {
+	case <-synced:
+		assert.Fail(t, "the handler synced before its content was carried over")
+	default:
+	}
+
+	nextOutputChan := make(chan *message.Message, 10)
+	next := NewMultiLineHandler(nextOutputChan, re, 10*time.Millisecond, parser.NoopParser)
+	h.carryOver(next)
+	// the content carried over is sent by the next handler
+	<-synced
+	h.Stop()
+	_, isOpen := <-outputChan
+	assert.False(t, isOpen)
+
+	next.Start()
+	next.Handle([]byte("second line"))
+	next.Handle([]byte("2. first line"))
+
+	// only the bytes read from the new file must be accounted for
+	output := <-nextOutputChan
+	assert.Equal(t, "1. first line"+"\\n"+"second line", string(output.Content))
+	assert.Equal(t, len("second line")+1, output.RawDataLen)
+
+	output = <-nextOutputChan
+	assert.Equal(t, "2. first line", string(output.Content))
+	assert.Equal(t, len("2. first line")+1, output.RawDataLen)
+
+	next.Stop()
+}
+
+func TestMultiLineHandlerSendsHeldContentOnStop(t *testing.T) {
+	re := regexp.MustCompile("[0-9]+\\.")
+	outputChan := make(chan *message.Message, 10)
+	h := NewMultiLineHandler(outputChan, re, 10*time.Millisecond, parser.NoopParser)
+	h.Start()
+
+	h.Handle([]byte("1. first line"))
+	h.hold()
+	h.Stop()
+
+	output := <-outputChan
+	assert.Equal(t, "1. first line", string(output.Content))
+	assert.Equal(t, len("1. first line")+1, output.RawDataLen)
+
+	// nothing is left to carry over once stopped
+	next := NewMultiLineHandler(make(chan *message.Message, 10), re, 10*time.Millisecond, parser.NoopParser)
+	h.carryOver(next)
+	assert.True(t, next.lineBuffer.IsEmpty())
+}
+
+// MockPartialParser mocks a parser of lines prefixed by P| when they are followed by their next part, F| otherwise.
+type MockPartialParser struct{}
+
+func (p *MockPartialParser) Parse(msg []byte) (*message.Message, error) {
+	return &message.Message{Content: msg[2:]}, nil
+}
+
+func (p *MockPartialParser) Unwrap(line []byte) ([]byte, error) {
+	return line[2:], nil
+}
+
+func (p *MockPartialParser) IsPartial(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("P|"))
+}
+
+func TestSingleLineHandlerJoinsPartialLines(t *testing.T) {
+	outputChan := make(chan *message.Message, 10)
+	h := NewSingleLineHandler(outputChan, &MockPartialParser{})
+	h.Start()
+
+	h.Handle([]byte("P|hello "))
+	h.Handle([]byte("P|big "))
+	h.Handle([]byte("F|world"))
+	h.Handle([]byte("F|bye"))
+
+	output := <-outputChan
+	assert.Equal(t, "hello big world", string(output.Content))
+	assert.Equal(t, len("P|hello P|big F|world")+3, output.RawDataLen)
+
+	output = <-outputChan
+	assert.Equal(t, "bye", string(output.Content))
+	assert.Equal(t, len("F|bye")+1, output.RawDataLen)
+
+	h.Stop()
+}
+
+func TestSingleLineHandlerSendsPartialLinesTooLong(t *testing.T) {
+	contentLenLimit = 10
+	defer func() { contentLenLimit = 256 * 1000 }()
+
+	outputChan := make(chan *message.Message, 10)
+	h := NewSingleLineHandler(outputChan, &MockPartialParser{})
+	h.Start()
+
+	h.Handle([]byte("P|hello"))
+	h.Handle([]byte("P|world"))
+	h.Handle([]byte("F|bye"))
+
+	output := <-outputChan
+	assert.Equal(t, "helloworld", string(output.Content))
+	assert.Equal(t, len("P|hello P|world")+1, output.RawDataLen)
+
+	output = <-outputChan
+	assert.Equal(t, "bye", string(output.Content))
+
+	h.Stop()
+}
+
+func TestMultiLineHandlerJoinsPartialLines(t *testing.T) {
+	re := regexp.MustCompile("^[0-9]+\\.")
+	outputChan := make(chan *message.Message, 10)
+	h := NewMultiLineHandler(outputChan, re, 10*time.Millisecond, &MockPartialParser{})
+	h.Start()
+
+	// the part following a partial line is not matched against the pattern
+	h.Handle([]byte("P|1. first "))
+	h.Handle([]byte("F|2. line"))
+	h.Handle([]byte("F|second line"))
+	h.Handle([]byte("F|3. next"))
+
+	output := <-outputChan
+	assert.Equal(t, "1. first 2. line\\nsecond line", string(output.Content))
+	assert.Equal(t, len("P|1. first "+"2. line"+"second line")+3, output.RawDataLen)
+
+	output = <-outputChan
+	assert.Equal(t, "3. next", string(output.Content))
+
+	h.Stop()
+}
@@ -0,0 +1,356 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
+)
+
+// identifierPrefix prefixes the identifiers of the buffers in the registry.
+const identifierPrefix = "disk_buffer:"
+
+// flushCheckInterval is the interval at which a flush checks whether the queue is empty.
+const flushCheckInterval = 50 * time.Millisecond
+
+// errInvalidRecord is returned when a record can not be decoded.
+var errInvalidRecord = errors.New("invalid record")
+
+// Sender sends the messages forwarded by the buffer.
+type Sender interface {
+	restart.Restartable
+	restart.Flushable
+	Throughput() metrics.Throughput
+	Backlog() metrics.Backlog
+}
+
+// Buffer sits between the processor and the sender of a pipeline, the messages are forwarded
+// to the sender as long as it keeps up and are spilled to a queue on disk otherwise, to be sent
+// in order once the sender catches up, for example when the network comes back after an outage.
+//
+// The offsets of the messages spilled are committed to the auditor as soon as they are on disk,
+// once the messages forwarded before them are sent, so that they are not collected again after a restart,
+// the position of the messages replayed from disk is then committed under the identifier of the buffer
+// when they are sent so that the queue resumes after them after a restart.
+type Buffer struct {
+	inputChan   chan *message.Message
+	senderChan  chan *message.Message
+	sentChan    chan *message.Message
+	auditorChan chan *message.Message
+	sender      Sender
+	identifier  string
+	// source is the source of the messages replayed from disk as their origin is not persisted
+	source *config.LogSource
+
+	// mu guards the queue and the offsets pending, shared by the goroutines forwarding
+	// the messages to the sender and the messages sent to the auditor
+	mu    sync.Mutex
+	queue *queue
+	// forwarded and acknowledged are the numbers of messages forwarded to the sender without being spilled
+	// and of those sent, the offsets pending are committed once the messages forwarded before them are sent
+	forwarded    uint64
+	acknowledged uint64
+	barrier      uint64
+	pending      map[string]string
+
+	flush      restart.FlushRequests
+	runDone    chan struct{}
+	commitDone chan struct{}
+}
+
+// NewBuffer returns a new buffer spilling the messages of inputChan to a queue in dir,
+// the oldest messages are dropped when the queue holds maxBytes or maxMessages, 0 means no limit.
+// The messages are forwarded to senderChan, consumed by sender which outputs them to sentChan,
+// and are finally forwarded to auditorChan, registry provides the position to resume the queue from.
+func NewBuffer(dir string, maxBytes int64, maxMessages int, registry auditor.Registry, inputChan, senderChan, sentChan, auditorChan chan *message.Message, sender Sender) (*Buffer, error) {
+	identifier := identifierPrefix + dir
+	queue, err := openQueue(dir, maxBytes, maxMessages, registry.GetOffset(identifier))
+	if err != nil {
+		return nil, err
+	}
+	return &Buffer{
+		inputChan:   inputChan,
+		senderChan:  senderChan,
+		sentChan:    sentChan,
+		auditorChan: auditorChan,
+		sender:      sender,
+		identifier:  identifier,
+		source:      config.NewLogSource("disk_buffer", &config.LogsConfig{}),
+		queue:       queue,
+		pending:     make(map[string]string),
+		flush:       restart.NewFlushRequests(),
+		runDone:     make(chan struct{}),
+		commitDone:  make(chan struct{}),
+	}, nil
+}
+
+// Start starts the buffer and its sender.
+func (b *Buffer) Start() {
+	b.sender.Start()
+	go b.run()
+	go b.commit()
+}
+
+// Stop stops the buffer and its sender, the messages left on disk are sent after the next start,
+// this call blocks until inputChan is flushed and the messages forwarded to the sender are sent.
+func (b *Buffer) Stop() {
+	close(b.inputChan)
+	<-b.runDone
+	b.sender.Stop()
+	close(b.sentChan)
+	<-b.commitDone
+	b.mu.Lock()
+	b.queue.close()
+	b.mu.Unlock()
+}
+
+// Throughput returns the logs sent and dropped so far by the sender.
+func (b *Buffer) Throughput() metrics.Throughput {
+	return b.sender.Throughput()
+}
+
+// Backlog returns the logs being sent by the sender along with the logs waiting for it, in memory and on disk,
+// the time the logs replayed from disk entered the pipeline is not persisted.
+func (b *Buffer) Backlog() metrics.Backlog {
+	backlog := b.sender.Backlog()
+	b.mu.Lock()
+	backlog.Buffered += int64(len(b.senderChan) + b.queue.records)
+	b.mu.Unlock()
+	return backlog
+}
+
+// Flush blocks until the messages of inputChan and of the queue when it is called are sent or dropped by the sender,
+// without stopping the buffer, returns an error if ctx is done first. As the messages are sent in order,
+// the messages spilled in the meantime must be sent as well for the queue to be emptied.
+func (b *Buffer) Flush(ctx context.Context) error {
+	if err := b.flush.Request(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(flushCheckInterval)
+	defer ticker.Stop()
+	for !b.isQueueEmpty() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return b.sender.Flush(ctx)
+}
+
+// run forwards the messages to the sender until inputChan is closed, spilling them to disk when the sender
+// does not keep up, the messages are spilled as long as the queue is not empty to keep them in order.
+func (b *Buffer) run() {
+	defer close(b.runDone)
+	for {
+		next := b.next()
+		if next == nil {
+			select {
+			case msg, isOpen := <-b.inputChan:
+				if !isOpen {
+					return
+				}
+				b.forward(msg)
+			case done := <-b.flush:
+				b.forwardBuffered()
+				close(done)
+			}
+			continue
+		}
+		select {
+		case msg, isOpen := <-b.inputChan:
+			if !isOpen {
+				return
+			}
+			b.spill(msg)
+		case b.senderChan <- next:
+			b.mu.Lock()
+			b.queue.pop()
+			b.mu.Unlock()
+		case done := <-b.flush:
+			b.forwardBuffered()
+			close(done)
+		}
+	}
+}
+
+// forward forwards msg to the sender if it keeps up, spills it to disk otherwise.
+func (b *Buffer) forward(msg *message.Message) {
+	select {
+	case b.senderChan <- msg:
+		b.countForwarded()
+	default:
+		b.spill(msg)
+	}
+}
+
+// forwardBuffered forwards the messages of inputChan to the sender or spills them to disk,
+// they are spilled as long as the queue is not empty to keep them in order.
+func (b *Buffer) forwardBuffered() {
+	for i := len(b.inputChan); i > 0; i-- {
+		msg := <-b.inputChan
+		if b.isQueueEmpty() {
+			b.forward(msg)
+		} else {
+			b.spill(msg)
+		}
+	}
+}
+
+// isQueueEmpty returns true if no message is waiting on disk.
+func (b *Buffer) isQueueEmpty() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queue.isEmpty()
+}
+
+// next returns the message at the head of the queue, nil if the queue is empty.
+func (b *Buffer) next() *message.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !b.queue.isEmpty() {
+		record, next, err := b.queue.peek()
+		if err == nil {
+			var msg *message.Message
+			if msg, err = decodeRecord(record); err == nil {
+				msg.Origin = message.NewOrigin(b.source)
+				msg.Origin.Identifier = b.identifier
+				msg.Origin.Offset = next.String()
+				return msg
+			}
+		}
+		log.Warnf("Could not read a message from the disk buffer: %v", err)
+		b.queue.drop()
+	}
+	return nil
+}
+
+// spill persists msg to disk, msg is forwarded to the sender instead if it can not be persisted.
+func (b *Buffer) spill(msg *message.Message) {
+	b.mu.Lock()
+	err := b.queue.push(encodeRecord(msg))
+	if err == nil {
+		if msg.Origin != nil && msg.Origin.Identifier != "" {
+			b.pending[msg.Origin.Identifier] = msg.Origin.Offset
+			b.barrier = b.forwarded
+		}
+		commits := b.takePending()
+		b.mu.Unlock()
+		b.sendCommits(commits)
+		return
+	}
+	b.mu.Unlock()
+	log.Warnf("Could not spill a message to disk, waiting for the sender: %v", err)
+	b.senderChan <- msg
+	b.countForwarded()
+}
+
+// countForwarded counts a message forwarded to the sender without being spilled.
+func (b *Buffer) countForwarded() {
+	b.mu.Lock()
+	b.forwarded++
+	b.mu.Unlock()
+}
+
+// commit forwards the messages sent to the auditor until sentChan is closed,
+// the records replayed are deleted from disk once sent.
+func (b *Buffer) commit() {
+	defer close(b.commitDone)
+	for msg := range b.sentChan {
+		b.mu.Lock()
+		if msg.Origin != nil && msg.Origin.LogSource == b.source {
+			if next, err := parsePosition(msg.Origin.Offset); err == nil {
+				b.queue.commit(next)
+			}
+		} else {
+			b.acknowledged++
+		}
+		b.mu.Unlock()
+		b.auditorChan <- msg
+		b.mu.Lock()
+		commits := b.takePending()
+		b.mu.Unlock()
+		b.sendCommits(commits)
+	}
+}
+
+// takePending returns the commits of the offsets of the messages spilled once all the messages
+// forwarded to the sender before them are sent, so that no offset goes backward, it must be called with mu held
+// and the commits sent once mu is released so that a slow auditor does not block the goroutines spilling.
+func (b *Buffer) takePending() []*message.Message {
+	if len(b.pending) == 0 || b.acknowledged < b.barrier {
+		return nil
+	}
+	commits := make([]*message.Message, 0, len(b.pending))
+	for identifier, offset := range b.pending {
+		origin := message.NewOrigin(b.source)
+		origin.Identifier = identifier
+		origin.Offset = offset
+		commits = append(commits, message.NewMessage(nil, origin, ""))
+	}
+	b.pending = make(map[string]string)
+	return commits
+}
+
+// sendCommits forwards commits to the auditor.
+func (b *Buffer) sendCommits(commits []*message.Message) {
+	for _, commit := range commits {
+		b.auditorChan <- commit
+	}
+}
+
+// encodeRecord returns the record persisting the timestamp, the status and the content of msg.
+func encodeRecord(msg *message.Message) []byte {
+	status := msg.GetStatus()
+	record := make([]byte, 0, 2*binary.MaxVarintLen64+len(msg.Timestamp)+len(status)+len(msg.Content))
+	record = appendString(record, msg.Timestamp)
+	record = appendString(record, status)
+	return append(record, msg.Content...)
+}
+
+// appendString appends value prefixed by its length to record.
+func appendString(record []byte, value string) []byte {
+	length := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(length, uint64(len(value)))
+	record = append(record, length[:n]...)
+	return append(record, value...)
+}
+
+// decodeRecord returns the message persisted in record, without origin.
+func decodeRecord(record []byte) (*message.Message, error) {
+	timestamp, record, err := readString(record)
+	if err != nil {
+		return nil, err
+	}
+	status, content, err := readString(record)
+	if err != nil {
+		return nil, err
+	}
+	msg := message.NewMessage(content, nil, status)
+	msg.Timestamp = timestamp
+	return msg, nil
+}
+
+// readString returns the string prefixed by its length at the start of record and what follows it.
+func readString(record []byte) (string, []byte, error) {
+	length, n := binary.Uvarint(record)
+	if n <= 0 || uint64(len(record)-n) < length {
+		return "", nil, errInvalidRecord
+	}
+	end := n + int(length)
+	return string(record[n:end]), record[end:], nil
+}
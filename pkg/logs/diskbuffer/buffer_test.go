@@ -0,0 +1,306 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// fakeRegistry returns the offsets set by the test.
+type fakeRegistry map[string]string
+
+func (r fakeRegistry) GetOffset(identifier string) string {
+	return r[identifier]
+}
+
+// fakeSender forwards its messages to its output once connected.
+type fakeSender struct {
+	inputChan  chan *message.Message
+	outputChan chan *message.Message
+	backlog    metrics.Backlog
+	connected  chan struct{}
+	connect    sync.Once
+	done       chan struct{}
+}
+
+func newFakeSender(inputChan, outputChan chan *message.Message) *fakeSender {
+	return &fakeSender{
+		inputChan:  inputChan,
+		outputChan: outputChan,
+		connected:  make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+func (s *fakeSender) Start() {
+	go func() {
+		defer close(s.done)
+		<-s.connected
+		for msg := range s.inputChan {
+			s.outputChan <- msg
+		}
+	}()
+}
+
+func (s *fakeSender) Stop() {
+	close(s.inputChan)
+	s.Connect()
+	<-s.done
+}
+
+func (s *fakeSender) Connect() {
+	s.connect.Do(func() {
+		close(s.connected)
+	})
+}
+
+// Flush waits for the messages of the input to be read.
+func (s *fakeSender) Flush(ctx context.Context) error {
+	for len(s.inputChan) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return nil
+}
+
+func (s *fakeSender) Throughput() metrics.Throughput {
+	return metrics.Throughput{}
+}
+
+func (s *fakeSender) Backlog() metrics.Backlog {
+	return s.backlog
+}
+
+type BufferTestSuite struct {
+	suite.Suite
+	dir         string
+	registry    fakeRegistry
+	source      *config.LogSource
+	inputChan   chan *message.Message
+	auditorChan chan *message.Message
+	sender      *fakeSender
+	buffer      *Buffer
+}
+
+func (suite *BufferTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "disk-buffer")
+	suite.Nil(err)
+	suite.registry = make(fakeRegistry)
+	suite.source = config.NewLogSource("", &config.LogsConfig{})
+	suite.auditorChan = make(chan *message.Message, 10)
+	suite.newBuffer()
+}
+
+func (suite *BufferTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+// newBuffer creates a buffer of which the sender is disconnected and can only hold one message.
+func (suite *BufferTestSuite) newBuffer() {
+	suite.inputChan = make(chan *message.Message)
+	senderChan := make(chan *message.Message, 1)
+	sentChan := make(chan *message.Message)
+	suite.sender = newFakeSender(senderChan, sentChan)
+	var err error
+	suite.buffer, err = NewBuffer(suite.dir, 0, 0, suite.registry, suite.inputChan, senderChan, sentChan, suite.auditorChan, suite.sender)
+	suite.Nil(err)
+	suite.buffer.Start()
+}
+
+func (suite *BufferTestSuite) newMessage(content, offset string) *message.Message {
+	origin := message.NewOrigin(suite.source)
+	origin.Identifier = "file:/var/log/foo.log"
+	origin.Offset = offset
+	msg := message.NewMessage([]byte(content), origin, message.StatusError)
+	msg.Timestamp = "2018-01-01T00:00:00Z"
+	return msg
+}
+
+// receive returns the next message committed and registers its offset.
+func (suite *BufferTestSuite) receive() *message.Message {
+	msg := <-suite.auditorChan
+	suite.registry[msg.Origin.Identifier] = msg.Origin.Offset
+	return msg
+}
+
+// stop stops the buffer and registers the offsets committed.
+func (suite *BufferTestSuite) stop() {
+	suite.buffer.Stop()
+	close(suite.auditorChan)
+	for len(suite.auditorChan) > 0 {
+		suite.receive()
+	}
+	suite.auditorChan = make(chan *message.Message, 10)
+}
+
+func (suite *BufferTestSuite) TestBufferForwardsTheMessagesWhenTheSenderKeepsUp() {
+	suite.sender.Connect()
+	suite.inputChan <- suite.newMessage("foo", "1")
+	msg := suite.receive()
+	suite.Equal("foo", string(msg.Content))
+	suite.Equal(suite.source, msg.Origin.LogSource)
+	suite.stop()
+	suite.Equal("1", suite.registry["file:/var/log/foo.log"])
+}
+
+func (suite *BufferTestSuite) TestBufferReplaysTheMessagesSpilledInOrder() {
+	for i, content := range []string{"foo", "bar", "baz"} {
+		suite.inputChan <- suite.newMessage(content, string(rune('1'+i)))
+	}
+	// only the first message fits in the sender, the others are spilled
+	suite.sender.Connect()
+	for _, content := range []string{"foo", "bar", "baz"} {
+		msg := suite.receive()
+		if msg.Content == nil {
+			// the offset of the messages spilled, committed once the first message is sent
+			suite.Equal("file:/var/log/foo.log", msg.Origin.Identifier)
+			suite.Equal("3", msg.Origin.Offset)
+			msg = suite.receive()
+		}
+		suite.Equal(content, string(msg.Content))
+		suite.Equal(message.StatusError, msg.GetStatus())
+		suite.Equal("2018-01-01T00:00:00Z", msg.Timestamp)
+	}
+	suite.stop()
+	suite.Equal("3", suite.registry["file:/var/log/foo.log"])
+}
+
+func (suite *BufferTestSuite) TestBufferResumesAfterARestart() {
+	for i, content := range []string{"foo", "bar", "baz"} {
+		suite.inputChan <- suite.newMessage(content, string(rune('1'+i)))
+	}
+	// the sender only connects while stopping and sends the message it holds
+	suite.stop()
+	suite.Equal("3", suite.registry["file:/var/log/foo.log"])
+
+	suite.newBuffer()
+	suite.sender.Connect()
+	for _, content := range []string{"bar", "baz"} {
+		msg := suite.receive()
+		suite.Equal(content, string(msg.Content))
+	}
+	suite.stop()
+
+	// the messages replayed are committed and not sent again
+	suite.newBuffer()
+	suite.buffer.mu.Lock()
+	suite.True(suite.buffer.queue.isEmpty())
+	suite.buffer.mu.Unlock()
+	suite.stop()
+}
+
+func (suite *BufferTestSuite) TestBufferFlushesTheMessagesSpilled() {
+	for i, content := range []string{"foo", "bar", "baz"} {
+		suite.inputChan <- suite.newMessage(content, string(rune('1'+i)))
+	}
+
+	// the messages spilled can not be sent while the sender is disconnected
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	suite.Equal(context.DeadlineExceeded, suite.buffer.Flush(ctx))
+
+	suite.sender.Connect()
+	suite.Nil(suite.buffer.Flush(context.Background()))
+	suite.True(suite.buffer.isQueueEmpty())
+	suite.stop()
+	suite.Equal("3", suite.registry["file:/var/log/foo.log"])
+}
+
+func (suite *BufferTestSuite) TestBufferCountsTheMessagesWaitingForTheSender() {
+	oldest := time.Now().Add(-time.Minute)
+	suite.sender.backlog = metrics.Backlog{Buffered: 1, Oldest: oldest}
+	for i, content := range []string{"foo", "bar", "baz"} {
+		suite.inputChan <- suite.newMessage(content, string(rune('1'+i)))
+	}
+	// wait for the messages to be spilled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	suite.Equal(context.DeadlineExceeded, suite.buffer.Flush(ctx))
+
+	// one message waits in memory for the sender and two on disk
+	suite.Equal(metrics.Backlog{Buffered: 4, Oldest: oldest}, suite.buffer.Backlog())
+
+	suite.sender.Connect()
+	suite.Nil(suite.buffer.Flush(context.Background()))
+	suite.Equal(int64(1), suite.buffer.Backlog().Buffered)
+	suite.stop()
+}
+
+func (suite *BufferTestSuite) TestBufferDoesNotHoldTheLockWhileCommitting() {
+	suite.stop()
+	// the auditor does not read the offsets committed until the test receives them
+	suite.auditorChan = make(chan *message.Message)
+	suite.newBuffer()
+
+	spilled := make(chan struct{})
+	go func() {
+		defer close(spilled)
+		suite.buffer.spill(suite.newMessage("foo", "1"))
+	}()
+	// give the buffer some time to block on the auditor
+	time.Sleep(10 * time.Millisecond)
+
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		suite.buffer.isQueueEmpty()
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		suite.Fail("the lock is held while committing")
+	}
+
+	msg := suite.receive()
+	suite.Nil(msg.Content)
+	suite.Equal("1", msg.Origin.Offset)
+	<-spilled
+
+	// the message spilled is replayed while stopping
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for range suite.auditorChan {
+		}
+	}()
+	suite.buffer.Stop()
+	close(suite.auditorChan)
+	<-received
+}
+
+func TestBufferTestSuite(t *testing.T) {
+	suite.Run(t, new(BufferTestSuite))
+}
+
+func TestEncodeRecord(t *testing.T) {
+	msg := message.NewMessage([]byte("foo"), nil, message.StatusWarning)
+	msg.Timestamp = "2018-01-01T00:00:00Z"
+
+	decoded, err := decodeRecord(encodeRecord(msg))
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", string(decoded.Content))
+	assert.Equal(t, message.StatusWarning, decoded.GetStatus())
+	assert.Equal(t, "2018-01-01T00:00:00Z", decoded.Timestamp)
+
+	_, err = decodeRecord([]byte{10, 'a'})
+	assert.Equal(t, errInvalidRecord, err)
+}
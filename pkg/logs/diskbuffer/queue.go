@@ -0,0 +1,441 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+const (
+	// segmentExtension is the extension of the segment files.
+	segmentExtension = ".buf"
+	// maxSegmentSize is the size above which a new segment is started.
+	maxSegmentSize = 4 * 1024 * 1024
+	// recordHeaderSize is the size of the length prefixing each record.
+	recordHeaderSize = 4
+)
+
+// errRecordTooLarge is returned when a record does not fit in the queue.
+var errRecordTooLarge = errors.New("the record is larger than the queue")
+
+// position is the location of a record in the queue.
+type position struct {
+	segment uint64
+	offset  int64
+}
+
+// String returns the position formatted as '<segment>:<offset>'.
+func (p position) String() string {
+	return fmt.Sprintf("%d:%d", p.segment, p.offset)
+}
+
+// parsePosition returns the position formatted as '<segment>:<offset>'.
+func parsePosition(value string) (position, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return position{}, fmt.Errorf("invalid position: %v", value)
+	}
+	segment, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return position{}, fmt.Errorf("invalid position: %v", value)
+	}
+	offset, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || offset < 0 {
+		return position{}, fmt.Errorf("invalid position: %v", value)
+	}
+	return position{segment: segment, offset: offset}, nil
+}
+
+// segment is a file holding records one after the other, each prefixed by its length.
+type segment struct {
+	id   uint64
+	path string
+	size int64
+	// bytes and records are the size and the number of the records of the segment not read yet
+	bytes   int64
+	records int
+	// read is true once one of the records of the segment has been read,
+	// the segment is then deleted when the records are committed, not when the last one is read.
+	read bool
+}
+
+// queue is a FIFO of records persisted in segment files, the records read are only deleted
+// from the disk once committed so that they are read again after a restart if they were not.
+// The oldest records are dropped to make room for the new ones when the queue is full.
+// A queue is not safe for concurrent use.
+type queue struct {
+	dir         string
+	maxBytes    int64
+	maxRecords  int
+	segmentSize int64
+	segments    []*segment
+	// nextID is the id of the next segment created, always above the ids of the segments committed
+	nextID   uint64
+	head     position
+	headFile *os.File
+	tailFile *os.File
+	bytes    int64
+	records  int
+	// peeked is the record at head, nil until it is read
+	peeked     []byte
+	peekedNext position
+}
+
+// openQueue returns the queue persisted in dir, created if need be, the records before cursor
+// are considered already committed, maxBytes and maxRecords are the capacity of the queue, 0 means no limit.
+func openQueue(dir string, maxBytes int64, maxRecords int, cursor string) (*queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	segmentSize := int64(maxSegmentSize)
+	if maxBytes > 0 && maxBytes/4 < segmentSize {
+		// keep a few segments so that dropping the oldest records frees the disk progressively
+		segmentSize = maxBytes / 4
+	}
+	q := &queue{
+		dir:         dir,
+		maxBytes:    maxBytes,
+		maxRecords:  maxRecords,
+		segmentSize: segmentSize,
+		nextID:      1,
+	}
+	segments, err := q.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	var start position
+	if cursor != "" {
+		if start, err = parsePosition(cursor); err != nil {
+			log.Warnf("Could not resume the disk buffer at %v, replaying it from the start: %v", cursor, err)
+		} else {
+			q.nextID = start.segment + 1
+		}
+	}
+	for _, seg := range segments {
+		if seg.id < start.segment {
+			// all the records of the segment were committed
+			q.remove(seg)
+			continue
+		}
+		if err := q.scan(seg, start); err != nil {
+			return nil, err
+		}
+		q.segments = append(q.segments, seg)
+		q.bytes += seg.bytes
+		q.records += seg.records
+		q.nextID = seg.id + 1
+	}
+	if len(q.segments) > 0 {
+		q.head = position{segment: q.segments[0].id}
+		if q.segments[0].id == start.segment {
+			q.head.offset = start.offset
+		}
+	}
+	return q, nil
+}
+
+// listSegments returns the segments of the queue directory ordered by id.
+func (q *queue) listSegments() ([]*segment, error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []*segment
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, &segment{id: id, path: filepath.Join(q.dir, name), size: file.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].id < segments[j].id
+	})
+	return segments, nil
+}
+
+// scan counts the records of seg after start and truncates the record left incomplete
+// when the agent stopped while writing it.
+func (q *queue) scan(seg *segment, start position) error {
+	file, err := os.OpenFile(seg.path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+	for offset < seg.size {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			break
+		}
+		next := offset + recordHeaderSize + int64(binary.BigEndian.Uint32(header))
+		if next > seg.size {
+			break
+		}
+		if seg.id != start.segment || offset >= start.offset {
+			seg.bytes += next - offset
+			seg.records++
+		}
+		offset = next
+	}
+	if offset < seg.size {
+		log.Warnf("Truncating the incomplete record at the end of %v", seg.path)
+		seg.size = offset
+		return file.Truncate(offset)
+	}
+	return nil
+}
+
+// isEmpty returns true if there is no record to read.
+func (q *queue) isEmpty() bool {
+	return q.records == 0
+}
+
+// push appends record to the queue, the oldest records are dropped if there is not enough room.
+func (q *queue) push(record []byte) error {
+	size := int64(recordHeaderSize + len(record))
+	if q.maxBytes > 0 && size > q.maxBytes {
+		return errRecordTooLarge
+	}
+	for !q.isEmpty() && ((q.maxBytes > 0 && q.bytes+size > q.maxBytes) || (q.maxRecords > 0 && q.records >= q.maxRecords)) {
+		q.drop()
+	}
+	tail, err := q.tail(size)
+	if err != nil {
+		return err
+	}
+	buffer := make([]byte, size)
+	binary.BigEndian.PutUint32(buffer, uint32(len(record)))
+	copy(buffer[recordHeaderSize:], record)
+	if _, err := q.tailFile.Write(buffer); err != nil {
+		// the record may be partially written
+		q.tailFile.Truncate(tail.size)
+		q.tailFile.Close()
+		q.tailFile = nil
+		return err
+	}
+	tail.size += size
+	tail.bytes += size
+	tail.records++
+	q.bytes += size
+	q.records++
+	return nil
+}
+
+// tail returns the segment to append a record of size to, a new one is created when the last one is full.
+func (q *queue) tail(size int64) (*segment, error) {
+	if len(q.segments) > 0 {
+		last := q.segments[len(q.segments)-1]
+		if last.size == 0 || last.size+size <= q.segmentSize {
+			if q.tailFile == nil {
+				file, err := os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					return nil, err
+				}
+				q.tailFile = file
+			}
+			return last, nil
+		}
+	}
+	id := q.nextID
+	path := filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentExtension))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if q.tailFile != nil {
+		q.tailFile.Close()
+	}
+	q.tailFile = file
+	q.nextID++
+	seg := &segment{id: id, path: path}
+	q.segments = append(q.segments, seg)
+	if q.records == 0 {
+		// there is no record left to read in the previous segments
+		q.leaveHead()
+		q.head = position{segment: id}
+	}
+	return seg, nil
+}
+
+// peek returns the record at the head of the queue and the position of the next one,
+// the queue must not be empty.
+func (q *queue) peek() ([]byte, position, error) {
+	if q.peeked != nil {
+		return q.peeked, q.peekedNext, nil
+	}
+	seg := q.segmentAt(q.head.segment)
+	for seg != nil && q.head.offset >= seg.size {
+		// all the records of the segment were read
+		next := q.segmentAfter(seg.id)
+		if next == nil {
+			return nil, position{}, io.EOF
+		}
+		q.leaveHead()
+		q.head = position{segment: next.id}
+		seg = next
+	}
+	if seg == nil {
+		return nil, position{}, io.EOF
+	}
+	if q.headFile == nil {
+		file, err := os.Open(seg.path)
+		if err != nil {
+			return nil, position{}, err
+		}
+		q.headFile = file
+	}
+	header := make([]byte, recordHeaderSize)
+	if _, err := q.headFile.ReadAt(header, q.head.offset); err != nil {
+		return nil, position{}, err
+	}
+	next := q.head.offset + recordHeaderSize + int64(binary.BigEndian.Uint32(header))
+	if next > seg.size {
+		return nil, position{}, fmt.Errorf("corrupted record at %v", q.head)
+	}
+	record := make([]byte, next-q.head.offset-recordHeaderSize)
+	if _, err := q.headFile.ReadAt(record, q.head.offset+recordHeaderSize); err != nil {
+		return nil, position{}, err
+	}
+	q.peeked = record
+	q.peekedNext = position{segment: seg.id, offset: next}
+	return q.peeked, q.peekedNext, nil
+}
+
+// pop removes the record at the head of the queue, the record is still on disk until committed.
+func (q *queue) pop() {
+	if _, _, err := q.peek(); err != nil {
+		q.skip(err)
+		return
+	}
+	q.segmentAt(q.head.segment).read = true
+	q.advance()
+}
+
+// drop removes the record at the head of the queue to make room for a new one.
+func (q *queue) drop() {
+	if _, _, err := q.peek(); err != nil {
+		q.skip(err)
+		return
+	}
+	q.advance()
+	metrics.DiskBufferDrops.Add(1)
+}
+
+// advance moves head after the record peeked.
+func (q *queue) advance() {
+	seg := q.segmentAt(q.head.segment)
+	size := q.peekedNext.offset - q.head.offset
+	seg.bytes -= size
+	seg.records--
+	q.bytes -= size
+	q.records--
+	q.head = q.peekedNext
+	q.peeked = nil
+}
+
+// skip drops the records left in the head segment as they can not be read.
+func (q *queue) skip(err error) {
+	seg := q.segmentAt(q.head.segment)
+	if seg == nil {
+		q.bytes, q.records = 0, 0
+		return
+	}
+	log.Warnf("Dropping %d records of %v which can not be read: %v", seg.records, seg.path, err)
+	metrics.DiskBufferDrops.Add(int64(seg.records))
+	q.bytes -= seg.bytes
+	q.records -= seg.records
+	seg.bytes, seg.records = 0, 0
+	q.head.offset = seg.size
+	q.peeked = nil
+}
+
+// leaveHead closes the head segment, which is deleted unless some of its records
+// were read and are not committed yet, or if it is the tail.
+func (q *queue) leaveHead() {
+	if q.headFile != nil {
+		q.headFile.Close()
+		q.headFile = nil
+	}
+	seg := q.segmentAt(q.head.segment)
+	if seg == nil || seg.read || seg == q.segments[len(q.segments)-1] {
+		return
+	}
+	q.remove(seg)
+	for i := range q.segments {
+		if q.segments[i] == seg {
+			q.segments = append(q.segments[:i], q.segments[i+1:]...)
+			break
+		}
+	}
+}
+
+// commit deletes the segments of which all the records before p were read.
+func (q *queue) commit(p position) {
+	for len(q.segments) > 0 {
+		seg := q.segments[0]
+		if seg.id >= q.head.segment || seg.id > p.segment || (seg.id == p.segment && p.offset < seg.size) {
+			return
+		}
+		q.remove(seg)
+		q.segments = q.segments[1:]
+	}
+}
+
+// remove deletes the file of seg.
+func (q *queue) remove(seg *segment) {
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Could not delete %v: %v", seg.path, err)
+	}
+}
+// segmentAt returns the segment with id, nil if there is none.
+func (q *queue) segmentAt(id uint64) *segment {
+	for _, seg := range q.segments {
+		if seg.id == id {
+			return seg
+		}
+	}
+	return nil
+}
+
+// segmentAfter returns the segment following the one with id, nil if there is none.
+func (q *queue) segmentAfter(id uint64) *segment {
+	for _, seg := range q.segments {
+		if seg.id > id {
+			return seg
+		}
+	}
+	return nil
+}
+
+// close closes the files of the queue, the records are left on disk.
+func (q *queue) close() {
+	if q.headFile != nil {
+		q.headFile.Close()
+		q.headFile = nil
+	}
+	if q.tailFile != nil {
+		q.tailFile.Close()
+		q.tailFile = nil
+	}
+}
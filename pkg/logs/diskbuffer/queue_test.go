@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+type QueueTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *QueueTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "disk-buffer")
+	suite.Nil(err)
+}
+
+func (suite *QueueTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *QueueTestSuite) pop(q *queue) (string, position) {
+	record, next, err := q.peek()
+	suite.Nil(err)
+	q.pop()
+	return string(record), next
+}
+
+func (suite *QueueTestSuite) countSegments() int {
+	files, err := filepath.Glob(filepath.Join(suite.dir, "*"+segmentExtension))
+	suite.Nil(err)
+	return len(files)
+}
+
+func (suite *QueueTestSuite) TestQueueReturnsTheRecordsInOrder() {
+	q, err := openQueue(suite.dir, 0, 0, "")
+	suite.Nil(err)
+	defer q.close()
+	suite.True(q.isEmpty())
+
+	suite.Nil(q.push([]byte("foo")))
+	suite.Nil(q.push([]byte("bar")))
+	suite.False(q.isEmpty())
+
+	record, _ := suite.pop(q)
+	suite.Equal("foo", record)
+	record, next := suite.pop(q)
+	suite.Equal("bar", record)
+	suite.Equal(position{segment: 1, offset: 14}, next)
+	suite.True(q.isEmpty())
+}
+
+func (suite *QueueTestSuite) TestQueueDropsTheOldestRecordsWhenFull() {
+	drops := metrics.DiskBufferDrops.Value()
+	q, err := openQueue(suite.dir, 0, 2, "")
+	suite.Nil(err)
+	defer q.close()
+
+	suite.Nil(q.push([]byte("foo")))
+	suite.Nil(q.push([]byte("bar")))
+	suite.Nil(q.push([]byte("baz")))
+	suite.Equal(drops+1, metrics.DiskBufferDrops.Value())
+
+	record, _ := suite.pop(q)
+	suite.Equal("bar", record)
+	record, _ = suite.pop(q)
+	suite.Equal("baz", record)
+	suite.True(q.isEmpty())
+}
+
+func (suite *QueueTestSuite) TestQueueDropsTheOldestRecordsWhenTooLarge() {
+	// each record takes 7 bytes
+	q, err := openQueue(suite.dir, 14, 0, "")
+	suite.Nil(err)
+	defer q.close()
+
+	suite.Nil(q.push([]byte("foo")))
+	suite.Nil(q.push([]byte("bar")))
+	suite.Nil(q.push([]byte("baz")))
+	suite.Equal(errRecordTooLarge, q.push([]byte("far too large")))
+
+	record, _ := suite.pop(q)
+	suite.Equal("bar", record)
+	record, _ = suite.pop(q)
+	suite.Equal("baz", record)
+	suite.True(q.isEmpty())
+}
+
+func (suite *QueueTestSuite) TestQueueResumesAfterTheCommittedRecords() {
+	q, err := openQueue(suite.dir, 0, 0, "")
+	suite.Nil(err)
+	suite.Nil(q.push([]byte("foo")))
+	suite.Nil(q.push([]byte("bar")))
+	suite.Nil(q.push([]byte("baz")))
+	_, next := suite.pop(q)
+	suite.pop(q)
+	q.close()
+
+	// only the first record was committed
+	q, err = openQueue(suite.dir, 0, 0, next.String())
+	suite.Nil(err)
+	defer q.close()
+	record, _ := suite.pop(q)
+	suite.Equal("bar", record)
+	record, _ = suite.pop(q)
+	suite.Equal("baz", record)
+	suite.True(q.isEmpty())
+}
+
+func (suite *QueueTestSuite) TestQueueDeletesTheSegmentsCommitted() {
+	// each segment holds two records of 7 bytes
+	q, err := openQueue(suite.dir, 56, 0, "")
+	suite.Nil(err)
+	defer q.close()
+	for _, record := range []string{"foo", "bar", "baz", "qux"} {
+		suite.Nil(q.push([]byte(record)))
+	}
+	suite.Equal(2, suite.countSegments())
+
+	suite.pop(q)
+	_, next := suite.pop(q)
+	suite.pop(q)
+	q.commit(next)
+	suite.Equal(1, suite.countSegments())
+}
+
+func (suite *QueueTestSuite) TestQueueTruncatesTheIncompleteRecords() {
+	q, err := openQueue(suite.dir, 0, 0, "")
+	suite.Nil(err)
+	suite.Nil(q.push([]byte("foo")))
+	suite.Nil(q.push([]byte("bar")))
+	q.close()
+
+	// the agent stopped while writing the second record
+	path := filepath.Join(suite.dir, "00000000000000000001"+segmentExtension)
+	suite.Nil(os.Truncate(path, 10))
+
+	q, err = openQueue(suite.dir, 0, 0, "")
+	suite.Nil(err)
+	defer q.close()
+	record, _ := suite.pop(q)
+	suite.Equal("foo", record)
+	suite.True(q.isEmpty())
+	suite.Nil(q.push([]byte("baz")))
+	record, _ = suite.pop(q)
+	suite.Equal("baz", record)
+}
+
+func TestQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueTestSuite))
+}
+
+func TestParsePosition(t *testing.T) {
+	p, err := parsePosition("12:345")
+	assert.Nil(t, err)
+	assert.Equal(t, position{segment: 12, offset: 345}, p)
+	assert.Equal(t, "12:345", p.String())
+
+	for _, value := range []string{"", "12", "a:1", "1:-1", "1:2:3"} {
+		_, err = parsePosition(value)
+		assert.NotNil(t, err)
+	}
+}
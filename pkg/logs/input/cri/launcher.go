@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package cri
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// scanPeriod is the period of time between two scans of the pods directory.
+const scanPeriod = 10 * time.Second
+
+// Launcher collects the logs the container runtimes implementing the CRI, like containerd or CRI-O, write
+// in the pods directory, without relying on the runtime nor on the kubelet.
+// The logs of each container are stored in <pods-directory>/<namespace>_<pod-name>_<pod-uid>/<container-name>/<restart-count>.log,
+// or in <pods-directory>/<pod-uid>/<container-name>/<restart-count>.log with the legacy layout,
+// the launcher adds a file source parsing the CRI format per container directory and removes it once the directory is deleted.
+type Launcher struct {
+	sources            *config.LogSources
+	podsPath           string
+	scanPeriod         time.Duration
+	sourcesByContainer map[string]*config.LogSource
+	stop               chan struct{}
+	done               chan struct{}
+}
+
+// NewLauncher returns a new launcher collecting the logs of the containers stored in podsPath.
+func NewLauncher(sources *config.LogSources, podsPath string) *Launcher {
+	return &Launcher{
+		sources:            sources,
+		podsPath:           podsPath,
+		scanPeriod:         scanPeriod,
+		sourcesByContainer: make(map[string]*config.LogSource),
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+}
+
+// Start starts the launcher.
+func (l *Launcher) Start() {
+	log.Infof("Starting the CRI launcher collecting the logs of %s", l.podsPath)
+	go l.run()
+}
+
+// Stop stops the launcher, the sources added are kept.
+func (l *Launcher) Stop() {
+	close(l.stop)
+	<-l.done
+}
+
+// run scans the pods directory periodically until the launcher is stopped.
+func (l *Launcher) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.scanPeriod)
+	defer ticker.Stop()
+	l.scan()
+	for {
+		select {
+		case <-ticker.C:
+			l.scan()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// scan adds the sources of the new container directories and removes the ones of the directories deleted.
+func (l *Launcher) scan() {
+	paths, err := filepath.Glob(filepath.Join(l.podsPath, "*", "*"))
+	if err != nil {
+		log.Warnf("Could not list the containers of %s: %v", l.podsPath, err)
+		return
+	}
+	containers := make(map[string]bool)
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+			continue
+		}
+		containers[path] = true
+		if _, exists := l.sourcesByContainer[path]; exists {
+			continue
+		}
+		source, err := newSource(path)
+		if err != nil {
+			log.Warnf("Could not collect the logs of the container %s: %v", path, err)
+			continue
+		}
+		l.sourcesByContainer[path] = source
+		l.sources.AddSource(source)
+	}
+	for path, source := range l.sourcesByContainer {
+		if !containers[path] {
+			delete(l.sourcesByContainer, path)
+			l.sources.RemoveSource(source)
+		}
+	}
+}
+
+// pod identifies the pod owning a container directory.
+type pod struct {
+	namespace string
+	name      string
+	uid       string
+}
+
+// parsePodDirectory returns the pod named by the directory name,
+// only the uid is known with the legacy layout.
+func parsePodDirectory(name string) pod {
+	parts := strings.Split(name, "_")
+	if len(parts) != 3 {
+		return pod{uid: name}
+	}
+	return pod{
+		namespace: parts[0],
+		name:      parts[1],
+		uid:       parts[2],
+	}
+}
+
+// newSource returns a new source tailing the logs of the container stored in path,
+// the source and the service of the logs are the name of the container.
+func newSource(path string) (*config.LogSource, error) {
+	containerName := filepath.Base(path)
+	pod := parsePodDirectory(filepath.Base(filepath.Dir(path)))
+
+	tags := []string{"kube_container_name:" + containerName, "pod_uid:" + pod.uid}
+	sourceName := pod.uid + "/" + containerName
+	if pod.name != "" {
+		tags = append(tags, "kube_namespace:"+pod.namespace, "pod_name:"+pod.name)
+		sourceName = fmt.Sprintf("%s/%s/%s", pod.namespace, pod.name, containerName)
+	}
+
+	cfg := &config.LogsConfig{
+		Type:    config.FileType,
+		Path:    filepath.Join(path, "*.log"),
+		Source:  containerName,
+		Service: containerName,
+		Tags:    tags,
+		// the logs written before the container was found are collected as well,
+		// the offsets recorded take precedence after a restart
+		StartPosition: config.BeginningStartPosition,
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	source := config.NewLogSource(sourceName, cfg)
+	source.SetSourceType(config.ContainerdType)
+	return source, nil
+}
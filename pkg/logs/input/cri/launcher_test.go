@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package cri
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func TestLauncherAddsAndRemovesTheSourcesOfTheContainers(t *testing.T) {
+	podsPath, err := ioutil.TempDir("", "cri-launcher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(podsPath)
+
+	container := filepath.Join(podsPath, "default_nginx-1234_0bbe9b5e-1bb0-4b2a-8d1f-0c5f8f0e0c1a", "nginx")
+	legacyContainer := filepath.Join(podsPath, "0bbe9b5e-1bb0-4b2a-8d1f-0c5f8f0e0c1b", "redis")
+	assert.Nil(t, os.MkdirAll(container, 0755))
+	assert.Nil(t, os.MkdirAll(legacyContainer, 0755))
+	// only the directories are containers
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(podsPath, "default_nginx-1234_0bbe9b5e-1bb0-4b2a-8d1f-0c5f8f0e0c1a", "foo.log"), nil, 0644))
+
+	sources := config.NewLogSources()
+	launcher := NewLauncher(sources, podsPath)
+	launcher.scan()
+	assert.Equal(t, 2, len(sources.GetSources()))
+
+	source := launcher.sourcesByContainer[container]
+	assert.NotNil(t, source)
+	assert.Equal(t, "default/nginx-1234/nginx", source.Name)
+	assert.Equal(t, config.FileType, source.Config.Type)
+	assert.Equal(t, filepath.Join(container, "*.log"), source.Config.Path)
+	assert.Equal(t, "nginx", source.Config.Source)
+	assert.Equal(t, "nginx", source.Config.Service)
+	assert.Equal(t, config.ContainerdType, source.GetSourceType())
+	assert.Equal(t, config.BeginningStartPosition, source.Config.StartPosition)
+	assert.ElementsMatch(t, []string{"kube_container_name:nginx", "pod_uid:0bbe9b5e-1bb0-4b2a-8d1f-0c5f8f0e0c1a", "kube_namespace:default", "pod_name:nginx-1234"}, source.Config.Tags)
+
+	source = launcher.sourcesByContainer[legacyContainer]
+	assert.NotNil(t, source)
+	assert.Equal(t, "0bbe9b5e-1bb0-4b2a-8d1f-0c5f8f0e0c1b/redis", source.Name)
+	assert.ElementsMatch(t, []string{"kube_container_name:redis", "pod_uid:0bbe9b5e-1bb0-4b2a-8d1f-0c5f8f0e0c1b"}, source.Config.Tags)
+
+	// the sources are added once
+	launcher.scan()
+	assert.Equal(t, 2, len(sources.GetSources()))
+
+	assert.Nil(t, os.RemoveAll(filepath.Dir(legacyContainer)))
+	launcher.scan()
+	assert.Equal(t, 1, len(sources.GetSources()))
+	assert.Equal(t, "default/nginx-1234/nginx", sources.GetSources()[0].Name)
+}
+
+func TestParsePodDirectory(t *testing.T) {
+	assert.Equal(t, pod{namespace: "default", name: "nginx", uid: "1234"}, parsePodDirectory("default_nginx_1234"))
+	assert.Equal(t, pod{uid: "1234"}, parsePodDirectory("1234"))
+}
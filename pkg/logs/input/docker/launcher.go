@@ -8,11 +8,13 @@
 package docker
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/tagger"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
@@ -42,6 +44,12 @@ type Launcher struct {
 	stop               chan struct{}
 	erroredContainerID chan string
 	lock               *sync.Mutex
+	staticTags         *staticTagsCache
+	// tailFromStart makes the containers launched before the agent start be tailed from their creation,
+	// historyMaxAge back at most and up to historyMaxBytes of logs emitted before the tailer started
+	tailFromStart   bool
+	historyMaxAge   time.Duration
+	historyMaxBytes int
 }
 
 // NewLauncher returns a new launcher
@@ -54,11 +62,19 @@ func NewLauncher(sources *config.LogSources, services *service.Services, pipelin
 		stop:               make(chan struct{}),
 		erroredContainerID: make(chan string),
 		lock:               &sync.Mutex{},
+		tailFromStart:      config.LogsAgent.GetBool("logs_config.container_tail_from_start"),
+		historyMaxAge:      time.Duration(config.LogsAgent.GetInt("logs_config.container_tail_from_start_max_age")) * time.Second,
+		historyMaxBytes:    config.LogsAgent.GetInt("logs_config.container_tail_from_start_max_bytes"),
 	}
 	err := launcher.setup()
 	if err != nil {
 		return nil, err
 	}
+	launcher.staticTags = newStaticTagsCache(
+		config.LogsAgent.GetStringSlice("logs_config.container_env_as_tags"),
+		config.LogsAgent.GetStringSlice("logs_config.container_labels_as_tags"),
+		launcher.inspect,
+	)
 	// Sources and services are added after the setup to avoid creating
 	// a channel that will lock the scheduler in case of setup failure
 	// FIXME(achntrl): Find a better way of choosing the right launcher
@@ -87,6 +103,13 @@ func (l *Launcher) setup() error {
 	return nil
 }
 
+// inspect returns the inspect data of the container.
+func (l *Launcher) inspect(containerID string) (types.ContainerJSON, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), inspectTimeout)
+	defer cancel()
+	return l.cli.ContainerInspect(ctx, containerID)
+}
+
 // Start starts the Launcher
 func (l *Launcher) Start() {
 	go l.run()
@@ -157,6 +180,8 @@ func (l *Launcher) run() {
 			// detected that a container has been stopped.
 			containerID := service.Identifier
 			l.stopTailer(containerID)
+			// the tags are read again if a container is recreated with the same ID
+			l.staticTags.remove(containerID)
 			delete(l.pendingContainers, containerID)
 		case containerId := <-l.erroredContainerID:
 			go l.restartTailer(containerId)
@@ -176,9 +201,15 @@ func (l *Launcher) startTailer(container *Container, source *config.LogSource) {
 	}
 
 	tailer := NewTailer(l.cli, containerID, source, l.pipelineProvider.NextPipelineChan(), l.erroredContainerID)
+	tailer.staticTags = l.staticTags.get(containerID)
 
 	// compute the offset to prevent from missing or duplicating logs
 	since, err := Since(l.registry, tailer.Identifier(), container.service.CreationTime)
+	if l.tailFromStart && container.service.CreationTime == service.Before && l.registry.GetOffset(tailer.Identifier()) == "" {
+		// the container was launched before the agent start and has never been tailed, collect its history
+		since = SinceContainerStart(time.Unix(container.container.Created, 0), l.historyMaxAge)
+		tailer.LimitHistory(l.historyMaxBytes)
+	}
 	if err != nil {
 		log.Warnf("Could not recover tailing from last committed offset: %v", ShortContainerID(containerID), err)
 	}
@@ -215,6 +246,7 @@ func (l *Launcher) restartTailer(containerID string) {
 	}
 
 	tailer := NewTailer(l.cli, containerID, source, l.pipelineProvider.NextPipelineChan(), l.erroredContainerID)
+	tailer.staticTags = l.staticTags.get(containerID)
 
 	// compute the offset to prevent from missing or duplicating logs
 	since, err := Since(l.registry, tailer.Identifier(), service.Before)
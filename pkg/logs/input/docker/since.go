@@ -38,3 +38,13 @@ func Since(registry auditor.Registry, identifier string, creationTime service.Cr
 	}
 	return since, err
 }
+
+// SinceContainerStart returns the date from when the logs of a container created before the agent start
+// should be collected when they are collected since its creation, maxAge back at most, 0 for no limit.
+func SinceContainerStart(created time.Time, maxAge time.Duration) time.Time {
+	since := created.UTC()
+	if oldest := time.Now().UTC().Add(-maxAge); maxAge > 0 && since.Before(oldest) {
+		since = oldest
+	}
+	return since
+}
@@ -43,3 +43,14 @@ func TestSince(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.True(t, since.After(now))
 }
+
+func TestSinceContainerStart(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	assert.True(t, SinceContainerStart(created, 0).Equal(created))
+	assert.True(t, SinceContainerStart(created, 2*time.Hour).Equal(created))
+
+	// the history is collected up to max age back
+	since := SinceContainerStart(created, time.Minute)
+	assert.True(t, since.After(created.Add(58*time.Minute)))
+	assert.True(t, since.Before(time.Now()))
+}
@@ -0,0 +1,154 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build docker
+
+package docker
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// inspectTimeout bounds the time to inspect a container.
+const inspectTimeout = 10 * time.Second
+
+// inspectFunc returns the inspect data of a container.
+type inspectFunc func(containerID string) (types.ContainerJSON, error)
+
+// staticTagsCache computes and caches per container the tags made of the environment variables
+// and of the labels of the allowlists, the others are ignored. They are read once per container
+// as they only change when the container is recreated, with a new ID.
+type staticTagsCache struct {
+	envAllowlist   *tagsAllowlist
+	labelAllowlist *tagsAllowlist
+	inspect        inspectFunc
+	tags           map[string][]string
+	mu             sync.Mutex
+}
+
+// newStaticTagsCache returns a new cache of the tags of the environment variables in envAllowlist
+// and of the labels in labelAllowlist.
+func newStaticTagsCache(envAllowlist, labelAllowlist []string, inspect inspectFunc) *staticTagsCache {
+	return &staticTagsCache{
+		envAllowlist:   newTagsAllowlist(envAllowlist),
+		labelAllowlist: newTagsAllowlist(labelAllowlist),
+		inspect:        inspect,
+		tags:           make(map[string][]string),
+	}
+}
+
+// get returns the tags of the environment variables and of the labels of the container,
+// the container is only inspected the first time, without holding the lock so that
+// a slow inspection does not delay the other containers.
+func (c *staticTagsCache) get(containerID string) []string {
+	if c.envAllowlist.isEmpty() && c.labelAllowlist.isEmpty() {
+		return nil
+	}
+
+	c.mu.Lock()
+	tags, exists := c.tags[containerID]
+	c.mu.Unlock()
+	if exists {
+		return tags
+	}
+
+	container, err := c.inspect(containerID)
+	if err != nil {
+		log.Warnf("Could not inspect container %v: %v", ShortContainerID(containerID), err)
+		return nil
+	}
+	if container.Config != nil {
+		tags = append(c.envTags(container.Config.Env), c.labelTags(container.Config.Labels)...)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tags[containerID] = tags
+	return tags
+}
+
+// remove removes the tags of the container from the cache.
+func (c *staticTagsCache) remove(containerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tags, containerID)
+}
+
+// envTags turns the environment variables of the allowlist into tags,
+// TEAM=logs becomes team:logs.
+func (c *staticTagsCache) envTags(env []string) []string {
+	var tags []string
+	for _, variable := range env {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			continue
+		}
+		if name, isAllowed := c.envAllowlist.tagName(parts[0]); isAllowed {
+			tags = append(tags, name+":"+parts[1])
+		}
+	}
+	return tags
+}
+
+// labelTags turns the labels of the allowlist into tags sorted by name,
+// com.datadoghq.tags.env=prod becomes env:prod when com.datadoghq.tags.* is allowed.
+func (c *staticTagsCache) labelTags(labels map[string]string) []string {
+	var tags []string
+	for label, value := range labels {
+		if value == "" {
+			continue
+		}
+		if name, isAllowed := c.labelAllowlist.tagName(label); isAllowed {
+			tags = append(tags, name+":"+value)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// tagsAllowlist holds the names of the environment variables or of the labels turned into tags,
+// the entries ending with * allow all the names starting with the rest of the entry.
+type tagsAllowlist struct {
+	names    map[string]bool
+	prefixes []string
+}
+
+// newTagsAllowlist returns a new allowlist of entries.
+func newTagsAllowlist(entries []string) *tagsAllowlist {
+	allowlist := &tagsAllowlist{names: make(map[string]bool)}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, "*") {
+			allowlist.prefixes = append(allowlist.prefixes, strings.TrimSuffix(entry, "*"))
+		} else {
+			allowlist.names[entry] = true
+		}
+	}
+	return allowlist
+}
+
+// isEmpty returns true if the allowlist allows no name.
+func (a *tagsAllowlist) isEmpty() bool {
+	return len(a.names) == 0 && len(a.prefixes) == 0
+}
+
+// tagName returns the name of the tag of name lower cased, without the prefix it was allowed by if any,
+// false if name is not allowed.
+func (a *tagsAllowlist) tagName(name string) (string, bool) {
+	if a.names[name] {
+		return strings.ToLower(name), true
+	}
+	for _, prefix := range a.prefixes {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return strings.ToLower(name[len(prefix):]), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build docker
+
+package docker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInspector returns the environment variables and the labels of the containers and counts the inspections.
+type fakeInspector struct {
+	env         map[string][]string
+	labels      map[string]map[string]string
+	inspections int
+}
+
+func (i *fakeInspector) inspect(containerID string) (types.ContainerJSON, error) {
+	i.inspections++
+	env, exists := i.env[containerID]
+	if !exists {
+		return types.ContainerJSON{}, errors.New("no such container")
+	}
+	return types.ContainerJSON{Config: &container.Config{Env: env, Labels: i.labels[containerID]}}, nil
+}
+
+func TestStaticTagsCacheOnlyReadsAllowlistedVariables(t *testing.T) {
+	inspector := &fakeInspector{env: map[string][]string{
+		"foo": {"PATH=/usr/bin", "TEAM=logs", "SERVICE_VERSION=1.2.3", "DB_PASSWORD=secret", "EMPTY="},
+		"bar": {"TEAM=metrics"},
+	}}
+	cache := newStaticTagsCache([]string{"TEAM", "SERVICE_VERSION", "EMPTY"}, nil, inspector.inspect)
+
+	assert.Equal(t, []string{"team:logs", "service_version:1.2.3"}, cache.get("foo"))
+	assert.Equal(t, []string{"team:metrics"}, cache.get("bar"))
+}
+
+func TestStaticTagsCacheReadsTheVariablesAndLabelsOfThePrefixes(t *testing.T) {
+	inspector := &fakeInspector{
+		env: map[string][]string{"foo": {"DD_TAG_TEAM=logs", "DD_TAG_=empty", "TEAM=apm", "VERSION=1.2.3"}},
+		labels: map[string]map[string]string{"foo": {
+			"com.datadoghq.tags.env":     "prod",
+			"com.datadoghq.tags.Region":  "eu",
+			"com.datadoghq.tags.empty":   "",
+			"com.docker.compose.project": "shop",
+			"maintainer":                 "logs@example.com",
+			"owner":                      "logs",
+		}},
+	}
+	cache := newStaticTagsCache([]string{"DD_TAG_*", "VERSION"}, []string{"com.datadoghq.tags.*", "owner"}, inspector.inspect)
+
+	assert.Equal(t, []string{"team:logs", "version:1.2.3", "env:prod", "owner:logs", "region:eu"}, cache.get("foo"))
+}
+
+func TestStaticTagsCacheInspectsContainersOnce(t *testing.T) {
+	inspector := &fakeInspector{env: map[string][]string{
+		"foo": {"TEAM=logs"},
+		"bar": {"PATH=/usr/bin"},
+	}}
+	cache := newStaticTagsCache([]string{"TEAM"}, nil, inspector.inspect)
+
+	assert.Equal(t, []string{"team:logs"}, cache.get("foo"))
+	assert.Equal(t, []string{"team:logs"}, cache.get("foo"))
+	assert.Nil(t, cache.get("bar"))
+	assert.Nil(t, cache.get("bar"))
+	assert.Equal(t, 2, inspector.inspections)
+
+	// the container is inspected again once removed
+	cache.remove("foo")
+	inspector.env["foo"] = []string{"TEAM=apm"}
+	assert.Equal(t, []string{"team:apm"}, cache.get("foo"))
+	assert.Equal(t, 3, inspector.inspections)
+}
+
+func TestStaticTagsCacheDoesNotCacheErrors(t *testing.T) {
+	inspector := &fakeInspector{env: map[string][]string{}}
+	cache := newStaticTagsCache([]string{"TEAM"}, nil, inspector.inspect)
+
+	assert.Nil(t, cache.get("foo"))
+	inspector.env["foo"] = []string{"TEAM=logs"}
+	assert.Equal(t, []string{"team:logs"}, cache.get("foo"))
+}
+
+func TestStaticTagsCacheWithEmptyAllowlistsDoesNotInspect(t *testing.T) {
+	inspector := &fakeInspector{env: map[string][]string{"foo": {"TEAM=logs"}}}
+	cache := newStaticTagsCache(nil, nil, inspector.inspect)
+
+	assert.Nil(t, cache.get("foo"))
+	assert.Equal(t, 0, inspector.inspections)
+
+	// the labels alone are read
+	inspector.labels = map[string]map[string]string{"foo": {"team": "logs"}}
+	cache = newStaticTagsCache(nil, []string{"team"}, inspector.inspect)
+	assert.Equal(t, []string{"team:logs"}, cache.get("foo"))
+}
+
+func TestStaticTagsCacheDoesNotHoldTheLockWhileInspecting(t *testing.T) {
+	inspector := &fakeInspector{env: map[string][]string{"foo": {"TEAM=logs"}}}
+	inspecting := make(chan struct{})
+	release := make(chan struct{})
+	cache := newStaticTagsCache([]string{"TEAM"}, nil, func(containerID string) (types.ContainerJSON, error) {
+		if containerID == "slow" {
+			close(inspecting)
+			<-release
+			return types.ContainerJSON{Config: &container.Config{Env: []string{"TEAM=apm"}}}, nil
+		}
+		return inspector.inspect(containerID)
+	})
+	assert.Equal(t, []string{"team:logs"}, cache.get("foo"))
+
+	slowTags := make(chan []string)
+	go func() {
+		slowTags <- cache.get("slow")
+	}()
+	<-inspecting
+	// the tags of the other containers are returned while the slow one is inspected
+	assert.Equal(t, []string{"team:logs"}, cache.get("foo"))
+	cache.remove("foo")
+	close(release)
+	assert.Equal(t, []string{"team:apm"}, <-slowTags)
+}
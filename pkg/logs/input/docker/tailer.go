@@ -41,6 +41,15 @@ type Tailer struct {
 	cli           *client.Client
 	source        *config.LogSource
 	containerTags []string
+	// staticTags are the tags of the environment variables and of the labels of the container,
+	// they are attached to the logs along with the tags of the container.
+	staticTags []string
+	// historyEnd is the date the tailer started when it collects the logs emitted before,
+	// at most maxHistoryBytes of the logs older than historyEnd are forwarded, the rest of them is skipped,
+	// historyBytes is the size of the logs older than historyEnd read so far.
+	historyEnd      time.Time
+	maxHistoryBytes int
+	historyBytes    int
 
 	sleepDuration      time.Duration
 	shouldStop         bool
@@ -80,6 +89,13 @@ func (t *Tailer) Stop() {
 	<-t.done
 }
 
+// LimitHistory limits the size of the logs emitted before now which are forwarded to maxBytes,
+// once the limit is reached the older logs are skipped until the tailer reads the logs emitted after now.
+func (t *Tailer) LimitHistory(maxBytes int) {
+	t.historyEnd = time.Now().UTC()
+	t.maxHistoryBytes = maxBytes
+}
+
 // Start starts tailing from the last log line processed.
 // if we see this container for the first time, it will:
 // start from now if the container has been created before the agent started
@@ -115,6 +131,8 @@ func (t *Tailer) tail(since string) error {
 	t.source.AddInput(t.ContainerID)
 
 	t.reader = reader
+	// the tags of the environment variables and of the labels are attached even if the container tags can not be fetched
+	t.containerTags = t.staticTags
 
 	go t.keepDockerTagsUpdated()
 	go t.forwardMessages()
@@ -175,7 +193,7 @@ func (t *Tailer) forwardMessages() {
 		t.done <- struct{}{}
 	}()
 	for output := range t.decoder.OutputChan {
-		if len(output.Content) > 0 {
+		if len(output.Content) > 0 && !t.skipsHistory(output) {
 			origin := message.NewOrigin(t.source)
 			origin.Offset = output.Timestamp
 			origin.Identifier = t.Identifier()
@@ -186,6 +204,27 @@ func (t *Tailer) forwardMessages() {
 	}
 }
 
+// skipsHistory returns true if output is older than historyEnd and the history forwarded already exceeds its limit.
+func (t *Tailer) skipsHistory(output *message.Message) bool {
+	if t.maxHistoryBytes <= 0 {
+		return false
+	}
+	timestamp, err := time.Parse(config.DateFormat, output.Timestamp)
+	if err != nil || !timestamp.Before(t.historyEnd) {
+		// the history has been read, all the logs are forwarded from now on
+		t.maxHistoryBytes = 0
+		return false
+	}
+	t.historyBytes += len(output.Content)
+	if t.historyBytes <= t.maxHistoryBytes {
+		return false
+	}
+	if t.historyBytes-len(output.Content) <= t.maxHistoryBytes {
+		log.Infof("The logs of container %v emitted before %v exceed %v bytes, skipping the rest of them", ShortContainerID(t.ContainerID), t.historyEnd.Format(config.DateFormat), t.maxHistoryBytes)
+	}
+	return true
+}
+
 func (t *Tailer) keepDockerTagsUpdated() {
 	t.checkForNewDockerTags()
 	ticker := time.NewTicker(tagsUpdatePeriod)
@@ -202,12 +241,23 @@ func (t *Tailer) checkForNewDockerTags() {
 	if err != nil {
 		log.Warn(err)
 	} else {
+		tags = t.withStaticTags(tags)
 		if !reflect.DeepEqual(tags, t.containerTags) {
 			t.containerTags = tags
 		}
 	}
 }
 
+// withStaticTags returns a copy of tags with the tags of the environment variables and of the labels of the container.
+func (t *Tailer) withStaticTags(tags []string) []string {
+	if len(t.staticTags) == 0 {
+		return tags
+	}
+	allTags := make([]string, 0, len(tags)+len(t.staticTags))
+	allTags = append(allTags, tags...)
+	return append(allTags, t.staticTags...)
+}
+
 // wait lets the reader sleep for a bit
 func (t *Tailer) wait() {
 	time.Sleep(t.sleepDuration)
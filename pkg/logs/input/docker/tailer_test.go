@@ -9,11 +9,43 @@ package docker
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
 )
 
 func TestTailerIdentifier(t *testing.T) {
 	tailer := &Tailer{ContainerID: "test"}
 	assert.Equal(t, "docker:test", tailer.Identifier())
 }
+
+func TestTailerWithStaticTags(t *testing.T) {
+	tailer := &Tailer{ContainerID: "test"}
+	tags := []string{"image_name:redis"}
+	assert.Equal(t, tags, tailer.withStaticTags(tags))
+
+	tailer.staticTags = []string{"team:logs"}
+	assert.Equal(t, []string{"image_name:redis", "team:logs"}, tailer.withStaticTags(tags))
+	assert.Equal(t, []string{"image_name:redis"}, tags)
+}
+
+func TestTailerLimitsTheHistoryForwarded(t *testing.T) {
+	tailer := &Tailer{ContainerID: "test"}
+	now := time.Now().UTC()
+	history := &message.Message{Content: []byte("foo"), Timestamp: now.Add(-time.Minute).Format(config.DateFormat)}
+	live := &message.Message{Content: []byte("foo"), Timestamp: now.Add(time.Minute).Format(config.DateFormat)}
+
+	// the history is not limited by default
+	assert.False(t, tailer.skipsHistory(history))
+
+	tailer.LimitHistory(5)
+	assert.False(t, tailer.skipsHistory(history))
+	assert.True(t, tailer.skipsHistory(history))
+	assert.True(t, tailer.skipsHistory(history))
+	// the logs emitted after the tailer started are all forwarded
+	assert.False(t, tailer.skipsHistory(live))
+	assert.False(t, tailer.skipsHistory(history))
+}
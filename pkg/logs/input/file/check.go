@@ -0,0 +1,16 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package file
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// CheckSource returns an error if no file can be found for source, without tailing any.
+func CheckSource(source *config.LogSource) error {
+	_, err := NewProvider(0, "").CollectFiles(source)
+	return err
+}
@@ -0,0 +1,251 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package file
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	logParser "github.com/DataDog/datadog-agent/pkg/logs/parser"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// compressedFileSuffix is the suffix of the rotated files compressed with gzip.
+const compressedFileSuffix = ".gz"
+
+// compressedFileDone is the offset registered once a compressed file has been read until its end,
+// the file is never read again.
+const compressedFileDone = "done"
+
+// isCompressed returns true if the file at path is compressed with gzip.
+func isCompressed(path string) bool {
+	return strings.HasSuffix(path, compressedFileSuffix)
+}
+
+// compressedIdentifier returns the identifier the offsets of a compressed file are registered with,
+// it is made of the identifier of the file when known so that the file is not read again once renamed
+// by the next rotations, app.log.1.gz becoming app.log.2.gz for example.
+func compressedIdentifier(path string, fileID string) string {
+	if fileID == "" {
+		return fmt.Sprintf("file:%s", path)
+	}
+	return fmt.Sprintf("compressed:%s", fileID)
+}
+
+// compressedReader reads a rotated file compressed with gzip once, from the offset in the decompressed content
+// where a previous run of the agent stopped until its end, then stops.
+// The content is decompressed as it is read so that large files are never loaded in memory.
+type compressedReader struct {
+	path   string
+	fileID string
+	tags   []string
+	file   *os.File
+	gzip   *gzip.Reader
+
+	readOffset     int64
+	decodedOffset  int64
+	readBufferSize int
+	// completed is set once the whole file has been decompressed,
+	// the offset of the last message is then registered as compressedFileDone.
+	completed int32
+
+	outputChan chan *message.Message
+	decoder    *decoder.Decoder
+	source     *config.LogSource
+
+	// previous is the reader of the compressed file modified before this one,
+	// this reader does not read its file until previous is done so that the logs are collected in order.
+	previous *compressedReader
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newCompressedReader returns a new compressedReader.
+func newCompressedReader(outputChan chan *message.Message, source *config.LogSource, path string, fileID string, readBufferSize int) *compressedReader {
+	return &compressedReader{
+		path:           path,
+		fileID:         fileID,
+		readBufferSize: readBufferSize,
+		outputChan:     outputChan,
+		decoder:        decoder.InitializeDecoder(source, logParser.NoopParser),
+		source:         source,
+		stop:           make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+}
+
+// Identifier returns the identifier the offsets of the file are registered with.
+func (r *compressedReader) Identifier() string {
+	return compressedIdentifier(r.path, r.fileID)
+}
+
+// Start opens the file and starts reading it from offset in the decompressed content,
+// returns an error if the file is not a valid gzip file or is shorter than offset.
+func (r *compressedReader) Start(offset int64) error {
+	if err := r.setup(offset); err != nil {
+		r.source.Status.Error(err)
+		return err
+	}
+	r.source.Status.Success()
+	r.source.AddInput(r.path)
+	go r.forwardMessages()
+	r.decoder.Start()
+	go r.read()
+	return nil
+}
+
+// setup opens the file and skips the decompressed content up to offset.
+func (r *compressedReader) setup(offset int64) error {
+	fullpath, err := filepath.Abs(r.path)
+	if err != nil {
+		return err
+	}
+	r.tags = []string{fmt.Sprintf("filename:%s", filepath.Base(r.path))}
+	r.tags = append(r.tags, pathTags(r.source.Config.PathTagsReg, fullpath)...)
+
+	log.Info("Reading compressed file ", r.path)
+	f, err := openFile(fullpath)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not read compressed file %s: %v", r.path, err)
+	}
+	// the content read by a previous run is decompressed again to find where to resume
+	if _, err := io.CopyN(ioutil.Discard, gz, offset); err != nil {
+		gz.Close()
+		f.Close()
+		return fmt.Errorf("could not resume reading compressed file %s at offset %d: %v", r.path, offset, err)
+	}
+	r.file = f
+	r.gzip = gz
+	r.readOffset = offset
+	r.decodedOffset = offset
+	return nil
+}
+
+// Stop stops reading the file and returns once the messages decoded are forwarded.
+func (r *compressedReader) Stop() {
+	select {
+	case r.stop <- struct{}{}:
+	default:
+	}
+	<-r.done
+}
+
+// isDone returns true once the reader stopped and forwarded all its messages.
+func (r *compressedReader) isDone() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// read decompresses the file until its end and sends its content to the decoder,
+// once the previous reader is done.
+func (r *compressedReader) read() {
+	defer r.onStop()
+	if r.previous != nil {
+		select {
+		case <-r.previous.done:
+			r.previous = nil
+		case <-r.stop:
+			return
+		}
+	}
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+		inBuf := make([]byte, r.readBufferSize)
+		n, err := r.gzip.Read(inBuf)
+		if n > 0 {
+			atomic.AddInt64(&r.readOffset, int64(n))
+			r.decoder.InputChan <- decoder.NewInput(inBuf[:n])
+		}
+		switch {
+		case err == io.EOF:
+			// the file does not grow anymore, its last line is sent even if it is not terminated by a line feed
+			r.decoder.FlushPartialLine()
+			atomic.StoreInt32(&r.completed, 1)
+			return
+		case err != nil:
+			// the file is corrupted or truncated, it is read again from the last offset registered on the next start
+			log.Warnf("Could not read compressed file %s: %v", r.path, err)
+			return
+		}
+	}
+}
+
+// onStop closes the file and flushes the decoder.
+func (r *compressedReader) onStop() {
+	log.Info("Closing ", r.path)
+	r.gzip.Close()
+	r.file.Close()
+	r.source.RemoveInput(r.path)
+	r.decoder.Stop()
+}
+
+// forwardMessages forwards the messages decoded to the output channel,
+// each message is held until the next one is decoded for the last one to register the completion of the file.
+func (r *compressedReader) forwardMessages() {
+	defer close(r.done)
+	var pending *message.Message
+	for output := range r.decoder.OutputChan {
+		offset := r.decodedOffset + int64(output.RawDataLen)
+		if readOffset := atomic.LoadInt64(&r.readOffset); offset > readOffset {
+			// the raw length of a line sent without its line feed includes the missing line feed
+			offset = readOffset
+		}
+		r.decodedOffset = offset
+		origin := message.NewOrigin(r.source)
+		origin.Identifier = r.Identifier()
+		origin.Offset = formatOffset(offset, r.fileID)
+		origin.SetTags(r.tags)
+		output.Origin = origin
+		if pending != nil {
+			r.outputChan <- pending
+		}
+		pending = output
+	}
+	if pending == nil {
+		return
+	}
+	if atomic.LoadInt32(&r.completed) != 0 {
+		pending.Origin.Offset = compressedFileDone
+	}
+	r.outputChan <- pending
+}
+
+// compressedPosition returns the offset in the decompressed content to read the compressed file from,
+// and false when it has already been read until its end.
+func compressedPosition(value string, fileID string) (int64, bool) {
+	if value == compressedFileDone {
+		return 0, false
+	}
+	offset, registeredID, err := parseOffset(value)
+	if err != nil || (registeredID != "" && fileID != "" && registeredID != fileID) {
+		// the file has never been read, or the path now holds another file
+		return 0, true
+	}
+	return offset, true
+}
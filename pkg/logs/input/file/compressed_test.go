@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package file
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// writeCompressedFile writes content compressed with gzip to path.
+func writeCompressedFile(t *testing.T, path string, content string) {
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(content))
+	assert.Nil(t, err)
+	assert.Nil(t, gz.Close())
+}
+
+func TestCompressedReaderReadsTheFileUntilItsEnd(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-compressed-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	path := filepath.Join(testDir, "app.log.1.gz")
+	writeCompressedFile(t, path, "foo\nbar\nbaz")
+
+	outputChan := make(chan *message.Message, 10)
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+	reader := newCompressedReader(outputChan, source, path, "1-2", 2)
+	assert.Nil(t, reader.Start(0))
+	assert.Equal(t, config.SourceRunning, source.Status.State())
+	<-reader.done
+
+	msg := <-outputChan
+	assert.Equal(t, "foo", string(msg.Content))
+	assert.Equal(t, "compressed:1-2", msg.Origin.Identifier)
+	assert.Equal(t, "4@1-2", msg.Origin.Offset)
+	assert.Equal(t, []string{"filename:app.log.1.gz"}, msg.Origin.Tags())
+	msg = <-outputChan
+	assert.Equal(t, "bar", string(msg.Content))
+	assert.Equal(t, "8@1-2", msg.Origin.Offset)
+	// the last line is flushed at the end of the file and registers its completion
+	msg = <-outputChan
+	assert.Equal(t, "baz", string(msg.Content))
+	assert.Equal(t, compressedFileDone, msg.Origin.Offset)
+	assert.Equal(t, 0, len(source.GetInputs()))
+}
+
+func TestCompressedReaderResumesFromTheOffsetRegistered(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-compressed-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	path := filepath.Join(testDir, "app.log.1.gz")
+	writeCompressedFile(t, path, "foo\nbar\n")
+
+	outputChan := make(chan *message.Message, 10)
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+	reader := newCompressedReader(outputChan, source, path, "", defaultReadBufferSize)
+	assert.Nil(t, reader.Start(4))
+	<-reader.done
+
+	msg := <-outputChan
+	assert.Equal(t, "bar", string(msg.Content))
+	assert.Equal(t, "file:"+path, msg.Origin.Identifier)
+	assert.Equal(t, compressedFileDone, msg.Origin.Offset)
+	assert.Equal(t, 0, len(outputChan))
+}
+
+func TestCompressedReaderDoesNotCompleteATruncatedFile(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-compressed-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	path := filepath.Join(testDir, "app.log.1.gz")
+	writeCompressedFile(t, path, "foo\nbar\n")
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	// the trailer of the file is not written yet
+	assert.Nil(t, ioutil.WriteFile(path, content[:len(content)-8], 0644))
+
+	outputChan := make(chan *message.Message, 10)
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+	reader := newCompressedReader(outputChan, source, path, "", defaultReadBufferSize)
+	assert.Nil(t, reader.Start(0))
+	<-reader.done
+
+	msg := <-outputChan
+	assert.Equal(t, "foo", string(msg.Content))
+	msg = <-outputChan
+	assert.Equal(t, "bar", string(msg.Content))
+	assert.Equal(t, "8", msg.Origin.Offset)
+}
+
+func TestCompressedReaderFailsToStartOnInvalidFiles(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-compressed-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	path := filepath.Join(testDir, "app.log.1.gz")
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("foo\n"), 0644))
+	reader := newCompressedReader(make(chan *message.Message), source, path, "", defaultReadBufferSize)
+	assert.NotNil(t, reader.Start(0))
+	assert.Equal(t, config.SourceError, source.Status.State())
+
+	// the offset registered is beyond the end of the file
+	writeCompressedFile(t, path, "foo\n")
+	reader = newCompressedReader(make(chan *message.Message), source, path, "", defaultReadBufferSize)
+	assert.NotNil(t, reader.Start(10))
+}
+
+func TestCompressedPosition(t *testing.T) {
+	offset, shouldRead := compressedPosition("", "1-2")
+	assert.Equal(t, int64(0), offset)
+	assert.True(t, shouldRead)
+	offset, shouldRead = compressedPosition("42@1-2", "1-2")
+	assert.Equal(t, int64(42), offset)
+	assert.True(t, shouldRead)
+	offset, shouldRead = compressedPosition("42@3-4", "1-2")
+	assert.Equal(t, int64(0), offset)
+	assert.True(t, shouldRead)
+	_, shouldRead = compressedPosition(compressedFileDone, "1-2")
+	assert.False(t, shouldRead)
+}
@@ -0,0 +1,230 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// CheckConflicts returns an error for each file source whose path can match the same files as the path
+// of another file source, listing the other sources, regardless of the files present on the host.
+// The overlaps depending on the exclusion patterns of globs are only detected when the files are scanned.
+func CheckConflicts(sources []*config.LogSource) map[*config.LogSource]error {
+	var fileSources []*config.LogSource
+	for _, source := range sources {
+		if source.Config.Type == config.FileType && source.Config.ManifestFormat == "" {
+			fileSources = append(fileSources, source)
+		}
+	}
+
+	p := NewProvider(0, "")
+	conflicts := make(map[*config.LogSource][]string)
+	for i, source := range fileSources {
+		for _, other := range fileSources[i+1:] {
+			if p.pathsOverlap(source, other) {
+				conflicts[source] = append(conflicts[source], other.Name)
+				conflicts[other] = append(conflicts[other], source.Name)
+			}
+		}
+	}
+
+	errs := make(map[*config.LogSource]error)
+	for source, names := range conflicts {
+		errs[source] = fmt.Errorf("path %s can match the same files as sources %s", source.Config.Path, strings.Join(names, ", "))
+	}
+	return errs
+}
+
+// pathsOverlap returns true if a file can be matched by the paths of both sources and not be excluded.
+func (p *Provider) pathsOverlap(source, other *config.LogSource) bool {
+	path, otherPath := filepath.Clean(source.Config.Path), filepath.Clean(other.Config.Path)
+	switch {
+	case !p.containsWildcard(path) && !p.containsWildcard(otherPath):
+		return path == otherPath && !isExcludedByAny(path, source, other)
+	case !p.containsWildcard(path):
+		return matches(otherPath, path) && !isExcludedByAny(path, source, other)
+	case !p.containsWildcard(otherPath):
+		return matches(path, otherPath) && !isExcludedByAny(otherPath, source, other)
+	case len(source.Config.ExcludePaths) > 0 || len(other.Config.ExcludePaths) > 0:
+		return false
+	}
+	segments, otherSegments := strings.Split(path, string(filepath.Separator)), strings.Split(otherPath, string(filepath.Separator))
+	if len(segments) != len(otherSegments) {
+		// the wildcards never match the separators
+		return false
+	}
+	for i := range segments {
+		if !segmentsOverlap(parseSegment(segments[i]), parseSegment(otherSegments[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isExcludedByAny returns true if path is excluded by one of the sources.
+func isExcludedByAny(path string, sources ...*config.LogSource) bool {
+	for _, source := range sources {
+		if isExcluded(path, source.Config.ExcludePaths) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if pattern matches path.
+func matches(pattern, path string) bool {
+	matched, err := filepath.Match(pattern, path)
+	return err == nil && matched
+}
+
+// globToken is a part of a glob matching either any sequence of characters or a single one.
+type globToken struct {
+	star bool
+	// ranges are the inclusive bounds of the characters matched by the token, pairwise.
+	ranges  []rune
+	negated bool
+}
+
+// anyCharacter matches any single character, like '?'.
+var anyCharacter = globToken{negated: true}
+
+// parseSegment splits a glob without any separator into tokens, following the syntax of filepath.Match,
+// the patterns have already been validated so malformed classes are read as literals.
+func parseSegment(segment string) []globToken {
+	var tokens []globToken
+	for len(segment) > 0 {
+		switch segment[0] {
+		case '*':
+			tokens = append(tokens, globToken{star: true})
+			segment = segment[1:]
+		case '?':
+			tokens = append(tokens, anyCharacter)
+			segment = segment[1:]
+		case '[':
+			if token, rest, ok := parseClass(segment[1:]); ok {
+				tokens = append(tokens, token)
+				segment = rest
+				continue
+			}
+			tokens = append(tokens, literal('['))
+			segment = segment[1:]
+		default:
+			var c rune
+			c, segment = nextRune(segment)
+			tokens = append(tokens, literal(c))
+		}
+	}
+	return tokens
+}
+
+// parseClass parses a character class following its opening bracket, returns the rest of the glob.
+func parseClass(class string) (globToken, string, bool) {
+	var token globToken
+	if len(class) > 0 && class[0] == '^' {
+		token.negated = true
+		class = class[1:]
+	}
+	for first := true; ; first = false {
+		if len(class) == 0 {
+			return globToken{}, "", false
+		}
+		if class[0] == ']' && !first {
+			return token, class[1:], true
+		}
+		var lo, hi rune
+		lo, class = nextRune(class)
+		hi = lo
+		if len(class) > 1 && class[0] == '-' && class[1] != ']' {
+			hi, class = nextRune(class[1:])
+		}
+		token.ranges = append(token.ranges, lo, hi)
+	}
+}
+
+// nextRune returns the first character of s, unescaped, and the rest of s.
+func nextRune(s string) (rune, string) {
+	if s[0] == '\\' && filepath.Separator != '\\' && len(s) > 1 {
+		s = s[1:]
+	}
+	c, size := utf8.DecodeRuneInString(s)
+	return c, s[size:]
+}
+
+// literal returns a token matching c.
+func literal(c rune) globToken {
+	return globToken{ranges: []rune{c, c}}
+}
+
+// segmentsOverlap returns true if a string can be matched by both tokens and other.
+func segmentsOverlap(tokens, other []globToken) bool {
+	// visited avoids exploring the same suffixes twice when both globs contain stars
+	visited := make(map[[2]int]bool)
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		key := [2]int{i, j}
+		if visited[key] {
+			return false
+		}
+		visited[key] = true
+		switch {
+		case i == len(tokens) && j == len(other):
+			return true
+		case i < len(tokens) && tokens[i].star:
+			return overlap(i+1, j) || (j < len(other) && overlap(i, j+1))
+		case j < len(other) && other[j].star:
+			return overlap(i, j+1) || (i < len(tokens) && overlap(i+1, j))
+		case i == len(tokens) || j == len(other):
+			return false
+		}
+		return charactersOverlap(tokens[i], other[j]) && overlap(i+1, j+1)
+	}
+	return overlap(0, 0)
+}
+
+// charactersOverlap returns true if a character can be matched by both single character tokens.
+func charactersOverlap(token, other globToken) bool {
+	switch {
+	case token.negated && other.negated:
+		// both only exclude a finite set of characters
+		return true
+	case token.negated:
+		return !covers(token.ranges, other.ranges)
+	case other.negated:
+		return !covers(other.ranges, token.ranges)
+	}
+	for i := 0; i < len(token.ranges); i += 2 {
+		for j := 0; j < len(other.ranges); j += 2 {
+			if token.ranges[i] <= other.ranges[j+1] && other.ranges[j] <= token.ranges[i+1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// covers returns true if all the characters of ranges are in excluded.
+func covers(excluded, ranges []rune) bool {
+	for i := 0; i < len(ranges); i += 2 {
+		for c := ranges[i]; c <= ranges[i+1]; {
+			next := c
+			for j := 0; j < len(excluded); j += 2 {
+				if excluded[j] <= c && c <= excluded[j+1] && excluded[j+1] >= next {
+					next = excluded[j+1] + 1
+				}
+			}
+			if next == c {
+				return false
+			}
+			c = next
+		}
+	}
+	return true
+}
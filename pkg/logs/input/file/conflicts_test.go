@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func TestPathsOverlap(t *testing.T) {
+	tests := []struct {
+		path, other string
+		excluded    []string
+		overlap     bool
+	}{
+		{"/var/log/app.log", "/var/log/app.log", nil, true},
+		{"/var/log/app.log", "/var/log/../log/app.log", nil, true},
+		{"/var/log/app.log", "/var/log/other.log", nil, false},
+		{"/var/log/app.log", "/var/log/*.log", nil, true},
+		{"/var/log/app.log", "/var/log/*.log", []string{"app.log"}, false},
+		{"/var/log/app.log", "/var/log/*.txt", nil, false},
+		{"/var/log/*.log", "/var/log/app*", nil, true},
+		{"/var/log/*.log", "/var/log/*.txt", nil, false},
+		{"/var/log/*.log", "/var/log/*.log", []string{"app.log"}, false},
+		{"/var/log/*/app.log", "/var/log/app.log", nil, false},
+		{"/var/log/*/*.log", "/var/*/nginx/access.*", nil, true},
+		{"/var/log/app-?.log", "/var/log/app-10.log", nil, false},
+		{"/var/log/app-[0-4].log", "/var/log/app-[5-9].log", nil, false},
+		{"/var/log/app-[0-4].log", "/var/log/app-[^5-9].log", nil, true},
+		{"/var/log/app-[0-9].log", "/var/log/app-[^0-9].log", nil, false},
+		{"/var/log/app-[^a].log", "/var/log/app-[^b].log", nil, true},
+		{"/var/log/app-\\*.log", "/var/log/app-1.log", nil, false},
+		{"/var/log/app-\\*.log", "/var/log/app-[*].log", nil, true},
+	}
+	p := NewProvider(0, "")
+	for _, test := range tests {
+		source := config.NewLogSource("source", &config.LogsConfig{Type: config.FileType, Path: test.path})
+		other := config.NewLogSource("other", &config.LogsConfig{Type: config.FileType, Path: test.other, ExcludePaths: test.excluded})
+		assert.Equal(t, test.overlap, p.pathsOverlap(source, other), "%s %s", test.path, test.other)
+		assert.Equal(t, test.overlap, p.pathsOverlap(other, source), "%s %s", test.other, test.path)
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	app := config.NewLogSource("app", &config.LogsConfig{Type: config.FileType, Path: "/var/log/app.log"})
+	all := config.NewLogSource("all", &config.LogsConfig{Type: config.FileType, Path: "/var/log/*.log"})
+	prefixed := config.NewLogSource("prefixed", &config.LogsConfig{Type: config.FileType, Path: "/var/log/app*"})
+	other := config.NewLogSource("other", &config.LogsConfig{Type: config.FileType, Path: "/var/log/other.txt"})
+	manifest := config.NewLogSource("manifest", &config.LogsConfig{Type: config.FileType, Path: "/var/log/app.log", ManifestFormat: "lines"})
+	docker := config.NewLogSource("docker", &config.LogsConfig{Type: config.DockerType})
+
+	errs := CheckConflicts([]*config.LogSource{app, all, prefixed, other, manifest, docker})
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs[app], "path /var/log/app.log can match the same files as sources all, prefixed")
+	assert.EqualError(t, errs[all], "path /var/log/*.log can match the same files as sources app, prefixed")
+	assert.EqualError(t, errs[prefixed], "path /var/log/app* can match the same files as sources app, all")
+}
@@ -0,0 +1,28 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode numbers of the file, which identify it whatever its path,
+// or an empty string if they can not be read.
+func fileID(f *os.File) string {
+	fi, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", stat.Dev, stat.Ino)
+}
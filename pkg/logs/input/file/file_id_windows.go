@@ -0,0 +1,25 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build windows
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileID returns the volume serial number and the file index of the file, which identify it whatever its path,
+// or an empty string if they can not be read.
+func fileID(f *os.File) string {
+	var info syscall.ByHandleFileInformation
+	err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d-%d", info.VolumeSerialNumber, info.FileIndexHigh, info.FileIndexLow)
+}
@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
@@ -20,6 +22,19 @@ import (
 // files are tailed
 const openFilesLimitWarningType = "open_files_limit_warning"
 
+// fileConflictWarningType is the key of the message generated when files
+// are matched by several sources
+const fileConflictWarningType = "file_conflict_warning"
+
+// Policies applied when several sources match the same file
+const (
+	// PrecedenceConflictPolicy tails the file for the source with the highest precedence,
+	// or the first one added when they have the same precedence.
+	PrecedenceConflictPolicy = "precedence"
+	// ErrorConflictPolicy does not tail the file and reports an error on all the sources matching it.
+	ErrorConflictPolicy = "error"
+)
+
 // File represents a file to tail
 type File struct {
 	Path   string
@@ -37,14 +52,21 @@ func NewFile(path string, source *config.LogSource) *File {
 // Provider implements the logic to retrieve at most filesLimit Files defined in sources
 type Provider struct {
 	filesLimit      int
+	conflictPolicy  string
 	shouldLogErrors bool
+	// preExistingFiles are the files present when their source was added, per path, for the sources
+	// ignoring them, they are never tailed for these sources unless they are replaced by new files.
+	preExistingFiles map[*config.LogSource]map[string]os.FileInfo
 }
 
-// NewProvider returns a new Provider
-func NewProvider(filesLimit int) *Provider {
+// NewProvider returns a new Provider,
+// conflictPolicy determines how to handle the files matched by several sources.
+func NewProvider(filesLimit int, conflictPolicy string) *Provider {
 	return &Provider{
-		filesLimit:      filesLimit,
-		shouldLogErrors: true,
+		filesLimit:       filesLimit,
+		conflictPolicy:   conflictPolicy,
+		shouldLogErrors:  true,
+		preExistingFiles: make(map[*config.LogSource]map[string]os.FileInfo),
 	}
 }
 
@@ -53,14 +75,29 @@ func NewProvider(filesLimit int) *Provider {
 // For now, there is no way to prioritize specific Files over others,
 // they are just returned in alphabetical order
 func (p *Provider) FilesToTail(sources []*config.LogSource) []*File {
+	files, _ := p.filesToTail(sources, p.filesLimit)
+	return files
+}
+
+// filesToTail returns the Files matching paths in sources, at most limit Files unless limit is negative,
+// along with the number of Files matching, which can be higher than the number of Files returned.
+func (p *Provider) filesToTail(sources []*config.LogSource, limit int) ([]*File, int) {
 	var filesToTail []*File
+	var matching int
 	shouldLogErrors := p.shouldLogErrors
 	p.shouldLogErrors = false // Let's log errors on first run only
 
+	filesBySource := make([][]*File, len(sources))
+	errs := make([]error, len(sources))
+	for i, source := range sources {
+		filesBySource[i], errs[i] = p.CollectFiles(source)
+	}
+	owners := p.resolveConflicts(sources, filesBySource, shouldLogErrors)
+
 	for i := 0; i < len(sources); i++ {
 		source := sources[i]
 		tailedFileCounter := 0
-		files, err := p.CollectFiles(source)
+		files, err := filesBySource[i], errs[i]
 		if err != nil {
 			source.Status.Error(err)
 			if p.containsWildcard(source.Config.Path) {
@@ -71,10 +108,20 @@ func (p *Provider) FilesToTail(sources []*config.LogSource) []*File {
 			}
 			continue
 		}
-		for j := 0; j < len(files) && len(filesToTail) < p.filesLimit; j++ {
-			file := files[j]
+		for _, file := range files {
+			if owner, isConflicting := owners[file.Path]; isConflicting && owner != source {
+				// the file is tailed for another source or not tailed at all
+				continue
+			}
+			matching++
+			if limit >= 0 && len(filesToTail) >= limit {
+				continue
+			}
+			if len(filesToTail) < p.filesLimit {
+				// the Files above filesLimit wait for others to stop being tailed
+				tailedFileCounter++
+			}
 			filesToTail = append(filesToTail, file)
-			tailedFileCounter++
 		}
 
 		if len(filesToTail) >= p.filesLimit {
@@ -94,31 +141,219 @@ func (p *Provider) FilesToTail(sources []*config.LogSource) []*File {
 		}
 	}
 
-	if len(filesToTail) == p.filesLimit {
+	if len(filesToTail) >= p.filesLimit {
 		log.Warn("Reached the limit on the maximum number of files in use: ", p.filesLimit)
-		return filesToTail
 	}
 
-	return filesToTail
+	return filesToTail, matching
+}
+
+// resolveConflicts returns the source each file matched by several sources must be tailed for,
+// the source is nil when the file must not be tailed, and reports the conflicts on the sources.
+// With the error policy, the paths that can overlap are reported by CheckConflicts when the sources are
+// validated, this also catches the conflicts only found at runtime, such as the ones depending on the excluded paths.
+func (p *Provider) resolveConflicts(sources []*config.LogSource, filesBySource [][]*File, shouldLogErrors bool) map[string]*config.LogSource {
+	matches := make(map[string][]*config.LogSource)
+	for i, files := range filesBySource {
+		for _, file := range files {
+			matches[file.Path] = append(matches[file.Path], sources[i])
+		}
+	}
+
+	owners := make(map[string]*config.LogSource)
+	conflicts := make(map[*config.LogSource][]string)
+	for path, matchingSources := range matches {
+		if len(matchingSources) < 2 {
+			continue
+		}
+		var owner *config.LogSource
+		if p.conflictPolicy != ErrorConflictPolicy {
+			owner = matchingSources[0]
+			for _, source := range matchingSources[1:] {
+				if source.Config.Precedence > owner.Config.Precedence {
+					owner = source
+				}
+			}
+		}
+		owners[path] = owner
+
+		var names []string
+		for _, source := range matchingSources {
+			names = append(names, source.Name)
+		}
+		var conflict string
+		if owner == nil {
+			conflict = fmt.Sprintf("%s is matched by sources %s and is not tailed", path, strings.Join(names, ", "))
+			if shouldLogErrors {
+				log.Errorf("File %s", conflict)
+			}
+		} else {
+			conflict = fmt.Sprintf("%s is matched by sources %s and is tailed for source %s", path, strings.Join(names, ", "), owner.Name)
+		}
+		for _, source := range matchingSources {
+			conflicts[source] = append(conflicts[source], conflict)
+		}
+	}
+
+	for _, source := range sources {
+		if sourceConflicts, exists := conflicts[source]; exists {
+			sort.Strings(sourceConflicts)
+			source.Messages.AddWarning(fileConflictWarningType, "Files matched by several sources: "+strings.Join(sourceConflicts, "; "))
+		} else {
+			source.Messages.RemoveWarning(fileConflictWarningType)
+		}
+	}
+	return owners
 }
 
-// CollectFiles returns all the files matching the source path.
+// CollectFiles returns all the files matching the source path,
+// or the active segment when the path is a manifest.
 func (p *Provider) CollectFiles(source *config.LogSource) ([]*File, error) {
+	if source.Config.ManifestFormat != "" {
+		segments, err := readManifest(source.Config.Path, source.Config.ManifestFormat)
+		if err != nil {
+			return nil, err
+		}
+		return []*File{
+			NewFile(segments[len(segments)-1], source),
+		}, nil
+	}
 	path := source.Config.Path
 	fileExists := p.exists(path)
 	switch {
 	case fileExists:
-		return []*File{
+		return p.excludeFiles([]*File{
 			NewFile(path, source),
-		}, nil
+		}, source), nil
 	case p.containsWildcard(path):
 		pattern := path
-		return p.searchFiles(pattern, source)
+		files, err := p.searchFiles(pattern, source)
+		if err != nil {
+			return nil, err
+		}
+		return p.excludeFiles(files, source), nil
 	default:
 		return nil, fmt.Errorf("file %s does not exist", path)
 	}
 }
 
+// excludeFiles returns the files not matching any of the exclusion patterns of source,
+// the files excluded can still be tailed for another source,
+// the compressed files are read once instead of being tailed when the source reads them
+// and the files present when the source was added are ignored when the source ignores them.
+func (p *Provider) excludeFiles(files []*File, source *config.LogSource) []*File {
+	preExistingFiles := p.preExistingFiles[source]
+	if len(source.Config.ExcludePaths) == 0 && !source.Config.ReadCompressed && len(preExistingFiles) == 0 {
+		return files
+	}
+	var included []*File
+	for _, file := range files {
+		if source.Config.ReadCompressed && isCompressed(file.Path) {
+			continue
+		}
+		if p.isPreExisting(file.Path, preExistingFiles) {
+			continue
+		}
+		if !isExcluded(file.Path, source.Config.ExcludePaths) {
+			included = append(included, file)
+		}
+	}
+	return included
+}
+
+// ignorePreExistingFiles records the files of source present when it is added so that they are never tailed for it,
+// the files replaced afterwards by new files at the same path, on a rotation for example, are tailed.
+func (p *Provider) ignorePreExistingFiles(source *config.LogSource, files []*File) {
+	preExistingFiles := make(map[string]os.FileInfo, len(files))
+	for _, file := range files {
+		if fi, err := os.Stat(file.Path); err == nil {
+			preExistingFiles[file.Path] = fi
+		}
+	}
+	p.preExistingFiles[source] = preExistingFiles
+}
+
+// forgetPreExistingFiles forgets the files ignored for source once it is removed.
+func (p *Provider) forgetPreExistingFiles(source *config.LogSource) {
+	delete(p.preExistingFiles, source)
+}
+
+// isPreExisting returns true if the file at path is still the one present when its source was added,
+// a file replaced since then is forgotten as it is tailed from now on.
+func (p *Provider) isPreExisting(path string, preExistingFiles map[string]os.FileInfo) bool {
+	preExisting, exists := preExistingFiles[path]
+	if !exists {
+		return false
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		// the file was removed in the meantime, it is ignored until it is created again
+		return true
+	}
+	if !os.SameFile(preExisting, fi) {
+		delete(preExistingFiles, path)
+		return false
+	}
+	return true
+}
+
+// isExcluded returns true if path matches one of patterns, the patterns without any
+// path separator are matched against the name of the file, the other ones against the whole path.
+func isExcluded(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		name := path
+		if !strings.ContainsRune(pattern, filepath.Separator) {
+			name = filepath.Base(path)
+		}
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectRotatedSegments returns the rotated segments listed in the manifest of the source,
+// from the oldest to the most recent one.
+func (p *Provider) CollectRotatedSegments(source *config.LogSource) ([]*File, error) {
+	segments, err := readManifest(source.Config.Path, source.Config.ManifestFormat)
+	if err != nil {
+		return nil, err
+	}
+	var files []*File
+	for _, segment := range segments[:len(segments)-1] {
+		files = append(files, NewFile(segment, source))
+	}
+	return files, nil
+}
+
+// CollectCompressedFiles returns the rotated files of the source compressed with gzip,
+// named after the files matched by its path followed by a suffix ending with .gz,
+// from the least to the most recently modified one.
+func (p *Provider) CollectCompressedFiles(source *config.LogSource) ([]*File, error) {
+	pattern := source.Config.Path + "*" + compressedFileSuffix
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("malformed pattern, could not find any file: %s", pattern)
+	}
+	modTimes := make(map[string]time.Time)
+	var files []*File
+	for _, path := range paths {
+		if isExcluded(path, source.Config.ExcludePaths) {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		modTimes[path] = fi.ModTime()
+		files = append(files, NewFile(path, source))
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return modTimes[files[i].Path].Before(modTimes[files[j].Path])
+	})
+	return files, nil
+}
+
 // searchFiles returns all the files matching the source path pattern.
 func (p *Provider) searchFiles(pattern string, source *config.LogSource) ([]*File, error) {
 	paths, err := filepath.Glob(pattern)
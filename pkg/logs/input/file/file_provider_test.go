@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 
@@ -73,7 +74,7 @@ func (suite *ProviderTestSuite) TearDownTest() {
 
 func (suite *ProviderTestSuite) TestFilesToTailReturnsSpecificFile() {
 	path := fmt.Sprintf("%s/1/1.log", suite.testDir)
-	fileProvider := NewProvider(suite.filesLimit)
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
 	logSources := suite.newLogSources(path)
 	files := fileProvider.FilesToTail(logSources)
 
@@ -85,7 +86,7 @@ func (suite *ProviderTestSuite) TestFilesToTailReturnsSpecificFile() {
 
 func (suite *ProviderTestSuite) TestFilesToTailReturnsAllFilesFromDirectory() {
 	path := fmt.Sprintf("%s/1/*.log", suite.testDir)
-	fileProvider := NewProvider(suite.filesLimit)
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
 	logSources := suite.newLogSources(path)
 	files := fileProvider.FilesToTail(logSources)
 
@@ -104,7 +105,7 @@ func (suite *ProviderTestSuite) TestFilesToTailReturnsAllFilesFromDirectory() {
 
 func (suite *ProviderTestSuite) TestFilesToTailReturnsAllFilesFromAnyDirectoryWithRightPermissions() {
 	path := fmt.Sprintf("%s/*/*1.log", suite.testDir)
-	fileProvider := NewProvider(suite.filesLimit)
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
 	logSources := suite.newLogSources(path)
 	files := fileProvider.FilesToTail(logSources)
 
@@ -117,7 +118,7 @@ func (suite *ProviderTestSuite) TestFilesToTailReturnsAllFilesFromAnyDirectoryWi
 
 func (suite *ProviderTestSuite) TestFilesToTailReturnsSpecificFileWithWildcard() {
 	path := fmt.Sprintf("%s/1/?.log", suite.testDir)
-	fileProvider := NewProvider(suite.filesLimit)
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
 	logSources := suite.newLogSources(path)
 	files := fileProvider.FilesToTail(logSources)
 
@@ -136,7 +137,7 @@ func (suite *ProviderTestSuite) TestFilesToTailReturnsSpecificFileWithWildcard()
 
 func (suite *ProviderTestSuite) TestNumberOfFilesToTailDoesNotExceedLimit() {
 	path := fmt.Sprintf("%s/*/*.log", suite.testDir)
-	fileProvider := NewProvider(suite.filesLimit)
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
 	logSources := suite.newLogSources(path)
 	files := fileProvider.FilesToTail(logSources)
 	suite.Equal(suite.filesLimit, len(files))
@@ -151,7 +152,7 @@ func (suite *ProviderTestSuite) TestNumberOfFilesToTailDoesNotExceedLimit() {
 
 func (suite *ProviderTestSuite) TestAllWildcardPathsAreUpdated() {
 	filesLimit := 2
-	fileProvider := NewProvider(filesLimit)
+	fileProvider := NewProvider(filesLimit, PrecedenceConflictPolicy)
 	logSources := []*config.LogSource{
 		config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/*.log", suite.testDir)}),
 		config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/2/*.log", suite.testDir)}),
@@ -200,6 +201,175 @@ func (suite *ProviderTestSuite) TestAllWildcardPathsAreUpdated() {
 	suite.Equal(make([]string, 0), logSources[0].Messages.GetWarnings())
 }
 
+func (suite *ProviderTestSuite) TestFilesToTailWithOverlappingGlobsTailsForFirstSource() {
+	sources := []*config.LogSource{
+		config.NewLogSource("all", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/*.log", suite.testDir)}),
+		config.NewLogSource("one", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/1.log", suite.testDir)}),
+	}
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+	files := fileProvider.FilesToTail(sources)
+
+	suite.Equal(3, len(files))
+	for _, file := range files {
+		suite.Equal(sources[0], file.Source)
+	}
+	expectedWarning := fmt.Sprintf("Files matched by several sources: %s/1/1.log is matched by sources all, one and is tailed for source all", suite.testDir)
+	suite.Contains(sources[0].Messages.GetWarnings(), expectedWarning)
+	suite.Contains(sources[1].Messages.GetWarnings(), expectedWarning)
+}
+
+func (suite *ProviderTestSuite) TestFilesToTailWithOverlappingGlobsTailsForHighestPrecedence() {
+	sources := []*config.LogSource{
+		config.NewLogSource("all", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/*.log", suite.testDir)}),
+		config.NewLogSource("one", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/[12].log", suite.testDir), Precedence: 1}),
+	}
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+	files := fileProvider.FilesToTail(sources)
+
+	suite.Equal(3, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/3.log", suite.testDir), files[0].Path)
+	suite.Equal(sources[0], files[0].Source)
+	suite.Equal(fmt.Sprintf("%s/1/1.log", suite.testDir), files[1].Path)
+	suite.Equal(sources[1], files[1].Source)
+	suite.Equal(fmt.Sprintf("%s/1/2.log", suite.testDir), files[2].Path)
+	suite.Equal(sources[1], files[2].Source)
+}
+
+func (suite *ProviderTestSuite) TestFilesToTailWithOverlappingGlobsAndErrorPolicy() {
+	sources := []*config.LogSource{
+		config.NewLogSource("all", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/*.log", suite.testDir)}),
+		config.NewLogSource("one", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/1.log", suite.testDir), Precedence: 1}),
+	}
+	fileProvider := NewProvider(suite.filesLimit, ErrorConflictPolicy)
+	files := fileProvider.FilesToTail(sources)
+
+	suite.Equal(2, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/2.log", suite.testDir), files[0].Path)
+	suite.Equal(fmt.Sprintf("%s/1/3.log", suite.testDir), files[1].Path)
+	expectedWarning := fmt.Sprintf("Files matched by several sources: %s/1/1.log is matched by sources all, one and is not tailed", suite.testDir)
+	suite.Contains(sources[1].Messages.GetWarnings(), expectedWarning)
+
+	// the warning is removed once the conflict is resolved
+	files = fileProvider.FilesToTail(sources[:1])
+	suite.Equal(3, len(files))
+	for _, warning := range sources[0].Messages.GetWarnings() {
+		suite.NotContains(warning, "Files matched by several sources")
+	}
+}
+
+func (suite *ProviderTestSuite) TestFilesToTailSkipsTheExcludedFiles() {
+	sources := []*config.LogSource{
+		config.NewLogSource("", &config.LogsConfig{
+			Type:         config.FileType,
+			Path:         fmt.Sprintf("%s/*/*.log", suite.testDir),
+			ExcludePaths: []string{"1.log", fmt.Sprintf("%s/1/3.*", suite.testDir)},
+		}),
+	}
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+	files := fileProvider.FilesToTail(sources)
+
+	suite.Equal(2, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/2.log", suite.testDir), files[0].Path)
+	suite.Equal(fmt.Sprintf("%s/2/2.log", suite.testDir), files[1].Path)
+	suite.Equal([]string{"2 files tailed out of 2 files matching"}, sources[0].Messages.GetMessages())
+}
+
+func (suite *ProviderTestSuite) TestFilesToTailSkipsTheSpecificFileExcluded() {
+	sources := suite.newLogSources(fmt.Sprintf("%s/1/1.log", suite.testDir))
+	sources[0].Config.ExcludePaths = []string{"*.log"}
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+	files := fileProvider.FilesToTail(sources)
+
+	suite.Equal(0, len(files))
+	suite.False(sources[0].Status.IsError())
+}
+
+func (suite *ProviderTestSuite) TestFilesToTailTailsTheFilesExcludedForTheOtherSources() {
+	// the file excluded from the source with the highest precedence is tailed for the other one without conflict
+	sources := []*config.LogSource{
+		config.NewLogSource("all", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/*.log", suite.testDir), Precedence: 1, ExcludePaths: []string{"1.log"}}),
+		config.NewLogSource("one", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/[12].log", suite.testDir)}),
+	}
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+	files := fileProvider.FilesToTail(sources)
+
+	suite.Equal(3, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/2.log", suite.testDir), files[0].Path)
+	suite.Equal(sources[0], files[0].Source)
+	suite.Equal(fmt.Sprintf("%s/1/3.log", suite.testDir), files[1].Path)
+	suite.Equal(sources[0], files[1].Source)
+	suite.Equal(fmt.Sprintf("%s/1/1.log", suite.testDir), files[2].Path)
+	suite.Equal(sources[1], files[2].Source)
+	expectedWarning := fmt.Sprintf("Files matched by several sources: %s/1/2.log is matched by sources all, one and is tailed for source all", suite.testDir)
+	suite.Contains(sources[1].Messages.GetWarnings(), expectedWarning)
+}
+
+func (suite *ProviderTestSuite) TestCollectFilesSkipsThePreExistingFiles() {
+	sources := suite.newLogSources(fmt.Sprintf("%s/1/*.log", suite.testDir))
+	sources[0].Config.IgnorePreExisting = true
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+	files, err := fileProvider.CollectFiles(sources[0])
+	suite.Nil(err)
+	fileProvider.ignorePreExistingFiles(sources[0], files[:2])
+
+	files, err = fileProvider.CollectFiles(sources[0])
+	suite.Nil(err)
+	suite.Equal(1, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/3.log", suite.testDir), files[0].Path)
+
+	// a file replaced by a new one is not ignored anymore
+	path := fmt.Sprintf("%s/1/1.log", suite.testDir)
+	suite.Nil(os.Rename(path, fmt.Sprintf("%s.1", path)))
+	suite.Nil(ioutil.WriteFile(path, []byte("hello\n"), 0644))
+	files, err = fileProvider.CollectFiles(sources[0])
+	suite.Nil(err)
+	suite.Equal(2, len(files))
+
+	fileProvider.forgetPreExistingFiles(sources[0])
+	files, err = fileProvider.CollectFiles(sources[0])
+	suite.Nil(err)
+	suite.Equal(3, len(files))
+}
+
+func (suite *ProviderTestSuite) TestCollectFilesFromManifest() {
+	manifest := writeManifest(suite.T(), suite.testDir, "1/1.log\n1/2.log\n")
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: manifest, ManifestFormat: LinesManifestFormat})
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+
+	files, err := fileProvider.CollectFiles(source)
+	suite.Nil(err)
+	suite.Equal(1, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/2.log", suite.testDir), files[0].Path)
+
+	files, err = fileProvider.CollectRotatedSegments(source)
+	suite.Nil(err)
+	suite.Equal(1, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/1.log", suite.testDir), files[0].Path)
+}
+
+func (suite *ProviderTestSuite) TestCollectCompressedFiles() {
+	for i, name := range []string{"1.log.1.gz", "1.log.2.gz", "2.log.1.gz"} {
+		path := fmt.Sprintf("%s/1/%s", suite.testDir, name)
+		suite.Nil(ioutil.WriteFile(path, nil, 0644))
+		modTime := time.Now().Add(-time.Duration(i) * time.Hour)
+		suite.Nil(os.Chtimes(path, modTime, modTime))
+	}
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/1/*.log*", suite.testDir), ExcludePaths: []string{"2.log*"}, ReadCompressed: true})
+	fileProvider := NewProvider(suite.filesLimit, PrecedenceConflictPolicy)
+
+	// the compressed files are not tailed
+	files, err := fileProvider.CollectFiles(source)
+	suite.Nil(err)
+	suite.Equal(2, len(files))
+
+	// from the least recently modified one
+	files, err = fileProvider.CollectCompressedFiles(source)
+	suite.Nil(err)
+	suite.Equal(2, len(files))
+	suite.Equal(fmt.Sprintf("%s/1/1.log.2.gz", suite.testDir), files[0].Path)
+	suite.Equal(fmt.Sprintf("%s/1/1.log.1.gz", suite.testDir), files[1].Path)
+}
+
 func TestProviderTestSuite(t *testing.T) {
 	suite.Run(t, new(ProviderTestSuite))
 }
@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Manifest formats supported by default
+const (
+	// LinesManifestFormat lists one segment per line.
+	LinesManifestFormat = "lines"
+	// JSONManifestFormat lists the segments in a JSON array of strings.
+	JSONManifestFormat = "json"
+)
+
+// ManifestParser returns the segments listed in the content of a manifest,
+// ordered from the oldest rotated segment to the active one.
+type ManifestParser func(content []byte) ([]string, error)
+
+var manifestParsers = map[string]ManifestParser{
+	LinesManifestFormat: parseLinesManifest,
+	JSONManifestFormat:  parseJSONManifest,
+}
+
+// RegisterManifestParser makes a new manifest format available to the file sources,
+// it must be called before the sources are scanned.
+func RegisterManifestParser(format string, parser ManifestParser) {
+	manifestParsers[format] = parser
+}
+
+// readManifest returns the paths of the segments listed in the manifest at path,
+// relative paths are resolved from the directory of the manifest.
+func readManifest(path string, format string) ([]string, error) {
+	parser, exists := manifestParsers[format]
+	if !exists {
+		return nil, fmt.Errorf("manifest format %s is not supported", format)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := parser(content)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse manifest %s: %v", path, err)
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("manifest %s does not list any file", path)
+	}
+	dir := filepath.Dir(path)
+	for i, segment := range segments {
+		if !filepath.IsAbs(segment) {
+			segments[i] = filepath.Join(dir, segment)
+		}
+	}
+	return segments, nil
+}
+
+// parseLinesManifest returns the non-empty lines of content.
+func parseLinesManifest(content []byte) ([]string, error) {
+	var segments []string
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			segments = append(segments, string(line))
+		}
+	}
+	return segments, nil
+}
+
+// parseJSONManifest returns the strings of the JSON array in content.
+func parseJSONManifest(content []byte) ([]string, error) {
+	var segments []string
+	err := json.Unmarshal(content, &segments)
+	return segments, err
+}
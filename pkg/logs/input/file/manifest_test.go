@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeManifest(t *testing.T, dir string, content string) string {
+	path := filepath.Join(dir, "segments.idx")
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	assert.Nil(t, err)
+	return path
+}
+
+func TestReadLinesManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-manifest-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeManifest(t, dir, "app.log.2\n  app.log.1\n\n/var/log/app.log\n")
+	segments, err := readManifest(path, LinesManifestFormat)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "app.log.2"), filepath.Join(dir, "app.log.1"), "/var/log/app.log"}, segments)
+}
+
+func TestReadJSONManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-manifest-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeManifest(t, dir, `["app.log.1", "app.log"]`)
+	segments, err := readManifest(path, JSONManifestFormat)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "app.log.1"), filepath.Join(dir, "app.log")}, segments)
+
+	path = writeManifest(t, dir, `{"segments": ["app.log"]}`)
+	_, err = readManifest(path, JSONManifestFormat)
+	assert.NotNil(t, err)
+}
+
+func TestReadManifestFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-manifest-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = readManifest(filepath.Join(dir, "missing.idx"), LinesManifestFormat)
+	assert.NotNil(t, err)
+
+	path := writeManifest(t, dir, "\n\n")
+	_, err = readManifest(path, LinesManifestFormat)
+	assert.NotNil(t, err)
+
+	_, err = readManifest(path, "xml")
+	assert.NotNil(t, err)
+}
+
+func TestRegisterManifestParser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-manifest-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer delete(manifestParsers, "csv")
+
+	RegisterManifestParser("csv", func(content []byte) ([]string, error) {
+		if len(content) == 0 {
+			return nil, fmt.Errorf("empty manifest")
+		}
+		return strings.Split(strings.TrimSpace(string(content)), ","), nil
+	})
+	path := writeManifest(t, dir, "app.log.1,app.log\n")
+	segments, err := readManifest(path, "csv")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "app.log.1"), filepath.Join(dir, "app.log")}, segments)
+}
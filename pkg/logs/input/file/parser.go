@@ -19,6 +19,10 @@ const (
 	stderr = "stderr"
 )
 
+// partialFlag flags the lines split by the runtime which are followed by their next part,
+// the last part of a line is flagged with F.
+const partialFlag = "P"
+
 // containerdFileParser parses containerd file logs
 var containerdFileParser *parser
 
@@ -52,6 +56,18 @@ func (p *parser) Unwrap(line []byte) ([]byte, error) {
 	return components[3], nil
 }
 
+// IsPartial returns true if line is followed by the next part of the same line,
+// the runtime splits the lines longer than 16KB in several parts.
+func (p *parser) IsPartial(line []byte) bool {
+	components, err := parse(line)
+	if err != nil {
+		return false
+	}
+	// the flags are separated by colons
+	flags := bytes.SplitN(components[2], []byte{':'}, 2)
+	return string(flags[0]) == partialFlag
+}
+
 // getContainerdStatus returns the status of the message based on the value of the
 // STREAM_TYPE field in the header. It returns the status INFO by default
 func getContainerdStatus(streamType []byte) string {
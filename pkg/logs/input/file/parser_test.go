@@ -6,8 +6,11 @@
 package file
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/decoder"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/stretchr/testify/assert"
 )
@@ -50,3 +53,31 @@ func TestContainerdParserShouldFailWithInvalidInput(t *testing.T) {
 	_, err = parser.Parse(msg)
 	assert.Nil(t, err)
 }
+
+func TestContainerdParserIsPartial(t *testing.T) {
+	parser := containerdFileParser
+	assert.True(t, parser.IsPartial([]byte("2018-09-20T11:54:11.753589172Z stdout P anything")))
+	assert.True(t, parser.IsPartial([]byte("2018-09-20T11:54:11.753589172Z stdout P:foo anything")))
+	assert.False(t, parser.IsPartial([]byte(containerdHeaderOut+" anything")))
+	assert.False(t, parser.IsPartial([]byte("anything")))
+}
+
+func TestContainerdLinesSplitByTheRuntimeAreJoined(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	d := decoder.InitializeDecoder(source, containerdFileParser)
+	d.Start()
+	defer d.Stop()
+
+	// the runtime splits the lines every 16KB
+	part := strings.Repeat("a", 16*1024)
+	input := "2018-09-20T11:54:11.753589172Z stderr P " + part + "\n" +
+		"2018-09-20T11:54:11.753589173Z stderr P " + part + "\n" +
+		"2018-09-20T11:54:11.753589174Z stderr F end\n"
+	d.InputChan <- decoder.NewInput([]byte(input))
+
+	msg := <-d.OutputChan
+	assert.Equal(t, part+part+"end", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, "2018-09-20T11:54:11.753589172Z", msg.Timestamp)
+	assert.Equal(t, len(input), msg.RawDataLen)
+}
@@ -8,24 +8,35 @@ package file
 import (
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 )
 
-// Position returns the position from where logs should be collected.
-func Position(registry auditor.Registry, identifier string, tailFromBeginning bool) (int64, int, error) {
+// offsetSeparator separates the offset from the identifier of the file it was read in.
+const offsetSeparator = "@"
+
+// Position returns the position from where logs should be collected,
+// the offset registered is discarded when it was read in another file than the one identified by fileID,
+// which happens when the file has been rotated while the agent was not running.
+func Position(registry auditor.Registry, identifier string, fileID string, tailFromBeginning bool) (int64, int, error) {
 	var offset int64
 	var whence int
 	var err error
 	value := registry.GetOffset(identifier)
+	var registeredID string
+	if value != "" {
+		offset, registeredID, err = parseOffset(value)
+	}
 	switch {
+	case value != "" && err != nil:
+		offset, whence = 0, io.SeekEnd
+	case value != "" && registeredID != "" && fileID != "" && registeredID != fileID:
+		// the file has been rotated since the offset was registered, tail the new file from the beginning
+		offset, whence = 0, io.SeekStart
 	case value != "":
 		// an offset was registered, tail from the offset
 		whence = io.SeekStart
-		offset, err = strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			offset, whence = 0, io.SeekEnd
-		}
 	case tailFromBeginning:
 		// a new service has been discovered, tail from the beginning
 		offset, whence = 0, io.SeekStart
@@ -35,3 +46,27 @@ func Position(registry auditor.Registry, identifier string, tailFromBeginning bo
 	}
 	return offset, whence, err
 }
+
+// formatOffset returns the offset to register for a file,
+// tagged with the identifier of the file when known.
+func formatOffset(offset int64, fileID string) string {
+	value := strconv.FormatInt(offset, 10)
+	if fileID == "" {
+		return value
+	}
+	return value + offsetSeparator + fileID
+}
+
+// parseOffset returns the offset and the identifier of the file registered in value,
+// the identifier is empty for the offsets registered by the previous versions.
+func parseOffset(value string) (int64, string, error) {
+	var fileID string
+	if i := strings.Index(value, offsetSeparator); i >= 0 {
+		value, fileID = value[:i], value[i+len(offsetSeparator):]
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return offset, fileID, nil
+}
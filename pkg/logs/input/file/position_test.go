@@ -21,25 +21,54 @@ func TestPosition(t *testing.T) {
 	var offset int64
 	var whence int
 
-	offset, whence, err = Position(registry, "", false)
+	offset, whence, err = Position(registry, "", "", false)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(0), offset)
 	assert.Equal(t, io.SeekEnd, whence)
 
-	offset, whence, err = Position(registry, "", true)
+	offset, whence, err = Position(registry, "", "", true)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(0), offset)
 	assert.Equal(t, io.SeekStart, whence)
 
 	registry.SetOffset("123456789")
-	offset, whence, err = Position(registry, "", false)
+	offset, whence, err = Position(registry, "", "", false)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(123456789), offset)
 	assert.Equal(t, io.SeekStart, whence)
 
 	registry.SetOffset("foo")
-	offset, whence, err = Position(registry, "", false)
+	offset, whence, err = Position(registry, "", "", false)
 	assert.NotNil(t, err)
 	assert.Equal(t, int64(0), offset)
 	assert.Equal(t, io.SeekEnd, whence)
+
+	registry.SetOffset("123@1-2")
+	offset, whence, err = Position(registry, "", "1-2", false)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123), offset)
+	assert.Equal(t, io.SeekStart, whence)
+
+	// the file has been rotated since the offset was registered
+	registry.SetOffset("123@1-2")
+	offset, whence, err = Position(registry, "", "1-3", false)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), offset)
+	assert.Equal(t, io.SeekStart, whence)
+}
+
+func TestFormatAndParseOffset(t *testing.T) {
+	offset, fileID, err := parseOffset(formatOffset(123, "1-2"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123), offset)
+	assert.Equal(t, "1-2", fileID)
+
+	assert.Equal(t, "123", formatOffset(123, ""))
+	offset, fileID, err = parseOffset("123")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123), offset)
+	assert.Equal(t, "", fileID)
+
+	_, _, err = parseOffset("foo@1-2")
+	assert.NotNil(t, err)
 }
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package file
+
+import (
+	"sync"
+	"time"
+)
+
+// readerPool reads the files of many tailers with a fixed number of workers
+// instead of one goroutine per tailer.
+// The tailers are read in turn, a tailer at the end of its file is read again after its sleep duration.
+// It only pools the goroutines reading the files, it is not a limit of the goroutines of the inputs:
+// each tailer still decodes and forwards its lines in goroutines of its own,
+// and the network and container inputs keep one goroutine per connection.
+type readerPool struct {
+	mu      sync.Mutex
+	ready   *sync.Cond
+	queue   []*Tailer
+	stopped bool
+	done    sync.WaitGroup
+}
+
+// newReaderPool returns a new pool with its workers started.
+func newReaderPool(workers int) *readerPool {
+	p := &readerPool{}
+	p.ready = sync.NewCond(&p.mu)
+	p.done.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// add queues tailer to be read by the next worker available.
+func (p *readerPool) add(tailer *Tailer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.queue = append(p.queue, tailer)
+	p.ready.Signal()
+}
+
+// addAfter queues tailer once delay is elapsed,
+// the timer of the tailer is reused as it is queued again and again.
+func (p *readerPool) addAfter(tailer *Tailer, delay time.Duration) {
+	if tailer.readTimer == nil {
+		tailer.readTimer = time.AfterFunc(delay, func() {
+			p.add(tailer)
+		})
+		return
+	}
+	tailer.readTimer.Reset(delay)
+}
+
+// next returns the next tailer to read, blocks until one is queued,
+// returns false if the pool is stopped.
+func (p *readerPool) next() (*Tailer, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.queue) == 0 && !p.stopped {
+		p.ready.Wait()
+	}
+	if p.stopped {
+		return nil, false
+	}
+	tailer := p.queue[0]
+	p.queue[0] = nil
+	p.queue = p.queue[1:]
+	return tailer, true
+}
+
+// work reads the tailers queued until the pool is stopped.
+func (p *readerPool) work() {
+	defer p.done.Done()
+	for {
+		tailer, ok := p.next()
+		if !ok {
+			return
+		}
+		select {
+		case <-tailer.stop:
+			// stop reading data from file
+			tailer.onStop()
+			continue
+		default:
+		}
+		n, err := tailer.read()
+		switch {
+		case err != nil:
+			tailer.onStop()
+		case n == 0:
+			// wait for new data to come
+			p.addAfter(tailer, tailer.sleepDuration)
+		default:
+			p.add(tailer)
+		}
+	}
+}
+
+// stop stops the workers, the tailers must be stopped first.
+func (p *readerPool) stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.queue = nil
+	p.ready.Broadcast()
+	p.mu.Unlock()
+	p.done.Wait()
+}
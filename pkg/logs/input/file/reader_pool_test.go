@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// startTailers starts a tailer per file, read by readers when not nil.
+func startTailers(t testing.TB, testDir string, count int, readers *readerPool, outputChan chan *message.Message) []*Tailer {
+	var tailers []*Tailer
+	for i := 0; i < count; i++ {
+		path := fmt.Sprintf("%s/%d.log", testDir, i)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(fmt.Sprintf("hello %d\n", i)), 0644))
+		source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+		tailer := NewTailer(outputChan, source, path, 10*time.Millisecond)
+		tailer.readers = readers
+		assert.Nil(t, tailer.StartFromBeginning())
+		tailers = append(tailers, tailer)
+	}
+	return tailers
+}
+
+func stopTailers(tailers []*Tailer) {
+	for _, tailer := range tailers {
+		tailer.Stop()
+	}
+}
+
+func TestReaderPoolReadsAllTailers(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-reader-pool-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	readers := newReaderPool(2)
+	outputChan := make(chan *message.Message, 10)
+	tailers := startTailers(t, testDir, 10, readers, outputChan)
+
+	received := make(map[string]bool)
+	for range tailers {
+		received[string((<-outputChan).Content)] = true
+	}
+	for i := range tailers {
+		assert.True(t, received[fmt.Sprintf("hello %d", i)])
+	}
+
+	// the tailers at the end of their file are read again
+	f, err := os.OpenFile(tailers[3].path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	defer f.Close()
+	_, err = f.WriteString("hello again\n")
+	assert.Nil(t, err)
+	msg := <-outputChan
+	assert.Equal(t, "hello again", string(msg.Content))
+	offset, _, err := parseOffset(msg.Origin.Offset)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), offset)
+
+	stopTailers(tailers)
+	readers.stop()
+}
+
+// BenchmarkTailers starts and stops many tailers and reports the goroutines and the memory used while they run,
+// run it with -benchtime 1x and -v to compare:
+// go test -run none -bench Tailers -benchtime 1x -v ./pkg/logs/input/file/
+func BenchmarkTailers(b *testing.B) {
+	const count = 2000
+	for _, workers := range []int{0, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				testDir, err := ioutil.TempDir("", "log-reader-pool-bench-")
+				assert.Nil(b, err)
+
+				var readers *readerPool
+				if workers > 0 {
+					readers = newReaderPool(workers)
+				}
+				var before runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				goroutines := runtime.NumGoroutine()
+
+				outputChan := make(chan *message.Message, count)
+				tailers := startTailers(b, testDir, count, readers, outputChan)
+				for range tailers {
+					<-outputChan
+				}
+
+				var after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				b.Logf("%d tailers: %d goroutines, %d KB of stacks, %d KB of heap",
+					count, runtime.NumGoroutine()-goroutines, (after.StackInuse-before.StackInuse)/1024, (after.HeapInuse-before.HeapInuse)/1024)
+
+				stopTailers(tailers)
+				if readers != nil {
+					readers.stop()
+				}
+				os.RemoveAll(testDir)
+			}
+		})
+	}
+}
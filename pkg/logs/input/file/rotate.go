@@ -7,6 +7,7 @@ package file
 
 import (
 	"os"
+	"path/filepath"
 )
 
 // DidRotate returns true if the file has been log-rotated.
@@ -15,23 +16,51 @@ import (
 // - removed and recreated
 // - truncated
 func DidRotate(file *os.File, lastReadOffset int64) (bool, error) {
+	recreated, truncated, err := rotation(file, lastReadOffset)
+	return recreated || truncated, err
+}
+
+// rotation returns whether the file found at the path of file is another file, after a rename or a removal,
+// and whether it has been truncated, in which case it is still the same file but its content starts over.
+func rotation(file *os.File, lastReadOffset int64) (recreated bool, truncated bool, err error) {
 	f, err := openFile(file.Name())
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
+	defer f.Close()
 
 	fi1, err := f.Stat()
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
 
 	fi2, err := file.Stat()
 	if err != nil {
-		return true, nil
+		return true, false, nil
 	}
 
-	recreated := !os.SameFile(fi1, fi2)
-	truncated := fi1.Size() < lastReadOffset
+	recreated = !os.SameFile(fi1, fi2)
+	truncated = !recreated && fi1.Size() < lastReadOffset
 
-	return recreated || truncated, nil
+	return recreated, truncated, nil
+}
+
+// symlinkTarget returns the path of the file found at path once the symlinks are resolved,
+// an empty string if they can not be resolved.
+func symlinkTarget(path string) string {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	return target
+}
+
+// retargeted returns true if the symlink tailed by tailer points to another file than when the tailer started,
+// the symlinks are only followed for the sources allowing it.
+func retargeted(tailer *Tailer) bool {
+	if tailer.target == "" {
+		return false
+	}
+	target := symlinkTarget(tailer.path)
+	return target != "" && target != tailer.target
 }
@@ -6,6 +6,10 @@
 package file
 
 import (
+	"expvar"
+	"io"
+	"os"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -14,6 +18,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 	"github.com/DataDog/datadog-agent/pkg/logs/restart"
 )
@@ -21,6 +26,22 @@ import (
 // scanPeriod represents the period of time between two scans.
 const scanPeriod = 10 * time.Second
 
+// Policies applied when the limit of open files is reached
+const (
+	// IgnoreOpenFilesLimitPolicy does not tail the files above the limit until other files stop being tailed.
+	IgnoreOpenFilesLimitPolicy = "ignore"
+	// RotateOpenFilesLimitPolicy closes the files which stopped growing the longest time ago
+	// to tail the files waiting, in the order they were found.
+	RotateOpenFilesLimitPolicy = "rotate"
+)
+
+// evictedFile is the position of a file closed to tail another one,
+// it is tailed again from this position once it grows.
+type evictedFile struct {
+	offset int64
+	fileID string
+}
+
 // Scanner checks all files provided by fileProvider and create new tailers
 // or update the old ones if needed
 type Scanner struct {
@@ -33,21 +54,71 @@ type Scanner struct {
 	tailers             map[string]*Tailer
 	registry            auditor.Registry
 	tailerSleepDuration time.Duration
-	stop                chan struct{}
+	trackCollectionLag  bool
+	readers             *readerPool
+	eofFlushTimeout     time.Duration
+	readBufferSize      int
+	// existingFiles are the paths of the files which already existed when their source was added
+	// and are not tailed yet, they are tailed from the start position of their source once tailed
+	existingFiles map[*config.LogSource]map[string]bool
+	// rotateFiles closes the tailers of the files which stopped growing for idleTimeout
+	// when files are waiting for the limit of open files, queuedSince is when each file waiting was found
+	// and evicted holds the position of the files closed.
+	rotateFiles bool
+	idleTimeout time.Duration
+	queuedSince map[string]time.Time
+	evicted     map[string]evictedFile
+	// removedSinceScan are the sources removed since the last scan, their tailers are kept until the next scan
+	// for the new version of a source modified by a reload to take them over.
+	removedSinceScan map[*config.LogSource]bool
+	// segmentTailers are the tailers finishing to read the rotated segments of the manifests,
+	// they are kept until their segment leaves the manifest and count toward the limit until they stop.
+	segmentTailers map[string]*Tailer
+	// compressedFiles are the compressed files found when their source was added which are not read yet,
+	// only the ones compressed before the scanner was started, they are read once they have not been modified
+	// for compressedStableDelay as they may still be written, compressedReaders are the readers
+	// of the compressed files read since the scanner was started, lastCompressedReader the last one started.
+	started               time.Time
+	compressedFiles       map[*config.LogSource][]*File
+	compressedReaders     map[string]*compressedReader
+	lastCompressedReader  *compressedReader
+	compressedStableDelay time.Duration
+	stop                  chan struct{}
 }
 
 // NewScanner returns a new scanner.
-func NewScanner(sources *config.LogSources, tailingLimit int, pipelineProvider pipeline.Provider, registry auditor.Registry, tailerSleepDuration time.Duration) *Scanner {
+// When trackCollectionLag is set, each message has the number of bytes its tailer was behind
+// the end of the file when it read it, which costs a stat of the file per read.
+func NewScanner(sources *config.LogSources, tailingLimit int, pipelineProvider pipeline.Provider, registry auditor.Registry, tailerSleepDuration time.Duration, trackCollectionLag bool) *Scanner {
+	var readers *readerPool
+	if workers := config.LogsAgent.GetInt("logs_config.file_reader_workers"); workers > 0 {
+		readers = newReaderPool(workers)
+	}
 	return &Scanner{
-		pipelineProvider:    pipelineProvider,
-		tailingLimit:        tailingLimit,
-		addedSources:        sources.GetAddedForType(config.FileType),
-		removedSources:      sources.GetRemovedForType(config.FileType),
-		fileProvider:        NewProvider(tailingLimit),
-		tailers:             make(map[string]*Tailer),
-		registry:            registry,
-		tailerSleepDuration: tailerSleepDuration,
-		stop:                make(chan struct{}),
+		pipelineProvider:      pipelineProvider,
+		tailingLimit:          tailingLimit,
+		addedSources:          sources.GetAddedForType(config.FileType),
+		removedSources:        sources.GetRemovedForType(config.FileType),
+		fileProvider:          NewProvider(tailingLimit, config.LogsAgent.GetString("logs_config.file_conflict_policy")),
+		tailers:               make(map[string]*Tailer),
+		segmentTailers:        make(map[string]*Tailer),
+		registry:              registry,
+		tailerSleepDuration:   tailerSleepDuration,
+		trackCollectionLag:    trackCollectionLag,
+		readers:               readers,
+		eofFlushTimeout:       time.Duration(config.LogsAgent.GetInt("logs_config.eof_flush_timeout")) * time.Second,
+		readBufferSize:        config.LogsAgent.GetInt("logs_config.read_buffer_size"),
+		existingFiles:         make(map[*config.LogSource]map[string]bool),
+		rotateFiles:           config.LogsAgent.GetString("logs_config.open_files_limit_policy") == RotateOpenFilesLimitPolicy,
+		idleTimeout:           scanPeriod,
+		queuedSince:           make(map[string]time.Time),
+		evicted:               make(map[string]evictedFile),
+		removedSinceScan:      make(map[*config.LogSource]bool),
+		compressedFiles:       make(map[*config.LogSource][]*File),
+		compressedReaders:     make(map[string]*compressedReader),
+		started:               time.Now(),
+		compressedStableDelay: scanPeriod,
+		stop:                  make(chan struct{}),
 	}
 }
 
@@ -61,6 +132,9 @@ func (s *Scanner) Start() {
 func (s *Scanner) Stop() {
 	s.stop <- struct{}{}
 	s.cleanup()
+	if s.readers != nil {
+		s.readers.stop()
+	}
 }
 
 // run checks periodically if there are new files to tail and the state of its tailers until stop
@@ -90,6 +164,16 @@ func (s *Scanner) cleanup() {
 		stopper.Add(tailer)
 		delete(s.tailers, tailer.path)
 	}
+	for path, tailer := range s.segmentTailers {
+		if atomic.LoadInt32(&tailer.shouldStop) == 0 {
+			stopper.Add(tailer)
+		}
+		delete(s.segmentTailers, path)
+	}
+	for path, reader := range s.compressedReaders {
+		stopper.Add(reader)
+		delete(s.compressedReaders, path)
+	}
 	stopper.Stop()
 }
 
@@ -101,9 +185,15 @@ func (s *Scanner) cleanup() {
 // The Scanner needs to stop that previous tailer,
 // and start a new one for the new file.
 func (s *Scanner) scan() {
-	files := s.fileProvider.FilesToTail(s.activeSources)
+	limit := s.tailingLimit
+	if s.rotateFiles {
+		// the files above the limit wait for a tailer to be closed
+		limit = -1
+	}
+	files, matching := s.fileProvider.filesToTail(s.activeSources, limit)
 	filesTailed := make(map[string]bool)
-	tailersLen := len(s.tailers)
+	tailersLen := s.openTailers()
+	var queued []*File
 
 	for _, file := range files {
 		tailer, isTailed := s.tailers[file.Path]
@@ -111,14 +201,18 @@ func (s *Scanner) scan() {
 			// skip this tailer as it must be stopped
 			continue
 		}
+		if !isTailed && s.isEvictedAndIdle(file.Path) {
+			// the file has not grown since it was closed
+			continue
+		}
 		if !isTailed && tailersLen >= s.tailingLimit {
 			// can't create new tailer because tailingLimit is reached
+			queued = append(queued, file)
 			continue
 		}
 
 		if !isTailed && tailersLen < s.tailingLimit {
-			// create a new tailer tailing from the beginning of the file if no offset has been recorded
-			succeeded := s.startNewTailer(file, true)
+			succeeded := s.startTailerForFile(file)
 			if !succeeded {
 				// the setup failed, let's try to tail this file in the next scan
 				continue
@@ -128,29 +222,227 @@ func (s *Scanner) scan() {
 			continue
 		}
 
-		didRotate, err := DidRotate(tailer.file, tailer.GetReadOffset())
-		if err != nil {
+		if tailer.source != file.Source {
+			// the file is now tailed for a source with a higher precedence
+			succeeded := s.restartTailerForSource(tailer, file)
+			if !succeeded {
+				// the setup failed, let's try to tail this file in the next scan
+				continue
+			}
+			filesTailed[file.Path] = true
 			continue
 		}
-		if didRotate {
-			// restart tailer because of file-rotation on file
-			succeeded := s.restartTailerAfterFileRotation(tailer, file)
-			if !succeeded {
+
+		if file.Source.Config.ManifestFormat != "" {
+			// the rotations are notified by the manifest
+			filesTailed[file.Path] = true
+			continue
+		}
+
+		if retargeted(tailer) {
+			// the symlink now points to another file, which is not a rotation of the old one
+			if !s.restartTailerAfterRetarget(tailer, file) {
 				// the setup failed, let's try to tail this file in the next scan
 				continue
 			}
+			filesTailed[file.Path] = true
+			continue
+		}
+
+		recreated, truncated, err := rotation(tailer.file, tailer.GetReadOffset())
+		if err != nil {
+			continue
+		}
+		succeeded := true
+		switch {
+		case recreated:
+			// restart tailer because of file-rotation on file
+			succeeded = s.restartTailerAfterFileRotation(tailer, file)
+		case truncated:
+			// the file has been copied and truncated, its content starts over
+			succeeded = s.restartTailerAfterTruncation(tailer, file)
+		}
+		if !succeeded {
+			// the setup failed, let's try to tail this file in the next scan
+			continue
 		}
 
 		filesTailed[file.Path] = true
 	}
 
+	if s.rotateFiles {
+		queued = s.rotate(queued, filesTailed)
+	}
+	s.updateQueue(files, queued)
+	metrics.FilesQueued.Set(int64(len(queued) + matching - len(files)))
+
 	for path, tailer := range s.tailers {
 		// stop all tailers which have not been selected
 		_, shouldTail := filesTailed[path]
-		if !shouldTail {
+		if shouldTail {
+			continue
+		}
+		if s.removedSinceScan[tailer.source] {
+			// the source may be replaced by a new version taking over the tailer
+			continue
+		}
+		if tailer.source.Config.ManifestFormat != "" && s.isActive(tailer.source) {
+			// the file is not the active segment anymore, finish reading it before stopping
+			s.stopTailerAfterRotation(tailer)
+			s.segmentTailers[path] = tailer
+		} else {
 			s.stopTailer(tailer)
 		}
 	}
+	s.finishAllRotatedSegments()
+
+	s.removedSinceScan = make(map[*config.LogSource]bool)
+	s.updateCollectionLags()
+	s.readCompressedFiles()
+}
+
+// rotate closes the tailers of the files which stopped growing the longest time ago to tail the files queued,
+// in the order they were queued, and returns the files still queued.
+func (s *Scanner) rotate(queued []*File, filesTailed map[string]bool) []*File {
+	if len(queued) == 0 {
+		return queued
+	}
+	var idleTailers []*Tailer
+	for path := range filesTailed {
+		tailer := s.tailers[path]
+		if tailer.source.Config.ManifestFormat != "" || time.Since(tailer.getLastActivity()) < s.idleTimeout {
+			continue
+		}
+		if lag, err := tailer.GetCollectionLag(); err != nil || lag > 0 {
+			// the file has grown since it was last read
+			continue
+		}
+		idleTailers = append(idleTailers, tailer)
+	}
+	sort.Slice(idleTailers, func(i, j int) bool {
+		return idleTailers[i].getLastActivity().Before(idleTailers[j].getLastActivity())
+	})
+	sort.SliceStable(queued, func(i, j int) bool {
+		return s.queuedAt(queued[i].Path).Before(s.queuedAt(queued[j].Path))
+	})
+
+	var stillQueued []*File
+	for _, file := range queued {
+		if len(idleTailers) == 0 {
+			stillQueued = append(stillQueued, file)
+			continue
+		}
+		tailer := idleTailers[0]
+		idleTailers = idleTailers[1:]
+		s.evictTailer(tailer)
+		delete(filesTailed, tailer.path)
+		if !s.startTailerForFile(file) {
+			// the setup failed, let's try to tail this file in the next scan
+			stillQueued = append(stillQueued, file)
+			continue
+		}
+		filesTailed[file.Path] = true
+	}
+	return stillQueued
+}
+
+// queuedAt returns when the file found at path was queued, now if it was not queued yet.
+func (s *Scanner) queuedAt(path string) time.Time {
+	if queuedSince, exists := s.queuedSince[path]; exists {
+		return queuedSince
+	}
+	return time.Now()
+}
+
+// updateQueue keeps track of when the files queued were found
+// and forgets the position of the files closed which do not match any source anymore.
+func (s *Scanner) updateQueue(files []*File, queued []*File) {
+	queuedSince := make(map[string]time.Time)
+	for _, file := range queued {
+		queuedSince[file.Path] = s.queuedAt(file.Path)
+	}
+	s.queuedSince = queuedSince
+
+	matching := make(map[string]bool)
+	for _, file := range files {
+		matching[file.Path] = true
+	}
+	for path := range s.evicted {
+		if !matching[path] {
+			delete(s.evicted, path)
+		}
+	}
+}
+
+// evictTailer stops tailer to tail another file, its file is tailed again from the last message sent once it grows,
+// the offsets of the messages sent are committed by the auditor as for any other tailer.
+func (s *Scanner) evictTailer(tailer *Tailer) {
+	log.Infof("Closing %s which does not grow to tail a file waiting for the limit of open files", tailer.path)
+	// the tailer must be stopped before reading its offset to make sure all the data read is sent
+	tailer.Stop()
+	delete(s.tailers, tailer.path)
+	s.evicted[tailer.path] = evictedFile{
+		offset: tailer.decodedOffset,
+		fileID: tailer.fileID,
+	}
+}
+
+// isEvictedAndIdle returns true if the file found at path was closed to tail another file and has not grown since.
+func (s *Scanner) isEvictedAndIdle(path string) bool {
+	evicted, exists := s.evicted[path]
+	if !exists {
+		return false
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.Size() <= evicted.offset && fileIDAt(path) == evicted.fileID
+}
+
+// startTailerForFile starts a new tailer for a file which is not tailed, from where it was closed if it was evicted,
+// from its beginning if no offset has been recorded unless the file already existed when its source was added,
+// returns true if the operation succeeded, false otherwise
+func (s *Scanner) startTailerForFile(file *File) bool {
+	if evicted, exists := s.evicted[file.Path]; exists && evicted.fileID == fileIDAt(file.Path) {
+		tailer := s.createTailer(file, s.pipelineProvider.PipelineChanFor(file.Path))
+		if err := tailer.Start(evicted.offset, io.SeekStart); err != nil {
+			log.Warn(err)
+			return false
+		}
+		delete(s.evicted, file.Path)
+		s.tailers[file.Path] = tailer
+		return true
+	}
+	delete(s.evicted, file.Path)
+	tailFromBeginning := true
+	if s.existingFiles[file.Source][file.Path] {
+		tailFromBeginning = tailsFromBeginning(file.Source)
+	}
+	return s.startNewTailer(file, tailFromBeginning)
+}
+
+// updateCollectionLags updates the number of bytes left to read per source,
+// this costs one stat per tailer and per scan.
+func (s *Scanner) updateCollectionLags() {
+	lags := make(map[string]int64)
+	for _, tailer := range s.tailers {
+		lag, err := tailer.GetCollectionLag()
+		if err != nil {
+			continue
+		}
+		lags[tailer.source.Config.Path] += lag
+	}
+	// the values are updated in place rather than re-created so that the map is never seen empty,
+	// the sources removed are reported as not lagging
+	metrics.CollectionLagBytes.Do(func(kv expvar.KeyValue) {
+		if value, ok := kv.Value.(*expvar.Int); ok {
+			value.Set(lags[kv.Key])
+		}
+		delete(lags, kv.Key)
+	})
+	for path, lag := range lags {
+		value := &expvar.Int{}
+		value.Set(lag)
+		metrics.CollectionLagBytes.Set(path, value)
+	}
 }
 
 // addSource keeps track of the new source and launch new tailers for this source.
@@ -163,45 +455,93 @@ func (s *Scanner) addSource(source *config.LogSource) {
 func (s *Scanner) removeSource(source *config.LogSource) {
 	for i, src := range s.activeSources {
 		if src == source {
-			// no need to stop the tailer here, it will be stopped in the next iteration of scan.
+			// no need to stop the tailer here, it will be stopped in the next iteration of scan
+			// unless a source added in the meantime takes it over.
 			s.activeSources = append(s.activeSources[:i], s.activeSources[i+1:]...)
+			s.removedSinceScan[source] = true
 			break
 		}
 	}
+	delete(s.existingFiles, source)
+	delete(s.compressedFiles, source)
+	s.fileProvider.forgetPreExistingFiles(source)
+}
+
+// isActive returns true if the source is still active.
+func (s *Scanner) isActive(source *config.LogSource) bool {
+	for _, src := range s.activeSources {
+		if src == source {
+			return true
+		}
+	}
+	return false
 }
 
 // launch launches new tailers for a new source.
 func (s *Scanner) launchTailers(source *config.LogSource) {
+	if source.Config.ManifestFormat != "" {
+		s.finishRotatedSegments(source)
+	}
+	if source.Config.ReadCompressed {
+		s.queueCompressedFiles(source)
+	}
 	files, err := s.fileProvider.CollectFiles(source)
 	if err != nil {
 		source.Status.Error(err)
 		log.Warnf("Could not collect files: %v", err)
 		return
 	}
+	existingFiles := make(map[string]bool)
+	var preExistingFiles []*File
 	for _, file := range files {
-		if len(s.tailers) >= s.tailingLimit {
-			return
+		if tailer, isTailed := s.tailers[file.Path]; isTailed {
+			if !s.isActive(tailer.source) {
+				// the source replaces a removed one, it takes over the tailer from where it stopped decoding
+				s.restartTailerForSource(tailer, file)
+			}
+			continue
 		}
-		if _, isTailed := s.tailers[file.Path]; isTailed {
+		if source.Config.IgnorePreExisting {
+			preExistingFiles = append(preExistingFiles, file)
 			continue
 		}
-		var tailFromBeginning bool
-		if source.Config.Identifier != "" {
-			// only sources generated from a service discovery will contain a config identifier,
-			// in which case we want to collect all logs.
-			// FIXME: better detect a source that has been generated from a service discovery.
-			tailFromBeginning = true
+		if s.openTailers() >= s.tailingLimit || !s.startNewTailer(file, tailsFromBeginning(source)) {
+			// the file will be tailed by a next scan, from the start position of the source
+			existingFiles[file.Path] = true
 		}
-		s.startNewTailer(file, tailFromBeginning)
+	}
+	if len(existingFiles) > 0 {
+		s.existingFiles[source] = existingFiles
+	}
+	if source.Config.IgnorePreExisting {
+		// only the files first seen afterwards are tailed, from their beginning
+		s.fileProvider.ignorePreExistingFiles(source, preExistingFiles)
+	}
+}
+
+// tailsFromBeginning returns true if the files of source must be tailed from their beginning
+// when no offset has been recorded for them.
+func tailsFromBeginning(source *config.LogSource) bool {
+	switch {
+	case source.Config.StartPosition != "":
+		// the start position has been set explicitly in the config
+		return source.Config.StartPosition == config.BeginningStartPosition
+	case source.Config.Identifier != "":
+		// only sources generated from a service discovery will contain a config identifier,
+		// in which case we want to collect all logs.
+		// FIXME: better detect a source that has been generated from a service discovery.
+		return true
+	default:
+		return false
 	}
 }
 
 // startNewTailer creates a new tailer, making it tail from the last committed offset, the beginning or the end of the file,
 // returns true if the operation succeeded, false otherwise
 func (s *Scanner) startNewTailer(file *File, tailFromBeginning bool) bool {
-	tailer := s.createTailer(file, s.pipelineProvider.NextPipelineChan())
+	tailer := s.createTailer(file, s.pipelineProvider.PipelineChanFor(file.Path))
 
-	offset, whence, err := Position(s.registry, tailer.Identifier(), tailFromBeginning)
+	offset, whence, err := Position(s.registry, tailer.Identifier(), fileIDAt(file.Path), tailFromBeginning)
 	if err != nil {
 		log.Warnf("Could not recover offset for file with path %v: %v", file.Path, err)
 	}
@@ -213,9 +553,182 @@ func (s *Scanner) startNewTailer(file *File, tailFromBeginning bool) bool {
 	}
 
 	s.tailers[file.Path] = tailer
+	if existingFiles, exists := s.existingFiles[file.Source]; exists {
+		// the file is tailed from now on, it is tailed from the beginning if it is recreated
+		delete(existingFiles, file.Path)
+		if len(existingFiles) == 0 {
+			delete(s.existingFiles, file.Source)
+		}
+	}
 	return true
 }
 
+// openTailers returns the number of files open by the tailers, which is bounded by the tailing limit.
+func (s *Scanner) openTailers() int {
+	count := len(s.tailers)
+	for _, tailer := range s.segmentTailers {
+		if atomic.LoadInt32(&tailer.shouldStop) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// finishAllRotatedSegments finishes to read the rotated segments of all the manifests
+// and forgets the tailers of the segments no manifest lists anymore once they stopped.
+func (s *Scanner) finishAllRotatedSegments() {
+	listed := make(map[string]bool)
+	for _, source := range s.activeSources {
+		if source.Config.ManifestFormat == "" {
+			continue
+		}
+		for _, path := range s.finishRotatedSegments(source) {
+			listed[path] = true
+		}
+	}
+	for path, tailer := range s.segmentTailers {
+		if !listed[path] && atomic.LoadInt32(&tailer.shouldStop) != 0 {
+			delete(s.segmentTailers, path)
+		}
+	}
+}
+
+// finishRotatedSegments starts new tailers to finish reading the rotated segments
+// listed in the manifest of source that were not fully read, and returns the paths of the segments listed.
+// A segment never read is read from its beginning when a previous segment has been read,
+// as it was rotated before the scanner found it, or when the source is tailed from the beginning.
+func (s *Scanner) finishRotatedSegments(source *config.LogSource) []string {
+	files, err := s.fileProvider.CollectRotatedSegments(source)
+	if err != nil {
+		// the error is reported when collecting the active segment
+		return nil
+	}
+	var paths []string
+	previousRead := tailsFromBeginning(source)
+	for _, file := range files {
+		paths = append(paths, file.Path)
+		if _, isTailed := s.tailers[file.Path]; isTailed {
+			previousRead = true
+			continue
+		}
+		if _, isTailed := s.segmentTailers[file.Path]; isTailed {
+			previousRead = true
+			continue
+		}
+		if s.openTailers() >= s.tailingLimit {
+			// the segment is read by a next scan
+			continue
+		}
+		tailer := s.createTailer(file, s.pipelineProvider.PipelineChanFor(file.Path))
+		offset, _, err := parseOffset(s.registry.GetOffset(tailer.Identifier()))
+		switch {
+		case err == nil:
+			previousRead = true
+		case previousRead:
+			offset = 0
+		default:
+			// the segment was rotated before the source was collected
+			continue
+		}
+		fi, err := os.Stat(file.Path)
+		if err != nil || offset >= fi.Size() {
+			continue
+		}
+		err = tailer.Start(offset, io.SeekStart)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		log.Info("Finishing to read rotated segment ", file.Path)
+		tailer.StopAfterFileRotation()
+		s.segmentTailers[file.Path] = tailer
+	}
+	return paths
+}
+
+// queueCompressedFiles queues the compressed files of source to be read once,
+// only the files compressed before the scanner started are read as the ones compressed afterwards have been tailed.
+func (s *Scanner) queueCompressedFiles(source *config.LogSource) {
+	files, err := s.fileProvider.CollectCompressedFiles(source)
+	if err != nil {
+		log.Warnf("Could not collect compressed files: %v", err)
+		return
+	}
+	var queued []*File
+	for _, file := range files {
+		if fi, err := os.Stat(file.Path); err == nil && fi.ModTime().Before(s.started) {
+			queued = append(queued, file)
+		}
+	}
+	if len(queued) > 0 {
+		s.compressedFiles[source] = queued
+		s.readCompressedFiles()
+	}
+}
+
+// readCompressedFiles starts reading the compressed files queued which are not modified anymore,
+// each one once the previous one is read, the files read until their end by a previous run are skipped.
+func (s *Scanner) readCompressedFiles() {
+	for source, files := range s.compressedFiles {
+		var pending []*File
+		for _, file := range files {
+			if _, isRead := s.compressedReaders[file.Path]; isRead {
+				continue
+			}
+			fi, err := os.Stat(file.Path)
+			if err != nil {
+				continue
+			}
+			age := time.Since(fi.ModTime())
+			if age < s.compressedStableDelay {
+				// the file may still be written by the rotation
+				pending = append(pending, file)
+				continue
+			}
+			if age > auditor.DefaultTTL {
+				// the completion of the file may have expired from the registry, it is considered read
+				continue
+			}
+			s.startCompressedReader(file)
+		}
+		if len(pending) > 0 {
+			s.compressedFiles[source] = pending
+		} else {
+			delete(s.compressedFiles, source)
+		}
+	}
+}
+
+// startCompressedReader starts reading file from the offset registered by a previous run, if not read until its end.
+func (s *Scanner) startCompressedReader(file *File) {
+	fileID := fileIDAt(file.Path)
+	offset, shouldRead := compressedPosition(s.registry.GetOffset(compressedIdentifier(file.Path, fileID)), fileID)
+	if !shouldRead {
+		return
+	}
+	readBufferSize := s.readBufferSize
+	if readBufferSize <= 0 {
+		readBufferSize = defaultReadBufferSize
+	}
+	reader := newCompressedReader(s.pipelineProvider.PipelineChanFor(file.Path), file.Source, file.Path, fileID, readBufferSize)
+	if s.lastCompressedReader != nil && !s.lastCompressedReader.isDone() {
+		reader.previous = s.lastCompressedReader
+	}
+	if err := reader.Start(offset); err != nil {
+		log.Warn(err)
+		return
+	}
+	s.compressedReaders[file.Path] = reader
+	s.lastCompressedReader = reader
+}
+
+// stopTailerAfterRotation lets the tailer finish reading its file before stopping
+func (s *Scanner) stopTailerAfterRotation(tailer *Tailer) {
+	log.Info("Log rotation happened to ", tailer.path)
+	tailer.StopAfterFileRotation()
+	delete(s.tailers, tailer.path)
+}
+
 // stopTailer stops the tailer
 func (s *Scanner) stopTailer(tailer *Tailer) {
 	go tailer.Stop()
@@ -227,18 +740,91 @@ func (s *Scanner) stopTailer(tailer *Tailer) {
 func (s *Scanner) restartTailerAfterFileRotation(tailer *Tailer, file *File) bool {
 	log.Info("Log rotation happened to ", tailer.path)
 	tailer.StopAfterFileRotation()
-	tailer = s.createTailer(file, tailer.outputChan)
+	return s.startTailerAfter(tailer, file)
+}
+
+// restartTailerAfterRetarget stops tailer once it reached the end of the old target of its symlink
+// and starts a new one reading the new target from the beginning,
+// returns true if the new tailer is up and running, false if an error occurred
+func (s *Scanner) restartTailerAfterRetarget(tailer *Tailer, file *File) bool {
+	log.Infof("Symlink %s now points to %s instead of %s", tailer.path, symlinkTarget(tailer.path), tailer.target)
+	tailer.StopAtEndOfFile()
+	return s.startTailerAfter(tailer, file)
+}
+
+// startTailerAfter starts a new tailer reading file from the beginning once tailer reached the end of its file,
+// returns true if the new tailer is up and running, false if an error occurred
+func (s *Scanner) startTailerAfter(tailer *Tailer, file *File) bool {
+	newTailer := s.createTailer(file, tailer.outputChan)
+	// the new file is read once the rotated one is fully read
+	newTailer.previous = tailer
+	// a multi-line message may have been split between the end of the rotated file and the beginning of the new one
+	tailer.decoder.CarryPendingContentTo(newTailer.decoder)
 	// force reading file from beginning since it has been log-rotated
-	err := tailer.StartFromBeginning()
+	err := newTailer.StartFromBeginning()
 	if err != nil {
 		log.Warn(err)
 		return false
 	}
-	s.tailers[file.Path] = tailer
+	s.tailers[file.Path] = newTailer
+	return true
+}
+
+// restartTailerAfterTruncation stops tailer and starts a new one reading the truncated file from the beginning,
+// the tailer is stopped in the background not to block the scan while its decoder is flushed,
+// the new tailer only starts reading once it is stopped,
+// returns true if the new tailer is up and running, false if an error occurred
+func (s *Scanner) restartTailerAfterTruncation(tailer *Tailer, file *File) bool {
+	log.Info("Log truncation happened to ", tailer.path)
+	go tailer.Stop()
+	delete(s.tailers, tailer.path)
+	newTailer := s.createTailer(file, tailer.outputChan)
+	newTailer.previous = tailer
+	err := newTailer.StartFromBeginning()
+	if err != nil {
+		log.Warn(err)
+		return false
+	}
+	s.tailers[file.Path] = newTailer
 	return true
 }
 
+// restartTailerForSource stops tailer and starts a new one for the source of file from the end of the last line
+// decoded, the partial line the decoder of tailer was holding is read again by the new tailer so that it is neither
+// lost nor split, returns true if the new tailer is up and running, false if an error occurred
+func (s *Scanner) restartTailerForSource(tailer *Tailer, file *File) bool {
+	log.Infof("Tailing %s for source %s instead of %s", file.Path, file.Source.Name, tailer.source.Name)
+	// the tailer must be stopped before reading its offset to make sure all the data decoded is sent
+	tailer.Stop()
+	delete(s.tailers, tailer.path)
+	newTailer := s.createTailer(file, tailer.outputChan)
+	err := newTailer.Start(tailer.decodedOffset, io.SeekStart)
+	if err != nil {
+		log.Warn(err)
+		return false
+	}
+	s.tailers[file.Path] = newTailer
+	return true
+}
+
+// fileIDAt returns the identifier of the file found at path, or an empty string if it can not be read.
+func fileIDAt(path string) string {
+	f, err := openFile(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return fileID(f)
+}
+
 // createTailer returns a new initialized tailer
 func (s *Scanner) createTailer(file *File, outputChan chan *message.Message) *Tailer {
-	return NewTailer(outputChan, file.Source, file.Path, s.tailerSleepDuration)
+	tailer := NewTailer(outputChan, file.Source, file.Path, s.tailerSleepDuration)
+	tailer.trackCollectionLag = s.trackCollectionLag
+	tailer.readers = s.readers
+	tailer.eofFlushTimeout = s.eofFlushTimeout
+	if s.readBufferSize > 0 {
+		tailer.readBufferSize = s.readBufferSize
+	}
+	return tailer
 }
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	auditor "github.com/DataDog/datadog-agent/pkg/logs/auditor/mock"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline/mock"
 )
@@ -60,7 +62,7 @@ func (suite *ScannerTestSuite) SetupTest() {
 	suite.openFilesLimit = 100
 	suite.source = config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: suite.testPath})
 	sleepDuration := 20 * time.Millisecond
-	suite.s = NewScanner(config.NewLogSources(), suite.openFilesLimit, suite.pipelineProvider, auditor.NewRegistry(), sleepDuration)
+	suite.s = NewScanner(config.NewLogSources(), suite.openFilesLimit, suite.pipelineProvider, auditor.NewRegistry(), sleepDuration, false)
 	suite.s.activeSources = append(suite.s.activeSources, suite.source)
 	suite.s.scan()
 }
@@ -133,6 +135,49 @@ func (suite *ScannerTestSuite) TestScannerScanWithLogRotation() {
 	suite.Equal("hello again", string(msg.Content))
 }
 
+func TestScannerJoinsMultiLineMessageSplitByLogRotation(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/test.log", testDir)
+	file, err := os.Create(path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	pipelineProvider := mock.NewMockProvider()
+	outputChan := pipelineProvider.NextPipelineChan()
+	scanner := NewScanner(config.NewLogSources(), 2, pipelineProvider, auditor.NewRegistry(), 20*time.Millisecond, false)
+	logsConfig := &config.LogsConfig{
+		Type:            config.FileType,
+		Path:            path,
+		ProcessingRules: []config.ProcessingRule{{Type: config.MultiLine, Name: "stack_traces", Pattern: "^[0-9]{4}-"}},
+	}
+	assert.Nil(t, logsConfig.Compile())
+	scanner.addSource(config.NewLogSource("", logsConfig))
+	defer scanner.cleanup()
+
+	// the stack trace is split between the rotated file and the new one
+	_, err = file.WriteString("2018-01-01 Exception\n\tat first\n")
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+	err = os.Rename(path, fmt.Sprintf("%s.1", path))
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	newFile, err := os.Create(path)
+	assert.Nil(t, err)
+	defer newFile.Close()
+	_, err = newFile.WriteString("\tat second\n2018-01-01 next\n")
+	assert.Nil(t, err)
+	scanner.scan()
+
+	msg := <-outputChan
+	assert.Equal(t, "2018-01-01 Exception\\n\tat first\\n\tat second", string(msg.Content))
+	msg = <-outputChan
+	assert.Equal(t, "2018-01-01 next", string(msg.Content))
+}
+
 func (suite *ScannerTestSuite) TestScannerScanWithLogRotationCopyTruncate() {
 	s := suite.s
 	source := suite.source
@@ -162,6 +207,83 @@ func (suite *ScannerTestSuite) TestScannerScanWithLogRotationCopyTruncate() {
 	suite.Equal("third", string(msg.Content))
 }
 
+func (suite *ScannerTestSuite) TestScannerScanWithLogRotationReadsRotatedFileFirst() {
+	s := suite.s
+	source := suite.source
+
+	_, err := suite.testFile.WriteString("hello world\n")
+	suite.Nil(err)
+	msg := <-suite.outputChan
+	suite.Equal("hello world", string(msg.Content))
+
+	// the logs written to the rotated file before the rotation is detected must not be lost
+	tailer := s.tailers[source.Config.Path]
+	suite.Nil(os.Rename(suite.testPath, suite.testRotatedPath))
+	_, err = suite.testFile.WriteString("end of rotated file\n")
+	suite.Nil(err)
+	f, err := os.Create(suite.testPath)
+	suite.Nil(err)
+	defer f.Close()
+	_, err = f.WriteString("hello again\n")
+	suite.Nil(err)
+
+	s.scan()
+	newTailer := s.tailers[source.Config.Path]
+	suite.True(tailer != newTailer)
+
+	msg = <-suite.outputChan
+	suite.Equal("end of rotated file", string(msg.Content))
+	msg = <-suite.outputChan
+	suite.Equal("hello again", string(msg.Content))
+	// the new tailer waited for the rotated file to be read, it read its file once the message is received
+	suite.Nil(newTailer.previous)
+	suite.NotEqual(tailer.fileID, newTailer.fileID)
+	offset, fileID, err := parseOffset(msg.Origin.Offset)
+	suite.Nil(err)
+	suite.Equal(int64(len("hello again\n")), offset)
+	suite.Equal(newTailer.fileID, fileID)
+}
+
+func (suite *ScannerTestSuite) TestScannerScanWithLogRotationCopyTruncateDoesNotReadTwice() {
+	s := suite.s
+	source := suite.source
+
+	_, err := suite.testFile.WriteString("hello world\n")
+	suite.Nil(err)
+	msg := <-suite.outputChan
+	suite.Equal("hello world", string(msg.Content))
+
+	tailer := s.tailers[source.Config.Path]
+	suite.Nil(suite.testFile.Truncate(0))
+	_, err = suite.testFile.Seek(0, 0)
+	suite.Nil(err)
+	_, err = suite.testFile.WriteString("third\n")
+	suite.Nil(err)
+
+	s.scan()
+	newTailer := s.tailers[source.Config.Path]
+	suite.True(tailer != newTailer)
+	// the file is the same, only its content starts over
+	suite.Equal(tailer.fileID, newTailer.fileID)
+
+	msg = <-suite.outputChan
+	suite.Equal("third", string(msg.Content))
+
+	_, err = suite.testFile.WriteString("a much longer line than the first one\n")
+	suite.Nil(err)
+	msg = <-suite.outputChan
+	suite.Equal("a much longer line than the first one", string(msg.Content))
+	offset, _, err := parseOffset(msg.Origin.Offset)
+	suite.Nil(err)
+	suite.Equal(int64(len("third\na much longer line than the first one\n")), offset)
+
+	select {
+	case msg = <-suite.outputChan:
+		suite.Fail("unexpected message", string(msg.Content))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 func (suite *ScannerTestSuite) TestScannerScanWithFileRemovedAndCreated() {
 	s := suite.s
 	tailerLen := len(s.tailers)
@@ -209,7 +331,7 @@ func TestScannerScanStartNewTailer(t *testing.T) {
 	path = fmt.Sprintf("%s/*.log", testDir)
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
-	scanner := NewScanner(config.NewLogSources(), openFilesLimit, mock.NewMockProvider(), auditor.NewRegistry(), sleepDuration)
+	scanner := NewScanner(config.NewLogSources(), openFilesLimit, mock.NewMockProvider(), auditor.NewRegistry(), sleepDuration, false)
 	scanner.activeSources = append(scanner.activeSources, config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path}))
 
 	// create file
@@ -233,6 +355,424 @@ func TestScannerScanStartNewTailer(t *testing.T) {
 	assert.Equal(t, "world", string(msg.Content))
 }
 
+func TestScannerScanDiscardsOffsetOfRotatedFile(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/test.log", testDir)
+	assert.Nil(t, ioutil.WriteFile(path, []byte("hello\nworld\n"), 0644))
+
+	// the offset was registered for another file, rotated while the agent was not running
+	registry := auditor.NewRegistry()
+	registry.SetOffset(formatOffset(6, "0-0"))
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), registry, 20*time.Millisecond, false)
+	scanner.activeSources = append(scanner.activeSources, config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path}))
+	scanner.scan()
+	defer scanner.cleanup()
+
+	tailer := scanner.tailers[path]
+	msg := <-tailer.outputChan
+	assert.Equal(t, "hello", string(msg.Content))
+	msg = <-tailer.outputChan
+	assert.Equal(t, "world", string(msg.Content))
+
+	// the offset was registered for this file
+	scanner.cleanup()
+	registry.SetOffset(formatOffset(6, tailer.fileID))
+	scanner.scan()
+	tailer = scanner.tailers[path]
+	msg = <-tailer.outputChan
+	assert.Equal(t, "world", string(msg.Content))
+}
+
+func TestScannerAddSourceHonorsStartPosition(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	for _, startPosition := range []string{config.BeginningStartPosition, config.EndStartPosition} {
+		path := fmt.Sprintf("%s/%s.log", testDir, startPosition)
+		assert.Nil(t, ioutil.WriteFile(path, []byte("hello\n"), 0644))
+
+		scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+		scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path, StartPosition: startPosition}))
+		tailer := scanner.tailers[path]
+		assert.NotNil(t, tailer)
+
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		assert.Nil(t, err)
+		_, err = file.WriteString("world\n")
+		assert.Nil(t, err)
+		file.Close()
+
+		msg := <-tailer.outputChan
+		if startPosition == config.BeginningStartPosition {
+			assert.Equal(t, "hello", string(msg.Content))
+			msg = <-tailer.outputChan
+		}
+		assert.Equal(t, "world", string(msg.Content))
+		scanner.cleanup()
+	}
+}
+
+func TestScannerScanHonorsStartPositionOfExistingFiles(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	for _, name := range []string{"a", "b"} {
+		assert.Nil(t, ioutil.WriteFile(fmt.Sprintf("%s/%s.log", testDir, name), []byte("hello\n"), 0644))
+	}
+
+	// only one file can be tailed when the source is added
+	scanner := NewScanner(config.NewLogSources(), 1, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir)}))
+	assert.Equal(t, 1, len(scanner.tailers))
+
+	for path := range scanner.tailers {
+		scanner.stopTailer(scanner.tailers[path])
+		assert.Nil(t, os.Remove(path))
+	}
+
+	// the file which already existed is tailed from the end like the first one
+	scanner.scan()
+	path := fmt.Sprintf("%s/b.log", testDir)
+	tailer := scanner.tailers[path]
+	assert.NotNil(t, tailer)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString("world\n")
+	assert.Nil(t, err)
+	file.Close()
+
+	msg := <-tailer.outputChan
+	assert.Equal(t, "world", string(msg.Content))
+	scanner.cleanup()
+}
+
+func TestScannerIgnoresPreExistingFiles(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	oldPath := fmt.Sprintf("%s/old.log", testDir)
+	assert.Nil(t, ioutil.WriteFile(oldPath, []byte("old\n"), 0644))
+
+	pipelineProvider := mock.NewMockProvider()
+	outputChan := pipelineProvider.NextPipelineChan()
+	scanner := NewScanner(config.NewLogSources(), 2, pipelineProvider, auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir), IgnorePreExisting: true}))
+	defer scanner.cleanup()
+	assert.Equal(t, 0, len(scanner.tailers))
+
+	// the file present when the source was added is never tailed, even once it grows
+	file, err := os.OpenFile(oldPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString("older\n")
+	assert.Nil(t, err)
+	file.Close()
+	scanner.scan()
+	assert.Equal(t, 0, len(scanner.tailers))
+
+	// the new files are tailed from their beginning
+	newPath := fmt.Sprintf("%s/new.log", testDir)
+	assert.Nil(t, ioutil.WriteFile(newPath, []byte("hello\n"), 0644))
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+	msg := <-outputChan
+	assert.Equal(t, "hello", string(msg.Content))
+
+	// the rotations of the new files are followed
+	assert.Nil(t, os.Rename(newPath, fmt.Sprintf("%s.1", newPath)))
+	assert.Nil(t, ioutil.WriteFile(newPath, []byte("world\n"), 0644))
+	scanner.scan()
+	msg = <-outputChan
+	assert.Equal(t, "world", string(msg.Content))
+
+	// the file present when the source was added is tailed once it is replaced by a new one
+	assert.Nil(t, os.Rename(oldPath, fmt.Sprintf("%s.1", oldPath)))
+	assert.Nil(t, ioutil.WriteFile(oldPath, []byte("rotated\n"), 0644))
+	scanner.scan()
+	assert.Equal(t, 2, len(scanner.tailers))
+	msg = <-outputChan
+	assert.Equal(t, "rotated", string(msg.Content))
+}
+
+func TestScannerScanUpdatesCollectionLag(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/*.log", testDir)
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.activeSources = append(scanner.activeSources, config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path}))
+
+	file, err := os.Create(fmt.Sprintf("%s/test.log", testDir))
+	assert.Nil(t, err)
+	defer file.Close()
+	_, err = file.WriteString("hello\n")
+	assert.Nil(t, err)
+
+	scanner.scan()
+	defer scanner.cleanup()
+	lag := metrics.CollectionLagBytes.Get(path)
+	assert.NotNil(t, lag)
+
+	tailer := scanner.tailers[file.Name()]
+	<-tailer.outputChan
+	scanner.scan()
+	// the gauge is updated in place so that it is never reset between two scrapes
+	assert.True(t, lag == metrics.CollectionLagBytes.Get(path))
+	assert.Equal(t, "0", lag.String())
+}
+
+func TestScannerFollowsManifestRotations(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	for _, name := range []string{"app.log.1", "app.log.2"} {
+		err = ioutil.WriteFile(fmt.Sprintf("%s/%s", testDir, name), []byte(name+"\n"), 0644)
+		assert.Nil(t, err)
+	}
+	manifest := writeManifest(t, testDir, "app.log.1\napp.log.2\n")
+
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: manifest, ManifestFormat: LinesManifestFormat})
+	scanner.activeSources = append(scanner.activeSources, source)
+	defer scanner.cleanup()
+
+	// only the active segment is tailed
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+	tailer := scanner.tailers[fmt.Sprintf("%s/app.log.2", testDir)]
+	assert.NotNil(t, tailer)
+	msg := <-tailer.outputChan
+	assert.Equal(t, "app.log.2", string(msg.Content))
+
+	// the previous active segment is read until it stops after the rotation
+	err = ioutil.WriteFile(fmt.Sprintf("%s/app.log.3", testDir), []byte("app.log.3\n"), 0644)
+	assert.Nil(t, err)
+	writeManifest(t, testDir, "app.log.1\napp.log.2\napp.log.3\n")
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tailer.didFileRotate))
+	newTailer := scanner.tailers[fmt.Sprintf("%s/app.log.3", testDir)]
+	assert.NotNil(t, newTailer)
+	msg = <-newTailer.outputChan
+	assert.Equal(t, "app.log.3", string(msg.Content))
+}
+
+func TestScannerFinishesRotatedSegmentsOfManifest(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s/app.log.1", testDir), []byte("foo\nbar\n"), 0644)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(fmt.Sprintf("%s/app.log.2", testDir), []byte("foo\n"), 0644)
+	assert.Nil(t, err)
+	manifest := writeManifest(t, testDir, `["app.log.1", "app.log.2"]`)
+
+	// the rotated segment has been read up to "bar"
+	registry := auditor.NewRegistry()
+	registry.SetOffset("4")
+	pipelineProvider := mock.NewMockProvider()
+	scanner := NewScanner(config.NewLogSources(), 2, pipelineProvider, registry, 20*time.Millisecond, false)
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: manifest, ManifestFormat: JSONManifestFormat})
+	scanner.addSource(source)
+	defer scanner.cleanup()
+
+	msg := <-pipelineProvider.NextPipelineChan()
+	assert.Equal(t, "bar", string(msg.Content))
+	assert.Equal(t, 1, len(scanner.tailers))
+	assert.NotNil(t, scanner.tailers[fmt.Sprintf("%s/app.log.2", testDir)])
+}
+
+func TestScannerFinishesSegmentsRotatedBetweenScans(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s/app.log.1", testDir), []byte("foo\n"), 0644)
+	assert.Nil(t, err)
+	manifest := writeManifest(t, testDir, "app.log.1\n")
+
+	pipelineProvider := mock.NewMockProvider()
+	scanner := NewScanner(config.NewLogSources(), 2, pipelineProvider, auditor.NewRegistry(), 20*time.Millisecond, false)
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: manifest, ManifestFormat: LinesManifestFormat})
+	scanner.activeSources = append(scanner.activeSources, source)
+	defer scanner.cleanup()
+
+	scanner.scan()
+	msg := <-pipelineProvider.NextPipelineChan()
+	assert.Equal(t, "foo", string(msg.Content))
+
+	// the manifest rotated twice, the segment in between has never been read
+	err = ioutil.WriteFile(fmt.Sprintf("%s/app.log.2", testDir), []byte("bar\n"), 0644)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(fmt.Sprintf("%s/app.log.3", testDir), nil, 0644)
+	assert.Nil(t, err)
+	writeManifest(t, testDir, "app.log.1\napp.log.2\napp.log.3\n")
+
+	// the rotated segment still read counts toward the limit
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+	assert.Equal(t, 1, len(scanner.segmentTailers))
+	assert.NotNil(t, scanner.segmentTailers[fmt.Sprintf("%s/app.log.1", testDir)])
+	assert.Equal(t, 2, scanner.openTailers())
+
+	// the segment is read from its beginning by the next scan once a file can be open
+	scanner.tailingLimit = 3
+	scanner.scan()
+	assert.Equal(t, 2, len(scanner.segmentTailers))
+	msg = <-pipelineProvider.NextPipelineChan()
+	assert.Equal(t, "bar", string(msg.Content))
+	assert.Equal(t, 3, scanner.openTailers())
+}
+
+func TestScannerSwitchesTailerToSourceWithHigherPrecedence(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/test.log", testDir)
+	file, err := os.Create(path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	source := config.NewLogSource("glob", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir)})
+	scanner.activeSources = append(scanner.activeSources, source)
+	defer scanner.cleanup()
+
+	scanner.scan()
+	tailer := scanner.tailers[path]
+	_, err = file.WriteString("hello\n")
+	assert.Nil(t, err)
+	msg := <-tailer.outputChan
+	assert.Equal(t, source, msg.Origin.LogSource)
+
+	// a source with a higher precedence takes over the file from the last offset read
+	otherSource := config.NewLogSource("file", &config.LogsConfig{Type: config.FileType, Path: path, Precedence: 1})
+	scanner.activeSources = append(scanner.activeSources, otherSource)
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+	tailer = scanner.tailers[path]
+	assert.Equal(t, otherSource, tailer.source)
+	_, err = file.WriteString("world\n")
+	assert.Nil(t, err)
+	msg = <-tailer.outputChan
+	assert.Equal(t, "world", string(msg.Content))
+	assert.Equal(t, otherSource, msg.Origin.LogSource)
+}
+
+func TestScannerHandsOffTheTailerToTheNewVersionOfAReloadedSource(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/test.log", testDir)
+	file, err := os.Create(path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	source := config.NewLogSource("app", &config.LogsConfig{Type: config.FileType, Path: path})
+	scanner.addSource(source)
+	defer scanner.cleanup()
+
+	tailer := scanner.tailers[path]
+	outputChan := tailer.outputChan
+	_, err = file.WriteString("hello\nwor")
+	assert.Nil(t, err)
+	msg := <-outputChan
+	assert.Equal(t, "hello", string(msg.Content))
+	// wait for the partial line to be read
+	for i := 0; i < 100 && tailer.GetReadOffset() < 9; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int64(9), tailer.GetReadOffset())
+
+	// the config of the source changes in the middle of a line, a scan may happen during the reload
+	newSource := config.NewLogSource("app", &config.LogsConfig{Type: config.FileType, Path: path, Tags: []string{"version:2"}})
+	scanner.removeSource(source)
+	scanner.scan()
+	assert.Equal(t, tailer, scanner.tailers[path])
+	scanner.addSource(newSource)
+	assert.Equal(t, 1, len(scanner.tailers))
+	assert.Equal(t, newSource, scanner.tailers[path].source)
+
+	_, err = file.WriteString("ld\nagain\n")
+	assert.Nil(t, err)
+	msg = <-outputChan
+	assert.Equal(t, "world", string(msg.Content))
+	assert.Equal(t, newSource, msg.Origin.LogSource)
+	msg = <-outputChan
+	assert.Equal(t, "again", string(msg.Content))
+	select {
+	case msg = <-outputChan:
+		assert.Fail(t, "unexpected message", string(msg.Content))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestScannerStopsTheTailersOfARemovedSourceAfterTheNextScan(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/test.log", testDir)
+	_, err = os.Create(path)
+	assert.Nil(t, err)
+
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	source := config.NewLogSource("app", &config.LogsConfig{Type: config.FileType, Path: path})
+	scanner.addSource(source)
+	defer scanner.cleanup()
+	assert.Equal(t, 1, len(scanner.tailers))
+
+	scanner.removeSource(source)
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+	scanner.scan()
+	assert.Equal(t, 0, len(scanner.tailers))
+}
+
+func TestScannerStopsTheTailersOfTheFilesExcluded(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	for _, name := range []string{"app.log", "debug-app.log"} {
+		_, err = os.Create(fmt.Sprintf("%s/%s", testDir, name))
+		assert.Nil(t, err)
+	}
+
+	scanner := NewScanner(config.NewLogSources(), 3, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir), ExcludePaths: []string{"debug-*.log"}})
+	scanner.activeSources = append(scanner.activeSources, source)
+	defer scanner.cleanup()
+
+	// the files excluded are skipped when discovered
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+	assert.NotNil(t, scanner.tailers[fmt.Sprintf("%s/app.log", testDir)])
+	_, err = os.Create(fmt.Sprintf("%s/debug-other.log", testDir))
+	assert.Nil(t, err)
+	scanner.scan()
+	assert.Equal(t, 1, len(scanner.tailers))
+
+	// the tailers of the files excluded afterwards are stopped
+	scanner.activeSources = []*config.LogSource{
+		config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir), ExcludePaths: []string{"*app.log", "debug-*.log"}}),
+	}
+	scanner.scan()
+	assert.Equal(t, 0, len(scanner.tailers))
+}
+
 func TestScannerScanWithTooManyFiles(t *testing.T) {
 	var err error
 	var path string
@@ -257,7 +797,7 @@ func TestScannerScanWithTooManyFiles(t *testing.T) {
 	path = fmt.Sprintf("%s/*.log", testDir)
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
-	scanner := NewScanner(config.NewLogSources(), openFilesLimit, mock.NewMockProvider(), auditor.NewRegistry(), sleepDuration)
+	scanner := NewScanner(config.NewLogSources(), openFilesLimit, mock.NewMockProvider(), auditor.NewRegistry(), sleepDuration, false)
 	scanner.activeSources = append(scanner.activeSources, config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path}))
 
 	// test at scan
@@ -274,3 +814,189 @@ func TestScannerScanWithTooManyFiles(t *testing.T) {
 	scanner.scan()
 	assert.Equal(t, 2, len(scanner.tailers))
 }
+
+func TestScannerRotatesIdleFilesWhenTooManyFiles(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	for _, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, ioutil.WriteFile(fmt.Sprintf("%s/%s.log", testDir, name), []byte(name+"\n"), 0644))
+	}
+
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.rotateFiles = true
+	scanner.idleTimeout = time.Hour
+	scanner.activeSources = append(scanner.activeSources, config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir)}))
+	defer scanner.cleanup()
+
+	// the last file waits for the limit of open files
+	scanner.scan()
+	assert.Equal(t, 2, len(scanner.tailers))
+	assert.Nil(t, scanner.tailers[fmt.Sprintf("%s/c.log", testDir)])
+	assert.Equal(t, int64(1), metrics.FilesQueued.Value())
+	outputChan := scanner.pipelineProvider.NextPipelineChan()
+	<-outputChan
+	<-outputChan
+
+	// one of the files fully read is closed to tail the file queued
+	scanner.idleTimeout = 0
+	scanner.scan()
+	assert.Equal(t, 2, len(scanner.tailers))
+	assert.NotNil(t, scanner.tailers[fmt.Sprintf("%s/c.log", testDir)])
+	assert.Equal(t, int64(0), metrics.FilesQueued.Value())
+	assert.Equal(t, "c", string((<-outputChan).Content))
+	assert.Equal(t, 1, len(scanner.evicted))
+
+	// the file closed is tailed again from where it was closed once it grows
+	var evictedPath string
+	for path := range scanner.evicted {
+		evictedPath = path
+	}
+	file, err := os.OpenFile(evictedPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString("again\n")
+	assert.Nil(t, err)
+	file.Close()
+
+	scanner.scan()
+	assert.Equal(t, 2, len(scanner.tailers))
+	assert.NotNil(t, scanner.tailers[evictedPath])
+	assert.Equal(t, "again", string((<-outputChan).Content))
+}
+
+func TestScannerFollowsSymlinkRetargets(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	oldTarget, newTarget, link := fmt.Sprintf("%s/v1.log", testDir), fmt.Sprintf("%s/v2.log", testDir), fmt.Sprintf("%s/current", testDir)
+	assert.Nil(t, ioutil.WriteFile(oldTarget, []byte("foo\n"), 0644))
+	assert.Nil(t, os.Symlink(oldTarget, link))
+
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: link, StartPosition: config.BeginningStartPosition, FollowSymlinkRetarget: true}))
+	defer scanner.cleanup()
+	tailer := scanner.tailers[link]
+	assert.NotNil(t, tailer)
+	assert.Equal(t, "foo", string((<-tailer.outputChan).Content))
+
+	// the symlink is repointed while the old target is still written
+	assert.Nil(t, ioutil.WriteFile(newTarget, []byte("bar\n"), 0644))
+	assert.Nil(t, os.Remove(link))
+	assert.Nil(t, os.Symlink(newTarget, link))
+	file, err := os.OpenFile(oldTarget, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString("baz\n")
+	assert.Nil(t, err)
+	file.Close()
+
+	assert.Equal(t, "baz", string((<-tailer.outputChan).Content))
+
+	// the tailer of the old target stops at its end and the new target is read from the beginning
+	scanner.scan()
+	newTailer := scanner.tailers[link]
+	assert.True(t, tailer != newTailer)
+	assert.Equal(t, "bar", string((<-tailer.outputChan).Content))
+	<-tailer.done
+	assert.Equal(t, symlinkTarget(newTarget), newTailer.target)
+}
+
+func TestScannerReadsTheCompressedFilesOnceInOrder(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/app.log", testDir)
+	assert.Nil(t, ioutil.WriteFile(path, []byte("live\n"), 0644))
+	writeCompressedFile(t, path+".1.gz", "one\n")
+	writeCompressedFile(t, path+".2.gz", "two\nthree\n")
+	assert.Nil(t, os.Chtimes(path+".1.gz", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+	assert.Nil(t, os.Chtimes(path+".2.gz", time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour)))
+
+	pipelineProvider := mock.NewMockProvider()
+	scanner := NewScanner(config.NewLogSources(), 2, pipelineProvider, auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path + "*", ReadCompressed: true}))
+	defer scanner.cleanup()
+
+	// the compressed files are read from the oldest one and are never tailed
+	outputChan := pipelineProvider.NextPipelineChan()
+	assert.Equal(t, "two", string((<-outputChan).Content))
+	msg := <-outputChan
+	assert.Equal(t, "three", string(msg.Content))
+	assert.Equal(t, compressedFileDone, msg.Origin.Offset)
+	assert.Equal(t, "one", string((<-outputChan).Content))
+	assert.Equal(t, 1, len(scanner.tailers))
+	assert.NotNil(t, scanner.tailers[path])
+
+	// the files are not read again when the source is reloaded
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path + "*", ReadCompressed: true})
+	scanner.addSource(source)
+	scanner.scan()
+	assert.Equal(t, 2, len(scanner.compressedReaders))
+	assert.Equal(t, 0, len(outputChan))
+}
+
+func TestScannerSkipsTheCompressedFilesAlreadyRead(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/app.log", testDir)
+	writeCompressedFile(t, path+".1.gz", "one\n")
+	assert.Nil(t, os.Chtimes(path+".1.gz", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+
+	registry := auditor.NewRegistry()
+	registry.SetOffset(compressedFileDone)
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), registry, 20*time.Millisecond, false)
+	scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path, ReadCompressed: true}))
+	defer scanner.cleanup()
+
+	assert.Equal(t, 0, len(scanner.compressedReaders))
+	assert.Equal(t, 0, len(scanner.compressedFiles))
+}
+
+func TestScannerReadsTheCompressedFilesOnceStable(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/app.log", testDir)
+	writeCompressedFile(t, path+".1.gz", "one\n")
+	writeCompressedFile(t, path+".2.gz", "two\n")
+	// the first file has been compressed too long ago for its completion to be registered
+	assert.Nil(t, os.Chtimes(path+".2.gz", time.Now().Add(-24*time.Hour), time.Now().Add(-24*time.Hour)))
+
+	pipelineProvider := mock.NewMockProvider()
+	scanner := NewScanner(config.NewLogSources(), 2, pipelineProvider, auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.started = time.Now().Add(time.Second)
+	scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path, ReadCompressed: true}))
+	defer scanner.cleanup()
+
+	// the second file is still being compressed
+	assert.Equal(t, 0, len(scanner.compressedReaders))
+	assert.Equal(t, 1, len(scanner.compressedFiles))
+
+	scanner.compressedStableDelay = 0
+	scanner.scan()
+	assert.Equal(t, "one", string((<-pipelineProvider.NextPipelineChan()).Content))
+	assert.Equal(t, 1, len(scanner.compressedReaders))
+	assert.Equal(t, 0, len(scanner.compressedFiles))
+}
+
+func TestScannerDoesNotReadTheFilesCompressedAfterItStarted(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "log-scanner-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := fmt.Sprintf("%s/app.log", testDir)
+	scanner := NewScanner(config.NewLogSources(), 2, mock.NewMockProvider(), auditor.NewRegistry(), 20*time.Millisecond, false)
+	scanner.started = time.Now().Add(-time.Minute)
+	// the file has been tailed before being compressed
+	writeCompressedFile(t, path+".1.gz", "one\n")
+	scanner.addSource(config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path, ReadCompressed: true}))
+	defer scanner.cleanup()
+
+	assert.Equal(t, 0, len(scanner.compressedFiles))
+	assert.Equal(t, 0, len(scanner.compressedReaders))
+}
@@ -10,7 +10,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,21 +28,64 @@ const DefaultSleepDuration = 1 * time.Second
 
 const defaultCloseTimeout = 60 * time.Second
 
+// defaultReadBufferSize is the number of bytes read from the file at most at once by default.
+const defaultReadBufferSize = 4096
+
 // Tailer tails one file and sends messages to an output channel
 type Tailer struct {
 	path     string
 	fullpath string
 	file     *os.File
 	tags     []string
+	// fileID identifies the file whatever its path, it is registered with the offsets
+	// to detect the rotations that happened while the agent was not running.
+	fileID string
+	// target is the file path points to when path is a symlink followed on retargets, path itself otherwise.
+	target string
 
 	readOffset    int64
 	decodedOffset int64
+	// lastActivity is the time in nanoseconds the tailer started or last read data from its file.
+	lastActivity int64
+
+	// collectionLag is the number of bytes left to read in file after the last read,
+	// it is only computed when trackCollectionLag is set as it costs a stat per read.
+	trackCollectionLag bool
+	collectionLag      int64
 
 	outputChan chan *message.Message
 	decoder    *decoder.Decoder
 	source     *config.LogSource
 
+	// holdOnRotation is set when the messages can span multiple lines,
+	// the pending content is then held at the end of a rotated file to be carried over to the new file.
+	holdOnRotation bool
+	holding        bool
+
+	// eofFlushTimeout is the time after which the last line of the file is sent
+	// when it is not terminated by a line feed and the file does not grow anymore, 0 means never.
+	eofFlushTimeout time.Duration
+	eofSince        time.Time
+	eofFlushed      bool
+
 	sleepDuration time.Duration
+	// readBufferSize is the number of bytes read from the file at most at once,
+	// a buffer of this size is allocated per read.
+	readBufferSize int
+	// readers reads the file when set, instead of a goroutine dedicated to the tailer.
+	readers   *readerPool
+	readTimer *time.Timer
+
+	// previous is the tailer of the file rotated before this one was created,
+	// this tailer does not read its file until previous reached the end of the rotated file
+	// so that the logs are collected in order.
+	previous  *Tailer
+	drained   chan struct{}
+	drainOnce sync.Once
+	// drainMarker is sent by the decoder after the lines read before the end of the rotated file,
+	// the tailer is drained once forwardMessages receives it, the lines are then in the pipeline.
+	drainMarker *message.Message
+	draining    bool
 
 	closeTimeout  time.Duration
 	shouldStop    int32
@@ -59,16 +103,30 @@ func NewTailer(outputChan chan *message.Message, source *config.LogSource, path
 		parser = logParser.NoopParser
 	}
 	return &Tailer{
-		path:          path,
-		outputChan:    outputChan,
-		decoder:       decoder.InitializeDecoder(source, parser),
-		source:        source,
-		readOffset:    0,
-		sleepDuration: sleepDuration,
-		closeTimeout:  defaultCloseTimeout,
-		stop:          make(chan struct{}, 1),
-		done:          make(chan struct{}, 1),
+		path:           path,
+		outputChan:     outputChan,
+		decoder:        decoder.InitializeDecoder(source, parser),
+		source:         source,
+		holdOnRotation: hasMultiLineRule(source),
+		readOffset:     0,
+		sleepDuration:  sleepDuration,
+		readBufferSize: defaultReadBufferSize,
+		closeTimeout:   defaultCloseTimeout,
+		drained:        make(chan struct{}),
+		drainMarker:    &message.Message{},
+		stop:           make(chan struct{}, 1),
+		done:           make(chan struct{}, 1),
+	}
+}
+
+// hasMultiLineRule returns true if the source aggregates multiple lines into one message.
+func hasMultiLineRule(source *config.LogSource) bool {
+	for _, rule := range source.Config.ProcessingRules {
+		if rule.Type == config.MultiLine {
+			return true
+		}
 	}
+	return false
 }
 
 // Identifier returns a string that uniquely identifies a source
@@ -88,7 +146,11 @@ func (t *Tailer) Start(offset int64, whence int) error {
 
 	go t.forwardMessages()
 	t.decoder.Start()
-	go t.readForever()
+	if t.readers != nil {
+		t.readers.add(t)
+	} else {
+		go t.readForever()
+	}
 
 	return nil
 }
@@ -102,6 +164,7 @@ func (t *Tailer) setup(offset int64, whence int) error {
 
 	// adds metadata to enable users to filter logs by filename
 	t.tags = []string{fmt.Sprintf("filename:%s", filepath.Base(t.path))}
+	t.tags = append(t.tags, pathTags(t.source.Config.PathTagsReg, fullpath)...)
 
 	log.Info("Opening ", t.path)
 	f, err := openFile(fullpath)
@@ -110,6 +173,12 @@ func (t *Tailer) setup(offset int64, whence int) error {
 	}
 
 	t.file = f
+	t.fileID = fileID(f)
+	if t.source.Config.FollowSymlinkRetarget {
+		t.target = symlinkTarget(t.path)
+	}
+	atomic.StoreInt64(&t.lastActivity, time.Now().UnixNano())
+	t.decoder.DetectByteOrder(f)
 	ret, _ := f.Seek(offset, whence)
 	t.readOffset = ret
 	t.decodedOffset = ret
@@ -117,6 +186,25 @@ func (t *Tailer) setup(offset int64, whence int) error {
 	return nil
 }
 
+// pathTags returns the tags made of the named groups captured by reg in path, none if reg is nil or does not match,
+// the groups which captured nothing are skipped.
+func pathTags(reg *regexp.Regexp, path string) []string {
+	if reg == nil {
+		return nil
+	}
+	matches := reg.FindStringSubmatch(path)
+	if matches == nil {
+		return nil
+	}
+	var tags []string
+	for i, name := range reg.SubexpNames() {
+		if name != "" && matches[i] != "" {
+			tags = append(tags, name+":"+matches[i])
+		}
+	}
+	return tags
+}
+
 // readForever lets the tailer tail the content of a file
 // until it is closed or the tailer is stopped.
 func (t *Tailer) readForever() {
@@ -128,25 +216,108 @@ func (t *Tailer) readForever() {
 			return
 		default:
 			// keep reading data from file
-			inBuf := make([]byte, 4096)
-			n, err := t.file.Read(inBuf)
-			if err != nil && err != io.EOF {
-				// an unexpected error occurred, stop the tailor
-				t.source.Status.Error(err)
-				log.Error("Unexpected error occurred while reading file: ", err)
+			n, err := t.read()
+			if err != nil {
 				return
 			}
 			if n == 0 {
 				// wait for new data to come
 				t.wait()
-				continue
 			}
-			t.decoder.InputChan <- decoder.NewInput(inBuf[:n])
-			t.incrementReadOffset(n)
 		}
 	}
 }
 
+// read reads the next chunk of the file and sends it to the decoder,
+// returns the number of bytes read, which is 0 at the end of the file,
+// or an error if the tailer must stop.
+func (t *Tailer) read() (int, error) {
+	if t.previous != nil {
+		select {
+		case <-t.previous.drained:
+			t.previous = nil
+		default:
+			// the rotated file is still being read
+			return 0, nil
+		}
+	}
+	// the rotation must be known before reading, the bytes written to the rotated file
+	// after it is read to the end and before the rotation is reported are read next
+	rotated := atomic.LoadInt32(&t.didFileRotate) != 0
+	inBuf := make([]byte, t.readBufferSize)
+	n, err := t.file.Read(inBuf)
+	if err != nil && err != io.EOF {
+		// an unexpected error occurred, stop the tailor
+		t.source.Status.Error(err)
+		log.Error("Unexpected error occurred while reading file: ", err)
+		return 0, err
+	}
+	if n == 0 {
+		if rotated && !t.draining {
+			t.draining = true
+			t.decoder.Sync(func() {
+				t.decoder.OutputChan <- t.drainMarker
+			})
+		}
+		if t.holdOnRotation && !t.holding {
+			t.holdPendingContentIfRotated()
+		}
+		if t.eofFlushTimeout > 0 {
+			t.flushPartialLineAfterTimeout()
+		}
+		return 0, nil
+	}
+	t.eofSince = time.Time{}
+	t.eofFlushed = false
+	atomic.StoreInt64(&t.lastActivity, time.Now().UnixNano())
+	t.incrementReadOffset(n)
+	if t.trackCollectionLag {
+		// the lag must be known before the content is decoded to add it to the messages
+		t.updateCollectionLag()
+	}
+	t.decoder.InputChan <- decoder.NewInput(inBuf[:n])
+	return n, nil
+}
+
+// flushPartialLineAfterTimeout lets the decoder send the last line of the file
+// once the end of the file has been reached for eofFlushTimeout.
+func (t *Tailer) flushPartialLineAfterTimeout() {
+	switch {
+	case t.eofFlushed:
+		// the line has already been sent, wait for new data
+	case t.eofSince.IsZero():
+		t.eofSince = time.Now()
+	case time.Since(t.eofSince) >= t.eofFlushTimeout:
+		t.decoder.FlushPartialLine()
+		t.eofFlushed = true
+	}
+}
+
+// holdPendingContentIfRotated prevents the decoder from flushing its pending content
+// when the end of the file is reached after a rotation,
+// the content may continue at the beginning of the new file.
+func (t *Tailer) holdPendingContentIfRotated() {
+	didRotate, err := DidRotate(t.file, t.GetReadOffset())
+	if os.IsNotExist(err) {
+		// the file has been moved or removed and may not have been recreated yet
+		didRotate = true
+	} else if err != nil {
+		return
+	}
+	if !didRotate {
+		return
+	}
+	t.holding = true
+	t.decoder.HoldPendingContent()
+}
+
+// markDrained lets the tailer of the new file start reading once the lines of the rotated file are forwarded.
+func (t *Tailer) markDrained() {
+	t.drainOnce.Do(func() {
+		close(t.drained)
+	})
+}
+
 // StartFromBeginning lets the tailer start tailing its file
 // from the beginning
 func (t *Tailer) StartFromBeginning() error {
@@ -170,6 +341,17 @@ func (t *Tailer) StopAfterFileRotation() {
 	t.source.RemoveInput(t.path)
 }
 
+// StopAtEndOfFile prepares the tailer to stop once it reached the end of its file,
+// the tailer following it starts reading once this one is drained.
+func (t *Tailer) StopAtEndOfFile() {
+	atomic.StoreInt32(&t.didFileRotate, 1)
+	go func() {
+		<-t.drained
+		t.stop <- struct{}{}
+	}()
+	t.source.RemoveInput(t.path)
+}
+
 // startStopTimer initialises and starts a timer to stop the tailor after the timeout
 func (t *Tailer) startStopTimer() {
 	stopTimer := time.NewTimer(t.closeTimeout)
@@ -189,9 +371,14 @@ func (t *Tailer) forwardMessages() {
 	defer func() {
 		// the decoder has successfully been flushed
 		atomic.StoreInt32(&t.shouldStop, 1)
+		t.markDrained()
 		t.done <- struct{}{}
 	}()
 	for output := range t.decoder.OutputChan {
+		if output == t.drainMarker {
+			t.markDrained()
+			continue
+		}
 		offset := t.decodedOffset + int64(output.RawDataLen)
 		identifier := t.Identifier()
 		if !t.shouldTrackOffset() {
@@ -201,8 +388,16 @@ func (t *Tailer) forwardMessages() {
 		t.decodedOffset = offset
 		origin := message.NewOrigin(t.source)
 		origin.Identifier = identifier
-		origin.Offset = strconv.FormatInt(offset, 10)
+		origin.Offset = formatOffset(offset, t.fileID)
 		origin.SetTags(t.tags)
+		if t.trackCollectionLag {
+			// the lag is an attribute rather than a tag as its value changes with almost every message,
+			// the content of the message is then wrapped as JSON with it by the processor
+			if output.Attributes == nil {
+				output.Attributes = make(map[string]interface{})
+			}
+			output.Attributes["collection_lag_bytes"] = atomic.LoadInt64(&t.collectionLag)
+		}
 		output.Origin = origin
 		t.outputChan <- output
 	}
@@ -217,6 +412,35 @@ func (t *Tailer) GetReadOffset() int64 {
 	return atomic.LoadInt64(&t.readOffset)
 }
 
+// updateCollectionLag records the number of bytes between the last byte read and the end of file.
+func (t *Tailer) updateCollectionLag() {
+	lag, err := t.GetCollectionLag()
+	if err != nil {
+		return
+	}
+	atomic.StoreInt64(&t.collectionLag, lag)
+}
+
+// getLastActivity returns the time the tailer started or last read data from its file.
+func (t *Tailer) getLastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&t.lastActivity))
+}
+
+// GetCollectionLag returns the number of bytes the tailer is behind the end of its file,
+// this requires a stat of the file.
+func (t *Tailer) GetCollectionLag() (int64, error) {
+	fi, err := t.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	lag := fi.Size() - t.GetReadOffset()
+	if lag < 0 {
+		// the file has been truncated
+		lag = 0
+	}
+	return lag, nil
+}
+
 // shouldTrackOffset returns whether the tailer should track the file offset or not
 func (t *Tailer) shouldTrackOffset() bool {
 	if atomic.LoadInt32(&t.didFileRotate) != 0 {
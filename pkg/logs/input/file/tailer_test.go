@@ -12,10 +12,12 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"strconv"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	"path/filepath"
@@ -123,6 +125,103 @@ func (suite *TailerTestSuite) TestTailFromEnd() {
 	suite.Equal(len(lines[0])+len(lines[1])+len(lines[2]), int(suite.tl.decodedOffset))
 }
 
+func (suite *TailerTestSuite) TestTailFlushesLastLineWithoutLineFeed() {
+	suite.tl.eofFlushTimeout = 30 * time.Millisecond
+
+	var msg *message.Message
+	var err error
+
+	_, err = suite.testFile.WriteString("hello world\nlast line")
+	suite.Nil(err)
+	suite.tl.StartFromBeginning()
+
+	msg = <-suite.outputChan
+	suite.Equal("hello world", string(msg.Content))
+	suite.Equal(len("hello world\n"), toInt(msg.Origin.Offset))
+
+	// the last line is sent once the file stopped growing for the timeout
+	msg = <-suite.outputChan
+	suite.Equal("last line", string(msg.Content))
+	suite.Equal(len("hello world\nlast line"), toInt(msg.Origin.Offset))
+
+	// the offsets remain accurate when the file grows again
+	_, err = suite.testFile.WriteString(" continued\ngood bye\n")
+	suite.Nil(err)
+	msg = <-suite.outputChan
+	suite.Equal("continued", string(msg.Content))
+	suite.Equal(len("hello world\nlast line continued\n"), toInt(msg.Origin.Offset))
+	msg = <-suite.outputChan
+	suite.Equal("good bye", string(msg.Content))
+	suite.Equal(len("hello world\nlast line continued\ngood bye\n"), toInt(msg.Origin.Offset))
+}
+
+func (suite *TailerTestSuite) TestTailReadsLinesSpanningMultipleReadBuffers() {
+	suite.tl.readBufferSize = 4
+
+	_, err := suite.testFile.WriteString("hello world\ngood bye\n")
+	suite.Nil(err)
+	suite.tl.StartFromBeginning()
+
+	msg := <-suite.outputChan
+	suite.Equal("hello world", string(msg.Content))
+	suite.Equal(len("hello world\n"), toInt(msg.Origin.Offset))
+	msg = <-suite.outputChan
+	suite.Equal("good bye", string(msg.Content))
+	suite.Equal(len("hello world\ngood bye\n"), toInt(msg.Origin.Offset))
+}
+
+func (suite *TailerTestSuite) TestTailTruncatesLinesLongerThanMaxLineLength() {
+	config.LogsAgent.Set("logs_config.max_line_length", 10)
+	defer config.LogsAgent.Set("logs_config.max_line_length", 0)
+	suite.tl = NewTailer(suite.outputChan, suite.source, suite.testPath, 10*time.Millisecond)
+	suite.tl.readBufferSize = 16
+
+	long := strings.Repeat("a", 100)
+	_, err := suite.testFile.WriteString("hello\n" + long + "\ngood bye\n")
+	suite.Nil(err)
+	suite.tl.StartFromBeginning()
+
+	msg := <-suite.outputChan
+	suite.Equal("hello", string(msg.Content))
+	// the rest of the line is discarded and the offset accounts for it
+	msg = <-suite.outputChan
+	suite.Equal("aaaaaaaaaa...TRUNCATED...", string(msg.Content))
+	suite.Equal(len("hello\n"+long+"\n"), toInt(msg.Origin.Offset))
+	msg = <-suite.outputChan
+	suite.Equal("good bye", string(msg.Content))
+	suite.Equal(len("hello\n"+long+"\ngood bye\n"), toInt(msg.Origin.Offset))
+}
+
+func (suite *TailerTestSuite) TestTailReadsTheByteOrderFromTheBeginningOfTheFile() {
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: suite.testPath, Encoding: config.UTF16Encoding})
+	suite.tl = NewTailer(suite.outputChan, source, suite.testPath, 10*time.Millisecond)
+
+	// the file starts with a big endian byte order mark
+	_, err := suite.testFile.Write([]byte{0xFE, 0xFF, 0, 'h', 0, 'i', 0, '\n'})
+	suite.Nil(err)
+	suite.tl.Start(0, io.SeekEnd)
+
+	_, err = suite.testFile.Write([]byte{0, 'y', 0, 'o', 0, '\n'})
+	suite.Nil(err)
+	msg := <-suite.outputChan
+	suite.Equal("yo", string(msg.Content))
+}
+
+func (suite *TailerTestSuite) TestTailKeepsLastLineWithoutLineFeedByDefault() {
+	_, err := suite.testFile.WriteString("hello world\nlast line")
+	suite.Nil(err)
+	suite.tl.StartFromBeginning()
+
+	msg := <-suite.outputChan
+	suite.Equal("hello world", string(msg.Content))
+
+	select {
+	case msg = <-suite.outputChan:
+		suite.Fail("the last line should not be sent", string(msg.Content))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 func (suite *TailerTestSuite) TestRecoverTailing() {
 	lines := []string{"hello world\n", "hello again\n", "good bye\n"}
 
@@ -173,12 +272,66 @@ func (suite *TailerTestSuite) TestOriginTagsWhenTailingFiles() {
 
 }
 
+func (suite *TailerTestSuite) TestOriginTagsFromPath() {
+	source := config.NewLogSource("", &config.LogsConfig{
+		Type:            config.FileType,
+		Path:            suite.testPath,
+		PathTagsPattern: `log-tailer-test-(?P<run>\d+)/(?P<name>[a-z]+)(?P<rotation>\.\d+)?\.log$`,
+	})
+	suite.Nil(source.Config.Compile())
+	suite.tl = NewTailer(suite.outputChan, source, suite.testPath, 10*time.Millisecond)
+	suite.tl.StartFromBeginning()
+
+	_, err := suite.testFile.WriteString("foo\n")
+	suite.Nil(err)
+
+	msg := <-suite.outputChan
+	run := strings.TrimPrefix(filepath.Base(suite.testDir), "log-tailer-test-")
+	suite.Equal([]string{"filename:tailer.log", "run:" + run, "name:tailer"}, msg.Origin.Tags())
+}
+
+func (suite *TailerTestSuite) TestCollectionLagAttribute() {
+	suite.tl.trackCollectionLag = true
+	suite.tl.StartFromBeginning()
+
+	_, err := suite.testFile.WriteString("foo\n")
+	suite.Nil(err)
+
+	msg := <-suite.outputChan
+	suite.Equal([]string{"filename:" + filepath.Base(suite.testFile.Name())}, msg.Origin.Tags())
+	suite.Equal(int64(0), msg.Attributes["collection_lag_bytes"])
+}
+
+func (suite *TailerTestSuite) TestGetCollectionLag() {
+	_, err := suite.testFile.WriteString("foo\nbar\n")
+	suite.Nil(err)
+
+	suite.tl.Start(4, io.SeekStart)
+
+	lag, err := suite.tl.GetCollectionLag()
+	suite.Nil(err)
+	suite.True(lag <= 4)
+
+	<-suite.outputChan
+	lag, err = suite.tl.GetCollectionLag()
+	suite.Nil(err)
+	suite.Equal(int64(0), lag)
+}
+
 func TestTailerTestSuite(t *testing.T) {
 	suite.Run(t, new(TailerTestSuite))
 }
 
+func TestPathTags(t *testing.T) {
+	reg := regexp.MustCompile(`^/logs/tenant-(?P<tenant>[^/]+)/`)
+	assert.Equal(t, []string{"tenant:42"}, pathTags(reg, "/logs/tenant-42/app.log"))
+	// the files which do not match get no tags
+	assert.Nil(t, pathTags(reg, "/logs/shared/app.log"))
+	assert.Nil(t, pathTags(nil, "/logs/tenant-42/app.log"))
+}
+
 func toInt(str string) int {
-	if value, err := strconv.ParseInt(str, 10, 64); err == nil {
+	if value, _, err := parseOffset(str); err == nil {
 		return int(value)
 	}
 	return 0
@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build systemd
+
+package journald
+
+import (
+	"fmt"
+
+	"github.com/coreos/go-systemd/sdjournal"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// CheckSource returns an error if the journal of source can not be opened
+// or if it holds no entry for one of the units included.
+func CheckSource(source *config.LogSource) error {
+	var journal *sdjournal.Journal
+	var err error
+	if source.Config.Path == "" {
+		journal, err = sdjournal.NewJournal()
+	} else {
+		journal, err = sdjournal.NewJournalFromDir(source.Config.Path)
+	}
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	for _, unit := range source.Config.IncludeUnits {
+		journal.FlushMatches()
+		match := sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT + "=" + unit
+		if err := journal.AddMatch(match); err != nil {
+			return fmt.Errorf("could not add filter %s: %s", match, err)
+		}
+		if err := journal.SeekHead(); err != nil {
+			return err
+		}
+		n, err := journal.Next()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("no entry found in the journal for unit %s", unit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !systemd
+
+package journald
+
+import (
+	"errors"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// CheckSource returns an error as the journal can not be read on no systemd environment.
+func CheckSource(source *config.LogSource) error {
+	return errors.New("journald is not supported by this build of the agent")
+}
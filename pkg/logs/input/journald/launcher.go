@@ -18,6 +18,7 @@ import (
 // Launcher is in charge of starting and stopping new journald tailers
 type Launcher struct {
 	sources          chan *config.LogSource
+	removedSources   chan *config.LogSource
 	pipelineProvider pipeline.Provider
 	registry         auditor.Registry
 	tailers          map[string]*Tailer
@@ -28,6 +29,7 @@ type Launcher struct {
 func NewLauncher(sources *config.LogSources, pipelineProvider pipeline.Provider, registry auditor.Registry) *Launcher {
 	return &Launcher{
 		sources:          sources.GetAddedForType(config.JournaldType),
+		removedSources:   sources.GetRemovedForType(config.JournaldType),
 		pipelineProvider: pipelineProvider,
 		registry:         registry,
 		tailers:          make(map[string]*Tailer),
@@ -40,7 +42,7 @@ func (l *Launcher) Start() {
 	go l.run()
 }
 
-// run starts new tailers.
+// run starts new tailers and stops the ones of the removed sources.
 func (l *Launcher) run() {
 	for {
 		select {
@@ -56,6 +58,12 @@ func (l *Launcher) run() {
 			} else {
 				l.tailers[identifier] = tailer
 			}
+		case source := <-l.removedSources:
+			identifier := source.Config.Path
+			if tailer, exists := l.tailers[identifier]; exists && tailer.source == source {
+				tailer.Stop()
+				delete(l.tailers, identifier)
+			}
 		case <-l.stop:
 			return
 		}
@@ -76,7 +84,7 @@ func (l *Launcher) Stop() {
 // setupTailer configures and starts a new tailer,
 // returns the tailer or an error.
 func (l *Launcher) setupTailer(source *config.LogSource) (*Tailer, error) {
-	tailer := NewTailer(source, l.pipelineProvider.NextPipelineChan())
+	tailer := NewTailer(source, l.pipelineProvider.PipelineChanFor(journaldIntegration+":"+source.Config.Path))
 	cursor := l.registry.GetOffset(tailer.Identifier())
 	err := tailer.Start(cursor)
 	if err != nil {
@@ -9,8 +9,10 @@ package journald
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/coreos/go-systemd/sdjournal"
@@ -28,7 +30,7 @@ type Tailer struct {
 	source     *config.LogSource
 	outputChan chan *message.Message
 	journal    *sdjournal.Journal
-	blacklist  map[string]bool
+	blacklist  map[string]map[string]bool
 	stop       chan struct{}
 	done       chan struct{}
 }
@@ -95,29 +97,72 @@ func (t *Tailer) setup() error {
 		}
 	}
 
-	t.blacklist = make(map[string]bool)
+	for _, match := range config.IncludeMatches {
+		// add filters to collect only the logs of which the fields hold the values defined in the configuration,
+		// the filters on the same field match any of their values while the filters on different fields must all match.
+		err := t.journal.AddMatch(match)
+		if err != nil {
+			return fmt.Errorf("could not add filter %s: %s", match, err)
+		}
+	}
+
+	t.blacklist = make(map[string]map[string]bool)
 	for _, unit := range config.ExcludeUnits {
 		// add filters to drop all the logs related to units to exclude.
-		t.blacklist[unit] = true
+		t.exclude(sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT, unit)
+	}
+	for _, match := range config.ExcludeMatches {
+		// add filters to drop all the logs of which a field holds a value to exclude.
+		if parts := strings.SplitN(match, "=", 2); len(parts) == 2 {
+			t.exclude(parts[0], parts[1])
+		}
 	}
 
 	return nil
 }
 
-// seek seeks to the cursor if it is not empty or the end of the journal,
+// exclude adds value to the values of field of the entries to drop.
+func (t *Tailer) exclude(field, value string) {
+	if _, exists := t.blacklist[field]; !exists {
+		t.blacklist[field] = make(map[string]bool)
+	}
+	t.blacklist[field][value] = true
+}
+
+// errCursorNotFound is returned when the entry of a cursor no longer exists in the journal.
+var errCursorNotFound = errors.New("the entry of the cursor no longer exists")
+
+// seek seeks to the entry following the cursor if it is not empty or the end of the journal,
+// it falls back to the end of the journal if the cursor is invalid or too old to still be in the journal,
 // returns an error if the operation failed.
 func (t *Tailer) seek(cursor string) error {
-	if cursor != "" {
-		err := t.journal.SeekCursor(cursor)
-		if err != nil {
-			return err
-		}
-		// must skip one entry since the cursor points to the last committed one.
-		_, err = t.journal.NextSkip(1)
-		return err
-	} else {
+	if cursor == "" {
 		return t.journal.SeekTail()
 	}
+	if err := t.seekCursor(cursor); err != nil {
+		log.Warnf("Could not resume tailing journal %s from cursor %s, tailing from its end: %v", t.journalPath(), cursor, err)
+		return t.journal.SeekTail()
+	}
+	return nil
+}
+
+// seekCursor moves to the entry of the cursor, the last one committed,
+// returns an error if the cursor is invalid or its entry no longer exists.
+func (t *Tailer) seekCursor(cursor string) error {
+	err := t.journal.SeekCursor(cursor)
+	if err != nil {
+		return err
+	}
+	// the journal moves to the closest entry when the one of the cursor does not exist.
+	_, err = t.journal.Next()
+	if err != nil {
+		return err
+	}
+	err = t.journal.TestCursor(cursor)
+	if err == sdjournal.ErrNoTestCursor {
+		return errCursorNotFound
+	}
+	return err
 }
 
 // tail tails the journal until a message stop is received.
@@ -160,13 +205,11 @@ func (t *Tailer) tail() {
 // shouldDrop returns true if the entry should be dropped,
 // returns false otherwise.
 func (t *Tailer) shouldDrop(entry *sdjournal.JournalEntry) bool {
-	unit, exists := entry.Fields[sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT]
-	if !exists {
-		return false
-	}
-	if _, blacklisted := t.blacklist[unit]; blacklisted {
-		// drop the entry
-		return true
+	for field, values := range t.blacklist {
+		if value, exists := entry.Fields[field]; exists && values[value] {
+			// drop the entry
+			return true
+		}
 	}
 	return false
 }
@@ -60,6 +60,36 @@ func TestShouldDropEntry(t *testing.T) {
 		}))
 }
 
+func TestShouldDropEntryWithExcludedMatches(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{ExcludeUnits: []string{"foo"}, ExcludeMatches: []string{"_COMM=bar", "PRIORITY=7"}})
+	tailer := NewTailer(source, nil)
+	err := tailer.setup()
+	assert.Nil(t, err)
+
+	assert.True(t, tailer.shouldDrop(
+		&sdjournal.JournalEntry{
+			Fields: map[string]string{
+				sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT: "foo",
+			},
+		}))
+
+	assert.True(t, tailer.shouldDrop(
+		&sdjournal.JournalEntry{
+			Fields: map[string]string{
+				sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT: "boo",
+				sdjournal.SD_JOURNAL_FIELD_PRIORITY:     "7",
+			},
+		}))
+
+	assert.False(t, tailer.shouldDrop(
+		&sdjournal.JournalEntry{
+			Fields: map[string]string{
+				sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT: "bar",
+				sdjournal.SD_JOURNAL_FIELD_COMM:         "foo",
+			},
+		}))
+}
+
 func TestApplicationName(t *testing.T) {
 	source := config.NewLogSource("", &config.LogsConfig{})
 	tailer := NewTailer(source, nil)
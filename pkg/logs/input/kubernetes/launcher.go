@@ -10,6 +10,7 @@ package kubernetes
 import (
 	"fmt"
 
+	"github.com/DataDog/datadog-agent/pkg/secrets"
 	"github.com/DataDog/datadog-agent/pkg/tagger"
 	"github.com/DataDog/datadog-agent/pkg/util/kubernetes/kubelet"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -123,7 +124,7 @@ func (l *Launcher) addSource(svc *service.Service) {
 		log.Warn(err)
 		return
 	}
-	source, err := l.getSource(pod, container)
+	source, err := getSource(pod, container)
 	if err != nil {
 		log.Warnf("Invalid configuration for pod %v, container %v: %v", pod.Metadata.Name, container.Name, err)
 		return
@@ -157,14 +158,17 @@ func (l *Launcher) removeSource(service *service.Service) {
 const kubernetesIntegration = "kubernetes"
 
 // getSource returns a new source for the container in pod.
-func (l *Launcher) getSource(pod *kubelet.Pod, container kubelet.ContainerStatus) (*config.LogSource, error) {
+func getSource(pod *kubelet.Pod, container kubelet.ContainerStatus) (*config.LogSource, error) {
 	var cfg *config.LogsConfig
-	if annotation := l.getAnnotation(pod, container); annotation != "" {
+	if annotation := getAnnotation(pod, container); annotation != "" {
 		configs, err := config.ParseJSON([]byte(annotation))
 		if err != nil || len(configs) == 0 {
 			return nil, fmt.Errorf("could not parse kubernetes annotation %v", annotation)
 		}
 		cfg = configs[0]
+		if err := cfg.Interpolate(secrets.DecryptHandle); err != nil {
+			return nil, fmt.Errorf("invalid kubernetes annotation: %v", err)
+		}
 	} else {
 		cfg = &config.LogsConfig{
 			Source:  kubernetesIntegration,
@@ -172,9 +176,9 @@ func (l *Launcher) getSource(pod *kubelet.Pod, container kubelet.ContainerStatus
 		}
 	}
 	cfg.Type = config.FileType
-	cfg.Path = l.getPath(pod, container)
+	cfg.Path = getPath(pod, container)
 	cfg.Identifier = container.ID
-	cfg.Tags = append(cfg.Tags, l.getTags(container)...)
+	cfg.Tags = append(cfg.Tags, getTags(container)...)
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid kubernetes annotation: %v", err)
 	}
@@ -182,7 +186,7 @@ func (l *Launcher) getSource(pod *kubelet.Pod, container kubelet.ContainerStatus
 		return nil, fmt.Errorf("could not compile kubernetes annotation: %v", err)
 	}
 
-	return config.NewLogSource(l.getSourceName(pod, container), cfg), nil
+	return config.NewLogSource(getSourceName(pod, container), cfg), nil
 }
 
 // configPath refers to the configuration that can be passed over a pod annotation,
@@ -194,14 +198,14 @@ const (
 )
 
 // getConfigPath returns the path of the logs-config annotation for container.
-func (l *Launcher) getConfigPath(container kubelet.ContainerStatus) string {
+func getConfigPath(container kubelet.ContainerStatus) string {
 	return fmt.Sprintf("%s/%s.%s", configPathPrefix, container.Name, configPathSuffix)
 }
 
 // getAnnotation returns the logs-config annotation for container if present.
 // FIXME: Reuse the annotation logic from AD
-func (l *Launcher) getAnnotation(pod *kubelet.Pod, container kubelet.ContainerStatus) string {
-	configPath := l.getConfigPath(container)
+func getAnnotation(pod *kubelet.Pod, container kubelet.ContainerStatus) string {
+	configPath := getConfigPath(container)
 	if annotation, exists := pod.Metadata.Annotations[configPath]; exists {
 		return annotation
 	}
@@ -209,17 +213,17 @@ func (l *Launcher) getAnnotation(pod *kubelet.Pod, container kubelet.ContainerSt
 }
 
 // getSourceName returns the source name of the container to tail.
-func (l *Launcher) getSourceName(pod *kubelet.Pod, container kubelet.ContainerStatus) string {
+func getSourceName(pod *kubelet.Pod, container kubelet.ContainerStatus) string {
 	return fmt.Sprintf("%s/%s/%s", pod.Metadata.Namespace, pod.Metadata.Name, container.Name)
 }
 
 // getPath returns the path where all the logs of the container of the pod are stored.
-func (l *Launcher) getPath(pod *kubelet.Pod, container kubelet.ContainerStatus) string {
+func getPath(pod *kubelet.Pod, container kubelet.ContainerStatus) string {
 	return fmt.Sprintf("%s/%s/%s/*.log", podsDirectoryPath, pod.Metadata.UID, container.Name)
 }
 
 // getTags returns all the tags of the container
-func (l *Launcher) getTags(container kubelet.ContainerStatus) []string {
+func getTags(container kubelet.ContainerStatus) []string {
 	tags, _ := tagger.Tag(container.ID, true)
 	return tags
 }
@@ -18,7 +18,6 @@ import (
 )
 
 func TestGetSource(t *testing.T) {
-	launcher := &Launcher{}
 	container := kubelet.ContainerStatus{
 		Name:  "foo",
 		Image: "bar",
@@ -35,7 +34,7 @@ func TestGetSource(t *testing.T) {
 		},
 	}
 
-	source, err := launcher.getSource(pod, container)
+	source, err := getSource(pod, container)
 	assert.Nil(t, err)
 	assert.Equal(t, config.FileType, source.Config.Type)
 	assert.Equal(t, "buu/fuz/foo", source.Name)
@@ -46,7 +45,6 @@ func TestGetSource(t *testing.T) {
 }
 
 func TestGetSourceShouldBeOverridenByAutoDiscoveryAnnotation(t *testing.T) {
-	launcher := &Launcher{}
 	container := kubelet.ContainerStatus{
 		Name:  "foo",
 		Image: "bar",
@@ -66,7 +64,7 @@ func TestGetSourceShouldBeOverridenByAutoDiscoveryAnnotation(t *testing.T) {
 		},
 	}
 
-	source, err := launcher.getSource(pod, container)
+	source, err := getSource(pod, container)
 	assert.Nil(t, err)
 	assert.Equal(t, config.FileType, source.Config.Type)
 	assert.Equal(t, "buu/fuz/foo", source.Name)
@@ -78,7 +76,6 @@ func TestGetSourceShouldBeOverridenByAutoDiscoveryAnnotation(t *testing.T) {
 }
 
 func TestGetSourceShouldFailWithInvalidAutoDiscoveryAnnotation(t *testing.T) {
-	launcher := &Launcher{}
 	container := kubelet.ContainerStatus{
 		Name:  "foo",
 		Image: "bar",
@@ -98,13 +95,12 @@ func TestGetSourceShouldFailWithInvalidAutoDiscoveryAnnotation(t *testing.T) {
 		},
 	}
 
-	source, err := launcher.getSource(pod, container)
+	source, err := getSource(pod, container)
 	assert.NotNil(t, err)
 	assert.Nil(t, source)
 }
 
 func TestGetSourceAddContainerdParser(t *testing.T) {
-	launcher := &Launcher{}
 	container := kubelet.ContainerStatus{
 		Name:  "foo",
 		Image: "bar",
@@ -121,7 +117,7 @@ func TestGetSourceAddContainerdParser(t *testing.T) {
 		},
 	}
 
-	source, err := launcher.getSource(pod, container)
+	source, err := getSource(pod, container)
 	assert.Nil(t, err)
 	assert.Equal(t, config.FileType, source.Config.Type)
 }
@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build kubelet
+
+package kubernetes
+
+import (
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/tagger"
+	"github.com/DataDog/datadog-agent/pkg/util/kubernetes/kubelet"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// The period at which the kubelet is polled for new and deleted pods,
+// a container is considered deleted once it has not been listed for a period.
+const podPollPeriod = 15 * time.Second
+
+// podWatcher provides the pods running on the node.
+type podWatcher interface {
+	PullChanges() ([]*kubelet.Pod, error)
+	Expire() ([]string, error)
+}
+
+// PodProvider polls the kubelet for the pods running on the node and creates one logs-source
+// per container annotated with a logs-config, the source of a container is removed,
+// which stops its tailer, once its pod is deleted.
+type PodProvider struct {
+	sources            *config.LogSources
+	sourcesByContainer map[string]*config.LogSource
+	watcher            podWatcher
+	stop               chan struct{}
+}
+
+// NewPodProvider returns a new provider.
+func NewPodProvider(sources *config.LogSources) (*PodProvider, error) {
+	watcher, err := kubelet.NewPodWatcher(podPollPeriod)
+	if err != nil {
+		return nil, err
+	}
+	// initialize the tagger to collect container tags
+	if err := tagger.Init(); err != nil {
+		return nil, err
+	}
+	return newPodProvider(sources, watcher), nil
+}
+
+// newPodProvider returns a new provider getting the pods from watcher.
+func newPodProvider(sources *config.LogSources, watcher podWatcher) *PodProvider {
+	return &PodProvider{
+		sources:            sources,
+		sourcesByContainer: make(map[string]*config.LogSource),
+		watcher:            watcher,
+		stop:               make(chan struct{}),
+	}
+}
+
+// Start starts the provider.
+func (p *PodProvider) Start() {
+	log.Info("Starting Kubernetes pod provider")
+	go p.run()
+}
+
+// Stop stops the provider.
+func (p *PodProvider) Stop() {
+	log.Info("Stopping Kubernetes pod provider")
+	p.stop <- struct{}{}
+}
+
+// run polls the kubelet until the provider is stopped.
+func (p *PodProvider) run() {
+	ticker := time.NewTicker(podPollPeriod)
+	defer ticker.Stop()
+	p.poll()
+	for {
+		select {
+		case <-ticker.C:
+			p.poll()
+		case <-p.stop:
+			log.Info("Kubernetes pod provider stopped")
+			return
+		}
+	}
+}
+
+// poll adds the sources of the new containers and removes the ones of the deleted containers.
+func (p *PodProvider) poll() {
+	pods, err := p.watcher.PullChanges()
+	if err != nil {
+		log.Warnf("Could not list the pods: %v", err)
+		return
+	}
+	p.addPods(pods)
+	expired, err := p.watcher.Expire()
+	if err != nil {
+		log.Warnf("Could not expire the pods: %v", err)
+		return
+	}
+	p.removeContainers(expired)
+}
+
+// addPods creates a new source for each annotated container of pods not collected yet.
+func (p *PodProvider) addPods(pods []*kubelet.Pod) {
+	for _, pod := range pods {
+		for _, container := range pod.Status.Containers {
+			if container.ID == "" || getAnnotation(pod, container) == "" {
+				// the container is not running yet or its logs are not to be collected
+				continue
+			}
+			if _, exists := p.sourcesByContainer[container.ID]; exists {
+				continue
+			}
+			source, err := getSource(pod, container)
+			if err != nil {
+				log.Warnf("Invalid configuration for pod %v, container %v: %v", pod.Metadata.Name, container.Name, err)
+				continue
+			}
+			source.SetSourceType(getRuntime(container))
+			p.sourcesByContainer[container.ID] = source
+			p.sources.AddSource(source)
+		}
+	}
+}
+
+// removeContainers removes the sources of the containers with ids,
+// the ids which are not the ones of a container collected are ignored.
+func (p *PodProvider) removeContainers(ids []string) {
+	for _, id := range ids {
+		if source, exists := p.sourcesByContainer[id]; exists {
+			delete(p.sourcesByContainer, id)
+			p.sources.RemoveSource(source)
+		}
+	}
+}
+
+// getRuntime returns the runtime of the container from its id formatted as '<runtime>://<id>'.
+func getRuntime(container kubelet.ContainerStatus) string {
+	if strings.HasPrefix(container.ID, config.ContainerdType+"://") {
+		return config.ContainerdType
+	}
+	return config.DockerType
+}
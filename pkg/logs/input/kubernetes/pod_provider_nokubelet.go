@@ -0,0 +1,28 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !kubelet
+
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// PodProvider is not supported on no kubelet environment
+type PodProvider struct{}
+
+// NewPodProvider returns an error as the kubelet is not supported
+func NewPodProvider(sources *config.LogSources) (*PodProvider, error) {
+	return nil, fmt.Errorf("the kubelet is not supported on this build")
+}
+
+// Start does nothing
+func (p *PodProvider) Start() {}
+
+// Stop does nothing
+func (p *PodProvider) Stop() {}
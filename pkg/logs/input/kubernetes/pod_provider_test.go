@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build kubelet
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/util/kubernetes/kubelet"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// fakePodWatcher returns the pods and the expired ids set by the test.
+type fakePodWatcher struct {
+	pods    []*kubelet.Pod
+	expired []string
+}
+
+func (w *fakePodWatcher) PullChanges() ([]*kubelet.Pod, error) {
+	pods := w.pods
+	w.pods = nil
+	return pods, nil
+}
+
+func (w *fakePodWatcher) Expire() ([]string, error) {
+	expired := w.expired
+	w.expired = nil
+	return expired, nil
+}
+
+func newAnnotatedPod() *kubelet.Pod {
+	return &kubelet.Pod{
+		Metadata: kubelet.PodMetadata{
+			Name:      "fuz",
+			Namespace: "buu",
+			UID:       "baz",
+			Annotations: map[string]string{
+				"ad.datadoghq.com/foo.logs": `[{"source":"any_source","service":"any_service"}]`,
+			},
+		},
+		Status: kubelet.Status{
+			Containers: []kubelet.ContainerStatus{
+				{Name: "foo", ID: "containerd://foo"},
+				{Name: "bar", ID: "docker://bar"},
+			},
+		},
+	}
+}
+
+func TestPodProviderAddsTheSourcesOfTheAnnotatedContainers(t *testing.T) {
+	sources := config.NewLogSources()
+	watcher := &fakePodWatcher{pods: []*kubelet.Pod{newAnnotatedPod()}}
+	provider := newPodProvider(sources, watcher)
+
+	provider.poll()
+	assert.Equal(t, 1, len(sources.GetSources()))
+	source := sources.GetSources()[0]
+	assert.Equal(t, "buu/fuz/foo", source.Name)
+	assert.Equal(t, "/var/log/pods/baz/foo/*.log", source.Config.Path)
+	assert.Equal(t, "any_source", source.Config.Source)
+	assert.Equal(t, "any_service", source.Config.Service)
+	assert.Equal(t, config.ContainerdType, source.GetSourceType())
+
+	// the pods are listed again when one of their containers is new
+	watcher.pods = []*kubelet.Pod{newAnnotatedPod()}
+	provider.poll()
+	assert.Equal(t, 1, len(sources.GetSources()))
+}
+
+func TestPodProviderRemovesTheSourcesOfTheDeletedContainers(t *testing.T) {
+	sources := config.NewLogSources()
+	watcher := &fakePodWatcher{pods: []*kubelet.Pod{newAnnotatedPod()}}
+	provider := newPodProvider(sources, watcher)
+	provider.poll()
+	assert.Equal(t, 1, len(sources.GetSources()))
+
+	removed := sources.GetRemovedForType(config.FileType)
+	watcher.expired = []string{"kubernetes_pod://baz", "docker://bar", "containerd://foo"}
+	go provider.poll()
+	source := <-removed
+	assert.Equal(t, "buu/fuz/foo", source.Name)
+	assert.Equal(t, 0, len(sources.GetSources()))
+}
+
+func TestPodProviderSkipsTheContainersNotRunning(t *testing.T) {
+	sources := config.NewLogSources()
+	pod := newAnnotatedPod()
+	pod.Status.Containers[0].ID = ""
+	provider := newPodProvider(sources, &fakePodWatcher{pods: []*kubelet.Pod{pod}})
+
+	provider.poll()
+	assert.Equal(t, 0, len(sources.GetSources()))
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// An acker acknowledges to the sender the messages of a connection framed with the acked octet count framing.
+//
+// The sender writes the messages framed by an octet count as defined by RFC6587, for example '11 hello world',
+// and the agent writes back the number of messages of the connection handed to the pipeline so far,
+// in decimal followed by a line feed, for example '3\n' once the first three messages are in the pipeline.
+// The counts are cumulative so that the sender can release all the messages up to the last count received,
+// the messages received together are acknowledged at once. The sender retransmits the messages which were not
+// acknowledged when the connection is lost, on a restart of the agent for example, some messages may then be
+// received twice but none is lost before reaching the pipeline.
+type acker struct {
+	conn   net.Conn
+	frames *frameReader
+	count  uint64
+}
+
+// newAcker returns a new acker writing to conn the acknowledgements of the messages read from frames.
+func newAcker(conn net.Conn, frames *frameReader) *acker {
+	return &acker{
+		conn:   conn,
+		frames: frames,
+	}
+}
+
+// ack acknowledges the message handed to the pipeline along with the previous ones,
+// unless the next message has already been received to acknowledge them at once,
+// returns an error if the acknowledgement could not be written.
+func (a *acker) ack() error {
+	a.count++
+	if a.frames.reader.Buffered() > 0 {
+		return nil
+	}
+	a.conn.SetWriteDeadline(time.Now().Add(defaultTimeout))
+	_, err := a.conn.Write(append(strconv.AppendUint(nil, a.count, 10), '\n'))
+	return err
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func TestAckerAcknowledgesTheMessagesReceivedTogetherAtOnce(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	frames := newFrameReader(server, config.AckedOctetCountFraming, 100)
+	a := newAcker(server, frames)
+	acks := bufio.NewReader(client)
+
+	go client.Write([]byte("5 hello"))
+	frame, _, err := frames.next()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(frame))
+	go a.ack()
+	ack, err := acks.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n", ack)
+
+	go client.Write([]byte("5 world3 foo"))
+	frame, _, err = frames.next()
+	assert.Nil(t, err)
+	assert.Equal(t, "world", string(frame))
+	// the next message is already buffered
+	assert.Nil(t, a.ack())
+	frame, _, err = frames.next()
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", string(frame))
+	go a.ack()
+	ack, err = acks.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "3\n", ack)
+}
@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// CheckSource returns an error if the port or the socket of source can not be bound,
+// the port is released right away, it can not be bound while the agent listens on it.
+func CheckSource(source *config.LogSource) error {
+	switch source.Config.Type {
+	case config.TCPType:
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", source.Config.Port))
+		if err != nil {
+			return err
+		}
+		return listener.Close()
+	case config.UDPType:
+		conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", source.Config.Port))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case config.UnixType, config.UnixgramType:
+		// the socket is not created so that a socket in use is not replaced
+		path := source.Config.Path
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s already exists and is not a socket", path)
+		}
+		fi, err := os.Stat(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", filepath.Dir(path))
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func TestCheckSource(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "listener-check-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	file := fmt.Sprintf("%s/test.log", testDir)
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+
+	l, err := net.Listen("tcp", ":0")
+	assert.Nil(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+
+	// the port is in use
+	assert.NotNil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.TCPType, Port: port})))
+	l.Close()
+	assert.Nil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.TCPType, Port: port})))
+	assert.Nil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.UDPType, Port: port})))
+
+	// the socket is not created
+	socket := fmt.Sprintf("%s/test.sock", testDir)
+	assert.Nil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.UnixgramType, Path: socket})))
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.UnixType, Path: socket})))
+	assert.NotNil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.UnixgramType, Path: file})))
+	assert.NotNil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.UnixType, Path: file})))
+	assert.NotNil(t, CheckSource(config.NewLogSource("", &config.LogsConfig{Type: config.UnixgramType, Path: fmt.Sprintf("%s/missing/test.sock", testDir)})))
+}
@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// maxOctetCountDigits is the maximum number of digits of an octet count,
+// a longer prefix means that the stream is not framed as expected.
+const maxOctetCountDigits = 10
+
+// isFramed returns true when the messages of source are not delimited by line feeds,
+// with UDP each datagram is then one message.
+func isFramed(source *config.LogSource) bool {
+	return source.Config.Framing != "" && source.Config.Framing != config.LineFraming
+}
+
+// frameReader reads the messages of a stream framed by an octet count as defined by RFC6587,
+// for example '11 hello world', or by line feeds when the framing is detected for each message,
+// the acked octet count framing is read like the octet count one.
+// The messages longer than the maximum frame size are truncated and their remaining bytes are skipped
+// so that the next messages are read properly.
+type frameReader struct {
+	reader       *bufio.Reader
+	framing      string
+	maxFrameSize int
+}
+
+// newFrameReader returns a new frameReader reading from r.
+func newFrameReader(r io.Reader, framing string, maxFrameSize int) *frameReader {
+	return &frameReader{
+		reader:       bufio.NewReaderSize(r, maxFrameSize),
+		framing:      framing,
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+// next returns the next message of the stream and whether it was truncated.
+func (r *frameReader) next() ([]byte, bool, error) {
+	if r.framing == config.AutoFraming {
+		// an octet count starts with a non-zero digit whereas a syslog message starts with '<'
+		head, err := r.reader.Peek(1)
+		if err != nil {
+			return nil, false, err
+		}
+		if head[0] < '1' || head[0] > '9' {
+			return r.nextLine()
+		}
+	}
+	return r.nextOctetCounted()
+}
+
+// nextOctetCounted returns the next message prefixed with its length.
+func (r *frameReader) nextOctetCounted() ([]byte, bool, error) {
+	length := 0
+	for i := 0; ; i++ {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return nil, false, err
+		}
+		if b == ' ' && i > 0 {
+			break
+		}
+		if b < '0' || b > '9' || i == maxOctetCountDigits {
+			return nil, false, fmt.Errorf("invalid octet count framing")
+		}
+		length = length*10 + int(b-'0')
+	}
+	size := length
+	if size > r.maxFrameSize {
+		size = r.maxFrameSize
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, frame); err != nil {
+		return nil, false, err
+	}
+	if size < length {
+		if _, err := r.reader.Discard(length - size); err != nil {
+			return nil, false, err
+		}
+		return frame, true, nil
+	}
+	return frame, false, nil
+}
+
+// nextLine returns the next message terminated by a line feed,
+// the last message of the stream may not be terminated.
+func (r *frameReader) nextLine() ([]byte, bool, error) {
+	line, err := r.reader.ReadSlice('\n')
+	switch {
+	case err == bufio.ErrBufferFull:
+		frame := copyFrame(line)
+		for err == bufio.ErrBufferFull {
+			_, err = r.reader.ReadSlice('\n')
+		}
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+		return frame, true, nil
+	case err == io.EOF && len(line) > 0:
+		return copyFrame(line), false, nil
+	case err != nil:
+		return nil, false, err
+	default:
+		return copyFrame(line[:len(line)-1]), false, nil
+	}
+}
+
+// copyFrame returns a copy of frame, which is only valid until the next read.
+func copyFrame(frame []byte) []byte {
+	return append([]byte(nil), frame...)
+}
+
+// truncateFrame marks frame as truncated and counts it for source.
+func truncateFrame(frame []byte, source *config.LogSource) []byte {
+	metrics.LogsTruncated.Add(1)
+	source.Throughput.CountTruncated()
+	return append(frame, decoder.TRUNCATED...)
+}
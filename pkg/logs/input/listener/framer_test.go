@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func TestFrameReaderReadsOctetCountedMessages(t *testing.T) {
+	frames := newFrameReader(strings.NewReader("11 hello world11 <13>foo\nbar"), config.OctetCountFraming, 100)
+
+	frame, truncated, err := frames.next()
+	assert.Nil(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, "hello world", string(frame))
+
+	// the line feeds are part of the message
+	frame, truncated, err = frames.next()
+	assert.Nil(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, "<13>foo\nbar", string(frame))
+
+	_, _, err = frames.next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFrameReaderTruncatesOversizedMessages(t *testing.T) {
+	frames := newFrameReader(strings.NewReader("30 "+strings.Repeat("a", 30)+"3 foo"), config.OctetCountFraming, 20)
+
+	frame, truncated, err := frames.next()
+	assert.Nil(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, strings.Repeat("a", 20), string(frame))
+
+	// the stream is still in sync
+	frame, truncated, err = frames.next()
+	assert.Nil(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, "foo", string(frame))
+}
+
+func TestFrameReaderFailsOnInvalidOctetCount(t *testing.T) {
+	frames := newFrameReader(strings.NewReader("<13>hello\n"), config.OctetCountFraming, 100)
+	_, _, err := frames.next()
+	assert.NotNil(t, err)
+
+	frames = newFrameReader(strings.NewReader("12345678901 hello"), config.OctetCountFraming, 100)
+	_, _, err = frames.next()
+	assert.NotNil(t, err)
+}
+
+func TestFrameReaderDetectsTheFramingOfEachMessage(t *testing.T) {
+	frames := newFrameReader(strings.NewReader("<13>hello\n5 world<14>"+strings.Repeat("a", 30)+"\n<15>bye"), config.AutoFraming, 20)
+
+	frame, truncated, err := frames.next()
+	assert.Nil(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, "<13>hello", string(frame))
+
+	frame, truncated, err = frames.next()
+	assert.Nil(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, "world", string(frame))
+
+	frame, truncated, err = frames.next()
+	assert.Nil(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, "<14>"+strings.Repeat("a", 16), string(frame))
+
+	// the last message is not terminated
+	frame, truncated, err = frames.next()
+	assert.Nil(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, "<15>bye", string(frame))
+
+	_, _, err = frames.next()
+	assert.Equal(t, io.EOF, err)
+}
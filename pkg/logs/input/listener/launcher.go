@@ -12,23 +12,37 @@ import (
 )
 
 // Launcher summons different protocol specific listeners based on configuration
+// and stops them when their source is removed.
 type Launcher struct {
-	pipelineProvider pipeline.Provider
-	frameSize        int
-	tcpSources       chan *config.LogSource
-	udpSources       chan *config.LogSource
-	listeners        []restart.Restartable
-	stop             chan struct{}
+	pipelineProvider       pipeline.Provider
+	frameSize              int
+	tcpSources             chan *config.LogSource
+	udpSources             chan *config.LogSource
+	unixSources            chan *config.LogSource
+	unixgramSources        chan *config.LogSource
+	removedTCPSources      chan *config.LogSource
+	removedUDPSources      chan *config.LogSource
+	removedUnixSources     chan *config.LogSource
+	removedUnixgramSources chan *config.LogSource
+	listeners              map[*config.LogSource]restart.Restartable
+	stop                   chan struct{}
 }
 
 // NewLauncher returns an initialized Launcher
 func NewLauncher(sources *config.LogSources, frameSize int, pipelineProvider pipeline.Provider) *Launcher {
 	return &Launcher{
-		pipelineProvider: pipelineProvider,
-		frameSize:        frameSize,
-		tcpSources:       sources.GetAddedForType(config.TCPType),
-		udpSources:       sources.GetAddedForType(config.UDPType),
-		stop:             make(chan struct{}),
+		pipelineProvider:       pipelineProvider,
+		frameSize:              frameSize,
+		tcpSources:             sources.GetAddedForType(config.TCPType),
+		udpSources:             sources.GetAddedForType(config.UDPType),
+		unixSources:            sources.GetAddedForType(config.UnixType),
+		unixgramSources:        sources.GetAddedForType(config.UnixgramType),
+		removedTCPSources:      sources.GetRemovedForType(config.TCPType),
+		removedUDPSources:      sources.GetRemovedForType(config.UDPType),
+		removedUnixSources:     sources.GetRemovedForType(config.UnixType),
+		removedUnixgramSources: sources.GetRemovedForType(config.UnixgramType),
+		listeners:              make(map[*config.LogSource]restart.Restartable),
+		stop:                   make(chan struct{}),
 	}
 }
 
@@ -37,30 +51,64 @@ func (l *Launcher) Start() {
 	go l.run()
 }
 
-// run starts new network listeners.
+// run starts new network listeners and stops the ones of the removed sources.
 func (l *Launcher) run() {
 	for {
 		select {
 		case source := <-l.tcpSources:
-			listener := NewTCPListener(l.pipelineProvider, source, l.frameSize)
-			listener.Start()
-			l.listeners = append(l.listeners, listener)
+			l.startListener(source, NewTCPListener(l.pipelineProvider, source, l.frameSize))
 		case source := <-l.udpSources:
-			listener := NewUDPListener(l.pipelineProvider, source, l.frameSize)
-			listener.Start()
-			l.listeners = append(l.listeners, listener)
+			l.startListener(source, NewUDPListener(l.pipelineProvider, source, l.frameSize))
+		case source := <-l.unixSources:
+			l.startListener(source, l.newUnixListener(source))
+		case source := <-l.unixgramSources:
+			l.startListener(source, NewUnixgramListener(l.pipelineProvider, source, l.frameSize))
+		case source := <-l.removedTCPSources:
+			l.stopListener(source)
+		case source := <-l.removedUDPSources:
+			l.stopListener(source)
+		case source := <-l.removedUnixSources:
+			l.stopListener(source)
+		case source := <-l.removedUnixgramSources:
+			l.stopListener(source)
 		case <-l.stop:
 			return
 		}
 	}
 }
 
+// newUnixListener returns the listener of the socket type of source.
+func (l *Launcher) newUnixListener(source *config.LogSource) restart.Restartable {
+	if source.Config.SocketType == config.DatagramSocketType {
+		return NewUnixgramListener(l.pipelineProvider, source, l.frameSize)
+	}
+	return NewUnixListener(l.pipelineProvider, source, l.frameSize)
+}
+
+// startListener starts listener and keeps track of it.
+func (l *Launcher) startListener(source *config.LogSource, listener restart.Restartable) {
+	listener.Start()
+	l.listeners[source] = listener
+}
+
+// stopListener stops the listener of source, the messages already received are flushed to the pipeline.
+// The listener is stopped synchronously so that a modified source added next can bind the same address.
+func (l *Launcher) stopListener(source *config.LogSource) {
+	listener, exists := l.listeners[source]
+	if !exists {
+		return
+	}
+	listener.Stop()
+	delete(l.listeners, source)
+}
+
 // Stop stops all listeners
 func (l *Launcher) Stop() {
 	l.stop <- struct{}{}
 	stopper := restart.NewParallelStopper()
-	for _, l := range l.listeners {
-		stopper.Add(l)
+	for source, listener := range l.listeners {
+		stopper.Add(listener)
+		delete(l.listeners, source)
 	}
 	stopper.Stop()
 }
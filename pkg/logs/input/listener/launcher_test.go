@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline/mock"
+)
+
+func TestLauncherStopsTheListenersOfRemovedSources(t *testing.T) {
+	sources := config.NewLogSources()
+	launcher := NewLauncher(sources, 9000, mock.NewMockProvider())
+	launcher.Start()
+	defer launcher.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{Type: config.TCPType, Port: tcpTestPort})
+	sources.AddSource(source)
+	assert.True(t, waitForSuccess(source))
+
+	sources.RemoveSource(source)
+	assert.True(t, waitForPortFree(tcpTestPort))
+
+	// the port is free to be bound by a new source
+	source = config.NewLogSource("", &config.LogsConfig{Type: config.TCPType, Port: tcpTestPort})
+	sources.AddSource(source)
+	assert.True(t, waitForSuccess(source))
+}
+
+// waitForSuccess returns true once the listener of source is started, false if it timed out,
+// the port is not probed while it is being bound as the probe could prevent the listener from binding it.
+func waitForSuccess(source *config.LogSource) bool {
+	for i := 0; i < 100; i++ {
+		if source.Status.IsSuccess() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+// waitForPortFree returns true once the port is free, false if it timed out.
+func waitForPortFree(port int) bool {
+	for i := 0; i < 100; i++ {
+		// the port is not probed with connections as they would be handled by tailers
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			l.Close()
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/parser"
+)
+
+// syslogParser parses the header of syslog messages, either in the RFC3164 format
+// used by the libc syslog() call or in the RFC5424 format, for example:
+// <13>Oct 15 10:00:00 host app[123]: hello
+// <13>1 2018-10-15T10:00:00.000Z host app 123 - [meta key="value"] hello
+// The status of the message is its severity and its timestamp the one of the header,
+// the other fields of the header, the structured data included, are parsed as attributes.
+var syslogParser *syslogMessageParser
+
+type syslogMessageParser struct {
+	parser.Parser
+}
+
+// newParser returns the parser of the messages received for source,
+// the syslog parser when the source receives syslog messages.
+func newParser(source *config.LogSource) parser.Parser {
+	if source.Config.Syslog {
+		return syslogParser
+	}
+	return parser.NoopParser
+}
+
+// syslogStatuses are the statuses of the syslog severities, indexed by severity.
+var syslogStatuses = []string{
+	message.StatusEmergency,
+	message.StatusAlert,
+	message.StatusCritical,
+	message.StatusError,
+	message.StatusWarning,
+	message.StatusNotice,
+	message.StatusInfo,
+	message.StatusDebug,
+}
+
+// maxSyslogPriority is the highest valid priority, for the facility local7 and the severity debug.
+const maxSyslogPriority = 191
+
+// syslogNilValue denotes an empty field in RFC5424 headers.
+const syslogNilValue = "-"
+
+// Parse parses the header of a syslog message, the messages without a valid priority are not parsed.
+func (p *syslogMessageParser) Parse(msg []byte) (*message.Message, error) {
+	priority, rest, ok := parseSyslogPriority(msg)
+	if !ok {
+		return message.NewMessage(msg, nil, ""), nil
+	}
+	parsedMsg := message.NewMessage(nil, nil, syslogStatuses[priority%8])
+	attributes := map[string]interface{}{
+		"syslog.severity": priority % 8,
+		"syslog.facility": priority / 8,
+	}
+	if bytes.HasPrefix(rest, []byte("1 ")) {
+		parsedMsg.Content = parseRFC5424Header(rest[2:], parsedMsg, attributes)
+	} else {
+		parsedMsg.Content = parseRFC3164Header(rest, parsedMsg, attributes, time.Now())
+	}
+	parsedMsg.Attributes = attributes
+	return parsedMsg, nil
+}
+
+// Unwrap removes the header of a syslog message.
+func (p *syslogMessageParser) Unwrap(line []byte) ([]byte, error) {
+	msg, err := p.Parse(line)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Content, nil
+}
+
+// parseSyslogPriority returns the priority at the beginning of msg and the rest of msg.
+func parseSyslogPriority(msg []byte) (int, []byte, bool) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, msg, false
+	}
+	end := bytes.IndexByte(msg, '>')
+	if end < 2 || end > 4 {
+		return 0, msg, false
+	}
+	priority, err := strconv.Atoi(string(msg[1:end]))
+	if err != nil || priority < 0 || priority > maxSyslogPriority {
+		return 0, msg, false
+	}
+	return priority, msg[end+1:], true
+}
+
+// parseRFC3164Header parses 'TIMESTAMP HOSTNAME TAG[PID]: MSG' and returns MSG,
+// the hostname is never set by the libc syslog() call but is by the relays, it is only recognized
+// when followed by a tag. The timestamp does not hold the year, the one making it the closest to now is used.
+func parseRFC3164Header(rest []byte, msg *message.Message, attributes map[string]interface{}, now time.Time) []byte {
+	if len(rest) > len(time.Stamp) && rest[len(time.Stamp)] == ' ' {
+		if timestamp, err := time.ParseInLocation(time.Stamp, string(rest[:len(time.Stamp)]), now.Location()); err == nil {
+			msg.Timestamp = withClosestYear(timestamp, now).UTC().Format(time.RFC3339Nano)
+			rest = rest[len(time.Stamp)+1:]
+		}
+	}
+	tag, content := nextSyslogField(rest)
+	if !bytes.HasSuffix(tag, []byte(":")) {
+		hostname := tag
+		tag, content = nextSyslogField(content)
+		if !bytes.HasSuffix(tag, []byte(":")) {
+			// there is no tag
+			return rest
+		}
+		attributes["syslog.hostname"] = string(hostname)
+	}
+	tag = tag[:len(tag)-1]
+	if start := bytes.IndexByte(tag, '['); start > 0 && bytes.HasSuffix(tag, []byte("]")) {
+		attributes["syslog.procid"] = string(tag[start+1 : len(tag)-1])
+		tag = tag[:start]
+	}
+	attributes["syslog.appname"] = string(tag)
+	return content
+}
+
+// nextSyslogField returns the field at the beginning of rest, up to the next space, and what follows it.
+func nextSyslogField(rest []byte) ([]byte, []byte) {
+	end := bytes.IndexByte(rest, ' ')
+	if end < 0 {
+		return rest, nil
+	}
+	return rest[:end], bytes.TrimLeft(rest[end:], " ")
+}
+
+// withClosestYear returns timestamp, parsed without year, in the year making it the closest to now,
+// the messages received shortly after new year having been sent the previous year.
+func withClosestYear(timestamp time.Time, now time.Time) time.Time {
+	closest := timestamp.AddDate(now.Year(), 0, 0)
+	for _, year := range []int{now.Year() - 1, now.Year() + 1} {
+		candidate := timestamp.AddDate(year, 0, 0)
+		if absDuration(candidate.Sub(now)) < absDuration(closest.Sub(now)) {
+			closest = candidate
+		}
+	}
+	return closest
+}
+
+// absDuration returns the absolute value of d.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// parseRFC5424Header parses 'TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG' and returns MSG.
+func parseRFC5424Header(rest []byte, msg *message.Message, attributes map[string]interface{}) []byte {
+	fields := bytes.SplitN(rest, []byte(" "), 6)
+	if len(fields) < 6 {
+		return rest
+	}
+	if timestamp := string(fields[0]); timestamp != syslogNilValue {
+		if _, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			msg.Timestamp = timestamp
+		}
+	}
+	for i, name := range []string{"syslog.hostname", "syslog.appname", "syslog.procid", "syslog.msgid"} {
+		if value := string(fields[i+1]); value != syslogNilValue {
+			attributes[name] = value
+		}
+	}
+	structuredData, content := parseStructuredData(fields[5])
+	if len(structuredData) > 0 {
+		attributes["syslog.structured_data"] = structuredData
+	}
+	// the message can start with a byte order mark
+	return bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+}
+
+// parseStructuredData returns the parameters of the structured data elements by element ID
+// and the content following the elements, for example '[meta key="value"] hello' returns
+// {"meta": {"key": "value"}} and 'hello'. The elements which are not terminated are left in the content.
+func parseStructuredData(rest []byte) (map[string]interface{}, []byte) {
+	if bytes.HasPrefix(rest, []byte(syslogNilValue)) {
+		return nil, bytes.TrimPrefix(rest[1:], []byte(" "))
+	}
+	elements := make(map[string]interface{})
+	for len(rest) > 0 && rest[0] == '[' {
+		id, params, next, ok := parseStructuredDataElement(rest[1:])
+		if !ok {
+			return elements, rest
+		}
+		elements[id] = params
+		rest = next
+	}
+	return elements, bytes.TrimPrefix(rest, []byte(" "))
+}
+
+// parseStructuredDataElement parses 'SD-ID *(SP PARAM-NAME="PARAM-VALUE")]' and returns the ID,
+// the parameters and what follows the element, the values are unescaped.
+func parseStructuredDataElement(rest []byte) (string, map[string]interface{}, []byte, bool) {
+	end := bytes.IndexAny(rest, " ]")
+	if end < 0 {
+		return "", nil, rest, false
+	}
+	id := string(rest[:end])
+	params := make(map[string]interface{})
+	rest = rest[end:]
+	for {
+		rest = bytes.TrimLeft(rest, " ")
+		if len(rest) == 0 {
+			return "", nil, rest, false
+		}
+		if rest[0] == ']' {
+			return id, params, rest[1:], true
+		}
+		equal := bytes.IndexByte(rest, '=')
+		if equal < 0 || equal+1 >= len(rest) || rest[equal+1] != '"' {
+			return "", nil, rest, false
+		}
+		name := string(rest[:equal])
+		var value []byte
+		escaped, closed := false, false
+		i := equal + 2
+		for ; i < len(rest) && !closed; i++ {
+			switch {
+			case escaped:
+				if rest[i] != '"' && rest[i] != '\\' && rest[i] != ']' {
+					// only these characters are escaped, the backslash is kept otherwise
+					value = append(value, '\\')
+				}
+				value = append(value, rest[i])
+				escaped = false
+			case rest[i] == '\\':
+				escaped = true
+			case rest[i] == '"':
+				closed = true
+			default:
+				value = append(value, rest[i])
+			}
+		}
+		if !closed {
+			return "", nil, rest, false
+		}
+		params[name] = string(value)
+		rest = rest[i:]
+	}
+}
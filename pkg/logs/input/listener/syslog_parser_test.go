@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestSyslogParserParsesRFC3164Messages(t *testing.T) {
+	msg, err := syslogParser.Parse([]byte("<11>Oct 15 10:00:00 app[123]: connection refused"))
+	assert.Nil(t, err)
+	assert.Equal(t, "connection refused", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.NotEqual(t, "", msg.Timestamp)
+	assert.Equal(t, map[string]interface{}{
+		"syslog.severity": 3,
+		"syslog.facility": 1,
+		"syslog.appname":  "app",
+		"syslog.procid":   "123",
+	}, msg.Attributes)
+
+	msg, err = syslogParser.Parse([]byte("<14>Oct  5 10:00:00 app: started"))
+	assert.Nil(t, err)
+	assert.Equal(t, "started", string(msg.Content))
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+	assert.Equal(t, "app", msg.Attributes["syslog.appname"])
+	assert.NotContains(t, msg.Attributes, "syslog.procid")
+
+	// the tag is optional
+	msg, err = syslogParser.Parse([]byte("<15>Oct 15 10:00:00 no tag here"))
+	assert.Nil(t, err)
+	assert.Equal(t, "no tag here", string(msg.Content))
+	assert.Equal(t, message.StatusDebug, msg.GetStatus())
+	assert.NotContains(t, msg.Attributes, "syslog.appname")
+	assert.NotContains(t, msg.Attributes, "syslog.hostname")
+
+	// the relays set the hostname
+	msg, err = syslogParser.Parse([]byte("<11>Oct 15 10:00:00 web-1 app[123]: connection refused"))
+	assert.Nil(t, err)
+	assert.Equal(t, "connection refused", string(msg.Content))
+	assert.Equal(t, "web-1", msg.Attributes["syslog.hostname"])
+	assert.Equal(t, "app", msg.Attributes["syslog.appname"])
+	assert.Equal(t, "123", msg.Attributes["syslog.procid"])
+
+	// the timestamp is optional
+	msg, err = syslogParser.Parse([]byte("<11>app: connection refused"))
+	assert.Nil(t, err)
+	assert.Equal(t, "connection refused", string(msg.Content))
+	assert.Equal(t, "", msg.Timestamp)
+	assert.Equal(t, "app", msg.Attributes["syslog.appname"])
+}
+
+func TestParseRFC3164HeaderTimestamp(t *testing.T) {
+	now := time.Date(2018, 10, 15, 12, 0, 0, 0, time.UTC)
+	msg := message.NewMessage(nil, nil, "")
+	parseRFC3164Header([]byte("Oct 15 10:00:00 app: hello"), msg, map[string]interface{}{}, now)
+	assert.Equal(t, "2018-10-15T10:00:00Z", msg.Timestamp)
+
+	// the messages received shortly after new year were sent the previous year
+	now = time.Date(2019, 1, 1, 0, 0, 5, 0, time.UTC)
+	parseRFC3164Header([]byte("Dec 31 23:59:59 app: hello"), msg, map[string]interface{}{}, now)
+	assert.Equal(t, "2018-12-31T23:59:59Z", msg.Timestamp)
+
+	// the clock of the sender can be slightly ahead
+	now = time.Date(2018, 12, 31, 23, 59, 59, 0, time.UTC)
+	parseRFC3164Header([]byte("Jan  1 00:00:01 app: hello"), msg, map[string]interface{}{}, now)
+	assert.Equal(t, "2019-01-01T00:00:01Z", msg.Timestamp)
+}
+
+func TestSyslogParserParsesRFC5424Messages(t *testing.T) {
+	msg, err := syslogParser.Parse([]byte(`<165>1 2018-10-15T10:00:00.000Z host app 123 ID47 [meta key="v\]al"][origin ip="10.0.0.1"] hello`))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(msg.Content))
+	assert.Equal(t, message.StatusNotice, msg.GetStatus())
+	assert.Equal(t, "2018-10-15T10:00:00.000Z", msg.Timestamp)
+	assert.Equal(t, map[string]interface{}{
+		"syslog.severity": 5,
+		"syslog.facility": 20,
+		"syslog.hostname": "host",
+		"syslog.appname":  "app",
+		"syslog.procid":   "123",
+		"syslog.msgid":    "ID47",
+		"syslog.structured_data": map[string]interface{}{
+			"meta":   map[string]interface{}{"key": "v]al"},
+			"origin": map[string]interface{}{"ip": "10.0.0.1"},
+		},
+	}, msg.Attributes)
+
+	msg, err = syslogParser.Parse([]byte("<12>1 - - app - - - \xef\xbb\xbfhello"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(msg.Content))
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+	assert.Equal(t, "", msg.Timestamp)
+	assert.NotContains(t, msg.Attributes, "syslog.hostname")
+	assert.NotContains(t, msg.Attributes, "syslog.structured_data")
+	assert.Equal(t, "app", msg.Attributes["syslog.appname"])
+}
+
+func TestParseStructuredData(t *testing.T) {
+	elements, content := parseStructuredData([]byte(`[exampleSDID@32473 iut="3" eventSource="App\"lication" path="C:\Temp"][empty] hello`))
+	assert.Equal(t, map[string]interface{}{
+		"exampleSDID@32473": map[string]interface{}{"iut": "3", "eventSource": `App"lication`, "path": `C:\Temp`},
+		"empty":             map[string]interface{}{},
+	}, elements)
+	assert.Equal(t, "hello", string(content))
+
+	// the elements which are not terminated are left in the content
+	elements, content = parseStructuredData([]byte(`[meta key="value"][broken key="value hello`))
+	assert.Equal(t, map[string]interface{}{"meta": map[string]interface{}{"key": "value"}}, elements)
+	assert.Equal(t, `[broken key="value hello`, string(content))
+
+	elements, content = parseStructuredData([]byte(`- hello`))
+	assert.Nil(t, elements)
+	assert.Equal(t, "hello", string(content))
+}
+
+func TestSyslogParserDoesNotParseMessagesWithoutPriority(t *testing.T) {
+	for _, content := range []string{"hello", "<>hello", "<192>hello", "<a>hello", "<1234>hello"} {
+		msg, err := syslogParser.Parse([]byte(content))
+		assert.Nil(t, err)
+		assert.Equal(t, content, string(msg.Content))
+		assert.Equal(t, message.StatusInfo, msg.GetStatus())
+		assert.Nil(t, msg.Attributes)
+	}
+}
+
+func TestSyslogParserUnwrap(t *testing.T) {
+	content, err := syslogParser.Unwrap([]byte("<11>Oct 15 10:00:00 app[123]: connection refused"))
+	assert.Nil(t, err)
+	assert.Equal(t, "connection refused", string(content))
+}
@@ -24,18 +24,29 @@ type Tailer struct {
 	outputChan chan *message.Message
 	read       func(*Tailer) ([]byte, error)
 	decoder    *decoder.Decoder
+	// framed is true when each read returns a whole message, which is not split on line feeds.
+	framed bool
+	// acker acknowledges the messages to the sender once handed to the pipeline,
+	// it is nil unless the messages are framed with the acked octet count framing.
+	acker *acker
+	// syncMarker is sent by the decoder after the messages of a frame to acknowledge,
+	// forwarded is signaled once forwardMessages receives it, the messages are then in the pipeline.
+	syncMarker *message.Message
+	forwarded  chan struct{}
 	stop       chan struct{}
 	done       chan struct{}
 }
 
-// NewTailer returns a new Tailer
-func NewTailer(source *config.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, error)) *Tailer {
+// NewTailer returns a new Tailer decoding the data read with parser
+func NewTailer(source *config.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, error), parser parser.Parser) *Tailer {
 	return &Tailer{
 		source:     source,
 		conn:       conn,
 		outputChan: outputChan,
 		read:       read,
-		decoder:    decoder.InitializeDecoder(source, parser.NoopParser),
+		decoder:    decoder.InitializeDecoder(source, parser),
+		syncMarker: &message.Message{},
+		forwarded:  make(chan struct{}),
 		stop:       make(chan struct{}, 1),
 		done:       make(chan struct{}, 1),
 	}
@@ -62,8 +73,11 @@ func (t *Tailer) forwardMessages() {
 		t.done <- struct{}{}
 	}()
 	for output := range t.decoder.OutputChan {
+		if output == t.syncMarker {
+			t.forwarded <- struct{}{}
+			continue
+		}
 		output.Origin = message.NewOrigin(t.source)
-		output.SetStatus(message.StatusInfo)
 		t.outputChan <- output
 	}
 }
@@ -90,7 +104,29 @@ func (t *Tailer) readForever() {
 				log.Warnf("Couldn't read message from connection: %v", err)
 				return
 			}
-			t.decoder.InputChan <- decoder.NewInput(data)
+			if t.acker != nil {
+				if err := t.forwardAcked(data); err != nil {
+					log.Warnf("Couldn't acknowledge message to connection: %v", err)
+					return
+				}
+				continue
+			}
+			if t.framed {
+				t.decoder.InputChan <- decoder.NewFrameInput(data)
+			} else {
+				t.decoder.InputChan <- decoder.NewInput(data)
+			}
 		}
 	}
 }
+
+// forwardAcked decodes the message read and acknowledges it to the sender once it is handed to the pipeline,
+// the empty messages are acknowledged without being sent.
+func (t *Tailer) forwardAcked(frame []byte) error {
+	t.decoder.InputChan <- decoder.NewFrameInput(frame)
+	t.decoder.Sync(func() {
+		t.decoder.OutputChan <- t.syncMarker
+	})
+	<-t.forwarded
+	return t.acker.ack()
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/parser"
 )
 
 const port = 10493
@@ -21,7 +22,7 @@ const port = 10493
 func TestReadAndForwardShouldSucceedWithSuccessfulRead(t *testing.T) {
 	msgChan := make(chan *message.Message)
 	r, w := net.Pipe()
-	tailer := NewTailer(config.NewLogSource("", &config.LogsConfig{Port: port}), r, msgChan, read)
+	tailer := NewTailer(config.NewLogSource("", &config.LogsConfig{Port: port}), r, msgChan, read, parser.NoopParser)
 	tailer.Start()
 
 	var msg *message.Message
@@ -45,7 +46,7 @@ func TestReadShouldFailWithError(t *testing.T) {
 	msgChan := make(chan *message.Message)
 	r, w := net.Pipe()
 	read := func(*Tailer) ([]byte, error) { return nil, errors.New("") }
-	tailer := NewTailer(config.NewLogSource("", &config.LogsConfig{Port: port}), r, msgChan, read)
+	tailer := NewTailer(config.NewLogSource("", &config.LogsConfig{Port: port}), r, msgChan, read, parser.NoopParser)
 	tailer.Start()
 
 	w.Write([]byte("foo\n"))
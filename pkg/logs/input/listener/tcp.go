@@ -6,6 +6,7 @@
 package listener
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 	"github.com/DataDog/datadog-agent/pkg/logs/restart"
@@ -22,6 +24,7 @@ import (
 const defaultTimeout = time.Minute
 
 // A TCPListener listens and accepts TCP connections and delegates the read operations to a tailer.
+// The connections are TLS-terminated when the source sets a certificate and a key.
 type TCPListener struct {
 	pipelineProvider pipeline.Provider
 	source           *config.LogSource
@@ -60,7 +63,10 @@ func (l *TCPListener) Start() {
 func (l *TCPListener) Stop() {
 	log.Infof("Stopping TCP forwarder on port %d", l.source.Config.Port)
 	l.stop <- struct{}{}
-	l.listener.Close()
+	if l.listener != nil {
+		// the listener is nil when it could not be started
+		l.listener.Close()
+	}
 	stopper := restart.NewParallelStopper()
 	for _, tailer := range l.tailers {
 		stopper.Add(tailer)
@@ -103,14 +109,48 @@ func (l *TCPListener) run() {
 
 // startListener starts a new listener, returns an error if it failed.
 func (l *TCPListener) startListener() error {
+	var tlsConfig *tls.Config
+	if l.source.Config.TLSCertPath != "" {
+		// the certificates are loaded on each start so that the ones renewed are used when the listener restarts
+		config, err := loadTLSConfig(l.source.Config)
+		if err != nil {
+			return err
+		}
+		tlsConfig = config
+	}
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", l.source.Config.Port))
 	if err != nil {
 		return err
 	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 	l.listener = listener
 	return nil
 }
 
+// loadTLSConfig returns the TLS config presenting the certificate of the source to the senders
+// and requiring them a certificate signed by its certificate authorities when set,
+// an error is returned if one of the files can not be loaded.
+func loadTLSConfig(sourceConfig *config.LogsConfig) (*tls.Config, error) {
+	cert, err := client.LoadKeyPair(sourceConfig.TLSCertPath, sourceConfig.TLSKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if sourceConfig.TLSCAPath != "" {
+		pool, err := client.LoadCertPool(sourceConfig.TLSCAPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
 // read reads data from connection, returns an error if it failed and stop the tailer.
 func (l *TCPListener) read(tailer *Tailer) ([]byte, error) {
 	tailer.conn.SetReadDeadline(time.Now().Add(defaultTimeout))
@@ -124,11 +164,40 @@ func (l *TCPListener) read(tailer *Tailer) ([]byte, error) {
 	return frame[:n], nil
 }
 
+// readFrame reads the next message framed as configured for the source from the connection,
+// returns an error if it failed and stop the tailer.
+func (l *TCPListener) readFrame(tailer *Tailer, frames *frameReader) ([]byte, error) {
+	tailer.conn.SetReadDeadline(time.Now().Add(defaultTimeout))
+	frame, truncated, err := frames.next()
+	if err != nil {
+		l.source.Status.Error(err)
+		go l.stopTailer(tailer)
+		return nil, err
+	}
+	if truncated {
+		frame = truncateFrame(frame, l.source)
+	}
+	return frame, nil
+}
+
 // startNewTailer creates and starts a new tailer that reads from the connection.
 func (l *TCPListener) startNewTailer(conn net.Conn) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	tailer := NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	read := l.read
+	framed := isFramed(l.source)
+	var frames *frameReader
+	if framed {
+		frames = newFrameReader(conn, l.source.Config.Framing, l.frameSize)
+		read = func(tailer *Tailer) ([]byte, error) {
+			return l.readFrame(tailer, frames)
+		}
+	}
+	tailer := NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), read, newParser(l.source))
+	tailer.framed = framed
+	if l.source.Config.Framing == config.AckedOctetCountFraming {
+		tailer.acker = newAcker(conn, frames)
+	}
 	l.tailers = append(l.tailers, tailer)
 	tailer.Start()
 }
@@ -6,10 +6,23 @@
 package listener
 
 import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -40,6 +53,18 @@ func TestTCPShouldReceivesMessages(t *testing.T) {
 	listener.Stop()
 }
 
+func TestTCPShouldStopWhenThePortCouldNotBeBound(t *testing.T) {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", tcpTestPort))
+	assert.Nil(t, err)
+	defer l.Close()
+
+	source := config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort})
+	listener := NewTCPListener(mock.NewMockProvider(), source, 9000)
+	listener.Start()
+	assert.True(t, source.Status.IsError())
+	listener.Stop()
+}
+
 func TestTCPDoesNotTruncateMessagesThatAreBiggerThanTheReadBufferSize(t *testing.T) {
 	pp := mock.NewMockProvider()
 	msgChan := pp.NextPipelineChan()
@@ -65,3 +90,183 @@ func TestTCPDoesNotTruncateMessagesThatAreBiggerThanTheReadBufferSize(t *testing
 
 	listener.Stop()
 }
+
+func TestTCPReadsOctetCountedMessages(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewTCPListener(pp, config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort, Framing: config.OctetCountFraming}), 20)
+	listener.Start()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", tcpTestPort))
+	assert.Nil(t, err)
+
+	var msg *message.Message
+
+	fmt.Fprintf(conn, "11 hello\nworld")
+	msg = <-msgChan
+	assert.Equal(t, "hello\nworld", string(msg.Content))
+
+	// the oversized message is truncated and the next one is read properly
+	fmt.Fprintf(conn, "30 %s3 foo", strings.Repeat("a", 30))
+	msg = <-msgChan
+	assert.Equal(t, strings.Repeat("a", 20)+"...TRUNCATED...", string(msg.Content))
+	msg = <-msgChan
+	assert.Equal(t, "foo", string(msg.Content))
+
+	listener.Stop()
+}
+
+func TestTCPAcknowledgesTheMessagesHandedToThePipeline(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewTCPListener(pp, config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort, Framing: config.AckedOctetCountFraming}), 20)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", tcpTestPort))
+	assert.Nil(t, err)
+	defer conn.Close()
+	acks := bufio.NewReader(conn)
+
+	var msg *message.Message
+
+	fmt.Fprintf(conn, "11 hello\nworld")
+	msg = <-msgChan
+	assert.Equal(t, "hello\nworld", string(msg.Content))
+	ack, err := acks.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n", ack)
+
+	// the empty messages are acknowledged without being sent
+	fmt.Fprintf(conn, "1  ")
+	ack, err = acks.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "2\n", ack)
+
+	fmt.Fprintf(conn, "3 foo")
+	msg = <-msgChan
+	assert.Equal(t, "foo", string(msg.Content))
+	ack, err = acks.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "3\n", ack)
+}
+
+func TestTCPParsesTheAcknowledgedSyslogMessages(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	source := config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort, Syslog: true, Framing: config.AckedOctetCountFraming})
+	listener := NewTCPListener(pp, source, 100)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", tcpTestPort))
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	frame := "<11>1 2018-10-15T10:00:00Z host app - - - hello world"
+	fmt.Fprintf(conn, "%d %s", len(frame), frame)
+	msg := <-msgChan
+	assert.Equal(t, "hello world", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, "host", msg.Attributes["syslog.hostname"])
+	ack, err := bufio.NewReader(conn).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n", ack)
+}
+
+// writeSelfSignedCertificate writes a certificate for 127.0.0.1 signed by itself and its key in dir,
+// the certificate is both its own authority and valid for the server and the client.
+func writeSelfSignedCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	assert.Nil(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certPath, keyPath
+}
+
+func TestTCPReceivesMessagesOverTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs-tls-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeSelfSignedCertificate(t, dir)
+
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	source := config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort, Syslog: true, TLSCertPath: certPath, TLSKeyPath: keyPath})
+	listener := NewTCPListener(pp, source, 9000)
+	listener.Start()
+	defer listener.Stop()
+	assert.True(t, source.Status.IsSuccess())
+
+	pem, err := ioutil.ReadFile(certPath)
+	assert.Nil(t, err)
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(pem))
+	conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", tcpTestPort), &tls.Config{RootCAs: pool})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "<11>1 2018-10-15T10:00:00Z host app - - - hello world\n")
+	msg := <-msgChan
+	assert.Equal(t, "hello world", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+}
+
+func TestTCPRejectsTheSendersWithoutAClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs-tls-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeSelfSignedCertificate(t, dir)
+
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	source := config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort, TLSCertPath: certPath, TLSKeyPath: keyPath, TLSCAPath: certPath})
+	listener := NewTCPListener(pp, source, 9000)
+	listener.Start()
+	defer listener.Stop()
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	assert.Nil(t, err)
+	// the handshake fails on the first read of the listener, which closes the connection
+	conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", tcpTestPort), &tls.Config{InsecureSkipVerify: true})
+	if err == nil {
+		fmt.Fprintf(conn, "dropped\n")
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.NotNil(t, err)
+
+	conn, err = tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", tcpTestPort), &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{cert}})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "hello world\n")
+	msg := <-msgChan
+	assert.Equal(t, "hello world", string(msg.Content))
+}
+
+func TestTCPShouldStopWhenTheCertificateCouldNotBeLoaded(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort, TLSCertPath: "/does/not/exist.crt", TLSKeyPath: "/does/not/exist.key"})
+	listener := NewTCPListener(mock.NewMockProvider(), source, 9000)
+	listener.Start()
+	assert.True(t, source.Status.IsError())
+	listener.Stop()
+}
@@ -67,7 +67,8 @@ func (l *UDPListener) startNewTailer() error {
 	if err != nil {
 		return err
 	}
-	l.tailer = NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	l.tailer = NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read, newParser(l.source))
+	l.tailer.framed = isFramed(l.source)
 	l.tailer.Start()
 	return nil
 }
@@ -92,6 +93,8 @@ func (l *UDPListener) read(tailer *Tailer) ([]byte, error) {
 	case err != nil:
 		go l.resetTailer()
 		return nil, err
+	case isFramed(l.source):
+		return l.datagramFrame(frame[:n]), nil
 	default:
 		if n == l.frameSize+1 {
 			// The message is bigger than the length of the read buffer, the trailing part of the content will be dropped.
@@ -102,6 +105,14 @@ func (l *UDPListener) read(tailer *Tailer) ([]byte, error) {
 	}
 }
 
+// datagramFrame returns the message of datagram, the datagrams bigger than the read buffer are truncated.
+func (l *UDPListener) datagramFrame(datagram []byte) []byte {
+	if len(datagram) > l.frameSize {
+		return truncateFrame(datagram[:l.frameSize], l.source)
+	}
+	return datagram
+}
+
 // resetTailer creates a new tailer.
 func (l *UDPListener) resetTailer() {
 	log.Infof("Resetting the UDP connection on port: %d", l.source.Config.Port)
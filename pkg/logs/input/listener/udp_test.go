@@ -8,6 +8,7 @@ package listener
 import (
 	"fmt"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -36,3 +37,44 @@ func TestUDPShouldReceiveMessage(t *testing.T) {
 
 	listener.Stop()
 }
+
+func TestUDPShouldParseSyslogMessages(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewUDPListener(pp, config.NewLogSource("", &config.LogsConfig{Port: udpTestPort, Syslog: true}), 9000)
+	listener.Start()
+
+	conn, err := net.Dial("udp", fmt.Sprintf("localhost:%d", udpTestPort))
+	assert.Nil(t, err)
+
+	fmt.Fprintf(conn, "<11>1 2018-10-15T10:00:00.000Z web-1 app 123 - - connection refused\n")
+	msg := <-msgChan
+	assert.Equal(t, "connection refused", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, "2018-10-15T10:00:00.000Z", msg.Timestamp)
+	assert.Equal(t, "web-1", msg.Attributes["syslog.hostname"])
+
+	listener.Stop()
+}
+
+func TestUDPShouldReceiveOneMessagePerDatagram(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewUDPListener(pp, config.NewLogSource("", &config.LogsConfig{Port: udpTestPort, Framing: config.AutoFraming}), 20)
+	listener.Start()
+
+	conn, err := net.Dial("udp", fmt.Sprintf("localhost:%d", udpTestPort))
+	assert.Nil(t, err)
+
+	var msg *message.Message
+
+	fmt.Fprintf(conn, "hello\nworld")
+	msg = <-msgChan
+	assert.Equal(t, "hello\nworld", string(msg.Content))
+
+	fmt.Fprintf(conn, strings.Repeat("a", 30))
+	msg = <-msgChan
+	assert.Equal(t, strings.Repeat("a", 20)+"...TRUNCATED...", string(msg.Content))
+
+	listener.Stop()
+}
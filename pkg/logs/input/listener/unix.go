@@ -0,0 +1,217 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
+)
+
+// A UnixListener binds a Unix stream socket, accepts the connections of the local writers
+// and delegates the read operations of each connection to a dedicated tailer.
+// The connections are read like TCP connections, without timeout as the writers can be idle for long.
+type UnixListener struct {
+	pipelineProvider pipeline.Provider
+	source           *config.LogSource
+	frameSize        int
+	listener         net.Listener
+	tailers          []*Tailer
+	mu               sync.Mutex
+	stop             chan struct{}
+	done             chan struct{}
+}
+
+// NewUnixListener returns an initialized UnixListener
+func NewUnixListener(pipelineProvider pipeline.Provider, source *config.LogSource, frameSize int) *UnixListener {
+	return &UnixListener{
+		pipelineProvider: pipelineProvider,
+		source:           source,
+		frameSize:        frameSize,
+		tailers:          []*Tailer{},
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+// Start binds the socket and starts accepting the connections.
+func (l *UnixListener) Start() {
+	log.Infof("Starting Unix stream forwarder on socket: %s, with read buffer size: %d", l.source.Config.Path, l.frameSize)
+	listener, err := listenUnix(l.source)
+	if err != nil {
+		log.Errorf("Can't start Unix stream forwarder on socket %s: %v", l.source.Config.Path, err)
+		l.source.Status.Error(err)
+		close(l.done)
+		return
+	}
+	l.listener = listener
+	l.source.Status.Success()
+	go l.run()
+}
+
+// Stop stops accepting the connections, stops all the active tailers and removes the socket.
+func (l *UnixListener) Stop() {
+	log.Infof("Stopping Unix stream forwarder on socket: %s", l.source.Config.Path)
+	close(l.stop)
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	<-l.done
+	l.mu.Lock()
+	stopper := restart.NewParallelStopper()
+	for _, tailer := range l.tailers {
+		stopper.Add(tailer)
+	}
+	l.tailers = nil
+	l.mu.Unlock()
+	stopper.Stop()
+	if l.listener != nil {
+		os.Remove(l.source.Config.Path)
+	}
+}
+
+// run accepts the connections and creates a dedicated tailer for each.
+func (l *UnixListener) run() {
+	defer close(l.done)
+	for {
+		conn, err := l.listener.Accept()
+		select {
+		case <-l.stop:
+			// the listener has been closed on stop
+			if err == nil {
+				conn.Close()
+			}
+			return
+		default:
+		}
+		switch {
+		case err != nil && isClosedConnError(err):
+			return
+		case err != nil:
+			// the connection has been aborted by the writer for example, the socket is still usable
+			log.Warnf("Can't accept connection on socket %s: %v", l.source.Config.Path, err)
+		default:
+			l.startNewTailer(conn)
+		}
+	}
+}
+
+// read reads data from the connection, returns an error if it failed and stops the tailer.
+func (l *UnixListener) read(tailer *Tailer) ([]byte, error) {
+	frame := make([]byte, l.frameSize)
+	n, err := tailer.conn.Read(frame)
+	if err != nil {
+		l.handleReadError(tailer, err)
+		return nil, err
+	}
+	return frame[:n], nil
+}
+
+// readFrame reads the next message framed as configured for the source from the connection,
+// returns an error if it failed and stops the tailer.
+func (l *UnixListener) readFrame(tailer *Tailer, frames *frameReader) ([]byte, error) {
+	frame, truncated, err := frames.next()
+	if err != nil {
+		l.handleReadError(tailer, err)
+		return nil, err
+	}
+	if truncated {
+		frame = truncateFrame(frame, l.source)
+	}
+	return frame, nil
+}
+
+// handleReadError stops the tailer, the source is in error unless the writer closed the connection.
+func (l *UnixListener) handleReadError(tailer *Tailer, err error) {
+	if err != io.EOF && !isClosedConnError(err) {
+		l.source.Status.Error(err)
+	}
+	go l.stopTailer(tailer)
+}
+
+// startNewTailer creates and starts a new tailer that reads from the connection.
+func (l *UnixListener) startNewTailer(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	read := l.read
+	framed := isFramed(l.source)
+	if framed {
+		frames := newFrameReader(conn, l.source.Config.Framing, l.frameSize)
+		read = func(tailer *Tailer) ([]byte, error) {
+			return l.readFrame(tailer, frames)
+		}
+	}
+	tailer := NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), read, newParser(l.source))
+	tailer.framed = framed
+	l.tailers = append(l.tailers, tailer)
+	tailer.Start()
+}
+
+// stopTailer stops the tailer unless it has already been stopped with the listener.
+func (l *UnixListener) stopTailer(tailer *Tailer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, t := range l.tailers {
+		if t == tailer {
+			tailer.Stop()
+			l.tailers = append(l.tailers[:i], l.tailers[i+1:]...)
+			return
+		}
+	}
+}
+
+// listenUnix binds the stream socket of source.
+func listenUnix(source *config.LogSource) (net.Listener, error) {
+	path := source.Config.Path
+	if err := removeStaleSocket("unix", path); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := chmodSocket(source); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// removeStaleSocket removes the socket of network left at path by a previous run, nothing listens on it anymore,
+// returns an error if path exists and is not a socket or if another process listens on it.
+func removeStaleSocket(network, path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s already exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout(network, path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is already in use", path)
+	}
+	return os.Remove(path)
+}
+
+// chmodSocket sets the permissions of the socket of source,
+// which is created with the umask of the agent.
+func chmodSocket(source *config.LogSource) error {
+	mode, err := source.Config.SocketPermissions()
+	if err != nil {
+		return err
+	}
+	return os.Chmod(source.Config.Path, mode)
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline/mock"
+)
+
+func newTestUnixSource(t *testing.T, logsConfig *config.LogsConfig) (*config.LogSource, string) {
+	testDir, err := ioutil.TempDir("", "log-unix-test-")
+	assert.Nil(t, err)
+	logsConfig.Type = config.UnixType
+	logsConfig.Path = fmt.Sprintf("%s/app.sock", testDir)
+	return config.NewLogSource("", logsConfig), logsConfig.Path
+}
+
+func TestUnixShouldReceiveTheLinesOfConcurrentWriters(t *testing.T) {
+	source, path := newTestUnixSource(t, &config.LogsConfig{})
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewUnixListener(pp, source, 9000)
+	listener.Start()
+	assert.True(t, source.Status.IsSuccess())
+
+	first, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	defer first.Close()
+	second, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	defer second.Close()
+
+	first.Write([]byte("first hello\nfirst "))
+	second.Write([]byte("second hello\n"))
+	first.Write([]byte("world\n"))
+
+	var contents []string
+	for i := 0; i < 3; i++ {
+		msg := <-msgChan
+		contents = append(contents, string(msg.Content))
+	}
+	sort.Strings(contents)
+	assert.Equal(t, []string{"first hello", "first world", "second hello"}, contents)
+
+	// a writer disconnecting does not affect the others
+	first.Close()
+	second.Write([]byte("second world\n"))
+	msg := <-msgChan
+	assert.Equal(t, "second world", string(msg.Content))
+	assert.True(t, source.Status.IsSuccess())
+
+	listener.Stop()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUnixShouldReadOctetCountedFrames(t *testing.T) {
+	source, path := newTestUnixSource(t, &config.LogsConfig{Framing: config.OctetCountFraming})
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewUnixListener(pp, source, 9000)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	conn.Write([]byte("11 hello\nworld"))
+	msg := <-msgChan
+	assert.Equal(t, "hello\nworld", string(msg.Content))
+}
+
+func TestUnixShouldSetTheSocketMode(t *testing.T) {
+	source, path := newTestUnixSource(t, &config.LogsConfig{SocketMode: "0620"})
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+
+	// a socket left by a previous run is replaced
+	l, err := net.Listen("unix", path)
+	assert.Nil(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	listener := NewUnixListener(mock.NewMockProvider(), source, 9000)
+	listener.Start()
+	defer listener.Stop()
+	assert.True(t, source.Status.IsSuccess())
+
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0620), fi.Mode().Perm())
+}
+
+func TestUnixShouldNotReplaceRegularFiles(t *testing.T) {
+	source, path := newTestUnixSource(t, &config.LogsConfig{})
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+	assert.Nil(t, ioutil.WriteFile(path, []byte("foo"), 0644))
+
+	listener := NewUnixListener(mock.NewMockProvider(), source, 9000)
+	listener.Start()
+	assert.True(t, source.Status.IsError())
+	listener.Stop()
+
+	_, err := os.Stat(path)
+	assert.Nil(t, err)
+}
+
+func TestUnixDatagramShouldNotParseSyslogHeaders(t *testing.T) {
+	source, path := newTestUnixSource(t, &config.LogsConfig{SocketType: config.DatagramSocketType})
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	launcher := &Launcher{pipelineProvider: pp, frameSize: 9000}
+	listener := launcher.newUnixListener(source)
+	listener.Start()
+
+	conn, err := net.Dial("unixgram", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	var msg *message.Message
+
+	// each datagram is one message
+	conn.Write([]byte("<11>Oct 15 10:00:00 app[123]: first line\nsecond line\n"))
+	msg = <-msgChan
+	assert.Equal(t, `<11>Oct 15 10:00:00 app[123]: first line\nsecond line`, string(msg.Content))
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+
+	listener.Stop()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listener
+
+import (
+	"bytes"
+	"net"
+	"os"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/parser"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+)
+
+// A UnixgramListener binds a Unix datagram socket, like the syslog socket /dev/log,
+// and delegates the read operations to a tailer.
+// Each datagram is one message, its syslog header is parsed unless the source is a unix source
+// with the datagram socket type. The datagrams bigger than the frame size are truncated.
+type UnixgramListener struct {
+	pipelineProvider pipeline.Provider
+	source           *config.LogSource
+	frameSize        int
+	tailer           *Tailer
+}
+
+// NewUnixgramListener returns an initialized UnixgramListener
+func NewUnixgramListener(pipelineProvider pipeline.Provider, source *config.LogSource, frameSize int) *UnixgramListener {
+	return &UnixgramListener{
+		pipelineProvider: pipelineProvider,
+		source:           source,
+		frameSize:        frameSize,
+	}
+}
+
+// Start binds the socket and starts a tailer.
+func (l *UnixgramListener) Start() {
+	log.Infof("Starting Unix datagram forwarder on socket: %s, with read buffer size: %d", l.source.Config.Path, l.frameSize)
+	err := l.startNewTailer()
+	if err != nil {
+		log.Errorf("Can't start Unix datagram forwarder on socket %s: %v", l.source.Config.Path, err)
+		l.source.Status.Error(err)
+		return
+	}
+	l.source.Status.Success()
+}
+
+// Stop stops the tailer and removes the socket.
+func (l *UnixgramListener) Stop() {
+	log.Infof("Stopping Unix datagram forwarder on socket: %s", l.source.Config.Path)
+	if l.tailer == nil {
+		return
+	}
+	l.tailer.Stop()
+	os.Remove(l.source.Config.Path)
+}
+
+// startNewTailer starts a new Tailer
+func (l *UnixgramListener) startNewTailer() error {
+	conn, err := l.newUnixgramConnection()
+	if err != nil {
+		return err
+	}
+	var p parser.Parser = syslogParser
+	if l.source.Config.Type == config.UnixType {
+		p = newParser(l.source)
+	}
+	l.tailer = NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read, p)
+	l.tailer.Start()
+	return nil
+}
+
+// newUnixgramConnection binds the socket, replacing a socket left by a previous run unless it is still bound,
+// returns an error if the creation failed.
+func (l *UnixgramListener) newUnixgramConnection() (net.Conn, error) {
+	path := l.source.Config.Path
+	if err := removeStaleSocket("unixgram", path); err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	if err := chmodSocket(l.source); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// read reads one datagram from the tailer connection and returns it as one line,
+// returns an error if it failed and reset the tailer.
+func (l *UnixgramListener) read(tailer *Tailer) ([]byte, error) {
+	frame := make([]byte, l.frameSize+1)
+	n, err := tailer.conn.Read(frame)
+	switch {
+	case err != nil && isClosedConnError(err):
+		return nil, err
+	case err != nil:
+		go l.resetTailer()
+		return nil, err
+	default:
+		return toLine(frame[:n], l.frameSize), nil
+	}
+}
+
+// toLine returns the content of a datagram as a single line,
+// the content is truncated to frameSize and its line feeds are escaped like in multi-line messages.
+func toLine(datagram []byte, frameSize int) []byte {
+	truncated := len(datagram) > frameSize
+	if truncated {
+		// the trailing part of the datagram has been dropped by the kernel
+		datagram = datagram[:frameSize]
+	}
+	// the datagrams can be terminated by a line feed or a null byte
+	datagram = bytes.TrimRight(datagram, "\n\x00")
+	line := bytes.Replace(datagram, []byte("\n"), []byte(`\n`), -1)
+	if truncated {
+		line = append(line, decoder.TRUNCATED...)
+	}
+	return append(line, '\n')
+}
+
+// resetTailer creates a new tailer.
+func (l *UnixgramListener) resetTailer() {
+	log.Infof("Resetting the Unix datagram socket: %s", l.source.Config.Path)
+	l.tailer.Stop()
+	err := l.startNewTailer()
+	if err != nil {
+		log.Errorf("Could not reset the Unix datagram socket %s: %v", l.source.Config.Path, err)
+		l.source.Status.Error(err)
+		return
+	}
+	l.source.Status.Success()
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline/mock"
+)
+
+func newTestUnixgramListener(t *testing.T, frameSize int) (*UnixgramListener, chan *message.Message, string) {
+	testDir, err := ioutil.TempDir("", "log-unixgram-test-")
+	assert.Nil(t, err)
+	path := fmt.Sprintf("%s/log.sock", testDir)
+	pp := mock.NewMockProvider()
+	listener := NewUnixgramListener(pp, config.NewLogSource("", &config.LogsConfig{Type: config.UnixgramType, Path: path}), frameSize)
+	return listener, pp.NextPipelineChan(), path
+}
+
+func TestUnixgramShouldReceiveOneMessagePerDatagram(t *testing.T) {
+	listener, msgChan, path := newTestUnixgramListener(t, 9000)
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+	listener.Start()
+
+	conn, err := net.Dial("unixgram", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	var msg *message.Message
+
+	// like the libc syslog() call, without trailing line feed
+	conn.Write([]byte("<11>Oct 15 10:00:00 app[123]: connection refused"))
+	msg = <-msgChan
+	assert.Equal(t, "connection refused", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, "app", msg.Attributes["syslog.appname"])
+
+	// the line feeds do not split the datagrams
+	conn.Write([]byte("<14>Oct 15 10:00:00 app: first line\nsecond line\n"))
+	msg = <-msgChan
+	assert.Equal(t, `first line\nsecond line`, string(msg.Content))
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+
+	conn.Write([]byte("hello world\x00"))
+	msg = <-msgChan
+	assert.Equal(t, "hello world", string(msg.Content))
+
+	listener.Stop()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUnixgramShouldTruncateBigDatagrams(t *testing.T) {
+	frameSize := 100
+	listener, msgChan, path := newTestUnixgramListener(t, frameSize)
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("unixgram", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	var msg *message.Message
+
+	conn.Write([]byte(strings.Repeat("a", frameSize+100)))
+	msg = <-msgChan
+	assert.Equal(t, strings.Repeat("a", frameSize)+string(decoder.TRUNCATED), string(msg.Content))
+
+	// the next datagram is not affected
+	conn.Write([]byte(strings.Repeat("b", frameSize)))
+	msg = <-msgChan
+	assert.Equal(t, strings.Repeat("b", frameSize), string(msg.Content))
+}
+
+func TestUnixgramShouldBeWritableByAllUsers(t *testing.T) {
+	listener, _, path := newTestUnixgramListener(t, 9000)
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+
+	// a socket left by a previous run is replaced
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.Nil(t, err)
+	conn.Close()
+
+	listener.Start()
+	defer listener.Stop()
+	assert.True(t, listener.source.Status.IsSuccess())
+
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, config.DefaultSocketMode, fi.Mode().Perm())
+}
+
+func TestUnixgramShouldNotReplaceRegularFiles(t *testing.T) {
+	listener, _, path := newTestUnixgramListener(t, 9000)
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+	assert.Nil(t, ioutil.WriteFile(path, []byte("foo"), 0644))
+
+	listener.Start()
+	assert.True(t, listener.source.Status.IsError())
+	listener.Stop()
+
+	_, err := os.Stat(path)
+	assert.Nil(t, err)
+}
+
+func TestUnixgramShouldReplaceStaleSocketsOnly(t *testing.T) {
+	listener, msgChan, path := newTestUnixgramListener(t, 9000)
+	defer os.RemoveAll(path[:strings.LastIndex(path, "/")])
+
+	// the socket of another process is still bound
+	other, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.Nil(t, err)
+	listener.Start()
+	assert.True(t, listener.source.Status.IsError())
+	listener.Stop()
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+
+	// the socket left once the other process stopped is replaced
+	other.Close()
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+	listener.Start()
+	defer listener.Stop()
+	assert.True(t, listener.source.Status.IsSuccess())
+
+	conn, err := net.Dial("unixgram", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello world"))
+	assert.Nil(t, err)
+	msg := <-msgChan
+	assert.Equal(t, "hello world", string(msg.Content))
+}
@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package namedpipe
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
+)
+
+// Launcher starts a tailer for each named pipe source and stops it when its source is removed.
+type Launcher struct {
+	sources          chan *config.LogSource
+	removedSources   chan *config.LogSource
+	pipelineProvider pipeline.Provider
+	tailers          map[*config.LogSource]*Tailer
+	stop             chan struct{}
+}
+
+// NewLauncher returns a new Launcher.
+func NewLauncher(sources *config.LogSources, pipelineProvider pipeline.Provider) *Launcher {
+	return &Launcher{
+		sources:          sources.GetAddedForType(config.NamedPipeType),
+		removedSources:   sources.GetRemovedForType(config.NamedPipeType),
+		pipelineProvider: pipelineProvider,
+		tailers:          make(map[*config.LogSource]*Tailer),
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start starts the launcher.
+func (l *Launcher) Start() {
+	go l.run()
+}
+
+// run starts new tailers and stops the ones of the removed sources.
+func (l *Launcher) run() {
+	for {
+		select {
+		case source := <-l.sources:
+			tailer := NewTailer(source, l.pipelineProvider.NextPipelineChan())
+			tailer.Start()
+			l.tailers[source] = tailer
+		case source := <-l.removedSources:
+			if tailer, exists := l.tailers[source]; exists {
+				tailer.Stop()
+				delete(l.tailers, source)
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Stop stops all active tailers
+func (l *Launcher) Stop() {
+	l.stop <- struct{}{}
+	stopper := restart.NewParallelStopper()
+	for source, tailer := range l.tailers {
+		stopper.Add(tailer)
+		delete(l.tailers, source)
+	}
+	stopper.Stop()
+}
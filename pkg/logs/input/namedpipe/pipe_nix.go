@@ -0,0 +1,32 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package namedpipe
+
+import (
+	"os"
+	"syscall"
+)
+
+// pipeMode lets all the local processes write to the pipes created by the agent.
+const pipeMode = 0666
+
+// createPipe creates a named pipe at path unless one already exists,
+// returns an error if another kind of file exists at path.
+func createPipe(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return checkPipe(path)
+	}
+	if err := syscall.Mkfifo(path, pipeMode); err != nil && !os.IsExist(err) {
+		return err
+	}
+	// the pipe is created with the umask of the agent
+	if err := os.Chmod(path, pipeMode); err != nil {
+		return err
+	}
+	return checkPipe(path)
+}
@@ -0,0 +1,17 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build windows
+
+package namedpipe
+
+import (
+	"errors"
+)
+
+// createPipe returns an error as the named pipes of Windows are not supported.
+func createPipe(path string) error {
+	return errors.New("named pipes are not supported on Windows")
+}
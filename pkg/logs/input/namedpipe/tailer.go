@@ -0,0 +1,201 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package namedpipe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/parser"
+)
+
+// readBufferSize is the size of the buffer the pipe is read into.
+const readBufferSize = 4096
+
+// defaultReopenDelay is the delay between two attempts to open the pipe and between two checks
+// that the pipe has not been replaced by another one.
+const defaultReopenDelay = time.Second
+
+// errStopped is returned when opening the pipe while the tailer is stopping.
+var errStopped = errors.New("tailer stopped")
+
+// Tailer reads the messages written to a named pipe, one per line.
+// The pipe is created if it does not exist, it is opened for reading and writing so that it always has a writer:
+// the reads never hit the end of the file when the writers close the pipe, which they can open again at any time,
+// and the tailer never waits for a writer to open it. The pipe is opened again when it is replaced by another one.
+type Tailer struct {
+	source     *config.LogSource
+	outputChan chan *message.Message
+	decoder    *decoder.Decoder
+	// reopenDelay is the delay between two attempts to open the pipe and between two checks
+	// that the pipe has not been replaced by another one
+	reopenDelay time.Duration
+
+	// mutex guards the pipe, which is closed to interrupt the read in progress
+	mutex   sync.Mutex
+	pipe    *os.File
+	stopped bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewTailer returns a new Tailer of the pipe at the path of source.
+func NewTailer(source *config.LogSource, outputChan chan *message.Message) *Tailer {
+	return &Tailer{
+		source:      source,
+		outputChan:  outputChan,
+		decoder:     decoder.InitializeDecoder(source, parser.NoopParser),
+		reopenDelay: defaultReopenDelay,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start starts reading the pipe.
+func (t *Tailer) Start() {
+	log.Infof("Starting to read the named pipe: %s", t.source.Config.Path)
+	go t.forwardMessages()
+	t.decoder.Start()
+	go t.readForever()
+}
+
+// Stop stops the tailer and waits for the decoder to be flushed,
+// it does not wait for a writer to open the pipe.
+func (t *Tailer) Stop() {
+	log.Infof("Stopping to read the named pipe: %s", t.source.Config.Path)
+	close(t.stop)
+	t.mutex.Lock()
+	t.stopped = true
+	if t.pipe != nil {
+		t.pipe.Close()
+	}
+	t.mutex.Unlock()
+	<-t.done
+}
+
+// forwardMessages forwards the messages decoded to outputChan.
+func (t *Tailer) forwardMessages() {
+	defer close(t.done)
+	for output := range t.decoder.OutputChan {
+		output.Origin = message.NewOrigin(t.source)
+		t.outputChan <- output
+	}
+}
+
+// readForever reads the pipe until the tailer is stopped, the pipe is opened again
+// after a delay when it can not be opened or read.
+func (t *Tailer) readForever() {
+	defer t.decoder.Stop()
+	for {
+		pipe, err := t.open()
+		if err == errStopped {
+			return
+		}
+		if err != nil {
+			log.Warnf("Could not open the named pipe %s: %v", t.source.Config.Path, err)
+			t.source.Status.Error(err)
+		} else {
+			t.source.Status.Success()
+			t.read(pipe)
+		}
+		select {
+		case <-t.stop:
+			return
+		case <-time.After(t.reopenDelay):
+		}
+	}
+}
+
+// open creates the pipe if need be and opens it, returns errStopped if the tailer is stopping.
+func (t *Tailer) open() (*os.File, error) {
+	path := t.source.Config.Path
+	if err := createPipe(path); err != nil {
+		return nil, err
+	}
+	pipe, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.stopped {
+		pipe.Close()
+		return nil, errStopped
+	}
+	t.pipe = pipe
+	return pipe, nil
+}
+
+// read forwards the data read from pipe to the decoder until the pipe is closed or replaced.
+func (t *Tailer) read(pipe *os.File) {
+	replaced := make(chan struct{})
+	go t.watch(pipe, replaced)
+	defer close(replaced)
+	for {
+		buffer := make([]byte, readBufferSize)
+		n, err := pipe.Read(buffer)
+		if n > 0 {
+			t.decoder.InputChan <- decoder.NewInput(buffer[:n])
+		}
+		if err != nil {
+			pipe.Close()
+			return
+		}
+	}
+}
+
+// watch closes pipe when the file at the path of the source is not pipe anymore,
+// until done is closed.
+func (t *Tailer) watch(pipe *os.File, done chan struct{}) {
+	ticker := time.NewTicker(t.reopenDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !isSamePipe(t.source.Config.Path, pipe) {
+				log.Infof("The named pipe %s has been replaced, opening it again", t.source.Config.Path)
+				pipe.Close()
+				return
+			}
+		}
+	}
+}
+
+// isSamePipe returns true if the file at path is pipe.
+func isSamePipe(path string, pipe *os.File) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	pipeInfo, err := pipe.Stat()
+	if err != nil {
+		return false
+	}
+	return os.SameFile(info, pipeInfo)
+}
+
+// checkPipe returns an error if the file at path exists and is not a named pipe.
+func checkPipe(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("%s already exists and is not a named pipe", path)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build !windows
+
+package namedpipe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+type TailerTestSuite struct {
+	suite.Suite
+	dir        string
+	path       string
+	source     *config.LogSource
+	outputChan chan *message.Message
+	tailer     *Tailer
+}
+
+func (suite *TailerTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "named-pipe")
+	suite.Nil(err)
+	suite.path = filepath.Join(suite.dir, "app.pipe")
+	suite.source = config.NewLogSource("", &config.LogsConfig{Type: config.NamedPipeType, Path: suite.path, Tags: []string{"app:legacy"}})
+	suite.outputChan = make(chan *message.Message, 10)
+	suite.tailer = NewTailer(suite.source, suite.outputChan)
+	suite.tailer.reopenDelay = 10 * time.Millisecond
+}
+
+func (suite *TailerTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+// isOpen returns true if the tailer opened the pipe.
+func (suite *TailerTestSuite) isOpen() bool {
+	suite.tailer.mutex.Lock()
+	defer suite.tailer.mutex.Unlock()
+	return suite.tailer.pipe != nil
+}
+
+// waitForPipe waits for the tailer to open the pipe.
+func (suite *TailerTestSuite) waitForPipe() {
+	for i := 0; i < 1000 && !suite.isOpen(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	suite.True(suite.isOpen())
+}
+
+// write opens the pipe, writes content and closes the pipe.
+func (suite *TailerTestSuite) write(content string) {
+	pipe, err := os.OpenFile(suite.path, os.O_WRONLY, 0)
+	suite.Nil(err)
+	_, err = pipe.WriteString(content)
+	suite.Nil(err)
+	suite.Nil(pipe.Close())
+}
+
+func (suite *TailerTestSuite) receive() string {
+	select {
+	case msg := <-suite.outputChan:
+		suite.Equal(suite.source, msg.Origin.LogSource)
+		return string(msg.Content)
+	case <-time.After(5 * time.Second):
+		suite.Fail("no message received")
+		return ""
+	}
+}
+
+func (suite *TailerTestSuite) TestTailerReadsTheLinesOfTheWriters() {
+	suite.tailer.Start()
+	defer suite.tailer.Stop()
+	suite.waitForPipe()
+
+	info, err := os.Stat(suite.path)
+	suite.Nil(err)
+	suite.NotEqual(0, info.Mode()&os.ModeNamedPipe)
+
+	suite.write("foo\nbar\n")
+	suite.Equal("foo", suite.receive())
+	suite.Equal("bar", suite.receive())
+
+	// the writer opens the pipe again
+	suite.write("baz\n")
+	suite.Equal("baz", suite.receive())
+}
+
+func (suite *TailerTestSuite) TestTailerOpensThePipeAgainWhenItIsReplaced() {
+	suite.tailer.Start()
+	defer suite.tailer.Stop()
+	suite.waitForPipe()
+
+	suite.Nil(os.Remove(suite.path))
+	suite.Nil(syscall.Mkfifo(suite.path, 0600))
+	// the writer blocks until the tailer opens the new pipe
+	suite.write("foo\n")
+	suite.Equal("foo", suite.receive())
+}
+
+func (suite *TailerTestSuite) TestTailerStopsWithoutWriter() {
+	suite.tailer.Start()
+	suite.waitForPipe()
+
+	done := make(chan struct{})
+	go func() {
+		suite.tailer.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		suite.Fail("the tailer did not stop")
+	}
+}
+
+func (suite *TailerTestSuite) TestTailerFailsWhenThePathIsNotAPipe() {
+	suite.Nil(ioutil.WriteFile(suite.path, []byte("foo\n"), 0644))
+	suite.tailer.Start()
+	time.Sleep(50 * time.Millisecond)
+	suite.tailer.Stop()
+
+	suite.False(suite.isOpen())
+	suite.True(suite.source.Status.IsError())
+}
+
+func TestTailerTestSuite(t *testing.T) {
+	suite.Run(t, new(TailerTestSuite))
+}
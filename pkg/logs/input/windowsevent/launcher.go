@@ -6,8 +6,12 @@
 package windowsevent
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
+	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 	"github.com/DataDog/datadog-agent/pkg/logs/restart"
@@ -17,15 +21,18 @@ import (
 type Launcher struct {
 	sources          chan *config.LogSource
 	pipelineProvider pipeline.Provider
+	registry         auditor.Registry
+	channels         []string
 	tailers          map[string]*Tailer
 	stop             chan struct{}
 }
 
 // NewLauncher returns a new Launcher.
-func NewLauncher(sources *config.LogSources, pipelineProvider pipeline.Provider) *Launcher {
+func NewLauncher(sources *config.LogSources, pipelineProvider pipeline.Provider, registry auditor.Registry) *Launcher {
 	return &Launcher{
 		sources:          sources.GetAddedForType(config.WindowsEventType),
 		pipelineProvider: pipelineProvider,
+		registry:         registry,
 		tailers:          make(map[string]*Tailer),
 		stop:             make(chan struct{}),
 	}
@@ -38,6 +45,7 @@ func (l *Launcher) Start() {
 		log.Debug("Could not list windows event log channels: ", err)
 	} else {
 		log.Debug("Found available windows event log channels: ", availableChannels)
+		l.channels = availableChannels
 	}
 	go l.run()
 }
@@ -75,20 +83,54 @@ func (l *Launcher) Stop() {
 	stopper.Stop()
 }
 
-// sanitizedConfig sets default values for the config
+// sanitizedConfig sets default values for the config,
+// the query selects the events with the IDs of the config when it has some.
 func (l *Launcher) sanitizedConfig(sourceConfig *config.LogsConfig) *Config {
-	config := &Config{sourceConfig.ChannelPath, sourceConfig.Query}
+	config := &Config{
+		ChannelPath: sourceConfig.ChannelPath,
+		Query:       sourceConfig.Query,
+		EventFormat: sourceConfig.EventFormat,
+	}
+	if config.Query == "" && len(sourceConfig.EventIDs) > 0 {
+		config.Query = eventIDsQuery(sourceConfig.EventIDs)
+	}
 	if config.Query == "" {
 		config.Query = "*"
 	}
 	return config
 }
 
-// setupTailer configures and starts a new tailer
+// eventIDsQuery returns the XPath query selecting the events with one of ids.
+func eventIDsQuery(ids []int) string {
+	conditions := make([]string, len(ids))
+	for i, id := range ids {
+		conditions[i] = fmt.Sprintf("EventID=%d", id)
+	}
+	return fmt.Sprintf("*[System[(%s)]]", strings.Join(conditions, " or "))
+}
+
+// isAvailable returns false if channelPath is not one of the channels found on the system.
+func (l *Launcher) isAvailable(channelPath string) bool {
+	if l.channels == nil {
+		// the channels could not be listed
+		return true
+	}
+	for _, channel := range l.channels {
+		if strings.EqualFold(channel, channelPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// setupTailer configures and starts a new tailer,
+// resuming after the last event committed if any.
 func (l *Launcher) setupTailer(source *config.LogSource) (*Tailer, error) {
-	sanitizedConfig := l.sanitizedConfig(source.Config)
-	config := &Config{sanitizedConfig.ChannelPath, sanitizedConfig.Query}
+	config := l.sanitizedConfig(source.Config)
+	if !l.isAvailable(config.ChannelPath) {
+		log.Warnf("Windows event log channel %s was not found, its events may not be collected", config.ChannelPath)
+	}
 	tailer := NewTailer(source, config, l.pipelineProvider.NextPipelineChan())
-	tailer.Start()
+	tailer.Start(l.registry.GetOffset(tailer.Identifier()))
 	return tailer, nil
 }
@@ -14,6 +14,17 @@ import (
 )
 
 func TestShouldSanitizeConfig(t *testing.T) {
-	launcher := NewLauncher(config.NewLogSources(), nil)
+	launcher := NewLauncher(config.NewLogSources(), nil, nil)
 	assert.Equal(t, "*", launcher.sanitizedConfig(&config.LogsConfig{ChannelPath: "System", Query: ""}).Query)
+	assert.Equal(t, "*[System[(EventID=4624 or EventID=4625)]]", launcher.sanitizedConfig(&config.LogsConfig{ChannelPath: "Security", EventIDs: []int{4624, 4625}}).Query)
+	assert.Equal(t, config.XMLEventFormat, launcher.sanitizedConfig(&config.LogsConfig{ChannelPath: "System", EventFormat: config.XMLEventFormat}).EventFormat)
+}
+
+func TestIsAvailable(t *testing.T) {
+	launcher := NewLauncher(config.NewLogSources(), nil, nil)
+	assert.True(t, launcher.isAvailable("Microsoft-Windows-Sysmon/Operational"))
+
+	launcher.channels = []string{"Application", "Microsoft-Windows-Sysmon/Operational"}
+	assert.True(t, launcher.isAvailable("microsoft-windows-sysmon/operational"))
+	assert.False(t, launcher.isAvailable("Microsoft-Windows-PowerShell/Operational"))
 }
@@ -6,6 +6,8 @@
 package windowsevent
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
@@ -18,6 +20,7 @@ import (
 type Config struct {
 	ChannelPath string
 	Query       string
+	EventFormat string
 }
 
 // eventContext links go and c
@@ -60,18 +63,100 @@ func (t *Tailer) Identifier() string {
 // Map is a convenient structure to convert XML into Json
 type Map map[string]interface{}
 
-// toMessage converts an XML message into json
-func (t *Tailer) toMessage(event string) (*message.Message, error) {
-	log.Debug("Rendered XML: ", event)
-	mxj.PrependAttrWithHyphen(false)
-	mv, err := mxj.NewMapXml([]byte(event))
-	if err != nil {
-		return &message.Message{}, err
+// event holds the fields of an event rendered in XML used as metadata of its message.
+type event struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		}
+		EventID       string
+		Level         string
+		Channel       string
+		EventRecordID string
 	}
-	jsonEvent, err := mv.Json(false)
+}
+
+// parseEvent returns the metadata of an event rendered in XML.
+func parseEvent(rendered string) (*event, error) {
+	e := &event{}
+	err := xml.Unmarshal([]byte(rendered), e)
+	return e, err
+}
+
+// toMessage converts an event rendered in XML into a message holding the event in the format of the tailer,
+// formatted is the message of the event formatted by its provider, the event is sent as JSON when it is empty.
+// The offset of the message is the bookmark of the event so that the tailer resumes after it after a restart.
+func (t *Tailer) toMessage(rendered string, formatted string) (*message.Message, error) {
+	log.Debug("Rendered XML: ", rendered)
+	e, err := parseEvent(rendered)
 	if err != nil {
 		return &message.Message{}, err
 	}
-	log.Debug("Sending JSON: ", string(jsonEvent))
-	return message.NewMessage(jsonEvent, message.NewOrigin(t.source), message.StatusInfo), nil
+	var content []byte
+	switch {
+	case t.config.EventFormat == config.XMLEventFormat:
+		content = []byte(rendered)
+	case t.config.EventFormat == config.MessageEventFormat && formatted != "":
+		content = []byte(formatted)
+	default:
+		mxj.PrependAttrWithHyphen(false)
+		mv, err := mxj.NewMapXml([]byte(rendered))
+		if err != nil {
+			return &message.Message{}, err
+		}
+		content, err = mv.Json(false)
+		if err != nil {
+			return &message.Message{}, err
+		}
+		log.Debug("Sending JSON: ", string(content))
+	}
+	origin := message.NewOrigin(t.source)
+	if bookmark := bookmarkXML(e.System.Channel, e.System.EventRecordID); bookmark != "" {
+		origin.Identifier = t.Identifier()
+		origin.Offset = bookmark
+	}
+	origin.SetTags(eventTags(e))
+	return message.NewMessage(content, origin, levelStatus(e.System.Level)), nil
+}
+
+// eventTags returns the tags holding the provider, the ID and the level of the event.
+func eventTags(e *event) []string {
+	var tags []string
+	if e.System.Provider.Name != "" {
+		tags = append(tags, "event_provider:"+e.System.Provider.Name)
+	}
+	if e.System.EventID != "" {
+		tags = append(tags, "event_id:"+e.System.EventID)
+	}
+	if e.System.Level != "" {
+		tags = append(tags, "event_level:"+e.System.Level)
+	}
+	return tags
+}
+
+// levelStatusMapping maps the levels of the events to the statuses of the messages.
+var levelStatusMapping = map[string]string{
+	"1": message.StatusCritical,
+	"2": message.StatusError,
+	"3": message.StatusWarning,
+	"4": message.StatusInfo,
+	"5": message.StatusDebug,
+}
+
+// levelStatus returns the status of an event of level, info by default.
+func levelStatus(level string) string {
+	if status, exists := levelStatusMapping[level]; exists {
+		return status
+	}
+	return message.StatusInfo
+}
+
+// bookmarkXML returns the bookmark of the event with recordID in channel, empty if one of them is unknown.
+func bookmarkXML(channel, recordID string) string {
+	if channel == "" || recordID == "" {
+		return ""
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(channel))
+	return fmt.Sprintf("<BookmarkList><Bookmark Channel='%s' RecordId='%s' IsCurrent='true'/></BookmarkList>", escaped.String(), recordID)
 }
@@ -9,12 +9,60 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
 )
 
 func TestToMessage(t *testing.T) {
-	tailer := NewTailer(nil, nil, nil)
+	tailer := NewTailer(nil, &Config{ChannelPath: "System", Query: "*"}, nil)
 	evt1 := `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Service Control Manager' Guid='{555908d1-a6d7-4695-8e1e-26931d2012f4}' EventSourceName='Service Control Manager'/><EventID Qualifiers='16384'>7036</EventID><Version>0</Version><Level>4</Level><Task>0</Task><Opcode>0</Opcode><Keywords>0x8080000000000000</Keywords><TimeCreated SystemTime='2013-08-22T14:51:44.205667300Z'/><EventRecordID>2</EventRecordID><Correlation/><Execution ProcessID='516' ThreadID='1792'/><Channel>System</Channel><Computer>windows-n7iefg2</Computer><Security/></System><EventData><Data Name='param1'>Windows Event Log</Data><Data Name='param2'>stopped</Data><Binary>4500760065006E0074004C006F0067002F0031000000</Binary></EventData></Event>`
 	expected1 := `{"Event":{"EventData":{"Binary":"4500760065006E0074004C006F0067002F0031000000","Data":[{"#text":"Windows Event Log","Name":"param1"},{"#text":"stopped","Name":"param2"}]},"System":{"Channel":"System","Computer":"windows-n7iefg2","Correlation":"","EventID":{"#text":"7036","Qualifiers":"16384"},"EventRecordID":"2","Execution":{"ProcessID":"516","ThreadID":"1792"},"Keywords":"0x8080000000000000","Level":"4","Opcode":"0","Provider":{"EventSourceName":"Service Control Manager","Guid":"{555908d1-a6d7-4695-8e1e-26931d2012f4}","Name":"Service Control Manager"},"Security":"","Task":"0","TimeCreated":{"SystemTime":"2013-08-22T14:51:44.205667300Z"},"Version":"0"},"xmlns":"http://schemas.microsoft.com/win/2004/08/events/event"}}`
-	actual, _ := tailer.toMessage(evt1)
+	actual, _ := tailer.toMessage(evt1, "")
 	assert.Equal(t, expected1, string(actual.Content))
 }
+
+// evt2 is an event of the System channel rendered in XML.
+const evt2 = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Service Control Manager'/><EventID Qualifiers='49152'>7023</EventID><Level>2</Level><EventRecordID>42</EventRecordID><Channel>System</Channel></System></Event>`
+
+func TestToMessageMetadata(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	tailer := NewTailer(source, &Config{ChannelPath: "System", Query: "*"}, nil)
+
+	msg, err := tailer.toMessage(evt2, "")
+	assert.Nil(t, err)
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, []string{"event_provider:Service Control Manager", "event_id:7023", "event_level:2"}, msg.Origin.Tags())
+	assert.Equal(t, "eventlog:System;*", msg.Origin.Identifier)
+	assert.Equal(t, "<BookmarkList><Bookmark Channel='System' RecordId='42' IsCurrent='true'/></BookmarkList>", msg.Origin.Offset)
+
+	_, err = tailer.toMessage("<Event>", "")
+	assert.NotNil(t, err)
+}
+
+func TestToMessageFormats(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	tailer := NewTailer(source, &Config{ChannelPath: "System", Query: "*", EventFormat: config.XMLEventFormat}, nil)
+	msg, _ := tailer.toMessage(evt2, "")
+	assert.Equal(t, evt2, string(msg.Content))
+
+	// the formatted message is sent as is, the event is sent as JSON when it could not be formatted
+	tailer.config.EventFormat = config.MessageEventFormat
+	msg, _ = tailer.toMessage(evt2, "The Foo service terminated with the following error.")
+	assert.Equal(t, "The Foo service terminated with the following error.", string(msg.Content))
+	msg, _ = tailer.toMessage(evt2, "")
+	assert.NotEqual(t, evt2, string(msg.Content))
+}
+
+func TestLevelStatus(t *testing.T) {
+	assert.Equal(t, message.StatusCritical, levelStatus("1"))
+	assert.Equal(t, message.StatusError, levelStatus("2"))
+	assert.Equal(t, message.StatusWarning, levelStatus("3"))
+	assert.Equal(t, message.StatusDebug, levelStatus("5"))
+	assert.Equal(t, message.StatusInfo, levelStatus("0"))
+}
+
+func TestBookmarkXML(t *testing.T) {
+	assert.Equal(t, "<BookmarkList><Bookmark Channel='Foo&amp;Bar/Operational' RecordId='42' IsCurrent='true'/></BookmarkList>", bookmarkXML("Foo&Bar/Operational", "42"))
+	assert.Equal(t, "", bookmarkXML("System", ""))
+}
@@ -17,13 +17,15 @@ import (
 	"syscall"
 	"unsafe"
 
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
-// Start starts tailing the event log.
-func (t *Tailer) Start() {
+// Start starts tailing the event log after the event of the bookmark,
+// or from the events raised from now on if the bookmark is empty.
+func (t *Tailer) Start(bookmark string) {
 	log.Infof("Starting windows event log tailing for channel %s query %s", t.config.ChannelPath, t.config.Query)
-	go t.tail()
+	go t.tail(bookmark)
 }
 
 // Stop stops the tailer
@@ -34,15 +36,27 @@ func (t *Tailer) Stop() {
 }
 
 // tail subscribes to the channel for the windows events
-func (t *Tailer) tail() {
+func (t *Tailer) tail(bookmark string) {
 	t.context = &eventContext{
 		id: indexForTailer(t),
 	}
+	flags := EvtSubscribeToFutureEvents
+	var hBookmark uintptr
+	if bookmark != "" {
+		var err error
+		hBookmark, err = EvtCreateBookmark(bookmark)
+		if err != nil {
+			log.Warnf("Could not resume tailing windows event log channel %s from bookmark %s, tailing the new events: %v", t.config.ChannelPath, bookmark, err)
+		} else {
+			flags = EvtSubscribeStartAfterBookmark
+			defer procEvtClose.Call(hBookmark)
+		}
+	}
 	C.startEventSubscribe(
 		C.CString(t.config.ChannelPath),
 		C.CString(t.config.Query),
-		C.ULONGLONG(0),
-		C.int(EvtSubscribeToFutureEvents),
+		C.ULONGLONG(hBookmark),
+		C.int(flags),
 		C.PVOID(uintptr(unsafe.Pointer(t.context))),
 	)
 	t.source.Status.Success()
@@ -85,7 +99,14 @@ func goNotificationCallback(handle C.ULONGLONG, ctx C.PVOID) {
 		log.Warnf("Got invalid eventContext id %s when map is %s", goctx.id, eventContextToTailerMap)
 		return
 	}
-	msg, err := t.toMessage(xml)
+	var formatted string
+	if t.config.EventFormat == config.MessageEventFormat {
+		formatted, err = formatMessage(handle, xml)
+		if err != nil {
+			log.Debugf("Couldn't format the message of event, sending it as JSON: %v", err)
+		}
+	}
+	msg, err := t.toMessage(xml, formatted)
 	if err != nil {
 		log.Warnf("Couldn't convert xml to json: %s for event %s", err, xml)
 		return
@@ -103,8 +124,74 @@ var (
 	procEvtOpenChannelEnum = modWinEvtAPI.NewProc("EvtOpenChannelEnum")
 	procEvtNextChannelPath = modWinEvtAPI.NewProc("EvtNextChannelPath")
 	procEvtNext            = modWinEvtAPI.NewProc("EvtNext")
+
+	procEvtCreateBookmark        = modWinEvtAPI.NewProc("EvtCreateBookmark")
+	procEvtOpenPublisherMetadata = modWinEvtAPI.NewProc("EvtOpenPublisherMetadata")
+	procEvtFormatMessage         = modWinEvtAPI.NewProc("EvtFormatMessage")
 )
 
+// EvtCreateBookmark returns the handle of the bookmark rendered in XML, it must be closed once used.
+func EvtCreateBookmark(bookmark string) (uintptr, error) {
+	bookmarkPtr, err := syscall.UTF16PtrFromString(bookmark)
+	if err != nil {
+		return 0, err
+	}
+	h, _, err := procEvtCreateBookmark.Call(uintptr(unsafe.Pointer(bookmarkPtr)))
+	if h == 0 {
+		return 0, err
+	}
+	return h, nil
+}
+
+// formatMessage returns the message of the event rendered in XML with handle h formatted by its provider.
+func formatMessage(h C.ULONGLONG, xml string) (string, error) {
+	e, err := parseEvent(xml)
+	if err != nil {
+		return "", err
+	}
+	providerPtr, err := syscall.UTF16PtrFromString(e.System.Provider.Name)
+	if err != nil {
+		return "", err
+	}
+	metadata, _, err := procEvtOpenPublisherMetadata.Call(uintptr(0), // local computer
+		uintptr(unsafe.Pointer(providerPtr)),
+		uintptr(0), // the metadata of the provider registered on the computer
+		uintptr(0), // locale of the current thread
+		uintptr(0)) // must be zero
+	if metadata == 0 {
+		return "", err
+	}
+	defer procEvtClose.Call(metadata)
+
+	var bufUsed uint32
+	procEvtFormatMessage.Call(metadata,
+		uintptr(h),
+		uintptr(0), // message id, not used to format the message of an event
+		uintptr(0), // no values, the ones of the event are used
+		uintptr(0),
+		uintptr(EvtFormatMessageEvent),
+		uintptr(0),
+		uintptr(0),                        // no buffer for now, just getting necessary size
+		uintptr(unsafe.Pointer(&bufUsed))) // filled in with necessary buffer size, in characters
+	if bufUsed == 0 {
+		return "", nil
+	}
+	buf := make([]uint16, bufUsed)
+	ret, _, err := procEvtFormatMessage.Call(metadata,
+		uintptr(h),
+		uintptr(0),
+		uintptr(0),
+		uintptr(0),
+		uintptr(EvtFormatMessageEvent),
+		uintptr(bufUsed),
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(unsafe.Pointer(&bufUsed)))
+	if ret == 0 {
+		return "", err
+	}
+	return syscall.UTF16ToString(buf), nil
+}
+
 // EvtRender takes an event handle and reders it to XML
 func EvtRender(h C.ULONGLONG) (xml string, err error) {
 	var bufSize uint32
@@ -155,6 +242,8 @@ const (
 	EvtRenderEventXml    = 1 // XML
 	EvtRenderBookmark    = 2 // Bookmark
 
+	EvtFormatMessageEvent = 1 // the message of the event
+
 	ERROR_NO_MORE_ITEMS syscall.Errno = 259
 )
 
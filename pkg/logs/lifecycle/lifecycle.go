@@ -0,0 +1,156 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package lifecycle
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// Reasons why a message is dropped.
+const (
+	// DropRateLimited is when the source of the message exceeded its logs per second.
+	DropRateLimited = "rate_limited"
+	// DropSampled is when the message is dropped by the adaptive sampling.
+	DropSampled = "sampled"
+	// DropDuplicate is when the message is identical to a message of its source recently sent.
+	DropDuplicate = "duplicate"
+	// DropFiltered is when the message is excluded by a processing rule of its source.
+	DropFiltered = "filtered"
+	// DropEncodingFailed is when the message could not be encoded.
+	DropEncodingFailed = "encoding_failed"
+	// DropRejected is when the message is rejected by the pre-send hook.
+	DropRejected = "rejected"
+	// DropExpired is when the message is too old to be sent.
+	DropExpired = "expired"
+	// DropCircuitOpen is when the circuit breaker of the destination is open.
+	DropCircuitOpen = "circuit_open"
+	// DropUndeliverable is when the message can not be sent, Err holds why.
+	DropUndeliverable = "undeliverable"
+)
+
+// Outcome describes what happened to a message at a step of the pipeline.
+type Outcome struct {
+	// Reason is why the message was dropped, empty otherwise.
+	Reason string
+	// Destination is the address of the destination the message was sent to when it is known, empty otherwise.
+	Destination string
+	// Err is the error which made the message undeliverable, nil otherwise.
+	Err error
+}
+
+// A Hook is notified of the messages at the steps of the pipeline:
+// OnProcessed once a message has been processed and encoded, right before it is handed to the sender,
+// OnSent once it has been sent to the main destination and OnDropped when it is dropped instead,
+// by the processor or the sender.
+// Each hook is notified from its own goroutine through a buffer of config.ChanSize events,
+// when the hook is too slow the buffer fills up and the new events are dropped for this hook only,
+// which never slows down the pipeline.
+// The messages given are copies taken when the events happened, they must not be modified,
+// their origin is shared with the rest of the pipeline.
+type Hook interface {
+	OnProcessed(msg *message.Message, outcome Outcome)
+	OnSent(msg *message.Message, outcome Outcome)
+	OnDropped(msg *message.Message, outcome Outcome)
+}
+
+// Kinds of events.
+const (
+	processed = iota
+	sent
+	dropped
+)
+
+// event is a step of the pipeline reached by a message.
+type event struct {
+	kind    int
+	msg     *message.Message
+	outcome Outcome
+}
+
+// hookWorker notifies a hook of the events of its buffer.
+type hookWorker struct {
+	hook      Hook
+	eventChan chan event
+}
+
+var (
+	// hooks holds the list of hook workers, it is copied on write
+	// to be read without locking for every message.
+	hooks   atomic.Value
+	hooksMu sync.Mutex
+)
+
+func init() {
+	hooks.Store([]*hookWorker{})
+}
+
+// RegisterHook registers hook to be notified of the steps reached by all the messages
+// for the lifetime of the process.
+func RegisterHook(hook Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	worker := &hookWorker{
+		hook:      hook,
+		eventChan: make(chan event, config.ChanSize),
+	}
+	go worker.run()
+	workers := hooks.Load().([]*hookWorker)
+	newWorkers := make([]*hookWorker, len(workers), len(workers)+1)
+	copy(newWorkers, workers)
+	hooks.Store(append(newWorkers, worker))
+}
+
+// Processed notifies the hooks that msg has been processed.
+func Processed(msg *message.Message) {
+	notify(processed, msg, Outcome{})
+}
+
+// Sent notifies the hooks that msg has been sent to destination.
+func Sent(msg *message.Message, destination string) {
+	notify(sent, msg, Outcome{Destination: destination})
+}
+
+// Dropped notifies the hooks that msg has been dropped for reason.
+func Dropped(msg *message.Message, reason string, err error) {
+	notify(dropped, msg, Outcome{Reason: reason, Err: err})
+}
+
+// notify hands the event to all the hooks without blocking, it does nothing when no hook is registered.
+func notify(kind int, msg *message.Message, outcome Outcome) {
+	workers := hooks.Load().([]*hookWorker)
+	if len(workers) == 0 {
+		return
+	}
+	// the message keeps changing through the pipeline while the hooks read it
+	snapshot := *msg
+	for _, worker := range workers {
+		select {
+		case worker.eventChan <- event{kind: kind, msg: &snapshot, outcome: outcome}:
+		default:
+			// the hook is too slow
+			metrics.LifecycleHookDrops.Add(1)
+		}
+	}
+}
+
+// run notifies the hook of the events of its buffer.
+func (w *hookWorker) run() {
+	for e := range w.eventChan {
+		switch e.kind {
+		case processed:
+			w.hook.OnProcessed(e.msg, e.outcome)
+		case sent:
+			w.hook.OnSent(e.msg, e.outcome)
+		case dropped:
+			w.hook.OnDropped(e.msg, e.outcome)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package lifecycle
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// notification is an event a chanHook was notified of.
+type notification struct {
+	kind    string
+	msg     *message.Message
+	outcome Outcome
+}
+
+// chanHook forwards the events it is notified of to notifications.
+type chanHook struct {
+	notifications chan notification
+}
+
+func (h *chanHook) OnProcessed(msg *message.Message, outcome Outcome) {
+	h.notifications <- notification{"processed", msg, outcome}
+}
+
+func (h *chanHook) OnSent(msg *message.Message, outcome Outcome) {
+	h.notifications <- notification{"sent", msg, outcome}
+}
+
+func (h *chanHook) OnDropped(msg *message.Message, outcome Outcome) {
+	h.notifications <- notification{"dropped", msg, outcome}
+}
+
+func resetHooks() {
+	hooks.Store([]*hookWorker{})
+}
+
+func newMessage(content string) *message.Message {
+	return message.NewMessage([]byte(content), message.NewOrigin(config.NewLogSource("", &config.LogsConfig{})), "")
+}
+
+func TestNotifyWithoutHooksIsANoop(t *testing.T) {
+	drops := metrics.LifecycleHookDrops.Value()
+	Processed(newMessage("foo"))
+	Sent(newMessage("foo"), "intake:10516")
+	Dropped(newMessage("foo"), DropExpired, nil)
+	assert.Equal(t, drops, metrics.LifecycleHookDrops.Value())
+}
+
+func TestHooksAreNotifiedOfTheEventsInOrder(t *testing.T) {
+	defer resetHooks()
+	hook := &chanHook{notifications: make(chan notification, 3)}
+	RegisterHook(hook)
+
+	msg := newMessage("foo")
+	Processed(msg)
+	// the hooks are given the message as it was when the event happened
+	msg.Content = []byte("encoded foo")
+	Sent(msg, "intake:10516")
+	err := errors.New("connection reset")
+	Dropped(msg, DropUndeliverable, err)
+
+	n := <-hook.notifications
+	assert.Equal(t, "processed", n.kind)
+	assert.Equal(t, "foo", string(n.msg.Content))
+	assert.Equal(t, msg.Origin, n.msg.Origin)
+	assert.Equal(t, Outcome{}, n.outcome)
+	n = <-hook.notifications
+	assert.Equal(t, "sent", n.kind)
+	assert.Equal(t, "encoded foo", string(n.msg.Content))
+	assert.Equal(t, Outcome{Destination: "intake:10516"}, n.outcome)
+	n = <-hook.notifications
+	assert.Equal(t, "dropped", n.kind)
+	assert.Equal(t, Outcome{Reason: DropUndeliverable, Err: err}, n.outcome)
+}
+
+func TestSlowHookDoesNotBlockNotifications(t *testing.T) {
+	defer resetHooks()
+	// the hook never returns
+	RegisterHook(&chanHook{notifications: make(chan notification)})
+
+	drops := metrics.LifecycleHookDrops.Value()
+	msg := newMessage("foo")
+	for i := 0; i < config.ChanSize+2; i++ {
+		Processed(msg)
+	}
+	// one event is held by the hook, the buffer is full
+	assert.True(t, metrics.LifecycleHookDrops.Value() >= drops+1)
+	assert.True(t, metrics.LifecycleHookDrops.Value() <= drops+2)
+}
+
+func TestRegisterHookKeepsPreviousHooks(t *testing.T) {
+	defer resetHooks()
+	RegisterHook(&chanHook{})
+	workers := hooks.Load().([]*hookWorker)
+	RegisterHook(&chanHook{})
+	assert.Equal(t, 1, len(workers))
+	assert.Equal(t, 2, len(hooks.Load().([]*hookWorker)))
+}
@@ -6,8 +6,12 @@
 package logs
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
+	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/scheduler"
 	"github.com/DataDog/datadog-agent/pkg/logs/service"
@@ -22,6 +26,8 @@ var (
 	// scheduler is plugged to autodiscovery to collect integration configs
 	// and schedule log collection for different kind of inputs
 	adScheduler *scheduler.Scheduler
+	// terminalFailureHandler is called when logs-agent gives up sending the logs
+	terminalFailureHandler func(err error)
 )
 
 // Start starts logs-agent
@@ -35,6 +41,15 @@ func Start() error {
 		return err
 	}
 
+	// make sure that the offsets can be persisted if required,
+	// otherwise the auditor falls back to an in-memory registry
+	if config.LogsAgent.GetBool("logs_config.require_writable_run_path") {
+		err = auditor.CheckRunPath(config.LogsAgent.GetString("logs_config.run_path"))
+		if err != nil {
+			return err
+		}
+	}
+
 	// setup the sources and the services
 	sources := config.NewLogSources()
 	services := service.NewServices()
@@ -42,11 +57,14 @@ func Start() error {
 	// initialize the config scheduler
 	adScheduler = scheduler.NewScheduler(sources, services)
 
-	// setup the status
-	status.Initialize(sources)
-
-	// setup and start the agent
+	// setup the agent and the status
 	agent = NewAgent(sources, services, endpoints)
+	if terminalFailureHandler != nil {
+		agent.OnTerminalFailure(terminalFailureHandler)
+	}
+	status.Initialize(sources, agent.Health)
+
+	// start the agent
 	log.Info("Starting logs-agent...")
 	agent.Start()
 	isRunning = true
@@ -66,7 +84,12 @@ func Stop() {
 	log.Info("Stopping logs-agent")
 	if isRunning {
 		if agent != nil {
-			agent.Stop()
+			summary := agent.Stop()
+			if summary.TimedOut {
+				log.Warnf("logs-agent did not flush all the logs in flight, %d logs were sent and %d dropped while stopping", summary.Delivered, summary.Dropped)
+			} else {
+				log.Infof("%d logs were sent and %d dropped while stopping logs-agent", summary.Delivered, summary.Dropped)
+			}
 			agent = nil
 		}
 		if adScheduler != nil {
@@ -79,6 +102,13 @@ func Stop() {
 	log.Info("logs-agent stopped")
 }
 
+// SetTerminalFailureHandler sets the handler called when logs-agent gives up sending the logs
+// after logs_config.sender.max_reconnect_attempts, for example to stop the agent or exit,
+// it is taken into account by the next start of logs-agent.
+func SetTerminalFailureHandler(handler func(err error)) {
+	terminalFailureHandler = handler
+}
+
 // IsAgentRunning returns true if the logs-agent is running.
 func IsAgentRunning() bool {
 	return isRunning
@@ -92,6 +122,37 @@ func GetStatus() status.Status {
 	return status.Get()
 }
 
+// GetRegistry returns a snapshot of the offsets committed by the auditor by identifier,
+// nil if logs-agent is not running.
+func GetRegistry() map[string]auditor.RegistryEntry {
+	if !IsAgentRunning() || agent == nil {
+		return nil
+	}
+	return agent.auditor.Snapshot()
+}
+
+// ScalePipelines adds or removes pipelines until there are numberOfPipelines of them,
+// returns an error if logs-agent is not running.
+func ScalePipelines(numberOfPipelines int) error {
+	if !IsAgentRunning() || agent == nil {
+		return fmt.Errorf("logs-agent is not running")
+	}
+	if numberOfPipelines < 1 {
+		return fmt.Errorf("the number of pipelines must be positive: %d", numberOfPipelines)
+	}
+	agent.ScalePipelines(numberOfPipelines)
+	return nil
+}
+
+// Flush blocks until the logs in flight in logs-agent are sent, without stopping it,
+// returns an error if logs-agent is not running or if ctx is done first.
+func Flush(ctx context.Context) error {
+	if !IsAgentRunning() || agent == nil {
+		return fmt.Errorf("logs-agent is not running")
+	}
+	return agent.Flush(ctx)
+}
+
 // GetScheduler returns the logs-config scheduler if set.
 func GetScheduler() *scheduler.Scheduler {
 	return adScheduler
@@ -5,6 +5,10 @@
 
 package message
 
+import (
+	"time"
+)
+
 // Message represents a log line sent to datadog, with its metadata
 type Message struct {
 	Content    []byte
@@ -12,6 +16,27 @@ type Message struct {
 	status     string
 	Timestamp  string
 	RawDataLen int
+	// Sequence is the number of the message among the processed messages of its source.
+	Sequence uint64
+	// Attributes are the structured fields parsed from the content,
+	// the dots of their keys denote nested attributes.
+	Attributes map[string]interface{}
+	// OriginalLen is the length of the content before it was truncated by the processor,
+	// 0 if it was not truncated.
+	OriginalLen int
+	// Chunk is the part of the message this message is when it was split by the processor, nil if it was not split.
+	Chunk *Chunk
+	// EnqueuedAt is when the message entered its pipeline, the zero time when unknown,
+	// as for the messages replayed from the disk buffer.
+	EnqueuedAt time.Time
+}
+
+// Chunk is a part of a message too large to be sent at once, the parts of a message share the same group
+// and are numbered from 0 to count-1, their contents put together in order are the content of the message.
+type Chunk struct {
+	Group string
+	Index int
+	Count int
 }
 
 // NewMessage returns a new message
@@ -23,14 +48,20 @@ func NewMessage(content []byte, origin *Origin, status string) *Message {
 	}
 }
 
-// GetStatus returns the status of the message
+// GetStatus returns the status of the message, info when it has none
 func (m *Message) GetStatus() string {
 	if m.status == "" {
-		m.status = StatusInfo
+		return StatusInfo
 	}
 	return m.status
 }
 
+// HasStatus returns true if the status of the message was set,
+// the messages without status get the default status of their source
+func (m *Message) HasStatus() bool {
+	return m.status != ""
+}
+
 // SetStatus sets the status of the message
 func (m *Message) SetStatus(status string) {
 	m.status = status
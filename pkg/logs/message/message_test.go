@@ -19,5 +19,9 @@ func TestMessage(t *testing.T) {
 	message.Content = []byte("world")
 	assert.Equal(t, "world", string(message.Content))
 	assert.Equal(t, StatusInfo, message.GetStatus())
+	assert.False(t, message.HasStatus())
 
+	message.SetStatus(StatusError)
+	assert.Equal(t, StatusError, message.GetStatus())
+	assert.True(t, message.HasStatus())
 }
@@ -19,6 +19,7 @@ type Origin struct {
 	service    string
 	source     string
 	tags       []string
+	hostTags   []string
 }
 
 // NewOrigin returns a new Origin
@@ -38,7 +39,7 @@ func (o *Origin) Tags() []string {
 	}
 
 	tags = append(tags, o.LogSource.Config.Tags...)
-	return tags
+	return appendHostTags(tags, o.hostTags)
 }
 
 // TagsPayload returns the raw tag payload of the origin.
@@ -57,6 +58,7 @@ func (o *Origin) TagsPayload() []byte {
 	var tags []string
 	tags = append(tags, o.LogSource.Config.Tags...)
 	tags = append(tags, o.tags...)
+	tags = appendHostTags(tags, o.hostTags)
 
 	if len(tags) > 0 {
 		tagsPayload = append(tagsPayload, []byte("[dd ddtags=\""+strings.Join(tags, ",")+"\"]")...)
@@ -72,6 +74,34 @@ func (o *Origin) SetTags(tags []string) {
 	o.tags = tags
 }
 
+// AddTags adds tags to the tags of the origin, the copies of the origin keep their own tags.
+func (o *Origin) AddTags(tags ...string) {
+	o.tags = append(append(make([]string, 0, len(o.tags)+len(tags)), o.tags...), tags...)
+}
+
+// SetHostTags sets the tags of the host the message was collected on,
+// they are merged with the other tags of the origin.
+func (o *Origin) SetHostTags(tags []string) {
+	o.hostTags = tags
+}
+
+// appendHostTags appends to tags the host tags which are not in tags already.
+func appendHostTags(tags []string, hostTags []string) []string {
+	if len(hostTags) == 0 {
+		return tags
+	}
+	present := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		present[tag] = true
+	}
+	for _, tag := range hostTags {
+		if !present[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 // SetSource sets the source of the origin.
 func (o *Origin) SetSource(source string) {
 	o.source = source
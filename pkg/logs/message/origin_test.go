@@ -58,6 +58,19 @@ func TestSetTagsWithConfigTags(t *testing.T) {
 	assert.Equal(t, "[dd ddsource=\"a\"][dd ddsourcecategory=\"b\"][dd ddtags=\"c:d,e,foo:bar,baz\"]", string(origin.TagsPayload()))
 }
 
+func TestHostTagsAreMergedWithTheOtherTags(t *testing.T) {
+	cfg := &config.LogsConfig{
+		Source: "a",
+		Tags:   []string{"c:d", "zone:us-east-1a"},
+	}
+	source := config.NewLogSource("", cfg)
+	origin := NewOrigin(source)
+	origin.SetTags([]string{"foo:bar"})
+	origin.SetHostTags([]string{"zone:us-east-1a", "instance-type:m5.large"})
+	assert.Equal(t, []string{"foo:bar", "c:d", "zone:us-east-1a", "instance-type:m5.large"}, origin.Tags())
+	assert.Equal(t, "[dd ddsource=\"a\"][dd ddtags=\"c:d,zone:us-east-1a,foo:bar,instance-type:m5.large\"]", string(origin.TagsPayload()))
+}
+
 func TestDefaultSourceValueIsSourceFromConfig(t *testing.T) {
 	var cfg *config.LogsConfig
 	var source *config.LogSource
@@ -101,3 +114,16 @@ func TestDefaultServiceValueIsServiceFromConfig(t *testing.T) {
 	origin.SetService("bar")
 	assert.Equal(t, "bar", origin.Service())
 }
+
+func TestAddTagsDoesNotChangeTheTagsOfTheCopies(t *testing.T) {
+	origin := NewOrigin(config.NewLogSource("", &config.LogsConfig{}))
+	origin.SetTags(make([]string, 1, 10))
+	origin.tags[0] = "foo:bar"
+
+	first, second := *origin, *origin
+	first.AddTags("chunk_index:0")
+	second.AddTags("chunk_index:1")
+	assert.Equal(t, []string{"foo:bar"}, origin.Tags())
+	assert.Equal(t, []string{"foo:bar", "chunk_index:0"}, first.Tags())
+	assert.Equal(t, []string{"foo:bar", "chunk_index:1"}, second.Tags())
+}
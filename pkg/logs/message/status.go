@@ -5,6 +5,10 @@
 
 package message
 
+import (
+	"strings"
+)
+
 // Status values
 const (
 	StatusEmergency = "emergency"
@@ -17,6 +21,43 @@ const (
 	StatusDebug     = "debug"
 )
 
+// statusAliases maps the usual severity names, lower cased, to the statuses.
+var statusAliases = map[string]string{
+	"emerg":         StatusEmergency,
+	"emergency":     StatusEmergency,
+	"panic":         StatusEmergency,
+	"alert":         StatusAlert,
+	"crit":          StatusCritical,
+	"critical":      StatusCritical,
+	"fatal":         StatusCritical,
+	"err":           StatusError,
+	"error":         StatusError,
+	"severe":        StatusError,
+	"warn":          StatusWarning,
+	"warning":       StatusWarning,
+	"wrn":           StatusWarning,
+	"notice":        StatusNotice,
+	"info":          StatusInfo,
+	"inf":           StatusInfo,
+	"information":   StatusInfo,
+	"informational": StatusInfo,
+	"debug":         StatusDebug,
+	"dbg":           StatusDebug,
+	"fine":          StatusDebug,
+	"trace":         StatusDebug,
+}
+
+// ToStatus returns the status of the severity name level, which is looked up in aliases first,
+// whose keys must be lower cased, then in the usual severity names, regardless of its case.
+func ToStatus(level string, aliases map[string]string) (string, bool) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if status, exists := aliases[level]; exists {
+		return status, true
+	}
+	status, exists := statusAliases[level]
+	return status, exists
+}
+
 // Syslog severity levels
 var (
 	SevEmergency = []byte("<40>")
@@ -33,3 +33,38 @@ func TestStatusToSeverity(t *testing.T) {
 	// default value should be "info"
 	assert.Equal(t, 0, bytes.Compare(SevInfo, StatusToSeverity("foo")))
 }
+
+func TestToStatus(t *testing.T) {
+	for level, expected := range map[string]string{
+		"WARNING":  StatusWarning,
+		"Warn":     StatusWarning,
+		"ERR":      StatusError,
+		" error ":  StatusError,
+		"FATAL":    StatusCritical,
+		"emerg":    StatusEmergency,
+		"Notice":   StatusNotice,
+		"INF":      StatusInfo,
+		"trace":    StatusDebug,
+		"DEBUG":    StatusDebug,
+		"critical": StatusCritical,
+	} {
+		status, ok := ToStatus(level, nil)
+		assert.True(t, ok, level)
+		assert.Equal(t, expected, status, level)
+	}
+
+	_, ok := ToStatus("verbose", nil)
+	assert.False(t, ok)
+
+	// the aliases extend and override the usual names
+	aliases := map[string]string{"verbose": StatusDebug, "fatal": StatusEmergency}
+	status, ok := ToStatus("VERBOSE", aliases)
+	assert.True(t, ok)
+	assert.Equal(t, StatusDebug, status)
+	status, ok = ToStatus("Fatal", aliases)
+	assert.True(t, ok)
+	assert.Equal(t, StatusEmergency, status)
+	status, ok = ToStatus("warn", aliases)
+	assert.True(t, ok)
+	assert.Equal(t, StatusWarning, status)
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Backlog holds the number of logs buffered waiting to be sent and when the oldest log being sent
+// entered its pipeline, the zero time if no log is being sent or if it is unknown.
+type Backlog struct {
+	Buffered int64
+	Oldest   time.Time
+}
+
+// Add returns the logs buffered by b and other, the oldest of their oldest logs.
+func (b Backlog) Add(other Backlog) Backlog {
+	sum := Backlog{Buffered: b.Buffered + other.Buffered, Oldest: b.Oldest}
+	if sum.Oldest.IsZero() || (!other.Oldest.IsZero() && other.Oldest.Before(sum.Oldest)) {
+		sum.Oldest = other.Oldest
+	}
+	return sum
+}
+
+// OldestAge returns how long the oldest log has been waiting at now, 0 if no log is being sent.
+func (b Backlog) OldestAge(now time.Time) time.Duration {
+	if b.Oldest.IsZero() || now.Before(b.Oldest) {
+		return 0
+	}
+	return now.Sub(b.Oldest)
+}
+
+// BacklogCounter counts the logs held by a sender until they are sent or dropped,
+// the logs are held in order and released all at once, the oldest one is then the first one held.
+// The counters are updated atomically so that the hot path never waits for a lock.
+type BacklogCounter struct {
+	held int64
+	// oldest is the time in nanoseconds since epoch the oldest log held entered its pipeline, 0 if unknown
+	oldest int64
+}
+
+// NewBacklogCounter returns a new counter.
+func NewBacklogCounter() *BacklogCounter {
+	return &BacklogCounter{}
+}
+
+// Hold counts a log which entered its pipeline at enqueuedAt, the zero time if unknown.
+func (c *BacklogCounter) Hold(enqueuedAt time.Time) {
+	atomic.AddInt64(&c.held, 1)
+	if !enqueuedAt.IsZero() {
+		atomic.CompareAndSwapInt64(&c.oldest, 0, enqueuedAt.UnixNano())
+	}
+}
+
+// ReleaseAll forgets the logs held.
+func (c *BacklogCounter) ReleaseAll() {
+	atomic.StoreInt64(&c.oldest, 0)
+	atomic.StoreInt64(&c.held, 0)
+}
+
+// Value returns the logs held.
+func (c *BacklogCounter) Value() Backlog {
+	backlog := Backlog{Buffered: atomic.LoadInt64(&c.held)}
+	if oldest := atomic.LoadInt64(&c.oldest); oldest != 0 {
+		backlog.Oldest = time.Unix(0, oldest)
+	}
+	return backlog
+}
@@ -0,0 +1,45 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBacklogCounter(t *testing.T) {
+	counter := NewBacklogCounter()
+	assert.Equal(t, Backlog{}, counter.Value())
+
+	first := time.Unix(100, 0)
+	// the logs replayed from disk are counted without being dated
+	counter.Hold(time.Time{})
+	counter.Hold(first)
+	counter.Hold(first.Add(time.Second))
+	backlog := counter.Value()
+	assert.Equal(t, int64(3), backlog.Buffered)
+	assert.True(t, first.Equal(backlog.Oldest))
+
+	counter.ReleaseAll()
+	assert.Equal(t, Backlog{}, counter.Value())
+}
+
+func TestBacklogAdd(t *testing.T) {
+	older, newer := time.Unix(100, 0), time.Unix(200, 0)
+	assert.Equal(t, Backlog{Buffered: 3, Oldest: older}, Backlog{Buffered: 1, Oldest: newer}.Add(Backlog{Buffered: 2, Oldest: older}))
+	assert.Equal(t, Backlog{Buffered: 3, Oldest: older}, Backlog{Buffered: 1, Oldest: older}.Add(Backlog{Buffered: 2, Oldest: newer}))
+	assert.Equal(t, Backlog{Buffered: 3, Oldest: newer}, Backlog{Buffered: 1}.Add(Backlog{Buffered: 2, Oldest: newer}))
+	assert.Equal(t, Backlog{Buffered: 3, Oldest: newer}, Backlog{Buffered: 1, Oldest: newer}.Add(Backlog{Buffered: 2}))
+}
+
+func TestBacklogOldestAge(t *testing.T) {
+	now := time.Unix(100, 0)
+	assert.Equal(t, time.Duration(0), Backlog{}.OldestAge(now))
+	assert.Equal(t, 5*time.Second, Backlog{Oldest: now.Add(-5 * time.Second)}.OldestAge(now))
+	assert.Equal(t, time.Duration(0), Backlog{Oldest: now.Add(time.Second)}.OldestAge(now))
+}
@@ -16,10 +16,78 @@ var (
 	LogsDecoded = expvar.Int{}
 	// LogsProcessed is the total number of processed logs.
 	LogsProcessed = expvar.Int{}
+	// LogsExpired is the total number of logs dropped because they were too old to be sent.
+	LogsExpired = expvar.Int{}
+	// LogsTruncated is the total number of logs truncated because they were too large to be received or sent.
+	LogsTruncated = expvar.Int{}
+	// LogsSplit is the total number of logs split into parts because they were too large.
+	LogsSplit = expvar.Int{}
+	// LogsNotJSON is the total number of logs left as is by a json processing rule because they are not JSON objects.
+	LogsNotJSON = expvar.Int{}
+	// LogsRateLimited is the number of logs dropped because their source exceeded its logs per second, per source name.
+	LogsRateLimited = expvar.Map{}
+	// LogsSampled is the total number of logs dropped by the adaptive sampling.
+	LogsSampled = expvar.Int{}
+	// LogsDeduplicated is the total number of logs dropped because they were identical to a log of their source recently sent.
+	LogsDeduplicated = expvar.Int{}
+	// SamplingRate is the share of the logs eligible to the adaptive sampling last kept.
+	SamplingRate = expvar.Float{}
+	// LogsRejected is the total number of logs dropped by the pre-send hook.
+	LogsRejected = expvar.Int{}
+	// LogsRejectedByIntake is the total number of logs dropped because an HTTP intake rejected them permanently.
+	LogsRejectedByIntake = expvar.Int{}
 	// LogsSent is the total number of sent logs.
 	LogsSent = expvar.Int{}
 	// DestinationErrors is the total number of network errors.
 	DestinationErrors = expvar.Int{}
+	// LifecycleHookDrops is the total number of events the lifecycle hooks were too slow to be notified of.
+	LifecycleHookDrops = expvar.Int{}
+	// DestinationDrops is the number of logs dropped because an additional destination did not keep up,
+	// per destination address.
+	DestinationDrops = expvar.Map{}
+	// CircuitBreakerDrops is the number of logs dropped without being sent because the circuit breaker
+	// of their destination was open, per destination address.
+	CircuitBreakerDrops = expvar.Map{}
+	// ReconnectsInProgress is the number of connection attempts to the destinations currently in progress.
+	ReconnectsInProgress = expvar.Int{}
+	// CollectionLagBytes is the number of bytes left to read in the files tailed, per source path.
+	CollectionLagBytes = expvar.Map{}
+	// DiskBufferDrops is the total number of logs dropped from the disk buffer to make room for newer ones.
+	DiskBufferDrops = expvar.Int{}
+	// ShortWrites is the total number of writes to the destinations which were only partially accepted
+	// by the connection and completed by another write.
+	ShortWrites = expvar.Int{}
+	// WriteErrorReconnects is the total number of connections to the destinations closed to be re-established
+	// because a write failed.
+	WriteErrorReconnects = expvar.Int{}
+	// SlowConsumerTimeouts is the total number of writes to the destinations which did not complete
+	// before the write timeout, the intake accepting the connection but not reading from it.
+	SlowConsumerTimeouts = expvar.Int{}
+	// ConnectionsRecycled is the total number of connections closed and re-established between two writes
+	// because they reached their max age or max idle time.
+	ConnectionsRecycled = expvar.Int{}
+	// LogsTimestampNotParsed is the total number of logs without any timestamp in the format of their timestamp rule.
+	LogsTimestampNotParsed = expvar.Int{}
+	// LogsStatusNotParsed is the total number of logs without any known severity found by their status rule.
+	LogsStatusNotParsed = expvar.Int{}
+	// LogsSampledOut is the total number of logs dropped by the sampling rules of their source.
+	LogsSampledOut = expvar.Int{}
+	// LogsOverflowed is the number of logs dropped because the pipelines were full, per source name,
+	// when the overflow policy drops logs instead of blocking the inputs.
+	LogsOverflowed = expvar.Map{}
+	// FilesQueued is the number of files matching the sources which are not tailed because of the limit of open files.
+	FilesQueued = expvar.Int{}
+	// InputBufferUtilization is the max and average fill level of the input buffers of the pipelines,
+	// in percentage of their capacity, last sampled.
+	InputBufferUtilization = expvar.Map{}
+	// SenderBufferUtilization is the max and average fill level of the sender buffers of the pipelines,
+	// in percentage of their capacity, last sampled.
+	SenderBufferUtilization = expvar.Map{}
+	// LogsBuffered is the number of logs buffered by the pipelines waiting to be sent, last sampled.
+	LogsBuffered = expvar.Int{}
+	// OldestLogAge is the time in seconds the oldest log being sent by the pipelines has been waiting
+	// since it entered its pipeline, last sampled, it keeps growing while the logs can not be sent.
+	OldestLogAge = expvar.Float{}
 	// TODO: Add LogsCollected for the total number of collected logs.
 )
 
@@ -27,6 +95,36 @@ func init() {
 	LogsExpvars = expvar.NewMap("logs-agent")
 	LogsExpvars.Set("LogsDecoded", &LogsDecoded)
 	LogsExpvars.Set("LogsProcessed", &LogsProcessed)
+	LogsExpvars.Set("LogsExpired", &LogsExpired)
+	LogsExpvars.Set("LogsTruncated", &LogsTruncated)
+	LogsExpvars.Set("LogsSplit", &LogsSplit)
+	LogsExpvars.Set("LogsNotJSON", &LogsNotJSON)
+	LogsExpvars.Set("LogsRateLimited", LogsRateLimited.Init())
+	LogsExpvars.Set("LogsSampled", &LogsSampled)
+	LogsExpvars.Set("LogsDeduplicated", &LogsDeduplicated)
+	LogsExpvars.Set("SamplingRate", &SamplingRate)
+	SamplingRate.Set(1)
+	LogsExpvars.Set("LogsRejected", &LogsRejected)
+	LogsExpvars.Set("LogsRejectedByIntake", &LogsRejectedByIntake)
 	LogsExpvars.Set("LogsSent", &LogsSent)
 	LogsExpvars.Set("DestinationErrors", &DestinationErrors)
+	LogsExpvars.Set("LifecycleHookDrops", &LifecycleHookDrops)
+	LogsExpvars.Set("DestinationDrops", DestinationDrops.Init())
+	LogsExpvars.Set("CircuitBreakerDrops", CircuitBreakerDrops.Init())
+	LogsExpvars.Set("ReconnectsInProgress", &ReconnectsInProgress)
+	LogsExpvars.Set("CollectionLagBytes", CollectionLagBytes.Init())
+	LogsExpvars.Set("DiskBufferDrops", &DiskBufferDrops)
+	LogsExpvars.Set("ShortWrites", &ShortWrites)
+	LogsExpvars.Set("WriteErrorReconnects", &WriteErrorReconnects)
+	LogsExpvars.Set("SlowConsumerTimeouts", &SlowConsumerTimeouts)
+	LogsExpvars.Set("ConnectionsRecycled", &ConnectionsRecycled)
+	LogsExpvars.Set("FilesQueued", &FilesQueued)
+	LogsExpvars.Set("LogsTimestampNotParsed", &LogsTimestampNotParsed)
+	LogsExpvars.Set("LogsStatusNotParsed", &LogsStatusNotParsed)
+	LogsExpvars.Set("LogsSampledOut", &LogsSampledOut)
+	LogsExpvars.Set("LogsOverflowed", LogsOverflowed.Init())
+	LogsExpvars.Set("InputBufferUtilization", InputBufferUtilization.Init())
+	LogsExpvars.Set("SenderBufferUtilization", SenderBufferUtilization.Init())
+	LogsExpvars.Set("LogsBuffered", &LogsBuffered)
+	LogsExpvars.Set("OldestLogAge", &OldestLogAge)
 }
@@ -12,5 +12,5 @@ import (
 )
 
 func TestMetrics(t *testing.T) {
-	assert.Equal(t, LogsExpvars.String(), `{"DestinationErrors": 0, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0}`)
+	assert.Equal(t, LogsExpvars.String(), `{"CircuitBreakerDrops": {}, "CollectionLagBytes": {}, "ConnectionsRecycled": 0, "DestinationDrops": {}, "DestinationErrors": 0, "DiskBufferDrops": 0, "FilesQueued": 0, "InputBufferUtilization": {}, "LifecycleHookDrops": 0, "LogsBuffered": 0, "LogsDecoded": 0, "LogsDeduplicated": 0, "LogsExpired": 0, "LogsNotJSON": 0, "LogsOverflowed": {}, "LogsProcessed": 0, "LogsRateLimited": {}, "LogsRejected": 0, "LogsRejectedByIntake": 0, "LogsSampled": 0, "LogsSampledOut": 0, "LogsSent": 0, "LogsSplit": 0, "LogsStatusNotParsed": 0, "LogsTimestampNotParsed": 0, "LogsTruncated": 0, "OldestLogAge": 0, "ReconnectsInProgress": 0, "SamplingRate": 1, "SenderBufferUtilization": {}, "ShortWrites": 0, "SlowConsumerTimeouts": 0, "WriteErrorReconnects": 0}`)
 }
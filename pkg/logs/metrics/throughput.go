@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package metrics
+
+import (
+	"sync/atomic"
+)
+
+// Throughput holds the number of logs processed, truncated, sent and dropped and the number of bytes sent.
+type Throughput struct {
+	Processed int64
+	Truncated int64
+	Sent      int64
+	BytesSent int64
+	Dropped   int64
+}
+
+// Add returns the sum of t and other.
+func (t Throughput) Add(other Throughput) Throughput {
+	return Throughput{
+		Processed: t.Processed + other.Processed,
+		Truncated: t.Truncated + other.Truncated,
+		Sent:      t.Sent + other.Sent,
+		BytesSent: t.BytesSent + other.BytesSent,
+		Dropped:   t.Dropped + other.Dropped,
+	}
+}
+
+// ThroughputCounter counts the logs flowing through a part of the pipeline,
+// the counters are updated atomically so that the hot path never waits for a lock.
+// A nil counter counts nothing.
+type ThroughputCounter struct {
+	value Throughput
+}
+
+// NewThroughputCounter returns a new counter.
+func NewThroughputCounter() *ThroughputCounter {
+	return &ThroughputCounter{}
+}
+
+// CountProcessed counts a processed log.
+func (c *ThroughputCounter) CountProcessed() {
+	if c != nil {
+		atomic.AddInt64(&c.value.Processed, 1)
+	}
+}
+
+// CountTruncated counts a log truncated because it was too large to be sent.
+func (c *ThroughputCounter) CountTruncated() {
+	if c != nil {
+		atomic.AddInt64(&c.value.Truncated, 1)
+	}
+}
+
+// CountSent counts a log of size bytes sent.
+func (c *ThroughputCounter) CountSent(size int) {
+	if c != nil {
+		atomic.AddInt64(&c.value.Sent, 1)
+		atomic.AddInt64(&c.value.BytesSent, int64(size))
+	}
+}
+
+// CountDropped counts a log dropped before being sent.
+func (c *ThroughputCounter) CountDropped() {
+	if c != nil {
+		atomic.AddInt64(&c.value.Dropped, 1)
+	}
+}
+
+// Value returns the logs counted so far.
+func (c *ThroughputCounter) Value() Throughput {
+	if c == nil {
+		return Throughput{}
+	}
+	return Throughput{
+		Processed: atomic.LoadInt64(&c.value.Processed),
+		Truncated: atomic.LoadInt64(&c.value.Truncated),
+		Sent:      atomic.LoadInt64(&c.value.Sent),
+		BytesSent: atomic.LoadInt64(&c.value.BytesSent),
+		Dropped:   atomic.LoadInt64(&c.value.Dropped),
+	}
+}
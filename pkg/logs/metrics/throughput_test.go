@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughputCounter(t *testing.T) {
+	counter := NewThroughputCounter()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter.CountProcessed()
+			counter.CountTruncated()
+			counter.CountSent(5)
+			counter.CountDropped()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, Throughput{Processed: 10, Truncated: 10, Sent: 10, BytesSent: 50, Dropped: 10}, counter.Value())
+}
+
+func TestNilThroughputCounterCountsNothing(t *testing.T) {
+	var counter *ThroughputCounter
+	counter.CountProcessed()
+	counter.CountTruncated()
+	counter.CountSent(5)
+	counter.CountDropped()
+	assert.Equal(t, Throughput{}, counter.Value())
+}
+
+func TestThroughputAdd(t *testing.T) {
+	throughput := Throughput{Processed: 1, Truncated: 5, Sent: 2, BytesSent: 3, Dropped: 4}
+	assert.Equal(t, Throughput{Processed: 2, Truncated: 10, Sent: 4, BytesSent: 6, Dropped: 8}, throughput.Add(throughput))
+}
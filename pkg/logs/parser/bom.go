@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package parser
+
+import (
+	"bytes"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// utf8BOM is the byte order mark written at the beginning of some UTF-8 files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// bomParser strips the byte order mark at the beginning of the lines
+// before handing them to the underlying parser.
+type bomParser struct {
+	parser Parser
+}
+
+// NewBOMParser returns a parser that strips the UTF-8 byte order mark at the beginning of the lines,
+// which is written again in the middle of the stream when a file is truncated or rewritten.
+func NewBOMParser(parser Parser) Parser {
+	return &bomParser{
+		parser: parser,
+	}
+}
+
+// Parse strips the byte order mark of msg and of the parsed content.
+func (p *bomParser) Parse(msg []byte) (*message.Message, error) {
+	output, err := p.parser.Parse(stripBOM(msg))
+	if err != nil {
+		return nil, err
+	}
+	output.Content = stripBOM(output.Content)
+	return output, nil
+}
+
+// Unwrap strips the byte order mark of msg and of the unwrapped content.
+func (p *bomParser) Unwrap(msg []byte) ([]byte, error) {
+	content, err := p.parser.Unwrap(stripBOM(msg))
+	if err != nil {
+		return nil, err
+	}
+	return stripBOM(content), nil
+}
+
+// IsPartial returns true if the underlying parser splits the long lines and msg is followed by the next part of the same line.
+func (p *bomParser) IsPartial(msg []byte) bool {
+	return IsPartial(p.parser, stripBOM(msg))
+}
+
+// stripBOM returns content without its leading byte order mark.
+func stripBOM(content []byte) []byte {
+	return bytes.TrimPrefix(content, utf8BOM)
+}
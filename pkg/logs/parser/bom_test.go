@@ -0,0 +1,36 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBOMParserStripsLeadingBOM(t *testing.T) {
+	parser := NewBOMParser(NoopParser)
+
+	msg, err := parser.Parse([]byte("\xEF\xBB\xBFfoo"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), msg.Content)
+
+	content, err := parser.Unwrap([]byte("\xEF\xBB\xBFfoo"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), content)
+}
+
+func TestBOMParserKeepsOtherBOMs(t *testing.T) {
+	parser := NewBOMParser(NoopParser)
+
+	msg, err := parser.Parse([]byte("foo\xEF\xBB\xBFbar"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo\xEF\xBB\xBFbar"), msg.Content)
+
+	msg, err = parser.Parse([]byte("foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), msg.Content)
+}
@@ -18,6 +18,20 @@ type Parser interface {
 	Unwrap([]byte) ([]byte, error)
 }
 
+// PartialParser is implemented by the parsers of the formats which split the long lines into several parts,
+// like the CRI format which splits the lines of the containers every 16KB, so that the parts are joined.
+type PartialParser interface {
+	Parser
+	// IsPartial returns true if line is followed by the next part of the same line.
+	IsPartial(line []byte) bool
+}
+
+// IsPartial returns true if parser splits the long lines and line is followed by the next part of the same line.
+func IsPartial(parser Parser, line []byte) bool {
+	partialParser, isPartialParser := parser.(PartialParser)
+	return isPartialParser && partialParser.IsPartial(line)
+}
+
 type noopParser struct {
 	Parser
 }
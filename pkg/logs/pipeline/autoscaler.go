@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"time"
+)
+
+// scaleDownSamples is the number of samples in a row the buffers must be below the scale down threshold
+// before a pipeline is removed, so that a short lull in a burst does not retire a pipeline.
+const scaleDownSamples = 6
+
+// autoscaler periodically samples the fill level of the buffers of the pipelines and adds a pipeline
+// when an input buffer reaches the scale up threshold while the main destination accepts the logs,
+// or removes one when all the buffers stay below the scale down threshold,
+// keeping the number of pipelines between min and max.
+type autoscaler struct {
+	interval      time.Duration
+	min           int
+	max           int
+	upThreshold   float64
+	downThreshold float64
+	sample        func() BufferUtilization
+	count         func() int
+	blocked       func() bool
+	scale         func(numberOfPipelines int)
+	// idle is the number of samples in a row below the scale down threshold
+	idle int
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newAutoscaler returns an autoscaler calling sample every interval and scale with the number of pipelines
+// it should be scaled to, from the current one returned by count, blocked returns true while the pipelines
+// fail to send the logs to their main destination, it does nothing when interval is 0.
+func newAutoscaler(interval time.Duration, min, max int, upThreshold, downThreshold float64, sample func() BufferUtilization, count func() int, blocked func() bool, scale func(numberOfPipelines int)) *autoscaler {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &autoscaler{
+		interval:      interval,
+		min:           min,
+		max:           max,
+		upThreshold:   upThreshold,
+		downThreshold: downThreshold,
+		sample:        sample,
+		count:         count,
+		blocked:       blocked,
+		scale:         scale,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// clamp returns numberOfPipelines bounded by min and max.
+func (a *autoscaler) clamp(numberOfPipelines int) int {
+	if numberOfPipelines < a.min {
+		return a.min
+	}
+	if numberOfPipelines > a.max {
+		return a.max
+	}
+	return numberOfPipelines
+}
+
+// Start starts scaling the pipelines.
+func (a *autoscaler) Start() {
+	if a.interval <= 0 {
+		close(a.done)
+		return
+	}
+	go a.run()
+}
+
+// Stop stops scaling the pipelines, it blocks until the scaling in progress is done.
+func (a *autoscaler) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+// run scales the pipelines every interval until stopped.
+func (a *autoscaler) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			current := a.count()
+			if target := a.target(a.sample(), a.blocked(), current); target != current {
+				a.scale(target)
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// target returns the number of pipelines needed for utilization with current pipelines.
+// The pipelines are not scaled up while they are blocked, as the backpressure of an unreachable
+// main destination fills up all the buffers, which more pipelines would not help.
+// The lanes of the inputs are spread across the pipelines added, which relieves the pipelines
+// shared by several busy lanes, but not a pipeline saturated by a single one.
+func (a *autoscaler) target(utilization BufferUtilization, blocked bool, current int) int {
+	switch {
+	case utilization.MaxInput >= a.upThreshold:
+		a.idle = 0
+		if current < a.max && !blocked {
+			return current + 1
+		}
+	case utilization.MaxInput < a.downThreshold && utilization.MaxSender < a.downThreshold:
+		a.idle++
+		if a.idle >= scaleDownSamples && current > a.min {
+			a.idle = 0
+			return current - 1
+		}
+	default:
+		a.idle = 0
+	}
+	return a.clamp(current)
+}
@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAutoscaler(min, max int) *autoscaler {
+	return newAutoscaler(0, min, max, 80, 20, nil, nil, nil, nil)
+}
+
+func TestAutoscalerScalesUpWhenAnInputBufferFillsUp(t *testing.T) {
+	a := newTestAutoscaler(1, 3)
+	assert.Equal(t, 2, a.target(BufferUtilization{MaxInput: 80}, false, 1))
+	assert.Equal(t, 3, a.target(BufferUtilization{MaxInput: 95}, false, 2))
+	// never above max
+	assert.Equal(t, 3, a.target(BufferUtilization{MaxInput: 100}, false, 3))
+	// the sender buffers fill up when the destinations are unreachable
+	assert.Equal(t, 2, a.target(BufferUtilization{MaxInput: 10, MaxSender: 100}, false, 2))
+	// so do the input buffers when the main destination backpressures the pipelines
+	assert.Equal(t, 2, a.target(BufferUtilization{MaxInput: 100, MaxSender: 100}, true, 2))
+}
+
+func TestAutoscalerScalesDownOnceTheBuffersStayLow(t *testing.T) {
+	a := newTestAutoscaler(1, 3)
+	for i := 0; i < scaleDownSamples-1; i++ {
+		assert.Equal(t, 3, a.target(BufferUtilization{MaxInput: 10, MaxSender: 10}, false, 3))
+	}
+	assert.Equal(t, 2, a.target(BufferUtilization{MaxInput: 10, MaxSender: 10}, false, 3))
+
+	// a busier sample resets the count
+	for i := 0; i < scaleDownSamples-1; i++ {
+		assert.Equal(t, 2, a.target(BufferUtilization{}, false, 2))
+	}
+	assert.Equal(t, 2, a.target(BufferUtilization{MaxInput: 50}, false, 2))
+	assert.Equal(t, 2, a.target(BufferUtilization{}, false, 2))
+
+	// never below min
+	a = newTestAutoscaler(2, 3)
+	for i := 0; i < scaleDownSamples; i++ {
+		assert.Equal(t, 2, a.target(BufferUtilization{}, false, 2))
+	}
+}
+
+func TestAutoscalerBounds(t *testing.T) {
+	a := newTestAutoscaler(0, 0)
+	assert.Equal(t, 1, a.min)
+	assert.Equal(t, 1, a.max)
+
+	a = newTestAutoscaler(2, 4)
+	assert.Equal(t, 2, a.clamp(1))
+	assert.Equal(t, 3, a.clamp(3))
+	assert.Equal(t, 4, a.clamp(5))
+	// the pipelines scaled out of the bounds are brought back within them
+	assert.Equal(t, 4, a.target(BufferUtilization{MaxInput: 50}, false, 6))
+}
+
+func TestAutoscalerScalesThePipelines(t *testing.T) {
+	scaled := make(chan int, 10)
+	current := 1
+	a := newAutoscaler(time.Millisecond, 1, 2, 80, 20, func() BufferUtilization {
+		return BufferUtilization{MaxInput: 100}
+	}, func() int {
+		return current
+	}, func() bool {
+		return false
+	}, func(numberOfPipelines int) {
+		current = numberOfPipelines
+		scaled <- numberOfPipelines
+	})
+	a.Start()
+	assert.Equal(t, 2, <-scaled)
+	a.Stop()
+	// scale is not called once max is reached
+	assert.Equal(t, 0, len(scaled))
+}
+
+func TestAutoscalerDisabled(t *testing.T) {
+	a := newAutoscaler(0, 1, 2, 80, 20, func() BufferUtilization {
+		assert.Fail(t, "the buffers should not be sampled")
+		return BufferUtilization{}
+	}, nil, nil, nil)
+	a.Start()
+	a.Stop()
+}
@@ -0,0 +1,59 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"time"
+)
+
+// Health holds the state of the delivery of the logs by the pipelines,
+// it tells apart the pipelines still collecting logs from those failing to send them.
+type Health struct {
+	// BlockedPipelines is the number of pipelines failing to send the logs to their main destination
+	BlockedPipelines int
+	// BackpressuredPipelines is the number of pipelines of which the input is full,
+	// the inputs forwarding logs to them stop collecting until they catch up
+	BackpressuredPipelines int
+	// LastSuccessfulSends holds the last time logs were sent successfully by address of destination,
+	// the zero time if they never were
+	LastSuccessfulSends map[string]time.Time
+	// ActiveDestinations holds the number of pipelines sending the logs to each address
+	// when the main endpoint has failover ones
+	ActiveDestinations map[string]int
+	// OpenCircuits holds the number of pipelines of which the circuit breaker of each address
+	// is open or half-open, the logs being dropped instead of sent to it
+	OpenCircuits map[string]int
+}
+
+// Add returns the health of the pipelines of h and of other,
+// the latest successful send is kept for the destinations shared by both.
+func (h Health) Add(other Health) Health {
+	sum := Health{
+		BlockedPipelines:       h.BlockedPipelines + other.BlockedPipelines,
+		BackpressuredPipelines: h.BackpressuredPipelines + other.BackpressuredPipelines,
+		LastSuccessfulSends:    make(map[string]time.Time),
+		ActiveDestinations:     make(map[string]int),
+		OpenCircuits:           make(map[string]int),
+	}
+	for _, sends := range []map[string]time.Time{h.LastSuccessfulSends, other.LastSuccessfulSends} {
+		for address, lastSuccess := range sends {
+			if current, exists := sum.LastSuccessfulSends[address]; !exists || lastSuccess.After(current) {
+				sum.LastSuccessfulSends[address] = lastSuccess
+			}
+		}
+	}
+	for _, active := range []map[string]int{h.ActiveDestinations, other.ActiveDestinations} {
+		for address, count := range active {
+			sum.ActiveDestinations[address] += count
+		}
+	}
+	for _, open := range []map[string]int{h.OpenCircuits, other.OpenCircuits} {
+		for address, count := range open {
+			sum.OpenCircuits[address] += count
+		}
+	}
+	return sum
+}
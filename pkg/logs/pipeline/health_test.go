@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestHealthAddKeepsTheLatestSuccessfulSends(t *testing.T) {
+	now := time.Now()
+	h := Health{
+		BlockedPipelines:    1,
+		LastSuccessfulSends: map[string]time.Time{"foo:1234": now, "bar:1234": {}},
+		ActiveDestinations:  map[string]int{"foo:1234": 1},
+		OpenCircuits:        map[string]int{"bar:1234": 1},
+	}
+	other := Health{
+		BackpressuredPipelines: 1,
+		LastSuccessfulSends:    map[string]time.Time{"foo:1234": now.Add(-time.Minute), "bar:1234": now},
+		ActiveDestinations:     map[string]int{"foo:1234": 1},
+		OpenCircuits:           map[string]int{"bar:1234": 1},
+	}
+
+	sum := h.Add(other)
+	assert.Equal(t, 1, sum.BlockedPipelines)
+	assert.Equal(t, 1, sum.BackpressuredPipelines)
+	assert.Equal(t, map[string]time.Time{"foo:1234": now, "bar:1234": now}, sum.LastSuccessfulSends)
+	assert.Equal(t, map[string]int{"foo:1234": 2}, sum.ActiveDestinations)
+	assert.Equal(t, map[string]int{"bar:1234": 2}, sum.OpenCircuits)
+}
+
+func TestPipelineIsBackpressuredWhenItsInputIsFull(t *testing.T) {
+	p := &Pipeline{InputChan: make(chan *message.Message, 1)}
+	assert.Equal(t, 0, p.Health().BackpressuredPipelines)
+
+	p.InputChan <- message.NewMessage([]byte("foo"), nil, "")
+	assert.Equal(t, 1, p.Health().BackpressuredPipelines)
+	// the logs published to an AMQP exchange have no destinations
+	assert.Equal(t, 0, p.Health().BlockedPipelines)
+	assert.Empty(t, p.Health().LastSuccessfulSends)
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
+)
+
+// lane forwards the messages the inputs write to its input to the pipeline it is bound to,
+// so that the inputs keep the same channel when the provider binds the lane to another pipeline.
+// The messages of the sources configured with tees are duplicated to the tee before.
+type lane struct {
+	input    chan *message.Message
+	pipeline *Pipeline
+	tee      *Tee
+	// bind receives the requests to forward the next messages to another pipeline
+	bind  chan *laneBinding
+	flush restart.FlushRequests
+	done  chan struct{}
+}
+
+// laneBinding is a request to bind a lane to pipeline, done is closed once the request is handled,
+// err is set when ctx is done before the lane is bound.
+type laneBinding struct {
+	ctx      context.Context
+	pipeline *Pipeline
+	done     chan struct{}
+	err      error
+}
+
+// newLane returns a new lane bound to pipeline, duplicating the messages to tee when it is not nil.
+func newLane(pipeline *Pipeline, tee *Tee) *lane {
+	return &lane{
+		input:    make(chan *message.Message, config.ChanSize),
+		pipeline: pipeline,
+		tee:      tee,
+		bind:     make(chan *laneBinding),
+		flush:    restart.NewFlushRequests(),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts forwarding the messages.
+func (l *lane) Start() {
+	go l.run()
+}
+
+// Stop stops the lane once the messages of its input are forwarded,
+// the inputs must not write to it anymore.
+func (l *lane) Stop() {
+	close(l.input)
+	<-l.done
+}
+
+// Flush blocks until the messages of the input when it is called are forwarded to the pipeline,
+// returns an error if ctx is done first.
+func (l *lane) Flush(ctx context.Context) error {
+	return l.flush.Request(ctx)
+}
+
+// Bind forwards the next messages to pipeline, this call blocks until the messages already forwarded
+// to the previous pipeline are sent so that the messages of the lane are sent in order,
+// returns an error if ctx is done first, the lane then stays bound to the previous pipeline.
+func (l *lane) Bind(ctx context.Context, pipeline *Pipeline) error {
+	binding := &laneBinding{
+		ctx:      ctx,
+		pipeline: pipeline,
+		done:     make(chan struct{}),
+	}
+	select {
+	case l.bind <- binding:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-binding.done
+	return binding.err
+}
+
+// run forwards the messages until the input is closed.
+func (l *lane) run() {
+	defer close(l.done)
+	for {
+		select {
+		case msg, isOpen := <-l.input:
+			if !isOpen {
+				return
+			}
+			l.forward(msg)
+		case done := <-l.flush:
+			for i := len(l.input); i > 0; i-- {
+				l.forward(<-l.input)
+			}
+			close(done)
+		case binding := <-l.bind:
+			if binding.pipeline != l.pipeline {
+				binding.err = l.pipeline.Flush(binding.ctx)
+				if binding.err == nil {
+					l.pipeline = binding.pipeline
+				}
+			}
+			close(binding.done)
+		}
+	}
+}
+
+// forward dates msg and forwards it to the tee when its source has tees, then to the pipeline.
+func (l *lane) forward(msg *message.Message) {
+	msg.EnqueuedAt = time.Now()
+	if l.tee != nil && len(msg.Origin.LogSource.Config.Tee) > 0 {
+		l.tee.Forward(msg)
+	}
+	l.pipeline.InputChan <- msg
+}
@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestLaneBindSendsTheLogsInOrder(t *testing.T) {
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	destinationsContext := client.NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	outputChan := make(chan *message.Message, 10)
+	endpoints := client.NewEndpoints(client.AddrToEndPoint(l.Addr()), nil)
+	previous := NewPipeline(outputChan, endpoints, destinationsContext, nil, nil)
+	previous.Start()
+	defer previous.Stop()
+	next := NewPipeline(outputChan, endpoints, destinationsContext, nil, nil)
+	next.Start()
+	defer next.Stop()
+
+	lane := newLane(previous, nil)
+	lane.Start()
+	source := config.NewLogSource("", &config.LogsConfig{})
+	lane.input <- message.NewMessage([]byte("hello"), message.NewOrigin(source), "")
+	assert.Nil(t, lane.Flush(context.Background()))
+	assert.Nil(t, lane.Bind(context.Background(), next))
+	// the logs forwarded to the previous pipeline are sent once the lane is bound to the next one
+	assert.Equal(t, 1, len(outputChan))
+
+	lane.input <- message.NewMessage([]byte("world"), message.NewOrigin(source), "")
+	lane.Stop()
+	assert.Nil(t, next.Flush(context.Background()))
+	assert.Contains(t, string((<-outputChan).Content), "hello")
+	assert.Contains(t, string((<-outputChan).Content), "world")
+	assert.Equal(t, int64(1), previous.Throughput().Sent)
+	assert.Equal(t, int64(1), next.Throughput().Sent)
+}
+
+func TestLaneForwardsTheLogsToTheTeeOnlyWhenTheirSourceHasTees(t *testing.T) {
+	tee := NewTee(nil, client.NewEndpoints(client.Endpoint{}, nil), client.NewDestinationsContext(nil))
+	pipeline := &Pipeline{InputChan: make(chan *message.Message, 1)}
+	lane := newLane(pipeline, tee)
+
+	before := time.Now()
+	msg := message.NewMessage([]byte("hello"), message.NewOrigin(config.NewLogSource("", &config.LogsConfig{})), "")
+	lane.forward(msg)
+	assert.Equal(t, msg, <-pipeline.InputChan)
+	assert.False(t, msg.EnqueuedAt.Before(before))
+	assert.Equal(t, 0, len(tee.branches))
+}
+
+func TestLaneBindStopsWaitingOnceTheContextIsDone(t *testing.T) {
+	// the previous pipeline never sends the messages forwarded to it
+	previous := &Pipeline{InputChan: make(chan *message.Message, 1)}
+	next := &Pipeline{InputChan: make(chan *message.Message, 1)}
+	lane := newLane(previous, nil)
+	lane.Start()
+	defer lane.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, lane.Bind(ctx, next))
+
+	// the lane is still bound to the previous pipeline
+	msg := message.NewMessage([]byte("hello"), message.NewOrigin(config.NewLogSource("", &config.LogsConfig{})), "")
+	lane.input <- msg
+	assert.Equal(t, msg, <-previous.InputChan)
+}
@@ -6,7 +6,11 @@
 package mock
 
 import (
+	"context"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 )
 
@@ -32,3 +36,39 @@ func (p *mockProvider) Stop() {}
 func (p *mockProvider) NextPipelineChan() chan *message.Message {
 	return p.msgChan
 }
+
+// PipelineChanFor returns the next pipeline
+func (p *mockProvider) PipelineChanFor(key string) chan *message.Message {
+	return p.msgChan
+}
+
+// Throughput returns no logs
+func (p *mockProvider) Throughput() metrics.Throughput {
+	return metrics.Throughput{}
+}
+
+// Health returns a healthy delivery
+func (p *mockProvider) Health() pipeline.Health {
+	return pipeline.Health{}
+}
+
+// Backlog returns an empty backlog
+func (p *mockProvider) Backlog() metrics.Backlog {
+	return metrics.Backlog{}
+}
+
+// BufferUtilization returns empty buffers
+func (p *mockProvider) BufferUtilization() pipeline.BufferUtilization {
+	return pipeline.BufferUtilization{}
+}
+
+// Scale does nothing
+func (p *mockProvider) Scale(numberOfPipelines int) {}
+
+// Flush does nothing
+func (p *mockProvider) Flush(ctx context.Context) error {
+	return nil
+}
+
+// ReleaseSource does nothing
+func (p *mockProvider) ReleaseSource(source *config.LogSource) {}
@@ -6,58 +6,368 @@
 package pipeline
 
 import (
+	"context"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/metadata/host"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/amqp"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/kafka"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/diskbuffer"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/processor"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
 	"github.com/DataDog/datadog-agent/pkg/logs/sender"
 )
 
+// Policies applied when the inputs write to a pipeline whose buffer is full.
+const (
+	// BlockOverflowPolicy blocks the inputs until the pipeline catches up, no logs are lost.
+	BlockOverflowPolicy = "block"
+	// DropNewestOverflowPolicy drops the logs written while the buffer is full.
+	DropNewestOverflowPolicy = "drop_newest"
+	// DropOldestOverflowPolicy drops the oldest logs of the buffer to make room for the new ones.
+	DropOldestOverflowPolicy = "drop_oldest"
+)
+
 // Pipeline processes and sends messages to the backend
 type Pipeline struct {
-	InputChan chan *message.Message
-	processor *processor.Processor
-	sender    *sender.Sender
+	InputChan     chan *message.Message
+	processorChan chan *message.Message
+	senderChan    chan *message.Message
+	processor     *processor.Processor
+	sender        restartableSender
+	// overflowPolicy is applied when processorChan is full
+	overflowPolicy string
+	// flush receives the requests to forward the messages of the input to the processor without stopping
+	// when they are not written to the processor directly
+	flush restart.FlushRequests
+	done  chan struct{}
+	// destinations is nil when the logs are published to an AMQP exchange or a Kafka topic
+	destinations *client.Destinations
+	// failover selects the main destination when the main endpoint has failover ones
+	failover *client.Failover
 }
 
-// NewPipeline returns a new Pipeline
-func NewPipeline(outputChan chan *message.Message, endpoints *client.Endpoints, destinationsContext *client.DestinationsContext) *Pipeline {
-	// initialize the main destination
-	main := client.NewDestination(endpoints.Main, destinationsContext)
+// DiskBufferConfig holds the settings of the queue on disk the messages are spilled to
+// when the sender does not keep up.
+type DiskBufferConfig struct {
+	Dir         string
+	MaxBytes    int64
+	MaxMessages int
+	Registry    auditor.Registry
+}
+
+// restartableSender sends the processed logs and counts them.
+type restartableSender interface {
+	restart.Restartable
+	restart.Flushable
+	Throughput() metrics.Throughput
+	Backlog() metrics.Backlog
+}
+
+// NewPipeline returns a new Pipeline,
+// when diskBuffer is not nil, the messages the sender does not keep up with are spilled to disk,
+// when deduplicator is not nil, the messages identical to a message of the same source recently sent are dropped.
+func NewPipeline(outputChan chan *message.Message, endpoints *client.Endpoints, destinationsContext *client.DestinationsContext, diskBuffer *DiskBufferConfig, deduplicator *processor.Deduplicator) *Pipeline {
+	// initialize the main destination, a failover between the main endpoint and the failover ones if any,
+	// an HTTP destination if the main endpoint is an HTTP intake
+	var main client.MainDestination
+	var failover *client.Failover
+	if len(endpoints.Failovers) > 0 {
+		failover = client.NewFailover(append([]client.Endpoint{endpoints.Main}, endpoints.Failovers...), endpoints.FailoverPolicy, destinationsContext)
+		main = failover
+	} else if endpoints.Main.UseHTTP {
+		main = client.NewHTTPDestination(endpoints.Main, destinationsContext)
+	} else {
+		main = client.NewDestination(endpoints.Main, destinationsContext)
+	}
 
 	// initialize the additional destinations
-	var additionals []*client.Destination
+	var additionals []client.AdditionalDestination
 	for _, endpoint := range endpoints.Additionals {
-		additionals = append(additionals, client.NewDestination(endpoint, destinationsContext))
+		switch {
+		case endpoint.Kafka != nil:
+			additionals = append(additionals, kafka.NewAdditionalDestination(endpoint, destinationsContext))
+		case endpoint.UseHTTP:
+			additionals = append(additionals, client.NewHTTPDestination(endpoint, destinationsContext))
+		default:
+			additionals = append(additionals, client.NewDestination(endpoint, destinationsContext))
+		}
 	}
 
 	// initialize the sender
 	destinations := client.NewDestinations(main, additionals)
 	senderChan := make(chan *message.Message, config.ChanSize)
-	sender := sender.NewSender(senderChan, outputChan, destinations)
+	bufferedChan, sentChan := senderChan, outputChan
+	if diskBuffer != nil {
+		bufferedChan = make(chan *message.Message, config.ChanSize)
+		sentChan = make(chan *message.Message, config.ChanSize)
+	}
+	hook := sender.NewHook(config.LogsAgent.GetString("logs_config.pre_send_hook_error_policy"))
+	maxMessageAge := time.Duration(config.LogsAgent.GetInt("logs_config.max_message_age")) * time.Second
+	var logsSender restartableSender
+	switch {
+	case endpoints.AMQP != nil:
+		// the logs are published to an AMQP exchange instead of the destinations
+		logsSender = sender.NewMessageSender(bufferedChan, sentChan, amqp.NewDestination(*endpoints.AMQP, destinationsContext), maxMessageAge, hook)
+		destinations, failover = nil, nil
+	case endpoints.Kafka != nil:
+		// the logs are published to a Kafka topic instead of the destinations
+		logsSender = sender.NewMessageSender(bufferedChan, sentChan, kafka.NewDestination(*endpoints.Kafka, destinationsContext), maxMessageAge, hook)
+		destinations, failover = nil, nil
+	default:
+		batch := sender.BatchStrategy{
+			MaxBytes:  config.LogsAgent.GetInt("logs_config.batch_max_size"),
+			MaxCount:  config.LogsAgent.GetInt("logs_config.batch_max_count"),
+			MaxLinger: time.Duration(config.LogsAgent.GetInt("logs_config.batch_max_linger_ms")) * time.Millisecond,
+		}
+		logsSender = sender.NewSender(bufferedChan, sentChan, destinations, maxMessageAge, hook, batch)
+	}
+	if diskBuffer != nil {
+		buffer, err := diskbuffer.NewBuffer(diskBuffer.Dir, diskBuffer.MaxBytes, diskBuffer.MaxMessages, diskBuffer.Registry, senderChan, bufferedChan, sentChan, outputChan, logsSender)
+		if err != nil {
+			log.Warnf("Could not open the disk buffer in %v, the logs are only buffered in memory: %v", diskBuffer.Dir, err)
+			return NewPipeline(outputChan, endpoints, destinationsContext, nil, deduplicator)
+		}
+		logsSender = buffer
+	}
 
-	// initialize the input chan
+	// initialize the input chan, the messages are forwarded to the processor by the pipeline
+	// when they can be dropped
+	overflowPolicy := overflowPolicy(config.LogsAgent.GetString("logs_config.overflow_policy"))
 	inputChan := make(chan *message.Message, config.ChanSize)
+	processorChan := inputChan
+	if overflowPolicy != BlockOverflowPolicy {
+		processorChan = make(chan *message.Message, config.ChanSize)
+	}
 
 	// initialize the processor
-	encoder := processor.NewEncoder(endpoints.Main.UseProto)
-	processor := processor.New(inputChan, senderChan, encoder)
+	var encoder processor.Encoder
+	switch {
+	case endpoints.Main.UseCEF:
+		encoder = processor.NewCEFEncoder()
+	case endpoints.Main.UseJSON:
+		encoder = processor.NewJSONEncoder(endpoints.Main.JSONRawMessage)
+	default:
+		encoder = processor.NewEncoder(endpoints.Main.UseProto)
+	}
+	workers := config.LogsAgent.GetInt("logs_config.processor_workers")
+	var sampler *processor.Sampler
+	if config.LogsAgent.GetBool("logs_config.adaptive_sampling") {
+		sampler = processor.NewSampler(
+			config.LogsAgent.GetFloat64("logs_config.adaptive_sampling_low_watermark"),
+			config.LogsAgent.GetFloat64("logs_config.adaptive_sampling_high_watermark"),
+			config.LogsAgent.GetFloat64("logs_config.adaptive_sampling_max_drop_rate"),
+			config.LogsAgent.GetStringSlice("logs_config.adaptive_sampling_statuses"),
+		)
+	}
+	truncator := processor.NewTruncator(
+		config.LogsAgent.GetInt("logs_config.max_message_size"),
+		config.LogsAgent.GetString("logs_config.truncation_marker"),
+	)
+	scrubber := processor.NewScrubber(config.LogsAgent.GetBool("logs_config.scrub_secrets"))
+	hostTagger := processor.NewHostTagger(
+		config.LogsAgent.GetBool("logs_config.host_tags"),
+		config.LogsAgent.GetStringSlice("logs_config.host_tags_include"),
+		time.Duration(config.LogsAgent.GetInt("logs_config.host_tags_refresh_interval"))*time.Second,
+		host.GetHostTags,
+	)
+	processor := processor.New(processorChan, senderChan, encoder, workers, sampler, truncator, scrubber, hostTagger, deduplicator)
 
 	return &Pipeline{
-		InputChan: inputChan,
-		processor: processor,
-		sender:    sender,
+		InputChan:      inputChan,
+		processorChan:  processorChan,
+		senderChan:     senderChan,
+		processor:      processor,
+		sender:         logsSender,
+		overflowPolicy: overflowPolicy,
+		flush:          restart.NewFlushRequests(),
+		done:           make(chan struct{}),
+		destinations:   destinations,
+		failover:       failover,
+	}
+}
+
+// overflowPolicy returns policy if it is supported, the block policy otherwise.
+func overflowPolicy(policy string) string {
+	switch policy {
+	case BlockOverflowPolicy, DropNewestOverflowPolicy, DropOldestOverflowPolicy:
+		return policy
+	default:
+		log.Warnf("Invalid overflow policy: %v, defaulting to %v", policy, BlockOverflowPolicy)
+		return BlockOverflowPolicy
 	}
 }
 
 // Start launches the pipeline
 func (p *Pipeline) Start() {
+	if p.failover != nil {
+		p.failover.Start()
+	}
 	p.sender.Start()
 	p.processor.Start()
+	if p.processorChan != p.InputChan {
+		go p.forward()
+	}
 }
 
 // Stop stops the pipeline
 func (p *Pipeline) Stop() {
+	if p.processorChan != p.InputChan {
+		close(p.InputChan)
+		<-p.done
+	}
 	p.processor.Stop()
 	p.sender.Stop()
+	if p.failover != nil {
+		p.failover.Stop()
+	}
+}
+
+// Flush blocks until the messages held by the pipeline when it is called are sent to the main destination or dropped,
+// without stopping it so that the inputs keep writing to it, returns an error if ctx is done first.
+// The messages are flushed from the input to the sender, each part of the pipeline is flushed once the previous
+// one handed over the messages it held.
+func (p *Pipeline) Flush(ctx context.Context) error {
+	if p.processorChan != p.InputChan {
+		if err := p.flush.Request(ctx); err != nil {
+			return err
+		}
+	}
+	if err := p.processor.Flush(ctx); err != nil {
+		return err
+	}
+	return p.sender.Flush(ctx)
+}
+
+// Throughput returns the logs processed, sent and dropped so far by the pipeline.
+func (p *Pipeline) Throughput() metrics.Throughput {
+	return p.processor.Throughput().Add(p.sender.Throughput())
+}
+
+// BufferUtilization returns the fill level of the input buffer and of the sender buffer of the pipeline.
+func (p *Pipeline) BufferUtilization() BufferUtilization {
+	input, sender := chanUtilization(p.InputChan), chanUtilization(p.senderChan)
+	return BufferUtilization{MaxInput: input, AvgInput: input, MaxSender: sender, AvgSender: sender}
+}
+
+// Backlog returns the number of logs held by the pipeline waiting to be sent
+// and when the oldest log being sent entered the pipeline.
+func (p *Pipeline) Backlog() metrics.Backlog {
+	backlog := p.sender.Backlog()
+	backlog.Buffered += int64(len(p.InputChan) + len(p.senderChan))
+	if p.processorChan != p.InputChan {
+		backlog.Buffered += int64(len(p.processorChan))
+	}
+	return backlog
+}
+
+// Health returns the state of the delivery of the logs by the pipeline,
+// the pipeline is blocked while it fails to send the logs to its main destination
+// and backpressured while its input is full.
+func (p *Pipeline) Health() Health {
+	health := Health{
+		LastSuccessfulSends: make(map[string]time.Time),
+		ActiveDestinations:  make(map[string]int),
+		OpenCircuits:        make(map[string]int),
+	}
+	if len(p.InputChan) == cap(p.InputChan) {
+		health.BackpressuredPipelines = 1
+	}
+	if p.destinations == nil {
+		return health
+	}
+	if p.destinations.Main.BackoffDelay() > 0 {
+		health.BlockedPipelines = 1
+	}
+	destinations := append([]client.AdditionalDestination(nil), p.destinations.Additionals...)
+	if p.failover != nil {
+		for _, destination := range p.failover.Destinations() {
+			destinations = append(destinations, destination)
+		}
+		health.ActiveDestinations[p.failover.Address()] = 1
+	} else {
+		health.LastSuccessfulSends[p.destinations.Main.Address()] = p.destinations.Main.LastSuccess()
+		if main, ok := p.destinations.Main.(*client.Destination); ok {
+			destinations = append(destinations, main)
+		}
+	}
+	for _, destination := range destinations {
+		health.LastSuccessfulSends[destination.Address()] = destination.LastSuccess()
+		if destination.CircuitState() != client.CircuitClosed {
+			health.OpenCircuits[destination.Address()]++
+		}
+	}
+	return health
+}
+
+// forward forwards the messages to the processor until InputChan is closed,
+// the messages are dropped following the overflow policy when the processor does not keep up.
+func (p *Pipeline) forward() {
+	defer func() {
+		p.done <- struct{}{}
+	}()
+	for {
+		select {
+		case msg, isOpen := <-p.InputChan:
+			if !isOpen {
+				return
+			}
+			p.push(msg)
+		case done := <-p.flush:
+			for i := len(p.InputChan); i > 0; i-- {
+				p.push(<-p.InputChan)
+			}
+			close(done)
+		}
+	}
+}
+
+// push forwards msg to the processor, msg is dropped following the overflow policy
+// when the processor does not keep up.
+func (p *Pipeline) push(msg *message.Message) {
+	switch p.overflowPolicy {
+	case DropNewestOverflowPolicy:
+		select {
+		case p.processorChan <- msg:
+		default:
+			drop(msg)
+		}
+	case DropOldestOverflowPolicy:
+		p.pushDroppingOldest(msg)
+	default:
+		p.processorChan <- msg
+	}
+}
+
+// pushDroppingOldest forwards msg to the processor, the oldest messages waiting for the processor
+// are dropped until there is room for msg.
+func (p *Pipeline) pushDroppingOldest(msg *message.Message) {
+	for {
+		select {
+		case p.processorChan <- msg:
+			return
+		default:
+		}
+		select {
+		case oldest := <-p.processorChan:
+			drop(oldest)
+		default:
+			// the processor made room in the meantime
+		}
+	}
+}
+
+// drop counts msg as dropped by its source because the pipeline was full,
+// its offset is committed along with the ones of the next messages of its source.
+func drop(msg *message.Message) {
+	source := msg.Origin.LogSource
+	metrics.LogsOverflowed.Add(source.Name, 1)
+	source.Throughput.CountDropped()
 }
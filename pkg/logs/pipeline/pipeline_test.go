@@ -0,0 +1,166 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// forwardAll forwards contents to a processor which does not consume anything and can hold two messages,
+// and returns the contents of the messages it holds.
+func forwardAll(source *config.LogSource, overflowPolicy string, contents ...string) []string {
+	p := &Pipeline{
+		InputChan:      make(chan *message.Message),
+		processorChan:  make(chan *message.Message, 2),
+		overflowPolicy: overflowPolicy,
+		done:           make(chan struct{}),
+	}
+	go p.forward()
+	for _, content := range contents {
+		p.InputChan <- message.NewMessage([]byte(content), message.NewOrigin(source), "")
+	}
+	close(p.InputChan)
+	<-p.done
+	close(p.processorChan)
+	var forwarded []string
+	for msg := range p.processorChan {
+		forwarded = append(forwarded, string(msg.Content))
+	}
+	return forwarded
+}
+
+// overflowed returns the number of logs of source dropped so far because the pipeline was full.
+func overflowed(source *config.LogSource) int64 {
+	if count, ok := metrics.LogsOverflowed.Get(source.Name).(*expvar.Int); ok {
+		return count.Value()
+	}
+	return 0
+}
+
+func TestPipelineDropsTheNewestLogsWhenFull(t *testing.T) {
+	source := config.NewLogSource("drop_newest", &config.LogsConfig{})
+	dropped := overflowed(source)
+	assert.Equal(t, []string{"a", "b"}, forwardAll(source, DropNewestOverflowPolicy, "a", "b", "c", "d"))
+	assert.Equal(t, dropped+2, overflowed(source))
+	assert.Equal(t, int64(2), source.Throughput.Value().Dropped)
+}
+
+func TestPipelineDropsTheOldestLogsWhenFull(t *testing.T) {
+	source := config.NewLogSource("drop_oldest", &config.LogsConfig{})
+	dropped := overflowed(source)
+	assert.Equal(t, []string{"c", "d"}, forwardAll(source, DropOldestOverflowPolicy, "a", "b", "c", "d"))
+	assert.Equal(t, dropped+2, overflowed(source))
+	assert.Equal(t, int64(2), source.Throughput.Value().Dropped)
+}
+
+func TestOverflowPolicyDefaultsToBlock(t *testing.T) {
+	assert.Equal(t, DropOldestOverflowPolicy, overflowPolicy(DropOldestOverflowPolicy))
+	assert.Equal(t, BlockOverflowPolicy, overflowPolicy("drop_everything"))
+}
+
+func TestPipelineFlushesTheLogsWhenStoppedWithOverflowPolicy(t *testing.T) {
+	config.LogsAgent.Set("logs_config.overflow_policy", DropNewestOverflowPolicy)
+	defer config.LogsAgent.Set("logs_config.overflow_policy", BlockOverflowPolicy)
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	destinationsContext := client.NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	outputChan := make(chan *message.Message, 10)
+	p := NewPipeline(outputChan, client.NewEndpoints(client.AddrToEndPoint(l.Addr()), nil), destinationsContext, nil, nil)
+	assert.NotEqual(t, p.InputChan, p.processorChan)
+	p.Start()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	p.InputChan <- message.NewMessage([]byte("hello"), message.NewOrigin(source), "")
+	p.Stop()
+	assert.Equal(t, 1, len(outputChan))
+}
+
+func TestPipelineFlushSendsTheLogsWithoutStopping(t *testing.T) {
+	config.LogsAgent.Set("logs_config.batch_max_count", 10)
+	config.LogsAgent.Set("logs_config.batch_max_linger_ms", 3600*1000)
+	defer func() {
+		config.LogsAgent.Set("logs_config.batch_max_count", 1)
+		config.LogsAgent.Set("logs_config.batch_max_linger_ms", 100)
+	}()
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	destinationsContext := client.NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	outputChan := make(chan *message.Message, 10)
+	p := NewPipeline(outputChan, client.NewEndpoints(client.AddrToEndPoint(l.Addr()), nil), destinationsContext, nil, nil)
+	// the inputs write to the processor directly when the logs are not dropped
+	assert.Equal(t, p.InputChan, p.processorChan)
+	p.Start()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	for i := 0; i < 3; i++ {
+		p.InputChan <- message.NewMessage([]byte("hello"), message.NewOrigin(source), "")
+	}
+	assert.Nil(t, p.Flush(context.Background()))
+	assert.Equal(t, 3, len(outputChan))
+
+	// the pipeline keeps sending the logs after a flush
+	p.InputChan <- message.NewMessage([]byte("world"), message.NewOrigin(source), "")
+	assert.Nil(t, p.Flush(context.Background()))
+	assert.Equal(t, 4, len(outputChan))
+	p.Stop()
+}
+
+func TestPipelineBacklog(t *testing.T) {
+	config.LogsAgent.Set("logs_config.batch_max_count", 10)
+	config.LogsAgent.Set("logs_config.batch_max_linger_ms", 3600*1000)
+	defer func() {
+		config.LogsAgent.Set("logs_config.batch_max_count", 1)
+		config.LogsAgent.Set("logs_config.batch_max_linger_ms", 100)
+	}()
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	destinationsContext := client.NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	outputChan := make(chan *message.Message, 10)
+	p := NewPipeline(outputChan, client.NewEndpoints(client.AddrToEndPoint(l.Addr()), nil), destinationsContext, nil, nil)
+	p.Start()
+	defer p.Stop()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	// the messages are dated by the lanes of the provider
+	enqueuedAt := time.Now()
+	for i := 0; i < 3; i++ {
+		msg := message.NewMessage([]byte("hello"), message.NewOrigin(source), "")
+		msg.EnqueuedAt = enqueuedAt
+		p.InputChan <- msg
+	}
+	// the logs wait in the partial batch of the sender
+	for p.sender.Backlog().Buffered < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	backlog := p.Backlog()
+	assert.Equal(t, int64(3), backlog.Buffered)
+	assert.True(t, backlog.Oldest.Equal(enqueuedAt))
+
+	assert.Nil(t, p.Flush(context.Background()))
+	assert.Equal(t, metrics.Backlog{}, p.Backlog())
+	assert.Equal(t, 3, len(outputChan))
+	assert.True(t, backlog.Oldest.Equal((<-outputChan).EnqueuedAt))
+}
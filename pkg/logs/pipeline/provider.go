@@ -6,11 +6,22 @@
 package pipeline
 
 import (
+	"context"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/processor"
 	"github.com/DataDog/datadog-agent/pkg/logs/restart"
 )
 
@@ -19,6 +30,14 @@ type Provider interface {
 	Start()
 	Stop()
 	NextPipelineChan() chan *message.Message
+	PipelineChanFor(key string) chan *message.Message
+	Throughput() metrics.Throughput
+	Health() Health
+	BufferUtilization() BufferUtilization
+	Backlog() metrics.Backlog
+	Scale(numberOfPipelines int)
+	Flush(ctx context.Context) error
+	ReleaseSource(source *config.LogSource)
 }
 
 // provider implements providing logic
@@ -28,9 +47,32 @@ type provider struct {
 	outputChan        chan *message.Message
 	endpoints         *client.Endpoints
 
-	pipelines            []*Pipeline
+	pipelines []*Pipeline
+	// lanes are the channels handed to the inputs, one per pipeline the provider can be scaled to,
+	// bindings holds the index of the pipeline each lane forwards its messages to
+	lanes    []*lane
+	bindings []int
+	// maxPipelines is the number of pipelines the provider can be scaled to
+	maxPipelines int
+	// stopped holds the logs counted by the pipelines removed by scaling down
+	stopped metrics.Throughput
+	// mu protects the pipelines and the lanes, scaleMu serializes the scaling and the stop of the provider
+	mu                   sync.RWMutex
+	scaleMu              sync.Mutex
 	currentPipelineIndex int32
 	destinationsContext  *client.DestinationsContext
+	tee                  *Tee
+	// deduplicator is shared by all pipelines so that the duplicates are dropped whichever pipeline they go through
+	deduplicator *processor.Deduplicator
+	// pinned is true when the messages sharing a key are all sent to the same lane to keep them in order
+	pinned bool
+	// sampler reports the fill level of the buffers of the pipelines and their backlog
+	sampler *utilizationSampler
+	// autoscaler adds and removes pipelines following the fill level of their buffers
+	autoscaler *autoscaler
+	// scaleCtx is cancelled on stop so that the lanes being bound to another pipeline stop waiting
+	scaleCtx    context.Context
+	cancelScale context.CancelFunc
 }
 
 // NewProvider returns a new Provider
@@ -48,34 +90,379 @@ func NewProvider(numberOfPipelines int, auditor *auditor.Auditor, endpoints *cli
 func (p *provider) Start() {
 	// This requires the auditor to be started before.
 	p.outputChan = p.auditor.Channel()
+	p.pinned = config.LogsAgent.GetBool("logs_config.pipeline_affinity")
+	p.deduplicator = processor.NewDeduplicator(
+		time.Duration(config.LogsAgent.GetInt("logs_config.dedup_window"))*time.Second,
+		config.LogsAgent.GetInt("logs_config.dedup_window_size"),
+	)
+
+	p.scaleCtx, p.cancelScale = context.WithCancel(context.Background())
+	p.autoscaler = p.newAutoscaler()
+	p.numberOfPipelines = p.autoscaler.clamp(p.numberOfPipelines)
+	p.maxPipelines = p.numberOfPipelines
+	if p.autoscaler.interval > 0 && p.autoscaler.max > p.maxPipelines {
+		p.maxPipelines = p.autoscaler.max
+	}
 
+	p.mu.Lock()
+	// the tee is shared by all lanes to start only one additional pipeline per tee
+	p.tee = NewTee(p.outputChan, p.endpoints, p.destinationsContext)
 	for i := 0; i < p.numberOfPipelines; i++ {
-		pipeline := NewPipeline(p.outputChan, p.endpoints, p.destinationsContext)
-		pipeline.Start()
-		p.pipelines = append(p.pipelines, pipeline)
+		p.pipelines = append(p.pipelines, p.startPipeline(i))
+	}
+	// the lanes are spread evenly across the pipelines, the first pipelines get one more when they cannot be evenly spread
+	for i := 0; i < p.maxPipelines; i++ {
+		lane := newLane(p.pipelines[i%p.numberOfPipelines], p.tee)
+		lane.Start()
+		p.lanes = append(p.lanes, lane)
+		p.bindings = append(p.bindings, i%p.numberOfPipelines)
+	}
+	p.mu.Unlock()
+	p.sampler = newUtilizationSampler(
+		time.Duration(config.LogsAgent.GetInt("logs_config.buffer_utilization_interval"))*time.Second,
+		config.LogsAgent.GetFloat64("logs_config.buffer_utilization_warning_threshold"),
+		p.BufferUtilization,
+		p.Backlog,
+	)
+	p.sampler.Start()
+	p.autoscaler.Start()
+}
+
+// newAutoscaler returns the autoscaler of the pipelines, it does nothing when autoscaling is disabled.
+func (p *provider) newAutoscaler() *autoscaler {
+	var interval time.Duration
+	if config.LogsAgent.GetBool("logs_config.autoscale.enabled") {
+		interval = time.Duration(config.LogsAgent.GetInt("logs_config.autoscale.interval")) * time.Second
+	}
+	return newAutoscaler(
+		interval,
+		config.LogsAgent.GetInt("logs_config.autoscale.min_pipelines"),
+		config.LogsAgent.GetInt("logs_config.autoscale.max_pipelines"),
+		config.LogsAgent.GetFloat64("logs_config.autoscale.scale_up_threshold"),
+		config.LogsAgent.GetFloat64("logs_config.autoscale.scale_down_threshold"),
+		p.BufferUtilization,
+		p.pipelineCount,
+		p.blocked,
+		p.Scale,
+	)
+}
+
+// pipelineCount returns the number of pipelines the inputs send their messages to.
+func (p *provider) pipelineCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.pipelines)
+}
+
+// blocked returns true while a pipeline fails to send the logs to its main destination.
+func (p *provider) blocked() bool {
+	return p.Health().BlockedPipelines > 0
+}
+
+// startPipeline starts a new pipeline with index.
+func (p *provider) startPipeline(index int) *Pipeline {
+	pipeline := NewPipeline(p.outputChan, p.endpoints, p.destinationsContext, p.diskBufferConfig(index), p.deduplicator)
+	pipeline.Start()
+	return pipeline
+}
+
+// Scale adds or removes pipelines until there are numberOfPipelines of them, at least one and at most
+// the number of pipelines the provider was started with, or logs_config.autoscale.max_pipelines
+// when autoscaling is enabled, without stopping the others.
+// The lanes the inputs write to are then spread evenly across the pipelines, moving as few lanes as possible,
+// so that the inputs started before the pipelines added write to them too. A lane forwards its messages to its
+// new pipeline once the ones it forwarded to the previous one are sent, and the pipelines removed are stopped
+// once no lane forwards its messages to them anymore.
+// This call blocks until the lanes moved are bound to their new pipeline, or the provider is stopped.
+func (p *provider) Scale(numberOfPipelines int) {
+	if numberOfPipelines < 1 {
+		numberOfPipelines = 1
+	}
+	p.scaleMu.Lock()
+	defer p.scaleMu.Unlock()
+	if p.outputChan == nil {
+		// the provider is not started, the pipelines are started with it
+		p.numberOfPipelines = numberOfPipelines
+		return
+	}
+	if numberOfPipelines > p.maxPipelines {
+		log.Warnf("Cannot scale the logs pipelines to %d, scaling them to the maximum of %d", numberOfPipelines, p.maxPipelines)
+		numberOfPipelines = p.maxPipelines
+	}
+	p.mu.Lock()
+	current := len(p.pipelines)
+	p.numberOfPipelines = numberOfPipelines
+	for i := current; i < numberOfPipelines; i++ {
+		p.pipelines = append(p.pipelines, p.startPipeline(i))
+	}
+	pipelines := p.pipelines
+	p.mu.Unlock()
+
+	// the lanes are bound in parallel as each one waits for the messages it forwarded to be sent
+	bindings := balance(p.bindings, numberOfPipelines)
+	errs := make([]error, len(p.lanes))
+	wg := &sync.WaitGroup{}
+	for i := range p.lanes {
+		if bindings[i] == p.bindings[i] {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, pipeline *Pipeline) {
+			defer wg.Done()
+			errs[i] = p.lanes[i].Bind(p.scaleCtx, pipeline)
+		}(i, pipelines[bindings[i]])
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			bindings[i] = p.bindings[i]
+		}
+	}
+	p.bindings = bindings
+	if p.scaleCtx.Err() != nil {
+		// the provider is stopping, the pipelines that should have been removed are stopped with the others
+		log.Infof("Stopped scaling the logs pipelines from %d to %d", current, numberOfPipelines)
+		return
+	}
+
+	if numberOfPipelines < current {
+		removed := pipelines[numberOfPipelines:]
+		stopper := restart.NewParallelStopper()
+		for _, pipeline := range removed {
+			stopper.Add(pipeline)
+		}
+		stopper.Stop()
+		p.mu.Lock()
+		for _, pipeline := range removed {
+			p.stopped = p.stopped.Add(pipeline.Throughput())
+		}
+		p.pipelines = append([]*Pipeline(nil), pipelines[:numberOfPipelines]...)
+		p.mu.Unlock()
+	}
+	log.Infof("Scaled the logs pipelines from %d to %d", current, numberOfPipelines)
+}
+
+// balance returns the index of the pipeline each lane is bound to once there are numberOfPipelines pipelines,
+// from the current bindings, so that the pipelines get the same number of lanes, one more for the first ones
+// when the lanes cannot be evenly spread. Only the lanes bound to a pipeline removed or holding more lanes
+// than its share are bound to another pipeline.
+func balance(bindings []int, numberOfPipelines int) []int {
+	share := func(index int) int {
+		if index < len(bindings)%numberOfPipelines {
+			return len(bindings)/numberOfPipelines + 1
+		}
+		return len(bindings) / numberOfPipelines
+	}
+	balanced := make([]int, len(bindings))
+	counts := make([]int, numberOfPipelines)
+	var moved []int
+	for lane, index := range bindings {
+		if index < numberOfPipelines && counts[index] < share(index) {
+			balanced[lane] = index
+			counts[index]++
+		} else {
+			moved = append(moved, lane)
+		}
+	}
+	index := 0
+	for _, lane := range moved {
+		for counts[index] >= share(index) {
+			index++
+		}
+		balanced[lane] = index
+		counts[index]++
+	}
+	return balanced
+}
+
+// diskBufferConfig returns the settings of the disk buffer of the pipeline with index,
+// nil if the disk buffer is not enabled, the capacity of the buffer is shared by all the pipelines
+// the provider can be scaled to so that it is never exceeded.
+func (p *provider) diskBufferConfig(index int) *DiskBufferConfig {
+	if !config.LogsAgent.GetBool("logs_config.disk_buffer") {
+		return nil
+	}
+	return &DiskBufferConfig{
+		Dir:         filepath.Join(config.LogsAgent.GetString("logs_config.run_path"), "disk_buffer", strconv.Itoa(index)),
+		MaxBytes:    config.LogsAgent.GetInt64("logs_config.disk_buffer_max_size") / int64(p.maxPipelines),
+		MaxMessages: config.LogsAgent.GetInt("logs_config.disk_buffer_max_messages") / p.maxPipelines,
+		Registry:    p.auditor,
 	}
 }
 
 // Stop stops all pipelines in parallel,
 // this call blocks until all pipelines are stopped
 func (p *provider) Stop() {
+	// the autoscaler scales the pipelines, it must be stopped before the scaling is locked,
+	// the lanes being bound stop waiting for their previous pipeline to send its messages
+	p.cancelScale()
+	p.autoscaler.Stop()
+	p.scaleMu.Lock()
+	defer p.scaleMu.Unlock()
+	// the sampler reads the pipelines, it must be stopped before they are released
+	p.sampler.Stop()
+	// the lanes forward their messages to the pipelines, they are stopped first
 	stopper := restart.NewParallelStopper()
+	for _, lane := range p.lanes {
+		stopper.Add(lane)
+	}
+	stopper.Stop()
+	stopper = restart.NewParallelStopper()
 	for _, pipeline := range p.pipelines {
 		stopper.Add(pipeline)
 	}
 	stopper.Stop()
+	// the lanes must be stopped before the tee as they forward messages to it
+	p.tee.Stop()
+	p.mu.Lock()
 	p.pipelines = p.pipelines[:0]
+	p.lanes = nil
+	p.bindings = nil
+	p.stopped = metrics.Throughput{}
+	p.mu.Unlock()
 	p.outputChan = nil
 }
 
-// NextPipelineChan returns the next pipeline input channel
+// Flush blocks until the messages held by all the lanes and pipelines when it is called are sent to the main destination
+// or dropped, without stopping them, returns an error if ctx is done first.
+// The lanes then the pipelines are flushed in parallel, the scaling and the stop of the provider wait for the flush.
+func (p *provider) Flush(ctx context.Context) error {
+	p.scaleMu.Lock()
+	defer p.scaleMu.Unlock()
+	p.mu.RLock()
+	var lanes, pipelines []restart.Flushable
+	for _, lane := range p.lanes {
+		lanes = append(lanes, lane)
+	}
+	for _, pipeline := range p.pipelines {
+		pipelines = append(pipelines, pipeline)
+	}
+	p.mu.RUnlock()
+	if err := flushAll(ctx, lanes); err != nil {
+		return err
+	}
+	return flushAll(ctx, pipelines)
+}
+
+// flushAll flushes components in parallel and returns an error if one of them could not be flushed.
+func flushAll(ctx context.Context, components []restart.Flushable) error {
+	errs := make(chan error, len(components))
+	for _, component := range components {
+		go func(component restart.Flushable) {
+			errs <- component.Flush(ctx)
+		}(component)
+	}
+	var err error
+	for range components {
+		if componentErr := <-errs; componentErr != nil {
+			err = componentErr
+		}
+	}
+	return err
+}
+
+// ReleaseSource stops the additional pipelines of the tees of source in the background,
+// it is called once source is removed.
+func (p *provider) ReleaseSource(source *config.LogSource) {
+	p.mu.RLock()
+	tee := p.tee
+	p.mu.RUnlock()
+	if tee != nil {
+		tee.Release(source)
+	}
+}
+
+// NextPipelineChan returns the input channel of the next lane, the lanes forward the messages to the pipelines
 func (p *provider) NextPipelineChan() chan *message.Message {
-	pipelinesLen := len(p.pipelines)
-	if pipelinesLen == 0 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	lanesLen := len(p.lanes)
+	if lanesLen == 0 {
 		return nil
 	}
-	index := int(p.currentPipelineIndex+1) % pipelinesLen
+	index := int(p.currentPipelineIndex+1) % lanesLen
 	defer atomic.StoreInt32(&p.currentPipelineIndex, int32(index))
-	nextPipeline := p.pipelines[index]
-	return nextPipeline.InputChan
+	return p.lanes[index].input
+}
+
+// PipelineChanFor returns the input channel of the lane the messages identified by key are pinned to,
+// the key is hashed so that the same key always maps to the same lane, preserving the order of its messages,
+// while different keys are spread across the lanes. The lanes do not change when scaling, a lane moved to another
+// pipeline forwards its messages to it once the ones it forwarded before are sent, so the messages of a key stay in order.
+// It returns the next lane when pinning is disabled.
+func (p *provider) PipelineChanFor(key string) chan *message.Message {
+	if !p.pinned {
+		return p.NextPipelineChan()
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	lanesLen := len(p.lanes)
+	if lanesLen == 0 {
+		return nil
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return p.lanes[jumpHash(hash.Sum64(), lanesLen)].input
+}
+
+// jumpHash returns the bucket of key among buckets, when the number of buckets changes only the keys
+// of the buckets removed or a share of the keys moving to the buckets added change of bucket,
+// see "A Fast, Minimal Memory, Consistent Hash Algorithm" by Lamping and Veach.
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// Throughput returns the logs processed, sent and dropped so far by all the pipelines, the ones removed by scaling
+// down included, the logs of the pipelines stopped with the provider are not counted anymore.
+func (p *provider) Throughput() metrics.Throughput {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	throughput := p.stopped
+	for _, pipeline := range p.pipelines {
+		throughput = throughput.Add(pipeline.Throughput())
+	}
+	return throughput
+}
+
+// Health returns the state of the delivery of the logs by all the pipelines.
+func (p *provider) Health() Health {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var health Health
+	for _, pipeline := range p.pipelines {
+		health = health.Add(pipeline.Health())
+	}
+	return health
+}
+
+// BufferUtilization returns the max and average fill levels of the buffers of all the pipelines.
+func (p *provider) BufferUtilization() BufferUtilization {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var pipelines []BufferUtilization
+	for _, pipeline := range p.pipelines {
+		pipelines = append(pipelines, pipeline.BufferUtilization())
+	}
+	return aggregateUtilization(pipelines)
+}
+
+// Backlog returns the number of logs held by all the lanes and pipelines waiting to be sent
+// and when the oldest log being sent entered its pipeline.
+func (p *provider) Backlog() metrics.Backlog {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var backlog metrics.Backlog
+	for _, lane := range p.lanes {
+		backlog.Buffered += int64(len(lane.input))
+	}
+	for _, pipeline := range p.pipelines {
+		backlog = backlog.Add(pipeline.Backlog())
+	}
+	return backlog
 }
@@ -6,14 +6,21 @@
 package pipeline
 
 import (
+	"context"
+	"fmt"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 )
 
 type ProviderTestSuite struct {
@@ -25,10 +32,11 @@ type ProviderTestSuite struct {
 func (suite *ProviderTestSuite) SetupTest() {
 	suite.a = auditor.New("", health.Register("fake"))
 	suite.p = &provider{
-		numberOfPipelines: 3,
-		auditor:           suite.a,
-		pipelines:         []*Pipeline{},
-		endpoints:         client.NewEndpoints(client.Endpoint{}, nil),
+		numberOfPipelines:   3,
+		auditor:             suite.a,
+		pipelines:           []*Pipeline{},
+		endpoints:           client.NewEndpoints(client.Endpoint{}, nil),
+		destinationsContext: client.NewDestinationsContext(nil),
 	}
 }
 
@@ -54,6 +62,208 @@ func (suite *ProviderTestSuite) TestProvider() {
 	suite.Nil(suite.p.NextPipelineChan())
 }
 
+func (suite *ProviderTestSuite) TestProviderPinsTheKeysToAPipeline() {
+	suite.a.Start()
+	suite.p.Start()
+	defer func() {
+		suite.p.Stop()
+		suite.a.Stop()
+	}()
+
+	c := suite.p.PipelineChanFor("/var/log/foo.log")
+	for i := 0; i < 5; i++ {
+		suite.Equal(c, suite.p.PipelineChanFor("/var/log/foo.log"))
+	}
+	suite.Equal(int32(0), suite.p.currentPipelineIndex)
+
+	// the keys are spread across the pipelines
+	pipelines := make(map[chan *message.Message]bool)
+	for i := 0; i < 100; i++ {
+		pipelines[suite.p.PipelineChanFor(fmt.Sprintf("/var/log/%d.log", i))] = true
+	}
+	suite.Len(pipelines, 3)
+}
+
+func (suite *ProviderTestSuite) TestProviderRoundRobinsTheKeysWhenNotPinned() {
+	config.LogsAgent.Set("logs_config.pipeline_affinity", false)
+	defer config.LogsAgent.Set("logs_config.pipeline_affinity", true)
+	suite.a.Start()
+	suite.p.Start()
+	defer func() {
+		suite.p.Stop()
+		suite.a.Stop()
+	}()
+
+	c := suite.p.PipelineChanFor("/var/log/foo.log")
+	suite.NotEqual(c, suite.p.PipelineChanFor("/var/log/foo.log"))
+	suite.Equal(int32(2), suite.p.currentPipelineIndex)
+}
+
+func (suite *ProviderTestSuite) TestProviderHealth() {
+	suite.a.Start()
+	suite.p.Start()
+	defer func() {
+		suite.p.Stop()
+		suite.a.Stop()
+	}()
+
+	health := suite.p.Health()
+	suite.Equal(0, health.BlockedPipelines)
+	suite.Equal(0, health.BackpressuredPipelines)
+	// the destination shared by the pipelines never sent any logs
+	suite.Len(health.LastSuccessfulSends, 1)
+	for _, lastSuccess := range health.LastSuccessfulSends {
+		suite.True(lastSuccess.IsZero())
+	}
+
+}
+
+func (suite *ProviderTestSuite) TestProviderBufferUtilization() {
+	suite.a.Start()
+	suite.p.Start()
+	defer func() {
+		suite.p.Stop()
+		suite.a.Stop()
+	}()
+
+	// no logs are waiting in the buffers of the pipelines
+	suite.Equal(BufferUtilization{}, suite.p.BufferUtilization())
+	suite.Equal(metrics.Backlog{}, suite.p.Backlog())
+}
+
+func (suite *ProviderTestSuite) TestProviderScale() {
+	config.LogsAgent.Set("logs_config.autoscale.enabled", true)
+	config.LogsAgent.Set("logs_config.autoscale.interval", 3600)
+	config.LogsAgent.Set("logs_config.autoscale.max_pipelines", 5)
+	defer func() {
+		config.LogsAgent.Set("logs_config.autoscale.enabled", false)
+		config.LogsAgent.Set("logs_config.autoscale.interval", 10)
+		config.LogsAgent.Set("logs_config.autoscale.max_pipelines", 8)
+	}()
+	suite.a.Start()
+	suite.p.Start()
+	defer func() {
+		suite.p.Stop()
+		suite.a.Stop()
+	}()
+	// there is a lane per pipeline the provider can be scaled to
+	suite.Len(suite.p.lanes, 5)
+	suite.Equal([]int{0, 1, 2, 0, 1}, suite.p.bindings)
+
+	keys := make(map[string]chan *message.Message)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("/var/log/%d.log", i)
+		keys[key] = suite.p.PipelineChanFor(key)
+	}
+
+	// the pipelines cannot be scaled beyond the number of lanes
+	suite.p.Scale(6)
+	suite.Len(suite.p.pipelines, 5)
+
+	suite.p.Scale(2)
+	suite.Len(suite.p.pipelines, 2)
+	suite.Equal([]int{0, 1, 0, 0, 1}, suite.p.bindings)
+	for i, lane := range suite.p.lanes {
+		suite.Equal(suite.p.pipelines[suite.p.bindings[i]], lane.pipeline)
+	}
+	// the keys stay pinned to the same lane
+	for key, c := range keys {
+		suite.Equal(c, suite.p.PipelineChanFor(key))
+	}
+
+	// there is always at least one pipeline
+	suite.p.Scale(0)
+	suite.Len(suite.p.pipelines, 1)
+	suite.Equal([]int{0, 0, 0, 0, 0}, suite.p.bindings)
+}
+
+func (suite *ProviderTestSuite) TestProviderScaleUpMovesTheLogsOfTheInputsToThePipelinesAdded() {
+	config.LogsAgent.Set("logs_config.autoscale.enabled", true)
+	config.LogsAgent.Set("logs_config.autoscale.interval", 3600)
+	config.LogsAgent.Set("logs_config.autoscale.max_pipelines", 2)
+	defer func() {
+		config.LogsAgent.Set("logs_config.autoscale.enabled", false)
+		config.LogsAgent.Set("logs_config.autoscale.interval", 10)
+		config.LogsAgent.Set("logs_config.autoscale.max_pipelines", 8)
+	}()
+	l := mock.NewMockLogsIntake(suite.T())
+	defer l.Close()
+	suite.p.endpoints = client.NewEndpoints(client.AddrToEndPoint(l.Addr()), nil)
+	suite.p.destinationsContext.Start()
+	defer suite.p.destinationsContext.Stop()
+	suite.p.numberOfPipelines = 1
+	suite.a.Start()
+	suite.p.Start()
+	defer func() {
+		suite.p.Stop()
+		suite.a.Stop()
+	}()
+
+	// two inputs share the only pipeline
+	source := config.NewLogSource("", &config.LogsConfig{})
+	inputs := []chan *message.Message{suite.p.NextPipelineChan(), suite.p.NextPipelineChan()}
+	send := func() {
+		for i := 0; i < 10; i++ {
+			for _, input := range inputs {
+				input <- message.NewMessage([]byte("hello"), message.NewOrigin(source), "")
+			}
+		}
+		suite.Nil(suite.p.Flush(context.Background()))
+	}
+	send()
+	suite.Equal(int64(20), suite.p.pipelines[0].Throughput().Sent)
+
+	// the pipeline added gets one of the inputs started before
+	suite.p.Scale(2)
+	send()
+	suite.Equal(int64(30), suite.p.pipelines[0].Throughput().Sent)
+	suite.Equal(int64(10), suite.p.pipelines[1].Throughput().Sent)
+}
+
+func (suite *ProviderTestSuite) TestProviderScaleBeforeStart() {
+	suite.p.Scale(2)
+	suite.a.Start()
+	suite.p.Start()
+	suite.Len(suite.p.pipelines, 2)
+	suite.p.Stop()
+	suite.a.Stop()
+}
+
+func (suite *ProviderTestSuite) TestProviderAutoscaleKeepsThePipelinesWithinTheBounds() {
+	config.LogsAgent.Set("logs_config.autoscale.enabled", true)
+	config.LogsAgent.Set("logs_config.autoscale.max_pipelines", 2)
+	defer func() {
+		config.LogsAgent.Set("logs_config.autoscale.enabled", false)
+		config.LogsAgent.Set("logs_config.autoscale.max_pipelines", 8)
+	}()
+	suite.a.Start()
+	suite.p.Start()
+	suite.Equal(2, suite.p.pipelineCount())
+	suite.p.Stop()
+	suite.a.Stop()
+}
+
+func TestJumpHashOnlyMovesTheKeysOfTheBucketsRemoved(t *testing.T) {
+	for key := uint64(0); key < 1000; key++ {
+		bucket := jumpHash(key, 5)
+		assert.True(t, bucket >= 0 && bucket < 5)
+		if bucket < 4 {
+			assert.Equal(t, bucket, jumpHash(key, 4))
+		}
+	}
+	assert.Equal(t, 0, jumpHash(42, 1))
+}
+
+func TestBalanceMovesAsFewLanesAsPossible(t *testing.T) {
+	// the lanes of the pipelines removed are spread across the remaining ones
+	assert.Equal(t, []int{0, 1, 0, 0, 1}, balance([]int{0, 1, 2, 0, 1}, 2))
+	// the pipelines added get the lanes above the share of the others
+	assert.Equal(t, []int{0, 1, 0, 1, 2, 3}, balance([]int{0, 1, 0, 1, 0, 1}, 4))
+	// the lanes are already balanced
+	assert.Equal(t, []int{0, 1, 2, 0}, balance([]int{0, 1, 2, 0}, 3))
+	assert.Equal(t, []int{0, 0, 0}, balance([]int{0, 1, 2}, 1))
+}
+
 func TestProviderTestSuite(t *testing.T) {
 	suite.Run(t, new(ProviderTestSuite))
 }
@@ -0,0 +1,195 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
+)
+
+// Tee duplicates the decoded messages of the sources configured with tees
+// to additional pipelines, one per tee, that process them with their own rules
+// and send them to their own destinations.
+// Each duplicated message holds its own copy of the content and each additional pipeline
+// buffers up to 2 * config.ChanSize messages, so the memory used to collect a source
+// grows linearly with its number of tees. A slow tee destination also slows down
+// the collection of the source as the messages are duplicated synchronously.
+type Tee struct {
+	outputChan          chan *message.Message
+	endpoints           *client.Endpoints
+	destinationsContext *client.DestinationsContext
+	branches            map[*config.LogSource][]*branch
+	// mu guards branches, it is never held while a message is sent to a branch
+	// so that a slow tee does not block the release of the branches of the other sources
+	mu sync.RWMutex
+	// releasing counts the branches of the sources removed being stopped
+	releasing sync.WaitGroup
+}
+
+// branch represents the additional pipeline of a tee.
+type branch struct {
+	source   *config.LogSource
+	pipeline *Pipeline
+	// forwarding counts the messages being sent to the pipeline, it is stopped once they are sent
+	forwarding sync.WaitGroup
+}
+
+// NewTee returns a new Tee, the additional pipelines send their messages to outputChan
+// and inherit the network settings of endpoints.
+func NewTee(outputChan chan *message.Message, endpoints *client.Endpoints, destinationsContext *client.DestinationsContext) *Tee {
+	return &Tee{
+		outputChan:          outputChan,
+		endpoints:           endpoints,
+		destinationsContext: destinationsContext,
+		branches:            make(map[*config.LogSource][]*branch),
+	}
+}
+
+// Forward sends a copy of msg to the pipeline of each tee of its source,
+// the pipelines are started on the first message of the source, unless it is removed.
+func (t *Tee) Forward(msg *message.Message) {
+	source := msg.Origin.LogSource
+	if len(source.Config.Tee) == 0 {
+		return
+	}
+	if !t.hasBranches(source) {
+		t.addBranches(source)
+	}
+	t.mu.RLock()
+	branches := t.branches[source]
+	// the sends are counted before the branches can be released
+	for _, branch := range branches {
+		branch.forwarding.Add(1)
+	}
+	t.mu.RUnlock()
+	for _, branch := range branches {
+		branch.pipeline.InputChan <- branch.copy(msg)
+		branch.forwarding.Done()
+	}
+}
+
+// Release stops the additional pipelines of source in the background once their messages are sent,
+// the next messages of source are not duplicated anymore if it is removed.
+// This call does not wait for the messages being forwarded to the pipelines.
+func (t *Tee) Release(source *config.LogSource) {
+	t.mu.Lock()
+	branches := t.branches[source]
+	delete(t.branches, source)
+	if len(branches) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	// the release is counted before Stop can wait for it
+	t.releasing.Add(1)
+	t.mu.Unlock()
+	go func() {
+		defer t.releasing.Done()
+		stopBranches(branches)
+	}()
+}
+
+// Stop stops all the additional pipelines in parallel,
+// this call blocks until all pipelines are flushed.
+func (t *Tee) Stop() {
+	t.mu.Lock()
+	var branches []*branch
+	for _, sourceBranches := range t.branches {
+		branches = append(branches, sourceBranches...)
+	}
+	t.branches = make(map[*config.LogSource][]*branch)
+	t.mu.Unlock()
+	stopBranches(branches)
+	t.releasing.Wait()
+}
+
+// stopBranches stops the pipelines of branches in parallel once the messages being forwarded to them are sent.
+func stopBranches(branches []*branch) {
+	stopper := restart.NewParallelStopper()
+	for _, branch := range branches {
+		branch.forwarding.Wait()
+		stopper.Add(branch.pipeline)
+	}
+	stopper.Stop()
+}
+
+// hasBranches returns true if the branches of source are started.
+func (t *Tee) hasBranches(source *config.LogSource) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, exists := t.branches[source]
+	return exists
+}
+
+// addBranches starts the branches of source if they are not started yet and it is not removed.
+func (t *Tee) addBranches(source *config.LogSource) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, exists := t.branches[source]; exists || source.IsRemoved() {
+		return
+	}
+	var branches []*branch
+	for _, teeConfig := range source.Config.Tee {
+		branches = append(branches, t.newBranch(source, teeConfig))
+	}
+	t.branches[source] = branches
+}
+
+// newBranch starts a new pipeline for the tee of source.
+func (t *Tee) newBranch(source *config.LogSource, teeConfig config.TeeConfig) *branch {
+	logsConfig := *source.Config
+	logsConfig.ProcessingRules = teeConfig.ProcessingRules
+	logsConfig.Tee = nil
+
+	pipeline := NewPipeline(t.outputChan, t.buildEndpoints(teeConfig.Endpoints), t.destinationsContext, nil, nil)
+	pipeline.Start()
+
+	return &branch{
+		source:   config.NewLogSource(source.Name, &logsConfig),
+		pipeline: pipeline,
+	}
+}
+
+// buildEndpoints returns the endpoints of a tee with the same network settings as the main ones,
+// the logs of a tee are sent as JSON when its first endpoint sets it.
+func (t *Tee) buildEndpoints(teeEndpoints []client.Endpoint) *client.Endpoints {
+	endpoints := make([]client.Endpoint, len(teeEndpoints))
+	useJSON := teeEndpoints[0].UseJSON || t.endpoints.Main.UseJSON
+	jsonRawMessage := teeEndpoints[0].JSONRawMessage || t.endpoints.Main.JSONRawMessage
+	for i, endpoint := range teeEndpoints {
+		endpoint.UseSSL = t.endpoints.Main.UseSSL
+		endpoint.UseProto = t.endpoints.Main.UseProto && !useJSON
+		endpoint.UseCEF = t.endpoints.Main.UseCEF && !useJSON
+		endpoint.UseJSON = useJSON
+		endpoint.JSONRawMessage = jsonRawMessage
+		if endpoint.ProxyAddress == "" && endpoint.HTTPProxy == "" {
+			endpoint.ProxyAddress = t.endpoints.Main.ProxyAddress
+			endpoint.HTTPProxy = t.endpoints.Main.HTTPProxy
+		}
+		endpoints[i] = endpoint
+	}
+	return client.NewEndpoints(endpoints[0], endpoints[1:])
+}
+
+// copy returns a copy of msg to be processed with the rules of the branch.
+func (b *branch) copy(msg *message.Message) *message.Message {
+	content := make([]byte, len(msg.Content))
+	copy(content, msg.Content)
+	origin := *msg.Origin
+	origin.LogSource = b.source
+	// the offsets are only tracked for the messages of the main pipeline
+	origin.Identifier = ""
+	// the copies without status get the default status of the source too
+	dup := message.NewMessage(content, &origin, "")
+	if msg.HasStatus() {
+		dup.SetStatus(msg.GetStatus())
+	}
+	dup.Timestamp = msg.Timestamp
+	return dup
+}
@@ -0,0 +1,218 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func newTeeMessage(content string, source *config.LogSource) *message.Message {
+	origin := message.NewOrigin(source)
+	origin.Identifier = "file:/var/log/foo.log"
+	origin.Offset = "42"
+	return message.NewMessage([]byte(content), origin, message.StatusInfo)
+}
+
+func TestTeeForwardsCopiesProcessedWithTheirOwnRules(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	host, port := client.AddrToHostPort(l.Addr())
+
+	rules := []config.ProcessingRule{{Name: "mask", Type: config.MaskSequences, Pattern: "secret", ReplacePlaceholder: "[masked]"}}
+	logsConfig := &config.LogsConfig{
+		Tee: []config.TeeConfig{{Name: "analytics", ProcessingRules: rules, Endpoints: []client.Endpoint{{Host: host, Port: port}}}},
+	}
+	assert.Nil(t, logsConfig.Compile())
+	source := config.NewLogSource("foo", logsConfig)
+
+	destinationsContext := client.NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+
+	outputChan := make(chan *message.Message, 10)
+	tee := NewTee(outputChan, client.NewEndpoints(client.Endpoint{}, nil), destinationsContext)
+	msg := newTeeMessage("my secret", source)
+	tee.Forward(msg)
+
+	conn, err := l.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Contains(t, line, "my [masked]")
+	assert.Equal(t, "my secret", string(msg.Content))
+
+	output := <-outputChan
+	assert.Equal(t, "", output.Origin.Identifier)
+	assert.Equal(t, 0, len(output.Origin.LogSource.Config.Tee))
+
+	tee.Stop()
+	assert.Equal(t, 0, len(tee.branches))
+}
+
+func TestTeeReleasesTheBranchesOfTheSourcesRemoved(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	host, port := client.AddrToHostPort(l.Addr())
+
+	logsConfig := &config.LogsConfig{
+		Type: config.FileType,
+		Tee:  []config.TeeConfig{{Name: "analytics", Endpoints: []client.Endpoint{{Host: host, Port: port}}}},
+	}
+	assert.Nil(t, logsConfig.Compile())
+	source := config.NewLogSource("foo", logsConfig)
+	sources := config.NewLogSources()
+	sources.AddSource(source)
+
+	destinationsContext := client.NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+
+	outputChan := make(chan *message.Message, 10)
+	tee := NewTee(outputChan, client.NewEndpoints(client.Endpoint{}, nil), destinationsContext)
+	sources.OnRemoved(tee.Release)
+	tee.Forward(newTeeMessage("foo", source))
+	assert.Equal(t, 1, len(tee.branches))
+
+	sources.RemoveSource(source)
+	assert.Equal(t, 0, len(tee.branches))
+	// the messages of the source still in flight are not duplicated anymore
+	tee.Forward(newTeeMessage("bar", source))
+	assert.Equal(t, 0, len(tee.branches))
+
+	// the branches released are stopped once their messages are sent
+	conn, err := l.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Contains(t, line, "foo")
+	tee.Stop()
+	assert.Equal(t, 1, len(outputChan))
+}
+
+func TestTeeReleaseDoesNotWaitForTheMessagesBeingForwarded(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	host, port := client.AddrToHostPort(l.Addr())
+
+	logsConfig := &config.LogsConfig{
+		Type: config.FileType,
+		Tee:  []config.TeeConfig{{Name: "analytics", Endpoints: []client.Endpoint{{Host: host, Port: port}}}},
+	}
+	assert.Nil(t, logsConfig.Compile())
+	source := config.NewLogSource("foo", logsConfig)
+	sources := config.NewLogSources()
+	sources.AddSource(source)
+
+	destinationsContext := client.NewDestinationsContext(nil)
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+
+	tee := NewTee(make(chan *message.Message, 10), client.NewEndpoints(client.Endpoint{}, nil), destinationsContext)
+	sources.OnRemoved(tee.Release)
+	// the input of the branch is not read so the messages forwarded to it block
+	teeBranch := tee.newBranch(source, logsConfig.Tee[0])
+	blocked := make(chan *message.Message)
+	teeBranch.pipeline.InputChan, teeBranch.pipeline.processorChan = blocked, blocked
+	tee.branches[source] = []*branch{teeBranch}
+
+	forwarded := make(chan struct{})
+	go func() {
+		tee.Forward(newTeeMessage("foo", source))
+		close(forwarded)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	removed := make(chan struct{})
+	go func() {
+		sources.RemoveSource(source)
+		close(removed)
+	}()
+	select {
+	case <-removed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the source should be removed while a message is forwarded to its tee")
+	}
+
+	select {
+	case <-blocked:
+	case <-forwarded:
+	}
+	<-forwarded
+	tee.Stop()
+}
+
+func TestTeeDoesNotForwardMessagesOfSourcesWithoutTee(t *testing.T) {
+	tee := NewTee(nil, client.NewEndpoints(client.Endpoint{}, nil), client.NewDestinationsContext(nil))
+	tee.Forward(newTeeMessage("foo", config.NewLogSource("foo", &config.LogsConfig{})))
+	assert.Equal(t, 0, len(tee.branches))
+}
+
+func TestBranchCopy(t *testing.T) {
+	source := config.NewLogSource("foo", &config.LogsConfig{})
+	teeSource := config.NewLogSource("foo", &config.LogsConfig{})
+	msg := newTeeMessage("foo", source)
+	msg.Origin.SetTags([]string{"foo:bar"})
+	msg.Timestamp = "2018-01-01T00:00:00Z"
+
+	dup := (&branch{source: teeSource}).copy(msg)
+	assert.Equal(t, msg.Content, dup.Content)
+	dup.Content[0] = 'b'
+	assert.Equal(t, "foo", string(msg.Content))
+	assert.Equal(t, teeSource, dup.Origin.LogSource)
+	assert.Equal(t, "", dup.Origin.Identifier)
+	assert.Equal(t, "file:/var/log/foo.log", msg.Origin.Identifier)
+	assert.Equal(t, []string{"foo:bar"}, dup.Origin.Tags())
+	assert.Equal(t, msg.GetStatus(), dup.GetStatus())
+	assert.Equal(t, msg.Timestamp, dup.Timestamp)
+
+	// the copies without status get the default status of the source
+	msg.SetStatus("")
+	dup = (&branch{source: teeSource}).copy(msg)
+	assert.False(t, dup.HasStatus())
+}
+
+func TestTeeBuildEndpointsInheritsNetworkSettings(t *testing.T) {
+	main := client.Endpoint{UseSSL: true, UseProto: true, ProxyAddress: "proxy:1080"}
+	tee := NewTee(nil, client.NewEndpoints(main, nil), nil)
+	endpoints := tee.buildEndpoints([]client.Endpoint{{Host: "foo", Port: 1}, {Host: "bar", Port: 2}})
+	assert.Equal(t, "foo", endpoints.Main.Host)
+	assert.True(t, endpoints.Main.UseSSL)
+	assert.True(t, endpoints.Main.UseProto)
+	assert.Equal(t, "proxy:1080", endpoints.Main.ProxyAddress)
+	assert.Equal(t, 1, len(endpoints.Additionals))
+	assert.Equal(t, "bar", endpoints.Additionals[0].Host)
+	assert.True(t, endpoints.Additionals[0].UseSSL)
+
+	// the endpoints setting their own proxy keep it
+	endpoints = tee.buildEndpoints([]client.Endpoint{{Host: "foo", Port: 1, HTTPProxy: "http://proxy:3128"}})
+	assert.Equal(t, "", endpoints.Main.ProxyAddress)
+	assert.Equal(t, "http://proxy:3128", endpoints.Main.HTTPProxy)
+}
+
+func TestTeeBuildEndpointsSelectsJSON(t *testing.T) {
+	main := client.Endpoint{UseProto: true}
+	tee := NewTee(nil, client.NewEndpoints(main, nil), nil)
+	endpoints := tee.buildEndpoints([]client.Endpoint{{Host: "foo", Port: 1, UseJSON: true, JSONRawMessage: true}, {Host: "bar", Port: 2}})
+	assert.True(t, endpoints.Main.UseJSON)
+	assert.True(t, endpoints.Main.JSONRawMessage)
+	assert.False(t, endpoints.Main.UseProto)
+	assert.True(t, endpoints.Additionals[0].UseJSON)
+	assert.False(t, endpoints.Additionals[0].UseProto)
+}
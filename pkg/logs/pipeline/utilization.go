@@ -0,0 +1,146 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// BufferUtilization holds the fill level of the buffers of the pipelines, in percentage of their capacity,
+// the input buffer holds the logs waiting to be processed and the sender buffer the logs waiting to be sent.
+type BufferUtilization struct {
+	MaxInput  float64
+	AvgInput  float64
+	MaxSender float64
+	AvgSender float64
+}
+
+// aggregateUtilization returns the max and average of the fill levels of the buffers of the pipelines.
+func aggregateUtilization(pipelines []BufferUtilization) BufferUtilization {
+	var aggregate BufferUtilization
+	if len(pipelines) == 0 {
+		return aggregate
+	}
+	for _, u := range pipelines {
+		if u.MaxInput > aggregate.MaxInput {
+			aggregate.MaxInput = u.MaxInput
+		}
+		if u.MaxSender > aggregate.MaxSender {
+			aggregate.MaxSender = u.MaxSender
+		}
+		aggregate.AvgInput += u.AvgInput
+		aggregate.AvgSender += u.AvgSender
+	}
+	aggregate.AvgInput /= float64(len(pipelines))
+	aggregate.AvgSender /= float64(len(pipelines))
+	return aggregate
+}
+
+// chanUtilization returns the fill level of the buffer of ch in percentage of its capacity.
+func chanUtilization(ch chan *message.Message) float64 {
+	if cap(ch) == 0 {
+		return 0
+	}
+	return 100 * float64(len(ch)) / float64(cap(ch))
+}
+
+// utilizationSampler periodically reports the fill level of the buffers of the pipelines and their backlog,
+// and warns when a buffer of a pipeline reaches the threshold, before the inputs get blocked.
+type utilizationSampler struct {
+	interval  time.Duration
+	threshold float64
+	sample    func() BufferUtilization
+	backlog   func() metrics.Backlog
+	// congested is true while a buffer is above the threshold, to warn only once per congestion
+	congested bool
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newUtilizationSampler returns a sampler calling sample and backlog every interval, it does nothing when interval is 0.
+func newUtilizationSampler(interval time.Duration, threshold float64, sample func() BufferUtilization, backlog func() metrics.Backlog) *utilizationSampler {
+	return &utilizationSampler{
+		interval:  interval,
+		threshold: threshold,
+		sample:    sample,
+		backlog:   backlog,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start starts sampling the buffers.
+func (s *utilizationSampler) Start() {
+	if s.interval <= 0 {
+		close(s.done)
+		return
+	}
+	go s.run()
+}
+
+// Stop stops sampling the buffers, the last values reported are reset.
+func (s *utilizationSampler) Stop() {
+	close(s.stop)
+	<-s.done
+	s.report(BufferUtilization{})
+	s.reportBacklog(metrics.Backlog{})
+}
+
+// run samples the buffers every interval until stopped.
+func (s *utilizationSampler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.check(s.sample())
+			s.reportBacklog(s.backlog())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// check reports utilization and warns when it crosses the threshold.
+func (s *utilizationSampler) check(utilization BufferUtilization) {
+	s.report(utilization)
+	congested := utilization.MaxInput >= s.threshold || utilization.MaxSender >= s.threshold
+	switch {
+	case congested && !s.congested:
+		log.Warnf("The buffers of the pipelines are filling up, input: %.0f%%, sender: %.0f%%, the inputs will be blocked or logs dropped once they are full", utilization.MaxInput, utilization.MaxSender)
+	case !congested && s.congested:
+		log.Infof("The buffers of the pipelines are back below %.0f%%", s.threshold)
+	}
+	s.congested = congested
+}
+
+// report sets the metrics of the fill level of the buffers.
+func (s *utilizationSampler) report(utilization BufferUtilization) {
+	setFloat(&metrics.InputBufferUtilization, "max", utilization.MaxInput)
+	setFloat(&metrics.InputBufferUtilization, "avg", utilization.AvgInput)
+	setFloat(&metrics.SenderBufferUtilization, "max", utilization.MaxSender)
+	setFloat(&metrics.SenderBufferUtilization, "avg", utilization.AvgSender)
+}
+
+// reportBacklog sets the metrics of the logs waiting to be sent.
+func (s *utilizationSampler) reportBacklog(backlog metrics.Backlog) {
+	metrics.LogsBuffered.Set(backlog.Buffered)
+	metrics.OldestLogAge.Set(backlog.OldestAge(time.Now()).Seconds())
+}
+
+// setFloat sets the value of key in m.
+func setFloat(m *expvar.Map, key string, value float64) {
+	v := new(expvar.Float)
+	v.Set(value)
+	m.Set(key, v)
+}
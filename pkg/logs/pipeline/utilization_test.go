@@ -0,0 +1,77 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func TestPipelineBufferUtilization(t *testing.T) {
+	p := &Pipeline{InputChan: make(chan *message.Message, 4), senderChan: make(chan *message.Message, 2)}
+	assert.Equal(t, BufferUtilization{}, p.BufferUtilization())
+
+	p.InputChan <- message.NewMessage([]byte("foo"), nil, "")
+	p.senderChan <- message.NewMessage([]byte("bar"), nil, "")
+	assert.Equal(t, BufferUtilization{MaxInput: 25, AvgInput: 25, MaxSender: 50, AvgSender: 50}, p.BufferUtilization())
+}
+
+func TestAggregateUtilization(t *testing.T) {
+	assert.Equal(t, BufferUtilization{}, aggregateUtilization(nil))
+	utilization := aggregateUtilization([]BufferUtilization{
+		{MaxInput: 80, AvgInput: 80, MaxSender: 0, AvgSender: 0},
+		{MaxInput: 20, AvgInput: 20, MaxSender: 50, AvgSender: 50},
+	})
+	assert.Equal(t, BufferUtilization{MaxInput: 80, AvgInput: 50, MaxSender: 50, AvgSender: 25}, utilization)
+}
+
+func TestUtilizationSamplerReportsTheUtilization(t *testing.T) {
+	samples := make(chan struct{}, 10)
+	sampler := newUtilizationSampler(time.Millisecond, 80, func() BufferUtilization {
+		samples <- struct{}{}
+		return BufferUtilization{MaxInput: 90, AvgInput: 45, MaxSender: 10, AvgSender: 5}
+	}, func() metrics.Backlog {
+		return metrics.Backlog{Buffered: 3, Oldest: time.Now().Add(-time.Minute)}
+	})
+	sampler.Start()
+	<-samples
+	<-samples
+	sampler.Stop()
+	// the values reported are reset once stopped
+	assert.Equal(t, `{"avg": 0, "max": 0}`, metrics.InputBufferUtilization.String())
+	assert.Equal(t, int64(0), metrics.LogsBuffered.Value())
+	assert.Equal(t, float64(0), metrics.OldestLogAge.Value())
+
+	sampler.check(BufferUtilization{MaxInput: 90, AvgInput: 45, MaxSender: 10, AvgSender: 5})
+	assert.True(t, sampler.congested)
+	assert.Equal(t, `{"avg": 45, "max": 90}`, metrics.InputBufferUtilization.String())
+	assert.Equal(t, `{"avg": 5, "max": 10}`, metrics.SenderBufferUtilization.String())
+	sampler.check(BufferUtilization{MaxInput: 50, AvgInput: 25})
+	assert.False(t, sampler.congested)
+	sampler.report(BufferUtilization{})
+
+	sampler.reportBacklog(metrics.Backlog{Buffered: 3, Oldest: time.Now().Add(-time.Minute)})
+	assert.Equal(t, int64(3), metrics.LogsBuffered.Value())
+	assert.True(t, metrics.OldestLogAge.Value() >= 60)
+	sampler.reportBacklog(metrics.Backlog{})
+}
+
+func TestUtilizationSamplerDisabled(t *testing.T) {
+	sampler := newUtilizationSampler(0, 80, func() BufferUtilization {
+		assert.Fail(t, "the buffers should not be sampled")
+		return BufferUtilization{}
+	}, func() metrics.Backlog {
+		assert.Fail(t, "the backlog should not be sampled")
+		return metrics.Backlog{}
+	})
+	sampler.Start()
+	sampler.Stop()
+}
@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// accessLogAttributes maps the access log fields to the attributes they are emitted as.
+var accessLogAttributes = map[string]string{
+	config.AccessLogClientIP:     "network.client.ip",
+	config.AccessLogIdent:        "http.ident",
+	config.AccessLogAuth:         "http.auth",
+	config.AccessLogDate:         "date_access",
+	config.AccessLogStatusCode:   "http.status_code",
+	config.AccessLogBytesWritten: "network.bytes_written",
+	config.AccessLogReferer:      "http.referer",
+	config.AccessLogUserAgent:    "http.useragent",
+}
+
+// parseAccessLog adds the fields of content to the attributes of msg
+// when it matches the access log regular expression reg, otherwise msg is left as is.
+func parseAccessLog(msg *message.Message, content []byte, reg *regexp.Regexp) {
+	matches := reg.FindSubmatch(content)
+	if matches == nil {
+		return
+	}
+	if msg.Attributes == nil {
+		msg.Attributes = make(map[string]interface{})
+	}
+	for i, field := range reg.SubexpNames() {
+		value := string(matches[i])
+		if field == "" || value == "" || value == "-" {
+			// the value is missing
+			continue
+		}
+		switch field {
+		case config.AccessLogRequest:
+			parseRequestLine(msg.Attributes, value)
+		case config.AccessLogStatusCode, config.AccessLogBytesWritten:
+			if number, err := strconv.Atoi(value); err == nil {
+				msg.Attributes[accessLogAttributes[field]] = number
+			}
+		default:
+			msg.Attributes[accessLogAttributes[field]] = value
+		}
+	}
+}
+
+// parseRequestLine adds the method, the url and the version of a request line
+// like "GET /index.html HTTP/1.1" to attributes.
+func parseRequestLine(attributes map[string]interface{}, requestLine string) {
+	parts := strings.Fields(requestLine)
+	switch len(parts) {
+	case 1:
+		attributes["http.url"] = parts[0]
+	case 2:
+		attributes["http.method"] = parts[0]
+		attributes["http.url"] = parts[1]
+	case 3:
+		attributes["http.method"] = parts[0]
+		attributes["http.url"] = parts[1]
+		attributes["http.version"] = strings.TrimPrefix(parts[2], "HTTP/")
+	}
+}
+
+// maskAttributes applies a mask rule to the attributes of msg extracted by the rules applied before it,
+// which would otherwise hold what the rule masks in the content: the sequences matching a mask_sequences rule
+// are replaced in the string attributes and the attributes named after a key of a mask_json_keys rule,
+// the last part of their path, are replaced by the placeholder.
+func maskAttributes(msg *message.Message, rule config.ProcessingRule) {
+	for key, value := range msg.Attributes {
+		switch rule.Type {
+		case config.MaskSequences:
+			if s, isString := value.(string); isString {
+				msg.Attributes[key] = rule.Reg.ReplaceAllLiteralString(s, rule.ReplacePlaceholder)
+			}
+		case config.MaskJSONKeys:
+			if isMaskedKey([]byte(key[strings.LastIndexByte(key, '.')+1:]), rule.Keys) {
+				msg.Attributes[key] = rule.ReplacePlaceholder
+			}
+		}
+	}
+}
+
+// attributesLen returns the number of bytes withAttributes adds to a content, 0 without attributes.
+func attributesLen(attributes map[string]interface{}) int {
+	if len(attributes) == 0 {
+		return 0
+	}
+	return len(withAttributes(nil, attributes))
+}
+
+// withAttributes returns a JSON payload holding content as message along with attributes,
+// the dots of the attribute keys denote nested objects.
+func withAttributes(content []byte, attributes map[string]interface{}) []byte {
+	payload := map[string]interface{}{
+		"message": string(content),
+	}
+	for key, value := range attributes {
+		object := payload
+		path := strings.Split(key, ".")
+		for _, name := range path[:len(path)-1] {
+			child, isObject := object[name].(map[string]interface{})
+			if !isObject {
+				child = make(map[string]interface{})
+				object[name] = child
+			}
+			object = child
+		}
+		object[path[len(path)-1]] = value
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		// ensure the message has some content if the json encoding failed
+		return content
+	}
+	return encoded
+}
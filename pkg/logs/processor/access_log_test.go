@@ -0,0 +1,177 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func newAccessLogSource(t *testing.T, rule config.ProcessingRule) *config.LogSource {
+	rule.Name = "access_log"
+	rule.Type = config.ParseAccessLog
+	logsConfig := &config.LogsConfig{ProcessingRules: []config.ProcessingRule{rule}}
+	assert.Nil(t, logsConfig.Compile())
+	return config.NewLogSource("", logsConfig)
+}
+
+func TestParseApacheCommonAccessLog(t *testing.T) {
+	source := newAccessLogSource(t, config.ProcessingRule{Format: "common"})
+	msg := newMessage([]byte(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`), source, "")
+
+	shouldProcess, content := applyRedactingRules(msg)
+	assert.True(t, shouldProcess)
+	assert.Equal(t, msg.Content, content)
+	assert.Equal(t, map[string]interface{}{
+		"network.client.ip":     "127.0.0.1",
+		"http.auth":             "frank",
+		"date_access":           "10/Oct/2000:13:55:36 -0700",
+		"http.method":           "GET",
+		"http.url":              "/apache_pb.gif",
+		"http.version":          "1.0",
+		"http.status_code":      200,
+		"network.bytes_written": 2326,
+	}, msg.Attributes)
+}
+
+func TestParseApacheCombinedAccessLog(t *testing.T) {
+	source := newAccessLogSource(t, config.ProcessingRule{Format: "combined"})
+	msg := newMessage([]byte(`192.168.1.20 - - [28/Jul/2006:10:27:10 -0300] "GET /cgi-bin/try/ HTTP/1.0" 304 - "http://192.168.1.2/" "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)"`), source, "")
+
+	applyRedactingRules(msg)
+	assert.Equal(t, map[string]interface{}{
+		"network.client.ip": "192.168.1.20",
+		"date_access":       "28/Jul/2006:10:27:10 -0300",
+		"http.method":       "GET",
+		"http.url":          "/cgi-bin/try/",
+		"http.version":      "1.0",
+		"http.status_code":  304,
+		"http.referer":      "http://192.168.1.2/",
+		"http.useragent":    "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)",
+	}, msg.Attributes)
+}
+
+func TestParseNginxAccessLog(t *testing.T) {
+	source := newAccessLogSource(t, config.ProcessingRule{Format: "nginx"})
+	msg := newMessage([]byte(`172.17.0.1 - - [06/Jan/2017:16:16:37 +0000] "GET /datadoghq/company?test=var1%20Pl HTTP/1.1" 404 612 "http://www.perdu.com/" "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/55.0.2883.87 Safari/537.36"`), source, "")
+
+	applyRedactingRules(msg)
+	assert.Equal(t, "172.17.0.1", msg.Attributes["network.client.ip"])
+	assert.Equal(t, "/datadoghq/company?test=var1%20Pl", msg.Attributes["http.url"])
+	assert.Equal(t, 404, msg.Attributes["http.status_code"])
+	assert.Equal(t, 612, msg.Attributes["network.bytes_written"])
+	assert.Equal(t, "http://www.perdu.com/", msg.Attributes["http.referer"])
+}
+
+func TestParseCustomAccessLog(t *testing.T) {
+	source := newAccessLogSource(t, config.ProcessingRule{LogFormat: `$remote_addr [$time_local] "$request" $status $request_time "$http_user_agent"`})
+	msg := newMessage([]byte(`10.0.0.5 [06/Jan/2017:16:16:37 +0000] "POST /api/v1/series HTTP/2.0" 202 0.004 "curl/7.58.0"`), source, "")
+
+	applyRedactingRules(msg)
+	assert.Equal(t, map[string]interface{}{
+		"network.client.ip": "10.0.0.5",
+		"date_access":       "06/Jan/2017:16:16:37 +0000",
+		"http.method":       "POST",
+		"http.url":          "/api/v1/series",
+		"http.version":      "2.0",
+		"http.status_code":  202,
+		"http.useragent":    "curl/7.58.0",
+	}, msg.Attributes)
+}
+
+func TestParseMalformedAccessLogs(t *testing.T) {
+	source := newAccessLogSource(t, config.ProcessingRule{Format: "combined"})
+	malformedLines := []string{
+		"",
+		"hello world",
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700 "GET /apache_pb.gif HTTP/1.0" 200 2326 "-" "-"`,
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0 200 2326 "-" "-"`,
+	}
+	for _, line := range malformedLines {
+		msg := newMessage([]byte(line), source, "")
+		shouldProcess, content := applyRedactingRules(msg)
+		assert.True(t, shouldProcess)
+		assert.Equal(t, line, string(content))
+		assert.Nil(t, msg.Attributes)
+	}
+}
+
+func TestParseAccessLogWithMalformedRequestLine(t *testing.T) {
+	source := newAccessLogSource(t, config.ProcessingRule{Format: "common"})
+	msg := newMessage([]byte(`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "\x16\x03\x01" 400 -`), source, "")
+
+	applyRedactingRules(msg)
+	assert.Equal(t, map[string]interface{}{
+		"network.client.ip": "127.0.0.1",
+		"date_access":       "10/Oct/2000:13:55:36 -0700",
+		"http.url":          `\x16\x03\x01`,
+		"http.status_code":  400,
+	}, msg.Attributes)
+}
+
+func TestProcessorEmitsAccessLogAttributes(t *testing.T) {
+	source := newAccessLogSource(t, config.ProcessingRule{Format: "common"})
+	inputChan := make(chan *message.Message, 1)
+	outputChan := make(chan *message.Message, 1)
+	p := New(inputChan, outputChan, &rawEncoder, 1, nil, nil, nil, nil, nil)
+	p.Start()
+	defer p.Stop()
+
+	line := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`
+	inputChan <- newMessage([]byte(line), source, "")
+	msg := <-outputChan
+
+	// the payload follows the RFC5424 header
+	var payload map[string]interface{}
+	assert.Nil(t, json.Unmarshal(msg.Content[bytes.IndexByte(msg.Content, '{'):], &payload))
+	assert.Equal(t, line, payload["message"])
+	assert.Equal(t, map[string]interface{}{"client": map[string]interface{}{"ip": "127.0.0.1"}, "bytes_written": float64(2326)}, payload["network"])
+	assert.Equal(t, "GET", payload["http"].(map[string]interface{})["method"])
+	assert.Equal(t, float64(200), payload["http"].(map[string]interface{})["status_code"])
+}
+
+func TestProcessorMasksAccessLogAttributes(t *testing.T) {
+	logsConfig := &config.LogsConfig{ProcessingRules: []config.ProcessingRule{
+		{Type: config.ParseAccessLog, Name: "access_log", Format: "common"},
+		{Type: config.MaskSequences, Name: "mask_ip", Pattern: `\d+\.\d+\.\d+\.\d+`, ReplacePlaceholder: "[ip]"},
+		{Type: config.MaskJSONKeys, Name: "mask_auth", Keys: []string{"auth"}, ReplacePlaceholder: "[auth]"},
+	}}
+	assert.Nil(t, logsConfig.Compile())
+	source := config.NewLogSource("", logsConfig)
+	inputChan := make(chan *message.Message, 1)
+	outputChan := make(chan *message.Message, 1)
+	p := New(inputChan, outputChan, &rawEncoder, 1, nil, nil, nil, nil, nil)
+	p.Start()
+	defer p.Stop()
+
+	inputChan <- newMessage([]byte(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`), source, "")
+	msg := <-outputChan
+
+	// the attributes extracted before the mask rules are masked as the content
+	var payload map[string]interface{}
+	assert.Nil(t, json.Unmarshal(msg.Content[bytes.IndexByte(msg.Content, '{'):], &payload))
+	assert.Equal(t, `[ip] - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`, payload["message"])
+	assert.Equal(t, map[string]interface{}{"ip": "[ip]"}, payload["network"].(map[string]interface{})["client"])
+	assert.Equal(t, "[auth]", payload["http"].(map[string]interface{})["auth"])
+	assert.Equal(t, "GET", payload["http"].(map[string]interface{})["method"])
+}
+
+func TestWithAttributes(t *testing.T) {
+	payload := withAttributes([]byte("hello"), map[string]interface{}{
+		"http.method":       "GET",
+		"http.status_code":  200,
+		"network.client.ip": "127.0.0.1",
+		"date_access":       "today",
+	})
+	assert.JSONEq(t, `{"message":"hello","date_access":"today","http":{"method":"GET","status_code":200},"network":{"client":{"ip":"127.0.0.1"}}}`, string(payload))
+}
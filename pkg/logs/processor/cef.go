@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+// CEF is an encoder implementation that writes messages as Common Event Format records:
+// CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+// The attributes of the messages are written as extension key=value pairs, the content as msg.
+var cefEncoder cef
+
+// NewCEFEncoder returns an encoder that writes messages as CEF records.
+func NewCEFEncoder() Encoder {
+	return &cefEncoder
+}
+
+const (
+	cefVersion       = "CEF:0"
+	cefDeviceVendor  = "Datadog"
+	cefDeviceProduct = "Agent"
+	cefDefaultName   = "log"
+)
+
+// statusCEFSeverityMapping maps the statuses to CEF severities which range from 0 (lowest) to 10 (highest).
+var statusCEFSeverityMapping = map[string]int{
+	message.StatusEmergency: 10,
+	message.StatusAlert:     9,
+	message.StatusCritical:  8,
+	message.StatusError:     7,
+	message.StatusWarning:   5,
+	message.StatusNotice:    4,
+	message.StatusInfo:      3,
+	message.StatusDebug:     0,
+}
+
+// cefHeaderEscaper escapes the pipes and backslashes of the header fields.
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+
+// cefExtensionEscaper escapes the equal signs, backslashes and line breaks of the extension values.
+var cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+
+// cefKeyInvalidChars matches the characters not allowed in the extension keys.
+var cefKeyInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.]`)
+
+type cef struct{}
+
+// encodesAttributes marks the CEF encoder as writing the attributes of the messages itself.
+func (c *cef) encodesAttributes() {}
+
+func (c *cef) encode(msg *message.Message, redactedMsg []byte) ([]byte, error) {
+	name := msg.Origin.Service()
+	if name == "" {
+		name = cefDefaultName
+	}
+	signatureID := msg.Origin.Source()
+	if signatureID == "" {
+		signatureID = cefDefaultName
+	}
+
+	header := []string{
+		cefVersion,
+		cefDeviceVendor,
+		cefDeviceProduct,
+		cefHeaderEscaper.Replace(version.AgentVersion),
+		cefHeaderEscaper.Replace(signatureID),
+		cefHeaderEscaper.Replace(name),
+		strconv.Itoa(c.severity(msg.GetStatus())),
+	}
+
+	extension := []string{
+		"rt=" + strconv.FormatInt(timestampOf(msg).UnixNano()/int64(time.Millisecond), 10),
+		"dvchost=" + cefExtensionEscaper.Replace(getHostname()),
+	}
+	if tags := msg.Origin.Tags(); len(tags) > 0 {
+		extension = append(extension, "cs1Label=tags", "cs1="+cefExtensionEscaper.Replace(strings.Join(tags, ",")))
+	}
+	extension = append(extension, c.attributes(msg.Attributes)...)
+	extension = append(extension, "msg="+cefExtensionEscaper.Replace(string(redactedMsg)))
+
+	return []byte(strings.Join(header, "|") + "|" + strings.Join(extension, " ")), nil
+}
+
+// attributes returns the extension key=value pairs of attributes sorted by key,
+// the characters not allowed in the keys are replaced by underscores
+// and the values which are not strings are written as JSON.
+func (c *cef) attributes(attributes map[string]interface{}) []string {
+	pairs := make([]string, 0, len(attributes))
+	for key, value := range attributes {
+		var formatted string
+		switch v := value.(type) {
+		case string:
+			formatted = v
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				encoded = []byte(fmt.Sprint(v))
+			}
+			formatted = string(encoded)
+		}
+		pairs = append(pairs, cefKeyInvalidChars.ReplaceAllString(key, "_")+"="+cefExtensionEscaper.Replace(formatted))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// severity returns the CEF severity matching status.
+func (c *cef) severity(status string) int {
+	if severity, exists := statusCEFSeverityMapping[status]; exists {
+		return severity
+	}
+	return statusCEFSeverityMapping[message.StatusInfo]
+}
@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+func TestNewCEFEncoder(t *testing.T) {
+	assert.Equal(t, &cefEncoder, NewCEFEncoder())
+}
+
+func TestCEFEncoderMapsFields(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{
+		Service: "my-service",
+		Source:  "nginx",
+		Tags:    []string{"env:prod"},
+	})
+	msg := newMessage([]byte("message"), source, message.StatusError)
+	msg.Origin.SetTags([]string{"filename:access.log"})
+
+	record, err := cefEncoder.encode(msg, []byte("redacted"))
+	assert.Nil(t, err)
+
+	parts := strings.SplitN(string(record), "|", 8)
+	assert.Equal(t, 8, len(parts))
+	assert.Equal(t, "CEF:0", parts[0])
+	assert.Equal(t, "Datadog", parts[1])
+	assert.Equal(t, "Agent", parts[2])
+	assert.Equal(t, version.AgentVersion, parts[3])
+	assert.Equal(t, "nginx", parts[4])
+	assert.Equal(t, "my-service", parts[5])
+	assert.Equal(t, "7", parts[6])
+
+	extension := parts[7]
+	assert.True(t, strings.HasPrefix(extension, "rt="))
+	assert.Contains(t, extension, " dvchost=")
+	assert.Contains(t, extension, " cs1Label=tags cs1=filename:access.log,env:prod ")
+	assert.True(t, strings.HasSuffix(extension, " msg=redacted"))
+}
+
+func TestCEFEncoderUsesDefaultNames(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte("message"), source, "")
+
+	record, err := cefEncoder.encode(msg, []byte("message"))
+	assert.Nil(t, err)
+
+	parts := strings.SplitN(string(record), "|", 8)
+	assert.Equal(t, "log", parts[4])
+	assert.Equal(t, "log", parts[5])
+	assert.Equal(t, "3", parts[6])
+	assert.NotContains(t, parts[7], "cs1Label")
+}
+
+func TestCEFEncoderEscapesHeaderFields(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{
+		Service: `my|service\`,
+		Source:  "multi\nline",
+	})
+	msg := newMessage([]byte("message"), source, message.StatusInfo)
+
+	record, err := cefEncoder.encode(msg, []byte("message"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(record), `|multi line|my\|service\\|3|`)
+}
+
+func TestCEFEncoderEscapesExtensionValues(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte("message"), source, message.StatusInfo)
+
+	record, err := cefEncoder.encode(msg, []byte("a=b|c\\d\r\ne"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(record), ` msg=a\=b|c\\d\r\ne`))
+}
+
+func TestCEFSeverity(t *testing.T) {
+	assert.Equal(t, 10, cefEncoder.severity(message.StatusEmergency))
+	assert.Equal(t, 9, cefEncoder.severity(message.StatusAlert))
+	assert.Equal(t, 8, cefEncoder.severity(message.StatusCritical))
+	assert.Equal(t, 7, cefEncoder.severity(message.StatusError))
+	assert.Equal(t, 5, cefEncoder.severity(message.StatusWarning))
+	assert.Equal(t, 4, cefEncoder.severity(message.StatusNotice))
+	assert.Equal(t, 3, cefEncoder.severity(message.StatusInfo))
+	assert.Equal(t, 0, cefEncoder.severity(message.StatusDebug))
+	assert.Equal(t, 3, cefEncoder.severity("unknown"))
+}
+
+func TestCEFEncoderWritesAttributesAsExtensions(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte("message"), source, message.StatusInfo)
+	msg.Attributes = map[string]interface{}{
+		"http.status_code": 404,
+		"http.url":         "/a=b",
+		"user agent":       "curl",
+		"retried":          true,
+	}
+
+	record, err := cefEncoder.encode(msg, []byte(`{"raw": "content"}`))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(record), ` http.status_code=404 http.url=/a\=b retried=true user_agent=curl msg={"raw": "content"}`))
+}
+
+func TestProcessorDoesNotWrapTheAttributesForCEF(t *testing.T) {
+	inputChan := make(chan *message.Message, 1)
+	outputChan := make(chan *message.Message, 1)
+	p := New(inputChan, outputChan, NewCEFEncoder(), 1, nil, nil, nil, nil, nil)
+	p.Start()
+	defer p.Stop()
+
+	msg := newMessage([]byte("GET /"), config.NewLogSource("", &config.LogsConfig{}), message.StatusInfo)
+	msg.Attributes = map[string]interface{}{"http.method": "GET"}
+	inputChan <- msg
+	record := string((<-outputChan).Content)
+	assert.True(t, strings.HasSuffix(record, " http.method=GET msg=GET /"))
+}
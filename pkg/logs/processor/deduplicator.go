@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// FNV-1a parameters of the hashes of the contents of the messages.
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// Deduplicator drops the messages identical to a message of the same source sent within the window,
+// it is shared by the pipelines so that the duplicates are dropped whichever pipeline they go through.
+// The sources are identified by their name, so that a file tailed twice by the sources of an integration is deduplicated.
+// Only the hashes of the last messages of each source are remembered, up to size per source,
+// a message repeated after more than size other messages of its source is sent again.
+type Deduplicator struct {
+	window time.Duration
+	size   int
+	mu     sync.Mutex
+	// sources holds the hashes of the messages recently sent per source name,
+	// the sources without any message within the window are forgotten by the sweeps
+	sources   map[string]*hashLRU
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewDeduplicator returns a new deduplicator, or nil if window or size is not positive.
+func NewDeduplicator(window time.Duration, size int) *Deduplicator {
+	if window <= 0 || size <= 0 {
+		return nil
+	}
+	return &Deduplicator{
+		window:  window,
+		size:    size,
+		sources: make(map[string]*hashLRU),
+		now:     time.Now,
+	}
+}
+
+// isDuplicate returns true if a message with the same content as msg was sent by its source within the window,
+// otherwise msg is remembered.
+func (d *Deduplicator) isDuplicate(msg *message.Message) bool {
+	hash := hashContent(msg.Content)
+	name := msg.Origin.LogSource.Name
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	if now.Sub(d.lastSweep) > d.window {
+		d.sweep(now)
+	}
+	lru, exists := d.sources[name]
+	if !exists {
+		lru = newHashLRU(d.size)
+		d.sources[name] = lru
+	}
+	return lru.seen(hash, now, d.window)
+}
+
+// sweep forgets the sources without any message since the beginning of the window.
+func (d *Deduplicator) sweep(now time.Time) {
+	d.lastSweep = now
+	for name, lru := range d.sources {
+		if now.Sub(lru.lastSeen) > d.window {
+			delete(d.sources, name)
+		}
+	}
+}
+
+// hashContent returns the FNV-1a hash of content, computed inline to not allocate a hasher per message.
+func hashContent(content []byte) uint64 {
+	hash := uint64(offset64)
+	for _, b := range content {
+		hash ^= uint64(b)
+		hash *= prime64
+	}
+	return hash
+}
+
+// hashEntry is a hash remembered with the time its message was sent.
+type hashEntry struct {
+	hash uint64
+	sent time.Time
+}
+
+// hashLRU remembers up to size hashes, the least recently seen ones are evicted first.
+type hashLRU struct {
+	size     int
+	entries  map[uint64]*list.Element
+	order    *list.List
+	lastSeen time.Time
+}
+
+// newHashLRU returns an empty hashLRU.
+func newHashLRU(size int) *hashLRU {
+	return &hashLRU{
+		size:    size,
+		entries: make(map[uint64]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// seen returns true if hash was sent within the window before now, otherwise it is remembered as sent now.
+// The time of a duplicate is not recorded so that a line repeated forever is still sent once per window.
+func (l *hashLRU) seen(hash uint64, now time.Time, window time.Duration) bool {
+	l.lastSeen = now
+	if element, exists := l.entries[hash]; exists {
+		l.order.MoveToFront(element)
+		entry := element.Value.(*hashEntry)
+		if now.Sub(entry.sent) <= window {
+			return true
+		}
+		entry.sent = now
+		return false
+	}
+	l.entries[hash] = l.order.PushFront(&hashEntry{hash: hash, sent: now})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*hashEntry).hash)
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// newTestDeduplicator returns a deduplicator whose clock is advanced by the returned function.
+func newTestDeduplicator(window time.Duration, size int) (*Deduplicator, func(time.Duration)) {
+	d := NewDeduplicator(window, size)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	return d, func(elapsed time.Duration) { now = now.Add(elapsed) }
+}
+
+func TestNewDeduplicatorIsDisabledByDefault(t *testing.T) {
+	assert.Nil(t, NewDeduplicator(0, 1000))
+	assert.Nil(t, NewDeduplicator(time.Second, 0))
+	assert.NotNil(t, NewDeduplicator(time.Second, 1000))
+}
+
+func TestDeduplicatorDropsTheDuplicatesWithinTheWindow(t *testing.T) {
+	d, advance := newTestDeduplicator(10*time.Second, 100)
+	source := config.NewLogSource("app", &config.LogsConfig{})
+
+	assert.False(t, d.isDuplicate(newMessage([]byte("hello"), source, "")))
+	assert.False(t, d.isDuplicate(newMessage([]byte("world"), source, "")))
+	assert.True(t, d.isDuplicate(newMessage([]byte("hello"), source, "")))
+
+	// a line repeated forever is sent once per window
+	advance(6 * time.Second)
+	assert.True(t, d.isDuplicate(newMessage([]byte("hello"), source, "")))
+	advance(5 * time.Second)
+	assert.False(t, d.isDuplicate(newMessage([]byte("hello"), source, "")))
+	assert.True(t, d.isDuplicate(newMessage([]byte("hello"), source, "")))
+}
+
+func TestDeduplicatorIsKeyedBySourceName(t *testing.T) {
+	d, _ := newTestDeduplicator(10*time.Second, 100)
+	source := config.NewLogSource("app", &config.LogsConfig{Path: "/var/log/app.log"})
+	// the same file tailed twice by the sources of an integration
+	sameName := config.NewLogSource("app", &config.LogsConfig{Path: "/var/log/*.log"})
+	otherName := config.NewLogSource("other", &config.LogsConfig{Path: "/var/log/app.log"})
+
+	assert.False(t, d.isDuplicate(newMessage([]byte("hello"), source, "")))
+	assert.True(t, d.isDuplicate(newMessage([]byte("hello"), sameName, "")))
+	assert.False(t, d.isDuplicate(newMessage([]byte("hello"), otherName, "")))
+}
+
+func TestDeduplicatorIsBoundedInMemory(t *testing.T) {
+	d, advance := newTestDeduplicator(10*time.Second, 2)
+	source := config.NewLogSource("app", &config.LogsConfig{})
+
+	assert.False(t, d.isDuplicate(newMessage([]byte("a"), source, "")))
+	assert.False(t, d.isDuplicate(newMessage([]byte("b"), source, "")))
+	// a is the least recently seen
+	assert.True(t, d.isDuplicate(newMessage([]byte("b"), source, "")))
+	assert.False(t, d.isDuplicate(newMessage([]byte("c"), source, "")))
+	assert.Equal(t, 2, d.sources["app"].order.Len())
+	assert.False(t, d.isDuplicate(newMessage([]byte("a"), source, "")))
+	assert.True(t, d.isDuplicate(newMessage([]byte("c"), source, "")))
+
+	// the sources without any message within the window are forgotten
+	other := config.NewLogSource("other", &config.LogsConfig{})
+	assert.False(t, d.isDuplicate(newMessage([]byte("a"), other, "")))
+	advance(11 * time.Second)
+	assert.False(t, d.isDuplicate(newMessage([]byte("d"), source, "")))
+	assert.Len(t, d.sources, 1)
+	assert.Contains(t, d.sources, "app")
+}
+
+func TestProcessorDropsTheDuplicates(t *testing.T) {
+	inputChan := make(chan *message.Message)
+	outputChan := make(chan *message.Message, 10)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, nil, nil, nil, NewDeduplicator(time.Minute, 100))
+	p.Start()
+
+	source := config.NewLogSource("app", &config.LogsConfig{})
+	deduplicated := metrics.LogsDeduplicated.Value()
+	for _, content := range []string{"a", "a", "b", "a"} {
+		inputChan <- newMessage([]byte(content), source, "")
+	}
+	p.Stop()
+	close(outputChan)
+
+	var contents []string
+	for msg := range outputChan {
+		contents = append(contents, string(msg.Content))
+	}
+	assert.Equal(t, []string{"a", "b"}, contents)
+	assert.Equal(t, deduplicated+2, metrics.LogsDeduplicated.Value())
+	assert.Equal(t, int64(2), source.Throughput.Value().Dropped)
+}
+
+func TestHashContentIsFNV1a(t *testing.T) {
+	for _, content := range []string{"", "hello", strings.Repeat("a", 1000)} {
+		h := fnv.New64a()
+		h.Write([]byte(content))
+		assert.Equal(t, h.Sum64(), hashContent([]byte(content)))
+	}
+}
+
+func benchmarkHashContent(b *testing.B, size int) {
+	content := []byte(strings.Repeat("a", size))
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hashContent(content)
+	}
+}
+
+func BenchmarkHashContent100B(b *testing.B) {
+	benchmarkHashContent(b, 100)
+}
+
+func BenchmarkHashContent1KB(b *testing.B) {
+	benchmarkHashContent(b, 1000)
+}
+
+func BenchmarkHashContent10KB(b *testing.B) {
+	benchmarkHashContent(b, 10000)
+}
+
+func BenchmarkDeduplicatorUniqueMessages(b *testing.B) {
+	d := NewDeduplicator(time.Minute, 1000)
+	source := config.NewLogSource("app", &config.LogsConfig{})
+	messages := make([]*message.Message, 10000)
+	for i := range messages {
+		messages[i] = newMessage([]byte(fmt.Sprintf("2018-08-22 14:51:44 INFO request %d completed in 42ms with status 200", i)), source, "")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.isDuplicate(messages[i%len(messages)])
+	}
+}
+
+func BenchmarkDeduplicatorDuplicates(b *testing.B) {
+	d := NewDeduplicator(time.Minute, 1000)
+	source := config.NewLogSource("app", &config.LogsConfig{})
+	msg := newMessage([]byte("2018-08-22 14:51:44 INFO heartbeat"), source, "")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.isDuplicate(msg)
+	}
+}
@@ -7,7 +7,7 @@ package processor
 
 import (
 	"regexp"
-	"time"
+	"strconv"
 	"unicode"
 	"unicode/utf8"
 
@@ -22,6 +22,18 @@ type Encoder interface {
 	encode(msg *message.Message, redactedMsg []byte) ([]byte, error)
 }
 
+// attributesEncoder is implemented by the encoders writing the attributes of the messages in their own format,
+// the content of their messages is then not wrapped as JSON with the attributes.
+type attributesEncoder interface {
+	encodesAttributes()
+}
+
+// wrapsAttributes returns true if the content of the messages encoded by encoder is wrapped as JSON with their attributes.
+func wrapsAttributes(encoder Encoder) bool {
+	_, encodesAttributes := encoder.(attributesEncoder)
+	return !encodesAttributes
+}
+
 // Raw is an encoder implementation that writes messages as raw strings.
 var rawEncoder raw
 
@@ -57,7 +69,7 @@ func (r *raw) encode(msg *message.Message, redactedMsg []byte) ([]byte, error) {
 		extraContent = append(extraContent, ' ')
 
 		// Timestamp
-		extraContent = time.Now().UTC().AppendFormat(extraContent, config.DateFormat)
+		extraContent = timestampOf(msg).AppendFormat(extraContent, config.DateFormat)
 		extraContent = append(extraContent, ' ')
 
 		extraContent = append(extraContent, []byte(getHostname())...)
@@ -76,6 +88,9 @@ func (r *raw) encode(msg *message.Message, redactedMsg []byte) ([]byte, error) {
 
 		// Tags
 		tagsPayload := msg.Origin.TagsPayload()
+		if msg.Origin.LogSource.Config.SequenceNumbers {
+			tagsPayload = append(tagsPayload, []byte("[dd ddsequence=\""+strconv.FormatUint(msg.Sequence, 10)+"\"]")...)
+		}
 		if len(tagsPayload) > 0 {
 			extraContent = append(extraContent, tagsPayload...)
 		} else {
@@ -107,7 +122,7 @@ func (p *proto) encode(msg *message.Message, redactedMsg []byte) ([]byte, error)
 	return (&pb.Log{
 		Message:   p.toValidUtf8(redactedMsg),
 		Status:    msg.GetStatus(),
-		Timestamp: time.Now().UTC().UnixNano(),
+		Timestamp: timestampOf(msg).UnixNano(),
 		Hostname:  getHostname(),
 		Service:   msg.Origin.Service(),
 		Source:    msg.Origin.Source(),
@@ -90,6 +90,24 @@ func TestRawEncoderDefaults(t *testing.T) {
 
 }
 
+func TestRawEncoderWithSequenceNumbers(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{Source: "Source", SequenceNumbers: true})
+	msg := newMessage([]byte("a"), source, "")
+	msg.Sequence = 42
+
+	raw, err := rawEncoder.encode(msg, []byte("a"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(raw), " - - [dd ddsource=\"Source\"][dd ddsequence=\"42\"] a"))
+
+	source = config.NewLogSource("", &config.LogsConfig{SequenceNumbers: true})
+	msg = newMessage([]byte("a"), source, "")
+	msg.Sequence = 1
+
+	raw, err = rawEncoder.encode(msg, []byte("a"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(raw), " - - [dd ddsequence=\"1\"] a"))
+}
+
 func TestRawEncoderEmpty(t *testing.T) {
 
 	logsConfig := &config.LogsConfig{}
@@ -196,3 +214,9 @@ func TestProtoEncoderToValidUTF8(t *testing.T) {
 	assert.Equal(t, "a���z", protoEncoder.toValidUtf8([]byte("a\xed\xa0\x80z")))
 	assert.Equal(t, "a����z", protoEncoder.toValidUtf8([]byte("a\xf0\x8f\xbf\xbfz")))
 }
+
+func TestWrapsAttributes(t *testing.T) {
+	assert.True(t, wrapsAttributes(NewEncoder(false)))
+	assert.True(t, wrapsAttributes(NewEncoder(true)))
+	assert.False(t, wrapsAttributes(NewCEFEncoder()))
+}
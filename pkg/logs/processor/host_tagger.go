@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/cache"
+)
+
+// defaultHostTagsRefreshInterval is used when the refresh interval is not set.
+const defaultHostTagsRefreshInterval = 5 * time.Minute
+
+// hostTagsCacheKey is the key of the tags of the host in the cache shared by the taggers of all the pipelines.
+var hostTagsCacheKey = cache.BuildAgentKey("logs", "host_tags")
+
+// HostTagger adds the tags of the host, such as its availability zone, its instance type or its kubernetes node,
+// to the messages. The tags are resolved when the tagger is created then refreshed in the background,
+// they are cached so that the taggers of all the pipelines share the same resolution.
+type HostTagger struct {
+	include         map[string]bool
+	refreshInterval time.Duration
+	resolve         func() []string
+	tags            atomic.Value
+	nextRefresh     int64
+	refreshing      int32
+}
+
+// NewHostTagger returns a new host tagger, or nil if it is not enabled, resolve returns the tags of the host,
+// which are resolved again every refreshInterval, only the tags with a key in include are kept if it is not empty.
+func NewHostTagger(enabled bool, include []string, refreshInterval time.Duration, resolve func() []string) *HostTagger {
+	if !enabled {
+		return nil
+	}
+	if refreshInterval <= 0 {
+		refreshInterval = defaultHostTagsRefreshInterval
+	}
+	t := &HostTagger{
+		refreshInterval: refreshInterval,
+		resolve:         resolve,
+		refreshing:      1,
+	}
+	if len(include) > 0 {
+		t.include = make(map[string]bool)
+		for _, key := range include {
+			t.include[key] = true
+		}
+	}
+	t.refresh()
+	return t
+}
+
+// hostTags returns the tags of the host, a refresh is triggered in the background once they are stale.
+func (t *HostTagger) hostTags() []string {
+	if time.Now().UnixNano() > atomic.LoadInt64(&t.nextRefresh) && atomic.CompareAndSwapInt32(&t.refreshing, 0, 1) {
+		go t.refresh()
+	}
+	return t.tags.Load().([]string)
+}
+
+// refresh resolves the tags of the host, unless they are in the cache already.
+func (t *HostTagger) refresh() {
+	defer atomic.StoreInt32(&t.refreshing, 0)
+	var tags []string
+	if cached, found := cache.Cache.Get(hostTagsCacheKey); found {
+		tags = cached.([]string)
+	} else {
+		tags = t.resolve()
+		cache.Cache.Set(hostTagsCacheKey, tags, t.refreshInterval)
+	}
+	t.tags.Store(t.filter(tags))
+	atomic.StoreInt64(&t.nextRefresh, time.Now().Add(t.refreshInterval).UnixNano())
+}
+
+// filter returns the tags with a key to include.
+func (t *HostTagger) filter(tags []string) []string {
+	if t.include == nil {
+		return tags
+	}
+	var included []string
+	for _, tag := range tags {
+		if t.include[strings.SplitN(tag, ":", 2)[0]] {
+			included = append(included, tag)
+		}
+	}
+	return included
+}
@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/cache"
+)
+
+// countingResolver returns the tags of the host and counts the resolutions.
+type countingResolver struct {
+	tags        []string
+	resolutions int
+}
+
+func (r *countingResolver) resolve() []string {
+	r.resolutions++
+	return r.tags
+}
+
+func TestHostTaggerIsNilWhenDisabled(t *testing.T) {
+	assert.Nil(t, NewHostTagger(false, nil, time.Minute, nil))
+}
+
+func TestHostTaggerSharesTheTagsResolved(t *testing.T) {
+	defer cache.Cache.Delete(hostTagsCacheKey)
+	resolver := &countingResolver{tags: []string{"zone:us-east-1a", "instance-type:m5.large", "kube_node:foo"}}
+
+	tagger := NewHostTagger(true, nil, time.Minute, resolver.resolve)
+	assert.Equal(t, []string{"zone:us-east-1a", "instance-type:m5.large", "kube_node:foo"}, tagger.hostTags())
+	other := NewHostTagger(true, []string{"zone", "kube_node"}, time.Minute, resolver.resolve)
+	assert.Equal(t, []string{"zone:us-east-1a", "kube_node:foo"}, other.hostTags())
+	assert.Equal(t, 1, resolver.resolutions)
+}
+
+func TestHostTaggerRefreshesTheTags(t *testing.T) {
+	defer cache.Cache.Delete(hostTagsCacheKey)
+	resolver := &countingResolver{tags: []string{"zone:us-east-1a"}}
+	tagger := NewHostTagger(true, nil, time.Minute, resolver.resolve)
+
+	// the tags are stale and expired from the cache
+	cache.Cache.Delete(hostTagsCacheKey)
+	tagger.nextRefresh = 0
+	tagger.refreshing = 1
+	resolver.tags = []string{"zone:us-east-1b"}
+	tagger.refresh()
+	assert.Equal(t, []string{"zone:us-east-1b"}, tagger.hostTags())
+	assert.Equal(t, 2, resolver.resolutions)
+}
+
+func TestProcessorAddsTheHostTags(t *testing.T) {
+	defer cache.Cache.Delete(hostTagsCacheKey)
+	inputChan := make(chan *message.Message, 10)
+	outputChan := make(chan *message.Message, 10)
+	tagger := NewHostTagger(true, nil, time.Minute, func() []string { return []string{"zone:us-east-1a"} })
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, nil, nil, tagger, nil)
+
+	source := config.NewLogSource("", &config.LogsConfig{Tags: []string{"env:prod"}})
+	excluded := config.NewLogSource("", &config.LogsConfig{ExcludeHostTags: true})
+	inputChan <- newMessage([]byte("foo"), source, "")
+	inputChan <- newMessage([]byte("bar"), excluded, "")
+	p.Start()
+	p.Stop()
+
+	msg := <-outputChan
+	assert.Equal(t, []string{"env:prod", "zone:us-east-1a"}, msg.Origin.Tags())
+	msg = <-outputChan
+	assert.Empty(t, msg.Origin.Tags())
+}
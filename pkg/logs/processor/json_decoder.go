@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// The fields holding the timestamp and the severity of the JSON logs when the rule does not name them.
+const (
+	defaultTimestampField = "timestamp"
+	defaultSeverityField  = "severity"
+)
+
+// syslogSeverityStatuses are the statuses of the numeric syslog severities, indexed by severity.
+var syslogSeverityStatuses = []string{
+	message.StatusEmergency,
+	message.StatusAlert,
+	message.StatusCritical,
+	message.StatusError,
+	message.StatusWarning,
+	message.StatusNotice,
+	message.StatusInfo,
+	message.StatusDebug,
+}
+
+// decodeJSON sets the timestamp and the status of msg from the fields of content named by rule,
+// content itself is left as is. The content which is not a JSON object is counted and left as is too.
+func decodeJSON(msg *message.Message, content []byte, rule config.ProcessingRule) {
+	object, ok := decodeJSONObject(content)
+	if !ok {
+		metrics.LogsNotJSON.Add(1)
+		return
+	}
+
+	timestampField := rule.TimestampField
+	if timestampField == "" {
+		timestampField = defaultTimestampField
+	}
+	if timestamp, ok := toTimestamp(lookupJSONField(object, timestampField)); ok {
+		msg.Timestamp = timestamp.UTC().Format(time.RFC3339Nano)
+	}
+
+	severityField := rule.SeverityField
+	if severityField == "" {
+		severityField = defaultSeverityField
+	}
+	if status, ok := toStatus(lookupJSONField(object, severityField), nil); ok {
+		msg.SetStatus(status)
+	}
+}
+
+// decodeJSONObject returns the JSON object of content, whose numbers are decoded as json.Number,
+// false if content is not a JSON object.
+func decodeJSONObject(content []byte) (map[string]interface{}, bool) {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, false
+	}
+	return object, true
+}
+
+// lookupJSONField returns the value of field in object, the dots of field denote nested objects,
+// nil if there is no such field.
+func lookupJSONField(object map[string]interface{}, field string) interface{} {
+	path := strings.Split(field, ".")
+	for _, name := range path[:len(path)-1] {
+		child, isObject := object[name].(map[string]interface{})
+		if !isObject {
+			return nil
+		}
+		object = child
+	}
+	return object[path[len(path)-1]]
+}
+
+// toTimestamp returns the time of value, either a RFC3339 date or a number of seconds,
+// or of milliseconds for the large numbers, since the epoch.
+func toTimestamp(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		timestamp, err := time.Parse(time.RFC3339Nano, v)
+		return timestamp, err == nil
+	case json.Number:
+		// the timestamps in seconds are far below 1e12 until the year 33658
+		if integer, err := v.Int64(); err == nil {
+			switch {
+			case integer <= 0:
+				return time.Time{}, false
+			case integer >= 1e12:
+				return time.Unix(0, integer*int64(time.Millisecond)), true
+			default:
+				return time.Unix(integer, 0), true
+			}
+		}
+		number, err := v.Float64()
+		if err != nil || number <= 0 {
+			return time.Time{}, false
+		}
+		if number >= 1e12 {
+			return time.Unix(0, int64(number*float64(time.Millisecond))), true
+		}
+		return time.Unix(0, int64(number*float64(time.Second))), true
+	}
+	return time.Time{}, false
+}
+
+// toStatus returns the status of value, either a severity name, looked up in aliases first,
+// or a numeric syslog severity.
+func toStatus(value interface{}, aliases map[string]string) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return message.ToStatus(v, aliases)
+	case json.Number:
+		severity, err := v.Int64()
+		if err != nil || severity < 0 || severity >= int64(len(syslogSeverityStatuses)) {
+			return "", false
+		}
+		return syslogSeverityStatuses[severity], true
+	}
+	return "", false
+}
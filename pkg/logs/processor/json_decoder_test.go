@@ -0,0 +1,77 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func newJSONSource(t *testing.T, rule config.ProcessingRule) *config.LogSource {
+	rule.Name = "json"
+	rule.Type = config.DecodeJSON
+	logsConfig := &config.LogsConfig{ProcessingRules: []config.ProcessingRule{rule}}
+	assert.Nil(t, logsConfig.Compile())
+	return config.NewLogSource("", logsConfig)
+}
+
+func TestDecodeJSONSetsTimestampAndStatus(t *testing.T) {
+	source := newJSONSource(t, config.ProcessingRule{})
+	content := []byte(`{"timestamp":"2018-10-15T10:00:00.5+02:00","severity":"WARNING","message":"hello"}`)
+	msg := newMessage(content, source, "")
+
+	shouldProcess, redactedMsg := applyRedactingRules(msg)
+	assert.True(t, shouldProcess)
+	// the payload is left intact
+	assert.Equal(t, content, redactedMsg)
+	assert.Equal(t, "2018-10-15T08:00:00.5Z", msg.Timestamp)
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+}
+
+func TestDecodeJSONUsesTheFieldsOfTheRule(t *testing.T) {
+	source := newJSONSource(t, config.ProcessingRule{TimestampField: "time", SeverityField: "log.level"})
+
+	msg := newMessage([]byte(`{"time":1539597600,"log":{"level":3},"message":"hello"}`), source, "")
+	applyRedactingRules(msg)
+	assert.Equal(t, "2018-10-15T10:00:00Z", msg.Timestamp)
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+
+	// the large numbers are milliseconds
+	msg = newMessage([]byte(`{"time":1539597600123,"log":{"level":"fatal"}}`), source, "")
+	applyRedactingRules(msg)
+	assert.Equal(t, "2018-10-15T10:00:00.123Z", msg.Timestamp)
+	assert.Equal(t, message.StatusCritical, msg.GetStatus())
+}
+
+func TestDecodeJSONIgnoresInvalidFields(t *testing.T) {
+	source := newJSONSource(t, config.ProcessingRule{})
+	msg := newMessage([]byte(`{"timestamp":"yesterday","severity":"loud"}`), source, message.StatusNotice)
+
+	applyRedactingRules(msg)
+	assert.Equal(t, "", msg.Timestamp)
+	assert.Equal(t, message.StatusNotice, msg.GetStatus())
+}
+
+func TestDecodeJSONCountsTheLogsNotJSON(t *testing.T) {
+	metrics.LogsNotJSON.Set(0)
+	defer metrics.LogsNotJSON.Set(0)
+	source := newJSONSource(t, config.ProcessingRule{})
+
+	for _, content := range []string{"hello world", `{"severity":"error"`, `["error"]`} {
+		msg := newMessage([]byte(content), source, "")
+		shouldProcess, redactedMsg := applyRedactingRules(msg)
+		// the logs are not dropped
+		assert.True(t, shouldProcess)
+		assert.Equal(t, content, string(redactedMsg))
+		assert.Equal(t, message.StatusInfo, msg.GetStatus())
+	}
+	assert.Equal(t, int64(3), metrics.LogsNotJSON.Value())
+}
@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// jsonEncoder is an encoder implementation that writes messages as JSON objects holding their content and metadata,
+// the messages are sent one per line.
+type jsonEncoder struct {
+	// rawMessage embeds the messages which are valid JSON as is instead of as strings
+	rawMessage bool
+}
+
+// NewJSONEncoder returns an encoder that writes messages as JSON objects,
+// the messages which are valid JSON are embedded as objects instead of strings when rawMessage is set.
+func NewJSONEncoder(rawMessage bool) Encoder {
+	return &jsonEncoder{
+		rawMessage: rawMessage,
+	}
+}
+
+// jsonPayload is the JSON object of a message.
+type jsonPayload struct {
+	Message   interface{} `json:"message"`
+	Status    string      `json:"status"`
+	Timestamp int64       `json:"timestamp"`
+	Hostname  string      `json:"hostname"`
+	Service   string      `json:"service,omitempty"`
+	Source    string      `json:"ddsource,omitempty"`
+	Tags      string      `json:"ddtags,omitempty"`
+	Sequence  uint64      `json:"sequence,omitempty"`
+}
+
+func (j *jsonEncoder) encode(msg *message.Message, redactedMsg []byte) ([]byte, error) {
+	var content interface{} = string(redactedMsg)
+	if j.rawMessage && json.Valid(redactedMsg) {
+		// the message is compacted by the encoder so that it fits on one line
+		content = json.RawMessage(redactedMsg)
+	}
+
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	// the messages are not embedded in HTML
+	encoder.SetEscapeHTML(false)
+	payload := jsonPayload{
+		Message:   content,
+		Status:    msg.GetStatus(),
+		Timestamp: timestampOf(msg).UnixNano() / int64(time.Millisecond),
+		Hostname:  getHostname(),
+		Service:   msg.Origin.Service(),
+		Source:    msg.Origin.Source(),
+		Tags:      strings.Join(msg.Origin.Tags(), ","),
+	}
+	if msg.Origin.LogSource.Config.SequenceNumbers {
+		// the numbers start at 1 so the sequence is only omitted for the sources not numbering their messages
+		payload.Sequence = msg.Sequence
+	}
+	if err := encoder.Encode(payload); err != nil {
+		return nil, err
+	}
+	// the messages are delimited by the destinations
+	return bytes.TrimSuffix(buffer.Bytes(), []byte{'\n'}), nil
+}
@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{
+		Service: "Service",
+		Source:  "Source",
+		Tags:    []string{"foo:bar", "baz"},
+	})
+	msg := newMessage([]byte("message"), source, message.StatusError)
+
+	encoded, err := NewJSONEncoder(false).encode(msg, []byte("redacted <\"\x01\ttab\"> \xff"))
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(encoded), "\n"))
+	assert.True(t, strings.Contains(string(encoded), `"message":"redacted <\"\u0001\ttab\"> �"`))
+
+	var payload map[string]interface{}
+	assert.Nil(t, json.Unmarshal(encoded, &payload))
+	assert.Equal(t, "redacted <\"\x01\ttab\"> �", payload["message"])
+	assert.Equal(t, message.StatusError, payload["status"])
+	assert.Equal(t, "Service", payload["service"])
+	assert.Equal(t, "Source", payload["ddsource"])
+	assert.Equal(t, "foo:bar,baz", payload["ddtags"])
+	assert.NotEmpty(t, payload["hostname"])
+	assert.NotZero(t, payload["timestamp"])
+}
+
+func TestJSONEncoderSequence(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte("message"), source, message.StatusInfo)
+	msg.Sequence = 42
+
+	var payload map[string]interface{}
+	encoded, err := NewJSONEncoder(false).encode(msg, []byte("message"))
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(encoded, &payload))
+	assert.NotContains(t, payload, "sequence")
+
+	source.Config.SequenceNumbers = true
+	encoded, err = NewJSONEncoder(false).encode(msg, []byte("message"))
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(encoded, &payload))
+	assert.Equal(t, float64(42), payload["sequence"])
+}
+
+func TestJSONEncoderRawMessage(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte(""), source, message.StatusInfo)
+
+	// the messages which are valid JSON are embedded on one line
+	encoded, err := NewJSONEncoder(true).encode(msg, []byte("{\n  \"foo\": \"bar\"\n}"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(encoded), `{"message":{"foo":"bar"},`))
+
+	// the other ones are embedded as strings
+	encoded, err = NewJSONEncoder(true).encode(msg, []byte("{foo"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(encoded), `{"message":"{foo",`))
+
+	encoded, err = NewJSONEncoder(false).encode(msg, []byte(`{"foo":"bar"}`))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(encoded), `{"message":"{\"foo\":\"bar\"}",`))
+}
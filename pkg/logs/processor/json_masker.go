@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+// maskJSONKeys replaces the values of all the fields named after one of keys with placeholder.
+// The content is scanned only once and is never unmarshaled, which makes it much cheaper than
+// decoding the whole document to redact a few fields.
+// Content that does not look like JSON or that is malformed is returned untouched.
+func maskJSONKeys(content []byte, keys []string, placeholder []byte) []byte {
+	start := skipWhitespaces(content, 0)
+	if start == len(content) || (content[start] != '{' && content[start] != '[') {
+		return content
+	}
+
+	var masked []byte
+	last := 0
+	for i := start; i < len(content); {
+		if content[i] != '"' {
+			i++
+			continue
+		}
+		end := scanJSONString(content, i)
+		if end < 0 {
+			return content
+		}
+		colon := skipWhitespaces(content, end)
+		if colon == len(content) || content[colon] != ':' || !isMaskedKey(content[i+1:end-1], keys) {
+			// this is either a value or a key we don't want to mask
+			i = end
+			continue
+		}
+		valueStart := skipWhitespaces(content, colon+1)
+		valueEnd := scanJSONValue(content, valueStart)
+		if valueEnd < 0 {
+			return content
+		}
+		if masked == nil {
+			masked = make([]byte, 0, len(content))
+		}
+		masked = append(masked, content[last:valueStart]...)
+		masked = append(masked, '"')
+		masked = append(masked, placeholder...)
+		masked = append(masked, '"')
+		last = valueEnd
+		i = valueEnd
+	}
+
+	if masked == nil {
+		return content
+	}
+	return append(masked, content[last:]...)
+}
+
+// isMaskedKey returns true if key is one of keys.
+func isMaskedKey(key []byte, keys []string) bool {
+	for _, k := range keys {
+		if string(key) == k {
+			return true
+		}
+	}
+	return false
+}
+
+// skipWhitespaces returns the index of the first non whitespace character found from i.
+func skipWhitespaces(content []byte, i int) int {
+	for i < len(content) && isWhitespace(content[i]) {
+		i++
+	}
+	return i
+}
+
+// isWhitespace returns true if c is a JSON whitespace.
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// scanJSONString returns the index right after the end of the string starting at i,
+// or -1 if the string is not terminated.
+func scanJSONString(content []byte, i int) int {
+	for j := i + 1; j < len(content); j++ {
+		switch content[j] {
+		case '\\':
+			// skip the escaped character
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// scanJSONValue returns the index right after the end of the value starting at i,
+// or -1 if the value is malformed.
+func scanJSONValue(content []byte, i int) int {
+	if i >= len(content) {
+		return -1
+	}
+	switch content[i] {
+	case '"':
+		return scanJSONString(content, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(content); {
+			switch content[j] {
+			case '"':
+				end := scanJSONString(content, j)
+				if end < 0 {
+					return -1
+				}
+				j = end
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+			j++
+		}
+		return -1
+	case ',', ':', '}', ']':
+		return -1
+	default:
+		// numbers, booleans and null end at the next delimiter
+		for j := i; j < len(content); j++ {
+			if c := content[j]; c == ',' || c == '}' || c == ']' || isWhitespace(c) {
+				return j
+			}
+		}
+		return -1
+	}
+}
@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+var maskedKeys = []string{"password", "token"}
+var placeholder = []byte("[masked]")
+
+func TestMaskJSONKeysWithFlatObject(t *testing.T) {
+	content := []byte(`{"user":"bob","password":"secret","age":42}`)
+	assert.Equal(t, `{"user":"bob","password":"[masked]","age":42}`, string(maskJSONKeys(content, maskedKeys, placeholder)))
+
+	content = []byte(`{ "token" : 123456 , "user" : "bob" }`)
+	assert.Equal(t, `{ "token" : "[masked]" , "user" : "bob" }`, string(maskJSONKeys(content, maskedKeys, placeholder)))
+
+	content = []byte(`{"token":true,"password":null}`)
+	assert.Equal(t, `{"token":"[masked]","password":"[masked]"}`, string(maskJSONKeys(content, maskedKeys, placeholder)))
+}
+
+func TestMaskJSONKeysWithNestedValues(t *testing.T) {
+	content := []byte(`{"user":{"name":"bob","password":"secret"},"token":{"value":"abc","expires":[1,{"a":"}"}]}}`)
+	assert.Equal(t, `{"user":{"name":"bob","password":"[masked]"},"token":"[masked]"}`, string(maskJSONKeys(content, maskedKeys, placeholder)))
+
+	content = []byte(`[{"password":"a"},{"password":"b"}]`)
+	assert.Equal(t, `[{"password":"[masked]"},{"password":"[masked]"}]`, string(maskJSONKeys(content, maskedKeys, placeholder)))
+}
+
+func TestMaskJSONKeysWithEscapedValues(t *testing.T) {
+	content := []byte(`{"password":"se\"cr\\et","msg":"a \"password\": b"}`)
+	assert.Equal(t, `{"password":"[masked]","msg":"a \"password\": b"}`, string(maskJSONKeys(content, maskedKeys, placeholder)))
+
+	content = []byte(`{"msg":"\"token\":\"abc\"","token":"x\\"}`)
+	assert.Equal(t, `{"msg":"\"token\":\"abc\"","token":"[masked]"}`, string(maskJSONKeys(content, maskedKeys, placeholder)))
+}
+
+func TestMaskJSONKeysIgnoresValuesNamedAfterKeys(t *testing.T) {
+	content := []byte(`{"field":"password","list":["token"]}`)
+	assert.Equal(t, string(content), string(maskJSONKeys(content, maskedKeys, placeholder)))
+}
+
+func TestMaskJSONKeysLeavesMalformedContentUntouched(t *testing.T) {
+	malformedContents := []string{
+		``,
+		`password: secret`,
+		`"password":"secret"`,
+		`{"password":"secret`,
+		`{"password":`,
+		`{"password":{"a":"b"`,
+		`{"password":,"a":"b"}`,
+		`{"password":42`,
+	}
+	for _, content := range malformedContents {
+		assert.Equal(t, content, string(maskJSONKeys([]byte(content), maskedKeys, placeholder)))
+	}
+}
+
+func TestMaskJSONKeysRule(t *testing.T) {
+	rule := config.ProcessingRule{
+		Type:                    config.MaskJSONKeys,
+		Name:                    "mask_passwords",
+		Keys:                    []string{"password"},
+		ReplacePlaceholderBytes: []byte("xxx"),
+	}
+	source := config.LogSource{Config: &config.LogsConfig{ProcessingRules: []config.ProcessingRule{rule}}}
+
+	shouldProcess, redactedMessage := applyRedactingRules(newMessage([]byte(`{"user":"bob","password":"secret"}`), &source, ""))
+	assert.True(t, shouldProcess)
+	assert.Equal(t, []byte(`{"user":"bob","password":"xxx"}`), redactedMessage)
+}
+
+var benchmarkContent = []byte(`{"timestamp":"2018-08-22T14:51:44.205Z","level":"info","user":{"id":1234,"name":"bob","password":"secret"},"request":{"method":"GET","path":"/api/v1/items","headers":{"accept":"application/json","token":"abcdef"}},"duration":0.042,"message":"request completed"}`)
+
+// unmarshalAndMask is the naive approach maskJSONKeys is compared to.
+func unmarshalAndMask(content []byte, keys []string, placeholder []byte) []byte {
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return content
+	}
+	maskValue(document, keys, string(placeholder))
+	masked, err := json.Marshal(document)
+	if err != nil {
+		return content
+	}
+	return masked
+}
+
+func maskValue(value interface{}, keys []string, placeholder string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isMaskedKey([]byte(key), keys) {
+				v[key] = placeholder
+			} else {
+				maskValue(field, keys, placeholder)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			maskValue(item, keys, placeholder)
+		}
+	}
+}
+
+func BenchmarkMaskJSONKeys(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		maskJSONKeys(benchmarkContent, maskedKeys, placeholder)
+	}
+}
+
+func BenchmarkUnmarshalAndMask(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		unmarshalAndMask(benchmarkContent, maskedKeys, placeholder)
+	}
+}
@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/logs/lifecycle"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// An Observer is notified of every message once it has been processed and encoded,
+// right before it is handed to the sender, which lets embedders compute their own
+// metrics from the stream of logs.
+// An observer is a lifecycle hook only notified of the messages processed, see lifecycle.Hook:
+// the messages observed are copies which must not be modified, their content holds the encoded payload,
+// and a slow observer misses the new messages instead of slowing down the pipeline.
+type Observer interface {
+	Observe(msg *message.Message)
+}
+
+// observerHook notifies an observer of the messages processed.
+type observerHook struct {
+	observer Observer
+}
+
+// RegisterObserver registers observer to be notified of all the messages processed
+// for the lifetime of the process.
+func RegisterObserver(observer Observer) {
+	lifecycle.RegisterHook(&observerHook{observer: observer})
+}
+
+// OnProcessed notifies the observer of msg.
+func (h *observerHook) OnProcessed(msg *message.Message, outcome lifecycle.Outcome) {
+	h.observer.Observe(msg)
+}
+
+// OnSent does nothing.
+func (h *observerHook) OnSent(msg *message.Message, outcome lifecycle.Outcome) {}
+
+// OnDropped does nothing.
+func (h *observerHook) OnDropped(msg *message.Message, outcome lifecycle.Outcome) {}
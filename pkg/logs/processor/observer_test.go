@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/lifecycle"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// chanObserver forwards the messages observed to msgChan.
+type chanObserver struct {
+	msgChan chan *message.Message
+}
+
+func (o *chanObserver) Observe(msg *message.Message) {
+	o.msgChan <- msg
+}
+
+func TestObserverIsNotifiedOfProcessedMessages(t *testing.T) {
+	// the observers are registered for the lifetime of the process
+	observer := &chanObserver{msgChan: make(chan *message.Message, 1)}
+	RegisterObserver(observer)
+
+	inputChan := make(chan *message.Message, 2)
+	outputChan := make(chan *message.Message, 2)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, nil, nil, nil, nil)
+	p.Start()
+	defer p.Stop()
+
+	rules := []config.ProcessingRule{{Type: config.ExcludeAtMatch, Pattern: "exclude"}}
+	logsConfig := &config.LogsConfig{ProcessingRules: rules}
+	assert.Nil(t, logsConfig.Compile())
+	source := config.NewLogSource("", logsConfig)
+	inputChan <- newMessage([]byte("exclude me"), source, "")
+	msg := newMessage([]byte("keep me"), source, "")
+	inputChan <- msg
+
+	assert.Equal(t, msg, <-outputChan)
+	observed := <-observer.msgChan
+	assert.Equal(t, msg, observed)
+	// the observer gets a copy of the message
+	assert.True(t, msg != observed)
+	msg.Content = []byte("changed by the sender")
+	assert.Equal(t, "keep me", string(observed.Content))
+}
+
+func TestObserverHookOnlyObservesTheMessagesProcessed(t *testing.T) {
+	observer := &chanObserver{msgChan: make(chan *message.Message, 1)}
+	hook := &observerHook{observer: observer}
+	msg := newMessage([]byte("foo"), config.NewLogSource("", &config.LogsConfig{}), "")
+	hook.OnSent(msg, lifecycle.Outcome{})
+	hook.OnDropped(msg, lifecycle.Outcome{Reason: lifecycle.DropFiltered})
+	assert.Equal(t, 0, len(observer.msgChan))
+	hook.OnProcessed(msg, lifecycle.Outcome{})
+	assert.Equal(t, msg, <-observer.msgChan)
+}
@@ -6,35 +6,77 @@
 package processor
 
 import (
+	"context"
+	"hash/fnv"
+	"sync"
+
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/lifecycle"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
 )
 
 // A Processor updates messages from an inputChan and pushes
 // in an outputChan.
+// The processing rules can be applied by several workers in parallel,
+// in which case messages are not guaranteed to be sent in order, except
+// for the sources that require it, whose messages are always handled by the same worker,
+// and for the files, journals and containers whose offsets are committed in the order the messages are sent.
 type Processor struct {
 	inputChan  chan *message.Message
 	outputChan chan *message.Message
 	encoder    Encoder
-	done       chan struct{}
+	// wrapAttributes is false when the encoder writes the attributes of the messages itself
+	wrapAttributes bool
+	workers        int
+	sampler        *Sampler
+	truncator      *Truncator
+	scrubber       *Scrubber
+	hostTagger     *HostTagger
+	// deduplicator is shared by the processors of all the pipelines
+	deduplicator *Deduplicator
+	throughput   *metrics.ThroughputCounter
+	flush        restart.FlushRequests
+	done         chan struct{}
 }
 
-// New returns an initialized Processor.
-func New(inputChan, outputChan chan *message.Message, encoder Encoder) *Processor {
+// New returns an initialized Processor,
+// when sampler is not nil, it drops messages depending on the occupancy of inputChan,
+// when truncator is not nil, it cuts or splits the messages too large to be sent,
+// when scrubber is not nil, it masks the secrets of the messages before the processing rules are applied,
+// when hostTagger is not nil, it adds the tags of the host to the messages of the sources not excluding them,
+// when deduplicator is not nil, it drops the messages identical to a message of the same source recently sent.
+func New(inputChan, outputChan chan *message.Message, encoder Encoder, workers int, sampler *Sampler, truncator *Truncator, scrubber *Scrubber, hostTagger *HostTagger, deduplicator *Deduplicator) *Processor {
+	if workers < 1 {
+		workers = 1
+	}
 	return &Processor{
-		inputChan:  inputChan,
-		outputChan: outputChan,
-		encoder:    encoder,
-		done:       make(chan struct{}),
+		inputChan:      inputChan,
+		outputChan:     outputChan,
+		encoder:        encoder,
+		wrapAttributes: wrapsAttributes(encoder),
+		workers:        workers,
+		sampler:        sampler,
+		truncator:      truncator,
+		scrubber:       scrubber,
+		hostTagger:     hostTagger,
+		deduplicator:   deduplicator,
+		throughput:     metrics.NewThroughputCounter(),
+		flush:          restart.NewFlushRequests(),
+		done:           make(chan struct{}),
 	}
 }
 
 // Start starts the Processor.
 func (p *Processor) Start() {
-	go p.run()
+	if p.workers == 1 {
+		go p.run()
+	} else {
+		go p.runParallel()
+	}
 }
 
 // Stop stops the Processor,
@@ -44,28 +86,261 @@ func (p *Processor) Stop() {
 	<-p.done
 }
 
+// Flush blocks until the messages of inputChan when it is called are processed, without stopping the Processor,
+// returns an error if ctx is done first.
+func (p *Processor) Flush(ctx context.Context) error {
+	return p.flush.Request(ctx)
+}
+
 // run starts the processing of the inputChan
 func (p *Processor) run() {
 	defer func() {
 		p.done <- struct{}{}
 	}()
-	for msg := range p.inputChan {
-		metrics.LogsDecoded.Add(1)
-		if shouldProcess, redactedMsg := applyRedactingRules(msg); shouldProcess {
-			metrics.LogsProcessed.Add(1)
-
-			// Encode the message to its final format
-			content, err := p.encoder.encode(msg, redactedMsg)
-			if err != nil {
-				log.Error("unable to encode msg ", err)
+	for {
+		select {
+		case msg, isOpen := <-p.inputChan:
+			if !isOpen {
+				return
+			}
+			p.process(msg)
+		case done := <-p.flush:
+			for i := len(p.inputChan); i > 0; i-- {
+				p.process(<-p.inputChan)
+			}
+			close(done)
+		}
+	}
+}
+
+// runParallel dispatches the messages of inputChan to the workers,
+// messages of sources that preserve order are always dispatched to the same worker,
+// as are the messages of each file, journal or container whose offsets are tracked,
+// whereas the others are handled by the first worker available.
+func (p *Processor) runParallel() {
+	sharedChan := make(chan *message.Message)
+	workerChans := make([]chan *message.Message, p.workers)
+	workerFlushes := make([]restart.FlushRequests, p.workers)
+	wg := &sync.WaitGroup{}
+	for i := range workerChans {
+		workerChans[i] = make(chan *message.Message, config.ChanSize)
+		workerFlushes[i] = restart.NewFlushRequests()
+		wg.Add(1)
+		go func(workerChan chan *message.Message, flush restart.FlushRequests) {
+			defer wg.Done()
+			p.work(workerChan, sharedChan, flush)
+		}(workerChans[i], workerFlushes[i])
+	}
+	defer func() {
+		// wait for all the workers to be flushed
+		close(sharedChan)
+		for _, workerChan := range workerChans {
+			close(workerChan)
+		}
+		wg.Wait()
+		p.done <- struct{}{}
+	}()
+	dispatch := func(msg *message.Message) {
+		source := msg.Origin.LogSource
+		switch {
+		case source.Config.PreserveOrder:
+			workerChans[workerIndex(source, p.workers)] <- msg
+		case tracksOffsets(source):
+			// the auditor keeps the last offset committed for an identifier,
+			// an earlier one committed afterwards would replay lines on restart
+			workerChans[identifierWorkerIndex(msg.Origin.Identifier, p.workers)] <- msg
+		default:
+			sharedChan <- msg
+		}
+	}
+	for {
+		select {
+		case msg, isOpen := <-p.inputChan:
+			if !isOpen {
+				return
+			}
+			dispatch(msg)
+		case done := <-p.flush:
+			for i := len(p.inputChan); i > 0; i-- {
+				dispatch(<-p.inputChan)
+			}
+			// the messages handed over to a worker through sharedChan are processed
+			// before the worker receives the request
+			for _, flush := range workerFlushes {
+				flush.Request(context.Background())
+			}
+			close(done)
+		}
+	}
+}
+
+// work processes the messages from workerChan and sharedChan until both are closed,
+// the messages of workerChan are processed on a flush request.
+func (p *Processor) work(workerChan, sharedChan chan *message.Message, flush restart.FlushRequests) {
+	for workerChan != nil || sharedChan != nil {
+		select {
+		case msg, isOpen := <-workerChan:
+			if !isOpen {
+				workerChan = nil
+				continue
+			}
+			p.process(msg)
+		case msg, isOpen := <-sharedChan:
+			if !isOpen {
+				sharedChan = nil
 				continue
 			}
-			msg.Content = content
-			p.outputChan <- msg
+			p.process(msg)
+		case done := <-flush:
+			for i := len(workerChan); i > 0; i-- {
+				p.process(<-workerChan)
+			}
+			close(done)
 		}
 	}
 }
 
+// process applies the processing rules to msg, encodes it and forwards it to outputChan.
+func (p *Processor) process(msg *message.Message) {
+	metrics.LogsDecoded.Add(1)
+	source := msg.Origin.LogSource
+	// the default status is set first so that the messages are sampled and routed by it
+	if !msg.HasStatus() && source.Config.DefaultStatus != "" {
+		msg.SetStatus(source.Config.DefaultStatus)
+	}
+	// the duplicates are dropped first so that they do not count toward the logs per second of their source
+	if p.deduplicator != nil && p.deduplicator.isDuplicate(msg) {
+		metrics.LogsDeduplicated.Add(1)
+		p.throughput.CountDropped()
+		source.Throughput.CountDropped()
+		lifecycle.Dropped(msg, lifecycle.DropDuplicate, nil)
+		return
+	}
+	if !source.RateLimiter.Allow() {
+		metrics.LogsRateLimited.Add(source.Name, 1)
+		p.throughput.CountDropped()
+		source.Throughput.CountDropped()
+		lifecycle.Dropped(msg, lifecycle.DropRateLimited, nil)
+		return
+	}
+	if p.sampler != nil && p.sampler.shouldDrop(msg, p.occupancy()) {
+		metrics.LogsSampled.Add(1)
+		p.throughput.CountDropped()
+		source.Throughput.CountDropped()
+		lifecycle.Dropped(msg, lifecycle.DropSampled, nil)
+		return
+	}
+	if p.scrubber != nil {
+		msg.Content = p.scrubber.scrub(msg.Content)
+	}
+	if shouldProcess, redactedMsg := applyRedactingRules(msg); shouldProcess {
+		metrics.LogsProcessed.Add(1)
+		p.throughput.CountProcessed()
+		source.Throughput.CountProcessed()
+		// the excluded messages are not numbered so that the gaps only reveal the messages lost afterwards
+		msg.Sequence = source.NextSequence()
+
+		if p.hostTagger != nil && !source.Config.ExcludeHostTags {
+			msg.Origin.SetHostTags(p.hostTagger.hostTags())
+		}
+
+		if p.truncator != nil && source.Config.OversizePolicy == config.SplitOversizePolicy {
+			// the content is split before each part is wrapped with the attributes so that the json stays valid
+			if parts, isSplit := p.truncator.reserve(chunkAttributesLen(msg.Attributes)).split(redactedMsg); isSplit {
+				metrics.LogsSplit.Add(1)
+				// the parts are sent one after the other so that they reach the sender in order
+				group := newChunkGroup()
+				for i, part := range parts {
+					attributes := msg.Attributes
+					if len(attributes) > 0 {
+						attributes = chunkAttributes(msg.Attributes, i, len(parts))
+						if p.wrapAttributes {
+							part = withAttributes(part, attributes)
+						}
+					}
+					chunk := newChunk(msg, part, group, i, len(parts))
+					chunk.Attributes = attributes
+					p.encodeAndSend(chunk, part)
+				}
+				return
+			}
+		} else if p.truncator != nil {
+			// the content is cut before being wrapped with the attributes so that the json stays valid
+			if truncatedMsg, isTruncated := p.truncator.reserve(attributesLen(msg.Attributes)).truncate(redactedMsg); isTruncated {
+				metrics.LogsTruncated.Add(1)
+				p.throughput.CountTruncated()
+				source.Throughput.CountTruncated()
+				msg.OriginalLen = len(redactedMsg)
+				redactedMsg = truncatedMsg
+			}
+		}
+
+		if len(msg.Attributes) > 0 && p.wrapAttributes {
+			redactedMsg = withAttributes(redactedMsg, msg.Attributes)
+		}
+		p.encodeAndSend(msg, redactedMsg)
+	} else {
+		lifecycle.Dropped(msg, lifecycle.DropFiltered, nil)
+	}
+}
+
+// encodeAndSend encodes msg with redactedMsg as content and sends it to outputChan.
+func (p *Processor) encodeAndSend(msg *message.Message, redactedMsg []byte) {
+	// Encode the message to its final format
+	content, err := p.encoder.encode(msg, redactedMsg)
+	if err != nil {
+		log.Error("unable to encode msg ", err)
+		p.throughput.CountDropped()
+		msg.Origin.LogSource.Throughput.CountDropped()
+		lifecycle.Dropped(msg, lifecycle.DropEncodingFailed, err)
+		return
+	}
+	msg.Content = content
+	lifecycle.Processed(msg)
+	p.outputChan <- msg
+}
+
+// Throughput returns the logs processed and dropped so far.
+func (p *Processor) Throughput() metrics.Throughput {
+	return p.throughput.Value()
+}
+
+// occupancy returns the share of the buffer of inputChan in use, which rises when the pipeline is congested.
+func (p *Processor) occupancy() float64 {
+	if cap(p.inputChan) == 0 {
+		return 0
+	}
+	return float64(len(p.inputChan)) / float64(cap(p.inputChan))
+}
+
+// workerIndex returns the index of the worker dedicated to source.
+func workerIndex(source *config.LogSource, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(source.Name))
+	h.Write([]byte(source.Config.Type))
+	h.Write([]byte(source.Config.Path))
+	h.Write([]byte(source.Config.Identifier))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// tracksOffsets returns whether the offsets of the messages of source are committed by the auditor
+// so that the collection resumes from them on restart.
+func tracksOffsets(source *config.LogSource) bool {
+	switch source.Config.Type {
+	case config.FileType, config.JournaldType, config.DockerType:
+		return true
+	default:
+		return false
+	}
+}
+
+// identifierWorkerIndex returns the index of the worker dedicated to the messages of identifier.
+func identifierWorkerIndex(identifier string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(identifier))
+	return int(h.Sum32() % uint32(workers))
+}
+
 // applyRedactingRules returns given a message if we should process it or not,
 // and a copy of the message with some fields redacted, depending on config
 func applyRedactingRules(msg *message.Message) (bool, []byte) {
@@ -82,6 +357,23 @@ func applyRedactingRules(msg *message.Message) (bool, []byte) {
 			}
 		case config.MaskSequences:
 			content = rule.Reg.ReplaceAllLiteral(content, rule.ReplacePlaceholderBytes)
+			maskAttributes(msg, rule)
+		case config.MaskJSONKeys:
+			content = maskJSONKeys(content, rule.Keys, rule.ReplacePlaceholderBytes)
+			maskAttributes(msg, rule)
+		case config.ParseAccessLog:
+			parseAccessLog(msg, content, rule.Reg)
+		case config.DecodeJSON:
+			decodeJSON(msg, content, rule)
+		case config.ParseTimestamp:
+			parseTimestamp(msg, content, rule.TimestampParser)
+		case config.ParseStatus:
+			parseStatus(msg, content, rule)
+		case config.Sample:
+			if !keepSample(content, rule) {
+				metrics.LogsSampledOut.Add(1)
+				return false, nil
+			}
 		}
 	}
 	return true, content
@@ -6,11 +6,15 @@
 package processor
 
 import (
+	"context"
+	"fmt"
 	"regexp"
+	"strconv"
 	"testing"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -147,3 +151,226 @@ func TestTruncate(t *testing.T) {
 	_, redactedMessage = applyRedactingRules(newMessage([]byte("hello"), source, ""))
 	assert.Equal(t, []byte("hello"), redactedMessage)
 }
+
+// noopEncoder leaves the content of the messages untouched.
+type noopEncoder struct{}
+
+func (e *noopEncoder) encode(msg *message.Message, redactedMsg []byte) ([]byte, error) {
+	return redactedMsg, nil
+}
+
+func TestProcessorWithWorkersPreservesOrderPerSource(t *testing.T) {
+	inputChan := make(chan *message.Message)
+	outputChan := make(chan *message.Message, 1000)
+	p := New(inputChan, outputChan, &noopEncoder{}, 4, nil, nil, nil, nil, nil)
+	p.Start()
+
+	var sources []*config.LogSource
+	for i := 0; i < 8; i++ {
+		sources = append(sources, config.NewLogSource(fmt.Sprintf("source%d", i), &config.LogsConfig{PreserveOrder: true}))
+	}
+	for i := 0; i < 100; i++ {
+		for _, source := range sources {
+			inputChan <- newMessage([]byte(fmt.Sprintf("%d", i)), source, "")
+		}
+	}
+	p.Stop()
+	close(outputChan)
+
+	next := make(map[*config.LogSource]int)
+	for msg := range outputChan {
+		source := msg.Origin.LogSource
+		assert.Equal(t, fmt.Sprintf("%d", next[source]), string(msg.Content))
+		next[source]++
+	}
+	for _, source := range sources {
+		assert.Equal(t, 100, next[source])
+	}
+}
+
+func TestProcessorWithWorkersCommitsOffsetsInOrder(t *testing.T) {
+	inputChan := make(chan *message.Message)
+	outputChan := make(chan *message.Message, 1000)
+	p := New(inputChan, outputChan, &noopEncoder{}, 4, nil, nil, nil, nil, nil)
+	p.Start()
+
+	var sources []*config.LogSource
+	for _, sourceType := range []string{config.FileType, config.JournaldType, config.DockerType} {
+		sources = append(sources, config.NewLogSource(sourceType, &config.LogsConfig{Type: sourceType}))
+	}
+	for i := 1; i <= 100; i++ {
+		for _, source := range sources {
+			// a source such as a wildcard path tails several identifiers
+			for j := 0; j < 2; j++ {
+				msg := newMessage([]byte("foo"), source, "")
+				msg.Origin.Identifier = fmt.Sprintf("%s:%d", source.Name, j)
+				msg.Origin.Offset = fmt.Sprintf("%d", i)
+				inputChan <- msg
+			}
+		}
+	}
+	p.Stop()
+	close(outputChan)
+
+	// the offsets reach the auditor in increasing order for each identifier
+	committed := make(map[string]int)
+	for msg := range outputChan {
+		offset, err := strconv.Atoi(msg.Origin.Offset)
+		assert.Nil(t, err)
+		assert.True(t, offset > committed[msg.Origin.Identifier], msg.Origin.Identifier)
+		committed[msg.Origin.Identifier] = offset
+	}
+	assert.Equal(t, 6, len(committed))
+	for identifier, offset := range committed {
+		assert.Equal(t, 100, offset, identifier)
+	}
+}
+
+func TestProcessorNumbersProcessedMessagesPerSource(t *testing.T) {
+	inputChan := make(chan *message.Message)
+	outputChan := make(chan *message.Message, 10)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, nil, nil, nil, nil)
+	p.Start()
+
+	source := buildTestConfigLogSource("exclude_at_match", "", "exclude")
+	otherSource := config.NewLogSource("", &config.LogsConfig{})
+	for _, content := range []string{"a", "exclude", "b"} {
+		inputChan <- newMessage([]byte(content), &source, "")
+	}
+	inputChan <- newMessage([]byte("c"), otherSource, "")
+	p.Stop()
+	close(outputChan)
+
+	var sequences []uint64
+	for msg := range outputChan {
+		sequences = append(sequences, msg.Sequence)
+	}
+	// the excluded message is not numbered
+	assert.Equal(t, []uint64{1, 2, 1}, sequences)
+}
+
+func TestProcessorWithWorkersProcessesAllMessages(t *testing.T) {
+	inputChan := make(chan *message.Message)
+	outputChan := make(chan *message.Message, 1000)
+	p := New(inputChan, outputChan, &noopEncoder{}, 4, nil, nil, nil, nil, nil)
+	p.Start()
+
+	source := buildTestConfigLogSource("exclude_at_match", "", "excluded")
+	for i := 0; i < 100; i++ {
+		inputChan <- newMessage([]byte(fmt.Sprintf("%d", i)), &source, "")
+		inputChan <- newMessage([]byte("excluded"), &source, "")
+	}
+	p.Stop()
+	close(outputChan)
+
+	received := make(map[string]bool)
+	for msg := range outputChan {
+		received[string(msg.Content)] = true
+	}
+	assert.Equal(t, 100, len(received))
+	for i := 0; i < 100; i++ {
+		assert.True(t, received[fmt.Sprintf("%d", i)])
+	}
+}
+
+func TestProcessorWithWorkersFlushesAllMessages(t *testing.T) {
+	inputChan := make(chan *message.Message, 200)
+	outputChan := make(chan *message.Message, 200)
+	p := New(inputChan, outputChan, &noopEncoder{}, 4, nil, nil, nil, nil, nil)
+	p.Start()
+
+	source := buildTestConfigLogSource("exclude_at_match", "", "excluded")
+	orderedSource := buildTestConfigLogSource("exclude_at_match", "", "excluded")
+	orderedSource.Config.PreserveOrder = true
+	for i := 0; i < 50; i++ {
+		inputChan <- newMessage([]byte(fmt.Sprintf("%d", i)), &source, "")
+		inputChan <- newMessage([]byte(fmt.Sprintf("ordered %d", i)), &orderedSource, "")
+		inputChan <- newMessage([]byte("excluded"), &source, "")
+	}
+	assert.Nil(t, p.Flush(context.Background()))
+	assert.Equal(t, 100, len(outputChan))
+
+	// the processor keeps processing after a flush
+	inputChan <- newMessage([]byte("last"), &source, "")
+	p.Stop()
+	assert.Equal(t, 101, len(outputChan))
+}
+
+func TestProcessorDropsMessagesAboveSourceRate(t *testing.T) {
+	inputChan := make(chan *message.Message, 10)
+	outputChan := make(chan *message.Message, 10)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, nil, nil, nil, nil)
+
+	noisy := config.NewLogSource("noisy", &config.LogsConfig{LogsPerSecond: 2})
+	quiet := config.NewLogSource("quiet", &config.LogsConfig{})
+	for i := 0; i < 4; i++ {
+		inputChan <- newMessage([]byte("noisy"), noisy, "")
+		inputChan <- newMessage([]byte("quiet"), quiet, "")
+	}
+	p.Start()
+	p.Stop()
+	close(outputChan)
+
+	var contents []string
+	for msg := range outputChan {
+		contents = append(contents, string(msg.Content))
+	}
+	assert.Equal(t, []string{"noisy", "quiet", "noisy", "quiet", "quiet", "quiet"}, contents)
+	assert.Equal(t, "2", metrics.LogsRateLimited.Get("noisy").String())
+	assert.Nil(t, metrics.LogsRateLimited.Get("quiet"))
+	assert.Equal(t, metrics.Throughput{Processed: 2, Dropped: 2}, noisy.Throughput.Value())
+	assert.Equal(t, metrics.Throughput{Processed: 4}, quiet.Throughput.Value())
+	metrics.LogsRateLimited.Init()
+}
+
+func TestWorkerIndexIsStable(t *testing.T) {
+	source := config.NewLogSource("foo", &config.LogsConfig{Type: config.FileType, Path: "/var/log/foo.log"})
+	index := workerIndex(source, 4)
+	assert.True(t, index >= 0 && index < 4)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, index, workerIndex(source, 4))
+	}
+}
+
+func benchmarkProcessor(b *testing.B, workers int) {
+	var rules []config.ProcessingRule
+	for i := 0; i < 10; i++ {
+		pattern := fmt.Sprintf(`(\w+)=(\d+\.){3}%d+`, i)
+		rules = append(rules, config.ProcessingRule{
+			Type:                    config.MaskSequences,
+			Name:                    "mask",
+			Pattern:                 pattern,
+			Reg:                     regexp.MustCompile(pattern),
+			ReplacePlaceholderBytes: []byte("$1=[masked]"),
+		})
+	}
+	source := config.NewLogSource("heavy", &config.LogsConfig{ProcessingRules: rules})
+	content := []byte("2018-08-22 14:51:44 INFO request from client=10.0.0.1 to server=10.0.0.2 completed in 42ms with status 200 for user bob")
+
+	inputChan := make(chan *message.Message)
+	outputChan := make(chan *message.Message, config.ChanSize)
+	p := New(inputChan, outputChan, &noopEncoder{}, workers, nil, nil, nil, nil, nil)
+	p.Start()
+	done := make(chan struct{})
+	go func() {
+		for range outputChan {
+		}
+		close(done)
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		inputChan <- newMessage(content, source, "")
+	}
+	p.Stop()
+	close(outputChan)
+	<-done
+}
+
+func BenchmarkProcessorWithOneWorker(b *testing.B) {
+	benchmarkProcessor(b, 1)
+}
+
+func BenchmarkProcessorWithFourWorkers(b *testing.B) {
+	benchmarkProcessor(b, 4)
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+// sampleBuckets is the number of buckets the keys are hashed into, the logs of the first buckets are kept.
+const sampleBuckets = 1000000
+
+// keepSample returns true if content is kept by the sampling rule, the decision only depends on the key
+// of content when it has one so that all the logs sharing a key are kept or dropped together,
+// the logs without key are kept at regular intervals to keep exactly the share of the rule.
+func keepSample(content []byte, rule config.ProcessingRule) bool {
+	if key, exists := sampleKey(content, rule); exists {
+		h := fnv.New64a()
+		h.Write(key)
+		return float64(h.Sum64()%sampleBuckets) < rule.SampleRate*sampleBuckets
+	}
+	count := atomic.AddUint64(rule.SampleCount, 1)
+	return math.Floor(float64(count)*rule.SampleRate) > math.Floor(float64(count-1)*rule.SampleRate)
+}
+
+// sampleKey returns the key of content, captured by the last group of the pattern of rule,
+// or held by the key field of rule when content is a JSON object.
+func sampleKey(content []byte, rule config.ProcessingRule) ([]byte, bool) {
+	if rule.Reg != nil {
+		matches := rule.Reg.FindSubmatch(content)
+		if matches == nil {
+			return nil, false
+		}
+		return matches[len(matches)-1], true
+	}
+	if rule.KeyField == "" {
+		return nil, false
+	}
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, false
+	}
+	value := lookupJSONField(object, rule.KeyField)
+	if value == nil {
+		return nil, false
+	}
+	return []byte(fmt.Sprint(value)), true
+}
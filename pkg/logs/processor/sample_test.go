@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func newSampleSource(t *testing.T, rule config.ProcessingRule) *config.LogSource {
+	rule.Name = "sample"
+	rule.Type = config.Sample
+	logsConfig := &config.LogsConfig{ProcessingRules: []config.ProcessingRule{rule}}
+	assert.Nil(t, logsConfig.Compile())
+	return config.NewLogSource("", logsConfig)
+}
+
+// countKept returns the number of messages kept out of the contents.
+func countKept(source *config.LogSource, contents []string) int {
+	kept := 0
+	for _, content := range contents {
+		if shouldProcess, _ := applyRedactingRules(newMessage([]byte(content), source, "")); shouldProcess {
+			kept++
+		}
+	}
+	return kept
+}
+
+func TestSampleKeepsOneLogInN(t *testing.T) {
+	sampledOut := metrics.LogsSampledOut.Value()
+	source := newSampleSource(t, config.ProcessingRule{SampleRate: 0.1})
+
+	contents := make([]string, 100)
+	for i := range contents {
+		contents[i] = "debug line"
+	}
+	assert.Equal(t, 10, countKept(source, contents))
+	assert.Equal(t, sampledOut+90, metrics.LogsSampledOut.Value())
+}
+
+func TestSampleKeepsOrDropsTheLogsOfAKeyTogether(t *testing.T) {
+	for _, test := range []struct {
+		rule   config.ProcessingRule
+		format string
+	}{
+		{config.ProcessingRule{SampleRate: 0.5, Pattern: `trace_id=(\w+)`}, "%s trace_id=%d"},
+		{config.ProcessingRule{SampleRate: 0.5, KeyField: "trace.id"}, `{"message":"%s","trace":{"id":%d}}`},
+	} {
+		source, format := newSampleSource(t, test.rule), test.format
+		keptTraces := 0
+		for i := 0; i < 100; i++ {
+			kept := countKept(source, []string{fmt.Sprintf(format, "start", i), fmt.Sprintf(format, "end", i)})
+			assert.True(t, kept == 0 || kept == 2)
+			if kept == 2 {
+				keptTraces++
+			}
+		}
+		// the share of the traces kept is close to the rate
+		assert.InDelta(t, 50, keptTraces, 20)
+	}
+}
+
+func TestSampleHashesTheKeysDeterministically(t *testing.T) {
+	rule := config.ProcessingRule{SampleRate: 0.3, Pattern: `request=(\S+)`}
+	first, second := newSampleSource(t, rule), newSampleSource(t, rule)
+	for i := 0; i < 20; i++ {
+		content := []string{fmt.Sprintf("request=%d", i)}
+		assert.Equal(t, countKept(first, content), countKept(second, content))
+	}
+}
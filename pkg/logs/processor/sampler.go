@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"math/rand"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// Sampler drops a share of the messages with an eligible status when the pipeline is congested,
+// the share grows linearly with the occupancy of the buffer of the processor,
+// from none below the low watermark up to the max drop rate above the high watermark.
+// The messages of the other statuses are always kept.
+type Sampler struct {
+	lowWatermark  float64
+	highWatermark float64
+	maxDropRate   float64
+	statuses      map[string]bool
+	random        func() float64
+}
+
+// NewSampler returns a new sampler, the watermarks are the occupancies of the buffer between 0 and 1.
+func NewSampler(lowWatermark, highWatermark, maxDropRate float64, statuses []string) *Sampler {
+	if lowWatermark < 0 || highWatermark > 1 || lowWatermark >= highWatermark {
+		log.Warnf("Invalid sampling watermarks: %v and %v, defaulting to 0.5 and 0.9", lowWatermark, highWatermark)
+		lowWatermark, highWatermark = 0.5, 0.9
+	}
+	if maxDropRate < 0 || maxDropRate > 1 {
+		log.Warnf("Invalid sampling max drop rate: %v, defaulting to 0.9", maxDropRate)
+		maxDropRate = 0.9
+	}
+	eligibleStatuses := make(map[string]bool)
+	for _, status := range statuses {
+		eligibleStatuses[status] = true
+	}
+	return &Sampler{
+		lowWatermark:  lowWatermark,
+		highWatermark: highWatermark,
+		maxDropRate:   maxDropRate,
+		statuses:      eligibleStatuses,
+		random:        rand.Float64,
+	}
+}
+
+// shouldDrop returns true if msg must be dropped given the occupancy of the buffer,
+// the sampling rate in effect is recorded.
+func (s *Sampler) shouldDrop(msg *message.Message, occupancy float64) bool {
+	dropRate := s.dropRate(occupancy)
+	metrics.SamplingRate.Set(1 - dropRate)
+	if dropRate == 0 || !s.statuses[msg.GetStatus()] {
+		return false
+	}
+	return s.random() < dropRate
+}
+
+// dropRate returns the share of eligible messages to drop for the occupancy of the buffer.
+func (s *Sampler) dropRate(occupancy float64) float64 {
+	switch {
+	case occupancy <= s.lowWatermark:
+		return 0
+	case occupancy >= s.highWatermark:
+		return s.maxDropRate
+	default:
+		return s.maxDropRate * (occupancy - s.lowWatermark) / (s.highWatermark - s.lowWatermark)
+	}
+}
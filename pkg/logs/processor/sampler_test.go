@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func TestSamplerDropRate(t *testing.T) {
+	sampler := NewSampler(0.5, 0.9, 0.8, nil)
+	assert.Equal(t, 0.0, sampler.dropRate(0))
+	assert.Equal(t, 0.0, sampler.dropRate(0.5))
+	assert.InDelta(t, 0.4, sampler.dropRate(0.7), 1e-9)
+	assert.Equal(t, 0.8, sampler.dropRate(0.9))
+	assert.Equal(t, 0.8, sampler.dropRate(1))
+}
+
+func TestNewSamplerWithInvalidSettings(t *testing.T) {
+	sampler := NewSampler(0.9, 0.5, 2, nil)
+	assert.Equal(t, 0.5, sampler.lowWatermark)
+	assert.Equal(t, 0.9, sampler.highWatermark)
+	assert.Equal(t, 0.9, sampler.maxDropRate)
+}
+
+func TestSamplerOnlyDropsEligibleMessages(t *testing.T) {
+	sampler := NewSampler(0.5, 0.9, 0.8, []string{message.StatusDebug})
+	sampler.random = func() float64 { return 0.5 }
+	source := config.NewLogSource("", &config.LogsConfig{})
+	debug := newMessage([]byte("a"), source, message.StatusDebug)
+	errorMsg := newMessage([]byte("a"), source, message.StatusError)
+
+	// full fidelity below the low watermark
+	assert.False(t, sampler.shouldDrop(debug, 0.2))
+	assert.Equal(t, 1.0, metrics.SamplingRate.Value())
+
+	// 0.4 of the eligible messages are dropped
+	assert.False(t, sampler.shouldDrop(debug, 0.7))
+	assert.InDelta(t, 0.6, metrics.SamplingRate.Value(), 1e-9)
+
+	// 0.8 of the eligible messages are dropped
+	assert.True(t, sampler.shouldDrop(debug, 1))
+	assert.False(t, sampler.shouldDrop(errorMsg, 1))
+	assert.InDelta(t, 0.2, metrics.SamplingRate.Value(), 1e-9)
+
+	metrics.SamplingRate.Set(1)
+}
+
+func TestProcessorSamplesMessagesWhenCongested(t *testing.T) {
+	inputChan := make(chan *message.Message, 10)
+	outputChan := make(chan *message.Message, 10)
+	sampler := NewSampler(0.1, 0.5, 1, []string{message.StatusDebug})
+	sampler.random = func() float64 { return 0.5 }
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, sampler, nil, nil, nil, nil)
+
+	sampled := metrics.LogsSampled.Value()
+	source := config.NewLogSource("", &config.LogsConfig{})
+	// the buffer is filled before the processor starts
+	for i := 0; i < 5; i++ {
+		inputChan <- newMessage([]byte("debug"), source, message.StatusDebug)
+		inputChan <- newMessage([]byte("error"), source, message.StatusError)
+	}
+	p.Start()
+	p.Stop()
+	close(outputChan)
+
+	var contents []string
+	for msg := range outputChan {
+		contents = append(contents, string(msg.Content))
+	}
+	// the debug messages are kept once the buffer is drained below the low watermark
+	assert.Equal(t, []string{"error", "error", "error", "debug", "error", "debug", "error"}, contents)
+	assert.Equal(t, sampled+3, metrics.LogsSampled.Value())
+	assert.Equal(t, metrics.Throughput{Processed: 7, Dropped: 3}, p.Throughput())
+	assert.Equal(t, metrics.Throughput{Processed: 7, Dropped: 3}, source.Throughput.Value())
+
+	metrics.SamplingRate.Set(1)
+}
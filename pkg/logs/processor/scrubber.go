@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// scrubbedPlaceholder replaces the secrets found in the messages,
+// bearerTokenReplacement keeps the scheme of the bearer tokens.
+var (
+	scrubbedPlaceholder    = []byte("[REDACTED]")
+	bearerTokenReplacement = []byte("${1}[REDACTED]")
+)
+
+var (
+	// creditCardPattern matches the sequences of 13 to 19 digits, optionally grouped by spaces or dashes,
+	// which are then checked to be valid card numbers.
+	creditCardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// bearerTokenPattern matches the tokens of the Authorization headers, the scheme is kept,
+	// the tokens are at least 8 characters long so that the words following bearer in a sentence are kept.
+	bearerTokenPattern = regexp.MustCompile(`(?i)\b(bearer\s+)[a-z0-9\-._~+/]{8,}=*`)
+)
+
+// minCreditCardDigits is the minimum number of digits of a card number,
+// the messages without such a sequence of digits are not matched against creditCardPattern.
+const minCreditCardDigits = 13
+
+// Scrubber masks the secrets of common shapes, credit card numbers and bearer tokens,
+// in the messages of all the sources before their processing rules are applied.
+// The messages are first scanned for cheap hints so that most of them are never matched against the patterns.
+type Scrubber struct{}
+
+// NewScrubber returns a new scrubber, or nil if it is not enabled.
+func NewScrubber(enabled bool) *Scrubber {
+	if !enabled {
+		return nil
+	}
+	return &Scrubber{}
+}
+
+// scrub returns content with its secrets masked, content itself is never modified.
+func (s *Scrubber) scrub(content []byte) []byte {
+	if hasDigitSequence(content, minCreditCardDigits) {
+		content = creditCardPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+			if isCreditCardNumber(match) {
+				return scrubbedPlaceholder
+			}
+			return match
+		})
+	}
+	if bytes.Contains(content, []byte("earer")) || bytes.Contains(content, []byte("EARER")) {
+		content = bearerTokenPattern.ReplaceAll(content, bearerTokenReplacement)
+	}
+	return content
+}
+
+// hasDigitSequence returns true if content holds a sequence of at least n digits,
+// the digits can be separated by single spaces or dashes like in card numbers.
+func hasDigitSequence(content []byte, n int) bool {
+	digits := 0
+	for i, c := range content {
+		switch {
+		case isDigit(c):
+			digits++
+			if digits >= n {
+				return true
+			}
+		case (c == ' ' || c == '-') && i > 0 && isDigit(content[i-1]):
+			// a separator within the sequence
+		default:
+			digits = 0
+		}
+	}
+	return false
+}
+
+// isDigit returns true if c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isCreditCardNumber returns true if the digits of number, separators excluded,
+// start like the numbers of the major networks and pass the Luhn check.
+func isCreditCardNumber(number []byte) bool {
+	digits := make([]int, 0, len(number))
+	for _, c := range number {
+		if isDigit(c) {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < minCreditCardDigits || len(digits) > 19 || digits[0] < 3 || digits[0] > 6 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		digit := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
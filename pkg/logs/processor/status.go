@@ -0,0 +1,36 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// parseStatus sets the status of msg to the severity found in content, either captured by the first group
+// of the pattern of rule, or matched by the whole pattern when it has no group, or held by the severity field
+// of the JSON logs. msg keeps its status when no known severity is found, which is counted.
+func parseStatus(msg *message.Message, content []byte, rule config.ProcessingRule) {
+	var status string
+	var ok bool
+	if rule.Reg != nil {
+		if match := rule.Reg.FindSubmatch(content); match != nil {
+			level := match[0]
+			if len(match) > 1 {
+				level = match[1]
+			}
+			status, ok = message.ToStatus(string(level), rule.StatusAliases)
+		}
+	} else if object, isJSON := decodeJSONObject(content); isJSON {
+		status, ok = toStatus(lookupJSONField(object, rule.SeverityField), rule.StatusAliases)
+	}
+	if !ok {
+		metrics.LogsStatusNotParsed.Add(1)
+		return
+	}
+	msg.SetStatus(status)
+}
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func newStatusSource(t *testing.T, rule config.ProcessingRule, defaultStatus string) *config.LogSource {
+	rule.Name = "status"
+	rule.Type = config.ParseStatus
+	logsConfig := &config.LogsConfig{ProcessingRules: []config.ProcessingRule{rule}, DefaultStatus: defaultStatus}
+	assert.Nil(t, logsConfig.Compile())
+	return config.NewLogSource("", logsConfig)
+}
+
+func TestParseStatusWithPattern(t *testing.T) {
+	source := newStatusSource(t, config.ProcessingRule{Pattern: `^\S+ \[(\w+)\]`}, "")
+
+	for content, expected := range map[string]string{
+		"10:00:00 [WARNING] disk almost full": message.StatusWarning,
+		"10:00:00 [ERR] disk full":            message.StatusError,
+		"10:00:00 [Fatal] out of memory":      message.StatusCritical,
+		"10:00:00 [debug] hello":              message.StatusDebug,
+	} {
+		msg := newMessage([]byte(content), source, "")
+		shouldProcess, redactedMsg := applyRedactingRules(msg)
+		assert.True(t, shouldProcess)
+		assert.Equal(t, content, string(redactedMsg))
+		assert.Equal(t, expected, msg.GetStatus(), content)
+	}
+
+	// the whole match is the severity when the pattern has no group
+	source = newStatusSource(t, config.ProcessingRule{Pattern: `\b(?i:warn|error)\b`}, "")
+	msg := newMessage([]byte("request failed: ERROR 42"), source, "")
+	applyRedactingRules(msg)
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+}
+
+func TestParseStatusWithSeverityField(t *testing.T) {
+	source := newStatusSource(t, config.ProcessingRule{SeverityField: "log.level"}, "")
+
+	msg := newMessage([]byte(`{"log":{"level":"Warn"},"message":"hello"}`), source, "")
+	applyRedactingRules(msg)
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+
+	// the numeric syslog severities
+	msg = newMessage([]byte(`{"log":{"level":3},"message":"hello"}`), source, "")
+	applyRedactingRules(msg)
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+}
+
+func TestParseStatusWithMapping(t *testing.T) {
+	mapping := map[string]string{"SEV1": message.StatusCritical, "warning": message.StatusNotice}
+	source := newStatusSource(t, config.ProcessingRule{Pattern: `severity=(\w+)`, StatusMapping: mapping}, "")
+
+	for content, expected := range map[string]string{
+		"severity=sev1 database down": message.StatusCritical,
+		"severity=WARNING slow query": message.StatusNotice,
+		"severity=error query failed": message.StatusError,
+	} {
+		msg := newMessage([]byte(content), source, "")
+		applyRedactingRules(msg)
+		assert.Equal(t, expected, msg.GetStatus(), content)
+	}
+}
+
+func TestParseStatusKeepsTheStatusWhenNoneIsFound(t *testing.T) {
+	notParsed := metrics.LogsStatusNotParsed.Value()
+	source := newStatusSource(t, config.ProcessingRule{Pattern: `level=(\w+)`}, "")
+
+	for _, content := range []string{"hello", "level=loud hello"} {
+		msg := newMessage([]byte(content), source, message.StatusNotice)
+		applyRedactingRules(msg)
+		assert.Equal(t, message.StatusNotice, msg.GetStatus())
+	}
+
+	source = newStatusSource(t, config.ProcessingRule{SeverityField: "level"}, "")
+	msg := newMessage([]byte("not json"), source, message.StatusNotice)
+	applyRedactingRules(msg)
+	assert.Equal(t, message.StatusNotice, msg.GetStatus())
+	assert.Equal(t, notParsed+3, metrics.LogsStatusNotParsed.Value())
+}
+
+func TestProcessorSetsTheDefaultStatusOfTheSource(t *testing.T) {
+	inputChan := make(chan *message.Message)
+	outputChan := make(chan *message.Message, 10)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, nil, nil, nil, nil)
+	p.Start()
+
+	source := newStatusSource(t, config.ProcessingRule{Pattern: `\[(\w+)\]`}, message.StatusWarning)
+	inputChan <- newMessage([]byte("[ERROR] disk full"), source, "")
+	inputChan <- newMessage([]byte("disk almost full"), source, "")
+	// the status set by the source is kept
+	inputChan <- newMessage([]byte("hello"), source, message.StatusDebug)
+	inputChan <- newMessage([]byte("hello"), config.NewLogSource("", &config.LogsConfig{}), "")
+	p.Stop()
+	close(outputChan)
+
+	var statuses []string
+	for msg := range outputChan {
+		statuses = append(statuses, msg.GetStatus())
+	}
+	assert.Equal(t, []string{message.StatusError, message.StatusWarning, message.StatusDebug, message.StatusInfo}, statuses)
+}
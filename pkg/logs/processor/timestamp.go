@@ -0,0 +1,35 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// parseTimestamp sets the timestamp of msg to the time written in content,
+// msg keeps the time it was received at when content holds no timestamp parsed by parser, which is counted.
+func parseTimestamp(msg *message.Message, content []byte, parser *config.TimestampParser) {
+	timestamp, ok := parser.Parse(content)
+	if !ok {
+		metrics.LogsTimestampNotParsed.Add(1)
+		return
+	}
+	msg.Timestamp = timestamp.UTC().Format(time.RFC3339Nano)
+}
+
+// timestampOf returns the time of msg, either its timestamp when it is set or the current time.
+func timestampOf(msg *message.Message) time.Time {
+	if msg.Timestamp != "" {
+		if timestamp, err := time.Parse(time.RFC3339Nano, msg.Timestamp); err == nil {
+			return timestamp.UTC()
+		}
+	}
+	return time.Now().UTC()
+}
@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func newTimestampSource(t *testing.T, format, timezone string) *config.LogSource {
+	rule := config.ProcessingRule{Name: "timestamp", Type: config.ParseTimestamp, Format: format, Timezone: timezone}
+	logsConfig := &config.LogsConfig{ProcessingRules: []config.ProcessingRule{rule}}
+	assert.Nil(t, logsConfig.Compile())
+	return config.NewLogSource("", logsConfig)
+}
+
+func TestParseTimestampSetsTheTimestamp(t *testing.T) {
+	source := newTimestampSource(t, "%Y-%m-%d %H:%M:%S", "America/New_York")
+	content := []byte("2018-10-15 04:30:00 INFO hello")
+	msg := newMessage(content, source, "")
+
+	shouldProcess, redactedMsg := applyRedactingRules(msg)
+	assert.True(t, shouldProcess)
+	assert.Equal(t, content, redactedMsg)
+	assert.Equal(t, "2018-10-15T08:30:00Z", msg.Timestamp)
+}
+
+func TestParseTimestampCountsTheLogsWithoutTimestamp(t *testing.T) {
+	notParsed := metrics.LogsTimestampNotParsed.Value()
+	msg := newMessage([]byte("INFO hello"), newTimestampSource(t, "rfc3339", ""), "")
+
+	applyRedactingRules(msg)
+	assert.Equal(t, "", msg.Timestamp)
+	assert.Equal(t, notParsed+1, metrics.LogsTimestampNotParsed.Value())
+}
+
+func TestTimestampOf(t *testing.T) {
+	msg := newMessage([]byte("hello"), config.NewLogSource("", &config.LogsConfig{}), "")
+	msg.Timestamp = "2018-10-15T08:30:00.5Z"
+	assert.Equal(t, time.Date(2018, 10, 15, 8, 30, 0, 500000000, time.UTC), timestampOf(msg))
+
+	// the messages without a valid timestamp are sent at the current time
+	for _, timestamp := range []string{"", "not a timestamp"} {
+		msg.Timestamp = timestamp
+		assert.WithinDuration(t, time.Now(), timestampOf(msg), time.Minute)
+	}
+}
@@ -0,0 +1,155 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// escapedLineFeed joins the lines of the messages aggregated by the multi-line rules.
+var escapedLineFeed = []byte(`\n`)
+
+// Truncator cuts the messages larger than the max size the backend accepts,
+// and ends them with a marker so that the truncation is visible, the marker counts in the max size,
+// or splits them into parts for the sources which must not lose the end of their messages.
+type Truncator struct {
+	maxSize int
+	marker  []byte
+}
+
+// NewTruncator returns a new truncator, or nil if maxSize is not positive.
+func NewTruncator(maxSize int, marker string) *Truncator {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &Truncator{
+		maxSize: maxSize,
+		marker:  []byte(marker),
+	}
+}
+
+// reserve returns a truncator leaving n bytes of the max size for what is added to the contents once cut,
+// the truncator itself when n is not positive.
+func (t *Truncator) reserve(n int) *Truncator {
+	if n <= 0 {
+		return t
+	}
+	maxSize := t.maxSize - n
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	return &Truncator{
+		maxSize: maxSize,
+		marker:  t.marker,
+	}
+}
+
+// truncate returns content cut to the max size and whether it was cut,
+// a multi-byte character is never split.
+func (t *Truncator) truncate(content []byte) ([]byte, bool) {
+	if len(content) <= t.maxSize {
+		return content, false
+	}
+	cut := t.maxSize - len(t.marker)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	truncated := make([]byte, 0, cut+len(t.marker))
+	truncated = append(truncated, content[:cut]...)
+	truncated = append(truncated, t.marker...)
+	if len(truncated) > t.maxSize {
+		// the marker alone exceeds the max size
+		truncated = truncated[:t.maxSize]
+	}
+	return truncated, true
+}
+
+// split returns content cut into parts of at most the max size and whether it was cut,
+// the parts end with a line feed, or the escaped line feed joining the lines of the multi-line messages,
+// when there is one, otherwise they are cut at the max size without splitting a multi-byte character.
+func (t *Truncator) split(content []byte) ([][]byte, bool) {
+	if len(content) <= t.maxSize {
+		return nil, false
+	}
+	var parts [][]byte
+	for len(content) > t.maxSize {
+		cut := lastLineEnd(content[:t.maxSize])
+		if cut == 0 {
+			cut = t.maxSize
+			for cut > 0 && !utf8.RuneStart(content[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				// the max size is below the size of a character
+				cut = t.maxSize
+			}
+		}
+		parts = append(parts, content[:cut])
+		content = content[cut:]
+	}
+	return append(parts, content), true
+}
+
+// lastLineEnd returns the position following the last line feed or escaped line feed of content, 0 if there is none.
+func lastLineEnd(content []byte) int {
+	end := bytes.LastIndexByte(content, '\n') + 1
+	if escaped := bytes.LastIndex(content, escapedLineFeed); escaped >= 0 && escaped+len(escapedLineFeed) > end {
+		end = escaped + len(escapedLineFeed)
+	}
+	return end
+}
+
+// newChunkGroup returns a random identifier of the parts of a message.
+func newChunkGroup() string {
+	group := make([]byte, 8)
+	rand.Read(group)
+	return hex.EncodeToString(group)
+}
+
+// newChunk returns the part index of the count parts of msg, with content, tagged with its group and index.
+// Only the last part commits the offset of msg so that msg is sent again if the agent stops before it is sent.
+func newChunk(msg *message.Message, content []byte, group string, index, count int) *message.Message {
+	origin := *msg.Origin
+	if index < count-1 {
+		origin.Identifier = ""
+	}
+	origin.AddTags("chunk_group:"+group, "chunk_index:"+strconv.Itoa(index), "chunk_count:"+strconv.Itoa(count))
+	chunk := *msg
+	chunk.Content = content
+	chunk.Origin = &origin
+	chunk.Chunk = &message.Chunk{Group: group, Index: index, Count: count}
+	return &chunk
+}
+
+// chunkAttributes returns a copy of attributes with the index and count of a part of a split message.
+func chunkAttributes(attributes map[string]interface{}, index, count int) map[string]interface{} {
+	copied := make(map[string]interface{}, len(attributes)+2)
+	for key, value := range attributes {
+		copied[key] = value
+	}
+	copied["chunk.index"] = index
+	copied["chunk.count"] = count
+	return copied
+}
+
+// chunkAttributesLen returns the most bytes withAttributes adds to a part of a split message with attributes,
+// 0 without attributes.
+func chunkAttributesLen(attributes map[string]interface{}) int {
+	if len(attributes) == 0 {
+		return 0
+	}
+	return attributesLen(chunkAttributes(attributes, math.MaxInt32, math.MaxInt32))
+}
@@ -0,0 +1,210 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package processor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func TestNewTruncatorWithoutMaxSize(t *testing.T) {
+	assert.Nil(t, NewTruncator(0, "..."))
+	assert.Nil(t, NewTruncator(-1, "..."))
+}
+
+func TestTruncatorTruncate(t *testing.T) {
+	truncator := NewTruncator(10, "...")
+
+	content, isTruncated := truncator.truncate([]byte("0123456789"))
+	assert.False(t, isTruncated)
+	assert.Equal(t, "0123456789", string(content))
+
+	content, isTruncated = truncator.truncate([]byte("0123456789a"))
+	assert.True(t, isTruncated)
+	assert.Equal(t, "0123456...", string(content))
+
+	// the multi-byte characters are not split
+	content, isTruncated = truncator.truncate([]byte("012345éééé"))
+	assert.True(t, isTruncated)
+	assert.Equal(t, "012345...", string(content))
+
+	// the marker is cut when it exceeds the max size
+	truncator = NewTruncator(2, "...")
+	content, isTruncated = truncator.truncate([]byte("0123"))
+	assert.True(t, isTruncated)
+	assert.Equal(t, "..", string(content))
+}
+
+func TestProcessorTruncatesLargeMessages(t *testing.T) {
+	inputChan := make(chan *message.Message, 10)
+	outputChan := make(chan *message.Message, 10)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, NewTruncator(10, "..."), nil, nil, nil)
+
+	truncated := metrics.LogsTruncated.Value()
+	source := config.NewLogSource("", &config.LogsConfig{})
+	inputChan <- newMessage([]byte("short"), source, "")
+	inputChan <- newMessage([]byte("a message too long"), source, "")
+	p.Start()
+	p.Stop()
+
+	msg := <-outputChan
+	assert.Equal(t, "short", string(msg.Content))
+	assert.Equal(t, 0, msg.OriginalLen)
+	msg = <-outputChan
+	assert.Equal(t, "a messa...", string(msg.Content))
+	assert.Equal(t, len("a message too long"), msg.OriginalLen)
+
+	assert.Equal(t, truncated+1, metrics.LogsTruncated.Value())
+	assert.Equal(t, metrics.Throughput{Processed: 2, Truncated: 1}, p.Throughput())
+	assert.Equal(t, metrics.Throughput{Processed: 2, Truncated: 1}, source.Throughput.Value())
+}
+
+func TestTruncatorReserve(t *testing.T) {
+	truncator := NewTruncator(10, "...")
+	assert.True(t, truncator == truncator.reserve(0))
+
+	content, isTruncated := truncator.reserve(4).truncate([]byte("0123456789"))
+	assert.True(t, isTruncated)
+	assert.Equal(t, "012...", string(content))
+
+	// the max size never drops to zero
+	content, isTruncated = truncator.reserve(20).truncate([]byte("0123456789"))
+	assert.True(t, isTruncated)
+	assert.Equal(t, ".", string(content))
+}
+
+func TestProcessorTruncatesTheContentOfLargeMessagesWithAttributes(t *testing.T) {
+	inputChan := make(chan *message.Message, 10)
+	outputChan := make(chan *message.Message, 10)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, NewTruncator(50, "..."), nil, nil, nil)
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte("a message too long"), source, "")
+	msg.Attributes = map[string]interface{}{"http.method": "GET"}
+	inputChan <- msg
+	p.Start()
+	p.Stop()
+
+	msg = <-outputChan
+	assert.True(t, len(msg.Content) <= 50)
+	assert.Equal(t, len("a message too long"), msg.OriginalLen)
+	// the message is cut, not the json payload holding it
+	var payload map[string]interface{}
+	assert.Nil(t, json.Unmarshal(msg.Content, &payload))
+	assert.Equal(t, "a message...", payload["message"])
+	assert.Equal(t, map[string]interface{}{"method": "GET"}, payload["http"])
+}
+
+func TestTruncatorSplit(t *testing.T) {
+	truncator := NewTruncator(10, "...")
+
+	parts, isSplit := truncator.split([]byte("0123456789"))
+	assert.False(t, isSplit)
+	assert.Nil(t, parts)
+
+	// the parts end with the last line feed within the max size
+	parts, isSplit = truncator.split([]byte("0123\n567\n90123456789abc"))
+	assert.True(t, isSplit)
+	assert.Equal(t, [][]byte{[]byte("0123\n567\n"), []byte("9012345678"), []byte("9abc")}, parts)
+
+	// or with the escaped line feeds joining the lines of the multi-line messages
+	parts, isSplit = truncator.split([]byte(`Error\n	at a\n	at b`))
+	assert.True(t, isSplit)
+	assert.Equal(t, [][]byte{[]byte(`Error\n`), []byte(`	at a\n`), []byte(`	at b`)}, parts)
+
+	// the multi-byte characters are not split
+	parts, isSplit = truncator.split([]byte("012345éééé"))
+	assert.True(t, isSplit)
+	assert.Equal(t, [][]byte{[]byte("012345éé"), []byte("éé")}, parts)
+}
+
+func TestNewChunk(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte("0123456789"), source, message.StatusError)
+	msg.Origin.Identifier = "file:/var/log/app.log"
+	msg.Origin.Offset = "42"
+	msg.Origin.SetTags([]string{"foo:bar"})
+
+	first := newChunk(msg, []byte("01234"), "abc", 0, 2)
+	last := newChunk(msg, []byte("56789"), "abc", 1, 2)
+	assert.Equal(t, "01234", string(first.Content))
+	assert.Equal(t, message.StatusError, first.GetStatus())
+	assert.Equal(t, &message.Chunk{Group: "abc", Index: 0, Count: 2}, first.Chunk)
+	assert.Equal(t, []string{"foo:bar", "chunk_group:abc", "chunk_index:0", "chunk_count:2"}, first.Origin.Tags())
+	assert.Equal(t, []string{"foo:bar", "chunk_group:abc", "chunk_index:1", "chunk_count:2"}, last.Origin.Tags())
+	assert.Equal(t, []string{"foo:bar"}, msg.Origin.Tags())
+
+	// only the last part commits the offset of the message
+	assert.Equal(t, "", first.Origin.Identifier)
+	assert.Equal(t, "file:/var/log/app.log", last.Origin.Identifier)
+	assert.Equal(t, "42", last.Origin.Offset)
+}
+
+func TestProcessorSplitsLargeMessagesOfTheSourcesSplittingThem(t *testing.T) {
+	inputChan := make(chan *message.Message, 10)
+	outputChan := make(chan *message.Message, 10)
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, NewTruncator(10, "..."), nil, nil, nil)
+
+	split := metrics.LogsSplit.Value()
+	source := config.NewLogSource("", &config.LogsConfig{OversizePolicy: config.SplitOversizePolicy})
+	inputChan <- newMessage([]byte("short"), source, "")
+	inputChan <- newMessage([]byte("a message\ntoo long"), source, "")
+	p.Start()
+	p.Stop()
+	close(outputChan)
+
+	var contents []string
+	var chunks []*message.Chunk
+	for msg := range outputChan {
+		contents = append(contents, string(msg.Content))
+		chunks = append(chunks, msg.Chunk)
+	}
+	assert.Equal(t, []string{"short", "a message\n", "too long"}, contents)
+	assert.Nil(t, chunks[0])
+	assert.Equal(t, 0, chunks[1].Index)
+	assert.Equal(t, 1, chunks[2].Index)
+	assert.Equal(t, 2, chunks[2].Count)
+	assert.Equal(t, chunks[1].Group, chunks[2].Group)
+	assert.Len(t, chunks[1].Group, 16)
+
+	assert.Equal(t, split+1, metrics.LogsSplit.Value())
+	assert.Equal(t, metrics.Throughput{Processed: 2}, p.Throughput())
+}
+
+func TestProcessorSplitsTheContentOfLargeMessagesWithAttributes(t *testing.T) {
+	inputChan := make(chan *message.Message, 10)
+	outputChan := make(chan *message.Message, 10)
+	maxSize := chunkAttributesLen(map[string]interface{}{"http.method": "GET"}) + 10
+	p := New(inputChan, outputChan, &noopEncoder{}, 1, nil, NewTruncator(maxSize, "..."), nil, nil, nil)
+
+	source := config.NewLogSource("", &config.LogsConfig{OversizePolicy: config.SplitOversizePolicy})
+	msg := newMessage([]byte("a message\ntoo long"), source, "")
+	msg.Attributes = map[string]interface{}{"http.method": "GET"}
+	inputChan <- msg
+	p.Start()
+	p.Stop()
+	close(outputChan)
+
+	var messages []interface{}
+	for msg := range outputChan {
+		assert.True(t, len(msg.Content) <= maxSize)
+		// each part is a json payload holding a part of the message with the attributes
+		var payload map[string]interface{}
+		assert.Nil(t, json.Unmarshal(msg.Content, &payload))
+		messages = append(messages, payload["message"])
+		assert.Equal(t, map[string]interface{}{"method": "GET"}, payload["http"])
+		assert.Equal(t, map[string]interface{}{"index": float64(msg.Chunk.Index), "count": float64(2)}, payload["chunk"])
+	}
+	assert.Equal(t, []interface{}{"a message\n", "too long"}, messages)
+	// the attributes of the message are left as is
+	assert.Equal(t, map[string]interface{}{"http.method": "GET"}, msg.Attributes)
+}
@@ -0,0 +1,41 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package restart
+
+import (
+	"context"
+)
+
+// Flushable represents an object whose messages in flight can be flushed without stopping it
+type Flushable interface {
+	Flush(ctx context.Context) error
+}
+
+// FlushRequests passes the flush requests to the goroutine forwarding the messages of a part of a data pipeline,
+// which closes the channel of a request once the messages it held when receiving it are forwarded.
+type FlushRequests chan chan struct{}
+
+// NewFlushRequests returns a new FlushRequests
+func NewFlushRequests() FlushRequests {
+	return make(FlushRequests)
+}
+
+// Request blocks until the messages held when the request is received are forwarded,
+// returns an error if ctx is done first.
+func (r FlushRequests) Request(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case r <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
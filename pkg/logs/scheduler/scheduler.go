@@ -8,10 +8,12 @@ package scheduler
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/providers"
 	"github.com/DataDog/datadog-agent/pkg/logs/service"
+	"github.com/DataDog/datadog-agent/pkg/secrets"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
 	logsConfig "github.com/DataDog/datadog-agent/pkg/logs/config"
@@ -25,6 +27,10 @@ import (
 type Scheduler struct {
 	sources  *logsConfig.LogSources
 	services *service.Services
+	// fileSources are the sources defined in configuration files,
+	// they are the only ones diffed when the configuration is reloaded.
+	fileSources []*logsConfig.LogSource
+	mu          sync.Mutex
 }
 
 // NewScheduler returns a new scheduler.
@@ -55,6 +61,11 @@ func (s *Scheduler) Schedule(configs []integration.Config) {
 				log.Warnf("Invalid configuration: %v", err)
 				continue
 			}
+			if s.isFileConfig(config) {
+				s.mu.Lock()
+				s.fileSources = append(s.fileSources, sources...)
+				s.mu.Unlock()
+			}
 			for _, source := range sources {
 				s.sources.AddSource(source)
 			}
@@ -110,11 +121,53 @@ func (s *Scheduler) Unschedule(configs []integration.Config) {
 	}
 }
 
+// Reload diffs the sources defined in the configuration files against the ones of configs:
+// the new sources are added and start being collected from their start position,
+// the removed ones are removed and their tailers stopped, and the unchanged ones are left untouched
+// so that they keep being collected from their current offset, without any gap or duplicate.
+func (s *Scheduler) Reload(configs []integration.Config) {
+	var newSources []*logsConfig.LogSource
+	for _, config := range configs {
+		if !s.isLogConfig(config) || !s.isFileConfig(config) {
+			continue
+		}
+		sources, err := s.toSources(config)
+		if err != nil {
+			log.Warnf("Invalid configuration: %v", err)
+			continue
+		}
+		newSources = append(newSources, sources...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unchangedSources, addedSources, removedSources := logsConfig.DiffSources(s.fileSources, newSources)
+	s.fileSources = append(unchangedSources, addedSources...)
+
+	// remove the sources first so that a modified source
+	// takes over the tailers of its previous version
+	for _, source := range removedSources {
+		log.Infof("Removing logs config: %v", source.Name)
+		s.sources.RemoveSource(source)
+	}
+	for _, source := range addedSources {
+		log.Infof("Adding logs config: %v", source.Name)
+		s.sources.AddSource(source)
+	}
+}
+
 // isLogConfig returns true if config contains a logs config.
 func (s *Scheduler) isLogConfig(config integration.Config) bool {
 	return config.LogsConfig != nil
 }
 
+// isFileConfig returns true if config is defined in a configuration file and is not a template,
+// templates being resolved by autodiscovery for each service.
+func (s *Scheduler) isFileConfig(config integration.Config) bool {
+	return config.Provider == providers.File && !config.IsTemplate() && config.Entity == ""
+}
+
 // newSources returns true if the config can be mapped to sources.
 func (s *Scheduler) newSources(config integration.Config) bool {
 	return config.Provider != ""
@@ -182,6 +235,11 @@ func (s *Scheduler) toSources(config integration.Config) ([]*logsConfig.LogSourc
 
 		source := logsConfig.NewLogSource(configName, cfg)
 		sources = append(sources, source)
+		if err := cfg.Interpolate(secrets.DecryptHandle); err != nil {
+			log.Warnf("Invalid logs configuration: %v", err)
+			source.Status.Error(err)
+			continue
+		}
 		if err := cfg.Validate(); err != nil {
 			log.Warnf("Invalid logs configuration: %v", err)
 			source.Status.Error(err)
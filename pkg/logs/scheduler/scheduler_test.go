@@ -6,6 +6,8 @@
 package scheduler
 
 import (
+	"fmt"
+	"os"
 	"testing"
 
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
@@ -108,3 +110,94 @@ func TestUnscheduleConfigRemovesService(t *testing.T) {
 	svc := <-servicesStream
 	assert.Equal(t, configService.Entity, svc.GetEntityID())
 }
+
+func TestReloadDiffsSourcesOfConfigFiles(t *testing.T) {
+	logSources := config.NewLogSources()
+	services := service.NewServices()
+	scheduler := NewScheduler(logSources, services)
+	addedSources := logSources.GetAddedForType(config.FileType)
+	removedSources := logSources.GetRemovedForType(config.FileType)
+
+	newConfig := func(name, path, startPosition string) integration.Config {
+		logsConfig := fmt.Sprintf("logs:\n  - type: file\n    path: %s\n    start_position: %s\n", path, startPosition)
+		return integration.Config{Name: name, Provider: providers.File, LogsConfig: []byte(logsConfig)}
+	}
+
+	go scheduler.Schedule([]integration.Config{
+		newConfig("foo", "/var/log/foo.log", ""),
+		newConfig("bar", "/var/log/bar.log", ""),
+	})
+	foo := <-addedSources
+	bar := <-addedSources
+
+	// foo is unchanged, bar is modified and baz is new
+	go scheduler.Reload([]integration.Config{
+		newConfig("foo", "/var/log/foo.log", ""),
+		newConfig("bar", "/var/log/bar.log", config.EndStartPosition),
+		newConfig("baz", "/var/log/baz.log", config.BeginningStartPosition),
+	})
+	assert.Equal(t, bar, <-removedSources)
+	newBar := <-addedSources
+	assert.Equal(t, "bar", newBar.Name)
+	assert.Equal(t, config.EndStartPosition, newBar.Config.StartPosition)
+	baz := <-addedSources
+	assert.Equal(t, "baz", baz.Name)
+	assert.Equal(t, config.BeginningStartPosition, baz.Config.StartPosition)
+
+	scheduler.mu.Lock()
+	assert.Equal(t, []*config.LogSource{foo, newBar, baz}, scheduler.fileSources)
+	scheduler.mu.Unlock()
+	assert.Equal(t, []*config.LogSource{foo, newBar, baz}, logSources.GetSources())
+
+	// all the configs have been removed
+	go scheduler.Reload(nil)
+	assert.Equal(t, foo, <-removedSources)
+	assert.Equal(t, newBar, <-removedSources)
+	assert.Equal(t, baz, <-removedSources)
+}
+
+func TestReloadIgnoresTemplatesAndOtherProviders(t *testing.T) {
+	logSources := config.NewLogSources()
+	services := service.NewServices()
+	scheduler := NewScheduler(logSources, services)
+
+	scheduler.Reload([]integration.Config{
+		{
+			Name:          "foo",
+			Provider:      providers.File,
+			ADIdentifiers: []string{"redis"},
+			LogsConfig:    []byte("logs:\n  - type: file\n    path: /var/log/foo.log\n"),
+		},
+		{
+			Name:       "bar",
+			Provider:   providers.Kubernetes,
+			Entity:     "docker://a1887023ed72a2b0d083ef465e8edfe4932a25731d4bda2f39f288f70af3405b",
+			LogsConfig: []byte(`[{"service":"foo","source":"bar"}]`),
+		},
+	})
+	assert.Equal(t, 0, len(scheduler.fileSources))
+	assert.Equal(t, 0, len(logSources.GetSources()))
+}
+
+func TestScheduleConfigInterpolatesTheSources(t *testing.T) {
+	os.Setenv("DD_TEST_LOG_DIR", "/var/log/app")
+	defer os.Unsetenv("DD_TEST_LOG_DIR")
+	logSources := config.NewLogSources()
+	services := service.NewServices()
+	scheduler := NewScheduler(logSources, services)
+	addedSources := logSources.GetAddedForType(config.FileType)
+
+	go scheduler.Schedule([]integration.Config{
+		{Name: "bar", Provider: providers.File, LogsConfig: []byte("logs:\n  - type: file\n    path: ${DD_TEST_UNSET_LOG_DIR}/bar.log\n")},
+		{Name: "foo", Provider: providers.File, LogsConfig: []byte("logs:\n  - type: file\n    path: ${DD_TEST_LOG_DIR}/foo.log\n")},
+	})
+	// the source whose references can not be resolved is not collected
+	foo := <-addedSources
+	assert.Equal(t, "foo", foo.Name)
+	assert.Equal(t, "/var/log/app/foo.log", foo.Config.Path)
+	sources := logSources.GetSources()
+	assert.Equal(t, 2, len(sources))
+	assert.Equal(t, "bar", sources[0].Name)
+	assert.True(t, sources[0].Status.IsError())
+	assert.Equal(t, "Error: could not interpolate path: environment variable DD_TEST_UNSET_LOG_DIR is not set", sources[0].Status.GetError())
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// Policies applied when an additional destination does not keep up with the main one.
+const (
+	// DropOnFull drops the logs when the buffer of the destination is full.
+	DropOnFull = "drop"
+	// BlockOnFull waits for the buffer of the destination to have room, slowing down the main destination.
+	BlockOnFull = "block"
+)
+
+// messagePublisher is implemented by the additional destinations publishing each message
+// according to its source, they are handed the messages instead of their content.
+type messagePublisher interface {
+	SendMessage(msg *message.Message) error
+}
+
+// additionalSender sends the logs to an additional destination from a goroutine of its own,
+// so that a slow or unreachable additional destination does not delay the main one.
+type additionalSender struct {
+	destination client.AdditionalDestination
+	// route selects the logs sent to the destination, all of them when nil
+	route     *route
+	inputChan chan *message.Message
+	block     bool
+	done      chan struct{}
+}
+
+// newAdditionalSender returns a new additional sender applying the buffer full policy of the destination.
+func newAdditionalSender(destination client.AdditionalDestination) *additionalSender {
+	policy := destination.BufferFullPolicy()
+	switch policy {
+	case DropOnFull, BlockOnFull:
+	case "":
+		policy = DropOnFull
+	default:
+		log.Warnf("Invalid buffer full policy for %v: %v, defaulting to %v", destination.Address(), policy, DropOnFull)
+		policy = DropOnFull
+	}
+	return &additionalSender{
+		destination: destination,
+		route:       newRoute(destination.Route()),
+		inputChan:   make(chan *message.Message, config.ChanSize),
+		block:       policy == BlockOnFull,
+		done:        make(chan struct{}),
+	}
+}
+
+// start starts sending the logs.
+func (s *additionalSender) start() {
+	go s.run()
+}
+
+// stop stops the sender,
+// this call blocks until the buffer is flushed.
+func (s *additionalSender) stop() {
+	close(s.inputChan)
+	<-s.done
+}
+
+// run sends the logs until the buffer is closed,
+// with a try and forget strategy, the logs which failed to be sent are not retried.
+func (s *additionalSender) run() {
+	defer func() {
+		s.done <- struct{}{}
+	}()
+	publisher, isPublisher := s.destination.(messagePublisher)
+	for msg := range s.inputChan {
+		// this call is blocking when the connection is not established yet
+		var err error
+		if isPublisher {
+			err = publisher.SendMessage(msg)
+		} else {
+			err = s.destination.Send(msg.Content)
+		}
+		if err == client.ErrCircuitOpen {
+			metrics.CircuitBreakerDrops.Add(s.destination.Address(), 1)
+		}
+	}
+}
+
+// send adds msg to the buffer, msg is dropped if the buffer is full
+// unless the policy is to block.
+func (s *additionalSender) send(msg *message.Message) {
+	if s.block {
+		s.inputChan <- msg
+		return
+	}
+	select {
+	case s.inputChan <- msg:
+	default:
+		metrics.DestinationDrops.Add(s.destination.Address(), 1)
+	}
+}
@@ -0,0 +1,177 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"expvar"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// newUnreachableDestination returns a destination which blocks until the context is stopped.
+func newUnreachableDestination(t *testing.T, ctx *client.DestinationsContext, policy string) *client.Destination {
+	// nothing listens on the port of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	l.Close()
+	endpoint := client.AddrToEndPoint(l.Addr())
+	endpoint.BackoffBase = time.Hour
+	endpoint.BackoffMax = time.Hour
+	endpoint.BufferFullPolicy = policy
+	return client.NewDestination(endpoint, ctx)
+}
+
+func droppedBy(destination client.AdditionalDestination) int64 {
+	if dropped, ok := metrics.DestinationDrops.Get(destination.Address()).(*expvar.Int); ok {
+		return dropped.Value()
+	}
+	return 0
+}
+
+func TestSenderDoesNotWaitForUnreachableAdditionalDestination(t *testing.T) {
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	input := make(chan *message.Message, 1)
+	output := make(chan *message.Message, 1)
+
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destinationsCtx.Start()
+
+	main := client.AddrToDestination(l.Addr(), destinationsCtx)
+	additional := newUnreachableDestination(t, destinationsCtx, "")
+	sender := NewSender(input, output, client.NewDestinations(main, []client.AdditionalDestination{additional}), 0, nil, BatchStrategy{})
+	sender.Start()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	count := 2*config.ChanSize + 1
+	for i := 0; i < count; i++ {
+		input <- newMessage([]byte("fake line"), source, "")
+		<-output
+	}
+	// the additional sender holds one message, when it took it before the buffer was full, and its buffer is full
+	dropped := droppedBy(additional)
+	assert.True(t, dropped == int64(count-config.ChanSize-1) || dropped == int64(count-config.ChanSize), dropped)
+
+	destinationsCtx.Stop()
+	sender.Stop()
+}
+
+func TestAdditionalSenderBlocksWhenFull(t *testing.T) {
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destinationsCtx.Start()
+
+	additional := newAdditionalSender(newUnreachableDestination(t, destinationsCtx, BlockOnFull))
+	additional.start()
+	for i := 0; i < config.ChanSize+1; i++ {
+		additional.send(message.NewMessage([]byte("fake line"), nil, ""))
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		additional.send(message.NewMessage([]byte("fake line"), nil, ""))
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		assert.Fail(t, "the message should not have been buffered")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, int64(0), droppedBy(additional.destination))
+
+	// the destination stops blocking once the context is stopped
+	destinationsCtx.Stop()
+	<-sent
+	additional.stop()
+}
+
+func TestAdditionalSenderDefaultsToDrop(t *testing.T) {
+	destinationsCtx := client.NewDestinationsContext(nil)
+	assert.False(t, newAdditionalSender(newUnreachableDestination(t, destinationsCtx, "")).block)
+	assert.False(t, newAdditionalSender(newUnreachableDestination(t, destinationsCtx, "foo")).block)
+	assert.True(t, newAdditionalSender(newUnreachableDestination(t, destinationsCtx, BlockOnFull)).block)
+}
+
+func TestSenderPostsTheLogsToHTTPAdditionalDestination(t *testing.T) {
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+	host, portString, err := net.SplitHostPort(u.Host)
+	assert.Nil(t, err)
+	port, err := strconv.Atoi(portString)
+	assert.Nil(t, err)
+
+	input := make(chan *message.Message, 1)
+	output := make(chan *message.Message, 1)
+
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destinationsCtx.Start()
+
+	main := client.AddrToDestination(l.Addr(), destinationsCtx)
+	additional := client.NewHTTPDestination(client.Endpoint{Host: host, Port: port, UseHTTP: true}, destinationsCtx)
+	sender := NewSender(input, output, client.NewDestinations(main, []client.AdditionalDestination{additional}), 0, nil, BatchStrategy{})
+	sender.Start()
+
+	input <- newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	<-output
+	select {
+	case body := <-bodies:
+		assert.Contains(t, body, "fake line")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the additional destination should have received the log")
+	}
+
+	destinationsCtx.Stop()
+	sender.Stop()
+}
+
+// publishingDestination records the messages published.
+type publishingDestination struct {
+	*client.Destination
+	published chan *message.Message
+}
+
+func (d *publishingDestination) SendMessage(msg *message.Message) error {
+	d.published <- msg
+	return nil
+}
+
+func TestAdditionalSenderHandsTheMessagesToThePublishers(t *testing.T) {
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destination := &publishingDestination{
+		Destination: newUnreachableDestination(t, destinationsCtx, ""),
+		published:   make(chan *message.Message, 1),
+	}
+	additional := newAdditionalSender(destination)
+	additional.start()
+	defer additional.stop()
+
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{KafkaTopic: "logs"}), "")
+	additional.send(msg)
+	assert.Equal(t, msg, <-destination.published)
+}
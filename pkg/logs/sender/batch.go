@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"context"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/lifecycle"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// BatchStrategy holds the conditions triggering the sending of a batch of messages in a single write,
+// whichever comes first: the batch holds MaxCount messages, MaxBytes of content, 0 means no limit,
+// or its first message has waited for MaxLinger, 0 means that the batch is sent as soon as no message is waiting.
+// The messages are sent one by one when MaxCount is lower than 2.
+type BatchStrategy struct {
+	MaxBytes  int
+	MaxCount  int
+	MaxLinger time.Duration
+}
+
+// isEnabled returns true if the messages are sent by batches.
+func (b BatchStrategy) isEnabled() bool {
+	return b.MaxCount > 1
+}
+
+// runBatches sends the messages by batches until inputChan is closed, the partial batch is then sent,
+// as well as on a flush request once the messages of inputChan are added to it.
+func (s *Sender) runBatches() {
+	batch := make([]*message.Message, 0, s.batch.MaxCount)
+	size := 0
+	var linger <-chan time.Time
+	flush := func() {
+		if len(batch) > 0 {
+			s.sendBatch(batch)
+			s.backlog.ReleaseAll()
+			batch = batch[:0]
+			size = 0
+		}
+		linger = nil
+	}
+	add := func(payload *message.Message) {
+		if s.batch.MaxBytes > 0 && len(batch) > 0 && size+len(payload.Content) > s.batch.MaxBytes {
+			// the message does not fit in the batch
+			flush()
+		}
+		batch = append(batch, payload)
+		size += len(payload.Content)
+		s.backlog.Hold(payload.EnqueuedAt)
+		if len(batch) == 1 && s.batch.MaxLinger > 0 {
+			linger = time.After(s.batch.MaxLinger)
+		}
+		if len(batch) >= s.batch.MaxCount || (s.batch.MaxBytes > 0 && size >= s.batch.MaxBytes) || (s.batch.MaxLinger <= 0 && len(s.inputChan) == 0) {
+			flush()
+		}
+	}
+	for {
+		select {
+		case payload, isOpen := <-s.inputChan:
+			if !isOpen {
+				flush()
+				return
+			}
+			add(payload)
+		case <-linger:
+			flush()
+		case done := <-s.flush:
+			for i := len(s.inputChan); i > 0; i-- {
+				add(<-s.inputChan)
+			}
+			flush()
+			close(done)
+		}
+	}
+}
+
+// sendBatch keeps trying to send the messages of batch to the main destination in a single write until it succeeds,
+// the messages rejected by the hook or expired are dropped, all the messages are then forwarded to outputChan in order.
+func (s *Sender) sendBatch(batch []*message.Message) {
+	// dropped holds why each message is dropped, empty for the messages kept
+	dropped := make([]string, len(batch))
+	for i, payload := range batch {
+		if !s.hook.apply(payload) {
+			dropped[i] = lifecycle.DropRejected
+		}
+	}
+	s.sendKept(batch, dropped)
+}
+
+// sendKept keeps trying to send the messages of batch which are not dropped in a single write until it succeeds,
+// when the destination rejects the batch as too large, each half of the batch is sent in turn instead.
+// All the messages are then forwarded to outputChan in order.
+func (s *Sender) sendKept(batch []*message.Message, dropped []string) {
+	var err error
+	for {
+		var contents [][]byte
+		for i, payload := range batch {
+			if dropped[i] == "" && s.isExpired(payload) {
+				metrics.LogsExpired.Add(1)
+				// the message is too old to be useful, drop the message
+				dropped[i] = lifecycle.DropExpired
+			}
+			if dropped[i] == "" {
+				contents = append(contents, payload.Content)
+			}
+		}
+		if len(contents) == 0 {
+			break
+		}
+		// this call is blocking until the batch is sent (or the connection destination context cancelled)
+		err = s.destinations.Main.SendBatch(contents)
+		if err == client.ErrCircuitOpen {
+			// the main destination keeps failing, drop the messages until it is probed again
+			metrics.CircuitBreakerDrops.Add(s.destinations.Main.Address(), int64(len(contents)))
+			dropAll(dropped, lifecycle.DropCircuitOpen)
+			break
+		}
+		if err != nil {
+			metrics.DestinationErrors.Add(1)
+			if _, isTooLarge := err.(*client.TooLargeError); isTooLarge {
+				// the batch holds at least two messages, so that each half is smaller
+				log.Debugf("Splitting a batch of %d logs rejected as too large by %v", len(contents), s.destinations.Main.Address())
+				half := len(batch) / 2
+				s.sendKept(batch[:half], dropped[:half])
+				s.sendKept(batch[half:], dropped[half:])
+				return
+			}
+			if isPermanentError(err) || err == context.Canceled {
+				// the messages can not be framed properly, were rejected by the intake, the destination gave up or the context was cancelled,
+				// agent is stopping non-gracefully, drop the messages
+				dropAll(dropped, lifecycle.DropUndeliverable)
+				break
+			}
+			// retry as the error can be related to network issues
+			continue
+		}
+		for i, payload := range batch {
+			if dropped[i] == "" {
+				s.sendToAdditionals(payload)
+			}
+		}
+		break
+	}
+	for i, payload := range batch {
+		if dropped[i] != "" {
+			var dropErr error
+			if dropped[i] == lifecycle.DropCircuitOpen || dropped[i] == lifecycle.DropUndeliverable {
+				dropErr = err
+			}
+			s.drop(payload, dropped[i], dropErr)
+			continue
+		}
+		metrics.LogsSent.Add(1)
+		s.throughput.CountSent(len(payload.Content))
+		payload.Origin.LogSource.Throughput.CountSent(len(payload.Content))
+		lifecycle.Sent(payload, s.destinations.Main.Address())
+		s.outputChan <- payload
+	}
+}
+
+// dropAll drops for reason the messages which are not dropped yet.
+func dropAll(dropped []string, reason string) {
+	for i := range dropped {
+		if dropped[i] == "" {
+			dropped[i] = reason
+		}
+	}
+}
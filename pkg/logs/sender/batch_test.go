@@ -0,0 +1,228 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+type BatchTestSuite struct {
+	suite.Suite
+	listener        net.Listener
+	received        chan string
+	destinationsCtx *client.DestinationsContext
+	source          *config.LogSource
+	input           chan *message.Message
+	output          chan *message.Message
+}
+
+func (suite *BatchTestSuite) SetupTest() {
+	var err error
+	suite.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	suite.Nil(err)
+	suite.received = make(chan string, 10)
+	go func() {
+		conn, err := suite.listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buffer := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				return
+			}
+			suite.received <- string(buffer[:n])
+		}
+	}()
+	suite.destinationsCtx = client.NewDestinationsContext(nil)
+	suite.destinationsCtx.Start()
+	suite.source = config.NewLogSource("", &config.LogsConfig{})
+	suite.input = make(chan *message.Message, 10)
+	suite.output = make(chan *message.Message, 10)
+}
+
+func (suite *BatchTestSuite) TearDownTest() {
+	suite.destinationsCtx.Stop()
+	suite.listener.Close()
+}
+
+func (suite *BatchTestSuite) newSender(batch BatchStrategy) *Sender {
+	destination := client.AddrToDestination(suite.listener.Addr(), suite.destinationsCtx)
+	sender := NewSender(suite.input, suite.output, client.NewDestinations(destination, nil), 0, nil, batch)
+	sender.Start()
+	return sender
+}
+
+func (suite *BatchTestSuite) TestSenderSendsFullBatchesInASingleWrite() {
+	sender := suite.newSender(BatchStrategy{MaxCount: 2, MaxLinger: time.Hour})
+	foo := newMessage([]byte("foo"), suite.source, "")
+	bar := newMessage([]byte("bar"), suite.source, "")
+	suite.input <- foo
+	suite.input <- bar
+
+	suite.Equal(foo, <-suite.output)
+	suite.Equal(bar, <-suite.output)
+	suite.Equal(" foo\n bar\n", <-suite.received)
+	suite.Equal(metrics.Throughput{Sent: 2, BytesSent: 6}, sender.Throughput())
+	sender.Stop()
+}
+
+func (suite *BatchTestSuite) TestSenderSendsTheBatchesReachingTheMaxSize() {
+	sender := suite.newSender(BatchStrategy{MaxBytes: 6, MaxCount: 10, MaxLinger: time.Hour})
+	suite.input <- newMessage([]byte("foo"), suite.source, "")
+	suite.input <- newMessage([]byte("bar"), suite.source, "")
+
+	<-suite.output
+	<-suite.output
+	suite.Equal(" foo\n bar\n", <-suite.received)
+	sender.Stop()
+}
+
+func (suite *BatchTestSuite) TestSenderSendsThePartialBatchesAfterTheMaxLinger() {
+	sender := suite.newSender(BatchStrategy{MaxCount: 10, MaxLinger: 10 * time.Millisecond})
+	suite.input <- newMessage([]byte("foo"), suite.source, "")
+
+	<-suite.output
+	suite.Equal(" foo\n", <-suite.received)
+	sender.Stop()
+}
+
+func (suite *BatchTestSuite) TestSenderSendsThePartialBatchWhenStopping() {
+	sender := suite.newSender(BatchStrategy{MaxCount: 10, MaxLinger: time.Hour})
+	suite.input <- newMessage([]byte("foo"), suite.source, "")
+	suite.input <- newMessage([]byte("bar"), suite.source, "")
+
+	sender.Stop()
+	suite.Equal(2, len(suite.output))
+	suite.Equal(" foo\n bar\n", <-suite.received)
+}
+
+func (suite *BatchTestSuite) TestSenderSendsThePartialBatchOnFlush() {
+	sender := suite.newSender(BatchStrategy{MaxCount: 10, MaxLinger: time.Hour})
+	suite.input <- newMessage([]byte("foo"), suite.source, "")
+	suite.input <- newMessage([]byte("bar"), suite.source, "")
+
+	suite.Nil(sender.Flush(context.Background()))
+	suite.Equal(2, len(suite.output))
+	suite.Equal(" foo\n bar\n", <-suite.received)
+
+	// the sender keeps sending after a flush
+	suite.input <- newMessage([]byte("baz"), suite.source, "")
+	sender.Stop()
+	suite.Equal(3, len(suite.output))
+	suite.Equal(" baz\n", <-suite.received)
+}
+
+func (suite *BatchTestSuite) TestSenderCountsTheMessagesOfThePartialBatch() {
+	sender := suite.newSender(BatchStrategy{MaxCount: 10, MaxLinger: time.Hour})
+	foo := newMessage([]byte("foo"), suite.source, "")
+	foo.EnqueuedAt = time.Now().Add(-time.Minute)
+	bar := newMessage([]byte("bar"), suite.source, "")
+	bar.EnqueuedAt = time.Now()
+	suite.input <- foo
+	suite.input <- bar
+
+	for sender.Backlog().Buffered < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	backlog := sender.Backlog()
+	suite.Equal(int64(2), backlog.Buffered)
+	suite.True(foo.EnqueuedAt.Equal(backlog.Oldest))
+
+	// the messages are not counted anymore once sent
+	suite.Nil(sender.Flush(context.Background()))
+	suite.Equal(metrics.Backlog{}, sender.Backlog())
+	suite.Equal(" foo\n bar\n", <-suite.received)
+	sender.Stop()
+	suite.Equal(2, len(suite.output))
+}
+
+func (suite *BatchTestSuite) TestSenderDropsTheExpiredMessagesOfTheBatches() {
+	destination := client.AddrToDestination(suite.listener.Addr(), suite.destinationsCtx)
+	sender := NewSender(suite.input, suite.output, client.NewDestinations(destination, nil), time.Hour, nil, BatchStrategy{MaxCount: 2, MaxLinger: time.Hour})
+	sender.Start()
+	expired := newMessageWithTimestamp(suite.source, time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339Nano))
+	foo := newMessage([]byte("foo"), suite.source, "")
+	suite.input <- expired
+	suite.input <- foo
+
+	// the messages are committed in order
+	suite.Equal(expired, <-suite.output)
+	suite.Equal(foo, <-suite.output)
+	suite.Equal(" foo\n", <-suite.received)
+	suite.Equal(metrics.Throughput{Sent: 1, BytesSent: 3, Dropped: 1}, sender.Throughput())
+	sender.Stop()
+}
+
+// tooLargeDestination rejects the batches of more than maxCount messages as too large
+// and records the ones it accepts.
+type tooLargeDestination struct {
+	maxCount int
+	batches  [][]string
+}
+
+func (d *tooLargeDestination) Send(payload []byte) error {
+	return d.SendBatch([][]byte{payload})
+}
+
+func (d *tooLargeDestination) SendBatch(payloads [][]byte) error {
+	if len(payloads) > d.maxCount {
+		return &client.TooLargeError{Count: len(payloads)}
+	}
+	var batch []string
+	for _, payload := range payloads {
+		batch = append(batch, string(payload))
+	}
+	d.batches = append(d.batches, batch)
+	return nil
+}
+
+func (d *tooLargeDestination) Address() string             { return "too-large" }
+func (d *tooLargeDestination) BackoffDelay() time.Duration { return 0 }
+func (d *tooLargeDestination) LastSuccess() time.Time      { return time.Time{} }
+
+func (suite *BatchTestSuite) TestSenderSplitsTheBatchesRejectedAsTooLarge() {
+	destination := &tooLargeDestination{maxCount: 1}
+	sender := NewSender(suite.input, suite.output, client.NewDestinations(destination, nil), 0, nil, BatchStrategy{MaxCount: 3, MaxLinger: time.Hour})
+	sender.Start()
+	var batch []*message.Message
+	for _, content := range []string{"foo", "bar", "baz"} {
+		msg := newMessage([]byte(content), suite.source, "")
+		batch = append(batch, msg)
+		suite.input <- msg
+	}
+
+	// the messages are sent and committed in order
+	for _, msg := range batch {
+		suite.Equal(msg, <-suite.output)
+	}
+	suite.Equal([][]string{{"foo"}, {"bar"}, {"baz"}}, destination.batches)
+	suite.Equal(metrics.Throughput{Sent: 3, BytesSent: 9}, sender.Throughput())
+	sender.Stop()
+}
+
+func TestBatchTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
+
+func TestBatchStrategyIsEnabled(t *testing.T) {
+	assert.False(t, BatchStrategy{}.isEnabled())
+	assert.False(t, BatchStrategy{MaxCount: 1, MaxBytes: 1000}.isEnabled())
+	assert.True(t, BatchStrategy{MaxCount: 2}.isEnabled())
+}
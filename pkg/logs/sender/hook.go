@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// Policies applied when the pre-send hook fails with an error other than ErrRejected.
+const (
+	// DropOnHookError drops the message.
+	DropOnHookError = "drop"
+	// SendOnHookError sends the message as it was before the hook was called.
+	SendOnHookError = "send"
+)
+
+// ErrRejected is returned by a PreSendHook to drop a message.
+var ErrRejected = errors.New("rejected by the pre-send hook")
+
+// PreSendHook is called with the encoded content of each message and its origin right before it is sent,
+// it returns the content to send, which can be the given one, or ErrRejected to drop the message.
+// The content is the output of the encoder, the API key prefix and the frame delimiter are added
+// by the destination after the hook is called, the logs are never compressed.
+type PreSendHook func(content []byte, origin *message.Origin) ([]byte, error)
+
+var (
+	preSendHook      PreSendHook
+	preSendHookMutex sync.Mutex
+)
+
+// RegisterPreSendHook registers the hook called on every message right before it is sent,
+// it must be called before the logs agent is started.
+func RegisterPreSendHook(hook PreSendHook) {
+	preSendHookMutex.Lock()
+	defer preSendHookMutex.Unlock()
+	preSendHook = hook
+}
+
+// Hook applies the registered pre-send hook to the messages.
+type Hook struct {
+	preSend     PreSendHook
+	errorPolicy string
+}
+
+// NewHook returns a hook applying the registered pre-send hook with errorPolicy on failures,
+// returns nil when no hook is registered.
+func NewHook(errorPolicy string) *Hook {
+	preSendHookMutex.Lock()
+	defer preSendHookMutex.Unlock()
+	if preSendHook == nil {
+		return nil
+	}
+	if errorPolicy != DropOnHookError && errorPolicy != SendOnHookError {
+		log.Warnf("Invalid pre-send hook error policy: %v, defaulting to %v", errorPolicy, DropOnHookError)
+		errorPolicy = DropOnHookError
+	}
+	return &Hook{
+		preSend:     preSendHook,
+		errorPolicy: errorPolicy,
+	}
+}
+
+// apply replaces the content of payload with the one returned by the pre-send hook,
+// returns false if the message must not be sent.
+func (h *Hook) apply(payload *message.Message) bool {
+	if h == nil {
+		return true
+	}
+	content, err := h.preSend(payload.Content, payload.Origin)
+	switch {
+	case err == ErrRejected:
+		log.Debug("Message rejected by the pre-send hook")
+		metrics.LogsRejected.Add(1)
+		return false
+	case err != nil:
+		log.Warnf("Pre-send hook failed: %v", err)
+		if h.errorPolicy == SendOnHookError {
+			return true
+		}
+		metrics.LogsRejected.Add(1)
+		return false
+	}
+	payload.Content = content
+	return true
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+func upperCaseHook(content []byte, origin *message.Origin) ([]byte, error) {
+	return bytes.ToUpper(content), nil
+}
+
+func TestNewHook(t *testing.T) {
+	defer RegisterPreSendHook(nil)
+
+	assert.Nil(t, NewHook(DropOnHookError))
+
+	RegisterPreSendHook(upperCaseHook)
+	assert.Equal(t, SendOnHookError, NewHook(SendOnHookError).errorPolicy)
+	assert.Equal(t, DropOnHookError, NewHook("foo").errorPolicy)
+}
+
+func TestHookModifiesContent(t *testing.T) {
+	hook := &Hook{preSend: upperCaseHook, errorPolicy: DropOnHookError}
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	assert.True(t, hook.apply(msg))
+	assert.Equal(t, "FAKE LINE", string(msg.Content))
+
+	var nilHook *Hook
+	assert.True(t, nilHook.apply(msg))
+	assert.Equal(t, "FAKE LINE", string(msg.Content))
+}
+
+func TestHookReceivesOrigin(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{Service: "compliant"})
+	hook := &Hook{
+		preSend: func(content []byte, origin *message.Origin) ([]byte, error) {
+			if origin.LogSource.Config.Service != "compliant" {
+				return nil, ErrRejected
+			}
+			return content, nil
+		},
+		errorPolicy: SendOnHookError,
+	}
+	assert.True(t, hook.apply(newMessage([]byte("fake line"), source, "")))
+	assert.False(t, hook.apply(newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")))
+}
+
+func TestHookErrorPolicies(t *testing.T) {
+	failingHook := func(content []byte, origin *message.Origin) ([]byte, error) {
+		return nil, errors.New("gateway unavailable")
+	}
+	source := config.NewLogSource("", &config.LogsConfig{})
+	rejected := metrics.LogsRejected.Value()
+
+	hook := &Hook{preSend: failingHook, errorPolicy: DropOnHookError}
+	assert.False(t, hook.apply(newMessage([]byte("fake line"), source, "")))
+	assert.Equal(t, rejected+1, metrics.LogsRejected.Value())
+
+	hook = &Hook{preSend: failingHook, errorPolicy: SendOnHookError}
+	msg := newMessage([]byte("fake line"), source, "")
+	assert.True(t, hook.apply(msg))
+	assert.Equal(t, "fake line", string(msg.Content))
+	assert.Equal(t, rejected+1, metrics.LogsRejected.Value())
+}
@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"context"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/lifecycle"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
+)
+
+// MessageDestination delivers messages, Send blocks until the message is delivered.
+type MessageDestination interface {
+	Send(msg *message.Message) error
+}
+
+// MessageSender is responsible for publishing logs to a message broker, an AMQP exchange or a Kafka topic.
+type MessageSender struct {
+	inputChan     chan *message.Message
+	outputChan    chan *message.Message
+	destination   MessageDestination
+	maxMessageAge time.Duration
+	hook          *Hook
+	throughput    *metrics.ThroughputCounter
+	backlog       *metrics.BacklogCounter
+	flush         restart.FlushRequests
+	done          chan struct{}
+}
+
+// NewMessageSender returns a new message sender,
+// the messages older than maxMessageAge are dropped, unless their source overrides it, 0 means no limit,
+// hook is applied to the messages right before they are published when not nil.
+func NewMessageSender(inputChan, outputChan chan *message.Message, destination MessageDestination, maxMessageAge time.Duration, hook *Hook) *MessageSender {
+	return &MessageSender{
+		inputChan:     inputChan,
+		outputChan:    outputChan,
+		destination:   destination,
+		maxMessageAge: maxMessageAge,
+		hook:          hook,
+		throughput:    metrics.NewThroughputCounter(),
+		backlog:       metrics.NewBacklogCounter(),
+		flush:         restart.NewFlushRequests(),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start starts the MessageSender
+func (s *MessageSender) Start() {
+	go s.run()
+}
+
+// Stop stops the MessageSender,
+// this call blocks until inputChan is flushed
+func (s *MessageSender) Stop() {
+	close(s.inputChan)
+	<-s.done
+}
+
+// Flush blocks until the messages of inputChan when it is called are published or dropped,
+// without stopping the MessageSender, returns an error if ctx is done first.
+func (s *MessageSender) Flush(ctx context.Context) error {
+	return s.flush.Request(ctx)
+}
+
+// run lets the sender publish messages.
+func (s *MessageSender) run() {
+	defer func() {
+		s.done <- struct{}{}
+	}()
+	for {
+		select {
+		case payload, isOpen := <-s.inputChan:
+			if !isOpen {
+				return
+			}
+			s.send(payload)
+		case done := <-s.flush:
+			for i := len(s.inputChan); i > 0; i-- {
+				s.send(<-s.inputChan)
+			}
+			close(done)
+		}
+	}
+}
+
+// send keeps trying to publish the message until the broker confirms it, refuses it or the message expires,
+// the message is then forwarded to outputChan to commit its offset.
+func (s *MessageSender) send(payload *message.Message) {
+	s.backlog.Hold(payload.EnqueuedAt)
+	defer s.backlog.ReleaseAll()
+	if !s.hook.apply(payload) {
+		s.drop(payload, lifecycle.DropRejected, nil)
+		return
+	}
+	for {
+		if isOlderThanMaxAge(payload, s.maxMessageAge) {
+			metrics.LogsExpired.Add(1)
+			// the message is too old to be useful,
+			// drop the message
+			s.drop(payload, lifecycle.DropExpired, nil)
+			return
+		}
+		err := s.destination.Send(payload)
+		if err != nil {
+			metrics.DestinationErrors.Add(1)
+			if isPermanentError(err) || err == context.Canceled {
+				// the broker refused the message or the context was cancelled, agent is stopping non-gracefully,
+				// drop the message
+				s.drop(payload, lifecycle.DropUndeliverable, err)
+				return
+			}
+			// retry as the error can be related to network issues or to the broker,
+			// the destination backs off before publishing again
+			continue
+		}
+		metrics.LogsSent.Add(1)
+		s.throughput.CountSent(len(payload.Content))
+		payload.Origin.LogSource.Throughput.CountSent(len(payload.Content))
+		lifecycle.Sent(payload, "")
+		break
+	}
+	s.outputChan <- payload
+}
+
+// drop forwards a message which is not published for reason to outputChan to commit its offset.
+func (s *MessageSender) drop(payload *message.Message, reason string, err error) {
+	lifecycle.Dropped(payload, reason, err)
+	s.throughput.CountDropped()
+	payload.Origin.LogSource.Throughput.CountDropped()
+	s.outputChan <- payload
+}
+
+// Throughput returns the logs published and dropped so far.
+func (s *MessageSender) Throughput() metrics.Throughput {
+	return s.throughput.Value()
+}
+
+// Backlog returns the number of logs being published and when the oldest of them entered the pipeline.
+func (s *MessageSender) Backlog() metrics.Backlog {
+	return s.backlog.Value()
+}
@@ -0,0 +1,170 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// stubDestination fails to send the messages with the errors in order.
+type stubDestination struct {
+	errors []error
+	sent   []*message.Message
+}
+
+func (d *stubDestination) Send(msg *message.Message) error {
+	if len(d.errors) > 0 {
+		err := d.errors[0]
+		d.errors = d.errors[1:]
+		return err
+	}
+	d.sent = append(d.sent, msg)
+	return nil
+}
+
+// blockingDestination notifies the messages being sent and confirms them once released.
+type blockingDestination struct {
+	sending chan *message.Message
+	release chan struct{}
+}
+
+func (d *blockingDestination) Send(msg *message.Message) error {
+	d.sending <- msg
+	<-d.release
+	return nil
+}
+
+func newTestMessageSender(destination MessageDestination) (*MessageSender, chan *message.Message, chan *message.Message) {
+	input := make(chan *message.Message, 1)
+	output := make(chan *message.Message, 1)
+	return NewMessageSender(input, output, destination, 0, nil), input, output
+}
+
+func TestMessageSenderRetriesUntilConfirmed(t *testing.T) {
+	destination := &stubDestination{errors: []error{errors.New("nack"), errors.New("channel closed")}}
+	sender, input, output := newTestMessageSender(destination)
+	sender.Start()
+	defer sender.Stop()
+
+	sent := metrics.LogsSent.Value()
+	destinationErrors := metrics.DestinationErrors.Value()
+
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	input <- msg
+	assert.Equal(t, msg, <-output)
+
+	assert.Equal(t, []*message.Message{msg}, destination.sent)
+	assert.Equal(t, sent+1, metrics.LogsSent.Value())
+	assert.Equal(t, destinationErrors+2, metrics.DestinationErrors.Value())
+}
+
+func TestMessageSenderDropsMessageWhenContextIsCancelled(t *testing.T) {
+	destination := &stubDestination{errors: []error{context.Canceled}}
+	sender, input, output := newTestMessageSender(destination)
+	sender.Start()
+	defer sender.Stop()
+
+	sent := metrics.LogsSent.Value()
+
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	input <- msg
+	assert.Equal(t, msg, <-output)
+
+	assert.Equal(t, 0, len(destination.sent))
+	assert.Equal(t, sent, metrics.LogsSent.Value())
+}
+
+func TestMessageSenderDropsMessageRefusedByTheBroker(t *testing.T) {
+	destination := &stubDestination{errors: []error{client.NewRefusedError(errors.New("message too large"))}}
+	sender, input, output := newTestMessageSender(destination)
+	sender.Start()
+	defer sender.Stop()
+
+	sent := metrics.LogsSent.Value()
+
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	input <- msg
+	// the message is still forwarded to commit its offset
+	assert.Equal(t, msg, <-output)
+
+	assert.Empty(t, destination.sent)
+	assert.Equal(t, sent, metrics.LogsSent.Value())
+}
+
+func TestMessageSenderDropsExpiredMessages(t *testing.T) {
+	destination := &stubDestination{errors: []error{errors.New("nack")}}
+	sender, input, output := newTestMessageSender(destination)
+	sender.maxMessageAge = time.Hour
+	sender.Start()
+	defer sender.Stop()
+
+	expired := metrics.LogsExpired.Value()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessageWithTimestamp(source, time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339Nano))
+	input <- msg
+	assert.Equal(t, msg, <-output)
+
+	msg = newMessageWithTimestamp(source, time.Now().UTC().Format(time.RFC3339Nano))
+	input <- msg
+	assert.Equal(t, msg, <-output)
+
+	assert.Equal(t, []*message.Message{msg}, destination.sent)
+	assert.Equal(t, expired+1, metrics.LogsExpired.Value())
+}
+
+func TestMessageSenderDropsMessagesRejectedByHook(t *testing.T) {
+	destination := &stubDestination{}
+	sender, input, output := newTestMessageSender(destination)
+	sender.hook = &Hook{
+		preSend: func(content []byte, origin *message.Origin) ([]byte, error) {
+			return nil, ErrRejected
+		},
+		errorPolicy: SendOnHookError,
+	}
+	sender.Start()
+	defer sender.Stop()
+
+	rejected := metrics.LogsRejected.Value()
+
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	input <- msg
+	// the message is still forwarded to commit its offset
+	assert.Equal(t, msg, <-output)
+
+	assert.Empty(t, destination.sent)
+	assert.Equal(t, rejected+1, metrics.LogsRejected.Value())
+}
+
+func TestMessageSenderCountsTheMessageBeingPublished(t *testing.T) {
+	destination := &blockingDestination{sending: make(chan *message.Message), release: make(chan struct{})}
+	sender, input, output := newTestMessageSender(destination)
+	sender.Start()
+
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	msg.EnqueuedAt = time.Now().Add(-time.Minute)
+	input <- msg
+	<-destination.sending
+	backlog := sender.Backlog()
+	assert.Equal(t, int64(1), backlog.Buffered)
+	assert.True(t, msg.EnqueuedAt.Equal(backlog.Oldest))
+
+	close(destination.release)
+	assert.Equal(t, msg, <-output)
+	// the message is not counted anymore once published
+	sender.Stop()
+	assert.Equal(t, metrics.Backlog{}, sender.Backlog())
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// route selects the logs sent to an additional destination, the ones with one of its statuses
+// or whose content matches its pattern, a nil route selects all the logs.
+// The parts of a message split by the processor are selected together, by the first one.
+type route struct {
+	statuses map[string]bool
+	pattern  *regexp.Regexp
+	// chunkGroup is the group of the last parts of a message seen, chunkMatches whether they are selected
+	chunkGroup   string
+	chunkMatches bool
+}
+
+// newRoute returns the route of the logs selected by config, nil if it selects all the logs.
+func newRoute(config *client.Route) *route {
+	if config == nil || (len(config.Statuses) == 0 && config.Pattern == "") {
+		return nil
+	}
+	r := &route{statuses: make(map[string]bool)}
+	for _, status := range config.Statuses {
+		r.statuses[strings.ToLower(status)] = true
+	}
+	if config.Pattern != "" {
+		reg, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			// the patterns are checked when the endpoints are built
+			log.Warnf("Invalid route pattern %v, the logs are routed by status only: %v", config.Pattern, err)
+		}
+		r.pattern = reg
+	}
+	return r
+}
+
+// matches returns true if payload must be sent to the destination of the route.
+func (r *route) matches(payload *message.Message) bool {
+	if r == nil {
+		return true
+	}
+	chunk := payload.Chunk
+	if chunk != nil && chunk.Index > 0 && chunk.Group == r.chunkGroup {
+		return r.chunkMatches
+	}
+	matches := r.statuses[payload.GetStatus()] || (r.pattern != nil && r.pattern.Match(payload.Content))
+	if chunk != nil {
+		r.chunkGroup, r.chunkMatches = chunk.Group, matches
+	}
+	return matches
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package sender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestRouteMatchesTheStatusesOrThePattern(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	r := newRoute(&client.Route{Statuses: []string{"ERROR", "critical"}, Pattern: "incident-[0-9]+"})
+
+	assert.True(t, r.matches(newMessage([]byte("disk full"), source, message.StatusError)))
+	assert.True(t, r.matches(newMessage([]byte("disk full"), source, message.StatusCritical)))
+	assert.True(t, r.matches(newMessage([]byte("opened incident-42"), source, message.StatusInfo)))
+	assert.False(t, r.matches(newMessage([]byte("disk full"), source, message.StatusWarning)))
+	assert.False(t, r.matches(newMessage([]byte("disk full"), source, "")))
+}
+
+func TestRouteMatchesThePartsOfAMessageTogether(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	r := newRoute(&client.Route{Pattern: "Exception"})
+	newChunk := func(content string, group string, index int) *message.Message {
+		msg := newMessage([]byte(content), source, message.StatusInfo)
+		msg.Chunk = &message.Chunk{Group: group, Index: index, Count: 2}
+		return msg
+	}
+
+	assert.True(t, r.matches(newChunk("Exception", "a", 0)))
+	assert.True(t, r.matches(newChunk("at main", "a", 1)))
+	assert.False(t, r.matches(newChunk("hello", "b", 0)))
+	assert.False(t, r.matches(newChunk("Exception", "b", 1)))
+	// the first part of a message decides even when the previous parts were not seen
+	assert.True(t, r.matches(newChunk("Exception", "c", 1)))
+}
+
+func TestNilRouteMatchesAllTheLogs(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	assert.Nil(t, newRoute(&client.Route{}))
+	r := newRoute(nil)
+	assert.Nil(t, r)
+	assert.True(t, r.matches(newMessage([]byte("disk full"), source, message.StatusDebug)))
+}
+
+func TestSenderOnlySendsTheRoutedLogsToTheAdditionalDestinations(t *testing.T) {
+	destinationsCtx := client.NewDestinationsContext(nil)
+	all := newAdditionalSender(newUnreachableDestination(t, destinationsCtx, ""))
+	routed := newAdditionalSender(client.NewDestination(client.Endpoint{Host: "localhost", Port: 1, Route: &client.Route{Statuses: []string{message.StatusError}}}, destinationsCtx))
+	s := &Sender{additionals: []*additionalSender{all, routed}}
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	s.sendToAdditionals(newMessage([]byte("request served"), source, message.StatusInfo))
+	s.sendToAdditionals(newMessage([]byte("request failed"), source, message.StatusError))
+
+	// the additional senders are not started, the logs stay in their buffer
+	assert.Len(t, all.inputChan, 2)
+	assert.Len(t, routed.inputChan, 1)
+	assert.Equal(t, "request failed", string((<-routed.inputChan).Content))
+}
+
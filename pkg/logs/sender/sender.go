@@ -7,40 +7,83 @@ package sender
 
 import (
 	"context"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/lifecycle"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/restart"
 )
 
 // Sender is responsible for sending logs to different destinations.
 type Sender struct {
-	inputChan    chan *message.Message
-	outputChan   chan *message.Message
-	destinations *client.Destinations
-	done         chan struct{}
+	inputChan     chan *message.Message
+	outputChan    chan *message.Message
+	destinations  *client.Destinations
+	additionals   []*additionalSender
+	throughput    *metrics.ThroughputCounter
+	backlog       *metrics.BacklogCounter
+	maxMessageAge time.Duration
+	hook          *Hook
+	batch         BatchStrategy
+	flush         restart.FlushRequests
+	done          chan struct{}
 }
 
-// NewSender returns an new sender.
-func NewSender(inputChan, outputChan chan *message.Message, destinations *client.Destinations) *Sender {
+// NewSender returns an new sender,
+// the messages older than maxMessageAge are dropped, unless their source overrides it, 0 means no limit,
+// hook is applied to the messages right before they are sent when not nil,
+// the messages are sent to the main destination by batches following batch.
+// The messages are sent to each additional destination from a goroutine of its own,
+// only the messages matching the route of an additional destination are sent to it.
+func NewSender(inputChan, outputChan chan *message.Message, destinations *client.Destinations, maxMessageAge time.Duration, hook *Hook, batch BatchStrategy) *Sender {
+	var additionals []*additionalSender
+	if destinations != nil {
+		for _, destination := range destinations.Additionals {
+			additionals = append(additionals, newAdditionalSender(destination))
+		}
+	}
 	return &Sender{
-		inputChan:    inputChan,
-		outputChan:   outputChan,
-		destinations: destinations,
-		done:         make(chan struct{}),
+		inputChan:     inputChan,
+		outputChan:    outputChan,
+		destinations:  destinations,
+		additionals:   additionals,
+		throughput:    metrics.NewThroughputCounter(),
+		backlog:       metrics.NewBacklogCounter(),
+		maxMessageAge: maxMessageAge,
+		hook:          hook,
+		batch:         batch,
+		flush:         restart.NewFlushRequests(),
+		done:          make(chan struct{}),
 	}
 }
 
 // Start starts the Sender
 func (s *Sender) Start() {
+	for _, additional := range s.additionals {
+		additional.start()
+	}
 	go s.run()
 }
 
 // Stop stops the Sender,
-// this call blocks until inputChan is flushed
+// this call blocks until inputChan and the buffers of the additional destinations are flushed
 func (s *Sender) Stop() {
 	close(s.inputChan)
 	<-s.done
+	for _, additional := range s.additionals {
+		additional.stop()
+	}
+}
+
+// Flush blocks until the messages of inputChan when it is called are sent to the main destination or dropped,
+// without stopping the Sender, returns an error if ctx is done first.
+// The messages are not waited for to be sent to the additional destinations.
+func (s *Sender) Flush(ctx context.Context) error {
+	return s.flush.Request(ctx)
 }
 
 // run lets the sender send messages.
@@ -48,47 +91,136 @@ func (s *Sender) run() {
 	defer func() {
 		s.done <- struct{}{}
 	}()
-	for payload := range s.inputChan {
-		s.send(payload)
+	if s.batch.isEnabled() {
+		s.runBatches()
+		return
+	}
+	for {
+		select {
+		case payload, isOpen := <-s.inputChan:
+			if !isOpen {
+				return
+			}
+			s.send(payload)
+		case done := <-s.flush:
+			for i := len(s.inputChan); i > 0; i-- {
+				s.send(<-s.inputChan)
+			}
+			close(done)
+		}
 	}
 }
 
 // send keeps trying to send the message to the main destination until it succeeds
-// and try to send the message to the additional destinations only once.
+// and hands the message over to the additional destinations.
 func (s *Sender) send(payload *message.Message) {
+	s.backlog.Hold(payload.EnqueuedAt)
+	defer s.backlog.ReleaseAll()
+	if !s.hook.apply(payload) {
+		s.drop(payload, lifecycle.DropRejected, nil)
+		return
+	}
 	for {
+		if s.isExpired(payload) {
+			metrics.LogsExpired.Add(1)
+			// the message is too old to be useful,
+			// drop the message
+			s.drop(payload, lifecycle.DropExpired, nil)
+			return
+		}
 		// this call is blocking until payload is sent (or the connection destination context cancelled)
 		err := s.destinations.Main.Send(payload.Content)
+		if err == client.ErrCircuitOpen {
+			// the main destination keeps failing, drop the message until it is probed again
+			metrics.CircuitBreakerDrops.Add(s.destinations.Main.Address(), 1)
+			s.drop(payload, lifecycle.DropCircuitOpen, err)
+			return
+		}
 		if err != nil {
-			if err == context.Canceled {
-				metrics.DestinationErrors.Add(1)
-				// the context was cancelled, agent is stopping non-gracefully.
-				// drop the message
-				break
+			metrics.DestinationErrors.Add(1)
+			if isPermanentError(err) || err == context.Canceled {
+				// the message can not be framed properly, was rejected by the intake, the destination gave up or the context was cancelled,
+				// agent is stopping non-gracefully, drop the message
+				s.drop(payload, lifecycle.DropUndeliverable, err)
+				return
 			}
-			switch err.(type) {
-			case *client.FramingError:
-				metrics.DestinationErrors.Add(1)
-				// the message can not be framed properly,
-				// drop the message
-				break
-			default:
-				metrics.DestinationErrors.Add(1)
-				// retry as the error can be related to network issues
-				continue
-			}
-		}
-		for _, destination := range s.destinations.Additionals {
-			// try and forget strategy for additional endpoints
-			// this call is also blocking when the connection is not established yet
-			// FIXME: run all `Send` in parallel to avoid the effect on a slow
-			// destination on the others. Potentially add a buffer for secondary
-			// destinations.
-			destination.Send(payload.Content)
+			// retry as the error can be related to network issues
+			continue
 		}
+		s.sendToAdditionals(payload)
 
 		metrics.LogsSent.Add(1)
+		s.throughput.CountSent(len(payload.Content))
+		payload.Origin.LogSource.Throughput.CountSent(len(payload.Content))
+		lifecycle.Sent(payload, s.destinations.Main.Address())
 		break
 	}
 	s.outputChan <- payload
 }
+
+// sendToAdditionals hands payload over to the additional destinations whose route it matches.
+func (s *Sender) sendToAdditionals(payload *message.Message) {
+	for _, additional := range s.additionals {
+		if additional.route.matches(payload) {
+			additional.send(payload)
+		}
+	}
+}
+
+// isPermanentError returns true if err can not be recovered from by sending the same logs again,
+// which is also the case once the main destination gave up reconnecting.
+// The errors of the message destinations are permanent when the broker refused the message.
+func isPermanentError(err error) bool {
+	if err == client.ErrReconnectAttemptsExhausted {
+		return true
+	}
+	switch err.(type) {
+	case *client.FramingError, *client.RejectedError, *client.RefusedError:
+		return true
+	default:
+		return false
+	}
+}
+
+// drop forwards a message which is not sent for reason to outputChan to commit its offset.
+func (s *Sender) drop(payload *message.Message, reason string, err error) {
+	lifecycle.Dropped(payload, reason, err)
+	s.throughput.CountDropped()
+	payload.Origin.LogSource.Throughput.CountDropped()
+	s.outputChan <- payload
+}
+
+// Throughput returns the logs sent and dropped so far.
+func (s *Sender) Throughput() metrics.Throughput {
+	return s.throughput.Value()
+}
+
+// Backlog returns the number of logs being sent and when the oldest of them entered the pipeline.
+func (s *Sender) Backlog() metrics.Backlog {
+	return s.backlog.Value()
+}
+
+// isExpired returns true if the message is older than the maximum age of its source.
+func (s *Sender) isExpired(payload *message.Message) bool {
+	return isOlderThanMaxAge(payload, s.maxMessageAge)
+}
+
+// isOlderThanMaxAge returns true if the message is older than the maximum age of its source,
+// maxMessageAge when its source does not override it, 0 means no limit.
+// The age can only be computed for the messages with a timestamp: the timestamp of the container runtime
+// for the logs collected from containers, the one of the syslog header for the syslog sources,
+// and the one set by the json and parse_timestamp processing rules, the other messages are never dropped.
+func isOlderThanMaxAge(payload *message.Message, maxMessageAge time.Duration) bool {
+	if sourceMaxMessageAge := payload.Origin.LogSource.Config.MaxMessageAge; sourceMaxMessageAge > 0 {
+		maxMessageAge = time.Duration(sourceMaxMessageAge) * time.Second
+	}
+	if maxMessageAge <= 0 || payload.Timestamp == "" {
+		return false
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, payload.Timestamp)
+	if err != nil {
+		log.Debugf("Could not parse the timestamp of the message: %v", err)
+		return false
+	}
+	return time.Since(timestamp) > maxMessageAge
+}
@@ -5,14 +5,21 @@
 package sender
 
 import (
-	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"expvar"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/testutil"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/lifecycle"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 )
 
 func newMessage(content []byte, source *config.LogSource, status string) *message.Message {
@@ -21,6 +28,12 @@ func newMessage(content []byte, source *config.LogSource, status string) *messag
 	return msg
 }
 
+func newMessageWithTimestamp(source *config.LogSource, timestamp string) *message.Message {
+	msg := newMessage([]byte("fake line"), source, "")
+	msg.Timestamp = timestamp
+	return msg
+}
+
 func TestSender(t *testing.T) {
 	l := mock.NewMockLogsIntake(t)
 	defer l.Close()
@@ -30,13 +43,13 @@ func TestSender(t *testing.T) {
 	input := make(chan *message.Message, 1)
 	output := make(chan *message.Message, 1)
 
-	destinationsCtx := client.NewDestinationsContext()
+	destinationsCtx := client.NewDestinationsContext(nil)
 	destinationsCtx.Start()
 
 	destination := client.AddrToDestination(l.Addr(), destinationsCtx)
 	destinations := client.NewDestinations(destination, nil)
 
-	sender := NewSender(input, output, destinations)
+	sender := NewSender(input, output, destinations, 0, nil, BatchStrategy{})
 	sender.Start()
 
 	expectedMessage := newMessage([]byte("fake line"), source, "")
@@ -47,7 +60,212 @@ func TestSender(t *testing.T) {
 
 	assert.True(t, ok)
 	assert.Equal(t, message, expectedMessage)
+	assert.Equal(t, metrics.Throughput{Sent: 1, BytesSent: 9}, sender.Throughput())
+	assert.Equal(t, metrics.Throughput{Sent: 1, BytesSent: 9}, source.Throughput.Value())
 
 	sender.Stop()
 	destinationsCtx.Stop()
 }
+
+func TestSenderDropsExpiredMessages(t *testing.T) {
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	input := make(chan *message.Message, 1)
+	output := make(chan *message.Message, 1)
+
+	destinationsCtx := client.NewDestinationsContext(nil)
+	destinationsCtx.Start()
+
+	destination := client.AddrToDestination(l.Addr(), destinationsCtx)
+	destinations := client.NewDestinations(destination, nil)
+
+	sender := NewSender(input, output, destinations, time.Hour, nil, BatchStrategy{})
+	sender.Start()
+
+	expired := metrics.LogsExpired.Value()
+	sent := metrics.LogsSent.Value()
+
+	msg := newMessage([]byte("fake line"), config.NewLogSource("", &config.LogsConfig{}), "")
+	msg.Timestamp = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)
+	input <- msg
+	assert.Equal(t, msg, <-output)
+	assert.Equal(t, expired+1, metrics.LogsExpired.Value())
+	assert.Equal(t, sent, metrics.LogsSent.Value())
+	assert.Equal(t, metrics.Throughput{Dropped: 1}, sender.Throughput())
+	assert.Equal(t, metrics.Throughput{Dropped: 1}, msg.Origin.LogSource.Throughput.Value())
+
+	sender.Stop()
+	destinationsCtx.Stop()
+}
+
+func TestSenderRetriesTheFailedSendsInOrder(t *testing.T) {
+	input := make(chan *message.Message, 2)
+	output := make(chan *message.Message, 2)
+
+	destination := testutil.NewDestination()
+	destination.FailNextSends(2, nil)
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), 0, nil, BatchStrategy{})
+	sender.Start()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	first, second := newMessage([]byte("foo"), source, ""), newMessage([]byte("bar"), source, "")
+	input <- first
+	input <- second
+	assert.Equal(t, first, <-output)
+	assert.Equal(t, second, <-output)
+	assert.Equal(t, [][]byte{[]byte("foo"), []byte("bar")}, destination.Payloads())
+	assert.Equal(t, 4, destination.Attempts())
+
+	sender.Stop()
+}
+
+func TestSenderDropsTheMessagesWhenTheCircuitIsOpen(t *testing.T) {
+	input := make(chan *message.Message, 2)
+	output := make(chan *message.Message, 2)
+
+	destination := testutil.NewDestination()
+	destination.FailNextSends(1, client.ErrCircuitOpen)
+	drops := circuitBreakerDrops(destination.Address())
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), 0, nil, BatchStrategy{})
+	sender.Start()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	first, second := newMessage([]byte("foo"), source, ""), newMessage([]byte("bar"), source, "")
+	input <- first
+	input <- second
+	// the dropped message is still committed
+	assert.Equal(t, first, <-output)
+	assert.Equal(t, second, <-output)
+	assert.Equal(t, [][]byte{[]byte("bar")}, destination.Payloads())
+	assert.Equal(t, 2, destination.Attempts())
+	assert.Equal(t, int64(1), sender.Throughput().Dropped)
+	assert.Equal(t, drops+1, circuitBreakerDrops(destination.Address()))
+
+	sender.Stop()
+}
+
+func TestSenderDropsTheMessagesRejectedByTheIntake(t *testing.T) {
+	input := make(chan *message.Message, 2)
+	output := make(chan *message.Message, 2)
+
+	destination := testutil.NewDestination()
+	destination.FailNextSends(1, &client.RejectedError{StatusCode: 400})
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), 0, nil, BatchStrategy{})
+	sender.Start()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	first, second := newMessage([]byte("foo"), source, ""), newMessage([]byte("bar"), source, "")
+	input <- first
+	input <- second
+	// the rejected message is not retried but still committed
+	assert.Equal(t, first, <-output)
+	assert.Equal(t, second, <-output)
+	assert.Equal(t, [][]byte{[]byte("bar")}, destination.Payloads())
+	assert.Equal(t, 2, destination.Attempts())
+	assert.Equal(t, int64(1), sender.Throughput().Dropped)
+
+	sender.Stop()
+}
+
+func TestSenderDropsTheMessagesOnceTheDestinationGaveUp(t *testing.T) {
+	input := make(chan *message.Message, 2)
+	output := make(chan *message.Message, 2)
+
+	destination := testutil.NewDestination()
+	destination.FailNextSends(2, client.ErrReconnectAttemptsExhausted)
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), 0, nil, BatchStrategy{})
+	sender.Start()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	first, second := newMessage([]byte("foo"), source, ""), newMessage([]byte("bar"), source, "")
+	input <- first
+	input <- second
+	// the messages are not retried but still committed
+	assert.Equal(t, first, <-output)
+	assert.Equal(t, second, <-output)
+	assert.Empty(t, destination.Payloads())
+	assert.Equal(t, 2, destination.Attempts())
+	assert.Equal(t, int64(2), sender.Throughput().Dropped)
+
+	sender.Stop()
+}
+
+// lifecycleHook forwards the names and the outcomes of the events of the messages starting with prefix to events.
+type lifecycleHook struct {
+	prefix string
+	events chan string
+}
+
+func (h *lifecycleHook) notify(kind string, msg *message.Message, outcome lifecycle.Outcome) {
+	if strings.HasPrefix(string(msg.Content), h.prefix) {
+		h.events <- fmt.Sprintf("%s %s %s%s", kind, msg.Content, outcome.Reason, outcome.Destination)
+	}
+}
+
+func (h *lifecycleHook) OnProcessed(msg *message.Message, outcome lifecycle.Outcome) {
+	h.notify("processed", msg, outcome)
+}
+
+func (h *lifecycleHook) OnSent(msg *message.Message, outcome lifecycle.Outcome) {
+	h.notify("sent", msg, outcome)
+}
+
+func (h *lifecycleHook) OnDropped(msg *message.Message, outcome lifecycle.Outcome) {
+	h.notify("dropped", msg, outcome)
+}
+
+func TestSenderNotifiesTheLifecycleHooks(t *testing.T) {
+	hook := &lifecycleHook{prefix: "lifecycle", events: make(chan string, 10)}
+	lifecycle.RegisterHook(hook)
+
+	for _, batch := range []BatchStrategy{{}, {MaxCount: 10}} {
+		input := make(chan *message.Message, 2)
+		output := make(chan *message.Message, 2)
+		destination := testutil.NewDestination()
+		sender := NewSender(input, output, client.NewDestinations(destination, nil), time.Hour, nil, batch)
+		sender.Start()
+
+		source := config.NewLogSource("", &config.LogsConfig{})
+		expired := newMessage([]byte("lifecycle expired"), source, "")
+		expired.Timestamp = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)
+		input <- expired
+		input <- newMessage([]byte("lifecycle sent"), source, "")
+		<-output
+		<-output
+		assert.Equal(t, "dropped lifecycle expired expired", <-hook.events)
+		assert.Equal(t, "sent lifecycle sent "+destination.Address(), <-hook.events)
+
+		sender.Stop()
+	}
+}
+
+// circuitBreakerDrops returns the number of logs dropped so far because the circuit of address was open.
+func circuitBreakerDrops(address string) int64 {
+	if drops, ok := metrics.CircuitBreakerDrops.Get(address).(*expvar.Int); ok {
+		return drops.Value()
+	}
+	return 0
+}
+
+func TestSenderIsExpired(t *testing.T) {
+	sender := NewSender(nil, nil, nil, time.Hour, nil, BatchStrategy{})
+	source := config.NewLogSource("", &config.LogsConfig{})
+	sourceWithMaxAge := config.NewLogSource("", &config.LogsConfig{MaxMessageAge: 60})
+
+	msg := newMessage([]byte("fake line"), source, "")
+	assert.False(t, sender.isExpired(msg))
+
+	msg.Timestamp = "not a timestamp"
+	assert.False(t, sender.isExpired(msg))
+
+	msg.Timestamp = time.Now().Add(-30 * time.Minute).UTC().Format(time.RFC3339Nano)
+	assert.False(t, sender.isExpired(msg))
+	assert.True(t, sender.isExpired(newMessageWithTimestamp(sourceWithMaxAge, msg.Timestamp)))
+
+	msg.Timestamp = time.Now().Add(-2 * time.Hour).UTC().Format(config.DateFormat)
+	assert.True(t, sender.isExpired(msg))
+
+	sender = NewSender(nil, nil, nil, 0, nil, BatchStrategy{})
+	assert.False(t, sender.isExpired(msg))
+}
@@ -7,7 +7,9 @@ package status
 
 import (
 	"expvar"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
@@ -22,8 +24,21 @@ type Source struct {
 	Type          string                 `json:"type"`
 	Configuration map[string]interface{} `json:"configuration"`
 	Status        string                 `json:"status"`
-	Inputs        []string               `json:"inputs"`
-	Messages      []string               `json:"messages"`
+	// State is pending, running or error, LastError is kept once the source recovered
+	State     string   `json:"state"`
+	LastError string   `json:"last_error,omitempty"`
+	Inputs    []string `json:"inputs"`
+	Messages  []string `json:"messages"`
+}
+
+// SourceStatus provides the state of the collection of a logs source,
+// to tell exactly which sources are healthy.
+type SourceStatus struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	State     string   `json:"state"`
+	LastError string   `json:"last_error,omitempty"`
+	Inputs    []string `json:"inputs"`
 }
 
 // Integration provides some information about a logs integration.
@@ -32,22 +47,95 @@ type Integration struct {
 	Sources []Source `json:"sources"`
 }
 
+// DestinationHealth provides some information about the delivery of the logs to a destination.
+type DestinationHealth struct {
+	Address            string    `json:"address"`
+	LastSuccessfulSend time.Time `json:"last_successful_send"`
+	// SecondsSinceLastSuccessfulSend is -1 when no logs were ever sent successfully
+	SecondsSinceLastSuccessfulSend int64 `json:"seconds_since_last_successful_send"`
+}
+
+// Health provides some information about the delivery of the logs,
+// to tell apart a logs-agent collecting logs from one also sending them.
+type Health struct {
+	BlockedPipelines       int                 `json:"blocked_pipelines"`
+	BackpressuredPipelines int                 `json:"backpressured_pipelines"`
+	InputsBlocked          bool                `json:"inputs_blocked"`
+	Destinations           []DestinationHealth `json:"destinations"`
+	// ActiveDestinations holds the number of pipelines sending the logs to each address
+	// when the main endpoint has failover ones
+	ActiveDestinations map[string]int `json:"active_destinations,omitempty"`
+	// OpenCircuits holds the number of pipelines dropping the logs of each address
+	// because its circuit breaker is open
+	OpenCircuits map[string]int `json:"open_circuits,omitempty"`
+	// BufferUtilization holds the fill level of the buffers of the pipelines
+	BufferUtilization BufferUtilization `json:"buffer_utilization"`
+	// Backlog holds the logs waiting to be sent by the pipelines
+	Backlog Backlog `json:"backlog"`
+}
+
+// BufferUtilization provides the max and average fill level of the buffers of the pipelines,
+// in percentage of their capacity, to alert before the inputs get blocked.
+type BufferUtilization struct {
+	MaxInput  float64 `json:"max_input"`
+	AvgInput  float64 `json:"avg_input"`
+	MaxSender float64 `json:"max_sender"`
+	AvgSender float64 `json:"avg_sender"`
+}
+
+// Backlog provides the number of logs buffered by the pipelines waiting to be sent and how long
+// the oldest log being sent has been waiting since it entered its pipeline, to tell how far behind the delivery is.
+type Backlog struct {
+	Buffered         int64   `json:"buffered"`
+	OldestAgeSeconds float64 `json:"oldest_age_seconds"`
+}
+
+// NewHealth returns the health of the delivery of the logs, the destinations are sorted by address.
+func NewHealth(blockedPipelines, backpressuredPipelines int, lastSuccessfulSends map[string]time.Time) Health {
+	health := Health{
+		BlockedPipelines:       blockedPipelines,
+		BackpressuredPipelines: backpressuredPipelines,
+		InputsBlocked:          backpressuredPipelines > 0,
+		Destinations:           []DestinationHealth{},
+	}
+	now := time.Now()
+	for address, lastSuccess := range lastSuccessfulSends {
+		destination := DestinationHealth{
+			Address:                        address,
+			LastSuccessfulSend:             lastSuccess,
+			SecondsSinceLastSuccessfulSend: -1,
+		}
+		if !lastSuccess.IsZero() {
+			destination.SecondsSinceLastSuccessfulSend = int64(now.Sub(lastSuccess) / time.Second)
+		}
+		health.Destinations = append(health.Destinations, destination)
+	}
+	sort.Slice(health.Destinations, func(i, j int) bool {
+		return health.Destinations[i].Address < health.Destinations[j].Address
+	})
+	return health
+}
+
 // Status provides some information about logs-agent.
 type Status struct {
 	IsRunning    bool          `json:"is_running"`
 	Integrations []Integration `json:"integrations"`
 	Messages     []string      `json:"messages"`
+	Health       *Health       `json:"health,omitempty"`
 }
 
 // Builder is used to build the status.
 type Builder struct {
 	sources *config.LogSources
+	health  func() Health
 }
 
-// Initialize instantiates a builder that holds the sources required to build the current status later on.
-func Initialize(sources *config.LogSources) {
+// Initialize instantiates a builder that holds the sources required to build the current status later on,
+// health returns the state of the delivery of the logs, it can be nil.
+func Initialize(sources *config.LogSources, health func() Health) {
 	builder = &Builder{
 		sources: sources,
+		health:  health,
 	}
 }
 
@@ -90,6 +178,8 @@ func Get() Status {
 				Type:          source.Config.Type,
 				Configuration: toDictionary(source.Config),
 				Status:        status,
+				State:         source.Status.State(),
+				LastError:     source.Status.LastError(),
 				Inputs:        source.GetInputs(),
 				Messages:      source.Messages.GetMessages(),
 			})
@@ -106,11 +196,34 @@ func Get() Status {
 		warnings = append(warnings, warning)
 	}
 
-	return Status{
+	status := Status{
 		IsRunning:    true,
 		Integrations: integrations,
 		Messages:     warnings,
 	}
+	if builder.health != nil {
+		health := builder.health()
+		status.Health = &health
+	}
+	return status
+}
+
+// GetSourceStatuses returns the state of the collection of each of sources, sorted by name.
+func GetSourceStatuses(sources *config.LogSources) []SourceStatus {
+	statuses := []SourceStatus{}
+	for _, source := range sources.GetSources() {
+		statuses = append(statuses, SourceStatus{
+			Name:      source.Name,
+			Type:      source.Config.Type,
+			State:     source.Status.State(),
+			LastError: source.Status.LastError(),
+			Inputs:    source.GetInputs(),
+		})
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
 }
 
 // toDictionary returns a representation of the configuration
@@ -119,7 +232,7 @@ func toDictionary(c *config.LogsConfig) map[string]interface{} {
 	switch c.Type {
 	case config.TCPType, config.UDPType:
 		dictionary["Port"] = c.Port
-	case config.FileType:
+	case config.FileType, config.UnixType, config.UnixgramType, config.NamedPipeType:
 		dictionary["Path"] = c.Path
 	case config.DockerType:
 		dictionary["Image"] = c.Image
@@ -6,8 +6,10 @@
 package status
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -31,7 +33,7 @@ func createSources() *config.LogSources {
 	sources.AddSource(config.NewLogSource("foo", &config.LogsConfig{Type: "foo"}))
 	sources.AddSource(config.NewLogSource("bar", &config.LogsConfig{Type: "foo"}))
 	sources.AddSource(config.NewLogSource("foo", &config.LogsConfig{Type: "foo"}))
-	Initialize(sources)
+	Initialize(sources, nil)
 	return sources
 }
 
@@ -55,6 +57,35 @@ func TestSourceAreGroupedByIntegrations(t *testing.T) {
 	}
 }
 
+func TestSourceStatuses(t *testing.T) {
+	defer Clear()
+	sources := createSources()
+	logSources := sources.GetSources()
+	logSources[0].Status.Error(errors.New("permission denied"))
+	logSources[1].Status.Success()
+	logSources[2].Status.Error(errors.New("no such file"))
+	logSources[2].Status.Success()
+
+	assert.Equal(t, []SourceStatus{
+		{Name: "bar", Type: "foo", State: config.SourceRunning, Inputs: []string{}},
+		{Name: "foo", Type: "foo", State: config.SourceError, LastError: "permission denied", Inputs: []string{}},
+		{Name: "foo", Type: "foo", State: config.SourceRunning, LastError: "no such file", Inputs: []string{}},
+	}, GetSourceStatuses(sources))
+
+	// the state and the last error are part of the status page
+	for _, integration := range Get().Integrations {
+		if integration.Name != "foo" {
+			continue
+		}
+		assert.Equal(t, config.SourceError, integration.Sources[0].State)
+		assert.Equal(t, "Error: permission denied", integration.Sources[0].Status)
+		assert.Equal(t, "permission denied", integration.Sources[0].LastError)
+		assert.Equal(t, config.SourceRunning, integration.Sources[1].State)
+		assert.Equal(t, "OK", integration.Sources[1].Status)
+		assert.Equal(t, "no such file", integration.Sources[1].LastError)
+	}
+}
+
 func TestStatusDeduplicateWarnings(t *testing.T) {
 	defer Clear()
 	sources := createSources()
@@ -74,10 +105,31 @@ func TestStatusDeduplicateWarnings(t *testing.T) {
 func TestMetrics(t *testing.T) {
 	defer Clear()
 	Clear()
-	assert.Equal(t, metrics.LogsExpvars.String(), `{"DestinationErrors": 0, "IsRunning": false, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "Warnings": ""}`)
+	assert.Equal(t, metrics.LogsExpvars.String(), `{"CircuitBreakerDrops": {}, "CollectionLagBytes": {}, "ConnectionsRecycled": 0, "DestinationDrops": {}, "DestinationErrors": 0, "DiskBufferDrops": 0, "FilesQueued": 0, "InputBufferUtilization": {}, "IsRunning": false, "LifecycleHookDrops": 0, "LogsBuffered": 0, "LogsDecoded": 0, "LogsDeduplicated": 0, "LogsExpired": 0, "LogsNotJSON": 0, "LogsOverflowed": {}, "LogsProcessed": 0, "LogsRateLimited": {}, "LogsRejected": 0, "LogsRejectedByIntake": 0, "LogsSampled": 0, "LogsSampledOut": 0, "LogsSent": 0, "LogsSplit": 0, "LogsStatusNotParsed": 0, "LogsTimestampNotParsed": 0, "LogsTruncated": 0, "OldestLogAge": 0, "ReconnectsInProgress": 0, "SamplingRate": 1, "SenderBufferUtilization": {}, "ShortWrites": 0, "SlowConsumerTimeouts": 0, "Warnings": "", "WriteErrorReconnects": 0}`)
 
 	sources := createSources()
 	logSources := sources.GetSources()
 	logSources[0].Messages.AddWarning("bar", "Unique Warning")
-	assert.Equal(t, metrics.LogsExpvars.String(), `{"DestinationErrors": 0, "IsRunning": true, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "Warnings": "Unique Warning"}`)
+	assert.Equal(t, metrics.LogsExpvars.String(), `{"CircuitBreakerDrops": {}, "CollectionLagBytes": {}, "ConnectionsRecycled": 0, "DestinationDrops": {}, "DestinationErrors": 0, "DiskBufferDrops": 0, "FilesQueued": 0, "InputBufferUtilization": {}, "IsRunning": true, "LifecycleHookDrops": 0, "LogsBuffered": 0, "LogsDecoded": 0, "LogsDeduplicated": 0, "LogsExpired": 0, "LogsNotJSON": 0, "LogsOverflowed": {}, "LogsProcessed": 0, "LogsRateLimited": {}, "LogsRejected": 0, "LogsRejectedByIntake": 0, "LogsSampled": 0, "LogsSampledOut": 0, "LogsSent": 0, "LogsSplit": 0, "LogsStatusNotParsed": 0, "LogsTimestampNotParsed": 0, "LogsTruncated": 0, "OldestLogAge": 0, "ReconnectsInProgress": 0, "SamplingRate": 1, "SenderBufferUtilization": {}, "ShortWrites": 0, "SlowConsumerTimeouts": 0, "Warnings": "Unique Warning", "WriteErrorReconnects": 0}`)
+}
+
+func TestStatusHoldsTheHealthOfTheDelivery(t *testing.T) {
+	defer Clear()
+	createSources()
+	assert.Nil(t, Get().Health)
+
+	now := time.Now()
+	Initialize(config.NewLogSources(), func() Health {
+		return NewHealth(1, 2, map[string]time.Time{"foo:1234": now.Add(-time.Minute), "bar:1234": {}})
+	})
+	health := Get().Health
+	assert.NotNil(t, health)
+	assert.Equal(t, 1, health.BlockedPipelines)
+	assert.Equal(t, 2, health.BackpressuredPipelines)
+	assert.True(t, health.InputsBlocked)
+	assert.Equal(t, 2, len(health.Destinations))
+	assert.Equal(t, "bar:1234", health.Destinations[0].Address)
+	assert.Equal(t, int64(-1), health.Destinations[0].SecondsSinceLastSuccessfulSend)
+	assert.Equal(t, "foo:1234", health.Destinations[1].Address)
+	assert.Equal(t, int64(60), health.Destinations[1].SecondsSinceLastSuccessfulSend)
 }
@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/amqp"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/input/file"
+	"github.com/DataDog/datadog-agent/pkg/logs/input/journald"
+	"github.com/DataDog/datadog-agent/pkg/logs/input/listener"
+)
+
+// validationTimeout bounds each connection attempt to the endpoints.
+var validationTimeout = 5 * time.Second
+
+// ValidationError is a problem found by Validate in a source or an endpoint.
+type ValidationError struct {
+	// Source is the source in error, nil for an endpoint.
+	Source *config.LogSource
+	// Endpoint is the address of the endpoint in error, empty for a source.
+	Endpoint string
+	Err      error
+}
+
+// Error returns the error prefixed with the source or the endpoint it was found in.
+func (e ValidationError) Error() string {
+	if e.Source != nil {
+		return fmt.Sprintf("source %s: %v", e.Source.Name, e.Err)
+	}
+	return fmt.Sprintf("endpoint %s: %v", e.Endpoint, e.Err)
+}
+
+// Validate checks that the sources are well-formed and can be collected and that the endpoints accept connections,
+// without tailing anything nor starting the pipeline, and returns the errors found.
+// The ports of the network sources are bound then released right away, so they are reported
+// as in error when an agent is already listening on them.
+// With the error file conflict policy, the file sources whose paths can match the same files are in error.
+func Validate(sources *config.LogSources, endpoints *client.Endpoints) []ValidationError {
+	var errs []ValidationError
+	var conflicts map[*config.LogSource]error
+	if config.LogsAgent.GetString("logs_config.file_conflict_policy") == file.ErrorConflictPolicy {
+		conflicts = file.CheckConflicts(sources.GetSources())
+	}
+	for _, source := range sources.GetSources() {
+		if err := validateSource(source); err != nil {
+			errs = append(errs, ValidationError{Source: source, Err: err})
+		}
+		if err, exists := conflicts[source]; exists {
+			errs = append(errs, ValidationError{Source: source, Err: err})
+		}
+	}
+
+	if endpoints.AMQP != nil {
+		// the logs are only published to the broker
+		if err := amqp.CheckEndpoint(*endpoints.AMQP, validationTimeout); err != nil {
+			errs = append(errs, ValidationError{Endpoint: endpoints.AMQP.URL, Err: err})
+		}
+		return errs
+	}
+	for _, endpoint := range append([]client.Endpoint{endpoints.Main}, endpoints.Additionals...) {
+		ctx, cancel := context.WithTimeout(context.Background(), validationTimeout)
+		err := client.CheckEndpoint(ctx, endpoint)
+		cancel()
+		if err != nil {
+			address := net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.Port))
+			errs = append(errs, ValidationError{Endpoint: address, Err: err})
+		}
+	}
+	return errs
+}
+
+// validateSource returns an error if the config of source is invalid or if source can not be collected.
+func validateSource(source *config.LogSource) error {
+	if err := source.Config.Validate(); err != nil {
+		return err
+	}
+	switch source.Config.Type {
+	case config.FileType:
+		return file.CheckSource(source)
+	case config.JournaldType:
+		return journald.CheckSource(source)
+	case config.TCPType, config.UDPType, config.UnixType, config.UnixgramType:
+		return listener.CheckSource(source)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package logs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func TestValidate(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "logs-validate-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	path := fmt.Sprintf("%s/test.log", testDir)
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+
+	// a port in use can not be bound
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	_, usedPort := client.AddrToHostPort(l.Addr())
+
+	sources := config.NewLogSources()
+	validSources := []*config.LogSource{
+		config.NewLogSource("file", &config.LogsConfig{Type: config.FileType, Path: path}),
+		config.NewLogSource("glob", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir)}),
+		config.NewLogSource("docker", &config.LogsConfig{Type: config.DockerType}),
+	}
+	invalidSources := []*config.LogSource{
+		config.NewLogSource("missing_file", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/missing.log", testDir)}),
+		config.NewLogSource("empty_glob", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.txt", testDir)}),
+		config.NewLogSource("invalid_rule", &config.LogsConfig{Type: config.FileType, Path: path, ProcessingRules: []config.ProcessingRule{{Name: "foo", Type: config.ExcludeAtMatch, Pattern: "("}}}),
+		config.NewLogSource("used_port", &config.LogsConfig{Type: config.TCPType, Port: usedPort}),
+		config.NewLogSource("no_type", &config.LogsConfig{}),
+	}
+	for _, source := range append(validSources, invalidSources...) {
+		sources.AddSource(source)
+	}
+
+	intake := mock.NewMockLogsIntake(t)
+	defer intake.Close()
+	host, port := client.AddrToHostPort(intake.Addr())
+	// nothing listens on the port of a closed listener
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	_, closedPort := client.AddrToHostPort(closed.Addr())
+	closed.Close()
+	endpoints := client.NewEndpoints(client.Endpoint{Host: host, Port: port}, []client.Endpoint{{Host: "127.0.0.1", Port: closedPort}})
+
+	errs := Validate(sources, endpoints)
+	assert.Len(t, errs, len(invalidSources)+1)
+	for i, source := range invalidSources {
+		assert.True(t, errs[i].Source == source, source.Name)
+		assert.NotNil(t, errs[i].Err)
+	}
+	last := errs[len(errs)-1]
+	assert.Nil(t, last.Source)
+	assert.Equal(t, fmt.Sprintf("127.0.0.1:%d", closedPort), last.Endpoint)
+	assert.Contains(t, last.Error(), "endpoint 127.0.0.1:")
+}
+
+func TestValidateFileConflicts(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "logs-validate-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	path := fmt.Sprintf("%s/test.log", testDir)
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+
+	sources := config.NewLogSources()
+	fileSource := config.NewLogSource("file", &config.LogsConfig{Type: config.FileType, Path: path})
+	globSource := config.NewLogSource("glob", &config.LogsConfig{Type: config.FileType, Path: fmt.Sprintf("%s/*.log", testDir)})
+	sources.AddSource(fileSource)
+	sources.AddSource(globSource)
+
+	intake := mock.NewMockLogsIntake(t)
+	defer intake.Close()
+	host, port := client.AddrToHostPort(intake.Addr())
+	endpoints := client.NewEndpoints(client.Endpoint{Host: host, Port: port}, nil)
+
+	// the sources overlap but the conflicts are resolved with the precedence policy
+	assert.Len(t, Validate(sources, endpoints), 0)
+
+	config.LogsAgent.Set("logs_config.file_conflict_policy", "error")
+	defer config.LogsAgent.Set("logs_config.file_conflict_policy", "precedence")
+	errs := Validate(sources, endpoints)
+	assert.Len(t, errs, 2)
+	assert.True(t, errs[0].Source == fileSource)
+	assert.Contains(t, errs[0].Error(), "source file: path "+path+" can match the same files as sources glob")
+	assert.True(t, errs[1].Source == globSource)
+	assert.Contains(t, errs[1].Error(), "as sources file")
+}
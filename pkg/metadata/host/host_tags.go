@@ -90,3 +90,10 @@ func getHostTags() *tags {
 		GoogleCloudPlatform: gceTags,
 	}
 }
+
+// GetHostTags returns the tags of the host reported in the metadata payload, the ones of the configuration
+// and the ones collected from the cloud providers, the container runtimes and the orchestrators.
+func GetHostTags() []string {
+	hostTags := getHostTags()
+	return append(hostTags.System, hostTags.GoogleCloudPlatform...)
+}
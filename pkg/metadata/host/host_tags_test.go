@@ -16,6 +16,13 @@ func TestGetHostTags(t *testing.T) {
 	assert.Equal(t, []string{"tag1:value1", "tag2", "tag3"}, hostTags.System)
 }
 
+func TestGetHostTagsFlattensTheTags(t *testing.T) {
+	config.Datadog.Set("tags", []string{"tag1:value1", "tag2"})
+	defer config.Datadog.Set("tags", nil)
+
+	assert.Equal(t, []string{"tag1:value1", "tag2"}, GetHostTags())
+}
+
 func TestGetEmptyHostTags(t *testing.T) {
 	// getHostTags should never return a nil value under System even when there are no host tags
 	hostTags := getHostTags()
@@ -115,6 +115,25 @@ func isEnc(str string) (bool, string) {
 // testing purpose
 var secretFetcher = fetchSecret
 
+// DecryptHandle returns the secret of handle, the content of an ENC[] reference, by executing
+// "secret_backend_command" unless the secret is already in the cache,
+// returns an error if no secret_backend_command is set.
+func DecryptHandle(handle string) (string, error) {
+	if secretBackendCommand == "" {
+		return "", fmt.Errorf("secret_backend_command is not set, the secret '%s' can not be decrypted", handle)
+	}
+	if secret, ok := secretCache[handle]; ok {
+		log.Debugf("Secret '%s' was retrieved from cache", handle)
+		return secret, nil
+	}
+	secrets, err := secretFetcher([]string{handle})
+	if err != nil {
+		return "", err
+	}
+	log.Debugf("Secret '%s' was retrieved from executable", handle)
+	return secrets[handle], nil
+}
+
 // Decrypt replaces all encrypted secrets in data by executing
 // "secret_backend_command" once if all secrets aren't present in the cache.
 func Decrypt(data []byte) ([]byte, error) {
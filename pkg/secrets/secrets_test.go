@@ -232,3 +232,36 @@ func TestDecryptSecretFullCache(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, testConfDecrypted, newConf)
 }
+
+func TestDecryptHandle(t *testing.T) {
+	secretFetcher = func(secrets []string) (map[string]string, error) {
+		require.Fail(t, "No secret should be fetched without command")
+		return nil, nil
+	}
+	_, err := DecryptHandle("pass1")
+	require.NotNil(t, err)
+
+	secretBackendCommand = "some_command"
+	defer func() { secretBackendCommand = "" }()
+	secretCache["pass1"] = "password1"
+	defer func() { secretCache = map[string]string{} }()
+
+	secretFetcher = func(secrets []string) (map[string]string, error) {
+		assert.Equal(t, []string{"pass2"}, secrets)
+		return map[string]string{
+			"pass2": "password2",
+		}, nil
+	}
+	secret, err := DecryptHandle("pass1")
+	require.Nil(t, err)
+	assert.Equal(t, "password1", secret)
+	secret, err = DecryptHandle("pass2")
+	require.Nil(t, err)
+	assert.Equal(t, "password2", secret)
+
+	secretFetcher = func(secrets []string) (map[string]string, error) {
+		return nil, fmt.Errorf("some error")
+	}
+	_, err = DecryptHandle("pass3")
+	require.NotNil(t, err)
+}
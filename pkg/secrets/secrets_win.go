@@ -7,6 +7,8 @@
 
 package secrets
 
+import "fmt"
+
 // Init encrypted secrets are not available on windows
 func Init(command string, arguments []string, timeout int, maxSize int) {
 }
@@ -15,3 +17,8 @@ func Init(command string, arguments []string, timeout int, maxSize int) {
 func Decrypt(data []byte) ([]byte, error) {
 	return data, nil
 }
+
+// DecryptHandle encrypted secrets are not available on windows
+func DecryptHandle(handle string) (string, error) {
+	return "", fmt.Errorf("encrypted secrets are not available on windows, the secret '%s' can not be decrypted", handle)
+}